@@ -37,6 +37,34 @@ func Authorize(ctx engine.Context, input string) (runner.AuthorizerResponse, err
 	}, nil
 }
 
+const (
+	credentialAllow        = "Yes"
+	credentialAllowSession = "Yes, and don't ask again for this tool"
+	credentialDeny         = "No"
+)
+
+// AuthorizeCredential asks whether a tool can use a credential from the store.
+func AuthorizeCredential(ctx engine.Context, credName string) (runner.AuthorizerResponse, error) {
+	defer context.GetPauseFuncFromCtx(ctx.Ctx)()()
+
+	var result string
+	err := survey.AskOne(&survey.Select{
+		Help:    fmt.Sprintf("The full source of the tools is as follows:\n\n%s", ctx.Tool.String()),
+		Default: credentialAllow,
+		Message: CredentialConfirmMessage(ctx, credName),
+		Options: []string{credentialAllow, credentialAllowSession, credentialDeny},
+	}, &result)
+	if err != nil {
+		return runner.AuthorizerResponse{}, err
+	}
+
+	return runner.AuthorizerResponse{
+		Accept:   result != credentialDeny,
+		Remember: result == credentialAllowSession,
+		Message:  fmt.Sprintf("Use of credential %s denied, blocking execution.", credName),
+	}, nil
+}
+
 func IsSafe(ctx engine.Context) bool {
 	if !ctx.Tool.IsCommand() {
 		return true
@@ -48,10 +76,27 @@ func IsSafe(ctx engine.Context) bool {
 
 func ConfirmMessage(ctx engine.Context, input string) string {
 	var (
-		loc         = ctx.Tool.Source.Location
+		loc         = location(ctx)
 		interpreter = strings.Split(ctx.Tool.Instructions, "\n")[0][2:]
 	)
 
+	return fmt.Sprintf(`Description: %s
+  Interpreter: %s
+  Source: %s
+  Input: %s
+Allow the above tool to execute?`, ctx.Tool.Description, interpreter, loc, strings.TrimSpace(input))
+}
+
+func CredentialConfirmMessage(ctx engine.Context, credName string) string {
+	return fmt.Sprintf(`Tool: %s
+  Description: %s
+  Source: %s
+Allow the above tool to use the stored credential %s?`, ctx.Tool.Name, ctx.Tool.Description, location(ctx), credName)
+}
+
+func location(ctx engine.Context) string {
+	loc := ctx.Tool.Source.Location
+
 	if ctx.Tool.Source.Repo != nil {
 		loc = ctx.Tool.Source.Repo.Root
 		loc = strings.TrimPrefix(loc, "https://")
@@ -63,9 +108,5 @@ func ConfirmMessage(ctx engine.Context, input string) string {
 		loc = "Builtin"
 	}
 
-	return fmt.Sprintf(`Description: %s
-  Interpreter: %s
-  Source: %s
-  Input: %s
-Allow the above tool to execute?`, ctx.Tool.Description, interpreter, loc, strings.TrimSpace(input))
+	return loc
 }
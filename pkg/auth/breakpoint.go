@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+)
+
+const (
+	breakpointContinue = "Continue"
+	breakpointModify   = "Change the input and continue"
+	breakpointSkip     = "Skip the tool and return an output"
+)
+
+// Breakpoint shows the input of a tool that the run is paused before, and asks whether to continue, change the input,
+// or skip the tool.
+func Breakpoint(ctx engine.Context, input string) (runner.BreakpointResponse, error) {
+	defer context.GetPauseFuncFromCtx(ctx.Ctx)()()
+
+	var action string
+	err := survey.AskOne(&survey.Select{
+		Help:    fmt.Sprintf("The full source of the tool is as follows:\n\n%s", ctx.Tool.String()),
+		Default: breakpointContinue,
+		Message: BreakpointMessage(ctx, input),
+		Options: []string{breakpointContinue, breakpointModify, breakpointSkip},
+	}, &action)
+	if err != nil {
+		return runner.BreakpointResponse{}, err
+	}
+
+	switch action {
+	case breakpointModify:
+		newInput := input
+		if err := survey.AskOne(&survey.Editor{
+			Message:       "Input",
+			Default:       formatInput(input, ""),
+			AppendDefault: true,
+			HideDefault:   true,
+		}, &newInput); err != nil {
+			return runner.BreakpointResponse{}, err
+		}
+		newInput = strings.TrimSpace(newInput)
+		return runner.BreakpointResponse{
+			Input: &newInput,
+		}, nil
+	case breakpointSkip:
+		var output string
+		if err := survey.AskOne(&survey.Input{
+			Message: "Output to return instead:",
+		}, &output); err != nil {
+			return runner.BreakpointResponse{}, err
+		}
+		return runner.BreakpointResponse{
+			Skip:   true,
+			Output: output,
+		}, nil
+	}
+
+	return runner.BreakpointResponse{}, nil
+}
+
+func BreakpointMessage(ctx engine.Context, input string) string {
+	return fmt.Sprintf(`Breakpoint: %s
+  Description: %s
+  Source: %s
+  Input: %s
+How do you want to continue?`, ctx.Tool.Name, ctx.Tool.Description, location(ctx), formatInput(input, "  "))
+}
+
+// formatInput indents JSON input so that it is easier to read and edit.
+func formatInput(input, prefix string) string {
+	input = strings.TrimSpace(input)
+	var buf bytes.Buffer
+	if json.Valid([]byte(input)) && json.Indent(&buf, []byte(input), prefix, "  ") == nil {
+		return buf.String()
+	}
+	return input
+}
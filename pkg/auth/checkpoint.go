@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+)
+
+// Checkpoint shows a summary of the run so far and asks whether it should continue.
+func Checkpoint(ctx engine.Context, summary runner.CheckpointSummary) (runner.AuthorizerResponse, error) {
+	defer context.GetPauseFuncFromCtx(ctx.Ctx)()()
+
+	var result bool
+	err := survey.AskOne(&survey.Confirm{
+		Default: true,
+		Message: fmt.Sprintf("Checkpoint: %s\nNext tool: %s\nContinue the run?", summary, ctx.Tool.Name),
+	}, &result)
+	if err != nil {
+		return runner.AuthorizerResponse{}, err
+	}
+
+	return runner.AuthorizerResponse{
+		Accept:  result,
+		Message: "stopped by the user",
+	}, nil
+}
@@ -0,0 +1,476 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/counter"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	gopenai "github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+var (
+	log = mvl.Package()
+	// modelRegexp matches the IDs of Bedrock models, such as anthropic.claude-3-haiku-20240307-v1:0, optionally with
+	// the region prefix of a cross-region inference profile, and the ARNs of provisioned and custom models.
+	modelRegexp = regexp.MustCompile(`^((us|eu|apac)\.)?(anthropic|meta|mistral|amazon|cohere|ai21)\.[a-z0-9.-]+(:[a-z0-9]+)*$|^arn:aws[a-z-]*:bedrock:`)
+)
+
+// Client calls the models of Amazon Bedrock with the Converse API, using the credentials and region of the AWS
+// config, which are only loaded when a Bedrock model is first called. Like the OpenAI client, it sends the internal
+// system prompt of the options and calls their hooks.
+type Client struct {
+	cache        *cache.Client
+	hooks        *gopenai.ClientHooks
+	systemPrompt *gopenai.SystemPrompt
+
+	lock   sync.Mutex
+	client *bedrockruntime.Client
+}
+
+func NewClient(cache *cache.Client, opts ...gopenai.Options) (*Client, error) {
+	systemPrompt, err := gopenai.NewSystemPrompt(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cache:        cache,
+		hooks:        gopenai.Complete(opts...).Hooks,
+		systemPrompt: systemPrompt,
+	}, nil
+}
+
+// ListModels returns nothing, because Bedrock models are referred to by their IDs.
+func (c *Client) ListModels(context.Context, ...string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *Client) Supports(_ context.Context, modelName string) (bool, error) {
+	return modelRegexp.MatchString(modelName), nil
+}
+
+// Embeddings is not supported, because the embedding models of Bedrock are not served by the Converse API.
+func (c *Client) Embeddings(_ context.Context, model string, _ []string) ([][]float64, error) {
+	return nil, fmt.Errorf("embeddings are not supported for the Bedrock model %s", model)
+}
+
+func (c *Client) bedrockClient(ctx context.Context) (*bedrockruntime.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for Bedrock: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region is configured for Bedrock, set AWS_REGION or the region of the AWS profile")
+	}
+
+	c.client = bedrockruntime.NewFromConfig(cfg)
+	return c.client, nil
+}
+
+func (c *Client) cacheKey(request types.CompletionRequest, systemPrompt string) any {
+	return map[string]any{
+		"bedrock":      request,
+		"systemPrompt": systemPrompt,
+	}
+}
+
+func (c *Client) Call(ctx context.Context, messageRequest types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	systemPrompt, err := c.systemPrompt.Render(messageRequest.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hooks, such as the ones of quotas, get the request in the format of the OpenAI API, and the changes that
+	// they make to the model and its parameters are sent to Bedrock.
+	hookRequest := toHookRequest(messageRequest)
+	if c.hooks != nil && c.hooks.BeforeRequest != nil {
+		// Bedrock requests are signed by the AWS SDK, so the headers of the hook are not sent.
+		if err := c.hooks.BeforeRequest(ctx, &hookRequest, http.Header{}); err != nil {
+			return nil, err
+		}
+		messageRequest.Model = hookRequest.Model
+		messageRequest.MaxTokens = hookRequest.MaxTokens
+		messageRequest.Temperature = hookRequest.Temperature
+	}
+
+	input, err := toConverseInput(messageRequest, systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	id := counter.Next()
+	status <- types.CompletionStatus{
+		CompletionID: id,
+		Request:      messageRequest,
+		Candidate:    messageRequest.Candidate,
+	}
+
+	var (
+		responses []openai.ChatCompletionStreamResponse
+		cached    bool
+	)
+	if messageRequest.GetCache() {
+		cached, err = c.cache.Get(ctx, c.cacheKey(messageRequest, systemPrompt), &responses)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !cached {
+		responses, err = c.stream(ctx, input, id, messageRequest.Candidate, status)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.cache.Store(ctx, c.cacheKey(messageRequest, systemPrompt), responses); err != nil {
+			return nil, err
+		}
+	}
+
+	result := gopenai.ToCompletionMessage(ctx, responses)
+	if cached {
+		result.Usage = types.Usage{}
+	}
+
+	if c.hooks != nil && c.hooks.AfterResponse != nil {
+		if err := c.hooks.AfterResponse(ctx, hookRequest, &result, cached); err != nil {
+			return nil, err
+		}
+	}
+
+	status <- types.CompletionStatus{
+		CompletionID: id,
+		Chunks:       responses,
+		Response:     result,
+		Usage:        result.Usage,
+		Cached:       cached,
+		Candidate:    messageRequest.Candidate,
+		Model:        messageRequest.Model,
+	}
+
+	return &result, nil
+}
+
+func (c *Client) stream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, id string, candidate int, partial chan<- types.CompletionStatus) ([]openai.ChatCompletionStreamResponse, error) {
+	client, err := c.bedrockClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	partial <- types.CompletionStatus{
+		CompletionID: id,
+		PartialResponse: &types.CompletionMessage{
+			Role:    types.CompletionMessageRoleTypeAssistant,
+			Content: types.Text("Waiting for model response..."),
+		},
+		Placeholder: true,
+		Candidate:   candidate,
+	}
+
+	log.WithContext(ctx).Debugf("calling bedrock model %s", aws.ToString(input.ModelId))
+
+	output, err := client.ConverseStream(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	stream := output.GetStream()
+	defer stream.Close()
+
+	var (
+		responses      []openai.ChatCompletionStreamResponse
+		partialMessage types.CompletionMessage
+		state          streamState
+	)
+	for event := range stream.Events() {
+		response, ok := state.toStreamResponse(event)
+		if !ok {
+			continue
+		}
+		partialMessage = gopenai.AppendMessage(partialMessage, response)
+		partial <- types.CompletionStatus{
+			CompletionID:    id,
+			PartialResponse: &partialMessage,
+			Candidate:       candidate,
+		}
+		responses = append(responses, response)
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// streamState numbers the tool calls of a stream. Bedrock numbers every content block, including text.
+type streamState struct {
+	toolCalls map[int32]int
+}
+
+// toStreamResponse converts an event of a Converse stream into the OpenAI stream response with the same delta, or
+// returns false for events that have none.
+func (s *streamState) toStreamResponse(event brtypes.ConverseStreamOutput) (openai.ChatCompletionStreamResponse, bool) {
+	var (
+		response openai.ChatCompletionStreamResponse
+		choice   openai.ChatCompletionStreamChoice
+	)
+
+	switch e := event.(type) {
+	case *brtypes.ConverseStreamOutputMemberMessageStart:
+		choice.Delta.Role = string(e.Value.Role)
+	case *brtypes.ConverseStreamOutputMemberContentBlockStart:
+		start, ok := e.Value.Start.(*brtypes.ContentBlockStartMemberToolUse)
+		if !ok {
+			return response, false
+		}
+		if s.toolCalls == nil {
+			s.toolCalls = map[int32]int{}
+		}
+		index := len(s.toolCalls)
+		s.toolCalls[aws.ToInt32(e.Value.ContentBlockIndex)] = index
+		choice.Delta.ToolCalls = []openai.ToolCall{{
+			Index: &index,
+			ID:    aws.ToString(start.Value.ToolUseId),
+			Type:  openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name: aws.ToString(start.Value.Name),
+			},
+		}}
+	case *brtypes.ConverseStreamOutputMemberContentBlockDelta:
+		switch delta := e.Value.Delta.(type) {
+		case *brtypes.ContentBlockDeltaMemberText:
+			choice.Delta.Content = delta.Value
+		case *brtypes.ContentBlockDeltaMemberToolUse:
+			index, ok := s.toolCalls[aws.ToInt32(e.Value.ContentBlockIndex)]
+			if !ok {
+				return response, false
+			}
+			choice.Delta.ToolCalls = []openai.ToolCall{{
+				Index: &index,
+				Function: openai.FunctionCall{
+					Arguments: aws.ToString(delta.Value.Input),
+				},
+			}}
+		default:
+			return response, false
+		}
+	case *brtypes.ConverseStreamOutputMemberMessageStop:
+		choice.FinishReason = finishReason(e.Value.StopReason)
+	case *brtypes.ConverseStreamOutputMemberMetadata:
+		if usage := e.Value.Usage; usage != nil {
+			response.Usage = openai.Usage{
+				PromptTokens:     int(aws.ToInt32(usage.InputTokens)),
+				CompletionTokens: int(aws.ToInt32(usage.OutputTokens)),
+				TotalTokens:      int(aws.ToInt32(usage.TotalTokens)),
+			}
+		}
+		return response, true
+	default:
+		return response, false
+	}
+
+	response.Choices = []openai.ChatCompletionStreamChoice{choice}
+	return response, true
+}
+
+func finishReason(reason brtypes.StopReason) openai.FinishReason {
+	switch reason {
+	case brtypes.StopReasonToolUse:
+		return openai.FinishReasonToolCalls
+	case brtypes.StopReasonMaxTokens:
+		return openai.FinishReasonLength
+	case brtypes.StopReasonContentFiltered, brtypes.StopReasonGuardrailIntervened:
+		return openai.FinishReasonContentFilter
+	default:
+		return openai.FinishReasonStop
+	}
+}
+
+// toHookRequest converts a completion request to the chat completion request that the hooks get, with the text of
+// its messages.
+func toHookRequest(request types.CompletionRequest) openai.ChatCompletionRequest {
+	result := openai.ChatCompletionRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+	}
+	for _, message := range request.Messages {
+		result.Messages = append(result.Messages, openai.ChatCompletionMessage{
+			Role:    string(message.Role),
+			Content: message.String(),
+		})
+	}
+	return result
+}
+
+// toConverseInput converts a completion request to the input of the Converse API, which takes the system prompts
+// separately, and tool results as content of user messages.
+func toConverseInput(request types.CompletionRequest, internalSystemPrompt string) (*bedrockruntime.ConverseStreamInput, error) {
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId: aws.String(request.Model),
+		InferenceConfig: &brtypes.InferenceConfiguration{
+			Temperature:   request.Temperature,
+			TopP:          request.TopP,
+			StopSequences: request.Stop,
+		},
+	}
+	if input.InferenceConfig.Temperature == nil {
+		input.InferenceConfig.Temperature = new(float32)
+	}
+	if request.MaxTokens > 0 {
+		input.InferenceConfig.MaxTokens = aws.Int32(int32(request.MaxTokens))
+	}
+
+	if request.InternalSystemPrompt == nil || *request.InternalSystemPrompt {
+		input.System = append(input.System, &brtypes.SystemContentBlockMemberText{
+			Value: internalSystemPrompt,
+		})
+	}
+
+	for _, message := range request.Messages {
+		var (
+			role    = brtypes.ConversationRoleUser
+			content []brtypes.ContentBlock
+		)
+
+		switch message.Role {
+		case types.CompletionMessageRoleTypeSystem:
+			for _, part := range message.Content {
+				if part.Text != "" {
+					input.System = append(input.System, &brtypes.SystemContentBlockMemberText{
+						Value: part.Text,
+					})
+				}
+			}
+			continue
+		case types.CompletionMessageRoleTypeTool:
+			if message.ToolCall == nil {
+				return nil, fmt.Errorf("tool result message is missing its tool call")
+			}
+			content = append(content, &brtypes.ContentBlockMemberToolResult{
+				Value: brtypes.ToolResultBlock{
+					ToolUseId: aws.String(message.ToolCall.ID),
+					Content: []brtypes.ToolResultContentBlock{
+						&brtypes.ToolResultContentBlockMemberText{
+							// Bedrock rejects empty content.
+							Value: types.FirstSet(message.String(), "(no output)"),
+						},
+					},
+				},
+			})
+		case types.CompletionMessageRoleTypeAssistant:
+			role = brtypes.ConversationRoleAssistant
+			for _, part := range message.Content {
+				if part.ToolCall != nil {
+					args := map[string]any{}
+					if strings.TrimSpace(part.ToolCall.Function.Arguments) != "" {
+						if err := json.Unmarshal([]byte(part.ToolCall.Function.Arguments), &args); err != nil {
+							return nil, fmt.Errorf("invalid arguments of tool call %s: %w", part.ToolCall.Function.Name, err)
+						}
+					}
+					content = append(content, &brtypes.ContentBlockMemberToolUse{
+						Value: brtypes.ToolUseBlock{
+							ToolUseId: aws.String(part.ToolCall.ID),
+							Name:      aws.String(part.ToolCall.Function.Name),
+							Input:     document.NewLazyDocument(args),
+						},
+					})
+				} else if strings.TrimSpace(part.Text) != "" {
+					content = append(content, &brtypes.ContentBlockMemberText{
+						Value: part.Text,
+					})
+				}
+			}
+		default:
+			for _, part := range message.Content {
+				text := part.Text
+				if prompt, ok := system.IsDefaultPrompt(text); ok {
+					text = prompt
+				}
+				if !request.Chat && strings.TrimSpace(text) == "{}" {
+					continue
+				}
+				if strings.TrimSpace(text) != "" {
+					content = append(content, &brtypes.ContentBlockMemberText{
+						Value: text,
+					})
+				}
+			}
+		}
+
+		if len(content) == 0 {
+			continue
+		}
+		// Bedrock requires the roles to alternate, so consecutive messages of a role, such as the results of parallel
+		// tool calls, are sent as one.
+		if n := len(input.Messages); n > 0 && input.Messages[n-1].Role == role {
+			input.Messages[n-1].Content = append(input.Messages[n-1].Content, content...)
+			continue
+		}
+		input.Messages = append(input.Messages, brtypes.Message{
+			Role:    role,
+			Content: content,
+		})
+	}
+
+	if len(input.Messages) == 0 {
+		// Bedrock needs a user message, so one is added for tools that only have instructions.
+		input.Messages = append(input.Messages, brtypes.Message{
+			Role: brtypes.ConversationRoleUser,
+			Content: []brtypes.ContentBlock{
+				&brtypes.ContentBlockMemberText{
+					Value: "Follow the instructions.",
+				},
+			},
+		})
+	}
+
+	if len(request.Tools) > 0 {
+		input.ToolConfig = &brtypes.ToolConfiguration{}
+		for _, tool := range request.Tools {
+			schema := map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			}
+			if tool.Function.Parameters != nil && len(tool.Function.Parameters.Properties) > 0 {
+				// Documents are encoded without the JSON marshalers of the schema, so it is converted to plain values.
+				data, err := json.Marshal(tool.Function.Parameters)
+				if err != nil {
+					return nil, err
+				}
+				schema = map[string]any{}
+				if err := json.Unmarshal(data, &schema); err != nil {
+					return nil, err
+				}
+			}
+			input.ToolConfig.Tools = append(input.ToolConfig.Tools, &brtypes.ToolMemberToolSpec{
+				Value: brtypes.ToolSpecification{
+					Name:        aws.String(tool.Function.Name),
+					Description: aws.String(tool.Function.Description),
+					InputSchema: &brtypes.ToolInputSchemaMemberJson{
+						Value: document.NewLazyDocument(schema),
+					},
+				},
+			})
+		}
+	}
+
+	return input, nil
+}
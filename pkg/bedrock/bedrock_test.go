@@ -0,0 +1,195 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/getkin/kin-openapi/openapi3"
+	openai "github.com/gptscript-ai/chat-completion-client"
+	gopenai "github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupports(t *testing.T) {
+	c, err := NewClient(nil)
+	require.NoError(t, err)
+	for model, expected := range map[string]bool{
+		"anthropic.claude-3-haiku-20240307-v1:0":                       true,
+		"us.anthropic.claude-3-5-sonnet-20240620-v1:0":                 true,
+		"meta.llama3-1-70b-instruct-v1:0":                              true,
+		"mistral.mistral-large-2407-v1:0":                              true,
+		"amazon.titan-text-express-v1":                                 true,
+		"arn:aws:bedrock:us-east-1:123456789012:provisioned-model/abc": true,
+		"gpt-4o":   false,
+		"llama3.1": false,
+		"mistral":  false,
+		"claude-3-haiku-20240307 from github.com/gptscript-ai/claude3-anthropic-provider": false,
+	} {
+		ok, err := c.Supports(context.Background(), model)
+		require.NoError(t, err)
+		require.Equal(t, expected, ok, model)
+	}
+}
+
+func documentValue(t *testing.T, doc document.Interface) (result map[string]any) {
+	t.Helper()
+	data, err := doc.MarshalSmithyDocument()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &result))
+	return
+}
+
+func TestToConverseInput(t *testing.T) {
+	input, err := toConverseInput(types.CompletionRequest{
+		Model:     "anthropic.claude-3-haiku-20240307-v1:0",
+		MaxTokens: 100,
+		Tools: []types.CompletionTool{{
+			Function: types.CompletionFunctionDefinition{
+				Name:        "search",
+				Description: "Search the web",
+				Parameters: &openapi3.Schema{
+					Type: &openapi3.Types{"object"},
+					Properties: openapi3.Schemas{
+						"query": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{"string"}}},
+					},
+				},
+			},
+		}},
+		Messages: []types.CompletionMessage{
+			{Role: types.CompletionMessageRoleTypeSystem, Content: types.Text("Search for the input.")},
+			{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("gptscript")},
+			{Role: types.CompletionMessageRoleTypeAssistant, Content: []types.ContentPart{
+				{ToolCall: &types.CompletionToolCall{ID: "call_1", Function: types.CompletionFunctionCall{Name: "search", Arguments: `{"query": "gptscript"}`}}},
+				{ToolCall: &types.CompletionToolCall{ID: "call_2", Function: types.CompletionFunctionCall{Name: "search"}}},
+			}},
+			{Role: types.CompletionMessageRoleTypeTool, Content: types.Text("results"), ToolCall: &types.CompletionToolCall{ID: "call_1"}},
+			{Role: types.CompletionMessageRoleTypeTool, Content: types.Text(""), ToolCall: &types.CompletionToolCall{ID: "call_2"}},
+		},
+	}, "You are a helpful assistant.")
+	require.NoError(t, err)
+
+	require.Equal(t, "anthropic.claude-3-haiku-20240307-v1:0", aws.ToString(input.ModelId))
+	require.Equal(t, int32(100), aws.ToInt32(input.InferenceConfig.MaxTokens))
+	require.Equal(t, float32(0), *input.InferenceConfig.Temperature)
+
+	require.Len(t, input.System, 2)
+	require.Equal(t, "You are a helpful assistant.", input.System[0].(*brtypes.SystemContentBlockMemberText).Value)
+	require.Equal(t, "Search for the input.", input.System[1].(*brtypes.SystemContentBlockMemberText).Value)
+
+	// The results of the parallel tool calls are sent in one user message.
+	require.Len(t, input.Messages, 3)
+	require.Equal(t, brtypes.ConversationRoleUser, input.Messages[0].Role)
+	require.Equal(t, "gptscript", input.Messages[0].Content[0].(*brtypes.ContentBlockMemberText).Value)
+
+	require.Equal(t, brtypes.ConversationRoleAssistant, input.Messages[1].Role)
+	require.Len(t, input.Messages[1].Content, 2)
+	toolUse := input.Messages[1].Content[0].(*brtypes.ContentBlockMemberToolUse).Value
+	require.Equal(t, "call_1", aws.ToString(toolUse.ToolUseId))
+	require.Equal(t, map[string]any{"query": "gptscript"}, documentValue(t, toolUse.Input))
+	require.Equal(t, map[string]any{}, documentValue(t, input.Messages[1].Content[1].(*brtypes.ContentBlockMemberToolUse).Value.Input))
+
+	require.Equal(t, brtypes.ConversationRoleUser, input.Messages[2].Role)
+	require.Len(t, input.Messages[2].Content, 2)
+	result := input.Messages[2].Content[1].(*brtypes.ContentBlockMemberToolResult).Value
+	require.Equal(t, "call_2", aws.ToString(result.ToolUseId))
+	require.Equal(t, "(no output)", result.Content[0].(*brtypes.ToolResultContentBlockMemberText).Value)
+
+	require.Len(t, input.ToolConfig.Tools, 1)
+	spec := input.ToolConfig.Tools[0].(*brtypes.ToolMemberToolSpec).Value
+	require.Equal(t, "search", aws.ToString(spec.Name))
+	require.Equal(t, "object", documentValue(t, spec.InputSchema.(*brtypes.ToolInputSchemaMemberJson).Value)["type"])
+}
+
+func TestCallHooks(t *testing.T) {
+	var request openai.ChatCompletionRequest
+	c, err := NewClient(nil, gopenai.Options{
+		InternalSystemPrompt: "You are {{.Model}}.",
+		SystemPromptTemplate: true,
+		Hooks: &gopenai.ClientHooks{
+			BeforeRequest: func(_ context.Context, r *openai.ChatCompletionRequest, _ http.Header) error {
+				request = *r
+				return errors.New("quota exceeded")
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	systemPrompt, err := c.systemPrompt.Render("anthropic.claude-3-haiku-20240307-v1:0")
+	require.NoError(t, err)
+	require.Equal(t, "You are anthropic.claude-3-haiku-20240307-v1:0.", systemPrompt)
+
+	// A hook can veto the call before Bedrock is called.
+	_, err = c.Call(context.Background(), types.CompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+	}, make(chan types.CompletionStatus, 10))
+	require.EqualError(t, err, "quota exceeded")
+	require.Equal(t, "anthropic.claude-3-haiku-20240307-v1:0", request.Model)
+	require.Equal(t, "hi", request.Messages[0].Content)
+}
+
+func TestToStreamResponse(t *testing.T) {
+	events := []brtypes.ConverseStreamOutput{
+		&brtypes.ConverseStreamOutputMemberMessageStart{Value: brtypes.MessageStartEvent{Role: brtypes.ConversationRoleAssistant}},
+		&brtypes.ConverseStreamOutputMemberContentBlockDelta{Value: brtypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(0),
+			Delta:             &brtypes.ContentBlockDeltaMemberText{Value: "Searching"},
+		}},
+		&brtypes.ConverseStreamOutputMemberContentBlockStop{Value: brtypes.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(0)}},
+		&brtypes.ConverseStreamOutputMemberContentBlockStart{Value: brtypes.ContentBlockStartEvent{
+			ContentBlockIndex: aws.Int32(1),
+			Start:             &brtypes.ContentBlockStartMemberToolUse{Value: brtypes.ToolUseBlockStart{Name: aws.String("search"), ToolUseId: aws.String("call_1")}},
+		}},
+		&brtypes.ConverseStreamOutputMemberContentBlockDelta{Value: brtypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(1),
+			Delta:             &brtypes.ContentBlockDeltaMemberToolUse{Value: brtypes.ToolUseBlockDelta{Input: aws.String(`{"query":`)}},
+		}},
+		&brtypes.ConverseStreamOutputMemberContentBlockDelta{Value: brtypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(1),
+			Delta:             &brtypes.ContentBlockDeltaMemberToolUse{Value: brtypes.ToolUseBlockDelta{Input: aws.String(` "gptscript"}`)}},
+		}},
+		&brtypes.ConverseStreamOutputMemberContentBlockStart{Value: brtypes.ContentBlockStartEvent{
+			ContentBlockIndex: aws.Int32(2),
+			Start:             &brtypes.ContentBlockStartMemberToolUse{Value: brtypes.ToolUseBlockStart{Name: aws.String("search"), ToolUseId: aws.String("call_2")}},
+		}},
+		&brtypes.ConverseStreamOutputMemberContentBlockDelta{Value: brtypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(2),
+			Delta:             &brtypes.ContentBlockDeltaMemberToolUse{Value: brtypes.ToolUseBlockDelta{Input: aws.String(`{}`)}},
+		}},
+		&brtypes.ConverseStreamOutputMemberMessageStop{Value: brtypes.MessageStopEvent{StopReason: brtypes.StopReasonToolUse}},
+		&brtypes.ConverseStreamOutputMemberMetadata{Value: brtypes.ConverseStreamMetadataEvent{
+			Usage: &brtypes.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(5), TotalTokens: aws.Int32(15)},
+		}},
+	}
+
+	var (
+		state     streamState
+		responses []openai.ChatCompletionStreamResponse
+	)
+	for _, event := range events {
+		if response, ok := state.toStreamResponse(event); ok {
+			responses = append(responses, response)
+		}
+	}
+	require.Len(t, responses, 9)
+	require.Equal(t, openai.FinishReasonToolCalls, responses[7].Choices[0].FinishReason)
+
+	msg := gopenai.ToCompletionMessage(context.Background(), responses)
+	require.Equal(t, types.CompletionMessageRoleTypeAssistant, msg.Role)
+	require.Equal(t, types.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, msg.Usage)
+	// Like the text of OpenAI responses, the text is kept with the first tool call.
+	require.Len(t, msg.Content, 2)
+	require.Equal(t, "Searching", msg.Content[0].Text)
+	require.Equal(t, "call_1", msg.Content[0].ToolCall.ID)
+	require.Equal(t, `{"query": "gptscript"}`, msg.Content[0].ToolCall.Function.Arguments)
+	require.Equal(t, "call_2", msg.Content[1].ToolCall.ID)
+	require.Equal(t, "search", msg.Content[1].ToolCall.Function.Name)
+	require.Equal(t, "{}", msg.Content[1].ToolCall.Function.Arguments)
+}
@@ -19,8 +19,10 @@ import (
 	"time"
 
 	"github.com/BurntSushi/locker"
+	"github.com/gptscript-ai/gptscript/pkg/document"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/prompt"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/jaytaylor/html2text"
 )
@@ -31,9 +33,14 @@ var SafeTools = map[string]struct{}{
 	"sys.chat.history": {},
 	"sys.chat.current": {},
 	"sys.echo":         {},
+	"sys.agents":       {},
 	"sys.prompt":       {},
 	"sys.time.now":     {},
 	"sys.context":      {},
+	"sys.k8s.get":      {},
+	"sys.k8s.list":     {},
+	"sys.k8s.describe": {},
+	"sys.k8s.logs":     {},
 }
 
 var tools = map[string]types.Tool{
@@ -65,6 +72,17 @@ var tools = map[string]types.Tool{
 			BuiltinFunc: SysRead,
 		},
 	},
+	"sys.doc.extract": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Extracts the text of a PDF, DOCX, XLSX, or PPTX file, with a section for each page, sheet, or slide",
+				Arguments: types.ObjectSchema(
+					"filename", "The name of the document to extract the text of",
+					"format", "The format of the text, markdown or text. markdown if not set"),
+			},
+			BuiltinFunc: SysDocExtract,
+		},
+	},
 	"sys.write": {
 		ToolDef: types.ToolDef{
 			Parameters: types.Parameters{
@@ -239,6 +257,96 @@ var tools = map[string]types.Tool{
 			BuiltinFunc: SysChatCurrent,
 		},
 	},
+	"sys.email.read": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Searches a mailbox with IMAP and returns the headers and text of the newest matching messages. The server and login are read from the EMAIL_IMAP_ADDRESS, EMAIL_USERNAME, and EMAIL_PASSWORD environment variables",
+				Arguments: types.ObjectSchema(
+					"mailbox", "The mailbox to search, INBOX if not set",
+					"search", "IMAP search criteria such as UNSEEN, FROM \"alice@example.com\", or SINCE 1-Jan-2024. ALL if not set",
+					"limit", "The maximum number of messages to return, 10 if not set",
+					"maxSize", "The maximum number of bytes of each message to read, 65536 if not set"),
+			},
+			BuiltinFunc: SysEmailRead,
+		},
+	},
+	"sys.email.send": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Sends a plain text email with SMTP. The server and login are read from the EMAIL_SMTP_ADDRESS, EMAIL_USERNAME, EMAIL_PASSWORD, and optional EMAIL_FROM environment variables",
+				Arguments: types.ObjectSchema(
+					"to", "Comma separated addresses to send to",
+					"cc", "(optional) Comma separated addresses to copy",
+					"subject", "The subject of the email",
+					"body", "The plain text body of the email",
+					"inReplyTo", "(optional) The Message-ID of the email being replied to"),
+			},
+			BuiltinFunc: SysEmailSend,
+		},
+	},
+	"sys.k8s.get": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Gets a Kubernetes object as YAML, except secrets. The cluster is read from KUBECONFIG and the optional KUBE_CONTEXT environment variables, ~/.kube/config, or the service account of the pod when running in a cluster",
+				Arguments: types.ObjectSchema(
+					"kind", "The kind or resource of the object, such as pod, deployment, or deployments.apps",
+					"name", "The name of the object",
+					"namespace", "(optional) The namespace of the object, the default namespace of the context if not set"),
+			},
+			BuiltinFunc: SysK8sGet,
+		},
+	},
+	"sys.k8s.list": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Lists Kubernetes objects of a kind as a table, like kubectl get",
+				Arguments: types.ObjectSchema(
+					"kind", "The kind or resource of the objects, such as pods, deployments, or nodes",
+					"namespace", "(optional) The namespace of the objects, the default namespace of the context if not set",
+					"allNamespaces", "(optional) Set to true to list the objects of all namespaces",
+					"selector", "(optional) A label selector, such as app=web"),
+			},
+			BuiltinFunc: SysK8sList,
+		},
+	},
+	"sys.k8s.describe": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Describes a Kubernetes object other than a secret, with its YAML and recent events, like kubectl describe",
+				Arguments: types.ObjectSchema(
+					"kind", "The kind or resource of the object, such as pod, deployment, or deployments.apps",
+					"name", "The name of the object",
+					"namespace", "(optional) The namespace of the object, the default namespace of the context if not set"),
+			},
+			BuiltinFunc: SysK8sDescribe,
+		},
+	},
+	"sys.k8s.logs": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Returns the end of the log of a container of a Kubernetes pod",
+				Arguments: types.ObjectSchema(
+					"pod", "The name of the pod",
+					"namespace", "(optional) The namespace of the pod, the default namespace of the context if not set",
+					"container", "(optional) The container of the pod, required if the pod has more than one",
+					"tailLines", "(optional) The number of lines from the end of the log to return, default 100",
+					"previous", "(optional) Set to true for the log of the previous instance of a restarted container"),
+			},
+			BuiltinFunc: SysK8sLogs,
+		},
+	},
+	"sys.k8s.apply": {
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: "Applies a YAML manifest of Kubernetes objects with server-side apply. By default this is a server dry run that validates the objects without changing the cluster",
+				Arguments: types.ObjectSchema(
+					"manifest", "The YAML manifest of the objects, with documents separated by ---",
+					"namespace", "(optional) The namespace of the objects that do not set one, the default namespace of the context if not set",
+					"dryRun", "(optional) Set to false to change the cluster, default true"),
+			},
+			BuiltinFunc: SysK8sApply,
+		},
+	},
 	"sys.context": {
 		ToolDef: types.ToolDef{
 			Parameters: types.Parameters{
@@ -275,7 +383,7 @@ func Builtin(name string) (types.Tool, bool) {
 	return SetDefaults(t), ok
 }
 
-func SysFind(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysFind(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var result []string
 	var params struct {
 		Pattern   string `json:"pattern,omitempty"`
@@ -288,9 +396,13 @@ func SysFind(_ context.Context, _ []string, input string, _ chan<- string) (stri
 	if params.Directory == "" {
 		params.Directory = "."
 	}
+	dir, err := toolPath(ctx, params.Directory)
+	if err != nil {
+		return fmt.Sprintf("Failed to traverse directory %s: %v", params.Directory, err), nil
+	}
 
-	log.Debugf("Finding files %s in %s", params.Pattern, params.Directory)
-	err := fs.WalkDir(os.DirFS(params.Directory), ".", func(pathname string, d fs.DirEntry, err error) error {
+	log.WithContext(ctx).Debugf("Finding files %s in %s", params.Pattern, dir)
+	err = fs.WalkDir(os.DirFS(dir), ".", func(pathname string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -316,7 +428,7 @@ func SysFind(_ context.Context, _ []string, input string, _ chan<- string) (stri
 	return strings.Join(result, "\n"), nil
 }
 
-func SysExec(_ context.Context, env []string, input string, progress chan<- string) (string, error) {
+func SysExec(ctx context.Context, env []string, input string, progress chan<- string) (string, error) {
 	var params struct {
 		Command   string `json:"command,omitempty"`
 		Directory string `json:"directory,omitempty"`
@@ -325,11 +437,20 @@ func SysExec(_ context.Context, env []string, input string, progress chan<- stri
 		return invalidArgument(input, err), nil
 	}
 
+	// A command can open any file, so it can't be kept in the working directory.
+	if workDir, confined := engine.WorkDirFromContext(ctx); confined {
+		return fmt.Sprintf("ERROR: commands can't be run by tools that are confined to their working directory %s", workDir), nil
+	}
+
 	if params.Directory == "" {
 		params.Directory = "."
 	}
+	dir, err := toolPath(ctx, params.Directory)
+	if err != nil {
+		return fmt.Sprintf("ERROR: %s", err), nil
+	}
 
-	log.Debugf("Running %s in %s", params.Command, params.Directory)
+	log.WithContext(ctx).Debugf("Running %s in %s", params.Command, dir)
 
 	var cmd *exec.Cmd
 
@@ -352,7 +473,7 @@ func SysExec(_ context.Context, env []string, input string, progress chan<- stri
 	}
 
 	cmd.Env = env
-	cmd.Dir = params.Directory
+	cmd.Dir = dir
 	cmd.Stdout = combined
 	cmd.Stderr = combined
 	if err := cmd.Run(); err != nil {
@@ -417,7 +538,7 @@ func getWorkspaceDir(envs []string) (string, error) {
 	return "", fmt.Errorf("no workspace directory found in env")
 }
 
-func SysLs(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysLs(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Dir string `json:"dir,omitempty"`
 	}
@@ -429,6 +550,10 @@ func SysLs(_ context.Context, _ []string, input string, _ chan<- string) (string
 	if dir == "" {
 		dir = "."
 	}
+	dir, err := toolPath(ctx, dir)
+	if err != nil {
+		return fmt.Sprintf("Failed to read directory %s: %v", params.Dir, err), nil
+	}
 
 	entries, err := os.ReadDir(dir)
 	if errors.Is(err, fs.ErrNotExist) {
@@ -453,7 +578,7 @@ func SysLs(_ context.Context, _ []string, input string, _ chan<- string) (string
 	return strings.Join(result, "\n"), nil
 }
 
-func SysRead(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysRead(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Filename string `json:"filename,omitempty"`
 	}
@@ -461,13 +586,16 @@ func SysRead(_ context.Context, _ []string, input string, _ chan<- string) (stri
 		return invalidArgument(input, err), nil
 	}
 
-	file := params.Filename
+	file, err := toolPath(ctx, params.Filename)
+	if err != nil {
+		return fmt.Sprintf("Failed to read file %s: %v", params.Filename, err), nil
+	}
 
 	// Lock the file to prevent concurrent writes from other tool calls.
 	locker.RLock(file)
 	defer locker.RUnlock(file)
 
-	log.Debugf("Reading file %s", file)
+	log.WithContext(ctx).Debugf("Reading file %s", file)
 	data, err := os.ReadFile(file)
 	if errors.Is(err, fs.ErrNotExist) {
 		return fmt.Sprintf("The file %s does not exist", params.Filename), nil
@@ -481,7 +609,41 @@ func SysRead(_ context.Context, _ []string, input string, _ chan<- string) (stri
 	return string(data), nil
 }
 
-func SysWrite(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysDocExtract(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Filename string `json:"filename,omitempty"`
+		Format   string `json:"format,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+
+	file, err := toolPath(ctx, params.Filename)
+	if err != nil {
+		return fmt.Sprintf("Failed to extract text from %s: %v", params.Filename, err), nil
+	}
+
+	// Lock the file to prevent concurrent writes from other tool calls.
+	locker.RLock(file)
+	defer locker.RUnlock(file)
+
+	log.WithContext(ctx).Debugf("Extracting text from %s", file)
+	format := document.Format(strings.ToLower(params.Format))
+	sections, err := document.Extract(file, format)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("The file %s does not exist", params.Filename), nil
+	} else if err != nil {
+		return fmt.Sprintf("Failed to extract text from %s: %v", params.Filename, err), nil
+	}
+
+	text := document.Render(sections, format)
+	if strings.TrimSpace(text) == "" {
+		return fmt.Sprintf("The file %s has no text", params.Filename), nil
+	}
+	return text, nil
+}
+
+func SysWrite(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Filename string `json:"filename,omitempty"`
 		Content  string `json:"content,omitempty"`
@@ -490,7 +652,10 @@ func SysWrite(_ context.Context, _ []string, input string, _ chan<- string) (str
 		return invalidArgument(input, err), nil
 	}
 
-	file := params.Filename
+	file, err := toolPath(ctx, params.Filename)
+	if err != nil {
+		return fmt.Sprintf("Failed to write file %s: %v", params.Filename, err), nil
+	}
 
 	// Lock the file to prevent concurrent writes from other tool calls.
 	locker.Lock(file)
@@ -498,14 +663,14 @@ func SysWrite(_ context.Context, _ []string, input string, _ chan<- string) (str
 
 	dir := filepath.Dir(file)
 	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
-		log.Debugf("Creating dir %s", dir)
+		log.WithContext(ctx).Debugf("Creating dir %s", dir)
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return fmt.Sprintf("Failed to create directory %s: %v", dir, err.Error()), nil
 		}
 	}
 
 	data := []byte(params.Content)
-	log.Debugf("Wrote %d bytes to file %s", len(data), file)
+	log.WithContext(ctx).Debugf("Wrote %d bytes to file %s", len(data), file)
 
 	if err := os.WriteFile(file, data, 0644); err != nil {
 		return fmt.Sprintf("Failed to write file %s: %v", file, err.Error()), nil
@@ -513,7 +678,7 @@ func SysWrite(_ context.Context, _ []string, input string, _ chan<- string) (str
 	return fmt.Sprintf("Wrote (%d) bytes to file %s", len(data), file), nil
 }
 
-func SysAppend(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysAppend(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Filename string `json:"filename,omitempty"`
 		Content  string `json:"content,omitempty"`
@@ -522,11 +687,16 @@ func SysAppend(_ context.Context, _ []string, input string, _ chan<- string) (st
 		return invalidArgument(input, err), nil
 	}
 
+	file, err := toolPath(ctx, params.Filename)
+	if err != nil {
+		return fmt.Sprintf("Failed to open file %s: %v", params.Filename, err), nil
+	}
+
 	// Lock the file to prevent concurrent writes from other tool calls.
-	locker.Lock(params.Filename)
-	defer locker.Unlock(params.Filename)
+	locker.Lock(file)
+	defer locker.Unlock(file)
 
-	f, err := os.OpenFile(params.Filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return fmt.Sprintf("Failed to open file %s: %v", params.Filename, err.Error()), nil
 	}
@@ -538,7 +708,7 @@ func SysAppend(_ context.Context, _ []string, input string, _ chan<- string) (st
 		return fmt.Sprintf("Failed to write file %s: %v", params.Filename, err.Error()), nil
 	}
 
-	log.Debugf("Appended %d bytes to file %s", n, params.Filename)
+	log.WithContext(ctx).Debugf("Appended %d bytes to file %s", n, params.Filename)
 	return fmt.Sprintf("Appended (%d) bytes to file %s", n, params.Filename), nil
 }
 
@@ -569,7 +739,7 @@ func SysHTTPGet(_ context.Context, _ []string, input string, _ chan<- string) (_
 
 	params.URL = fixQueries(params.URL)
 
-	c := http.Client{Timeout: 10 * time.Second}
+	c := http.Client{Transport: proxy.Transport, Timeout: 10 * time.Second}
 
 	log.Debugf("http get %s", params.URL)
 	resp, err := c.Get(params.URL)
@@ -623,7 +793,7 @@ func SysHTTPPost(ctx context.Context, _ []string, input string, _ chan<- string)
 		req.Header.Set("Content-Type", params.ContentType)
 	}
 
-	c := http.Client{Timeout: 10 * time.Second}
+	c := http.Client{Transport: proxy.Transport, Timeout: 10 * time.Second}
 
 	resp, err := c.Do(req)
 	if err != nil {
@@ -771,7 +941,7 @@ func SysAbort(_ context.Context, _ []string, input string, _ chan<- string) (str
 	return "", fmt.Errorf("ABORT: %s", params.Message)
 }
 
-func SysRemove(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysRemove(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Location string `json:"location,omitempty"`
 	}
@@ -779,18 +949,23 @@ func SysRemove(_ context.Context, _ []string, input string, _ chan<- string) (st
 		return invalidArgument(input, err), nil
 	}
 
+	file, err := toolPath(ctx, params.Location)
+	if err != nil {
+		return fmt.Sprintf("Failed to removed %s: %v", params.Location, err), nil
+	}
+
 	// Lock the file to prevent concurrent writes from other tool calls.
-	locker.Lock(params.Location)
-	defer locker.Unlock(params.Location)
+	locker.Lock(file)
+	defer locker.Unlock(file)
 
-	if err := os.Remove(params.Location); err != nil {
+	if err := os.Remove(file); err != nil {
 		return fmt.Sprintf("Failed to removed %s: %v", params.Location, err), nil
 	}
 
 	return fmt.Sprintf("Removed file: %s", params.Location), nil
 }
 
-func SysStat(_ context.Context, _ []string, input string, _ chan<- string) (string, error) {
+func SysStat(ctx context.Context, _ []string, input string, _ chan<- string) (string, error) {
 	var params struct {
 		Filepath string `json:"filepath,omitempty"`
 	}
@@ -798,7 +973,12 @@ func SysStat(_ context.Context, _ []string, input string, _ chan<- string) (stri
 		return invalidArgument(input, err), nil
 	}
 
-	stat, err := os.Stat(params.Filepath)
+	file, err := toolPath(ctx, params.Filepath)
+	if err != nil {
+		return fmt.Sprintf("failed to stat %s: %s", params.Filepath, err), nil
+	}
+
+	stat, err := os.Stat(file)
 	if err != nil {
 		return fmt.Sprintf("failed to stat %s: %s", params.Filepath, err), nil
 	}
@@ -810,7 +990,7 @@ func SysStat(_ context.Context, _ []string, input string, _ chan<- string) (stri
 	return fmt.Sprintf("%s %s mode: %s, size: %d bytes, modtime: %s", title, params.Filepath, stat.Mode().String(), stat.Size(), stat.ModTime().String()), nil
 }
 
-func SysDownload(_ context.Context, env []string, input string, _ chan<- string) (_ string, err error) {
+func SysDownload(ctx context.Context, env []string, input string, _ chan<- string) (_ string, err error) {
 	var params struct {
 		URL      string `json:"url,omitempty"`
 		Location string `json:"location,omitempty"`
@@ -827,8 +1007,14 @@ func SysDownload(_ context.Context, env []string, input string, _ chan<- string)
 	if err != nil {
 		return "", err
 	}
+	if workDir, confined := engine.WorkDirFromContext(ctx); confined {
+		tmpDir = workDir
+	}
 
 	if params.Location != "" {
+		if params.Location, err = toolPath(ctx, params.Location); err != nil {
+			return fmt.Sprintf("failed to create [%s]: %v", params.Location, err), nil
+		}
 		if s, err := os.Stat(params.Location); err == nil && s.IsDir() {
 			tmpDir = params.Location
 			params.Location = ""
@@ -855,8 +1041,8 @@ func SysDownload(_ context.Context, env []string, input string, _ chan<- string)
 		}
 	}
 
-	log.Infof("download [%s] to [%s]", params.URL, params.Location)
-	resp, err := http.Get(params.URL)
+	log.WithContext(ctx).Infof("download [%s] to [%s]", params.URL, params.Location)
+	resp, err := proxy.Client.Get(params.URL)
 	if err != nil {
 		return fmt.Sprintf("failed to download %s: %v", params.URL, err), nil
 	}
@@ -0,0 +1,398 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaytaylor/html2text"
+)
+
+const (
+	defaultEmailLimit   = 10
+	defaultEmailMaxSize = 64 * 1024
+	maxEmailLimit       = 100
+)
+
+// emailSettings are read from the environment of the call, usually set by a credential of the calling tool.
+type emailSettings struct {
+	IMAPAddress string
+	SMTPAddress string
+	Username    string
+	Password    string
+	From        string
+}
+
+func getEmailSettings(env []string) emailSettings {
+	lookup := func(name string) string {
+		for i := len(env) - 1; i >= 0; i-- {
+			if v, ok := strings.CutPrefix(env[i], name+"="); ok {
+				return v
+			}
+		}
+		return os.Getenv(name)
+	}
+
+	settings := emailSettings{
+		IMAPAddress: lookup("EMAIL_IMAP_ADDRESS"),
+		SMTPAddress: lookup("EMAIL_SMTP_ADDRESS"),
+		Username:    lookup("EMAIL_USERNAME"),
+		Password:    lookup("EMAIL_PASSWORD"),
+		From:        lookup("EMAIL_FROM"),
+	}
+	if settings.From == "" {
+		settings.From = settings.Username
+	}
+	return settings
+}
+
+func SysEmailRead(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Mailbox string `json:"mailbox,omitempty"`
+		Search  string `json:"search,omitempty"`
+		Limit   string `json:"limit,omitempty"`
+		MaxSize string `json:"maxSize,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+
+	settings := getEmailSettings(env)
+	if settings.IMAPAddress == "" || settings.Username == "" {
+		return "EMAIL_IMAP_ADDRESS and EMAIL_USERNAME must be set, usually by a credential of the calling tool", nil
+	}
+
+	if params.Mailbox == "" {
+		params.Mailbox = "INBOX"
+	}
+	if params.Search == "" {
+		params.Search = "ALL"
+	}
+	limit, err := positiveInt(params.Limit, defaultEmailLimit)
+	if err != nil {
+		return invalidArgument(input, err), nil
+	}
+	limit = min(limit, maxEmailLimit)
+	maxSize, err := positiveInt(params.MaxSize, defaultEmailMaxSize)
+	if err != nil {
+		return invalidArgument(input, err), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	log.WithContext(ctx).Debugf("Searching %s of %s for %s", params.Mailbox, settings.Username, params.Search)
+	c, err := dialIMAP(ctx, settings.IMAPAddress)
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to %s: %v", settings.IMAPAddress, err), nil
+	}
+	defer c.Close()
+
+	if err := c.login(settings.Username, settings.Password); err != nil {
+		return fmt.Sprintf("Failed to log in to %s: %v", settings.IMAPAddress, err), nil
+	}
+	if err := c.selectMailbox(params.Mailbox); err != nil {
+		return fmt.Sprintf("Failed to open mailbox %s: %v", params.Mailbox, err), nil
+	}
+
+	uids, err := c.search(params.Search)
+	if err != nil {
+		return fmt.Sprintf("Failed to search mailbox %s: %v", params.Mailbox, err), nil
+	}
+	if len(uids) == 0 {
+		return "No messages found", nil
+	}
+
+	// Return the newest messages first.
+	slices.Sort(uids)
+	slices.Reverse(uids)
+	total := len(uids)
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+
+	messages, err := c.fetch(uids, maxSize)
+	if err != nil {
+		return fmt.Sprintf("Failed to fetch messages: %v", err), nil
+	}
+	slices.SortFunc(messages, func(a, b imapMessage) int {
+		return b.uid - a.uid
+	})
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Showing %d of %d messages\n", len(messages), total)
+	for _, msg := range messages {
+		buf.WriteString("\n")
+		buf.WriteString(formatEmail(msg, maxSize))
+	}
+	return buf.String(), nil
+}
+
+func formatEmail(msg imapMessage, maxSize int) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "UID: %d\n", msg.uid)
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg.data))
+	if err != nil {
+		fmt.Fprintf(&buf, "Failed to parse message: %v\n", err)
+		return buf.String()
+	}
+
+	decoder := new(mime.WordDecoder)
+	for _, key := range []string{"Message-ID", "Date", "From", "To", "Cc", "Subject"} {
+		if v := m.Header.Get(key); v != "" {
+			if decoded, err := decoder.DecodeHeader(v); err == nil {
+				v = decoded
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", key, v)
+		}
+	}
+
+	body, attachments := emailText(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if len(attachments) > 0 {
+		fmt.Fprintf(&buf, "Attachments: %s\n", strings.Join(attachments, ", "))
+	}
+	buf.WriteString("\n")
+	buf.WriteString(strings.TrimSpace(body))
+	buf.WriteString("\n")
+	if msg.size > maxSize {
+		fmt.Fprintf(&buf, "[Truncated, the message is %d bytes]\n", msg.size)
+	}
+	return buf.String()
+}
+
+// emailText returns the text of a message body, preferring text/plain over text/html parts, and the names of its
+// attachments. Bodies truncated by the size limit return as much text as could be read.
+func emailText(contentType, encoding string, body io.Reader) (string, []string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		var (
+			plain, html string
+			attachments []string
+			reader      = multipart.NewReader(body, params["boundary"])
+		)
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if name := part.FileName(); name != "" {
+				attachments = append(attachments, name)
+				continue
+			}
+			text, nested := emailText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			attachments = append(attachments, nested...)
+
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "text/html" {
+				html += text
+			} else if plain == "" {
+				plain = text
+			}
+		}
+		if plain == "" {
+			plain = html
+		}
+		return plain, attachments
+	case mediaType == "text/html":
+		data, _ := io.ReadAll(decodeTransfer(encoding, body))
+		text, err := html2text.FromString(string(data), html2text.Options{PrettyTables: true})
+		if err != nil {
+			return string(data), nil
+		}
+		return text, nil
+	case strings.HasPrefix(mediaType, "text/"):
+		data, _ := io.ReadAll(decodeTransfer(encoding, body))
+		return string(data), nil
+	}
+	return "", nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+func SysEmailSend(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		To        string `json:"to,omitempty"`
+		Cc        string `json:"cc,omitempty"`
+		Subject   string `json:"subject,omitempty"`
+		Body      string `json:"body,omitempty"`
+		InReplyTo string `json:"inReplyTo,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+
+	settings := getEmailSettings(env)
+	if settings.SMTPAddress == "" || settings.From == "" {
+		return "EMAIL_SMTP_ADDRESS and EMAIL_USERNAME or EMAIL_FROM must be set, usually by a credential of the calling tool", nil
+	}
+
+	to, err := parseAddresses(params.To)
+	if err != nil || len(to) == 0 {
+		return fmt.Sprintf("Invalid to addresses %q: %v", params.To, err), nil
+	}
+	cc, err := parseAddresses(params.Cc)
+	if err != nil {
+		return fmt.Sprintf("Invalid cc addresses %q: %v", params.Cc, err), nil
+	}
+	from, err := mail.ParseAddress(settings.From)
+	if err != nil {
+		return fmt.Sprintf("Invalid from address %q: %v", settings.From, err), nil
+	}
+
+	msg := buildEmail(from, to, cc, params.Subject, params.Body, params.InReplyTo)
+
+	var recipients []string
+	for _, addr := range slices.Concat(to, cc) {
+		recipients = append(recipients, addr.Address)
+	}
+
+	log.WithContext(ctx).Debugf("Sending email from %s to %s", from.Address, strings.Join(recipients, ", "))
+	if err := sendEmail(ctx, settings, from.Address, recipients, msg); err != nil {
+		return fmt.Sprintf("Failed to send email: %v", err), nil
+	}
+	return fmt.Sprintf("Sent email to %s", strings.Join(recipients, ", ")), nil
+}
+
+func parseAddresses(list string) ([]*mail.Address, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	return mail.ParseAddressList(list)
+}
+
+func buildEmail(from *mail.Address, to, cc []*mail.Address, subject, body, inReplyTo string) []byte {
+	join := func(addrs []*mail.Address) string {
+		var result []string
+		for _, addr := range addrs {
+			result = append(result, addr.String())
+		}
+		return strings.Join(result, ", ")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", join(to))
+	if len(cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", join(cc))
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(subject, "\n", " ")))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if inReplyTo = strings.TrimSpace(inReplyTo); inReplyTo != "" && !strings.ContainsAny(inReplyTo, "\r\n") {
+		fmt.Fprintf(&buf, "In-Reply-To: %s\r\nReferences: %s\r\n", inReplyTo, inReplyTo)
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func sendEmail(ctx context.Context, settings emailSettings, from string, to []string, msg []byte) error {
+	host, port, err := net.SplitHostPort(settings.SMTPAddress)
+	if err != nil {
+		host, port = settings.SMTPAddress, "587"
+	}
+	address := net.JoinHostPort(host, port)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if settings.Password != "" {
+		if err := c.Auth(smtp.PlainAuth("", settings.Username, settings.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func positiveInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if i <= 0 {
+		return 0, errors.New("must be greater than zero")
+	}
+	return i, nil
+}
@@ -0,0 +1,94 @@
+package builtin
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEmail = "Message-ID: <1@example.com>\r\n" +
+	"From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: =?utf-8?q?Caf=C3=A9?=\r\n" +
+	"Content-Type: multipart/mixed; boundary=b1\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: multipart/alternative; boundary=b2\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Lunch at the caf=C3=A9?\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>Lunch?</p>\r\n" +
+	"--b2--\r\n" +
+	"--b1\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=menu.pdf\r\n" +
+	"\r\n" +
+	"JVBERi0=\r\n" +
+	"--b1--\r\n"
+
+func TestFormatEmail(t *testing.T) {
+	out := formatEmail(imapMessage{uid: 7, size: len(testEmail), data: []byte(testEmail)}, defaultEmailMaxSize)
+	assert.Equal(t, `UID: 7
+Message-ID: <1@example.com>
+From: Alice <alice@example.com>
+To: bob@example.com
+Subject: Café
+Attachments: menu.pdf
+
+Lunch at the café?
+`, out)
+}
+
+func TestIMAPClient(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		respond := func(expected string, lines ...string) {
+			line, err := r.ReadString('\n')
+			if err != nil || !strings.HasSuffix(strings.TrimSpace(line), expected) {
+				_, _ = server.Write([]byte("* BAD unexpected " + line))
+				return
+			}
+			tag, _, _ := strings.Cut(line, " ")
+			for _, l := range lines {
+				_, _ = server.Write([]byte(l + "\r\n"))
+			}
+			_, _ = server.Write([]byte(tag + " OK done\r\n"))
+		}
+		respond(`LOGIN "bob" "p\"w"`)
+		respond(`EXAMINE "INBOX"`, "* 2 EXISTS")
+		respond("UID SEARCH UNSEEN", "* SEARCH 3 7")
+		respond("UID FETCH 7 (UID RFC822.SIZE BODY.PEEK[]<0.10>)", "* 1 FETCH (UID 7 RFC822.SIZE 300 BODY[]<0> {10}\r\nSubject: x)")
+	}()
+
+	c := &imapClient{conn: client, reader: bufio.NewReader(client)}
+	require.NoError(t, c.login("bob", `p"w`))
+	require.NoError(t, c.selectMailbox("INBOX"))
+
+	uids, err := c.search("UNSEEN")
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 7}, uids)
+
+	messages, err := c.fetch([]int{7}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []imapMessage{{uid: 7, size: 300, data: []byte("Subject: x")}}, messages)
+
+	// Strings that would end the command are not sent.
+	require.ErrorContains(t, c.selectMailbox("INBOX\r\nA1 DELETE Archive"), "invalid mailbox")
+	require.ErrorContains(t, c.login("bob", "p\x00w"), "invalid password")
+	_, err = c.search("UNSEEN\x00")
+	require.ErrorContains(t, err, "invalid search criteria")
+}
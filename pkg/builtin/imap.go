@@ -0,0 +1,249 @@
+package builtin
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapClient is just enough of an IMAP4rev1 client to search and fetch messages.
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is an untagged response with the contents of its literals inlined in order.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+func dialIMAP(ctx context.Context, address string) (*imapClient, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, "993"
+		address = net.JoinHostPort(host, port)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if port == "143" {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &imapClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		_ = conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %s", strings.TrimSpace(greeting))
+	}
+
+	if port == "143" {
+		if _, err := c.command("STARTTLS"); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		c.conn = tlsConn
+		c.reader = bufio.NewReader(tlsConn)
+	}
+
+	return c, nil
+}
+
+func (c *imapClient) Close() error {
+	_, _ = c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+func (c *imapClient) login(username, password string) error {
+	user, err := imapQuote("username", username)
+	if err != nil {
+		return err
+	}
+	pass, err := imapQuote("password", password)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("LOGIN " + user + " " + pass)
+	return err
+}
+
+func (c *imapClient) selectMailbox(mailbox string) error {
+	name, err := imapQuote("mailbox", mailbox)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("EXAMINE " + name)
+	return err
+}
+
+func (c *imapClient) search(criteria string) ([]int, error) {
+	if strings.ContainsAny(criteria, "\r\n\x00") {
+		return nil, fmt.Errorf("invalid search criteria %q", criteria)
+	}
+
+	responses, err := c.command("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []int
+	for _, resp := range responses {
+		rest, ok := strings.CutPrefix(resp.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			if uid, err := strconv.Atoi(field); err == nil {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	return uids, nil
+}
+
+type imapMessage struct {
+	uid  int
+	size int
+	data []byte
+}
+
+// fetch returns the size and the first maxSize bytes of the messages, without marking them as seen.
+func (c *imapClient) fetch(uids []int, maxSize int) ([]imapMessage, error) {
+	set := make([]string, len(uids))
+	for i, uid := range uids {
+		set[i] = strconv.Itoa(uid)
+	}
+
+	responses, err := c.command(fmt.Sprintf("UID FETCH %s (UID RFC822.SIZE BODY.PEEK[]<0.%d>)", strings.Join(set, ","), maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []imapMessage
+	for _, resp := range responses {
+		if !strings.Contains(resp.line, " FETCH ") {
+			continue
+		}
+		msg := imapMessage{
+			uid:  imapNumber(resp.line, "UID "),
+			size: imapNumber(resp.line, "RFC822.SIZE "),
+		}
+		if len(resp.literals) > 0 {
+			msg.data = resp.literals[0]
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+// command sends a command and returns its untagged responses, or an error if it does not complete with OK.
+func (c *imapClient) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		status, ok := strings.CutPrefix(resp.line, tag+" ")
+		if !ok {
+			responses = append(responses, resp)
+			continue
+		}
+		if !strings.HasPrefix(status, "OK") {
+			verb, _, _ := strings.Cut(cmd, " ")
+			return nil, fmt.Errorf("IMAP %s failed: %s", verb, status)
+		}
+		return responses, nil
+	}
+}
+
+// readResponse reads a response line, along with any literals it contains.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		size, ok := literalSize(line)
+		if !ok {
+			resp.line += line
+			return resp, nil
+		}
+
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return resp, err
+		}
+		resp.line += line
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndex(line, "{")
+	if i < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[i+1 : len(line)-1])
+	return size, err == nil
+}
+
+func imapNumber(line, key string) int {
+	_, rest, ok := strings.Cut(line, key)
+	if !ok {
+		return 0
+	}
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		rest = rest[:end]
+	}
+	n, _ := strconv.Atoi(rest)
+	return n
+}
+
+// imapQuote returns the string as an IMAP quoted string. Quoted strings can't have CR, LF, or NUL, which would end the
+// command or let the string add commands of its own, so strings with them are rejected.
+func imapQuote(name, s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return "", fmt.Errorf("invalid %s, it must not have line breaks or NUL characters", name)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
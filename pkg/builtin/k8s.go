@@ -0,0 +1,196 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/k8s"
+)
+
+const defaultK8sTailLines = 100
+
+// newK8sClient connects to the cluster of the KUBECONFIG and optional KUBE_CONTEXT in the environment of the call,
+// or ~/.kube/config, or the service account of the pod when running in a cluster.
+func newK8sClient(ctx context.Context, env []string) (*k8s.Client, error) {
+	lookup := func(name string) string {
+		for i := len(env) - 1; i >= 0; i-- {
+			if v, ok := strings.CutPrefix(env[i], name+"="); ok {
+				return v
+			}
+		}
+		return os.Getenv(name)
+	}
+
+	cfg, err := k8s.LoadConfig(lookup("KUBECONFIG"), lookup("KUBE_CONTEXT"))
+	if err != nil {
+		return nil, err
+	}
+	return k8s.NewClient(ctx, cfg)
+}
+
+// k8sResult returns the errors of the API server, such as objects that are not found or forbidden, as the result of
+// the call, so that the LLM can act on them.
+func k8sResult(result string, err error) (string, error) {
+	if apiErr := (*k8s.APIError)(nil); errors.As(err, &apiErr) {
+		return fmt.Sprintf("Error from server (%s): %s", apiErr.Reason, apiErr.Error()), nil
+	} else if errors.Is(err, k8s.ErrSecret) {
+		return err.Error(), nil
+	}
+	return result, err
+}
+
+func SysK8sGet(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Kind      string `json:"kind,omitempty"`
+		Name      string `json:"name,omitempty"`
+		Namespace string `json:"namespace,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+	if params.Kind == "" || params.Name == "" {
+		return "kind and name are required", nil
+	}
+
+	client, err := newK8sClient(ctx, env)
+	if err != nil {
+		return err.Error(), nil
+	}
+	return k8sResult(client.Get(ctx, params.Kind, params.Namespace, params.Name))
+}
+
+func SysK8sList(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Kind          string `json:"kind,omitempty"`
+		Namespace     string `json:"namespace,omitempty"`
+		AllNamespaces string `json:"allNamespaces,omitempty"`
+		Selector      string `json:"selector,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+	if params.Kind == "" {
+		return "kind is required", nil
+	}
+
+	client, err := newK8sClient(ctx, env)
+	if err != nil {
+		return err.Error(), nil
+	}
+	allNamespaces, _ := strconv.ParseBool(params.AllNamespaces)
+	return k8sResult(client.List(ctx, params.Kind, params.Namespace, allNamespaces, params.Selector))
+}
+
+func SysK8sDescribe(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Kind      string `json:"kind,omitempty"`
+		Name      string `json:"name,omitempty"`
+		Namespace string `json:"namespace,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+	if params.Kind == "" || params.Name == "" {
+		return "kind and name are required", nil
+	}
+
+	client, err := newK8sClient(ctx, env)
+	if err != nil {
+		return err.Error(), nil
+	}
+	return k8sResult(client.Describe(ctx, params.Kind, params.Namespace, params.Name))
+}
+
+func SysK8sLogs(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Pod       string `json:"pod,omitempty"`
+		Namespace string `json:"namespace,omitempty"`
+		Container string `json:"container,omitempty"`
+		TailLines string `json:"tailLines,omitempty"`
+		Previous  string `json:"previous,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+	if params.Pod == "" {
+		return "pod is required", nil
+	}
+
+	opts := k8s.LogOptions{
+		Container: params.Container,
+		TailLines: defaultK8sTailLines,
+	}
+	if params.TailLines != "" {
+		tailLines, err := strconv.Atoi(params.TailLines)
+		if err != nil || tailLines <= 0 {
+			return fmt.Sprintf("invalid tailLines %q, must be a positive number", params.TailLines), nil
+		}
+		opts.TailLines = tailLines
+	}
+	opts.Previous, _ = strconv.ParseBool(params.Previous)
+
+	client, err := newK8sClient(ctx, env)
+	if err != nil {
+		return err.Error(), nil
+	}
+	logs, err := client.Logs(ctx, params.Namespace, params.Pod, opts)
+	if err == nil && logs == "" {
+		logs = "The log is empty"
+	}
+	return k8sResult(logs, err)
+}
+
+func SysK8sApply(ctx context.Context, env []string, input string, _ chan<- string) (string, error) {
+	var params struct {
+		Manifest  string `json:"manifest,omitempty"`
+		Namespace string `json:"namespace,omitempty"`
+		DryRun    string `json:"dryRun,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return invalidArgument(input, err), nil
+	}
+	if strings.TrimSpace(params.Manifest) == "" {
+		return "manifest is required", nil
+	}
+
+	// Only an explicit false applies the manifest, so that a change is always validated first.
+	dryRun := true
+	if params.DryRun != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(params.DryRun); err != nil {
+			return fmt.Sprintf("invalid dryRun %q, must be true or false", params.DryRun), nil
+		}
+	}
+
+	client, err := newK8sClient(ctx, env)
+	if err != nil {
+		return err.Error(), nil
+	}
+
+	results, err := client.Apply(ctx, params.Manifest, params.Namespace, dryRun)
+
+	var lines []string
+	for _, result := range results {
+		line := result.String()
+		if dryRun {
+			line += " (server dry run)"
+		}
+		lines = append(lines, line)
+	}
+	if err != nil {
+		// The objects before the one that failed were applied.
+		result, err := k8sResult("", err)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, result)
+	} else if dryRun {
+		lines = append(lines, "Nothing was changed. Call again with dryRun set to false to apply the manifest.")
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,23 @@
+package builtin
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// toolPath returns the file of a path that a file tool was given. Relative paths are relative to the working directory
+// of the calling tool, if it has one, and paths outside of it are rejected when tools are confined to it.
+func toolPath(ctx context.Context, path string) (string, error) {
+	dir, confined := engine.WorkDirFromContext(ctx)
+	switch {
+	case dir == "":
+		return path, nil
+	case confined:
+		return engine.ConfinePath(dir, path)
+	case filepath.IsAbs(path):
+		return path, nil
+	}
+	return filepath.Join(dir, path), nil
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/gob"
@@ -19,13 +20,19 @@ import (
 )
 
 type Client struct {
-	dir  string
-	noop bool
+	dir       string
+	noop      bool
+	canonical bool
+	remote    *remote
+	chunkSize int64
 }
 
 type Options struct {
-	DisableCache bool   `usage:"Disable caching of LLM API responses"`
-	CacheDir     string `usage:"Directory to store cache (default: $XDG_CACHE_HOME/gptscript)"`
+	DisableCache       bool   `usage:"Disable caching of LLM API responses"`
+	CacheDir           string `usage:"Directory to store cache (default: $XDG_CACHE_HOME/gptscript)"`
+	CanonicalCacheKeys bool   `usage:"Ignore tool order, system prompt whitespace, and the user field when matching cached LLM responses"`
+	CacheURL           string `usage:"URL of a shared cache server of LLM responses, such as one started with gptscript cache serve, to read through and write to"`
+	CacheToken         string `usage:"Token to authenticate to the shared cache server"`
 }
 
 func init() {
@@ -37,6 +44,9 @@ func Complete(opts ...Options) (result Options) {
 	for _, opt := range opts {
 		result.CacheDir = types.FirstSet(opt.CacheDir, result.CacheDir)
 		result.DisableCache = types.FirstSet(opt.DisableCache, result.DisableCache)
+		result.CanonicalCacheKeys = types.FirstSet(opt.CanonicalCacheKeys, result.CanonicalCacheKeys)
+		result.CacheURL = types.FirstSet(opt.CacheURL, result.CacheURL)
+		result.CacheToken = types.FirstSet(opt.CacheToken, result.CacheToken)
 	}
 	if result.CacheDir == "" {
 		result.CacheDir = filepath.Join(xdg.CacheHome, version.ProgramName)
@@ -60,9 +70,16 @@ func New(opts ...Options) (*Client, error) {
 	if err := os.MkdirAll(opt.CacheDir, 0755); err != nil {
 		return nil, err
 	}
+	r, err := newRemote(opt.CacheURL, opt.CacheToken)
+	if err != nil {
+		return nil, err
+	}
 	return &Client{
-		dir:  opt.CacheDir,
-		noop: opt.DisableCache,
+		dir:       opt.CacheDir,
+		noop:      opt.DisableCache,
+		canonical: opt.CanonicalCacheKeys,
+		remote:    r,
+		chunkSize: defaultChunkSize,
 	}, nil
 }
 
@@ -70,6 +87,17 @@ func (c *Client) CacheDir() string {
 	return c.dir
 }
 
+// Shared returns true if the cache reads through and writes to a shared cache server.
+func (c *Client) Shared() bool {
+	return c != nil && c.remote != nil
+}
+
+// CanonicalKeys returns true if callers should canonicalize requests before using them as cache keys so that
+// equivalent requests share a cache entry.
+func (c *Client) CanonicalKeys() bool {
+	return c.canonical
+}
+
 func (c *Client) cacheKey(key any) (string, error) {
 	hash := sha256.New()
 	if err := json.NewEncoder(hash).Encode(key); err != nil {
@@ -100,13 +128,20 @@ func (c *Client) Store(ctx context.Context, key, value any) error {
 		return err
 	}
 
-	f, err := os.Create(filepath.Join(c.dir, keyValue))
-	if err != nil {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(value); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	return gob.NewEncoder(f).Encode(value)
+	if err := os.WriteFile(filepath.Join(c.dir, keyValue), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if err := c.remote.put(ctx, keyValue, bytes.NewReader(buf.Bytes())); err != nil {
+		// The shared cache is best effort, the value is still cached locally
+		log.Warnf("failed to store %s in shared cache: %v", keyValue, err)
+	}
+	return nil
 }
 
 func (c *Client) Get(ctx context.Context, key, out any) (bool, error) {
@@ -119,13 +154,22 @@ func (c *Client) Get(ctx context.Context, key, out any) (bool, error) {
 		return false, err
 	}
 
-	f, err := os.Open(filepath.Join(c.dir, keyValue))
+	data, err := os.ReadFile(filepath.Join(c.dir, keyValue))
 	if errors.Is(err, fs.ErrNotExist) {
-		return false, nil
+		data, err = c.remote.get(ctx, keyValue)
+		if err != nil {
+			log.Warnf("failed to read %s from shared cache: %v", keyValue, err)
+			return false, nil
+		} else if data == nil {
+			return false, nil
+		}
+		// Keep a local copy so the next lookup does not go to the network
+		if err := os.WriteFile(filepath.Join(c.dir, keyValue), data, 0644); err != nil {
+			return false, err
+		}
 	} else if err != nil {
 		return false, err
 	}
-	defer f.Close()
 
-	return gob.NewDecoder(f).Decode(out) == nil, nil
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(out) == nil, nil
 }
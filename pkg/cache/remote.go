@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+)
+
+// remote is a client for a shared cache server started with NewServer.
+type remote struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newRemote(baseURL, token string) (*remote, error) {
+	if baseURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid cache URL %q, must be an http or https URL", baseURL)
+	}
+
+	return &remote{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Transport: proxy.Transport,
+			Timeout:   30 * time.Second,
+		},
+	}, nil
+}
+
+func (r *remote) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+"/cache/"+key, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return req, nil
+}
+
+// get returns the value stored for the key, or nil if the server does not have it.
+func (r *remote) get(ctx context.Context, key string) ([]byte, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	req, err := r.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(io.LimitReader(resp.Body, maxValueSize))
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status from cache server: %s", resp.Status)
+	}
+}
+
+func (r *remote) put(ctx context.Context, key string, body io.Reader) error {
+	if r == nil {
+		return nil
+	}
+
+	req, err := r.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from cache server: %s", resp.Status)
+	}
+	return nil
+}
+
+// putFile uploads a file without reading it into memory.
+func (r *remote) putFile(ctx context.Context, key, path string) error {
+	if r == nil {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.put(ctx, key, f)
+}
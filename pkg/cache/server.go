@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// maxValueSize is the largest value the shared cache server accepts.
+const maxValueSize = 64 << 20
+
+// keyRegexp matches the key of a value, or the index or a chunk of a stream entry.
+var keyRegexp = regexp.MustCompile(`^[0-9a-f]{64}(\.(index|([0-9a-f]{16}\.)?[0-9]+))?$`)
+
+// NewServer returns a handler that serves the cache entries in dir so that they can be shared by clients configured
+// with a cache URL. If token is set, requests must authenticate with it as a bearer token.
+func NewServer(dir, token string) http.Handler {
+	s := &server{
+		dir:   dir,
+		token: token,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cache/{key}", s.get)
+	mux.HandleFunc("PUT /cache/{key}", s.put)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return s.authenticate(mux)
+}
+
+type server struct {
+	dir   string
+	token string
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && r.URL.Path != "/healthz" {
+			expected := "Bearer " + s.token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) path(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.PathValue("key")
+	if !keyRegexp.MatchString(key) {
+		http.Error(w, "invalid cache key", http.StatusBadRequest)
+		return "", false
+	}
+	return filepath.Join(s.dir, key), true
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.path(w, r)
+	if !ok {
+		return
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Errorf("failed to read cache entry %s: %v", path, err)
+		http.Error(w, "failed to read cache entry", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = io.Copy(w, f)
+}
+
+func (s *server) put(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.path(w, r)
+	if !ok {
+		return
+	}
+
+	// Write to a temporary file and rename so that readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		log.Errorf("failed to create cache entry: %v", err)
+		http.Error(w, "failed to store cache entry", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, http.MaxBytesReader(w, r.Body, maxValueSize))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+		http.Error(w, "cache entry too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to store cache entry", http.StatusBadRequest)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Errorf("failed to store cache entry %s: %v", path, err)
+		http.Error(w, "failed to store cache entry", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedCache(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(NewServer(t.TempDir(), "secret"))
+	defer srv.Close()
+
+	writer, err := New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL, CacheToken: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, writer.Store(ctx, "key", "value"))
+
+	// A client with an empty local cache reads through to the server
+	reader, err := New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL, CacheToken: "secret"})
+	require.NoError(t, err)
+	var value string
+	found, err := reader.Get(ctx, "key", &value)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	found, err = reader.Get(ctx, "missing", &value)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// The wrong token is treated as a miss
+	unauthorized, err := New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL, CacheToken: "wrong"})
+	require.NoError(t, err)
+	found, err = unauthorized.Get(ctx, "key", &value)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/cache/..%2fetc", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,443 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// defaultChunkSize is the size of the encoded values after which a stream entry starts a new chunk.
+const defaultChunkSize = 4 << 20
+
+// A stream entry is stored as gzip compressed chunks of gob encoded values, named <key>.<generation>.0,
+// <key>.<generation>.1, ..., and an index named <key>.index that is written last, so that an entry is only visible once
+// it is complete. Each writer of the key has a generation of its own, so that the chunks of an entry that is being
+// read are not replaced when the entry is written again.
+type index struct {
+	// Generation is empty for the entries that were written before chunks had generations.
+	Generation string  `json:"generation,omitempty"`
+	Chunks     []chunk `json:"chunks"`
+}
+
+type chunk struct {
+	Values int   `json:"values"`
+	Size   int64 `json:"size"`
+}
+
+func indexName(key string) string {
+	return key + ".index"
+}
+
+func chunkName(key, generation string, i int) string {
+	if generation == "" {
+		return key + "." + strconv.Itoa(i)
+	}
+	return key + "." + generation + "." + strconv.Itoa(i)
+}
+
+func newGeneration() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Writer stores a stream of values in the cache as they are produced, so that long streams are neither held in
+// memory twice nor written at once.
+type Writer struct {
+	c     *Client
+	ctx   context.Context
+	key   string
+	index index
+
+	file    *os.File
+	gz      *gzip.Writer
+	counter countingWriter
+	enc     *gob.Encoder
+	values  int
+
+	uploads   chan string
+	uploaded  sync.WaitGroup
+	uploadErr error
+	closed    bool
+}
+
+// NewWriter returns a writer for a stream entry. The writer is nil if the cache is disabled, in which case the entry
+// is removed, and all methods of a nil writer do nothing.
+func (c *Client) NewWriter(ctx context.Context, key any) (*Writer, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	keyValue, err := c.cacheKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.noop || IsNoCache(ctx) {
+		_ = os.Remove(filepath.Join(c.dir, indexName(keyValue)))
+		_ = os.Remove(filepath.Join(c.dir, keyValue))
+		return nil, nil
+	}
+
+	generation, err := newGeneration()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		c:   c,
+		ctx: ctx,
+		key: keyValue,
+		index: index{
+			Generation: generation,
+		},
+	}
+	if c.remote != nil {
+		w.uploads = make(chan string, 16)
+		w.uploaded.Add(1)
+		go w.upload()
+	}
+	return w, nil
+}
+
+// upload sends the finished chunks to the shared cache while the stream is still being written.
+func (w *Writer) upload() {
+	defer w.uploaded.Done()
+	for name := range w.uploads {
+		if w.uploadErr != nil {
+			continue
+		}
+		w.uploadErr = w.c.remote.putFile(w.ctx, name, filepath.Join(w.c.dir, name))
+	}
+}
+
+// Write adds a value to the stream.
+func (w *Writer) Write(value any) error {
+	if w == nil {
+		return nil
+	}
+	if w.closed {
+		return fmt.Errorf("cache writer for %s is closed", w.key)
+	}
+
+	if w.enc == nil {
+		f, err := os.CreateTemp(w.c.dir, ".chunk-*")
+		if err != nil {
+			return err
+		}
+		w.file = f
+		w.gz = gzip.NewWriter(f)
+		w.counter = countingWriter{w: w.gz}
+		// Every chunk has its own encoder so that it can be decoded without the chunks before it.
+		w.enc = gob.NewEncoder(&w.counter)
+		w.values = 0
+	}
+
+	if err := w.enc.Encode(value); err != nil {
+		return err
+	}
+	w.values++
+
+	if w.counter.n >= w.c.chunkSize {
+		return w.finishChunk()
+	}
+	return nil
+}
+
+func (w *Writer) finishChunk() error {
+	err := w.gz.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.enc = nil
+	if err != nil {
+		_ = os.Remove(w.file.Name())
+		return err
+	}
+
+	name := chunkName(w.key, w.index.Generation, len(w.index.Chunks))
+	if err := os.Rename(w.file.Name(), filepath.Join(w.c.dir, name)); err != nil {
+		_ = os.Remove(w.file.Name())
+		return err
+	}
+
+	w.index.Chunks = append(w.index.Chunks, chunk{
+		Values: w.values,
+		Size:   w.counter.n,
+	})
+	if w.uploads != nil {
+		w.uploads <- name
+	}
+	return nil
+}
+
+// Close finishes the last chunk and writes the index, which makes the entry visible to readers. The chunks of the entry
+// that it replaces are removed after.
+func (w *Writer) Close() error {
+	if w == nil || w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.enc != nil {
+		if err := w.finishChunk(); err != nil {
+			w.stopUploads()
+			w.removeChunks()
+			return err
+		}
+	}
+
+	data, err := json.Marshal(w.index)
+	if err != nil {
+		w.stopUploads()
+		w.removeChunks()
+		return err
+	}
+	var previous index
+	if data, err := os.ReadFile(filepath.Join(w.c.dir, indexName(w.key))); err == nil {
+		_ = json.Unmarshal(data, &previous)
+	}
+	if err := writeFile(w.c.dir, indexName(w.key), data); err != nil {
+		w.stopUploads()
+		w.removeChunks()
+		return err
+	}
+	if previous.Generation != w.index.Generation {
+		w.c.removeChunks(w.key, previous)
+	}
+
+	if w.uploads != nil {
+		w.stopUploads()
+		if err := w.uploadErr; err == nil {
+			err = w.c.remote.put(w.ctx, indexName(w.key), bytes.NewReader(data))
+			w.uploadErr = err
+		}
+		if w.uploadErr != nil {
+			// The shared cache is best effort, the value is still cached locally
+			log.Warnf("failed to store %s in shared cache: %v", w.key, w.uploadErr)
+		}
+	}
+	return nil
+}
+
+// Abort discards the stream if it was not closed. It is safe to defer it right after creating the writer.
+func (w *Writer) Abort() {
+	if w == nil || w.closed {
+		return
+	}
+	w.closed = true
+
+	if w.enc != nil {
+		_ = w.gz.Close()
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+	}
+	w.stopUploads()
+	w.removeChunks()
+}
+
+func (w *Writer) stopUploads() {
+	if w.uploads != nil {
+		close(w.uploads)
+		w.uploaded.Wait()
+		w.uploads = nil
+	}
+}
+
+func (w *Writer) removeChunks() {
+	w.c.removeChunks(w.key, w.index)
+}
+
+func (c *Client) removeChunks(key string, index index) {
+	for i := range index.Chunks {
+		_ = os.Remove(filepath.Join(c.dir, chunkName(key, index.Generation, i)))
+	}
+}
+
+// Reader reads the values of a stream entry one at a time, so only the chunk that is being read is loaded.
+type Reader struct {
+	c     *Client
+	ctx   context.Context
+	key   string
+	index index
+
+	next      int
+	remaining int
+	file      *os.File
+	gz        *gzip.Reader
+	dec       *gob.Decoder
+}
+
+// NewReader returns a reader for a stream entry and true, or false if there is no complete entry for the key.
+func (c *Client) NewReader(ctx context.Context, key any) (*Reader, bool, error) {
+	if c == nil || c.noop || IsNoCache(ctx) {
+		return nil, false, nil
+	}
+
+	keyValue, err := c.cacheKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := c.readOrFetch(ctx, indexName(keyValue))
+	if err != nil {
+		log.Warnf("failed to read %s from shared cache: %v", keyValue, err)
+		return nil, false, nil
+	} else if data == nil {
+		return nil, false, nil
+	}
+
+	r := &Reader{
+		c:   c,
+		ctx: ctx,
+		key: keyValue,
+	}
+	if err := json.Unmarshal(data, &r.index); err != nil {
+		return nil, false, nil
+	}
+	return r, true, nil
+}
+
+// Next decodes the next value of the stream into out, or returns io.EOF at the end of the stream.
+func (r *Reader) Next(out any) error {
+	for r.remaining == 0 {
+		if err := r.closeChunk(); err != nil {
+			return err
+		}
+		if r.next >= len(r.index.Chunks) {
+			return io.EOF
+		}
+		if err := r.openChunk(); err != nil {
+			return err
+		}
+	}
+
+	if err := r.dec.Decode(out); err != nil {
+		return fmt.Errorf("failed to decode cache entry %s: %w", r.key, err)
+	}
+	r.remaining--
+	return nil
+}
+
+// Skip moves past n values of the stream. Chunks that are skipped entirely are not read.
+func (r *Reader) Skip(n int) error {
+	for n > 0 {
+		if r.remaining == 0 && r.next < len(r.index.Chunks) && r.index.Chunks[r.next].Values <= n {
+			n -= r.index.Chunks[r.next].Values
+			r.next++
+			continue
+		}
+		if err := r.Next(nil); err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
+
+// Close releases the chunk that is being read.
+func (r *Reader) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.closeChunk()
+}
+
+func (r *Reader) openChunk() error {
+	name := chunkName(r.key, r.index.Generation, r.next)
+	if _, err := os.Stat(filepath.Join(r.c.dir, name)); errors.Is(err, fs.ErrNotExist) {
+		data, err := r.c.readOrFetch(r.ctx, name)
+		if err != nil {
+			return err
+		} else if data == nil {
+			return fmt.Errorf("cache entry %s is missing chunk %d", r.key, r.next)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(r.c.dir, name))
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to read cache entry %s: %w", r.key, err)
+	}
+
+	r.file = f
+	r.gz = gz
+	r.dec = gob.NewDecoder(gz)
+	r.remaining = r.index.Chunks[r.next].Values
+	r.next++
+	return nil
+}
+
+func (r *Reader) closeChunk() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.gz.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file, r.gz, r.dec = nil, nil, nil
+	return err
+}
+
+// readOrFetch returns the content of a local cache file, or else reads it from the shared cache and keeps a local
+// copy. It returns nil if neither has it.
+func (c *Client) readOrFetch(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+
+	data, err = c.remote.get(ctx, name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return data, writeFile(c.dir, name, data)
+}
+
+// writeFile writes to a temporary file and renames it, so that readers never see a partial file.
+func writeFile(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".write-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
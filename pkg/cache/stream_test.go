@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(NewServer(t.TempDir(), ""))
+	defer srv.Close()
+
+	writer, err := New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL})
+	require.NoError(t, err)
+	writer.chunkSize = 100
+
+	w, err := writer.NewWriter(ctx, "key")
+	require.NoError(t, err)
+	for i := range 100 {
+		require.NoError(t, w.Write(fmt.Sprintf("value %d", i)))
+
+		// Nothing can be read before the writer is closed.
+		_, found, err := writer.NewReader(ctx, "key")
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+	require.NoError(t, w.Close())
+
+	keyValue, err := writer.cacheKey("key")
+	require.NoError(t, err)
+	chunks, err := filepath.Glob(filepath.Join(writer.dir, keyValue+".*.[0-9]*"))
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+
+	// A client with an empty local cache reads the chunks through the server.
+	reader, err := New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL})
+	require.NoError(t, err)
+	r, found, err := reader.NewReader(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	for i := range 100 {
+		var value string
+		require.NoError(t, r.Next(&value))
+		require.Equal(t, fmt.Sprintf("value %d", i), value)
+	}
+	require.Equal(t, io.EOF, r.Next(new(string)))
+	require.NoError(t, r.Close())
+
+	// Skipped values are not returned, whether they are in the current chunk or in later ones.
+	r, found, err = reader.NewReader(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	defer r.Close()
+	var value string
+	require.NoError(t, r.Skip(1))
+	require.NoError(t, r.Next(&value))
+	require.Equal(t, "value 1", value)
+	require.NoError(t, r.Skip(90))
+	require.NoError(t, r.Next(&value))
+	require.Equal(t, "value 92", value)
+}
+
+func TestStreamRewrite(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c.chunkSize = 10
+
+	write := func(prefix string) {
+		w, err := c.NewWriter(ctx, "key")
+		require.NoError(t, err)
+		for i := range 10 {
+			require.NoError(t, w.Write(fmt.Sprintf("%s %d", prefix, i)))
+		}
+		require.NoError(t, w.Close())
+	}
+	write("old")
+
+	// A reader of the entry keeps reading it when the entry is written again, instead of reading the new chunks.
+	r, found, err := c.NewReader(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	defer r.Close()
+	var value string
+	require.NoError(t, r.Next(&value))
+	require.Equal(t, "old 0", value)
+
+	write("new")
+	require.NoError(t, r.Next(&value))
+	require.Equal(t, "old 1", value)
+
+	r, found, err = c.NewReader(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	defer r.Close()
+	for i := range 10 {
+		require.NoError(t, r.Next(&value))
+		require.Equal(t, fmt.Sprintf("new %d", i), value)
+	}
+
+	// The chunks of the entry that was replaced are removed.
+	keyValue, err := c.cacheKey("key")
+	require.NoError(t, err)
+	chunks, err := filepath.Glob(filepath.Join(c.dir, keyValue+".*.[0-9]*"))
+	require.NoError(t, err)
+	require.Len(t, chunks, len(r.index.Chunks))
+}
+
+func TestStreamAbort(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c.chunkSize = 10
+
+	w, err := c.NewWriter(ctx, "key")
+	require.NoError(t, err)
+	defer w.Abort()
+	for i := range 10 {
+		require.NoError(t, w.Write(i))
+	}
+	w.Abort()
+
+	_, found, err := c.NewReader(ctx, "key")
+	require.NoError(t, err)
+	require.False(t, found)
+	entries, err := os.ReadDir(c.dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Without caching, there is no writer and writing does nothing.
+	w, err = c.NewWriter(WithNoCache(ctx), "key")
+	require.NoError(t, err)
+	require.Nil(t, w)
+	require.NoError(t, w.Write(1))
+	require.NoError(t, w.Close())
+}
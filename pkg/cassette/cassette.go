@@ -0,0 +1,259 @@
+// Package cassette records the HTTP interactions of model clients to a file and replays them later so that runs
+// against model providers can be reproduced without network access.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ModeAuto   = "auto"
+	ModeRecord = "record"
+	ModeReplay = "replay"
+
+	redacted = "[REDACTED]"
+)
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Cassette is a file of recorded interactions shared by every client that uses the same path.
+type Cassette struct {
+	path         string
+	replay       bool
+	lock         sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+var (
+	cassettesLock sync.Mutex
+	cassettes     = map[string]*Cassette{}
+)
+
+// Open returns the cassette at path. In auto mode, an existing cassette is replayed and a missing one is recorded.
+// Opening the same path more than once returns the same cassette so that all clients of a run record to one file.
+func Open(path, mode string) (*Cassette, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cassettesLock.Lock()
+	defer cassettesLock.Unlock()
+
+	if c, ok := cassettes[abs]; ok {
+		return c, nil
+	}
+
+	c, err := load(abs, mode)
+	if err != nil {
+		return nil, err
+	}
+	cassettes[abs] = c
+	return c, nil
+}
+
+func load(path, mode string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{
+		path: path,
+	}
+	switch mode {
+	case "", ModeAuto:
+		c.replay = data != nil
+	case ModeRecord:
+	case ModeReplay:
+		if data == nil {
+			return nil, fmt.Errorf("cassette %s not found", path)
+		}
+		c.replay = true
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q, must be one of %s, %s, or %s", mode, ModeAuto, ModeRecord, ModeReplay)
+	}
+
+	if c.replay {
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+	}
+
+	return c, nil
+}
+
+// Replaying returns true if requests are served from the cassette instead of the network.
+func (c *Cassette) Replaying() bool {
+	return c.replay
+}
+
+// Transport returns a round tripper that records to or replays from the cassette. Requests are sent with base
+// when recording.
+func (c *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{
+		cassette: c,
+		base:     base,
+	}
+}
+
+type transport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if t.cassette.replay {
+		return t.cassette.replayRequest(req, string(body))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.cassette.record(Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    ScrubURL(req.URL),
+			Header: Scrub(req.Header),
+			Body:   string(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     Scrub(resp.Header),
+			Body:       string(respBody),
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+func (c *Cassette) record(interaction Interaction) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.interactions = append(c.interactions, interaction)
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// replayRequest returns the response of the first unused interaction with the same method, URL, and body.
+func (c *Cassette) replayRequest(req *http.Request, body string) (*http.Response, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != ScrubURL(req.URL) || interaction.Request.Body != body {
+			continue
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no interaction recorded in cassette %s for %s %s", c.path, req.Method, ScrubURL(req.URL))
+}
+
+func isSecret(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "auth") || strings.Contains(lower, "key") || strings.Contains(lower, "token") ||
+		strings.Contains(lower, "secret") || strings.Contains(lower, "cookie") || lower == "openai-organization"
+}
+
+// Scrub replaces the values of headers that may contain secrets.
+func Scrub(header http.Header) http.Header {
+	result := header.Clone()
+	for key := range result {
+		if isSecret(key) {
+			result[key] = []string{redacted}
+		}
+	}
+	return result
+}
+
+// ScrubURL replaces the values of query parameters that may contain secrets, such as API keys.
+func ScrubURL(u *url.URL) string {
+	query := u.Query()
+	var changed bool
+	for key := range query {
+		if isSecret(key) {
+			query[key] = []string{redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	scrubbed := *u
+	scrubbed.RawQuery = query.Encode()
+	return scrubbed.String()
+}
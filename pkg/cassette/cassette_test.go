@@ -0,0 +1,67 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte("echo " + string(body)))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	send := func(c *Cassette, body string) (string, error) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions?api_key=sk-secret", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer sk-secret")
+		resp, err := (&http.Client{Transport: c.Transport(http.DefaultTransport)}).Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	recorder, err := load(path, ModeAuto)
+	require.NoError(t, err)
+	require.False(t, recorder.Replaying())
+	out, err := send(recorder, "one")
+	require.NoError(t, err)
+	require.Equal(t, "echo one", out)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "sk-secret")
+	require.NotContains(t, string(data), "session=abc")
+
+	replayer, err := load(path, ModeAuto)
+	require.NoError(t, err)
+	require.True(t, replayer.Replaying())
+	out, err = send(replayer, "one")
+	require.NoError(t, err)
+	require.Equal(t, "echo one", out)
+	require.Equal(t, 1, calls)
+
+	// Each interaction is replayed once and unknown requests fail
+	_, err = send(replayer, "one")
+	require.ErrorContains(t, err, "no interaction recorded")
+	_, err = send(replayer, "two")
+	require.ErrorContains(t, err, "no interaction recorded")
+
+	_, err = load(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	require.Error(t, err)
+}
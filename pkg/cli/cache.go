@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	cmd2 "github.com/gptscript-ai/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes"
+	"github.com/spf13/cobra"
+)
+
+type Cache struct {
+	root *GPTScript
+}
+
+func (c *Cache) Customize(cmd *cobra.Command) {
+	cmd.Use = "cache"
+	cmd.Short = "Manage the cache of LLM responses and downloaded tools"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&CacheServe{root: c.root}))
+	cmd.AddCommand(cmd2.Command(&CacheUsage{root: c.root}))
+	cmd.AddCommand(cmd2.Command(&CachePrune{root: c.root}))
+}
+
+func (c *Cache) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type CacheServe struct {
+	root          *GPTScript
+	ListenAddress string `usage:"Address to listen on" default:"127.0.0.1:9595" env:"GPTSCRIPT_CACHE_SERVER_LISTEN_ADDRESS"`
+	Token         string `usage:"Token that clients must send as a bearer token, no authentication if empty" env:"GPTSCRIPT_CACHE_SERVER_TOKEN"`
+}
+
+func (c *CacheServe) Customize(cmd *cobra.Command) {
+	cmd.Use = "serve"
+	cmd.Short = "Serve the cached LLM responses over HTTP so they can be shared with --cache-url"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *CacheServe) Run(cmd *cobra.Command, _ []string) error {
+	opts := cache.Complete(cache.Options(c.root.CacheOptions))
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", c.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", c.ListenAddress, err)
+	}
+
+	server := &http.Server{
+		Handler:           cache.NewServer(opts.CacheDir, c.Token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	context.AfterFunc(cmd.Context(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+
+	if c.Token == "" {
+		log.Warnf("Serving cache without authentication")
+	}
+	log.Infof("Serving cache %s on http://%s", opts.CacheDir, listener.Addr())
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type CacheUsage struct {
+	root *GPTScript
+}
+
+func (c *CacheUsage) Customize(cmd *cobra.Command) {
+	cmd.Use = "usage"
+	cmd.Short = "Show the disk space used by tool environments, runtimes, and git repositories"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *CacheUsage) Run(*cobra.Command, []string) error {
+	usage, err := c.root.reposManager().Usage()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 10, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	_, _ = w.Write([]byte("TYPE\tCOUNT\tSIZE\n"))
+	_, _ = fmt.Fprintf(w, "environments\t%d\t%s\n", usage.Environments, formatBytes(usage.EnvironmentBytes))
+	_, _ = fmt.Fprintf(w, "runtimes\t%d\t%s\n", usage.Runtimes, formatBytes(usage.RuntimeBytes))
+	_, _ = fmt.Fprintf(w, "git\t\t%s\n", formatBytes(usage.GitBytes))
+	_, _ = fmt.Fprintf(w, "total\t\t%s\n", formatBytes(usage.TotalBytes))
+	return nil
+}
+
+type CachePrune struct {
+	root       *GPTScript
+	Runtimes   bool `usage:"Also prune downloaded runtimes and virtual envs that no remaining tool environment uses"`
+	UnusedDays int  `usage:"Prune tool environments that have not been used for this many days" default:"30"`
+	DryRun     bool `usage:"Print what would be pruned without removing anything"`
+}
+
+func (c *CachePrune) Customize(cmd *cobra.Command) {
+	cmd.Use = "prune"
+	cmd.Short = "Remove tool environments, and optionally runtimes, that have not been used recently"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *CachePrune) Run(*cobra.Command, []string) error {
+	if c.UnusedDays < 0 {
+		return fmt.Errorf("invalid --unused-days %d, must not be negative", c.UnusedDays)
+	}
+
+	result, err := c.root.reposManager().Prune(repos.PruneOptions{
+		MaxUnused: time.Duration(c.UnusedDays) * 24 * time.Hour,
+		Runtimes:  c.Runtimes,
+		DryRun:    c.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, removed := range result.Removed {
+		fmt.Println(removed)
+	}
+
+	verb := "Freed"
+	if c.DryRun {
+		verb = "Would free"
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%s %s from %d paths\n", verb, formatBytes(result.Bytes), len(result.Removed))
+	return nil
+}
+
+func (r *GPTScript) reposManager() *repos.Manager {
+	return repos.New(cache.Complete(cache.Options(r.CacheOptions)).CacheDir, runtimes.Runtimes...)
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
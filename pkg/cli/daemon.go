@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/input"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/sdkserver"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type Daemon struct {
+	root    *GPTScript
+	MaxRuns int `usage:"Maximum number of runs at once, 0 for no limit. Runs over it wait in order of their priority"`
+}
+
+func (c *Daemon) Customize(cmd *cobra.Command) {
+	cmd.Use = "daemon"
+	cmd.Short = "Run a background server that the CLI sends runs to over a local socket"
+	cmd.Long = "Run a background server that the CLI sends runs to over a local socket, with --use-daemon or " +
+		"GPTSCRIPT_USE_DAEMON=true, so that its caches, runtimes, and providers stay loaded between runs. Chats " +
+		"continued with --chat-id are kept by the daemon. Tools run in the current directory of the CLI, and the model " +
+		"and cache options of the CLI apply to the run. Runs that need to ask the user anything, such as those with " +
+		"--confirm or --break, and runs with options that change how the daemon runs tools, such as --max-parallel or " +
+		"--guardrail, are not sent to the daemon."
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *Daemon) Run(cmd *cobra.Command, _ []string) error {
+	opts, err := c.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	return sdkserver.Start(cmd.Context(), sdkserver.Options{
+		Options:       opts,
+		ListenAddress: "unix://" + daemonSocket(c.root.DaemonSocket),
+		Debug:         c.root.Debug,
+		MaxRuns:       c.MaxRuns,
+		KeepAlive:     true,
+	})
+}
+
+// daemonSocket returns the path of the socket of the daemon, which is in the runtime directory of the user by default.
+func daemonSocket(socket string) string {
+	return types.FirstSet(socket, filepath.Join(xdg.RuntimeDir, "gptscript", "gptscriptd.sock"))
+}
+
+// localFlags are the flags of runs that the daemon doesn't take from the CLI, because they change the runner or the
+// clients that the daemon was started with, or because they need this process. Runs with any of them set are not
+// sent to the daemon.
+var localFlags = []string{
+	"events-stream-to", "force-sequential", "max-parallel", "max-llm-concurrency", "max-tool-concurrency",
+	"tool-output-tokens", "summary-model", "model-alias", "confine-tools", "guardrail", "artifacts-dir",
+	"save-env-vars", "credential-cache-ttl", "ports", "ollama-host", "ollama-pull", "disable-ollama",
+}
+
+// useDaemon returns whether the run can be sent to the daemon, which can't ask the user anything.
+func (r *GPTScript) useDaemon(cmd *cobra.Command, args []string) bool {
+	if !r.UseDaemon || len(args) == 0 || r.UI || r.ListModels || r.ListTools || r.Assemble || r.Daemon ||
+		r.ForceChat || r.Confirm || r.ConfirmCredentials || len(r.Break) > 0 || r.CheckpointCalls != 0 ||
+		r.CheckpointCost != "" || r.SaveChatStateFile != "" || r.ChatState != "" {
+		return false
+	}
+	for _, name := range localFlags {
+		if cmd.Flags().Changed(name) {
+			log.Debugf("Not sending the run to the daemon because --%s is set, running locally", name)
+			return false
+		}
+	}
+	return true
+}
+
+// runInDaemon sends the run to the daemon and prints its output. It returns false without an error when the daemon
+// is not running, so that the run is done by this process instead.
+func (r *GPTScript) runInDaemon(ctx context.Context, args []string, labels map[string]string) (bool, error) {
+	socket := daemonSocket(r.DaemonSocket)
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://gptscriptd/healthz")
+	if err != nil {
+		log.Debugf("The daemon is not running on %s, running locally: %v", socket, err)
+		return false, nil
+	}
+	_ = resp.Body.Close()
+
+	toolInput, err := input.FromCLI(r.Input, args)
+	if err != nil {
+		return true, err
+	}
+
+	request, err := r.daemonOptions()
+	if err != nil {
+		return true, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return true, err
+	}
+	workspace, err := absPath(r.Workspace)
+	if err != nil {
+		return true, err
+	}
+	for k, v := range map[string]any{
+		"subTool":             r.SubTool,
+		"input":               toolInput,
+		"chatID":              r.ChatID,
+		"env":                 os.Environ(),
+		"workingDir":          cwd,
+		"workspace":           workspace,
+		"credentialContext":   r.CredentialContext,
+		"credentialOverrides": r.CredentialOverride,
+		"labels":              labels,
+	} {
+		request[k] = v
+	}
+	if args[0] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return true, err
+		}
+		request["content"] = string(data)
+	} else if _, err := os.Stat(args[0]); err == nil {
+		// The daemon runs in another directory, so local files are sent by their absolute path.
+		file, err := filepath.Abs(args[0])
+		if err != nil {
+			return true, err
+		}
+		request["file"] = file
+	} else {
+		request["file"] = args[0]
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return true, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://gptscriptd/run", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send the run to the daemon on %s: %w", socket, err)
+	}
+	defer resp.Body.Close()
+
+	output, err := readDaemonOutput(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	return true, r.PrintOutput(toolInput, output)
+}
+
+// daemonOptions returns the model and cache options of the run as fields of the request to the daemon, with their
+// files by their absolute paths since the daemon runs in another directory.
+func (r *GPTScript) daemonOptions() (map[string]any, error) {
+	openAIOptions, cacheOptions := r.OpenAIOptions, r.CacheOptions
+	for _, file := range []*string{
+		&openAIOptions.ConfigFile, &openAIOptions.ModelsFile, &openAIOptions.Cassette, &openAIOptions.ModelCACert,
+		&openAIOptions.ModelClientCert, &openAIOptions.ModelClientKey, &cacheOptions.CacheDir,
+	} {
+		var err error
+		if *file, err = absPath(*file); err != nil {
+			return nil, err
+		}
+	}
+
+	request := map[string]any{}
+	for _, options := range []any{openAIOptions, cacheOptions} {
+		data, err := json.Marshal(options)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &request); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+func absPath(file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	return filepath.Abs(file)
+}
+
+// readDaemonOutput reads the server sent events of a run until its output. The server writes errors as JSON without
+// the data prefix of an event.
+func readDaemonOutput(body io.Reader) (string, error) {
+	var result *runner.ChatResponse
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if line == "[DONE]" {
+			break
+		} else if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var event struct {
+			Stdout *runner.ChatResponse `json:"stdout"`
+			Stderr string               `json:"stderr"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return "", fmt.Errorf("invalid event from the daemon: %w", err)
+		}
+		if event.Stderr != "" {
+			return "", errors.New(event.Stderr)
+		}
+		if event.Stdout != nil {
+			result = event.Stdout
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", fmt.Errorf("the daemon did not return the output of the run")
+	}
+	return result.Content, nil
+}
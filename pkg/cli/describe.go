@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"encoding/json"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type Describe struct {
+	SubTool     string `usage:"Describe the tool of this name, not the first tool in file"`
+	PrettyPrint bool   `usage:"Indent the json output" short:"p"`
+
+	gptscript *GPTScript
+}
+
+type toolDescription struct {
+	ID           string                 `json:"id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	ModelName    string                 `json:"modelName,omitempty"`
+	Chat         bool                   `json:"chat,omitempty"`
+	Command      bool                   `json:"command,omitempty"`
+	JSONResponse bool                   `json:"jsonResponse,omitempty"`
+	Temperature  *float32               `json:"temperature,omitempty"`
+	MaxTokens    int                    `json:"maxTokens,omitempty"`
+	Arguments    *openapi3.Schema       `json:"arguments,omitempty"`
+	Tools        []types.CompletionTool `json:"tools,omitempty"`
+	Context      []types.ToolReference  `json:"context,omitempty"`
+	Credentials  []string               `json:"credentials,omitempty"`
+	Source       types.ToolSource       `json:"source,omitempty"`
+}
+
+func (e *Describe) Customize(cmd *cobra.Command) {
+	cmd.Use = "describe <program file>"
+	cmd.Short = "Print the fully resolved definition of a tool as JSON"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (e *Describe) Run(cmd *cobra.Command, args []string) error {
+	cacheClient, err := cache.New(cache.Options(e.gptscript.CacheOptions))
+	if err != nil {
+		return err
+	}
+
+	prg, err := loader.Program(cmd.Context(), args[0], e.SubTool, loader.Options{
+		Cache: cacheClient,
+	})
+	if err != nil {
+		return err
+	}
+
+	desc, err := describeTool(prg, e.gptscript.DefaultModel)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	if e.PrettyPrint {
+		enc.SetIndent("", "  ")
+	}
+
+	return enc.Encode(desc)
+}
+
+// describeTool returns the description of the entry tool of the program, with the tools and context it resolves to.
+func describeTool(prg types.Program, defaultModel string) (toolDescription, error) {
+	var (
+		tool = prg.ToolSet[prg.EntryToolID]
+		err  error
+	)
+	desc := toolDescription{
+		ID:           tool.ID,
+		Name:         tool.Name,
+		Description:  tool.Description,
+		ModelName:    types.FirstSet(tool.ModelName, defaultModel),
+		Chat:         tool.Chat,
+		Command:      tool.IsCommand(),
+		JSONResponse: tool.JSONResponse,
+		Temperature:  tool.Temperature,
+		MaxTokens:    tool.MaxTokens,
+		Arguments:    tool.Arguments,
+		Credentials:  tool.Credentials,
+		Source:       tool.Source,
+	}
+
+	if desc.Arguments == nil && !desc.Command {
+		if tool.Chat {
+			desc.Arguments = &system.DefaultChatSchema
+		} else {
+			desc.Arguments = &system.DefaultToolSchema
+		}
+	}
+
+	if desc.Command {
+		// Commands don't call the LLM so they have no model or tools
+		desc.ModelName = ""
+	} else if desc.Tools, err = tool.GetCompletionTools(prg); err != nil {
+		return desc, err
+	}
+
+	if desc.Context, err = tool.GetContextTools(prg); err != nil {
+		return desc, err
+	}
+
+	return desc, nil
+}
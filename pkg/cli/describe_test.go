@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeTool(t *testing.T) {
+	prg, err := loader.ProgramFromSource(context.Background(), `Name: support
+Tools: lookup
+Context: policy
+Args: question: The question of the customer
+
+Answer the question.
+
+---
+Name: lookup
+Description: Looks up an order
+Args: order: The ID of the order
+
+#!/bin/echo ${order}
+
+---
+Name: policy
+
+#!/bin/echo Be polite.
+`, "", loader.Options{})
+	require.NoError(t, err)
+
+	desc, err := describeTool(prg, "gpt-4o")
+	require.NoError(t, err)
+	require.Equal(t, "support", desc.Name)
+	require.Equal(t, "gpt-4o", desc.ModelName)
+	require.False(t, desc.Command)
+	require.Contains(t, desc.Arguments.Properties, "question")
+	// References are resolved to the tools the model is given and the context tools that run first.
+	require.Len(t, desc.Tools, 1)
+	require.Equal(t, "lookup", desc.Tools[0].Function.Name)
+	require.Equal(t, "Looks up an order", desc.Tools[0].Function.Description)
+	require.Len(t, desc.Context, 1)
+	require.Equal(t, "policy", desc.Context[0].Reference)
+
+	// Commands don't call a model.
+	prg, err = loader.ProgramFromSource(context.Background(), "Name: date\n\n#!/bin/date\n", "", loader.Options{})
+	require.NoError(t, err)
+	desc, err = describeTool(prg, "gpt-4o")
+	require.NoError(t, err)
+	require.True(t, desc.Command)
+	require.Empty(t, desc.ModelName)
+	require.Empty(t, desc.Tools)
+	require.Nil(t, desc.Arguments)
+
+	// Tools without arguments take the default input.
+	prg, err = loader.ProgramFromSource(context.Background(), "Name: joke\n\nTell a joke.\n", "", loader.Options{})
+	require.NoError(t, err)
+	desc, err = describeTool(prg, "gpt-4o")
+	require.NoError(t, err)
+	require.Equal(t, &system.DefaultToolSchema, desc.Arguments)
+}
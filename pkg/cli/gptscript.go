@@ -10,6 +10,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/gptscript-ai/cmd"
@@ -18,12 +20,14 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/chat"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/env"
 	"github.com/gptscript-ai/gptscript/pkg/gptscript"
 	"github.com/gptscript-ai/gptscript/pkg/input"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/monitor"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/ollama"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/system"
@@ -39,19 +43,27 @@ type (
 	DisplayOptions monitor.Options
 	CacheOptions   cache.Options
 	OpenAIOptions  openai.Options
+	OllamaOptions  ollama.Options
 )
 
 type GPTScript struct {
 	CacheOptions
 	OpenAIOptions
+	OllamaOptions
 	DisplayOptions
-	Color          *bool  `usage:"Use color in output (default true)" default:"true"`
-	Confirm        bool   `usage:"Prompt before running potentially dangerous commands"`
-	Debug          bool   `usage:"Enable debug logging"`
-	NoTrunc        bool   `usage:"Do not truncate long log messages"`
-	Quiet          *bool  `usage:"No output logging (set --quiet=false to force on even when there is no TTY)" short:"q"`
-	Output         string `usage:"Save output to a file, or - for stdout" short:"o"`
-	EventsStreamTo string `usage:"Stream events to this location, could be a file descriptor/handle (e.g. fd://2), filename, or named pipe (e.g. \\\\.\\pipe\\my-pipe)" name:"events-stream-to"`
+	Color              *bool    `usage:"Use color in output (default true)" default:"true"`
+	Confirm            bool     `usage:"Prompt before running potentially dangerous commands"`
+	ConfirmCredentials bool     `usage:"Prompt the first time each tool uses a credential from the store in a run"`
+	SaveEnvVars        bool     `usage:"Save the environment variables that tools prompt for as credentials"`
+	Break              []string `usage:"Pause before running matching tools to inspect, skip, or change their input (ex: --break tool=search)"`
+	CheckpointCalls    int      `usage:"Pause the run after every N tool calls and ask whether to continue" local:"true"`
+	CheckpointCost     string   `usage:"Pause the run every time it spends this many more dollars and ask whether to continue, priced by the quota of the credential context (ex: 0.50)" local:"true"`
+	Debug              bool     `usage:"Enable debug logging"`
+	NoTrunc            bool     `usage:"Do not truncate long log messages"`
+	LogFormat          string   `usage:"Log output format, one of text or json" default:"text"`
+	Quiet              *bool    `usage:"No output logging (set --quiet=false to force on even when there is no TTY)" short:"q"`
+	Output             string   `usage:"Save output to a file, or - for stdout" short:"o"`
+	EventsStreamTo     string   `usage:"Stream events to this location, could be a file descriptor/handle (e.g. fd://2), filename, or named pipe (e.g. \\\\.\\pipe\\my-pipe)" name:"events-stream-to"`
 	// Input should not be using GPTSCRIPT_INPUT env var because that is the same value that is set in tool executions
 	Input              string   `usage:"Read input from a file (\"-\" for stdin)" short:"f" env:"GPTSCRIPT_INPUT_FILE"`
 	SubTool            string   `usage:"Use tool of this name, not the first tool in file" local:"true"`
@@ -64,13 +76,28 @@ type GPTScript struct {
 	Ports              string   `usage:"The port range to use for ephemeral daemon ports (ex: 11000-12000)" hidden:"true"`
 	CredentialContext  string   `usage:"Context name in which to store credentials" default:"default"`
 	CredentialOverride []string `usage:"Credentials to override (ex: --credential-override github.com/example/cred-tool:API_TOKEN=1234)"`
+	CredentialCacheTTL string   `usage:"How long to keep credentials read from the credential store in memory, 0 to always read them from the store" default:"1m"`
 	ChatState          string   `usage:"The chat state to continue, or null to start a new chat and return the state" local:"true"`
 	ForceChat          bool     `usage:"Force an interactive chat session if even the top level tool is not a chat tool" local:"true"`
 	ForceSequential    bool     `usage:"Force parallel calls to run sequentially" local:"true"`
+	MaxParallel        int      `usage:"Maximum number of concurrent LLM calls and tool executions, 0 for no limit" local:"true"`
+	MaxLLMConcurrency  int      `usage:"Maximum number of concurrent LLM calls, 0 for no limit" name:"max-llm-concurrency"`
+	MaxToolConcurrency int      `usage:"Maximum number of concurrent tool executions, 0 for no limit" name:"max-tool-concurrency"`
+	ToolOutputTokens   int      `usage:"Summarize tool outputs over this many tokens before adding them to the chat, 0 to never summarize"`
+	SummaryModel       string   `usage:"The model that summarizes tool outputs, by default the model of the calling tool"`
+	ModelAlias         []string `usage:"Send requests for a model to another, such as an Azure OpenAI deployment (ex: --model-alias gpt-4o=prod-gpt-4o)" env:"GPTSCRIPT_MODEL_ALIASES"`
+	ConfineTools       bool     `usage:"Keep sys.read, sys.write, and the other file builtins called by tools with a Working Dir from using files outside of it, and refuse sys.exec and command tools for them"`
+	Guardrail          []string `usage:"Classifier tool that checks every user input and model response, as [input:|output:]<block|redact|flag>=<tool> (ex: --guardrail input:block=./jailbreak.gpt)"`
 	Workspace          string   `usage:"Directory to use for the workspace, if specified it will not be deleted on exit"`
+	ArtifactsDir       string   `usage:"Directory to copy the artifacts declared by tools to after the run" local:"true"`
 	UI                 bool     `usage:"Launch the UI" local:"true" name:"ui"`
 	DisableTUI         bool     `usage:"Don't use chat TUI but instead verbose output" local:"true" name:"disable-tui"`
+	DisableStreaming   bool     `usage:"Print the output when the run finishes instead of as it is generated" local:"true" name:"disable-streaming"`
 	SaveChatStateFile  string   `usage:"A file to save the chat state to so that a conversation can be resumed with --chat-state" local:"true"`
+	Label              []string `usage:"Label to attach to the run, included in its events and passed to its tools (ex: --label team=payments)"`
+	UseDaemon          bool     `usage:"Send the run to the daemon started with gptscript daemon if it is running, instead of running it in this process" local:"true"`
+	DaemonSocket       string   `usage:"Path of the socket of the daemon (default $XDG_RUNTIME_DIR/gptscript/gptscriptd.sock)"`
+	ChatID             string   `usage:"With --use-daemon, continue the chat with this ID that the daemon keeps, or start it" local:"true"`
 
 	readData []byte
 }
@@ -82,7 +109,21 @@ func New() *cobra.Command {
 		&Eval{gptscript: root},
 		&Credential{root: root},
 		&Parse{},
+		&Describe{gptscript: root},
+		&LSP{},
+		&Cache{root: root},
 		&Fmt{},
+		&Migrate{},
+		&ImportTranscript{},
+		&Usage{},
+		&NewTool{},
+		&Schedule{root: root},
+		&Slack{root: root},
+		&Watch{root: root},
+		&Webhook{root: root},
+		&Daemon{root: root},
+		&Shell{root: root},
+		&Hook{root: root},
 		&SDKServer{
 			GPTScript: root,
 		},
@@ -127,24 +168,58 @@ func New() *cobra.Command {
 }
 
 func (r *GPTScript) NewGPTScriptOpts() (gptscript.Options, error) {
+	var credCacheTTL time.Duration
+	if r.CredentialCacheTTL != "" {
+		var err error
+		credCacheTTL, err = time.ParseDuration(r.CredentialCacheTTL)
+		if err != nil {
+			return gptscript.Options{}, fmt.Errorf("invalid --credential-cache-ttl: %w", err)
+		}
+	}
+
 	opts := gptscript.Options{
 		Cache:   cache.Options(r.CacheOptions),
 		OpenAI:  openai.Options(r.OpenAIOptions),
+		Ollama:  ollama.Options(r.OllamaOptions),
 		Monitor: monitor.Options(r.DisplayOptions),
 		Runner: runner.Options{
 			CredentialOverrides: r.CredentialOverride,
 			Sequential:          r.ForceSequential,
+			MaxParallel:         r.MaxParallel,
+			MaxLLMConcurrency:   r.MaxLLMConcurrency,
+			MaxToolConcurrency:  r.MaxToolConcurrency,
+
+			SummarizeToolOutputTokens: r.ToolOutputTokens,
+			SummaryModel:              r.SummaryModel,
+			ConfineTools:              r.ConfineTools,
+			Guardrails:                r.Guardrail,
 		},
 		Quiet:               r.Quiet,
 		Env:                 os.Environ(),
 		CredentialContext:   r.CredentialContext,
+		CredentialCacheTTL:  credCacheTTL,
 		Workspace:           r.Workspace,
 		DisablePromptServer: r.UI,
+		ModelAliases:        r.ModelAlias,
+	}
+
+	if r.CheckpointCost != "" {
+		cost, err := strconv.ParseFloat(strings.TrimPrefix(r.CheckpointCost, "$"), 64)
+		if err != nil || cost <= 0 {
+			return gptscript.Options{}, fmt.Errorf("invalid --checkpoint-cost %q, must be a positive number of dollars", r.CheckpointCost)
+		}
+		opts.Runner.CheckpointCost = cost
 	}
+	opts.Runner.CheckpointCalls = r.CheckpointCalls
 
 	if r.Confirm {
 		opts.Runner.Authorizer = auth.Authorize
 	}
+	if r.ConfirmCredentials {
+		opts.Runner.CredentialAuthorizer = auth.AuthorizeCredential
+	}
+	opts.Runner.SaveEnvVars = r.SaveEnvVars
+	opts.Runner.Breakpoints = r.Break
 
 	if r.Ports != "" {
 		start, end, _ := strings.Cut(r.Ports, "-")
@@ -252,6 +327,14 @@ func (r *GPTScript) PersistentPre(*cobra.Command, []string) error {
 		}
 	}
 
+	switch r.LogFormat {
+	case "", "text":
+	case "json":
+		mvl.SetJSONFormat()
+	default:
+		return fmt.Errorf("invalid log format %q, must be one of text or json", r.LogFormat)
+	}
+
 	if r.Color != nil {
 		color.NoColor = !*r.Color
 	}
@@ -330,6 +413,22 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 		return err
 	}
 
+	// Tools with the Breakpoint directive only pause when someone can answer at the terminal.
+	if len(r.Break) > 0 || term.IsTerminal(int(os.Stdin.Fd())) {
+		gptOpt.Runner.BreakpointHandler = auth.Breakpoint
+	}
+	if r.CheckpointCalls > 0 || r.CheckpointCost != "" {
+		gptOpt.Runner.CheckpointHandler = auth.Checkpoint
+	}
+
+	// The output is written to stdout as it is generated instead of when the whole run finishes, unless it would be
+	// mixed with the progress of the run on the terminal.
+	stream := &outputStream{out: os.Stdout}
+	toStdout := r.Output == "" || r.Output == "-"
+	if toStdout && !r.DisableStreaming && (*r.Quiet || !term.IsTerminal(int(os.Stdout.Fd()))) {
+		gptOpt.Monitor.Output = stream
+	}
+
 	// If the user is trying to launch the chat-builder UI, then set up the tool and options here.
 	if r.UI {
 		args = append([]string{uiTool()}, args...)
@@ -385,7 +484,17 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 		r.Daemon = true
 	}
 
-	ctx := cmd.Context()
+	labels, err := parseLabels(r.Label)
+	if err != nil {
+		return err
+	}
+	ctx := gcontext.WithLabels(cmd.Context(), labels)
+
+	if r.useDaemon(cmd, args) {
+		if ok, err := r.runInDaemon(ctx, args, labels); ok || err != nil {
+			return err
+		}
+	}
 
 	gptScript, err := gptscript.New(ctx, gptOpt)
 	if err != nil {
@@ -449,10 +558,13 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 
 	// This chat in a stateless mode
 	if r.SaveChatStateFile == "-" || r.SaveChatStateFile == "stdout" {
-		resp, err := gptScript.Chat(cmd.Context(), chatState, prg, gptOpt.Env, toolInput)
+		resp, err := gptScript.Chat(ctx, chatState, prg, gptOpt.Env, toolInput)
 		if err != nil {
 			return err
 		}
+		if err := r.saveArtifacts(gptScript, prg); err != nil {
+			return err
+		}
 		data, err := json.Marshal(resp)
 		if err != nil {
 			return err
@@ -477,7 +589,7 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 				ChatState:           chatState,
 			})
 		}
-		return chat.Start(cmd.Context(), chatState, gptScript, func() (types.Program, error) {
+		return chat.Start(ctx, chatState, gptScript, func() (types.Program, error) {
 			return r.readProgram(ctx, gptScript, args)
 		}, gptOpt.Env, toolInput, r.SaveChatStateFile)
 	}
@@ -488,14 +600,79 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 		gptScript.ExtraEnv = nil
 	}
 
-	s, err := gptScript.Run(cmd.Context(), prg, gptOpt.Env, toolInput)
+	stream.start()
+	s, err := gptScript.Run(ctx, prg, gptOpt.Env, toolInput)
+	streamed := stream.stop()
 	if err != nil {
+		if streamed != "" && !strings.HasSuffix(streamed, "\n") {
+			fmt.Println()
+		}
 		return err
 	}
 
+	if err := r.saveArtifacts(gptScript, prg); err != nil {
+		return err
+	}
+
+	if streamed != "" {
+		if strings.HasSuffix(streamed, s) {
+			if !strings.HasSuffix(streamed, "\n") {
+				fmt.Println()
+			}
+			return nil
+		}
+		// The output is not the text that was streamed, such as when an output filter changed it.
+		fmt.Print("\n\n")
+	}
+
 	return r.PrintOutput(toolInput, s)
 }
 
+// outputStream writes the output of a run to stdout as it is generated. It only writes while a run is started, so
+// that chat sessions, which print their own responses, are not affected.
+type outputStream struct {
+	lock    sync.Mutex
+	out     io.Writer
+	started bool
+	written strings.Builder
+}
+
+func (o *outputStream) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.started {
+		return len(p), nil
+	}
+	o.written.Write(p)
+	return o.out.Write(p)
+}
+
+func (o *outputStream) start() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.started = true
+}
+
+// stop stops writing and returns what was written.
+func (o *outputStream) stop() string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.started = false
+	return o.written.String()
+}
+
+func (r *GPTScript) saveArtifacts(gptScript *gptscript.GPTScript, prg types.Program) error {
+	if r.ArtifactsDir == "" {
+		return nil
+	}
+
+	artifacts, err := gptScript.Artifacts(prg)
+	if err != nil {
+		return err
+	}
+	return gptScript.SaveArtifacts(artifacts, r.ArtifactsDir)
+}
+
 // uiTool returns the versioned UI tool reference for the current GPTScript version.
 // For release versions, a reference with a matching release tag is returned.
 // For all other versions, a reference to main is returned.
@@ -507,3 +684,15 @@ func uiTool() string {
 
 	return env.VarOrDefault("GPTSCRIPT_CHAT_UI_TOOL", ref)
 }
+
+func parseLabels(labels []string) (map[string]string, error) {
+	result := make(map[string]string, len(labels))
+	for _, label := range labels {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		result[k] = v
+	}
+	return result, nil
+}
@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cmd2 "github.com/gptscript-ai/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/assemble"
+	"github.com/gptscript-ai/gptscript/pkg/githook"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+type Hook struct {
+	root *GPTScript
+}
+
+func (c *Hook) Customize(cmd *cobra.Command) {
+	cmd.Use = "hook"
+	cmd.Short = "Run tools as git hooks, such as to write or review commit messages"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&HookInstall{root: c.root}), cmd2.Command(&HookUninstall{}), cmd2.Command(&HookRun{root: c.root}))
+}
+
+func (c *Hook) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type HookInstall struct {
+	root    *GPTScript
+	Tool    string `usage:"The tool to run as the hook, a file or a reference such as github.com/example/tools/review.gpt"`
+	Timeout string `usage:"Maximum time the tool may take before the hook gives up" default:"2m"`
+	Strict  bool   `usage:"Fail the hook when the tool fails or times out, not only when it aborts"`
+	Force   bool   `usage:"Replace a hook that was not installed by gptscript"`
+}
+
+func (c *HookInstall) Customize(cmd *cobra.Command) {
+	cmd.Use = "install HOOK"
+	cmd.Short = "Install a tool as the pre-commit, prepare-commit-msg, or commit-msg hook of the current repository"
+	cmd.Long = "Install a tool as the pre-commit, prepare-commit-msg, or commit-msg hook of the current repository. The tool " +
+		"is given the JSON input `{\"hook\": \"commit-msg\", \"args\": [], \"files\": [], \"diff\": \"...\", \"message\": \"...\"}` " +
+		"with the staged files and diff, and the commit message for the message hooks. The output of a prepare-commit-msg " +
+		"tool becomes the commit message, unless one was given with -m or -F, and the output of the other tools is shown. " +
+		"A tool rejects the commit by calling sys.abort. When the tool fails otherwise or times out, such as when the " +
+		"model provider can't be reached, the commit goes ahead with a warning unless --strict is set. Tools that are not " +
+		"local files are downloaded once and saved in the git directory, so that the hook doesn't download them again."
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (c *HookInstall) Run(cmd *cobra.Command, args []string) error {
+	if c.Tool == "" {
+		return fmt.Errorf("--tool is required")
+	}
+	if _, err := parseHookTimeout(c.Timeout); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	root, err := githook.Root(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	tool, err := c.resolveTool(ctx, args[0], root)
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	command := []string{self, "hook", "run", args[0], "--tool", tool, "--timeout", c.Timeout}
+	if c.Strict {
+		command = append(command, "--strict")
+	}
+
+	path, err := githook.Install(ctx, "", args[0], githook.Script(args[0], command...), c.Force)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "Installed %s to run %s\n", path, c.Tool)
+	return err
+}
+
+// resolveTool returns the tool that the hook runs. Local files are run from the top level of the working tree, where
+// git runs hooks, and other tools are assembled into a file in the git directory.
+func (c *HookInstall) resolveTool(ctx context.Context, hook, root string) (string, error) {
+	if _, err := os.Stat(c.Tool); err == nil {
+		file, err := filepath.Abs(c.Tool)
+		if err != nil {
+			return "", err
+		}
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel, nil
+		}
+		return file, nil
+	}
+
+	opts, err := c.root.NewGPTScriptOpts()
+	if err != nil {
+		return "", err
+	}
+	gptScript, err := gptscript.New(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	defer gptScript.Close(true)
+
+	prg, err := loader.Program(ctx, c.Tool, "", loader.Options{
+		Cache: gptScript.Cache,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	file, err := githook.Path(ctx, "", filepath.Join("gptscript", hook+".gpt"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return file, assemble.Assemble(prg, f)
+}
+
+type HookUninstall struct{}
+
+func (c *HookUninstall) Customize(cmd *cobra.Command) {
+	cmd.Use = "uninstall HOOK"
+	cmd.Short = "Remove a hook that was installed with gptscript hook install"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (c *HookUninstall) Run(cmd *cobra.Command, args []string) error {
+	path, err := githook.Uninstall(cmd.Context(), "", args[0])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", path)
+	return err
+}
+
+type HookRun struct {
+	root    *GPTScript
+	Tool    string `usage:"The tool to run as the hook"`
+	Timeout string `usage:"Maximum time the tool may take before the hook gives up" default:"2m"`
+	Strict  bool   `usage:"Fail the hook when the tool fails or times out, not only when it aborts"`
+}
+
+func (c *HookRun) Customize(cmd *cobra.Command) {
+	cmd.Use = "run HOOK [ARGS...]"
+	cmd.Short = "Run a tool as a git hook with the arguments that git gave the hook, which is what installed hooks do"
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (c *HookRun) Run(cmd *cobra.Command, args []string) error {
+	hook, hookArgs := args[0], args[1:]
+	if c.Tool == "" {
+		return fmt.Errorf("--tool is required")
+	}
+	// The message of a commit with -m, -F, or -c is left as it is.
+	if hook == "prepare-commit-msg" && !githook.GeneratesMessage(hook, hookArgs) {
+		return nil
+	}
+
+	timeout, err := parseHookTimeout(c.Timeout)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+
+	output, err := c.run(ctx, hook, hookArgs)
+	if err != nil {
+		if _, message, ok := strings.Cut(err.Error(), "ABORT: "); ok {
+			return fmt.Errorf("%s rejected the commit: %s", c.Tool, message)
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%s timed out after %s", c.Tool, timeout)
+		}
+		if c.Strict {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "WARNING: skipping the %s hook: %v\n", hook, err)
+		return nil
+	}
+
+	if githook.GeneratesMessage(hook, hookArgs) {
+		return githook.WriteMessage(hookArgs[0], output)
+	} else if output != "" {
+		_, _ = fmt.Fprintln(os.Stderr, output)
+	}
+	return nil
+}
+
+func (c *HookRun) run(ctx context.Context, hook string, args []string) (string, error) {
+	input, err := githook.NewInput(ctx, "", hook, args)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := c.root.NewGPTScriptOpts()
+	if err != nil {
+		return "", err
+	}
+
+	// The progress of the run is not shown among the output of git.
+	if !c.root.Debug {
+		mvl.SetError()
+		opts.Runner.MonitorFactory = runner.NoopMonitorFactory()
+	}
+
+	gptScript, err := gptscript.New(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	defer gptScript.Close(true)
+
+	prg, err := loader.Program(ctx, c.Tool, "", loader.Options{
+		Cache: gptScript.Cache,
+	})
+	if err != nil {
+		return "", err
+	}
+	return gptScript.Run(ctx, prg, opts.Env, input)
+}
+
+func parseHookTimeout(timeout string) (time.Duration, error) {
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --timeout %q", timeout)
+	}
+	return d, nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/llmtest"
+	"github.com/spf13/cobra"
+)
+
+type ImportTranscript struct {
+	Output string `usage:"Directory to write the fixtures to instead of printing them" short:"o"`
+}
+
+func (i *ImportTranscript) Customize(cmd *cobra.Command) {
+	cmd.Use = "import-transcript [transcript...]"
+	cmd.Short = "Convert chat transcripts in the OpenAI JSON format into test fixtures"
+	cmd.Long = "Convert chat transcripts in the OpenAI JSON format, such as the messages of a chat completion request or a " +
+		"JSON Lines fine-tuning dataset, into fixtures that the llmtest package replays. A file named weather.json is " +
+		"written to weather.fixture.json in the output directory, or weather-1.fixture.json and so on for a file " +
+		"with several transcripts."
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (i *ImportTranscript) Run(_ *cobra.Command, args []string) error {
+	if i.Output != "" {
+		if err := os.MkdirAll(i.Output, 0755); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range args {
+		f, err := os.Open(arg)
+		if err != nil {
+			return err
+		}
+		fixtures, err := llmtest.ImportOpenAI(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", arg, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+		for n, fixture := range fixtures {
+			data, err := json.MarshalIndent(fixture, "", "  ")
+			if err != nil {
+				return err
+			}
+			if i.Output == "" {
+				fmt.Println(string(data))
+				continue
+			}
+
+			file := name + ".fixture.json"
+			if len(fixtures) > 1 {
+				file = fmt.Sprintf("%s-%d.fixture.json", name, n+1)
+			}
+			if err := os.WriteFile(filepath.Join(i.Output, file), append(data, '\n'), 0644); err != nil {
+				return err
+			}
+		}
+		if i.Output != "" {
+			log.Infof("Imported %d fixtures from %s", len(fixtures), arg)
+		}
+	}
+	return nil
+}
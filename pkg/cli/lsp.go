@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/gptscript-ai/gptscript/pkg/lsp"
+	"github.com/spf13/cobra"
+)
+
+type LSP struct{}
+
+func (l *LSP) Customize(cmd *cobra.Command) {
+	cmd.Use = "lsp"
+	cmd.Short = "Run a language server for .gpt files over stdin and stdout"
+	cmd.Args = cobra.NoArgs
+}
+
+func (l *LSP) Run(cmd *cobra.Command, _ []string) error {
+	return lsp.NewServer(os.Stdin, os.Stdout).Serve(cmd.Context())
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/parser"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	"github.com/spf13/cobra"
+)
+
+type Migrate struct {
+	Write bool `usage:"Write the migrated files instead of printing a diff" short:"w"`
+}
+
+func (m *Migrate) Customize(cmd *cobra.Command) {
+	cmd.Use = "migrate [file or dir...]"
+	cmd.Short = "Rewrite deprecated directives and syntax in .gpt files to their current forms"
+	cmd.Long = "Rewrite deprecated directives and syntax in the .gpt files under the given files and directories, the " +
+		"current directory by default, to their current forms. The changes are printed as a diff unless --write is set."
+}
+
+func (m *Migrate) Run(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var failed, migrated int
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != arg && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if path != arg && filepath.Ext(path) != ".gpt" {
+				return nil
+			}
+
+			changed, err := m.migrate(path)
+			if err != nil {
+				log.Errorf("Failed to migrate %s: %v", path, err)
+				failed++
+			} else if changed {
+				migrated++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Write {
+		log.Infof("Migrated %d files", migrated)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d files", failed)
+	}
+	return nil
+}
+
+func (m *Migrate) migrate(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	before := string(data)
+	after, err := parser.Migrate(before)
+	if err != nil {
+		return false, err
+	}
+	if after == before {
+		return false, nil
+	}
+
+	if m.Write {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		return true, os.WriteFile(path, []byte(after), info.Mode().Perm())
+	}
+
+	edits := myers.ComputeEdits(span.URIFromPath(path), before, after)
+	fmt.Print(gotextdiff.ToUnified(path, path, before, edits))
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+type NewTool struct {
+	Dir      string   `usage:"Directory to create the tool in (default: the tool name)"`
+	Author   string   `usage:"Author of the tool (default: git user.name)"`
+	Runtime  string   `usage:"Runtime of the built-in template, one of python, node, or go (default: no code)"`
+	Template string   `usage:"Template to copy instead of the built-in one, a local directory, git URL, or github.com/org/repo, optionally followed by @ref"`
+	Var      []string `usage:"Additional variables to substitute in the template (ex: --var license=MIT)"`
+}
+
+func (n *NewTool) Customize(cmd *cobra.Command) {
+	cmd.Use = "new <tool name>"
+	cmd.Short = "Create a new tool from a template"
+	cmd.Long = "Create a new tool from a template. Every `{{name}}`, `{{author}}`, `{{runtime}}`, and `{{key}}` of --var in the file names and contents of the template is replaced with its value."
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (n *NewTool) Run(cmd *cobra.Command, args []string) error {
+	vars := map[string]string{}
+	for _, v := range n.Var {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --var %q, expected key=value", v)
+		}
+		vars[key] = value
+	}
+
+	dir, err := scaffold.New(cmd.Context(), scaffold.Options{
+		Name:     args[0],
+		Dir:      n.Dir,
+		Author:   n.Author,
+		Runtime:  n.Runtime,
+		Template: n.Template,
+		Vars:     vars,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Created tool %s in %s", args[0], dir)
+	return nil
+}
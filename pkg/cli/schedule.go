@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/input"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/schedule"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+type Schedule struct {
+	root          *GPTScript
+	SchedulesFile string `usage:"YAML file of schedules to run instead of a single schedule from the arguments" short:"s"`
+	SubTool       string `usage:"Use tool of this name, not the first tool in file"`
+	HistoryDir    string `usage:"Directory to record the history of runs in (default: $XDG_DATA_HOME/gptscript/schedules)"`
+	NotifyURL     string `usage:"URL to POST a JSON record of every failed run to"`
+}
+
+func (s *Schedule) Customize(cmd *cobra.Command) {
+	cmd.Use = "schedule <cron> <file> [input...]"
+	cmd.Short = "Run tools on cron schedules"
+	cmd.Long = `Run tools on cron schedules until interrupted. The schedule is a five field cron expression, a descriptor such
+as @hourly or @daily, or @every followed by a duration. A run is skipped if the previous run of the same schedule has
+not finished. With --schedules-file, every schedule in the file is run, for example:
+
+schedules:
+- name: report
+  cron: "0 8 * * 1-5"
+  tool: ./report.gpt
+  input: '{"team": "payments"}'`
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if s.SchedulesFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	}
+	// Leave the flags after the file to the input of the tool.
+	cmd.Flags().SetInterspersed(false)
+}
+
+func (s *Schedule) Run(cmd *cobra.Command, args []string) error {
+	var jobs []schedule.Job
+	if s.SchedulesFile != "" {
+		var err error
+		jobs, err = schedule.ReadFile(s.SchedulesFile)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return fmt.Errorf("no schedules found in %s", s.SchedulesFile)
+		}
+	} else {
+		jobs = []schedule.Job{{
+			Cron:    args[0],
+			Tool:    args[1],
+			SubTool: s.SubTool,
+			Input:   input.FromArgs(args[2:]),
+		}}
+	}
+
+	historyDir := s.HistoryDir
+	if historyDir == "" {
+		historyDir = filepath.Join(xdg.DataHome, version.ProgramName, "schedules")
+	}
+
+	gptOpt, err := s.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	gptScript, err := gptscript.New(cmd.Context(), gptOpt)
+	if err != nil {
+		return err
+	}
+	defer gptScript.Close(true)
+
+	log.Infof("Running %d schedule(s), recording history in %s", len(jobs), historyDir)
+	return schedule.New(schedule.Options{
+		HistoryDir: historyDir,
+		NotifyURL:  s.NotifyURL,
+		Run: func(ctx context.Context, job schedule.Job) (string, error) {
+			// Reload the program on every run to pick up changes to the tool.
+			prg, err := loader.Program(ctx, job.Tool, job.SubTool, loader.Options{
+				Cache: gptScript.Cache,
+			})
+			if err != nil {
+				return "", err
+			}
+			return gptScript.Run(ctx, prg, gptOpt.Env, job.Input)
+		},
+	}).Run(cmd.Context(), jobs)
+}
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/gptscript-ai/gptscript/pkg/sdkserver"
 	"github.com/spf13/cobra"
@@ -11,6 +13,10 @@ import (
 
 type SDKServer struct {
 	*GPTScript
+	StorageURL     string `usage:"The postgres:// URL of a database to store runs and chats in, so that replicas of the server share them" env:"GPTSCRIPT_SDKSERVER_STORAGE_URL"`
+	EventRetention string `usage:"How long the events of runs are kept in the database of --storage-url, 0 to keep them forever" default:"720h" env:"GPTSCRIPT_SDKSERVER_EVENT_RETENTION"`
+	MaxRuns        int    `usage:"Maximum number of runs at once, 0 for no limit. Runs over it wait in order of their priority" env:"GPTSCRIPT_SDKSERVER_MAX_RUNS"`
+	UITool         string `usage:"The tool that the chat UI at /ui runs when none is given in its URL (ex: ./chat.gpt)" name:"ui-tool"`
 }
 
 func (c *SDKServer) Customize(cmd *cobra.Command) {
@@ -26,6 +32,11 @@ func (c *SDKServer) Run(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	eventRetention, err := time.ParseDuration(c.EventRetention)
+	if err != nil || eventRetention < 0 {
+		return fmt.Errorf("invalid event retention %q", c.EventRetention)
+	}
+
 	// Don't use cmd.Context() as we don't want to die on ctrl+c
 	ctx := context.Background()
 	if term.IsTerminal(int(os.Stdin.Fd())) {
@@ -34,8 +45,12 @@ func (c *SDKServer) Run(cmd *cobra.Command, _ []string) error {
 	}
 
 	return sdkserver.Start(ctx, sdkserver.Options{
-		Options:       opts,
-		ListenAddress: c.ListenAddress,
-		Debug:         c.Debug,
+		Options:        opts,
+		ListenAddress:  c.ListenAddress,
+		Debug:          c.Debug,
+		StorageURL:     c.StorageURL,
+		EventRetention: eventRetention,
+		MaxRuns:        c.MaxRuns,
+		UITool:         c.UITool,
 	})
 }
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	cmd2 "github.com/gptscript-ai/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+type Shell struct {
+	root *GPTScript
+}
+
+func (c *Shell) Customize(cmd *cobra.Command) {
+	cmd.Use = "shell"
+	cmd.Short = "Turn requests in natural language into commands at the prompt of bash or zsh"
+	cmd.Long = "Turn requests in natural language into commands at the prompt of bash or zsh. After adding " +
+		"`eval \"$(gptscript shell init zsh)\"` to ~/.zshrc, or `eval \"$(gptscript shell init bash)\"` to ~/.bashrc, " +
+		"type a request at the prompt and press Ctrl-G. The proposed command is shown for confirmation, and replaces the " +
+		"request at the prompt when it is accepted, so that it can be edited or run with Enter."
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&ShellInit{}), cmd2.Command(&ShellSuggest{root: c.root}))
+}
+
+func (c *Shell) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type ShellInit struct{}
+
+func (c *ShellInit) Customize(cmd *cobra.Command) {
+	cmd.Use = "init bash|zsh"
+	cmd.Short = "Print the script that binds Ctrl-G to gptscript shell suggest in bash or zsh"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (c *ShellInit) Run(cmd *cobra.Command, args []string) error {
+	script, ok := shellScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q, must be bash or zsh", args[0])
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), script, "'"+strings.ReplaceAll(self, "'", `'\''`)+"'")
+	return err
+}
+
+// shellScripts bind Ctrl-G to replace the line being edited with the command that is accepted for it, and leave the
+// line as it is otherwise. The path of gptscript is substituted for %s.
+var shellScripts = map[string]string{
+	"bash": `_gptscript_shell() {
+  local cmd
+  cmd=$(%s shell suggest --shell bash -- "$READLINE_LINE") && READLINE_LINE=$cmd && READLINE_POINT=${#cmd}
+}
+bind -x '"\C-g": _gptscript_shell'
+`,
+	"zsh": `_gptscript_shell() {
+  local cmd
+  cmd=$(%s shell suggest --shell zsh -- "$BUFFER" </dev/tty) && BUFFER=$cmd && CURSOR=${#BUFFER}
+  zle reset-prompt
+}
+zle -N _gptscript_shell
+bindkey '^G' _gptscript_shell
+`,
+}
+
+type ShellSuggest struct {
+	root  *GPTScript
+	Shell string `usage:"The shell to write the command for (default the name of $SHELL)"`
+}
+
+func (c *ShellSuggest) Customize(cmd *cobra.Command) {
+	cmd.Use = "suggest [flags] REQUEST..."
+	cmd.Short = "Propose a shell command for a request and print it if it is accepted"
+	cmd.Long = "Propose a shell command for a request and print it if it is accepted. The command is confirmed on the " +
+		"terminal, so that the output can be captured by the shell. Rejecting the command with a comment proposes " +
+		"another one, and canceling exits with an error."
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+// errShellCanceled is returned by the authorizer of the proposals to stop the run when the user cancels.
+var errShellCanceled = errors.New("canceled")
+
+const shellTool = `Name: shell
+Description: Writes a shell command for a request of the user
+Tools: propose
+
+You write commands for a user of the %s shell on %s, in the directory %s. Turn the request of the user into a single
+command line and propose it with propose. When the user asks for changes, propose the changed command. Prefer
+tools that are installed on most systems, and don't run anything or reply with an explanation.
+
+---
+Name: propose
+Description: Proposes a command to the user, who inserts it at their prompt if they accept it
+Param: command: The command line
+Param: explanation: What the command does in one sentence
+
+#!sys.echo
+The user accepted the command.
+`
+
+func (c *ShellSuggest) Run(cmd *cobra.Command, args []string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("a terminal is needed to confirm the command: %w", err)
+	}
+	defer tty.Close()
+
+	shell := c.Shell
+	if shell == "" {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	opts, err := c.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	var accepted string
+	opts.Runner.Authorizer = shellAuthorizer(func(command, explanation string) (runner.AuthorizerResponse, error) {
+		return confirmShellCommand(tty, command, explanation)
+	}, &accepted, cancel)
+
+	gptScript, err := gptscript.New(cmd.Context(), opts)
+	if err != nil {
+		return err
+	}
+	defer gptScript.Close(true)
+
+	prg, err := loader.ProgramFromSource(cmd.Context(), fmt.Sprintf(shellTool, shell, runtime.GOOS, wd), "", loader.Options{
+		Cache: gptScript.Cache,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = gptScript.Run(ctx, prg, opts.Env, strings.Join(args, " "))
+	if accepted != "" {
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), accepted)
+		return err
+	} else if err != nil {
+		return err
+	}
+	return errors.New("no command was proposed")
+}
+
+// shellAuthorizer returns the authorizer of the run of the shell tool, which asks confirm whether to accept each proposed
+// command. The accepted command is stored in accepted, and the run is canceled because the command is all that is
+// needed, instead of waiting for the reply of the model.
+func shellAuthorizer(confirm func(command, explanation string) (runner.AuthorizerResponse, error), accepted *string, cancel func()) runner.AuthorizerFunc {
+	return func(callCtx engine.Context, input string) (runner.AuthorizerResponse, error) {
+		var proposal struct {
+			Command     string `json:"command"`
+			Explanation string `json:"explanation"`
+		}
+		if callCtx.Tool.Name != "propose" || json.Unmarshal([]byte(input), &proposal) != nil {
+			return runner.AuthorizerResponse{Message: "Only propose can be called."}, nil
+		}
+
+		resp, err := confirm(proposal.Command, proposal.Explanation)
+		if err == nil && resp.Accept {
+			*accepted = proposal.Command
+			cancel()
+		}
+		return resp, err
+	}
+}
+
+const (
+	shellInsert = "Insert it"
+	shellChange = "Change it"
+	shellCancel = "Cancel"
+)
+
+// confirmShellCommand asks on the terminal whether to insert the command. Changing it sends the comment of the user
+// to the model, which proposes another command.
+func confirmShellCommand(tty *os.File, command, explanation string) (runner.AuthorizerResponse, error) {
+	stdio := survey.WithStdio(tty, tty, tty)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{
+		Message: fmt.Sprintf("%s\n  %s\n", command, explanation),
+		Options: []string{shellInsert, shellChange, shellCancel},
+		Default: shellInsert,
+	}, &choice, stdio)
+	if err != nil {
+		return runner.AuthorizerResponse{}, err
+	}
+
+	switch choice {
+	case shellInsert:
+		return runner.AuthorizerResponse{Accept: true}, nil
+	case shellChange:
+		var comment string
+		if err := survey.AskOne(&survey.Input{Message: "What should be different?"}, &comment, stdio); err != nil {
+			return runner.AuthorizerResponse{}, err
+		}
+		return runner.AuthorizerResponse{
+			Message: fmt.Sprintf("The user rejected the command and asked for this change: %s", comment),
+		}, nil
+	default:
+		return runner.AuthorizerResponse{}, errShellCanceled
+	}
+}
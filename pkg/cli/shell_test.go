@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// proposingModel proposes the commands in order, one for each request.
+type proposingModel struct {
+	commands []string
+	requests []types.CompletionRequest
+}
+
+func (m *proposingModel) Call(_ context.Context, req types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	m.requests = append(m.requests, req)
+	if len(m.commands) == 0 {
+		return &types.CompletionMessage{Role: types.CompletionMessageRoleTypeAssistant, Content: types.Text("done")}, nil
+	}
+
+	command := m.commands[0]
+	m.commands = m.commands[1:]
+	index := 0
+	return &types.CompletionMessage{
+		Role: types.CompletionMessageRoleTypeAssistant,
+		Content: []types.ContentPart{{ToolCall: &types.CompletionToolCall{
+			Index: &index,
+			ID:    fmt.Sprintf("call_%d", len(m.requests)),
+			Function: types.CompletionFunctionCall{
+				Name:      "propose",
+				Arguments: fmt.Sprintf(`{"command": %q, "explanation": "Lists the files"}`, command),
+			},
+		}}},
+	}, nil
+}
+
+func TestShellSuggest(t *testing.T) {
+	model := &proposingModel{commands: []string{"ls", "ls -la"}}
+
+	var (
+		proposed []string
+		accepted string
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first command is changed, and the second is accepted.
+	r, err := runner.New(model, credentials.NoopStore{}, runner.Options{
+		Authorizer: shellAuthorizer(func(command, explanation string) (runner.AuthorizerResponse, error) {
+			proposed = append(proposed, command+": "+explanation)
+			if command == "ls" {
+				return runner.AuthorizerResponse{Message: "The user rejected the command and asked for this change: show hidden files"}, nil
+			}
+			return runner.AuthorizerResponse{Accept: true}, nil
+		}, &accepted, cancel),
+	})
+	require.NoError(t, err)
+
+	prg, err := loader.ProgramFromSource(ctx, fmt.Sprintf(shellTool, "zsh", "linux", "/home/user"), "", loader.Options{})
+	require.NoError(t, err)
+
+	_, _ = r.Run(ctx, prg, nil, "list the files")
+	require.Equal(t, "ls -la", accepted)
+	require.Equal(t, []string{"ls: Lists the files", "ls -la: Lists the files"}, proposed)
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	// The change that the user asked for is sent to the model with the rejected command.
+	require.GreaterOrEqual(t, len(model.requests), 2)
+	require.Contains(t, model.requests[0].Messages[0].ChatText(), "the zsh shell on linux")
+	messages := model.requests[1].Messages
+	require.Contains(t, messages[len(messages)-1].ChatText(), "show hidden files")
+}
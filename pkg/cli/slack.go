@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/slack"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+type Slack struct {
+	root     *GPTScript
+	AppToken string `usage:"Slack app-level token (xapp-) with the connections:write scope" env:"SLACK_APP_TOKEN"`
+	BotToken string `usage:"Slack bot token (xoxb-) with the chat:write scope" env:"SLACK_BOT_TOKEN"`
+	StateDir string `usage:"Directory to persist the chat state of each channel in (default: $XDG_DATA_HOME/gptscript/slack)"`
+	SubTool  string `usage:"Use tool of this name, not the first tool in file"`
+}
+
+func (s *Slack) Customize(cmd *cobra.Command) {
+	cmd.Use = "slack <file>"
+	cmd.Short = "Connect a chat tool to Slack"
+	cmd.Long = `Connect a chat tool to Slack using Socket Mode. Direct messages to the app and mentions of it in channels are
+turns of the chat, with a separate chat for each channel that is persisted across restarts. With --confirm, tools
+that need confirmation are posted to the channel with Allow and Deny buttons, which only the user whose message
+started the run can click. The app must have Socket Mode and interactivity enabled, and subscribe to the
+app_mention and message.im events.`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (s *Slack) Run(cmd *cobra.Command, args []string) error {
+	stateDir := s.StateDir
+	if stateDir == "" {
+		stateDir = filepath.Join(xdg.DataHome, version.ProgramName, "slack")
+	}
+
+	gptOpt, err := s.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	var gptScript *gptscript.GPTScript
+	adapter, err := slack.New(slack.Options{
+		AppToken: s.AppToken,
+		BotToken: s.BotToken,
+		StateDir: stateDir,
+	}, func(ctx context.Context, prevState runner.ChatState, input string) (runner.ChatResponse, error) {
+		// Reload the program on every turn to pick up changes to the tool.
+		prg, err := loader.Program(ctx, args[0], s.SubTool, loader.Options{
+			Cache: gptScript.Cache,
+		})
+		if err != nil {
+			return runner.ChatResponse{}, err
+		}
+		return gptScript.Chat(ctx, prevState, prg, gptOpt.Env, input)
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.root.Confirm {
+		gptOpt.Runner.Authorizer = adapter.Authorize
+	}
+
+	gptScript, err = gptscript.New(cmd.Context(), gptOpt)
+	if err != nil {
+		return err
+	}
+	defer gptScript.Close(true)
+
+	return adapter.Run(cmd.Context())
+}
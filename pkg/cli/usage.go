@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	cmd2 "github.com/gptscript-ai/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/sdkserver"
+	"github.com/spf13/cobra"
+)
+
+type Usage struct{}
+
+func (u *Usage) Customize(cmd *cobra.Command) {
+	cmd.Use = "usage"
+	cmd.Short = "Report the LLM usage and cost of the runs of the SDK server"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&UsageExport{}))
+}
+
+func (u *Usage) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type UsageExport struct {
+	StorageURL string   `usage:"The postgres:// URL of the database that the SDK server stores runs in" env:"GPTSCRIPT_SDKSERVER_STORAGE_URL"`
+	From       string   `usage:"Start of the report, a date (2006-01-02) or RFC 3339 time (default: the first day of this month)"`
+	To         string   `usage:"End of the report, not included, a date (2006-01-02) or RFC 3339 time (default: now)"`
+	GroupBy    []string `usage:"Group the report by model, tool, label (all labels), or label:<name>, in order (default: model)"`
+	Format     string   `usage:"Format of the report, csv or json" default:"csv"`
+	Output     string   `usage:"File to write the report to (default: stdout)" short:"o"`
+}
+
+func (u *UsageExport) Customize(cmd *cobra.Command) {
+	cmd.Use = "export"
+	cmd.Short = "Export the LLM usage and cost of runs, grouped by model, tool, and labels, for chargeback reports"
+	cmd.Long = "Export the LLM usage and cost of the runs that the SDK server stored in the database of --storage-url " +
+		"between --from and --to. Runs are labeled by the labels of their run requests, such as a cost center."
+	cmd.Args = cobra.NoArgs
+}
+
+func (u *UsageExport) Run(cmd *cobra.Command, _ []string) error {
+	if u.StorageURL == "" {
+		return fmt.Errorf("--storage-url is required, runs are only kept in memory without it")
+	}
+
+	now := time.Now()
+	from, err := parseReportTime(u.From, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	to, err := parseReportTime(u.To, now)
+	if err != nil {
+		return err
+	}
+
+	groupBy := u.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = []string{sdkserver.GroupByModel}
+	}
+	if err := sdkserver.ValidateGroupBy(groupBy); err != nil {
+		return err
+	}
+	if u.Format != "csv" && u.Format != "json" {
+		return fmt.Errorf("invalid format %q, must be csv or json", u.Format)
+	}
+
+	storage, err := sdkserver.OpenStorage(cmd.Context(), u.StorageURL, 0)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	rows, err := sdkserver.UsageReport(cmd.Context(), storage, from, to, groupBy)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if u.Output != "" {
+		f, err := os.Create(u.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if u.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	return sdkserver.WriteUsageCSV(out, groupBy, rows)
+}
+
+// parseReportTime parses a date, which is midnight in the local time zone, or an RFC 3339 time.
+func parseReportTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be a date (2006-01-02) or RFC 3339 time", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/watch"
+	"github.com/spf13/cobra"
+)
+
+type Watch struct {
+	root        *GPTScript
+	Pattern     []string `usage:"Glob pattern of the files to watch, matched against the relative path and base name (default: all files)" short:"p"`
+	SubTool     string   `usage:"Use tool of this name, not the first tool in file"`
+	Interval    string   `usage:"How often to scan the directory for changes" default:"1s"`
+	Debounce    string   `usage:"How long a file must go unchanged before the tool is run" default:"2s"`
+	Concurrency int      `usage:"Maximum number of tool runs at once" default:"1"`
+	Existing    bool     `usage:"Also run the tool for the files that exist when the watch starts"`
+}
+
+func (w *Watch) Customize(cmd *cobra.Command) {
+	cmd.Use = "watch <dir> <file>"
+	cmd.Short = "Run a tool for every file created or modified in a directory"
+	cmd.Long = "Run a tool for every file created or modified in a directory until interrupted. The tool is given the JSON input " +
+		"`{\"path\": \"/absolute/path/of/file\", \"event\": \"created\"}`, where event is created or modified."
+	cmd.Args = cobra.ExactArgs(2)
+}
+
+func (w *Watch) Run(cmd *cobra.Command, args []string) error {
+	interval, err := time.ParseDuration(w.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid --interval: %w", err)
+	}
+	debounce, err := time.ParseDuration(w.Debounce)
+	if err != nil {
+		return fmt.Errorf("invalid --debounce: %w", err)
+	}
+
+	gptOpt, err := w.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	gptScript, err := gptscript.New(cmd.Context(), gptOpt)
+	if err != nil {
+		return err
+	}
+	defer gptScript.Close(true)
+
+	log.Infof("Watching %s", args[0])
+	return watch.Watch(cmd.Context(), args[0], watch.Options{
+		Patterns:    w.Pattern,
+		Interval:    interval,
+		Debounce:    debounce,
+		Concurrency: w.Concurrency,
+		Existing:    w.Existing,
+	}, func(ctx context.Context, event watch.Event) {
+		log.Infof("Running %s for %s file %s", args[1], event.Type, event.Path)
+		if err := w.run(ctx, gptScript, gptOpt.Env, args[1], event); err != nil {
+			log.Errorf("Run of %s for %s failed: %v", args[1], event.Path, err)
+		}
+	})
+}
+
+func (w *Watch) run(ctx context.Context, gptScript *gptscript.GPTScript, env []string, file string, event watch.Event) error {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Reload the program on every run to pick up changes to the tool.
+	prg, err := loader.Program(ctx, file, w.SubTool, loader.Options{
+		Cache: gptScript.Cache,
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := gptScript.Run(ctx, prg, env, string(input))
+	if err != nil {
+		return err
+	}
+	log.Infof("Run of %s for %s finished: %s", file, event.Path, out)
+	return nil
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	cmd2 "github.com/gptscript-ai/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/webhook"
+	"github.com/spf13/cobra"
+)
+
+type Webhook struct {
+	root *GPTScript
+}
+
+func (c *Webhook) Customize(cmd *cobra.Command) {
+	cmd.Use = "webhook"
+	cmd.Short = "Run tools in response to inbound webhooks"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&WebhookServe{root: c.root}))
+}
+
+func (c *Webhook) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type WebhookServe struct {
+	root   *GPTScript
+	Bind   string   `usage:"Address to listen on, such as :9090 to accept requests from other hosts" default:"127.0.0.1:9090"`
+	Map    []string `usage:"Map a path to the tool to run for POST requests to it (ex: --map /github=handler.gpt)"`
+	Secret []string `usage:"Secret to verify the HMAC signature of requests to a path with (ex: --secret /github=s3cr3t)" env:"GPTSCRIPT_WEBHOOK_SECRET"`
+	Wait   bool     `usage:"Respond with the output of the tool instead of 202 Accepted as soon as the request is verified"`
+}
+
+func (c *WebhookServe) Customize(cmd *cobra.Command) {
+	cmd.Use = "serve"
+	cmd.Short = "Serve HTTP endpoints that run tools with the requests they receive"
+	cmd.Long = "Serve HTTP endpoints that run tools with the requests they receive. The tool is given the JSON input " +
+		"`{\"method\": \"POST\", \"path\": \"/github\", \"query\": {}, \"headers\": {}, \"body\": \"...\"}`, with lower cased header names. " +
+		"Signatures are verified using the scheme of GitHub (X-Hub-Signature-256), Stripe (Stripe-Signature), Slack (X-Slack-Signature), " +
+		"or the hex encoded HMAC-SHA256 of the body in X-Signature-256."
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *WebhookServe) Run(cmd *cobra.Command, _ []string) error {
+	routes, err := parseRoutes(c.Map, c.Secret)
+	if err != nil {
+		return err
+	}
+
+	gptOpt, err := c.root.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	gptScript, err := gptscript.New(cmd.Context(), gptOpt)
+	if err != nil {
+		return err
+	}
+	defer gptScript.Close(true)
+
+	handler := webhook.NewServer(cmd.Context(), routes, webhook.Options{
+		Wait: c.Wait,
+		Run: func(ctx context.Context, tool, input string) (string, error) {
+			// Reload the program on every run to pick up changes to the tool.
+			prg, err := loader.Program(ctx, tool, "", loader.Options{
+				Cache: gptScript.Cache,
+			})
+			if err != nil {
+				return "", err
+			}
+			return gptScript.Run(ctx, prg, gptOpt.Env, input)
+		},
+	})
+	defer handler.Wait()
+
+	listener, err := net.Listen("tcp", c.Bind)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", c.Bind, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	context.AfterFunc(cmd.Context(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+
+	for _, route := range routes {
+		if route.Secret == "" {
+			log.Warnf("Serving %s without signature verification", route.Path)
+		}
+		log.Infof("Serving %s on http://%s%s", route.Tool, listener.Addr(), route.Path)
+	}
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func parseRoutes(mappings, secrets []string) ([]webhook.Route, error) {
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("at least one --map is required")
+	}
+
+	var (
+		routes  []webhook.Route
+		indexes = map[string]int{}
+	)
+	for _, mapping := range mappings {
+		path, tool, ok := strings.Cut(mapping, "=")
+		if !ok || !strings.HasPrefix(path, "/") || tool == "" {
+			return nil, fmt.Errorf("invalid --map %q, expected /path=tool", mapping)
+		}
+		// The path is a pattern of the mux of the server, where braces are wildcards and spaces separate the method.
+		if strings.ContainsAny(path, "{} \t") {
+			return nil, fmt.Errorf("invalid --map %q, the path must not have braces or spaces", mapping)
+		}
+		if _, ok := indexes[path]; ok {
+			return nil, fmt.Errorf("path %s is mapped more than once", path)
+		}
+		indexes[path] = len(routes)
+		routes = append(routes, webhook.Route{
+			Path: path,
+			Tool: tool,
+		})
+	}
+
+	for _, secret := range secrets {
+		path, value, ok := strings.Cut(secret, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid --secret, expected /path=secret")
+		}
+		i, ok := indexes[path]
+		if !ok {
+			return nil, fmt.Errorf("--secret for %s does not match any --map", path)
+		}
+		routes[i].Secret = value
+	}
+
+	return routes, nil
+}
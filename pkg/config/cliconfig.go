@@ -55,11 +55,61 @@ type CLIConfig struct {
 	Auths               map[string]AuthConfig `json:"auths,omitempty"`
 	CredentialsStore    string                `json:"credsStore,omitempty"`
 	GPTScriptConfigFile string                `json:"gptscriptConfig,omitempty"`
+	// Quotas are keyed by credential context, the key * applies to contexts without their own entry
+	Quotas map[string]Quota `json:"quotas,omitempty"`
+	// Prices are keyed by model name and override the built-in prices used to compute the cost of runs
+	Prices map[string]Price `json:"prices,omitempty"`
+	// PruneUnusedDays enables daily pruning of tool environments, and the runtimes only they use, that have not been
+	// used for this many days
+	PruneUnusedDays int `json:"pruneUnusedDays,omitempty"`
+	// Proxies route the HTTP requests of GPTScript to hosts through proxies, the first rule that matches the host of a
+	// request is used
+	Proxies []ProxyRule `json:"proxies,omitempty"`
+	// ModelAliases map the names of models in scripts to the names that their providers know them by, such as the
+	// names of Azure OpenAI deployments
+	ModelAliases map[string]string `json:"modelAliases,omitempty"`
 
 	auths     map[string]types.AuthConfig
 	authsLock *sync.Mutex
 }
 
+// Quota limits the LLM usage of a credential context. Zero limits are not enforced.
+type Quota struct {
+	DailyTokens   int     `json:"dailyTokens,omitempty"`
+	MonthlyTokens int     `json:"monthlyTokens,omitempty"`
+	DailyCost     float64 `json:"dailyCost,omitempty"`
+	MonthlyCost   float64 `json:"monthlyCost,omitempty"`
+	// WarnAt is the fraction of a limit at which a warning is logged, defaults to 0.8
+	WarnAt float64 `json:"warnAt,omitempty"`
+	// Prices are keyed by model name and used to compute the cost of a call
+	Prices map[string]Price `json:"prices,omitempty"`
+}
+
+// ProxyRule routes the requests to hosts matching any of Hosts through the proxy at URL, which is direct to not use a
+// proxy.
+type ProxyRule struct {
+	Hosts []string `json:"hosts,omitempty"`
+	URL   string   `json:"url,omitempty"`
+}
+
+// Price is the cost of a model in dollars per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt,omitempty"`
+	Completion float64 `json:"completion,omitempty"`
+}
+
+// GetQuota returns the quota for the credential context, if one is configured.
+func (c *CLIConfig) GetQuota(credCtx string) (Quota, bool) {
+	if c == nil {
+		return Quota{}, false
+	}
+	if q, ok := c.Quotas[credCtx]; ok {
+		return q, true
+	}
+	q, ok := c.Quotas["*"]
+	return q, ok
+}
+
 func (c *CLIConfig) Sanitize() *CLIConfig {
 	if c == nil {
 		return nil
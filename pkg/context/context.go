@@ -57,3 +57,19 @@ func GetEnv(ctx context.Context) []string {
 	l, _ := ctx.Value(envKey{}).([]string)
 	return l
 }
+
+type labelsKey struct{}
+
+// WithLabels attaches labels to a run. The labels are added to the events of the run and passed to its tools and,
+// if enabled, to the model provider.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, labelsKey{}, labels)
+}
+
+func GetLabels(ctx context.Context) map[string]string {
+	l, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return l
+}
@@ -0,0 +1,62 @@
+package cost
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// Prices are the prices of models in dollars per million tokens, keyed by model name.
+type Prices map[string]config.Price
+
+// defaultPrices are the list prices of common models. The configuration file can override them.
+var defaultPrices = Prices{
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4o-2024-05-13": {Prompt: 5, Completion: 15},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4":             {Prompt: 30, Completion: 60},
+	"gpt-4-32k":         {Prompt: 60, Completion: 120},
+	"gpt-3.5-turbo":     {Prompt: 0.5, Completion: 1.5},
+	"o1-preview":        {Prompt: 15, Completion: 60},
+	"o1-mini":           {Prompt: 3, Completion: 12},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-opus":     {Prompt: 15, Completion: 75},
+	"claude-3-haiku":    {Prompt: 0.25, Completion: 1.25},
+}
+
+// WithDefaults returns the default prices with the given prices set over them, in order.
+func WithDefaults(prices ...map[string]config.Price) Prices {
+	result := maps.Clone(defaultPrices)
+	for _, p := range prices {
+		maps.Copy(result, p)
+	}
+	return result
+}
+
+// Price returns the price of a model. A model without a price of its own, such as a dated version like
+// gpt-4o-mini-2024-07-18, has the price of the longest model name that it starts with followed by a dash.
+func (p Prices) Price(model string) (config.Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	var (
+		match string
+		price config.Price
+	)
+	for name, candidate := range p {
+		if len(name) > len(match) && strings.HasPrefix(model, name+"-") {
+			match, price = name, candidate
+		}
+	}
+	return price, match != ""
+}
+
+// Cost returns the cost in dollars of the usage of a model, which is 0 if the model has no price.
+func (p Prices) Cost(model string, usage types.Usage) float64 {
+	price, _ := p.Price(model)
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1_000_000
+}
@@ -0,0 +1,45 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrice(t *testing.T) {
+	prices := WithDefaults(map[string]config.Price{
+		"gpt-4o": {Prompt: 1, Completion: 2},
+		"local":  {},
+	})
+
+	for model, expected := range map[string]config.Price{
+		"gpt-4o":                 {Prompt: 1, Completion: 2},
+		"gpt-4o-2024-08-06":      {Prompt: 1, Completion: 2},
+		"gpt-4o-2024-05-13":      {Prompt: 5, Completion: 15},
+		"gpt-4o-mini-2024-07-18": {Prompt: 0.15, Completion: 0.6},
+		"gpt-4-0613":             {Prompt: 30, Completion: 60},
+		"local":                  {},
+	} {
+		price, ok := prices.Price(model)
+		require.True(t, ok, model)
+		require.Equal(t, expected, price, model)
+	}
+
+	for _, model := range []string{"gpt-4o2", "llama3.1", ""} {
+		_, ok := prices.Price(model)
+		require.False(t, ok, model)
+	}
+
+	// The defaults are not changed by overrides.
+	price, _ := WithDefaults().Price("gpt-4o")
+	require.Equal(t, config.Price{Prompt: 2.5, Completion: 10}, price)
+}
+
+func TestCost(t *testing.T) {
+	prices := Prices{"model": {Prompt: 2, Completion: 10}}
+	usage := types.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}
+	require.InDelta(t, 0.007, prices.Cost("model", usage), 1e-9)
+	require.Zero(t, prices.Cost("unknown", usage))
+}
@@ -0,0 +1,102 @@
+package credentials
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// CachedStore keeps the credentials that were read from a store for a while, because reading a credential can run a
+// credential helper program, which is slow when a run has many tools. Adding or removing a credential through the
+// CachedStore updates the cache, but changes made by other processes are only seen once the cached entry expires.
+type CachedStore struct {
+	store   CredentialStore
+	ttl     time.Duration
+	now     func() time.Time
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	cred    *Credential
+	exists  bool
+	expires time.Time
+}
+
+// NewCachedStore returns a store that caches the credentials read from store for ttl. The store is returned as is if
+// ttl is not positive.
+func NewCachedStore(store CredentialStore, ttl time.Duration) CredentialStore {
+	if ttl <= 0 {
+		return store
+	}
+	return &CachedStore{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+func (s *CachedStore) Get(ctx context.Context, toolName string) (*Credential, bool, error) {
+	s.lock.Lock()
+	entry, ok := s.entries[toolName]
+	s.lock.Unlock()
+
+	if ok && s.now().Before(entry.expires) {
+		return copyCredential(entry.cred), entry.exists, nil
+	}
+
+	cred, exists, err := s.store.Get(ctx, toolName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.lock.Lock()
+	s.entries[toolName] = cacheEntry{
+		cred:    copyCredential(cred),
+		exists:  exists,
+		expires: s.now().Add(s.ttl),
+	}
+	s.lock.Unlock()
+
+	return cred, exists, nil
+}
+
+func (s *CachedStore) Add(ctx context.Context, cred Credential) error {
+	defer s.Invalidate(cred.ToolName)
+	return s.store.Add(ctx, cred)
+}
+
+func (s *CachedStore) Remove(ctx context.Context, toolName string) error {
+	defer s.Invalidate(toolName)
+	return s.store.Remove(ctx, toolName)
+}
+
+// List isn't cached, because it is only used to show the credentials to the user.
+func (s *CachedStore) List(ctx context.Context) ([]Credential, error) {
+	return s.store.List(ctx)
+}
+
+// Invalidate removes a credential from the cache, so that it is read from the store the next time, or all of them if
+// no names are given.
+func (s *CachedStore) Invalidate(toolNames ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(toolNames) == 0 {
+		clear(s.entries)
+	}
+	for _, toolName := range toolNames {
+		delete(s.entries, toolName)
+	}
+}
+
+func copyCredential(cred *Credential) *Credential {
+	if cred == nil {
+		return nil
+	}
+	c := *cred
+	c.Env = maps.Clone(cred.Env)
+	return &c
+}
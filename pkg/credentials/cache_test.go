@@ -0,0 +1,75 @@
+package credentials
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingStore struct {
+	NoopStore
+	creds map[string]Credential
+	gets  int
+}
+
+func (s *countingStore) Get(_ context.Context, toolName string) (*Credential, bool, error) {
+	s.gets++
+	cred, ok := s.creds[toolName]
+	if !ok {
+		return nil, false, nil
+	}
+	return &cred, true, nil
+}
+
+func (s *countingStore) Add(_ context.Context, cred Credential) error {
+	s.creds[cred.ToolName] = cred
+	return nil
+}
+
+func (s *countingStore) Remove(_ context.Context, toolName string) error {
+	delete(s.creds, toolName)
+	return nil
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingStore{creds: map[string]Credential{
+		"github": {ToolName: "github", Env: map[string]string{"GITHUB_TOKEN": "one"}},
+	}}
+
+	now := time.Now()
+	store := NewCachedStore(backing, time.Minute).(*CachedStore)
+	store.now = func() time.Time { return now }
+
+	get := func(toolName string) *Credential {
+		t.Helper()
+		cred, _, err := store.Get(ctx, toolName)
+		require.NoError(t, err)
+		return cred
+	}
+
+	require.Equal(t, "one", get("github").Env["GITHUB_TOKEN"])
+	get("github").Env["GITHUB_TOKEN"] = "changed by the caller"
+	require.Equal(t, "one", get("github").Env["GITHUB_TOKEN"])
+	require.Nil(t, get("missing"))
+	require.Nil(t, get("missing"))
+	require.Equal(t, 2, backing.gets)
+
+	// Adding and removing a credential is seen right away.
+	require.NoError(t, store.Add(ctx, Credential{ToolName: "github", Env: map[string]string{"GITHUB_TOKEN": "two"}}))
+	require.Equal(t, "two", get("github").Env["GITHUB_TOKEN"])
+	require.NoError(t, store.Remove(ctx, "github"))
+	require.Nil(t, get("github"))
+	require.Equal(t, 4, backing.gets)
+
+	// Changes made to the store by others are seen once the entry expires.
+	backing.creds["missing"] = Credential{ToolName: "missing"}
+	require.Nil(t, get("missing"))
+	now = now.Add(time.Minute)
+	require.NotNil(t, get("missing"))
+	require.Equal(t, 5, backing.gets)
+
+	require.Same(t, backing, NewCachedStore(backing, 0))
+}
@@ -5,19 +5,38 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/gptscript-ai/gptscript/pkg/system"
 )
 
 func SysDaemon() error {
-	ctx, cancel := context.WithCancel(context.Background())
+	// The daemon runs in a process group of its own, so it doesn't get the Ctrl-C that this process does. It is killed
+	// with its process group instead, so that it doesn't outlive this process.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return run(ctx, os.Stdin, os.Args[2], os.Args[3:]...)
+}
+
+// run runs the daemon until it exits, or kills it with every process it started when the context is canceled or
+// stdin is closed by gptscript.
+func run(ctx context.Context, stdin io.Reader, name string, args ...string) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	go func() {
-		_, _ = io.ReadAll(os.Stdin)
+		_, _ = io.ReadAll(stdin)
 		cancel()
 	}()
 
-	cmd := exec.CommandContext(ctx, os.Args[2], os.Args[3:]...)
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
-	return cmd.Run()
+	release, err := system.StartProcessTree(cmd)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return cmd.Wait()
 }
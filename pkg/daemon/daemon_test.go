@@ -0,0 +1,64 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startDaemon runs a daemon that starts a child process and writes its PID to a file, and returns the PID once the
+// child is running.
+func startDaemon(t *testing.T, ctx context.Context, stdin io.Reader) (int, <-chan error) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, stdin, "sh", "-c", `sleep 60 & echo $! > "$0"; wait`, pidFile)
+	}()
+
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil || !strings.HasSuffix(string(data), "\n") {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return pid, done
+}
+
+func requireExited(t *testing.T, pid int) {
+	require.Eventually(t, func() bool {
+		return errors.Is(syscall.Kill(pid, 0), syscall.ESRCH)
+	}, 5*time.Second, 10*time.Millisecond, "process %d is still running", pid)
+}
+
+func TestRunCanceled(t *testing.T) {
+	// Canceling the daemon, such as on Ctrl-C, kills the processes it started too.
+	ctx, cancel := context.WithCancel(context.Background())
+	stdin, _ := io.Pipe()
+	pid, done := startDaemon(t, ctx, stdin)
+	cancel()
+	require.Error(t, <-done)
+	requireExited(t, pid)
+}
+
+func TestRunStdinClosed(t *testing.T) {
+	// gptscript closes stdin of the daemon when it no longer needs it.
+	stdin, w := io.Pipe()
+	pid, done := startDaemon(t, context.Background(), stdin)
+	require.NoError(t, w.Close())
+	require.Error(t, <-done)
+	requireExited(t, pid)
+}
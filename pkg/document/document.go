@@ -0,0 +1,84 @@
+// Package document extracts the text of PDF and Office documents.
+package document
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxFileSize is the largest document that is read.
+const maxFileSize = 100 << 20
+
+// Section is a page of a PDF, a sheet of a workbook, or a slide of a presentation. Word documents have a single
+// section.
+type Section struct {
+	Title string
+	// Text is Markdown when extracting Markdown, with tables for sheets and headings for headings.
+	Text string
+}
+
+type Format string
+
+const (
+	Text     Format = "text"
+	Markdown Format = "markdown"
+)
+
+// Extract returns the sections of the PDF, DOCX, XLSX, or PPTX file.
+func Extract(path string, format Format) ([]Section, error) {
+	if format == "" {
+		format = Markdown
+	}
+	if format != Text && format != Markdown {
+		return nil, fmt.Errorf("invalid format %q, must be text or markdown", format)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxFileSize {
+		return nil, fmt.Errorf("%s is %d bytes, larger than the limit of %d bytes", path, info.Size(), maxFileSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".pdf":
+		return extractPDF(data)
+	case ".docx":
+		return extractDOCX(data, format)
+	case ".xlsx":
+		return extractXLSX(data, format)
+	case ".pptx":
+		return extractPPTX(data)
+	default:
+		return nil, fmt.Errorf("unsupported document type %q, must be .pdf, .docx, .xlsx, or .pptx", ext)
+	}
+}
+
+// Render joins the sections into a single document, with a heading for each section when extracting Markdown or a
+// separator line when extracting text.
+func Render(sections []Section, format Format) string {
+	var buf strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if section.Title != "" {
+			if format == Text {
+				fmt.Fprintf(&buf, "--- %s ---\n\n", section.Title)
+			} else {
+				fmt.Fprintf(&buf, "## %s\n\n", section.Title)
+			}
+		}
+		buf.WriteString(strings.TrimSpace(section.Text))
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,170 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func writeZip(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return writeFile(t, name, buf.Bytes())
+}
+
+func buildPDF(t testing.TB) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	_, _ = w.Write([]byte("BT /F2 12 Tf 72 700 Td <000100020003> Tj ET"))
+	require.NoError(t, w.Close())
+
+	cmap := "/CIDInit /ProcSet findresource begin\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"1 beginbfchar <0001> <00E9> endbfchar\n1 beginbfrange <0002> <0003> <0074> endbfrange\nendcmap"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 8 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 9 0 R >>",
+	}
+	streams := []struct {
+		dict string
+		data []byte
+	}{
+		{"", []byte("BT /F1 12 Tf 72 720 Td [(Hello)-300(W)20(orld)] TJ 0 -14 Td (\\(second\\) line) Tj ET")},
+		{"/Filter /FlateDecode", compressed.Bytes()},
+		{"", []byte(cmap)},
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	for i, stream := range streams {
+		fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d %s >>\nstream\n", len(objects)+i+1, len(stream.data), stream.dict)
+		buf.Write(stream.data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	sections, err := Extract(writeFile(t, "test.pdf", buildPDF(t)), Markdown)
+	require.NoError(t, err)
+	assert.Equal(t, []Section{
+		{Title: "Page 1", Text: "Hello World\n(second) line"},
+		{Title: "Page 2", Text: "étu"},
+	}, sections)
+}
+
+func TestExtractEncryptedPDF(t *testing.T) {
+	data := []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R >>\n")
+	_, err := Extract(writeFile(t, "test.pdf", data), Text)
+	assert.ErrorContains(t, err, "encrypted")
+}
+
+func TestExtractDOCX(t *testing.T) {
+	path := writeZip(t, "test.docx", map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Title</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Some </w:t></w:r><w:r><w:t>text</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr/></w:pPr><w:r><w:t>Item</w:t></w:r></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>Name</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Value</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>a|b</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+</w:body></w:document>`,
+	})
+
+	sections, err := Extract(path, Markdown)
+	require.NoError(t, err)
+	assert.Equal(t, "# Title\n\nSome text\n\n- Item\n\n| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n", Render(sections, Markdown))
+
+	sections, err = Extract(path, Text)
+	require.NoError(t, err)
+	assert.Equal(t, "Title\n\nSome text\n\n- Item\n\nName\tValue\na|b\t1\n", Render(sections, Text))
+}
+
+func TestExtractXLSX(t *testing.T) {
+	path := writeZip(t, "test.xlsx", map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="r"><sheets><sheet name="Totals" r:id="rId1"/><sheet name="Empty" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>Item</t></si><si><r><t>Co</t></r><r><t>st</t></r></si><si><t>Apple</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="2"><c r="B2" t="s"><v>0</v></c><c r="C2" t="s"><v>1</v></c></row>
+<row r="3"><c r="B3" t="s"><v>2</v></c><c r="C3"><v>1.5</v></c><c r="D3" t="b"><v>1</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData/></worksheet>`,
+	})
+
+	sections, err := Extract(path, Markdown)
+	require.NoError(t, err)
+	assert.Equal(t, []Section{
+		{Title: "Sheet: Totals", Text: "| Item | Cost |  |\n| --- | --- | --- |\n| Apple | 1.5 | TRUE |\n"},
+		{Title: "Sheet: Empty"},
+	}, sections)
+}
+
+func TestExtractPPTX(t *testing.T) {
+	path := writeZip(t, "test.pptx", map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="p" xmlns:r="r"><p:sldIdLst>` +
+			`<p:sldId id="257" r:id="rId3"/><p:sldId id="256" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships><Relationship Id="rId2" Target="slides/slide1.xml"/>` +
+			`<Relationship Id="rId3" Target="slides/slide2.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml": `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>Second</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide2.xml": `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>First </a:t></a:r><a:r><a:t>slide</a:t></a:r></a:p><a:p/></p:sld>`,
+	})
+
+	sections, err := Extract(path, Text)
+	require.NoError(t, err)
+	assert.Equal(t, "--- Slide 1 ---\n\nFirst slide\n\n--- Slide 2 ---\n\nSecond\n", Render(sections, Text))
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	_, err := Extract(writeFile(t, "test.txt", []byte("text")), Markdown)
+	assert.ErrorContains(t, err, "unsupported document type")
+
+	_, err = Extract(writeFile(t, "test.pdf", []byte("%PDF-1.4")), "html")
+	assert.ErrorContains(t, err, "invalid format")
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add([]byte("%PDF0 0 obj<"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Length 100 >>\nstream\n"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /ObjStm /N 2 /First 100 >>\nstream\n1 0\nendstream\n"))
+	f.Add(buildPDF(f))
+
+	f.Fuzz(func(_ *testing.T, data []byte) {
+		// Malformed files fail with an error instead of a panic.
+		_, _ = extractPDF(data)
+	})
+}
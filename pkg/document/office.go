@@ -0,0 +1,503 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize is the largest XML part of an Office document that is read, to guard against zip bombs.
+const maxPartSize = 200 << 20
+
+type officeFile struct {
+	files map[string]*zip.File
+}
+
+func openOffice(data []byte) (*officeFile, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Office document: %w", err)
+	}
+	f := &officeFile{
+		files: map[string]*zip.File{},
+	}
+	for _, file := range r.File {
+		f.files[file.Name] = file
+	}
+	return f, nil
+}
+
+func (o *officeFile) open(name string) (*xml.Decoder, func(), error) {
+	file, ok := o.files[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid Office document: missing %s", name)
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return xml.NewDecoder(io.LimitReader(r, maxPartSize)), func() { _ = r.Close() }, nil
+}
+
+// relationships returns the targets of the relationships of a part by ID, relative to the root of the document.
+func (o *officeFile) relationships(part string) (map[string]string, error) {
+	dir, base := path.Split(part)
+	decoder, closer, err := o.open(dir + "_rels/" + base + ".rels")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decoder.Decode(&rels); err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			result[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			result[rel.ID] = path.Join(dir, rel.Target)
+		}
+	}
+	return result, nil
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func extractDOCX(data []byte, format Format) ([]Section, error) {
+	doc, err := openOffice(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder, closer, err := doc.open("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var (
+		buf       strings.Builder
+		paragraph strings.Builder
+		prefix    string
+		inText    bool
+		row       []string
+		tableRows int
+		depth     int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tbl":
+				depth++
+				tableRows = 0
+			case "tr":
+				row = nil
+			case "tc":
+				row = append(row, "")
+			case "p":
+				paragraph.Reset()
+				prefix = ""
+			case "pStyle":
+				if format == Markdown {
+					if level, err := strconv.Atoi(strings.TrimPrefix(attr(t, "val"), "Heading")); err == nil && level > 0 && level <= 6 {
+						prefix = strings.Repeat("#", level) + " "
+					}
+				}
+			case "numPr":
+				prefix = "- "
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := paragraph.String()
+				if depth > 0 {
+					// Paragraphs of a cell are joined with spaces to keep the row on a line.
+					if len(row) > 0 && row[len(row)-1] != "" && text != "" {
+						row[len(row)-1] += " "
+					}
+					if len(row) > 0 {
+						row[len(row)-1] += text
+					}
+					continue
+				}
+				if strings.TrimSpace(text) != "" {
+					buf.WriteString(prefix + text + "\n\n")
+				}
+			case "tr":
+				buf.WriteString(tableRow(row, format))
+				if tableRows == 0 && format == Markdown {
+					buf.WriteString(tableSeparator(len(row)))
+				}
+				tableRows++
+			case "tbl":
+				depth--
+				buf.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+
+	return []Section{{Text: buf.String()}}, nil
+}
+
+func tableRow(cells []string, format Format) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		cell = strings.ReplaceAll(strings.TrimSpace(cell), "\n", " ")
+		if format == Markdown {
+			cell = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		escaped[i] = cell
+	}
+	if format == Text {
+		return strings.Join(escaped, "\t") + "\n"
+	}
+	return "| " + strings.Join(escaped, " | ") + " |\n"
+}
+
+func tableSeparator(columns int) string {
+	return "|" + strings.Repeat(" --- |", columns) + "\n"
+}
+
+func extractXLSX(data []byte, format Format) ([]Section, error) {
+	doc, err := openOffice(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedStrings, err := xlsxSharedStrings(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, err := doc.relationships("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, closer, err := doc.open("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var workbook struct {
+		Sheets []struct {
+			Name string     `xml:"name,attr"`
+			ID   []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decoder.Decode(&workbook); err != nil {
+		return nil, err
+	}
+
+	var sections []Section
+	for _, sheet := range workbook.Sheets {
+		var target string
+		for _, a := range sheet.ID {
+			if a.Name.Local == "id" {
+				target = rels[a.Value]
+			}
+		}
+		if target == "" {
+			continue
+		}
+
+		rows, err := xlsxRows(doc, target, sharedStrings)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", sheet.Name, err)
+		}
+
+		var buf strings.Builder
+		for i, row := range rows {
+			buf.WriteString(tableRow(row, format))
+			if i == 0 && format == Markdown {
+				buf.WriteString(tableSeparator(len(row)))
+			}
+		}
+		sections = append(sections, Section{
+			Title: "Sheet: " + sheet.Name,
+			Text:  buf.String(),
+		})
+	}
+	return sections, nil
+}
+
+func xlsxSharedStrings(doc *officeFile) ([]string, error) {
+	if _, ok := doc.files["xl/sharedStrings.xml"]; !ok {
+		return nil, nil
+	}
+	decoder, closer, err := doc.open("xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var (
+		result []string
+		inText bool
+		// Phonetic runs repeat the text in another script, and are skipped.
+		inPhonetic bool
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				result = append(result, "")
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic && len(result) > 0 {
+				result[len(result)-1] += string(t)
+			}
+		}
+	}
+}
+
+// xlsxRows returns the rows of a sheet with every row padded to the width of the widest row, dropping empty rows and
+// the columns before the first column with a value.
+func xlsxRows(doc *officeFile, part string, sharedStrings []string) ([][]string, error) {
+	decoder, closer, err := doc.open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline []struct {
+					Text string `xml:",chardata"`
+				} `xml:"is>r>t"`
+				InlineText string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decoder.Decode(&sheet); err != nil {
+		return nil, err
+	}
+
+	var (
+		cells    = map[int]map[int]string{}
+		maxCol   = -1
+		minCol   = -1
+		rowOrder []int
+	)
+	for rowIndex, row := range sheet.Rows {
+		values := map[int]string{}
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				if i, err := strconv.Atoi(cell.Value); err == nil && i >= 0 && i < len(sharedStrings) {
+					value = sharedStrings[i]
+				}
+			case "inlineStr":
+				value = cell.InlineText
+				for _, r := range cell.Inline {
+					value += r.Text
+				}
+			case "b":
+				value = map[string]string{"0": "FALSE", "1": "TRUE"}[cell.Value]
+			default:
+				value = cell.Value
+			}
+			if value == "" {
+				continue
+			}
+
+			values[col] = value
+			maxCol = max(maxCol, col)
+			if minCol == -1 || col < minCol {
+				minCol = col
+			}
+		}
+		if len(values) > 0 {
+			cells[rowIndex] = values
+			rowOrder = append(rowOrder, rowIndex)
+		}
+	}
+	sort.Ints(rowOrder)
+
+	var rows [][]string
+	for _, rowIndex := range rowOrder {
+		row := make([]string, maxCol-minCol+1)
+		for col, value := range cells[rowIndex] {
+			row[col-minCol] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// columnIndex returns the zero based column of a cell reference such as AB12.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+func extractPPTX(data []byte) ([]Section, error) {
+	doc, err := openOffice(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, err := doc.relationships("ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, closer, err := doc.open("ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	var presentation struct {
+		Slides []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := decoder.Decode(&presentation); err != nil {
+		return nil, err
+	}
+
+	var sections []Section
+	for i, slide := range presentation.Slides {
+		var target string
+		for _, a := range slide.Attrs {
+			if a.Name.Local == "id" && a.Name.Space != "" {
+				target = rels[a.Value]
+			}
+		}
+		if target == "" {
+			continue
+		}
+
+		text, err := pptxText(doc, target)
+		if err != nil {
+			return nil, fmt.Errorf("slide %d: %w", i+1, err)
+		}
+		sections = append(sections, Section{
+			Title: fmt.Sprintf("Slide %d", i+1),
+			Text:  text,
+		})
+	}
+	return sections, nil
+}
+
+func pptxText(doc *officeFile, part string) (string, error) {
+	decoder, closer, err := doc.open(part)
+	if err != nil {
+		return "", err
+	}
+	defer closer()
+
+	var (
+		buf       strings.Builder
+		paragraph strings.Builder
+		inText    bool
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return buf.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+			case "t":
+				inText = true
+			case "br":
+				paragraph.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if text := strings.TrimSpace(paragraph.String()); text != "" {
+					buf.WriteString(text + "\n")
+				}
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+}
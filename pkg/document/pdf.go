@@ -0,0 +1,893 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxStreamSize is the largest decoded stream of a PDF that is read, to guard against compression bombs.
+const maxStreamSize = 100 << 20
+
+// maxFormDepth limits the nesting of form XObjects, which can reference each other.
+const maxFormDepth = 8
+
+type (
+	pdfName    string
+	pdfString  string
+	pdfKeyword string
+	pdfDict    map[string]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+var (
+	objRegexp     = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	trailerRegexp = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// pdfLexer reads the objects of the PDF syntax, which is shared by the file structure and content streams.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isPDFDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch b := l.data[l.pos]; {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value reads the next object, combining "num gen R" into a reference. It returns io.EOF at the end of the data.
+func (l *pdfLexer) value() (any, error) {
+	v, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+
+	num, ok := v.(int)
+	if !ok {
+		return v, nil
+	}
+
+	// Look ahead for a reference, restoring the position if this is just a number.
+	save := l.pos
+	if gen, err := l.next(); err == nil {
+		if gen, ok := gen.(int); ok {
+			if r, err := l.next(); err == nil && r == pdfKeyword("R") {
+				return pdfRef{num: num, gen: gen}, nil
+			}
+		}
+	}
+	l.pos = save
+	return num, nil
+}
+
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	switch b := l.data[l.pos]; {
+	case b == '(':
+		return l.literalString(), nil
+	case b == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict()
+	case b == '<':
+		return l.hexString(), nil
+	case b == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case b == '[':
+		l.pos++
+		return l.array()
+	case b == ']':
+		l.pos++
+		return pdfKeyword("]"), nil
+	case b == '/':
+		l.pos++
+		return l.name(), nil
+	case b == '{' || b == '}' || b == ')' || b == '>':
+		l.pos++
+		return pdfKeyword(string(b)), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+
+	if i, err := strconv.Atoi(token); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, nil
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(token), nil
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	dict := pdfDict{}
+	for {
+		key, err := l.next()
+		if err != nil {
+			return dict, err
+		}
+		if key == pdfKeyword(">>") {
+			return dict, nil
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		value, err := l.value()
+		if err != nil {
+			return dict, err
+		}
+		if value == pdfKeyword(">>") {
+			return dict, nil
+		}
+		dict[string(name)] = value
+	}
+}
+
+func (l *pdfLexer) array() ([]any, error) {
+	var result []any
+	for {
+		v, err := l.value()
+		if err != nil {
+			return result, err
+		}
+		if v == pdfKeyword("]") {
+			return result, nil
+		}
+		result = append(result, v)
+	}
+}
+
+func (l *pdfLexer) name() pdfName {
+	var buf strings.Builder
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		b := l.data[l.pos]
+		if b == '#' && l.pos+2 < len(l.data) {
+			if decoded, err := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3])); err == nil {
+				buf.Write(decoded)
+				l.pos += 3
+				continue
+			}
+		}
+		buf.WriteByte(b)
+		l.pos++
+	}
+	return pdfName(buf.String())
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if b := l.data[l.pos]; !isPDFSpace(b) {
+			digits = append(digits, b)
+		}
+		l.pos++
+	}
+	if l.pos < len(l.data) {
+		l.pos++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded, _ := hex.DecodeString(string(digits))
+	return pdfString(decoded)
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++
+	var (
+		buf   []byte
+		depth = 1
+	)
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(buf)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+			continue
+		}
+		buf = append(buf, b)
+	}
+	return pdfString(buf)
+}
+
+type pdfDoc struct {
+	objects  map[int]any
+	trailers []pdfDict
+}
+
+func parsePDF(data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	doc := &pdfDoc{
+		objects: map[int]any{},
+	}
+
+	// Objects are found by scanning for their headers rather than by reading the cross-reference table, which is
+	// often damaged. Later definitions replace earlier ones, as in incremental updates.
+	pos := 0
+	for {
+		loc := objRegexp.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		l := &pdfLexer{data: data, pos: pos + loc[1]}
+
+		v, err := l.value()
+		if err != nil {
+			break
+		}
+		if l.pos > len(data) {
+			return nil, fmt.Errorf("malformed PDF: object %d ends past the end of the file", num)
+		}
+		if dict, ok := v.(pdfDict); ok {
+			save := l.pos
+			if kw, err := l.next(); err == nil && kw == pdfKeyword("stream") {
+				stream, err := readStream(data, l, dict)
+				if err != nil {
+					return nil, fmt.Errorf("malformed PDF: object %d: %w", num, err)
+				}
+				v = stream
+				if dict["Type"] == pdfName("XRef") {
+					doc.trailers = append(doc.trailers, dict)
+				}
+			} else {
+				l.pos = save
+			}
+		}
+		doc.objects[num] = v
+		pos = l.pos
+	}
+
+	for _, loc := range trailerRegexp.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: loc[0] + len("trailer")}
+		if v, err := l.next(); err == nil {
+			if dict, ok := v.(pdfDict); ok {
+				doc.trailers = append(doc.trailers, dict)
+			}
+		}
+	}
+
+	for _, trailer := range doc.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			return nil, fmt.Errorf("encrypted PDFs are not supported")
+		}
+	}
+
+	doc.loadObjectStreams()
+	return doc, nil
+}
+
+// readStream returns the stream that starts after the stream keyword the lexer just read.
+func readStream(data []byte, l *pdfLexer, dict pdfDict) (*pdfStream, error) {
+	start := l.pos
+	if start > len(data) {
+		return nil, fmt.Errorf("stream starts past the end of the file")
+	}
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	end := -1
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(data) {
+		rest := bytes.TrimLeft(data[start+length:], " \t\r\n")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			end = start + length
+		}
+	}
+	if end < 0 {
+		i := bytes.Index(data[start:], []byte("endstream"))
+		if i < 0 {
+			end = len(data)
+		} else {
+			end = start + i
+			for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+				end--
+			}
+		}
+	}
+
+	l.pos = end
+	if i := bytes.Index(data[end:], []byte("endstream")); i >= 0 {
+		l.pos = end + i + len("endstream")
+	}
+	return &pdfStream{dict: dict, raw: data[start:end]}, nil
+}
+
+// loadObjectStreams adds the objects compressed in object streams that are not defined directly.
+func (d *pdfDoc) loadObjectStreams() {
+	for _, v := range d.objects {
+		stream, ok := v.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := d.decode(stream)
+		if err != nil {
+			continue
+		}
+
+		n, _ := stream.dict["N"].(int)
+		first, _ := stream.dict["First"].(int)
+		if first > len(data) {
+			continue
+		}
+
+		header := &pdfLexer{data: data[:first]}
+		for i := 0; i < n; i++ {
+			numValue, err1 := header.next()
+			offsetValue, err2 := header.next()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			num, ok1 := numValue.(int)
+			offset, ok2 := offsetValue.(int)
+			if !ok1 || !ok2 || first+offset >= len(data) {
+				continue
+			}
+			if _, exists := d.objects[num]; exists {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: first + offset}
+			if v, err := l.value(); err == nil {
+				d.objects[num] = v
+			}
+		}
+	}
+}
+
+func (d *pdfDoc) resolve(v any) any {
+	for i := 0; i < 16; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch v := d.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (d *pdfDoc) decode(stream *pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+
+	data := stream.raw
+	for _, filter := range filters {
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Keep what was decoded from streams that are truncated or have a bad checksum.
+			decoded, err := io.ReadAll(io.LimitReader(r, maxStreamSize))
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			l := &pdfLexer{data: append(append([]byte{'<'}, data...), '>')}
+			data = []byte(l.hexString())
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			trimmed := bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+			decoded := make([]byte, 4*len(trimmed)/5+4)
+			n, _, err := ascii85.Decode(decoded, bytes.TrimPrefix(trimmed, []byte("<~")), true)
+			if err != nil {
+				return nil, err
+			}
+			data = decoded[:n]
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// pages returns the page dictionaries in order, with their inherited resources.
+func (d *pdfDoc) pages() []pdfDict {
+	var root pdfDict
+	for i := len(d.trailers) - 1; i >= 0 && root == nil; i-- {
+		root = d.dict(d.trailers[i]["Root"])
+	}
+	if root == nil {
+		for _, v := range d.objects {
+			if dict := d.dict(v); dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var (
+		result  []pdfDict
+		visited = map[any]bool{}
+		walk    func(node any, resources any)
+	)
+	walk = func(node any, resources any) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil {
+			return
+		}
+		if r, ok := dict["Resources"]; ok {
+			resources = r
+		}
+		if kids, ok := d.resolve(dict["Kids"]).([]any); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		page := pdfDict{}
+		for k, v := range dict {
+			page[k] = v
+		}
+		page["Resources"] = resources
+		result = append(result, page)
+	}
+	walk(root["Pages"], nil)
+	return result
+}
+
+func extractPDF(data []byte) ([]Section, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []Section
+	for i, page := range doc.pages() {
+		var content []byte
+		switch c := doc.resolve(page["Contents"]).(type) {
+		case *pdfStream:
+			content, _ = doc.decode(c)
+		case []any:
+			for _, part := range c {
+				if stream, ok := doc.resolve(part).(*pdfStream); ok {
+					decoded, _ := doc.decode(stream)
+					content = append(append(content, decoded...), '\n')
+				}
+			}
+		}
+
+		e := &textExtractor{doc: doc}
+		e.run(content, doc.dict(page["Resources"]), 0)
+		sections = append(sections, Section{
+			Title: fmt.Sprintf("Page %d", i+1),
+			Text:  cleanText(e.buf.String()),
+		})
+	}
+	return sections, nil
+}
+
+func cleanText(text string) string {
+	lines := strings.Split(text, "\n")
+	var (
+		result []string
+		blank  bool
+	)
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank && len(result) > 0 {
+				result = append(result, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		result = append(result, line)
+	}
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+type pdfFont struct {
+	codeLen int
+	cmap    map[uint32]string
+}
+
+type textExtractor struct {
+	doc   *pdfDoc
+	buf   strings.Builder
+	fonts map[string]*pdfFont
+	lastY float64
+}
+
+func (e *textExtractor) run(content []byte, resources pdfDict, depth int) {
+	var (
+		l        = &pdfLexer{data: content}
+		operands []any
+		font     *pdfFont
+		fonts    = e.doc.dict(resources["Font"])
+		xobjects = e.doc.dict(resources["XObject"])
+		cache    = map[string]*pdfFont{}
+	)
+
+	for {
+		v, err := l.value()
+		if err != nil {
+			return
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					if cache[string(name)] == nil {
+						cache[string(name)] = e.loadFont(fonts[string(name)])
+					}
+					font = cache[string(name)]
+				}
+			}
+		case "Tj":
+			e.show(font, last(operands))
+		case "'", "\"":
+			e.newline()
+			e.show(font, last(operands))
+		case "TJ":
+			if items, ok := last(operands).([]any); ok {
+				for _, item := range items {
+					switch item := item.(type) {
+					case pdfString:
+						e.show(font, item)
+					case int, float64:
+						// A large negative adjustment is the gap between words.
+						if toFloat(item) < -200 {
+							e.space()
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 && toFloat(operands[1]) != 0 {
+				e.newline()
+			} else {
+				e.space()
+			}
+		case "T*":
+			e.newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				y := toFloat(operands[5])
+				if y != e.lastY {
+					e.newline()
+				} else {
+					e.space()
+				}
+				e.lastY = y
+			}
+		case "ET":
+			e.space()
+		case "Do":
+			if depth < maxFormDepth && len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					if form, ok := e.doc.resolve(xobjects[string(name)]).(*pdfStream); ok && form.dict["Subtype"] == pdfName("Form") {
+						formResources := e.doc.dict(form.dict["Resources"])
+						if formResources == nil {
+							formResources = resources
+						}
+						if data, err := e.doc.decode(form); err == nil {
+							e.run(data, formResources, depth+1)
+						}
+					}
+				}
+			}
+		case "ID":
+			// Skip the binary data of inline images.
+			if i := bytes.Index(l.data[l.pos:], []byte("EI")); i >= 0 {
+				l.pos += i + 2
+			} else {
+				l.pos = len(l.data)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func last(operands []any) any {
+	if len(operands) == 0 {
+		return nil
+	}
+	return operands[len(operands)-1]
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func (e *textExtractor) newline() {
+	if e.buf.Len() > 0 {
+		e.buf.WriteString("\n")
+	}
+}
+
+func (e *textExtractor) space() {
+	s := e.buf.String()
+	if len(s) > 0 && s[len(s)-1] != ' ' && s[len(s)-1] != '\n' {
+		e.buf.WriteString(" ")
+	}
+}
+
+func (e *textExtractor) show(font *pdfFont, v any) {
+	s, ok := v.(pdfString)
+	if !ok {
+		return
+	}
+	if font == nil {
+		font = &pdfFont{codeLen: 1}
+	}
+
+	for i := 0; i+font.codeLen <= len(s); i += font.codeLen {
+		var code uint32
+		for j := 0; j < font.codeLen; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		if text, ok := font.cmap[code]; ok {
+			e.buf.WriteString(text)
+		} else if font.codeLen == 1 {
+			e.buf.WriteRune(winAnsi(byte(code)))
+		}
+	}
+}
+
+// winAnsi maps the codes of simple fonts without a ToUnicode map, which are almost always WinAnsiEncoding or
+// StandardEncoding. Both match Latin-1 for letters and digits.
+func winAnsi(b byte) rune {
+	switch b {
+	case 0x91, 0x92:
+		return '\''
+	case 0x93, 0x94:
+		return '"'
+	case 0x95:
+		return '•'
+	case 0x96:
+		return '–'
+	case 0x97:
+		return '—'
+	case 0x85:
+		return '…'
+	}
+	if b < 0x20 && b != '\t' && b != '\n' {
+		return ' '
+	}
+	return rune(b)
+}
+
+func (e *textExtractor) loadFont(v any) *pdfFont {
+	font := &pdfFont{codeLen: 1}
+	dict := e.doc.dict(v)
+	if dict == nil {
+		return font
+	}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+	}
+
+	stream, ok := e.doc.resolve(dict["ToUnicode"]).(*pdfStream)
+	if !ok {
+		return font
+	}
+	data, err := e.doc.decode(stream)
+	if err != nil {
+		return font
+	}
+	font.cmap, font.codeLen = parseCMap(data, font.codeLen)
+	return font
+}
+
+// parseCMap reads the mappings of a ToUnicode CMap and the length of its codes in bytes.
+func parseCMap(data []byte, codeLen int) (map[uint32]string, int) {
+	var (
+		cmap     = map[uint32]string{}
+		l        = &pdfLexer{data: data}
+		operands []any
+	)
+
+	code := func(v any) (uint32, bool) {
+		s, ok := v.(pdfString)
+		if !ok || len(s) == 0 || len(s) > 4 {
+			return 0, false
+		}
+		var c uint32
+		for i := 0; i < len(s); i++ {
+			c = c<<8 | uint32(s[i])
+		}
+		return c, true
+	}
+
+	for {
+		v, err := l.next()
+		if err != nil {
+			return cmap, codeLen
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if s, ok := operands[0].(pdfString); ok && len(s) > 0 {
+					codeLen = len(s)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if c, ok := code(operands[i]); ok {
+					if dst, ok := operands[i+1].(pdfString); ok {
+						cmap[c] = utf16String(dst)
+					}
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := code(operands[i])
+				hi, ok2 := code(operands[i+1])
+				if !ok1 || !ok2 || hi < lo || hi-lo > 0xffff {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					runes := utf16.Decode(utf16Units(dst))
+					if len(runes) == 0 {
+						continue
+					}
+					// The last character of the destination is incremented for each code of the range.
+					base := runes[len(runes)-1]
+					for c := lo; c <= hi; c++ {
+						runes[len(runes)-1] = base + rune(c-lo)
+						cmap[c] = string(runes)
+					}
+				case []any:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && lo+uint32(j) <= hi {
+							cmap[lo+uint32(j)] = utf16String(s)
+						}
+					}
+				}
+			}
+		}
+		if strings.HasPrefix(string(op), "end") || strings.HasPrefix(string(op), "begin") {
+			operands = operands[:0]
+		}
+	}
+}
+
+func utf16Units(s pdfString) []uint16 {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return units
+}
+
+func utf16String(s pdfString) string {
+	return string(utf16.Decode(utf16Units(s)))
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/counter"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const routerPrompt = `You route the request of the user to the agent that is best suited to handle it, by calling that agent.
+Call exactly one agent and do not answer the request yourself.`
+
+// Route is the agent that the input of a tool of the agents type was routed to.
+type Route struct {
+	// Agent is the name of the agent in the tool that routed to it.
+	Agent  string `json:"agent,omitempty"`
+	ToolID string `json:"toolID,omitempty"`
+	// Model is the model that chose the agent. It is empty when the tool has only one agent.
+	Model string `json:"model,omitempty"`
+}
+
+// runAgents routes the input of a tool of the agents type to one of its agents, which the model of the tool chooses by
+// their descriptions. The agent is called with the input as it is, and its output is the output of the tool. The body,
+// after the #!sys.agents line if it has one, is added to the instructions of the model.
+func (e *Engine) runAgents(ctx Context, tool types.Tool, input string) (*Return, error) {
+	agents, err := tool.GetAgentCompletionTools(*ctx.Program)
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("tool %s has no agents to route to", tool.Name)
+	}
+
+	prompt := routerPrompt
+	instructions := tool.Instructions
+	if strings.HasPrefix(instructions, types.AgentsPrefix) {
+		_, instructions, _ = strings.Cut(instructions, "\n")
+	}
+	if strings.TrimSpace(instructions) != "" {
+		prompt += "\n" + expandArgs(strings.TrimSpace(instructions), input)
+	}
+	parallel := false
+	state := &State{
+		Completion: types.CompletionRequest{
+			Model:                tool.Parameters.ModelName,
+			Cache:                tool.Parameters.Cache,
+			Temperature:          tool.Parameters.Temperature,
+			InternalSystemPrompt: new(bool),
+			Tools:                agents,
+			ToolChoice:           types.ToolChoiceRequired,
+			ParallelToolCalls:    &parallel,
+			Messages: []types.CompletionMessage{
+				{
+					Role:    types.CompletionMessageRoleTypeSystem,
+					Content: types.Text(prompt),
+				},
+				{
+					Role:    types.CompletionMessageRoleTypeUser,
+					Content: types.Text(input),
+				},
+			},
+		},
+	}
+
+	var (
+		agent = agents[0]
+		id    = counter.Next()
+		model string
+	)
+	if len(agents) > 1 {
+		ret, err := e.complete(ctx.Ctx, state)
+		if err != nil {
+			return nil, err
+		}
+		state, model = ret.State, state.Completion.Model
+		// The first agent is used when the model doesn't call one.
+		if called, callID, ok := calledAgent(agents, state.Completion.Messages[len(state.Completion.Messages)-1]); ok {
+			agent, id = called, callID
+		}
+	}
+
+	return &Return{
+		State: state,
+		Calls: map[string]Call{
+			id: {
+				ToolID:    agent.Function.ToolID,
+				Input:     input,
+				Overrides: agent.Function.Overrides,
+			},
+		},
+		Route: &Route{
+			Agent:  agent.Function.Name,
+			ToolID: agent.Function.ToolID,
+			Model:  model,
+		},
+	}, nil
+}
+
+// continueAgents returns the output of the agent that a tool of the agents type routed to. New input from the user is
+// routed again.
+func (e *Engine) continueAgents(ctx Context, state *State, results []CallResult) (*Return, error) {
+	for _, result := range results {
+		if result.CallID == "" {
+			return e.runAgents(ctx, ctx.Tool, result.User)
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("invalid continue call, no result of the agent")
+	}
+	return &Return{
+		State:  state,
+		Result: &results[0].Result,
+	}, nil
+}
+
+// calledAgent returns the first agent that the response calls.
+func calledAgent(agents []types.CompletionTool, response types.CompletionMessage) (types.CompletionTool, string, bool) {
+	for _, content := range response.Content {
+		if content.ToolCall == nil {
+			continue
+		}
+		for _, agent := range agents {
+			if agent.Function.Name == content.ToolCall.Function.Name {
+				return agent, content.ToolCall.ID, true
+			}
+		}
+	}
+	return types.CompletionTool{}, "", false
+}
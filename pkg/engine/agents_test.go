@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type routingModel struct {
+	agent    string
+	requests []types.CompletionRequest
+}
+
+func (m *routingModel) Call(_ context.Context, request types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	m.requests = append(m.requests, request)
+	return &types.CompletionMessage{
+		Role: types.CompletionMessageRoleTypeAssistant,
+		Content: []types.ContentPart{{ToolCall: &types.CompletionToolCall{
+			ID:       "call_1",
+			Function: types.CompletionFunctionCall{Name: m.agent, Arguments: "{}"},
+		}}},
+	}, nil
+}
+
+func agentsProgram(agents ...string) *types.Program {
+	router := types.Tool{
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Name:      "support",
+				ModelName: "gpt-4o-mini",
+				Agents:    agents,
+			},
+			Instructions: types.AgentsPrefix + "\nSend questions about invoices to billing.",
+		},
+		ID:          "support.gpt:support",
+		ToolMapping: map[string][]types.ToolReference{},
+	}
+	prg := &types.Program{
+		EntryToolID: router.ID,
+		ToolSet:     types.ToolSet{},
+	}
+	for _, agent := range agents {
+		id := "support.gpt:" + agent
+		router.ToolMapping[agent] = []types.ToolReference{{Reference: agent, ToolID: id}}
+		prg.ToolSet[id] = types.Tool{
+			ToolDef: types.ToolDef{
+				Parameters:   types.Parameters{Name: agent, Description: "Answers questions about " + agent},
+				Instructions: "You answer questions about " + agent,
+			},
+			ID: id,
+		}
+	}
+	prg.ToolSet[router.ID] = router
+	return prg
+}
+
+func TestRunAgents(t *testing.T) {
+	model := &routingModel{agent: "billing"}
+	e := &Engine{Model: model}
+	prg := agentsProgram("shipping", "billing")
+	var ctx Context
+	ctx.Ctx = context.Background()
+	ctx.Program = prg
+	ctx.Tool = prg.ToolSet[prg.EntryToolID]
+
+	ret, err := e.Start(ctx, "Why was I charged twice?")
+	require.NoError(t, err)
+	require.Equal(t, &Route{Agent: "billing", ToolID: "support.gpt:billing", Model: "gpt-4o-mini"}, ret.Route)
+	// The agent gets the input as it is, not the arguments of the call of the model.
+	require.Equal(t, map[string]Call{"call_1": {ToolID: "support.gpt:billing", Input: "Why was I charged twice?"}}, ret.Calls)
+
+	require.Len(t, model.requests, 1)
+	request := model.requests[0]
+	require.Equal(t, "gpt-4o-mini", request.Model)
+	require.Equal(t, types.ToolChoiceRequired, request.ToolChoice)
+	require.Len(t, request.Tools, 2)
+	require.Contains(t, request.Messages[0].ChatText(), "Send questions about invoices to billing.")
+	require.Equal(t, "Why was I charged twice?", request.Messages[1].ChatText())
+
+	// The output of the agent is the output of the tool.
+	ret, err = e.Continue(ctx, ret.State, CallResult{CallID: "call_1", Result: "You were refunded."})
+	require.NoError(t, err)
+	require.Equal(t, "You were refunded.", *ret.Result)
+	require.Nil(t, ret.Route)
+
+	// New input is routed again.
+	model.agent = "shipping"
+	ret, err = e.Continue(ctx, ret.State, CallResult{User: "Where is my order?"})
+	require.NoError(t, err)
+	require.Equal(t, "shipping", ret.Route.Agent)
+	require.Len(t, model.requests, 2)
+
+	// Tools of the agents type route their input without the #!sys.agents line.
+	ctx.Tool.Type = types.ToolTypeAgents
+	ctx.Tool.Instructions = "Send questions about deliveries to shipping."
+	ret, err = e.Start(ctx, "Where is my order?")
+	require.NoError(t, err)
+	require.Equal(t, "shipping", ret.Route.Agent)
+	require.Contains(t, model.requests[2].Messages[0].ChatText(), "Send questions about deliveries to shipping.")
+}
+
+func TestRunAgentsOne(t *testing.T) {
+	model := &routingModel{}
+	e := &Engine{Model: model}
+	prg := agentsProgram("billing")
+	var ctx Context
+	ctx.Ctx = context.Background()
+	ctx.Program = prg
+	ctx.Tool = prg.ToolSet[prg.EntryToolID]
+
+	// A single agent is called without asking the model.
+	ret, err := e.Start(ctx, "Why was I charged twice?")
+	require.NoError(t, err)
+	require.Equal(t, "billing", ret.Route.Agent)
+	require.Empty(t, ret.Route.Model)
+	require.Len(t, ret.Calls, 1)
+	require.Empty(t, model.requests)
+
+	ctx.Tool.Agents = nil
+	_, err = e.Start(ctx, "Why was I charged twice?")
+	require.ErrorContains(t, err, "no agents")
+}
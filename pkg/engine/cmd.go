@@ -17,6 +17,7 @@ import (
 	"github.com/google/shlex"
 	"github.com/gptscript-ai/gptscript/pkg/counter"
 	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/system"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/gptscript-ai/gptscript/pkg/version"
 )
@@ -57,12 +58,17 @@ func (e *Engine) runCommand(ctx Context, tool types.Tool, input string, toolCate
 		}
 	}()
 
+	dir, err := e.workDir(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	if tool.BuiltinFunc != nil {
 		e.Progress <- types.CompletionStatus{
 			CompletionID: id,
 			Request: map[string]any{
 				"command": []string{tool.ID},
-				"input":   input,
+				"input":   redactInput(tool, input),
 			},
 		}
 
@@ -88,28 +94,50 @@ func (e *Engine) runCommand(ctx Context, tool types.Tool, input string, toolCate
 			}
 		}()
 
-		return tool.BuiltinFunc(ctx.WrappedContext(), e.Env, input, progress)
+		callCtx := ctx.WrappedContext()
+		if dir != "" {
+			callCtx = withWorkDir(callCtx, dir, e.ConfineTools)
+		} else if e.Dir != "" {
+			callCtx = withWorkDir(callCtx, e.Dir, false)
+		}
+		return tool.BuiltinFunc(callCtx, e.Env, input, progress)
+	}
+
+	// Like sys.exec, a command can open any file, so it can't be kept in the working directory.
+	if dir != "" && e.ConfineTools {
+		err := fmt.Errorf("command tool %s can't be run by a tool that is confined to its working directory %s", tool.Parameters.Name, dir)
+		if toolCategory == NoCategory {
+			return fmt.Sprintf("ERROR: %v", err), nil
+		}
+		return "", err
 	}
 
 	var instructions []string
 	for _, inputContext := range ctx.InputContext {
 		instructions = append(instructions, inputContext.Content)
 	}
-	var extraEnv = []string{
+	var extraEnv = append([]string{
 		strings.TrimSpace(fmt.Sprintf("GPTSCRIPT_CONTEXT=%s", strings.Join(instructions, "\n"))),
+	}, traceEnv(ctx.Ctx)...)
+
+	daemonEnv, err := e.daemonEnv(ctx.Program, tool, nil)
+	if err != nil {
+		return "", err
 	}
+	extraEnv = append(extraEnv, daemonEnv...)
 
 	cmd, stop, err := e.newCommand(ctx.Ctx, extraEnv, tool, input)
 	if err != nil {
 		return "", err
 	}
 	defer stop()
+	cmd.Dir = types.FirstSet(dir, e.Dir)
 
 	e.Progress <- types.CompletionStatus{
 		CompletionID: id,
 		Request: map[string]any{
-			"command": cmd.Args,
-			"input":   input,
+			"command": redactArgs(cmd.Args, e.Secrets),
+			"input":   redactInput(tool, input),
 		},
 	}
 
@@ -121,12 +149,17 @@ func (e *Engine) runCommand(ctx Context, tool types.Tool, input string, toolCate
 		progress: e.Progress,
 	})
 
-	if err := cmd.Run(); err != nil {
+	release, err := system.StartProcessTree(cmd)
+	if err == nil {
+		err = cmd.Wait()
+		release()
+	}
+	if err != nil {
 		if toolCategory == NoCategory {
 			return fmt.Sprintf("ERROR: got (%v) while running tool, OUTPUT: %s", err, all), nil
 		}
 		_, _ = os.Stderr.Write(output.Bytes())
-		log.Errorf("failed to run tool [%s] cmd %v: %v", tool.Parameters.Name, cmd.Args, err)
+		log.WithContext(ctx.Ctx).Errorf("failed to run tool [%s] cmd %v: %v", tool.Parameters.Name, cmd.Args, err)
 		return "", fmt.Errorf("ERROR: %s: %w", all, err)
 	}
 
@@ -180,6 +213,65 @@ func appendEnv(envs []string, k, v string) []string {
 	return envs
 }
 
+// redactInput hides the values of the parameters that are filled from credentials, so that they aren't in the events.
+func redactInput(tool types.Tool, input string) string {
+	if len(tool.Parameters.CredentialArguments) == 0 {
+		return input
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return input
+	}
+	for name := range tool.Parameters.CredentialArguments {
+		if _, ok := args[name]; ok {
+			args[name] = "<redacted>"
+		}
+	}
+
+	redacted, err := json.Marshal(args)
+	if err != nil {
+		return input
+	}
+	return string(redacted)
+}
+
+// redactArgs hides the secrets in the arguments of a command, such as credentials that the command line has from the
+// environment, so that they aren't in the events.
+func redactArgs(args, secrets []string) []string {
+	if len(secrets) == 0 {
+		return args
+	}
+
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		for _, secret := range secrets {
+			arg = strings.ReplaceAll(arg, secret, "<redacted>")
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+// credentialArguments returns the values of the parameters of the tool that are filled from credentials, by name.
+func credentialArguments(tool types.Tool, input string) map[string]string {
+	if len(tool.Parameters.CredentialArguments) == 0 {
+		return nil
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return nil
+	}
+	result := map[string]string{}
+	for name := range tool.Parameters.CredentialArguments {
+		if value, ok := args[name].(string); ok && value != "" {
+			result[name] = value
+		}
+	}
+	return result
+}
+
 func appendInputAsEnv(env []string, input string) []string {
 	data := map[string]any{}
 	dec := json.NewDecoder(bytes.NewReader([]byte(input)))
@@ -238,6 +330,16 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 		})
 	}
 
+	// Parameters from credentials are only passed in the environment, because the command line of a process can be
+	// read by other processes.
+	for name, value := range credentialArguments(tool, input) {
+		for _, arg := range args {
+			if strings.Contains(arg, value) {
+				return nil, nil, fmt.Errorf("parameter %s of tool %s is filled from a credential and can't be in its command line, read it from the environment variable %s instead", name, tool.Name, env.ToEnvLike(name))
+			}
+		}
+	}
+
 	if runtime.GOOS == "windows" && (args[0] == "/usr/bin/env" || args[0] == "/bin/env") {
 		args = args[1:]
 	}
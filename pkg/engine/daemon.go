@@ -3,10 +3,13 @@ package engine
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"io"
-	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -49,67 +52,200 @@ func CloseDaemons() {
 	ports.daemonWG.Wait()
 }
 
-func nextPort() int64 {
+const (
+	// defaultStartupTimeout is how long a daemon has to respond to its health check, unless it sets a timeout.
+	defaultStartupTimeout = 120 * time.Second
+	// preferredPortTries is how many ports after its preferred port a daemon tries when the port is taken.
+	preferredPortTries = 100
+)
+
+// daemonOptions are set in parentheses after the #!sys.daemon prefix of a tool, such as
+// "#!sys.daemon (path=/api, health=/healthz, port=8080, timeout=30s) node server.js".
+type daemonOptions struct {
+	// path is appended to the address of the daemon to get the URL of the tool.
+	path string
+	// health is the path that must respond with 200 OK once the daemon started, which defaults to path.
+	health string
+	// port is the port the daemon prefers to listen on, which is used unless another process has it.
+	port int64
+	// timeout is how long the daemon has to start.
+	timeout time.Duration
+}
+
+func parseDaemonOptions(instructions string) (string, daemonOptions, error) {
+	opts := daemonOptions{
+		timeout: defaultStartupTimeout,
+	}
+
+	instructions = strings.TrimSpace(instructions)
+	if !strings.HasPrefix(instructions, "(") {
+		return instructions, opts, nil
+	}
+
+	line, rest, ok := strings.Cut(instructions[1:], ")")
+	if !ok {
+		return instructions, opts, nil
+	}
+
+	for _, option := range strings.Split(line, ",") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return "", opts, fmt.Errorf("invalid daemon option %q, expected key=value", strings.TrimSpace(option))
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "path":
+			opts.path = value
+		case "health":
+			opts.health = value
+		case "port":
+			port, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || port < 1 || port > 65535 {
+				return "", opts, fmt.Errorf("invalid daemon port %q", value)
+			}
+			opts.port = port
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return "", opts, fmt.Errorf("invalid daemon timeout %q", value)
+			}
+			opts.timeout = timeout
+		default:
+			return "", opts, fmt.Errorf("unknown daemon option %q, expected path, health, port, or timeout", key)
+		}
+	}
+
+	if opts.health == "" {
+		opts.health = opts.path
+	}
+	return strings.TrimSpace(rest), opts, nil
+}
+
+// nextPort returns the first free port for the daemon. A daemon with a preferred port gets it, or else the next free
+// port after it. Other daemons get a port in the configured range, starting at a position derived from the ID of the
+// tool, so that a daemon usually gets the same port every time. Ports that other processes listen on are skipped.
+// It must be called with the daemonLock held.
+func nextPort(toolID string, preferred int64) (int64, error) {
 	if ports.startPort == 0 {
 		ports.startPort = 10240
 		ports.endPort = 11240
 	}
-	// This is pretty simple and inefficient approach, but also never releases ports
-	count := ports.endPort - ports.startPort + 1
-	toTry := make([]int64, 0, count)
-	for i := ports.startPort; i <= ports.endPort; i++ {
-		toTry = append(toTry, i)
-	}
 
-	rand.Shuffle(len(toTry), func(i, j int) {
-		toTry[i], toTry[j] = toTry[j], toTry[i]
-	})
+	var candidates []int64
+	if preferred > 0 {
+		for i := int64(0); i < preferredPortTries && preferred+i <= 65535; i++ {
+			candidates = append(candidates, preferred+i)
+		}
+	} else {
+		count := ports.endPort - ports.startPort + 1
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(toolID))
+		offset := int64(h.Sum64() % uint64(count))
+		for i := int64(0); i < count; i++ {
+			candidates = append(candidates, ports.startPort+(offset+i)%count)
+		}
+	}
 
-	for _, nextPort := range toTry {
-		if _, ok := ports.usedPorts[nextPort]; ok {
+	for _, port := range candidates {
+		if _, ok := ports.usedPorts[port]; ok {
+			continue
+		}
+		if !portAvailable(port) {
 			continue
 		}
 		if ports.usedPorts == nil {
 			ports.usedPorts = map[int64]struct{}{}
 		}
-		ports.usedPorts[nextPort] = struct{}{}
-		return nextPort
+		ports.usedPorts[port] = struct{}{}
+		return port, nil
 	}
 
-	panic("Ran out of usable ports")
+	if preferred > 0 {
+		return 0, fmt.Errorf("no free port in %d-%d", preferred, candidates[len(candidates)-1])
+	}
+	return 0, fmt.Errorf("no free port in %d-%d", ports.startPort, ports.endPort)
 }
 
-func getPath(instructions string) (string, string) {
-	instructions = strings.TrimSpace(instructions)
-	line := strings.TrimSpace(instructions)
-
-	if !strings.HasPrefix(line, "(") {
-		return instructions, ""
+func portAvailable(port int64) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
 	}
+	_ = l.Close()
+	return true
+}
 
-	line, rest, ok := strings.Cut(line[1:], ")")
-	if !ok {
-		return instructions, ""
+// daemonEnv starts the daemons that the tool references in its tools, and returns the variables with their URLs,
+// named GPTSCRIPT_DAEMON_URL_<NAME>. Starting tracks the daemons that are being started to break cycles.
+func (e *Engine) daemonEnv(prg *types.Program, tool types.Tool, starting map[string]bool) ([]string, error) {
+	if prg == nil || len(tool.Tools) == 0 {
+		return nil, nil
 	}
 
-	path, value, ok := strings.Cut(strings.TrimSpace(line), "=")
-	if !ok || strings.TrimSpace(path) != "path" {
-		return instructions, ""
+	refs, err := tool.GetToolRefsFromNames(tool.Tools)
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.TrimSpace(rest), strings.TrimSpace(value)
+	var result []string
+	for _, ref := range refs {
+		daemon, ok := prg.ToolSet[ref.ToolID]
+		if !ok || !daemon.IsDaemon() || starting[daemon.ID] {
+			continue
+		}
+		url, err := e.startDaemon(prg, daemon, starting)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start daemon %s: %w", daemon.Name, err)
+		}
+		name := ref.Named
+		if name == "" {
+			name = daemon.Name
+		}
+		result = append(result, daemonURLEnvPrefix+strings.ToUpper(nonEnvChars.ReplaceAllString(name, "_"))+"="+url)
+	}
+	return result, nil
 }
 
-func (e *Engine) startDaemon(tool types.Tool) (string, error) {
-	ports.daemonLock.Lock()
-	defer ports.daemonLock.Unlock()
+const daemonURLEnvPrefix = "GPTSCRIPT_DAEMON_URL_"
+
+var nonEnvChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
 
+// startDaemon starts the daemon of the tool, unless it is running, and returns its URL. Pass nil for starting unless
+// the daemon is started for another daemon.
+func (e *Engine) startDaemon(prg *types.Program, tool types.Tool, starting map[string]bool) (string, error) {
 	instructions := strings.TrimPrefix(tool.Instructions, types.DaemonPrefix)
-	instructions, path := getPath(instructions)
+	instructions, opts, err := parseDaemonOptions(instructions)
+	if err != nil {
+		return "", err
+	}
 	tool.Instructions = types.CommandPrefix + instructions
 
+	ports.daemonLock.Lock()
 	port, ok := ports.daemonPorts[tool.ID]
-	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+	ports.daemonLock.Unlock()
+	if ok {
+		return fmt.Sprintf("http://127.0.0.1:%d%s", port, opts.path), nil
+	}
+
+	// The daemons this one depends on are started first, so that it can be given their URLs.
+	if starting == nil {
+		starting = map[string]bool{}
+	}
+	starting[tool.ID] = true
+	extraEnv, err := e.daemonEnv(prg, tool, starting)
+	if err != nil {
+		return "", err
+	}
+
+	ports.daemonLock.Lock()
+	defer ports.daemonLock.Unlock()
+
+	port, ok = ports.daemonPorts[tool.ID]
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, opts.path)
 	if ok {
 		return url, nil
 	}
@@ -124,13 +260,17 @@ func (e *Engine) startDaemon(tool types.Tool) (string, error) {
 	}
 
 	ctx := ports.daemonCtx
-	port = nextPort()
-	url = fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+	port, err = nextPort(tool.ID, opts.port)
+	if err != nil {
+		return "", err
+	}
+	url = fmt.Sprintf("http://127.0.0.1:%d%s", port, opts.path)
+	healthURL := fmt.Sprintf("http://127.0.0.1:%d%s", port, opts.health)
 
-	cmd, stop, err := e.newCommand(ctx, []string{
+	cmd, stop, err := e.newCommand(ctx, append([]string{
 		fmt.Sprintf("PORT=%d", port),
 		fmt.Sprintf("GPTSCRIPT_PORT=%d", port),
-	},
+	}, extraEnv...),
 		tool,
 		"{}",
 	)
@@ -184,17 +324,24 @@ func (e *Engine) startDaemon(tool types.Tool) (string, error) {
 		defer ports.daemonLock.Unlock()
 
 		delete(ports.daemonPorts, tool.ID)
+		delete(ports.usedPorts, port)
 		ports.daemonWG.Done()
 	}()
 
-	for i := 0; i < 120; i++ {
-		resp, err := http.Get(url)
-		if err == nil && resp.StatusCode == http.StatusOK {
+	deadline := time.Now().Add(opts.timeout)
+	for {
+		resp, err := http.Get(healthURL)
+		if err == nil {
 			go func() {
 				_, _ = io.ReadAll(resp.Body)
 				_ = resp.Body.Close()
 			}()
-			return url, nil
+			if resp.StatusCode == http.StatusOK {
+				return url, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			break
 		}
 		select {
 		case <-killedCtx.Done():
@@ -203,11 +350,11 @@ func (e *Engine) startDaemon(tool types.Tool) (string, error) {
 		}
 	}
 
-	return url, fmt.Errorf("timeout waiting for 200 response from GET %s", url)
+	return url, fmt.Errorf("timeout after %s waiting for 200 response from GET %s", opts.timeout, healthURL)
 }
 
 func (e *Engine) runDaemon(ctx context.Context, prg *types.Program, tool types.Tool, input string) (cmdRet *Return, cmdErr error) {
-	url, err := e.startDaemon(tool)
+	url, err := e.startDaemon(prg, tool, nil)
 	if err != nil {
 		return nil, err
 	}
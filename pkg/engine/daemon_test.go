@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDaemonOptions(t *testing.T) {
+	rest, opts, err := parseDaemonOptions(" (path=/api, health=/healthz, port=8080, timeout=30s) node server.js")
+	require.NoError(t, err)
+	require.Equal(t, "node server.js", rest)
+	require.Equal(t, daemonOptions{path: "/api", health: "/healthz", port: 8080, timeout: 30 * time.Second}, opts)
+
+	rest, opts, err = parseDaemonOptions("(path=/api) node server.js")
+	require.NoError(t, err)
+	require.Equal(t, "node server.js", rest)
+	require.Equal(t, daemonOptions{path: "/api", health: "/api", timeout: defaultStartupTimeout}, opts)
+
+	rest, opts, err = parseDaemonOptions("node server.js")
+	require.NoError(t, err)
+	require.Equal(t, "node server.js", rest)
+	require.Equal(t, daemonOptions{timeout: defaultStartupTimeout}, opts)
+
+	_, _, err = parseDaemonOptions("(port=http) node server.js")
+	require.EqualError(t, err, `invalid daemon port "http"`)
+
+	_, _, err = parseDaemonOptions("(host=localhost) node server.js")
+	require.ErrorContains(t, err, `unknown daemon option "host"`)
+}
+
+func TestNextPort(t *testing.T) {
+	savedStart, savedEnd, savedUsed := ports.startPort, ports.endPort, ports.usedPorts
+	defer func() {
+		ports.startPort, ports.endPort, ports.usedPorts = savedStart, savedEnd, savedUsed
+	}()
+	ports.startPort, ports.endPort, ports.usedPorts = 20000, 20009, nil
+
+	// Without a preferred port, a tool gets the same port every time.
+	first, err := nextPort("tool.gpt:server", 0)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, first, int64(20000))
+	require.LessOrEqual(t, first, int64(20009))
+	delete(ports.usedPorts, first)
+	again, err := nextPort("tool.gpt:server", 0)
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+
+	// A port that is in use goes to the next daemon.
+	next, err := nextPort("tool.gpt:server", 0)
+	require.NoError(t, err)
+	require.Equal(t, 20000+(first-20000+1)%10, next)
+
+	// A preferred port that another process listens on is skipped.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	taken := int64(l.Addr().(*net.TCPAddr).Port)
+
+	port, err := nextPort("tool.gpt:api", taken)
+	require.NoError(t, err)
+	require.Greater(t, port, taken)
+
+	for range 8 {
+		_, err = nextPort("tool.gpt:other", 0)
+		require.NoError(t, err)
+	}
+	_, err = nextPort("tool.gpt:other", 0)
+	require.EqualError(t, err, fmt.Sprintf("no free port in %d-%d", 20000, 20009))
+}
@@ -10,8 +10,10 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/config"
 	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/counter"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/gptscript-ai/gptscript/pkg/version"
+	"golang.org/x/sync/errgroup"
 )
 
 type Model interface {
@@ -24,11 +26,26 @@ type RuntimeManager interface {
 	SetUpCredentialHelpers(ctx context.Context, cliCfg *config.CLIConfig, env []string) error
 }
 
+// bestOfTemperature is the temperature of the completions of a tool with Best Of set that doesn't set one.
+var bestOfTemperature float32 = 0.7
+
 type Engine struct {
 	Model          Model
 	RuntimeManager RuntimeManager
 	Env            []string
 	Progress       chan<- types.CompletionStatus
+	// Dir is the directory that the tools without a Working Dir run in, and that relative Working Dirs are relative to,
+	// instead of the current directory of the process. Unlike a Working Dir, it does not confine the tools.
+	Dir string
+	// Secrets are the values of the credentials of the tool, which are redacted from the command lines in the events
+	// of its calls.
+	Secrets []string
+	// ConfineTools keeps the file tools that are called by a tool with a working directory from using files outside
+	// of it, and refuses to run the command tools that it calls, which could open any file.
+	ConfineTools bool
+	// AcquireCandidate is called before each completion of a request with Best Of set, so that every completion counts
+	// against the limit of concurrent model calls. It returns the function that releases the slot.
+	AcquireCandidate func(ctx context.Context) (func(), error)
 }
 
 type State struct {
@@ -42,11 +59,34 @@ type Return struct {
 	State  *State          `json:"state,omitempty"`
 	Calls  map[string]Call `json:"calls,omitempty"`
 	Result *string         `json:"result,omitempty"`
+	// Candidates are the responses to choose the Result from, starting with the Result, when the tool has more than
+	// one choice and none of the responses call tools.
+	Candidates []string `json:"candidates,omitempty"`
+	// Route is set when a tool of the agents type routed its input to the agent that it calls.
+	Route *Route `json:"route,omitempty"`
+}
+
+// Choose replaces the Result, and the response in the state, with the candidate at index i.
+func (r *Return) Choose(i int) {
+	if i < 0 || i >= len(r.Candidates) {
+		return
+	}
+	r.SetResult(r.Candidates[i])
+	r.Candidates = nil
+}
+
+// SetResult replaces the Result, and the response in the state, such as with a redacted response.
+func (r *Return) SetResult(result string) {
+	r.Result = &result
+	if r.State != nil && len(r.State.Completion.Messages) > 0 {
+		r.State.Completion.Messages[len(r.State.Completion.Messages)-1].Content = types.Text(result)
+	}
 }
 
 type Call struct {
-	ToolID string `json:"toolID,omitempty"`
-	Input  string `json:"input,omitempty"`
+	ToolID    string               `json:"toolID,omitempty"`
+	Input     string               `json:"input,omitempty"`
+	Overrides *types.ToolOverrides `json:"overrides,omitempty"`
 }
 
 type CallResult struct {
@@ -101,6 +141,9 @@ const (
 	ContextToolCategory    ToolCategory = "context"
 	InputToolCategory      ToolCategory = "input"
 	OutputToolCategory     ToolCategory = "output"
+	ValidateToolCategory   ToolCategory = "validate"
+	ChooseToolCategory     ToolCategory = "choose"
+	GuardrailToolCategory  ToolCategory = "guardrail"
 	NoCategory             ToolCategory = ""
 )
 
@@ -188,15 +231,19 @@ func ToolCategoryFromContext(ctx context.Context) ToolCategory {
 }
 
 func NewContext(ctx context.Context, prg *types.Program, input string) (Context, error) {
-	category := ToolCategoryFromContext(ctx)
+	var (
+		category = ToolCategoryFromContext(ctx)
+		id       = counter.Next()
+		tool     = prg.ToolSet[prg.EntryToolID]
+	)
 
 	callCtx := Context{
 		commonContext: commonContext{
-			ID:           counter.Next(),
-			Tool:         prg.ToolSet[prg.EntryToolID],
+			ID:           id,
+			Tool:         tool,
 			ToolCategory: category,
 		},
-		Ctx:     ctx,
+		Ctx:     withCallTrace(mvl.WithFields(ctx, "callID", id, "tool", tool.Name), id),
 		Program: prg,
 		Input:   input,
 	}
@@ -232,7 +279,7 @@ func (c *Context) SubCallContext(ctx context.Context, input, toolID, callID stri
 			AgentGroup:   agentGroup,
 			ToolCategory: toolCategory,
 		},
-		Ctx:           ctx,
+		Ctx:           withCallTrace(mvl.WithFields(ctx, "callID", callID, "tool", tool.Name), callID),
 		Parent:        c,
 		Program:       c.Program,
 		CurrentReturn: c.CurrentReturn,
@@ -260,23 +307,21 @@ func (e *Engine) Start(ctx Context, input string) (ret *Return, _ error) {
 		}
 	}()
 
+	input, err := e.fileInput(ctx, tool, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if tool.IsAgents() {
+		return e.runAgents(ctx, tool, input)
+	}
+
 	if tool.IsCommand() {
-		if tool.IsHTTP() {
-			return e.runHTTP(ctx.Ctx, ctx.Program, tool, input)
-		} else if tool.IsDaemon() {
-			return e.runDaemon(ctx.Ctx, ctx.Program, tool, input)
-		} else if tool.IsOpenAPI() {
-			return e.runOpenAPI(tool, input)
-		} else if tool.IsEcho() {
-			return e.runEcho(tool)
-		}
-		s, err := e.runCommand(ctx, tool, input, ctx.ToolCategory)
+		ret, err := e.startCommand(ctx, tool, input)
 		if err != nil {
 			return nil, err
 		}
-		return &Return{
-			Result: &s,
-		}, nil
+		return e.fileOutput(ctx.Ctx, tool, ret)
 	}
 
 	if ctx.ToolCategory == CredentialToolCategory {
@@ -291,28 +336,64 @@ func (e *Engine) Start(ctx Context, input string) (ret *Return, _ error) {
 		Chat:                 tool.Parameters.Chat,
 		Temperature:          tool.Parameters.Temperature,
 		InternalSystemPrompt: tool.Parameters.InternalPrompt,
+		OutputRegex:          tool.Parameters.OutputRegex,
+		OutputGrammar:        tool.Parameters.OutputGrammar,
+		Choices:              tool.Parameters.Choices,
+		BestOf:               tool.Parameters.BestOf,
+		TopP:                 tool.Parameters.TopP,
+		Stop:                 tool.Parameters.Stop,
+		FrequencyPenalty:     tool.Parameters.FrequencyPenalty,
+		PresencePenalty:      tool.Parameters.PresencePenalty,
+		ParallelToolCalls:    tool.Parameters.ParallelToolCalls,
+		LogProbs:             tool.Parameters.LogProbs,
+		TopLogProbs:          tool.Parameters.TopLogProbs,
+	}
+
+	if completion.BestOf > 1 && completion.Temperature == nil {
+		// The completions would be about the same at the default temperature of 0.
+		temperature := bestOfTemperature
+		completion.Temperature = &temperature
+	}
+
+	if tool.ValidateSchema() {
+		completion.OutputSchema = json.RawMessage(strings.TrimSpace(tool.Validate))
 	}
 
 	if tool.Chat && completion.InternalSystemPrompt == nil {
 		completion.InternalSystemPrompt = new(bool)
 	}
 
-	var err error
 	completion.Tools, err = tool.GetCompletionTools(*ctx.Program, ctx.AgentGroup...)
 	if err != nil {
 		return nil, err
 	}
 
-	completion.Messages = addUpdateSystem(ctx, tool, completion.Messages)
+	completion.ToolChoice, err = toolChoice(tool, completion.Tools)
+	if err != nil {
+		return nil, err
+	}
+
+	completion.Messages = addUpdateSystem(ctx, tool, input, completion.Messages)
+	completion.Messages = addExamples(tool, completion.Messages)
+
+	images, input, err := e.imageInput(ctx, tool, input)
+	if err != nil {
+		return nil, err
+	}
 
 	if tool.Chat && input == "{}" {
 		input = ""
 	}
 
-	if input != "" {
+	var content []types.ContentPart
+	// Input that is referenced in the instructions is not also sent as a user message
+	if input != "" && (tool.Chat || !hasArgRefs(tool.Instructions)) {
+		content = types.Text(input)
+	}
+	if content = append(content, images...); len(content) > 0 {
 		completion.Messages = append(completion.Messages, types.CompletionMessage{
 			Role:    types.CompletionMessageRoleTypeUser,
-			Content: types.Text(input),
+			Content: content,
 		})
 	}
 
@@ -321,7 +402,30 @@ func (e *Engine) Start(ctx Context, input string) (ret *Return, _ error) {
 	})
 }
 
-func addUpdateSystem(ctx Context, tool types.Tool, msgs []types.CompletionMessage) []types.CompletionMessage {
+func (e *Engine) startCommand(ctx Context, tool types.Tool, input string) (*Return, error) {
+	if tool.IsHTTP() {
+		return e.runHTTP(ctx.Ctx, ctx.Program, tool, input)
+	} else if tool.IsDaemon() {
+		return e.runDaemon(ctx.Ctx, ctx.Program, tool, input)
+	} else if tool.IsOpenAPI() {
+		return e.runOpenAPI(tool, input)
+	} else if tool.IsGraphQL() {
+		return e.runGraphQL(ctx.Ctx, tool, input)
+	} else if tool.IsGRPC() {
+		return e.runGRPC(ctx.Ctx, tool, input)
+	} else if tool.IsEcho() {
+		return e.runEcho(tool)
+	}
+	s, err := e.runCommand(ctx, tool, input, ctx.ToolCategory)
+	if err != nil {
+		return nil, err
+	}
+	return &Return{
+		Result: &s,
+	}, nil
+}
+
+func addUpdateSystem(ctx Context, tool types.Tool, input string, msgs []types.CompletionMessage) []types.CompletionMessage {
 	var instructions []string
 
 	for _, context := range ctx.InputContext {
@@ -329,7 +433,7 @@ func addUpdateSystem(ctx Context, tool types.Tool, msgs []types.CompletionMessag
 	}
 
 	if tool.Instructions != "" {
-		instructions = append(instructions, tool.Instructions)
+		instructions = append(instructions, expandArgs(tool.Instructions, input))
 	}
 
 	if len(instructions) == 0 {
@@ -348,6 +452,23 @@ func addUpdateSystem(ctx Context, tool types.Tool, msgs []types.CompletionMessag
 	return append([]types.CompletionMessage{msg}, msgs...)
 }
 
+// addExamples adds the examples of the tool as user and assistant message pairs.
+func addExamples(tool types.Tool, msgs []types.CompletionMessage) []types.CompletionMessage {
+	for _, example := range tool.Examples {
+		if example.Output == "" {
+			continue
+		}
+		msgs = append(msgs, types.CompletionMessage{
+			Role:    types.CompletionMessageRoleTypeUser,
+			Content: types.Text(example.Input),
+		}, types.CompletionMessage{
+			Role:    types.CompletionMessageRoleTypeAssistant,
+			Content: types.Text(example.Output),
+		})
+	}
+	return msgs
+}
+
 func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
 	var (
 		progress = make(chan types.CompletionStatus)
@@ -372,20 +493,35 @@ func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
 		}
 	}()
 
-	resp, err := e.Model.Call(gcontext.WithEnv(ctx, e.Env), state.Completion, progress)
+	ctx = gcontext.WithEnv(ctx, e.Env)
+	resp, err := e.call(ctx, state.Completion, progress)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = e.correctToolCalls(ctx, state.Completion, resp, progress)
 	if err != nil {
 		return nil, err
 	}
 
+	candidates := resp.Candidates
+	resp.Candidates = nil
+	if resp.ContentFilter != nil && !resp.IsToolCall() {
+		// The calling tool gets the content filter result instead of a partial or empty response.
+		resp.Content = types.Text(resp.ContentFilter.String())
+	}
 	state.Completion.Messages = append(state.Completion.Messages, *resp)
 
 	state.Pending = map[string]types.CompletionToolCall{}
 	for _, content := range resp.Content {
 		if content.ToolCall != nil {
-			var toolID string
+			var (
+				toolID    string
+				overrides *types.ToolOverrides
+			)
 			for _, tool := range state.Completion.Tools {
 				if tool.Function.Name == content.ToolCall.Function.Name {
 					toolID = tool.Function.ToolID
+					overrides = tool.Function.Overrides
 				}
 			}
 			if toolID == "" {
@@ -393,8 +529,9 @@ func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
 			}
 			state.Pending[content.ToolCall.ID] = *content.ToolCall
 			ret.Calls[content.ToolCall.ID] = Call{
-				ToolID: toolID,
-				Input:  content.ToolCall.Function.Arguments,
+				ToolID:    toolID,
+				Input:     content.ToolCall.Function.Arguments,
+				Overrides: overrides,
 			}
 		} else {
 			cp := content.Text
@@ -408,14 +545,69 @@ func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
 		ret.Result = &empty
 	}
 
+	// Only responses that don't call tools can be chosen between, otherwise the first response is used.
+	if len(candidates) > 0 && len(ret.Calls) == 0 {
+		ret.Candidates = []string{*ret.Result}
+		for _, candidate := range candidates {
+			if candidate.IsToolCall() {
+				ret.Candidates = nil
+				break
+			}
+			ret.Candidates = append(ret.Candidates, candidate.ChatText())
+		}
+	}
+
 	return &ret, nil
 }
 
+// call calls the model, or for a request with BestOf set, calls it that many times in parallel and returns the first
+// response with the others as its candidates.
+func (e *Engine) call(ctx context.Context, request types.CompletionRequest, progress chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	if request.BestOf <= 1 {
+		return e.Model.Call(ctx, request, progress)
+	}
+
+	responses := make([]*types.CompletionMessage, request.BestOf)
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := range responses {
+		candidateRequest := request
+		candidateRequest.Candidate = i
+		eg.Go(func() (err error) {
+			if e.AcquireCandidate != nil {
+				release, err := e.AcquireCandidate(ctx)
+				if err != nil {
+					return err
+				}
+				defer release()
+			}
+			responses[i], err = e.Model.Call(ctx, candidateRequest, progress)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	resp := responses[0]
+	for _, candidate := range responses[1:] {
+		// The usage of each completion is reported in its own progress.
+		candidate.Usage = types.Usage{}
+		choices := candidate.Candidates
+		candidate.Candidates = nil
+		resp.Candidates = append(append(resp.Candidates, *candidate), choices...)
+	}
+	return resp, nil
+}
+
 func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Return, error) {
 	if state == nil {
 		return nil, fmt.Errorf("invalid continue call, missing state")
 	}
 
+	if ctx.Tool.IsAgents() {
+		return e.continueAgents(ctx, state, results)
+	}
+
 	var added bool
 
 	state = &State{
@@ -424,6 +616,8 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 		Pending:    state.Pending,
 		Results:    map[string]CallResult{},
 	}
+	// The tool choice only applies to the first response, otherwise the model could never stop calling tools.
+	state.Completion.ToolChoice = ""
 
 	for _, result := range results {
 		if result.CallID == "" {
@@ -445,8 +639,9 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 	for id, pending := range state.Pending {
 		if _, ok := state.Results[id]; !ok {
 			ret.Calls[id] = Call{
-				ToolID: state.Completion.Tools[*pending.Index].Function.ToolID,
-				Input:  pending.Function.Arguments,
+				ToolID:    state.Completion.Tools[*pending.Index].Function.ToolID,
+				Input:     pending.Function.Arguments,
+				Overrides: state.Completion.Tools[*pending.Index].Function.Overrides,
 			}
 		}
 	}
@@ -484,6 +679,6 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 		return nil, fmt.Errorf("invalid continue call, no completion needed")
 	}
 
-	state.Completion.Messages = addUpdateSystem(ctx, ctx.Tool, state.Completion.Messages)
+	state.Completion.Messages = addUpdateSystem(ctx, ctx.Tool, state.Input, state.Completion.Messages)
 	return e.complete(ctx.Ctx, state)
 }
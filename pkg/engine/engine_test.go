@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type candidateModel struct{}
+
+func (candidateModel) Call(_ context.Context, request types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	return &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text(fmt.Sprintf("candidate %d at %v", request.Candidate, *request.Temperature)),
+		Usage:   types.Usage{TotalTokens: 1},
+	}, nil
+}
+
+func TestBestOf(t *testing.T) {
+	var tool types.Tool
+	tool.Instructions = "Write a slogan"
+	tool.BestOf = 3
+
+	ctx := Context{
+		Ctx:     context.Background(),
+		Program: &types.Program{},
+	}
+	ctx.Tool = tool
+
+	ret, err := (&Engine{Model: candidateModel{}}).Start(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "candidate 0 at 0.7", *ret.Result)
+	require.Equal(t, []string{"candidate 0 at 0.7", "candidate 1 at 0.7", "candidate 2 at 0.7"}, ret.Candidates)
+	// Only the chosen response is kept in the conversation.
+	last := ret.State.Completion.Messages[len(ret.State.Completion.Messages)-1]
+	require.Nil(t, last.Candidates)
+
+	ret.Choose(2)
+	require.Equal(t, "candidate 2 at 0.7", *ret.Result)
+	require.Equal(t, "candidate 2 at 0.7", ret.State.Completion.Messages[len(ret.State.Completion.Messages)-1].ChatText())
+
+	// Each completion acquires a slot of its own.
+	var acquired, released atomic.Int32
+	_, err = (&Engine{Model: candidateModel{}, AcquireCandidate: func(context.Context) (func(), error) {
+		acquired.Add(1)
+		return func() { released.Add(1) }, nil
+	}}).Start(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), acquired.Load())
+	require.Equal(t, int32(3), released.Load())
+}
+
+func TestCommandSecrets(t *testing.T) {
+	var tool types.Tool
+	tool.Name = "clone"
+	tool.Instructions = "#!git clone https://${TOKEN}@github.com/${REPO}.git"
+	tool.CredentialArguments = map[string]string{"token": "github-token"}
+
+	// Parameters from credentials can only be read from the environment.
+	_, _, err := (&Engine{}).newCommand(context.Background(), nil, tool, `{"repo": "acme/app", "token": "secret"}`)
+	require.EqualError(t, err, "parameter token of tool clone is filled from a credential and can't be in its command line, read it from the environment variable TOKEN instead")
+
+	tool.Instructions = "#!/usr/bin/env bash\n\ngit clone \"https://${TOKEN}@github.com/${REPO}.git\""
+	cmd, stop, err := (&Engine{}).newCommand(context.Background(), nil, tool, `{"repo": "acme/app", "token": "secret"}`)
+	require.NoError(t, err)
+	defer stop()
+	require.Contains(t, cmd.Env, "TOKEN=secret")
+
+	// The other credentials of the tool are redacted from the command line in the events.
+	require.Equal(t, []string{"curl", "-H", "Authorization: Bearer <redacted>"}, redactArgs([]string{"curl", "-H", "Authorization: Bearer abc123"}, []string{"abc123"}))
+}
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// maxTransferSize is the largest file that is uploaded to or downloaded from a tool that doesn't share the workspace.
+const maxTransferSize = 100 * 1024 * 1024
+
+// fileUpload is how a file argument is sent to a tool that doesn't share the workspace, such as an HTTP tool.
+type fileUpload struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	// Content is the base64 encoded content of the file.
+	Content string `json:"content"`
+}
+
+func workspaceDir(env []string) string {
+	var dir string
+	for _, env := range env {
+		if v, ok := strings.CutPrefix(env, "GPTSCRIPT_WORKSPACE_DIR="); ok {
+			dir = v
+		}
+	}
+	return dir
+}
+
+// workspaceFile returns the file of a path that is relative to the workspace unless it is absolute.
+func workspaceFile(workspace, file string) string {
+	if filepath.IsAbs(file) || workspace == "" {
+		return file
+	}
+	return filepath.Join(workspace, filepath.FromSlash(file))
+}
+
+func detectMimeType(file string) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(file)); mimeType != "" {
+		return mimeType
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	data := make([]byte, 512)
+	n, _ := io.ReadFull(f, data)
+	return http.DetectContentType(data[:n])
+}
+
+// confineFile returns the file of a path that the model gave as an argument, which must be in the workspace or in the
+// working directory of the tool, so that the model can't make tools read any other file. Relative paths are relative
+// to the workspace.
+func (e *Engine) confineFile(ctx Context, file string) (string, error) {
+	dir, err := e.workDir(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var roots []string
+	for _, root := range []string{workspaceDir(e.Env), dir} {
+		if root == "" {
+			continue
+		}
+		if root, err = filepath.EvalSymlinks(root); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) == 0 {
+		return "", fmt.Errorf("there is no workspace or working directory for %s to be in", file)
+	}
+
+	for _, root := range roots {
+		if confined, err := ConfinePath(root, filepath.FromSlash(file)); err == nil {
+			return confined, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside of the workspace and the working directory of the tool", file)
+}
+
+// fileInput replaces the file arguments of the JSON input with the absolute paths of their files, or with their
+// uploads for tools that don't share the workspace. A file that is the whole input is the argument of a tool that
+// takes one file, so that the output of a tool can be passed on as is. The input is returned as is if the tool has no
+// file arguments.
+func (e *Engine) fileInput(ctx Context, tool types.Tool, input string) (string, error) {
+	if len(tool.FileArguments) == 0 {
+		return input, nil
+	}
+
+	if ref, ok := types.ParseFileRef(input); ok && len(tool.FileArguments) == 1 {
+		data, err := json.Marshal(map[string]any{tool.FileArguments[0]: ref})
+		if err != nil {
+			return "", err
+		}
+		input = string(data)
+	}
+
+	args := map[string]any{}
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		// Input that isn't JSON can't have file arguments.
+		return input, nil
+	}
+
+	for _, name := range tool.FileArguments {
+		file, err := fileArgument(args[name])
+		if err != nil {
+			return "", fmt.Errorf("invalid file argument %s of tool %s: %w", name, tool.Name, err)
+		} else if file == "" {
+			continue
+		}
+
+		file, err = e.confineFile(ctx, file)
+		if err != nil {
+			return "", fmt.Errorf("invalid file argument %s of tool %s: %w", name, tool.Name, err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("invalid file argument %s of tool %s: %w", name, tool.Name, err)
+		} else if !info.Mode().IsRegular() {
+			return "", fmt.Errorf("invalid file argument %s of tool %s: %s is not a file", name, tool.Name, file)
+		}
+
+		if !tool.IsHTTP() {
+			args[name] = file
+			continue
+		}
+
+		if info.Size() > maxTransferSize {
+			return "", fmt.Errorf("file argument %s of tool %s is larger than %d bytes", name, tool.Name, maxTransferSize)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		args[name] = fileUpload{
+			Name:     filepath.Base(file),
+			MimeType: detectMimeType(file),
+			Size:     info.Size(),
+			Content:  base64.StdEncoding.EncodeToString(data),
+		}
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fileArgument returns the path of the value of a file argument, which is a path or a file reference.
+func fileArgument(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if ref, ok := types.ParseFileRef(v); ok {
+			return ref.Path, nil
+		}
+		return v, nil
+	case map[string]any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		if ref, ok := types.ParseFileRef(string(data)); ok && ref.Path != "" {
+			return ref.Path, nil
+		}
+	}
+	return "", fmt.Errorf("must be a path or a file returned by a tool")
+}
+
+// fileOutput completes the file reference that the output of a tool is, if it is one, with the size and MIME type of
+// the file. The file is downloaded to the workspace when the tool serves it from a URL.
+func (e *Engine) fileOutput(ctx context.Context, tool types.Tool, ret *Return) (*Return, error) {
+	if ret == nil || ret.Result == nil {
+		return ret, nil
+	}
+	ref, ok := types.ParseFileRef(*ret.Result)
+	if !ok {
+		return ret, nil
+	}
+
+	workspace := workspaceDir(e.Env)
+	if ref.URL != "" {
+		file, err := downloadFile(ctx, workspace, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download the file %s returned by tool %s: %w", ref.URL, tool.Name, err)
+		}
+		ref.Path, ref.URL = file, ""
+	}
+
+	file := workspaceFile(workspace, ref.Path)
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file returned by tool %s: %w", tool.Name, err)
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("invalid file returned by tool %s: %s is not a file", tool.Name, file)
+	}
+
+	ref.Size = info.Size()
+	if ref.MimeType == "" {
+		ref.MimeType = detectMimeType(file)
+	}
+	if abs, err := filepath.Abs(file); err == nil && workspace != "" {
+		if rel, err := filepath.Rel(workspace, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			ref.Path = filepath.ToSlash(rel)
+		}
+	}
+
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return nil, err
+	}
+	result := string(data)
+	ret.Result = &result
+	return ret, nil
+}
+
+// downloadFile saves the file of the URL of the reference in the workspace, named after the path of the reference or
+// of the URL, and returns its name. A number is added to the name when the workspace already has a file of that name,
+// so that no file is overwritten.
+func downloadFile(ctx context.Context, workspace string, ref types.FileRef) (string, error) {
+	if workspace == "" {
+		return "", fmt.Errorf("there is no workspace to download it to")
+	}
+
+	name := path.Base(ref.Path)
+	var body io.Reader
+	if data, ok := strings.CutPrefix(ref.URL, "data:"); ok {
+		meta, content, ok := strings.Cut(data, ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return "", fmt.Errorf("only base64 data URLs are supported")
+		}
+		body = base64.NewDecoder(base64.StdEncoding, strings.NewReader(content))
+	} else {
+		u, err := url.Parse(ref.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", fmt.Errorf("unsupported URL")
+		}
+		if ref.Path == "" {
+			name = path.Base(u.Path)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.URL, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := proxy.Client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode > 299 {
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "file"
+	}
+
+	f, name, err := createUnique(workspace, name)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, maxTransferSize+1))
+	if err == nil && n > maxTransferSize {
+		err = fmt.Errorf("the file is larger than %d bytes", maxTransferSize)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return name, nil
+}
+
+// createUnique creates a new file of the name in the directory, or of the name with a number added to it if the
+// directory already has a file of that name, and returns it with its name.
+func createUnique(dir, name string) (*os.File, string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < 1000; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return f, candidate, nil
+	}
+	return nil, "", fmt.Errorf("the workspace has too many files named like %s", name)
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileInput(t *testing.T) {
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "report.csv"), []byte("a,b\n1,2\n"), 0644))
+	e := &Engine{Env: []string{"GPTSCRIPT_WORKSPACE_DIR=" + workspace}}
+
+	var tool types.Tool
+	tool.Name = "summarize"
+	tool.FileArguments = []string{"report"}
+
+	// Paths are relative to the workspace, and file references are passed as their paths.
+	var out string
+	for _, input := range []string{
+		`{"report": "report.csv", "question": "what is the total?"}`,
+		`{"report": {"type": "file", "path": "report.csv"}, "question": "what is the total?"}`,
+		`{"report": "{\"type\": \"file\", \"path\": \"report.csv\"}", "question": "what is the total?"}`,
+	} {
+		out, err = e.fileInput(Context{}, tool, input)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"report": "`+filepath.Join(workspace, "report.csv")+`", "question": "what is the total?"}`, out)
+	}
+
+	// A file that is the whole input is the only file argument.
+	out, err = e.fileInput(Context{}, tool, `{"type": "file", "path": "report.csv", "mimeType": "text/csv"}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"report": "`+filepath.Join(workspace, "report.csv")+`"}`, out)
+
+	_, err = e.fileInput(Context{}, tool, `{"report": "missing.csv"}`)
+	require.ErrorContains(t, err, "invalid file argument report of tool summarize")
+
+	// Files outside of the workspace can't be passed to tools.
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0644))
+	for _, file := range []string{secret, "../" + filepath.Base(filepath.Dir(secret)) + "/secret.txt"} {
+		_, err = e.fileInput(Context{}, tool, `{"report": "`+file+`"}`)
+		require.ErrorContains(t, err, "is outside of the workspace")
+	}
+
+	// HTTP tools don't share the workspace, so they get the content of the file.
+	tool.Instructions = "#!http://localhost:8080/summarize"
+	out, err = e.fileInput(Context{}, tool, `{"report": "report.csv"}`)
+	require.NoError(t, err)
+	var args map[string]fileUpload
+	require.NoError(t, json.Unmarshal([]byte(out), &args))
+	require.Equal(t, "report.csv", args["report"].Name)
+	require.Contains(t, args["report"].MimeType, "text/csv")
+	require.Equal(t, int64(8), args["report"].Size)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")), args["report"].Content)
+
+	// Tools without file arguments get their input as is.
+	out, err = e.fileInput(Context{}, types.Tool{}, `{"report": "missing.csv"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"report": "missing.csv"}`, out)
+}
+
+func TestFileOutput(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "chart.png"), []byte("\x89PNG\r\n\x1a\n"), 0644))
+	e := &Engine{Env: []string{"GPTSCRIPT_WORKSPACE_DIR=" + workspace}}
+	tool := types.Tool{ToolDef: types.ToolDef{Parameters: types.Parameters{Name: "plot"}}}
+
+	result := `{"type": "file", "path": "chart.png"}`
+	ret, err := e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "file", "path": "chart.png", "mimeType": "image/png", "size": 8}`, *ret.Result)
+
+	// Files that are served by a tool are downloaded to the workspace.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("%PDF-1.7\n"))
+	}))
+	defer s.Close()
+
+	result = `{"type": "file", "url": "` + s.URL + `/files/1234/summary.pdf"}`
+	ret, err = e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "file", "path": "summary.pdf", "mimeType": "application/pdf", "size": 9}`, *ret.Result)
+	data, err := os.ReadFile(filepath.Join(workspace, "summary.pdf"))
+	require.NoError(t, err)
+	require.Equal(t, "%PDF-1.7\n", string(data))
+
+	// Files that are downloaded again don't overwrite the files in the workspace.
+	result = `{"type": "file", "url": "` + s.URL + `/files/1234/summary.pdf"}`
+	ret, err = e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "file", "path": "summary-1.pdf", "mimeType": "application/pdf", "size": 9}`, *ret.Result)
+
+	result = `{"type": "file", "path": "logo.png", "url": "data:image/png;base64,iVBORw0KGgo="}`
+	ret, err = e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "file", "path": "logo.png", "mimeType": "image/png", "size": 8}`, *ret.Result)
+
+	result = `{"type": "file", "path": "missing.png"}`
+	_, err = e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.ErrorContains(t, err, "invalid file returned by tool plot")
+
+	// Other output is returned as is.
+	result = `{"type": "file", "path": "chart.png", "caption": "sales"}`
+	ret, err = e.fileOutput(context.Background(), tool, &Return{Result: &result})
+	require.NoError(t, err)
+	require.Equal(t, `{"type": "file", "path": "chart.png", "caption": "sales"}`, *ret.Result)
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type GraphQLInstructions struct {
+	Endpoint string `json:"endpoint"`
+	Query    string `json:"query"`
+}
+
+// runGraphQL runs a tool that was generated from a GraphQL schema.
+// The tools Instructions field will be in the format "#!sys.graphql '{Instructions JSON}'",
+// where {Instructions JSON} is a JSON string of type GraphQLInstructions. The input of the tool is sent as the
+// variables of the query.
+func (e *Engine) runGraphQL(ctx context.Context, tool types.Tool, input string) (*Return, error) {
+	var instructions GraphQLInstructions
+	_, inst, _ := strings.Cut(tool.Instructions, types.GraphQLPrefix+" ")
+	inst = strings.TrimPrefix(inst, "'")
+	inst = strings.TrimSuffix(inst, "'")
+	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	}
+
+	u, err := url.Parse(instructions.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint URL %s: %w", instructions.Endpoint, err)
+	}
+
+	variables := json.RawMessage("{}")
+	if strings.TrimSpace(input) != "" {
+		if !json.Valid([]byte(input)) {
+			return nil, fmt.Errorf("invalid input, must be a JSON object of the variables of the query: %s", input)
+		}
+		variables = json.RawMessage(input)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"query":     instructions.Query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	// Use the bearer token for the server, as with OpenAPI tools, only if using HTTPS or localhost.
+	if u.Scheme == "https" || u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" {
+		for _, v := range e.Env {
+			if token, ok := strings.CutPrefix(v, "GPTSCRIPT_"+env.ToEnvLike(u.Hostname())+"_BEARER_TOKEN="); ok {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+	}
+
+	resp, err := proxy.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resultStr := string(result)
+
+	return &Return{
+		Result: &resultStr,
+	}, nil
+}
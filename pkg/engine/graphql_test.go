@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGraphQL(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.Equal(t, "query pet($id: ID!) { pet(id: $id) { name } }", req.Query)
+		_, _ = fmt.Fprintf(w, `{"data":{"pet":{"name":%q}}}`, req.Variables["id"])
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	// The bearer token is only sent to HTTPS servers and localhost.
+	endpoint := "http://localhost:" + u.Port() + "/graphql"
+
+	inst, err := json.Marshal(GraphQLInstructions{
+		Endpoint: endpoint,
+		Query:    "query pet($id: ID!) { pet(id: $id) { name } }",
+	})
+	require.NoError(t, err)
+
+	e := &Engine{
+		Env: []string{"GPTSCRIPT_" + env.ToEnvLike("localhost") + "_BEARER_TOKEN=secret"},
+	}
+	ret, err := e.runGraphQL(context.Background(), types.Tool{
+		ToolDef: types.ToolDef{
+			Instructions: fmt.Sprintf("%s '%s'", types.GraphQLPrefix, inst),
+		},
+	}, `{"id": "rex"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"data":{"pet":{"name":"rex"}}}`, *ret.Result)
+
+	_, err = e.runGraphQL(context.Background(), types.Tool{
+		ToolDef: types.ToolDef{
+			Instructions: fmt.Sprintf("%s '%s'", types.GraphQLPrefix, inst),
+		},
+	}, `not json`)
+	require.ErrorContains(t, err, "invalid input")
+}
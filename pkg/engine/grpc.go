@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/grpc"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type GRPCInstructions struct {
+	Target          string       `json:"target"`
+	TLS             bool         `json:"tls,omitempty"`
+	Method          string       `json:"method"`
+	Input           string       `json:"input"`
+	Output          string       `json:"output"`
+	ServerStreaming bool         `json:"serverStreaming,omitempty"`
+	Schema          *grpc.Schema `json:"schema"`
+}
+
+// runGRPC runs a tool that was generated from a method of a gRPC service.
+// The tools Instructions field will be in the format "#!sys.grpc '{Instructions JSON}'",
+// where {Instructions JSON} is a JSON string of type GRPCInstructions. The input of the tool is the JSON of the
+// request message, and the result is the JSON of the response message, or an array of them for server streaming
+// methods.
+func (e *Engine) runGRPC(ctx context.Context, tool types.Tool, input string) (*Return, error) {
+	var instructions GRPCInstructions
+	_, inst, _ := strings.Cut(tool.Instructions, types.GRPCPrefix+" ")
+	inst = strings.TrimPrefix(inst, "'")
+	inst = strings.TrimSuffix(inst, "'")
+	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	}
+	if instructions.Schema == nil {
+		return nil, fmt.Errorf("no schema found in tool instructions")
+	}
+
+	var request any = map[string]any{}
+	if strings.TrimSpace(input) != "" {
+		dec := json.NewDecoder(strings.NewReader(input))
+		// Keep the precision of 64-bit integers.
+		dec.UseNumber()
+		if err := dec.Decode(&request); err != nil {
+			return nil, fmt.Errorf("invalid input, must be a JSON object of the fields of %s: %w", instructions.Input, err)
+		}
+	}
+
+	requestBytes, err := instructions.Schema.Marshal(instructions.Input, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	header := http.Header{}
+	// Use the bearer token for the server, as with OpenAPI tools, only if using TLS or localhost.
+	host, _, err := net.SplitHostPort(instructions.Target)
+	if err != nil {
+		host = instructions.Target
+	}
+	if instructions.TLS || host == "localhost" || host == "127.0.0.1" {
+		for _, v := range e.Env {
+			if token, ok := strings.CutPrefix(v, "GPTSCRIPT_"+env.ToEnvLike(host)+"_BEARER_TOKEN="); ok {
+				header.Set("Authorization", "Bearer "+token)
+			}
+		}
+	}
+
+	responses, err := grpc.NewClient(instructions.Target, instructions.TLS, header).Call(ctx, instructions.Method, requestBytes)
+	if statusErr := (*grpc.StatusError)(nil); errors.As(err, &statusErr) {
+		// Errors from the service are the result of the call, as error responses are for OpenAPI tools.
+		result := statusErr.Error()
+		return &Return{
+			Result: &result,
+		}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", instructions.Method, err)
+	}
+
+	var messages []any
+	for _, response := range responses {
+		message, err := instructions.Schema.Unmarshal(instructions.Output, response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	var result any = messages
+	if !instructions.ServerStreaming {
+		if len(messages) != 1 {
+			return nil, fmt.Errorf("expected one response from %s, got %d", instructions.Method, len(messages))
+		}
+		result = messages[0]
+	} else if messages == nil {
+		result = []any{}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(result); err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	resultStr := strings.TrimSpace(buf.String())
+
+	return &Return{
+		Result: &resultStr,
+	}, nil
+}
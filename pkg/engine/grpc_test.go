@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/grpc"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestRunGRPC(t *testing.T) {
+	s := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/pets.Pets/GetPet", r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		// The request is {"id": "7"}, which is field 1 with the varint 7.
+		if string(body[5:]) != "\x08\x07" {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "pet not found")
+			return
+		}
+
+		// The response is {"name": "rex"}.
+		response := []byte("\x0a\x03rex")
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(response)))
+		_, _ = w.Write(prefix[:])
+		_, _ = w.Write(response)
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	inst, err := json.Marshal(GRPCInstructions{
+		// The bearer token is only sent with TLS or to localhost.
+		Target: "localhost:" + u.Port(),
+		Method: "/pets.Pets/GetPet",
+		Input:  "pets.GetPetRequest",
+		Output: "pets.Pet",
+		Schema: &grpc.Schema{
+			Messages: map[string]*grpc.Message{
+				"pets.GetPetRequest": {Fields: []grpc.Field{{Name: "id", JSONName: "id", Number: 1, Type: grpc.TypeInt64}}},
+				"pets.Pet":           {Fields: []grpc.Field{{Name: "name", JSONName: "name", Number: 1, Type: grpc.TypeString}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	e := &Engine{
+		Env: []string{"GPTSCRIPT_" + env.ToEnvLike("localhost") + "_BEARER_TOKEN=secret"},
+	}
+	tool := types.Tool{
+		ToolDef: types.ToolDef{
+			Instructions: fmt.Sprintf("%s '%s'", types.GRPCPrefix, inst),
+		},
+	}
+
+	ret, err := e.runGRPC(context.Background(), tool, `{"id": "7"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"rex"}`, *ret.Result)
+
+	ret, err = e.runGRPC(context.Background(), tool, `{"id": 8}`)
+	require.NoError(t, err)
+	require.Equal(t, "rpc error: code = 5 desc = pet not found", *ret.Result)
+
+	_, err = e.runGRPC(context.Background(), tool, `{"id": "seven"}`)
+	require.ErrorContains(t, err, "must be a 64-bit integer")
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
@@ -43,7 +44,7 @@ func (e *Engine) runHTTP(ctx context.Context, prg *types.Program, tool types.Too
 		if !ok {
 			return nil, fmt.Errorf("failed to find tool [%s] for [%s]", referencedToolName, parsed.Hostname())
 		}
-		toolURL, err = e.startDaemon(referencedTool)
+		toolURL, err = e.startDaemon(prg, referencedTool, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -67,6 +68,7 @@ func (e *Engine) runHTTP(ctx context.Context, prg *types.Program, tool types.Too
 	}
 
 	req.Header.Set("X-GPTScript-Tool-Name", tool.Parameters.Name)
+	setTraceHeaders(ctx, req.Header)
 
 	if err := json.Unmarshal([]byte(input), &map[string]any{}); err == nil {
 		req.Header.Set("Content-Type", "application/json")
@@ -74,7 +76,7 @@ func (e *Engine) runHTTP(ctx context.Context, prg *types.Program, tool types.Too
 		req.Header.Set("Content-Type", "text/plain")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := proxy.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// maxImageSize is the largest image file that is sent to the LLM, which is the limit of the OpenAI API.
+const maxImageSize = 20 * 1024 * 1024
+
+// imageInput takes the image arguments of the tool out of the JSON input and returns them as image content parts,
+// along with the input of the remaining arguments. The input is returned as is if the tool has no image arguments.
+func (e *Engine) imageInput(ctx Context, tool types.Tool, input string) ([]types.ContentPart, string, error) {
+	if len(tool.ImageArguments) == 0 {
+		return nil, input, nil
+	}
+
+	args := map[string]any{}
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		// Input that isn't JSON can't have image arguments.
+		return nil, input, nil
+	}
+
+	var parts []types.ContentPart
+	for _, name := range tool.ImageArguments {
+		value, _ := args[name].(string)
+		delete(args, name)
+		if value == "" {
+			continue
+		}
+		image, err := e.loadImage(ctx, value)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid image argument %s of tool %s: %w", name, tool.Name, err)
+		}
+		parts = append(parts, types.ContentPart{
+			Image: image,
+		})
+	}
+
+	if len(args) == 0 {
+		return parts, "", nil
+	}
+	rest, err := json.Marshal(args)
+	if err != nil {
+		return nil, "", err
+	}
+	return parts, string(rest), nil
+}
+
+// loadImage returns the image of an http(s) or data URL as is, and reads any other value as the path of an image file,
+// which must be in the workspace or the working directory of the tool like the files of file arguments. The content
+// of the file must be an image, whatever its extension is.
+func (e *Engine) loadImage(ctx Context, value string) (*types.ImageContent, error) {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "data:image/") {
+		return &types.ImageContent{
+			URL: value,
+		}, nil
+	}
+
+	file, err := e.confineFile(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", value)
+	} else if info.Size() > maxImageSize {
+		return nil, fmt.Errorf("image %s is larger than %d bytes", value, maxImageSize)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	mimeType := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("%s is not an image", value)
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(f, maxImageSize-int64(n)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ImageContent{
+		Data:     append(head[:n], rest...),
+		MIMEType: mimeType,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageInput(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	file := filepath.Join(workspace, "screenshot.png")
+	require.NoError(t, os.WriteFile(file, png, 0644))
+	e := &Engine{Env: []string{"GPTSCRIPT_WORKSPACE_DIR=" + workspace}}
+
+	var tool types.Tool
+	tool.ImageArguments = []string{"screenshot", "logo"}
+
+	parts, rest, err := e.imageInput(Context{}, tool, `{"screenshot": "`+file+`", "logo": "https://example.com/logo.png", "question": "what is wrong?", "count": 10000000000000001}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"count":10000000000000001,"question":"what is wrong?"}`, rest)
+	require.Equal(t, []types.ContentPart{
+		{Image: &types.ImageContent{Data: png, MIMEType: "image/png"}},
+		{Image: &types.ImageContent{URL: "https://example.com/logo.png"}},
+	}, parts)
+	require.Equal(t, "data:image/png;base64,iVBORw0KGgoAAAANSUhEUg==", parts[0].Image.DataURL())
+
+	// Optional image arguments are skipped and no input is left without other arguments.
+	parts, rest, err = e.imageInput(Context{}, tool, `{"logo": "data:image/gif;base64,R0lGOD"}`)
+	require.NoError(t, err)
+	require.Equal(t, "", rest)
+	require.Equal(t, []types.ContentPart{{Image: &types.ImageContent{URL: "data:image/gif;base64,R0lGOD"}}}, parts)
+
+	// The content of the file must be an image, whatever its extension is.
+	for _, name := range []string{"notes.txt", "notes.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte("not an image"), 0644))
+		_, _, err = e.imageInput(Context{}, tool, `{"screenshot": "`+name+`"}`)
+		require.ErrorContains(t, err, "is not an image")
+	}
+
+	// Images outside of the workspace can't be sent to the LLM.
+	outside := filepath.Join(t.TempDir(), "secret.png")
+	require.NoError(t, os.WriteFile(outside, png, 0644))
+	_, _, err = e.imageInput(Context{}, tool, `{"screenshot": "`+outside+`"}`)
+	require.ErrorContains(t, err, "is outside of the workspace")
+
+	// Tools without image arguments get their input as is.
+	parts, rest, err = e.imageInput(Context{}, types.Tool{}, `{"screenshot": "`+file+`"}`)
+	require.NoError(t, err)
+	require.Nil(t, parts)
+	require.Equal(t, `{"screenshot": "`+file+`"}`, rest)
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/tidwall/gjson"
 	"golang.org/x/exp/maps"
@@ -18,7 +19,7 @@ import (
 
 var (
 	SupportedMIMETypes     = []string{"application/json", "text/plain", "multipart/form-data"}
-	SupportedSecurityTypes = []string{"apiKey", "http"}
+	SupportedSecurityTypes = []string{"apiKey", "http", "oauth2", "openIdConnect"}
 )
 
 type Parameter struct {
@@ -30,7 +31,7 @@ type Parameter struct {
 // A SecurityInfo represents a security scheme in OpenAPI.
 type SecurityInfo struct {
 	Name       string `json:"name"`       // name as defined in the security schemes
-	Type       string `json:"type"`       // http or apiKey
+	Type       string `json:"type"`       // http, apiKey, oauth2, or openIdConnect
 	Scheme     string `json:"scheme"`     // bearer or basic, for type==http
 	APIKeyName string `json:"apiKeyName"` // name of the API key, for type==apiKey
 	In         string `json:"in"`         // header, query, or cookie, for type==apiKey
@@ -45,6 +46,8 @@ func (i SecurityInfo) GetCredentialToolStrings(hostname string) []string {
 		switch i.Type {
 		case "apiKey":
 			field = i.APIKeyName
+		case "oauth2", "openIdConnect":
+			field = "access token"
 		case "http":
 			if i.Scheme == "bearer" {
 				field = "bearer token"
@@ -200,7 +203,7 @@ func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
 	}
 
 	// Make the request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := proxy.Client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -265,6 +268,9 @@ func handleAuths(req *http.Request, envMap map[string]string, infoSets [][]Secur
 				case "basic":
 					req.SetBasicAuth(envMap[envNames[0]], envMap[envNames[1]])
 				}
+			case "oauth2", "openIdConnect":
+				// The flow to get an access token is left to the credential tool, and the token is sent as a bearer token.
+				req.Header.Set("Authorization", "Bearer "+envMap[envNames[0]])
 			}
 		}
 		return nil
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// summaryChunkTokens is the most tokens of output that are summarized in one request. Larger outputs are split into
+// chunks that are summarized on their own, and then the summaries of the chunks are summarized together.
+var summaryChunkTokens = 32000
+
+// maxSummaryRounds limits how many times the summaries of chunks are summarized again.
+const maxSummaryRounds = 3
+
+// CountTokens estimates the number of tokens of text the same way that requests to models are estimated.
+func CountTokens(text string) int {
+	return len(text) / 3
+}
+
+// Summarize asks the model to summarize the output of a tool in about the given number of tokens. It returns the
+// summary and the number of chunks the output was split into. The chunks are summarized at the same time, each
+// within a slot from acquire, which returns the function that releases the slot. A nil acquire doesn't limit them.
+func (e *Engine) Summarize(ctx context.Context, model, toolName, output string, tokens int, acquire func() (func(), error)) (string, int, error) {
+	var (
+		progress = make(chan types.CompletionStatus)
+		wg       sync.WaitGroup
+	)
+
+	wg.Add(1)
+	defer wg.Wait()
+	defer close(progress)
+
+	go func() {
+		defer wg.Done()
+		for message := range progress {
+			if e.Progress != nil {
+				e.Progress <- message
+			}
+		}
+	}()
+
+	ctx = gcontext.WithEnv(ctx, e.Env)
+	chunks := splitChunks(output, summaryChunkTokens*3)
+	count := len(chunks)
+
+	for round := 0; ; round++ {
+		summaries := make([]string, len(chunks))
+		eg, ctx := errgroup.WithContext(ctx)
+		for i, chunk := range chunks {
+			eg.Go(func() (err error) {
+				if acquire != nil {
+					release, err := acquire()
+					if err != nil {
+						return err
+					}
+					defer release()
+				}
+				summaries[i], err = e.summarize(ctx, model, toolName, chunk, tokens, len(chunks) > 1, progress)
+				return err
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return "", 0, err
+		}
+
+		summary := strings.Join(summaries, "\n\n")
+		if len(chunks) == 1 || round >= maxSummaryRounds {
+			return summary, count, nil
+		}
+		// The summaries of the chunks are summarized together, in chunks again if there are too many of them.
+		chunks = splitChunks(summary, summaryChunkTokens*3)
+	}
+}
+
+func (e *Engine) summarize(ctx context.Context, model, toolName, text string, tokens int, partial bool, progress chan<- types.CompletionStatus) (string, error) {
+	part := "the output"
+	if partial {
+		part = "a part of the output"
+	}
+
+	resp, err := e.Model.Call(ctx, types.CompletionRequest{
+		Model:     model,
+		MaxTokens: tokens,
+		Messages: []types.CompletionMessage{
+			{
+				Role: types.CompletionMessageRoleTypeSystem,
+				Content: types.Text(fmt.Sprintf("Summarize %s of the tool %s below in at most %d tokens. "+
+					"Keep the facts, names, identifiers, numbers, and errors that are needed to use the output, and leave out "+
+					"repetition and formatting. Respond with only the summary.", part, toolName, tokens)),
+			},
+			{
+				Role:    types.CompletionMessageRoleTypeUser,
+				Content: types.Text(text),
+			},
+		},
+	}, progress)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize the output of tool %s: %w", toolName, err)
+	}
+	return resp.ChatText(), nil
+}
+
+// splitChunks splits text into chunks of at most size bytes, at the end of a line when there is one in the chunk.
+func splitChunks(text string, size int) (chunks []string) {
+	for len(text) > size {
+		end := size
+		if i := strings.LastIndexByte(text[:size], '\n'); i > 0 {
+			end = i + 1
+		} else {
+			for end > 1 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+		chunks = append(chunks, text[:end])
+		text = text[end:]
+	}
+	return append(chunks, text)
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type summaryModel struct {
+	lock   sync.Mutex
+	inputs []string
+}
+
+func (m *summaryModel) Call(_ context.Context, request types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	input := request.Messages[1].ChatText()
+	m.lock.Lock()
+	m.inputs = append(m.inputs, input)
+	m.lock.Unlock()
+	return &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text(fmt.Sprintf("%d lines", strings.Count(input, "\n"))),
+	}, nil
+}
+
+func TestSummarize(t *testing.T) {
+	defer func(tokens int) { summaryChunkTokens = tokens }(summaryChunkTokens)
+	summaryChunkTokens = 10
+
+	model := &summaryModel{}
+	e := &Engine{Model: model}
+
+	summary, chunks, err := e.Summarize(context.Background(), "gpt-4o", "search", "line 1\nline 2\n", 5, nil)
+	require.NoError(t, err)
+	require.Equal(t, "2 lines", summary)
+	require.Equal(t, 1, chunks)
+
+	// Outputs over a chunk are split at the end of lines, and the summaries of the chunks are summarized together.
+	model.inputs = nil
+	// Each chunk is summarized within a slot of its own.
+	var (
+		slot     = make(chan struct{}, 1)
+		acquired atomic.Int32
+	)
+	acquire := func() (func(), error) {
+		slot <- struct{}{}
+		acquired.Add(1)
+		return func() { <-slot }, nil
+	}
+	summary, chunks, err = e.Summarize(context.Background(), "gpt-4o", "search", strings.Repeat("0123456789\n", 5), 5, acquire)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), acquired.Load())
+	require.Equal(t, 3, chunks)
+	require.Len(t, model.inputs, 4)
+	require.Equal(t, "2 lines\n\n2 lines\n\n1 lines", model.inputs[3])
+	require.Equal(t, "4 lines", summary)
+}
+
+func TestSplitChunks(t *testing.T) {
+	require.Equal(t, []string{"ab\n", "cd\n", "ef"}, splitChunks("ab\ncd\nef", 4))
+	// Lines longer than a chunk are split between runes.
+	require.Equal(t, []string{"aé", "éb"}, splitChunks("aééb", 4))
+}
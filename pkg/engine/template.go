@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// argRefRegexp matches ${args.<name>} references in tool instructions. A leading $ escapes the reference.
+var argRefRegexp = regexp.MustCompile(`\$?\$\{args\.([A-Za-z0-9_-]+)}`)
+
+// hasArgRefs returns true if the instructions contain at least one unescaped ${args.<name>} reference.
+func hasArgRefs(instructions string) bool {
+	for _, ref := range argRefRegexp.FindAllString(instructions, -1) {
+		if !strings.HasPrefix(ref, "$$") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandArgs replaces ${args.<name>} references in the instructions with the value of the named argument in the
+// JSON input. References to missing arguments are replaced with an empty string and $${args.<name>} is replaced
+// with the literal ${args.<name>}.
+func expandArgs(instructions, input string) string {
+	if !strings.Contains(instructions, "${args.") {
+		return instructions
+	}
+
+	args := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(input)))
+	dec.UseNumber()
+	// Invalid JSON is treated as no arguments
+	_ = dec.Decode(&args)
+
+	return argRefRegexp.ReplaceAllStringFunc(instructions, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		name := argRefRegexp.FindStringSubmatch(ref)[1]
+		switch val := args[name].(type) {
+		case nil:
+			return ""
+		case string:
+			return val
+		case json.Number:
+			return string(val)
+		case bool:
+			return fmt.Sprint(val)
+		default:
+			data, err := json.Marshal(val)
+			if err != nil {
+				return ""
+			}
+			return string(data)
+		}
+	})
+}
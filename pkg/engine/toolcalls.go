@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// maxToolCallCorrections is how many times the model is asked to correct the arguments of its tool calls before they
+// are passed to the tools as they are.
+const maxToolCallCorrections = 2
+
+// invalidToolCalls returns the reason the arguments of each tool call of the response can't be passed to its tool, by
+// the ID of the call. Tools with parameters take a JSON object, but arguments that don't start like JSON are passed
+// to the tool as plain text, as they always have been, so only JSON that is still broken after the client repaired
+// it is invalid.
+func invalidToolCalls(tools []types.CompletionTool, resp *types.CompletionMessage) map[string]string {
+	invalid := map[string]string{}
+	for _, content := range resp.Content {
+		if content.ToolCall == nil {
+			continue
+		}
+		if args := strings.TrimSpace(content.ToolCall.Function.Arguments); !strings.HasPrefix(args, "{") && !strings.HasPrefix(args, "[") {
+			continue
+		}
+		for _, tool := range tools {
+			if tool.Function.Name != content.ToolCall.Function.Name || tool.Function.Parameters == nil {
+				continue
+			}
+			var args map[string]any
+			if err := json.Unmarshal([]byte(content.ToolCall.Function.Arguments), &args); err != nil {
+				invalid[content.ToolCall.ID] = err.Error()
+			}
+			break
+		}
+	}
+	return invalid
+}
+
+// correctToolCalls asks the model to call its tools again when the arguments of its calls are not valid, by sending
+// the request with the response and an error for each call. The exchange is not added to the chat, only the
+// corrected response is used.
+func (e *Engine) correctToolCalls(ctx context.Context, request types.CompletionRequest, resp *types.CompletionMessage, progress chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	for i := 0; i < maxToolCallCorrections; i++ {
+		invalid := invalidToolCalls(request.Tools, resp)
+		if len(invalid) == 0 {
+			break
+		}
+
+		messages := append(append([]types.CompletionMessage{}, request.Messages...), *resp)
+		for _, content := range resp.Content {
+			if content.ToolCall == nil {
+				continue
+			}
+			result := "This call was not made, because other calls in the same response had invalid arguments. Make it again if it is still needed."
+			if reason, ok := invalid[content.ToolCall.ID]; ok {
+				log.WithContext(ctx).Debugf("Asking the model to correct the invalid arguments of the call to %s: %s", content.ToolCall.Function.Name, reason)
+				result = fmt.Sprintf("ERROR: The arguments are not a valid JSON object: %s. Call the tool again with valid JSON arguments.", reason)
+			}
+			messages = append(messages, types.CompletionMessage{
+				Role:     types.CompletionMessageRoleTypeTool,
+				Content:  types.Text(result),
+				ToolCall: content.ToolCall,
+			})
+		}
+
+		retry := request
+		retry.Messages = messages
+		corrected, err := e.Model.Call(ctx, retry, progress)
+		if err != nil {
+			return nil, err
+		}
+		resp = corrected
+	}
+	return resp, nil
+}
+
+// toolChoice returns the tool choice of the first request of the tool. A tool that it names is referenced by the name
+// in its Tools, which is given to the model as the name of one of the functions.
+func toolChoice(tool types.Tool, tools []types.CompletionTool) (string, error) {
+	switch choice := tool.Parameters.ToolChoice; choice {
+	case "", types.ToolChoiceAuto, types.ToolChoiceNone, types.ToolChoiceRequired:
+		return choice, nil
+	default:
+		for _, ref := range tool.ToolMapping[choice] {
+			for _, completionTool := range tools {
+				if completionTool.Function.ToolID == ref.ToolID {
+					return completionTool.Function.Name, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("invalid tool choice %q of tool %s, must be auto, none, required, or the name of one of its tools", choice, tool.Name)
+	}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type correctingModel struct {
+	requests []types.CompletionRequest
+}
+
+func (m *correctingModel) Call(_ context.Context, request types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	m.requests = append(m.requests, request)
+	return toolCallMessage(`{"query": "weather"}`), nil
+}
+
+func toolCallMessage(args string) *types.CompletionMessage {
+	return &types.CompletionMessage{
+		Role: types.CompletionMessageRoleTypeAssistant,
+		Content: []types.ContentPart{{ToolCall: &types.CompletionToolCall{
+			ID:       "call_1",
+			Function: types.CompletionFunctionCall{Name: "search", Arguments: args},
+		}}},
+	}
+}
+
+func TestCorrectToolCalls(t *testing.T) {
+	model := &correctingModel{}
+	e := &Engine{Model: model}
+	request := types.CompletionRequest{
+		Tools: []types.CompletionTool{{Function: types.CompletionFunctionDefinition{
+			Name:       "search",
+			Parameters: &openapi3.Schema{},
+		}}},
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("weather?")}},
+	}
+
+	resp, err := e.correctToolCalls(context.Background(), request, toolCallMessage(`{"query": "weather"}`), nil)
+	require.NoError(t, err)
+	require.Equal(t, `{"query": "weather"}`, resp.Content[0].ToolCall.Function.Arguments)
+	require.Empty(t, model.requests)
+
+	resp, err = e.correctToolCalls(context.Background(), request, toolCallMessage(`{"query": weather}`), nil)
+	require.NoError(t, err)
+	require.Equal(t, `{"query": "weather"}`, resp.Content[0].ToolCall.Function.Arguments)
+	require.Len(t, model.requests, 1)
+
+	// The model is sent its invalid call with the error, which is not added to the chat.
+	messages := model.requests[0].Messages
+	require.Len(t, messages, 3)
+	require.Equal(t, types.CompletionMessageRoleTypeTool, messages[2].Role)
+	require.Contains(t, messages[2].ChatText(), "not a valid JSON object")
+	require.Len(t, request.Messages, 1)
+
+	// Arguments that are not JSON are passed to the tool as plain text.
+	resp, err = e.correctToolCalls(context.Background(), request, toolCallMessage(`weather`), nil)
+	require.NoError(t, err)
+	require.Equal(t, `weather`, resp.Content[0].ToolCall.Function.Arguments)
+	require.Len(t, model.requests, 1)
+}
+
+func TestToolChoice(t *testing.T) {
+	tool := types.Tool{
+		ToolDef: types.ToolDef{Parameters: types.Parameters{Name: "research", ToolChoice: "web search"}},
+		ToolMapping: map[string][]types.ToolReference{
+			"web search": {{Reference: "web search", ToolID: "search.gpt:web search"}},
+		},
+	}
+	tools := []types.CompletionTool{{Function: types.CompletionFunctionDefinition{
+		ToolID: "search.gpt:web search",
+		Name:   "webSearch",
+	}}}
+
+	choice, err := toolChoice(tool, tools)
+	require.NoError(t, err)
+	require.Equal(t, "webSearch", choice)
+
+	tool.Parameters.ToolChoice = "summarize"
+	_, err = toolChoice(tool, tools)
+	require.ErrorContains(t, err, "invalid tool choice")
+
+	tool.Parameters.ToolChoice = types.ToolChoiceRequired
+	choice, err = toolChoice(tool, tools)
+	require.NoError(t, err)
+	require.Equal(t, types.ToolChoiceRequired, choice)
+}
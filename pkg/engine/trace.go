@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+)
+
+var traceParentRegexp = regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+type traceKey struct{}
+
+// trace identifies a call in the call tree of a run so that tools can correlate their own logs and traces.
+type trace struct {
+	runID   string
+	traceID string
+	callID  string
+}
+
+// WithRunID returns a context for a new run with the given ID. Calls started with the returned context share a
+// W3C trace ID, which is inherited from the TRACEPARENT environment variable if gptscript itself is run by a traced
+// tool.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace{
+		runID:   runID,
+		traceID: newTraceID(),
+	})
+}
+
+func newTraceID() string {
+	if m := traceParentRegexp.FindStringSubmatch(os.Getenv("TRACEPARENT")); m != nil {
+		return m[1]
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// withCallTrace records the call ID in the context. If the context is not part of a run, the call starts a new
+// run with the call ID as the run ID.
+func withCallTrace(ctx context.Context, callID string) context.Context {
+	t, ok := ctx.Value(traceKey{}).(trace)
+	if !ok {
+		t = trace{
+			runID:   callID,
+			traceID: newTraceID(),
+		}
+	}
+	t.callID = callID
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// traceParent returns the W3C traceparent for the call with the span ID derived from the call ID.
+func (t trace) traceParent() string {
+	spanID := sha256.Sum256([]byte(t.runID + "/" + t.callID))
+	return "00-" + t.traceID + "-" + hex.EncodeToString(spanID[:8]) + "-01"
+}
+
+// baggage returns the labels of the run as a W3C baggage value.
+func baggage(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	members := make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, url.PathEscape(k)+"="+url.PathEscape(labels[k]))
+	}
+	return strings.Join(members, ",")
+}
+
+// traceEnv returns the environment variables that identify the current call, and the labels of its run, to a tool
+// subprocess.
+func traceEnv(ctx context.Context) (result []string) {
+	if labels := gcontext.GetLabels(ctx); len(labels) > 0 {
+		data, err := json.Marshal(labels)
+		if err == nil {
+			result = append(result, "GPTSCRIPT_LABELS="+string(data), "BAGGAGE="+baggage(labels))
+		}
+	}
+
+	t, ok := ctx.Value(traceKey{}).(trace)
+	if !ok {
+		return result
+	}
+	return append(result,
+		"GPTSCRIPT_RUN_ID="+t.runID,
+		"GPTSCRIPT_CALL_ID="+t.callID,
+		"TRACEPARENT="+t.traceParent(),
+	)
+}
+
+// setTraceHeaders sets the headers that identify the current call, and the labels of its run, on a request to a
+// daemon or HTTP tool.
+func setTraceHeaders(ctx context.Context, header http.Header) {
+	if labels := gcontext.GetLabels(ctx); len(labels) > 0 {
+		header.Set("Baggage", baggage(labels))
+	}
+
+	t, ok := ctx.Value(traceKey{}).(trace)
+	if !ok {
+		return
+	}
+	header.Set("X-GPTScript-Run-ID", t.runID)
+	header.Set("X-GPTScript-Call-ID", t.callID)
+	header.Set("Traceparent", t.traceParent())
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type workDirKey struct{}
+
+type workDir struct {
+	dir      string
+	confined bool
+}
+
+// WorkDirFromContext returns the working directory of the tool that called the builtin tool of the context, and
+// whether the builtin tool must not use files outside of it. The directory is empty when the tool has none.
+func WorkDirFromContext(ctx context.Context) (string, bool) {
+	w, _ := ctx.Value(workDirKey{}).(workDir)
+	return w.dir, w.confined
+}
+
+func withWorkDir(ctx context.Context, dir string, confined bool) context.Context {
+	return context.WithValue(ctx, workDirKey{}, workDir{dir: dir, confined: confined})
+}
+
+// workDir returns the working directory of the tool of the context, which is that of the closest tool in the calls
+// that lead to it that declared one, with its symlinks resolved.
+func (e *Engine) workDir(ctx Context) (string, error) {
+	for c := &ctx; c != nil; c = c.Parent {
+		if c.Tool.WorkDir == "" {
+			continue
+		}
+
+		_, envMap := envAsMapAndDeDup(e.Env)
+		dir := os.Expand(c.Tool.WorkDir, func(s string) string {
+			return envMap[s]
+		})
+		if !filepath.IsAbs(dir) && e.Dir != "" {
+			dir = filepath.Join(e.Dir, dir)
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		dir, err = filepath.EvalSymlinks(dir)
+		if err != nil {
+			return "", fmt.Errorf("invalid working dir of tool %s: %w", c.Tool.Name, err)
+		}
+		if s, err := os.Stat(dir); err != nil || !s.IsDir() {
+			return "", fmt.Errorf("invalid working dir of tool %s: %s is not a directory", c.Tool.Name, dir)
+		}
+		return dir, nil
+	}
+	return "", nil
+}
+
+// ConfinePath returns the file of the path, which is relative to the directory unless it is absolute, and fails if it
+// is outside of the directory. Symlinks are resolved first, so a link can't lead outside of the directory, and the
+// path need not exist so that new files can be written. The directory must have its own symlinks resolved.
+func ConfinePath(dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	// The missing parts of the path can't be links, so only the part that exists is resolved.
+	existing, missing := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			path = filepath.Join(resolved, missing)
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the working directory %s", path, dir)
+	}
+	return path, nil
+}
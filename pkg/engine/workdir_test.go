@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkDir(t *testing.T) {
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(workspace, "data"), 0755))
+	e := &Engine{Env: []string{"GPTSCRIPT_WORKSPACE_DIR=" + workspace}}
+
+	var parent Context
+	parent.Tool = types.Tool{ToolDef: types.ToolDef{Parameters: types.Parameters{Name: "notes", WorkDir: "${GPTSCRIPT_WORKSPACE_DIR}/data"}}}
+	child := Context{Parent: &parent}
+
+	// Tools run in the working directory of the tool that called them.
+	dir, err := e.workDir(child)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(workspace, "data"), dir)
+
+	dir, err = e.workDir(Context{})
+	require.NoError(t, err)
+	require.Empty(t, dir)
+
+	// Relative working directories are relative to the directory of the engine, such as that of a client of the daemon.
+	parent.Tool.WorkDir = "data"
+	e.Dir = workspace
+	dir, err = e.workDir(child)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(workspace, "data"), dir)
+
+	parent.Tool.WorkDir = "${GPTSCRIPT_WORKSPACE_DIR}/missing"
+	_, err = e.workDir(child)
+	require.ErrorContains(t, err, "invalid working dir of tool notes")
+}
+
+func TestConfinePath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "escape")))
+
+	file, err := ConfinePath(dir, "notes.txt")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "notes.txt"), file)
+
+	// Files that don't exist yet can be written.
+	file, err = ConfinePath(dir, "drafts/../new.txt")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "new.txt"), file)
+
+	for _, path := range []string{"../notes.txt", "/etc/passwd", "escape/secret.txt", filepath.Join(dir, "escape", "new", "file.txt")} {
+		_, err = ConfinePath(dir, path)
+		require.ErrorContains(t, err, "is outside of the working directory", path)
+	}
+}
+
+func TestConfineCommand(t *testing.T) {
+	progress := make(chan types.CompletionStatus)
+	go func() {
+		for range progress {
+		}
+	}()
+	defer close(progress)
+	e := &Engine{Progress: progress, ConfineTools: true}
+
+	var parent Context
+	parent.Tool = types.Tool{ToolDef: types.ToolDef{Parameters: types.Parameters{Name: "notes", WorkDir: t.TempDir()}}}
+	child := Context{Ctx: context.Background(), Parent: &parent, Program: &types.Program{}}
+	tool := types.Tool{ToolDef: types.ToolDef{Parameters: types.Parameters{Name: "cat"}, Instructions: "#!/bin/cat /etc/passwd"}}
+
+	// A command can open any file, so it is refused instead of being run in the working directory.
+	out, err := e.runCommand(child, tool, "", NoCategory)
+	require.NoError(t, err)
+	require.Contains(t, out, "ERROR: command tool cat can't be run by a tool that is confined to its working directory")
+
+	_, err = e.runCommand(child, tool, "", CredentialToolCategory)
+	require.ErrorContains(t, err, "command tool cat can't be run")
+}
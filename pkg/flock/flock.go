@@ -0,0 +1,67 @@
+// Package flock locks files across processes, so that concurrent gptscript processes sharing a cache directory don't
+// set up the same repository or runtime at the same time.
+package flock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+)
+
+var log = mvl.Package()
+
+const (
+	// pollInterval is how often a lock that is held by another process is tried again.
+	pollInterval = 100 * time.Millisecond
+	// waitMessageAfter is how long to wait before logging that another process holds the lock.
+	waitMessageAfter = 2 * time.Second
+)
+
+// Lock blocks until this process holds the lock of the file at path, which is created if it doesn't exist, or ctx is
+// done. The lock is released by calling the returned function, or by the operating system when the process exits, so
+// a process that crashes doesn't leave it locked. Locks are held by the process, so goroutines of the same process
+// have to be serialized separately.
+//
+// Callers check for the result of the work that the lock guards once they hold it, so that a process that waited for
+// another one to download or set up the same thing reuses it instead of doing it again.
+func Lock(ctx context.Context, path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	logged := false
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				_ = unlock(f)
+				_ = f.Close()
+			}, nil
+		}
+
+		if !logged && time.Since(start) > waitMessageAfter {
+			log.InfofCtx(ctx, "Waiting for another process to release %s", path)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
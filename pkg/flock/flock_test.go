@@ -0,0 +1,40 @@
+package flock
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "setup.lock")
+
+	unlock, err := Lock(context.Background(), path)
+	require.NoError(t, err)
+
+	// Each call opens the file again, so a second lock in the same process waits like another process would.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*pollInterval)
+	defer cancel()
+	_, err = Lock(ctx, path)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlocked := make(chan struct{})
+	go func() {
+		time.Sleep(2 * pollInterval)
+		close(unlocked)
+		unlock()
+	}()
+
+	unlock, err = Lock(context.Background(), path)
+	require.NoError(t, err)
+	defer unlock()
+
+	select {
+	case <-unlocked:
+	default:
+		t.Fatal("lock was acquired before it was released")
+	}
+}
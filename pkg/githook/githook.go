@@ -0,0 +1,216 @@
+package githook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Hooks are the git hooks that a tool can be installed as.
+var Hooks = []string{"pre-commit", "prepare-commit-msg", "commit-msg"}
+
+// marker identifies the hooks that were installed by gptscript, which are the only ones that are replaced or removed.
+const marker = "# Installed by gptscript hook install."
+
+// maxDiffBytes is the most of the staged diff that is given to the tool, so that large commits don't overflow the
+// context window of the model.
+const maxDiffBytes = 200_000
+
+func validHook(hook string) error {
+	for _, h := range Hooks {
+		if hook == h {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported hook %q, must be one of %s", hook, strings.Join(Hooks, ", "))
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Path returns the path of a file under the git directory of the repository in dir, such as hooks/commit-msg, which
+// respects core.hooksPath and worktrees.
+func Path(ctx context.Context, dir, name string) (string, error) {
+	path, err := git(ctx, dir, "rev-parse", "--path-format=absolute", "--git-path", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// Root returns the top level directory of the working tree in dir, which hooks are run in.
+func Root(ctx context.Context, dir string) (string, error) {
+	root, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(root), nil
+}
+
+// Script returns the hook that runs gptscript hook run with the arguments, which are quoted for the shell.
+func Script(hook string, command ...string) string {
+	quoted := make([]string, 0, len(command))
+	for _, arg := range command {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return fmt.Sprintf("#!/bin/sh\n%s Remove it with: gptscript hook uninstall %s\nexec %s -- \"$@\"\n",
+		marker, hook, strings.Join(quoted, " "))
+}
+
+// Install writes the script as the hook of the repository in dir. A hook that was not installed by gptscript is only
+// replaced with force.
+func Install(ctx context.Context, dir, hook, script string, force bool) (string, error) {
+	if err := validHook(hook); err != nil {
+		return "", err
+	}
+
+	path, err := Path(ctx, dir, filepath.Join("hooks", hook))
+	if err != nil {
+		return "", err
+	}
+
+	if existing, err := os.ReadFile(path); err == nil && !bytes.Contains(existing, []byte(marker)) && !force {
+		return "", fmt.Errorf("%s already exists and was not installed by gptscript, use --force to replace it", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(script), 0755)
+}
+
+// Uninstall removes the hook of the repository in dir if it was installed by gptscript.
+func Uninstall(ctx context.Context, dir, hook string) (string, error) {
+	if err := validHook(hook); err != nil {
+		return "", err
+	}
+
+	path, err := Path(ctx, dir, filepath.Join("hooks", hook))
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s is not installed", hook)
+	} else if err != nil {
+		return "", err
+	}
+	if !bytes.Contains(existing, []byte(marker)) {
+		return "", fmt.Errorf("%s was not installed by gptscript", path)
+	}
+	return path, os.Remove(path)
+}
+
+// Input is what the tool of a hook is given, as JSON.
+type Input struct {
+	Hook string `json:"hook"`
+	// Args are the arguments that git called the hook with.
+	Args []string `json:"args,omitempty"`
+	// Files are the paths of the staged files.
+	Files []string `json:"files"`
+	// Diff is the staged diff, which is cut off after about 200KB.
+	Diff string `json:"diff"`
+	// Message is the commit message without its comments, for prepare-commit-msg and commit-msg.
+	Message string `json:"message,omitempty"`
+}
+
+// NewInput returns the input of the tool of a hook that git called with the arguments.
+func NewInput(ctx context.Context, dir, hook string, args []string) (string, error) {
+	if err := validHook(hook); err != nil {
+		return "", err
+	}
+
+	input := Input{
+		Hook:  hook,
+		Args:  args,
+		Files: []string{},
+	}
+
+	files, err := git(ctx, dir, "diff", "--cached", "--name-only")
+	if err != nil {
+		return "", err
+	}
+	for _, file := range strings.Split(strings.TrimSpace(files), "\n") {
+		if file != "" {
+			input.Files = append(input.Files, file)
+		}
+	}
+
+	input.Diff, err = git(ctx, dir, "diff", "--cached")
+	if err != nil {
+		return "", err
+	}
+	if len(input.Diff) > maxDiffBytes {
+		input.Diff = input.Diff[:maxDiffBytes] + "\n[The rest of the diff was cut off because it is too long]\n"
+	}
+
+	if hook != "pre-commit" && len(args) > 0 {
+		file := args[0]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		input.Message = stripComments(string(data))
+	}
+
+	data, err := json.Marshal(input)
+	return string(data), err
+}
+
+func stripComments(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// GeneratesMessage returns whether the output of the tool of a prepare-commit-msg hook becomes the commit message,
+// which is only when the message was not given with -m, -F, or another commit.
+func GeneratesMessage(hook string, args []string) bool {
+	return hook == "prepare-commit-msg" && len(args) > 0 && (len(args) == 1 || args[1] == "" || args[1] == "template")
+}
+
+// WriteMessage replaces the commit message in the file with the message, keeping the comments that git adds for the
+// editor.
+func WriteMessage(file, message string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var comments []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+		}
+	}
+
+	content := strings.TrimSpace(message) + "\n"
+	if len(comments) > 0 {
+		content += "\n" + strings.Join(comments, "\n") + "\n"
+	}
+	return os.WriteFile(file, []byte(content), 0644)
+}
@@ -0,0 +1,102 @@
+package githook
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	_, err := git(context.Background(), dir, "init", "-q")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestInstall(t *testing.T) {
+	ctx := context.Background()
+	dir := newRepo(t)
+
+	script := Script("commit-msg", "/usr/bin/gptscript", "hook", "run", "commit-msg", "--tool", "it's.gpt")
+	require.Contains(t, script, `exec '/usr/bin/gptscript' 'hook' 'run' 'commit-msg' '--tool' 'it'\''s.gpt' -- "$@"`)
+
+	path, err := Install(ctx, dir, "commit-msg", script, false)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, ".git", "hooks", "commit-msg"), path)
+
+	// A hook that was installed by gptscript is replaced.
+	_, err = Install(ctx, dir, "commit-msg", script, false)
+	require.NoError(t, err)
+
+	_, err = Uninstall(ctx, dir, "commit-msg")
+	require.NoError(t, err)
+	_, err = Uninstall(ctx, dir, "commit-msg")
+	require.ErrorContains(t, err, "not installed")
+
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), 0755))
+	_, err = Install(ctx, dir, "commit-msg", script, false)
+	require.ErrorContains(t, err, "--force")
+	_, err = Uninstall(ctx, dir, "commit-msg")
+	require.ErrorContains(t, err, "not installed by gptscript")
+	_, err = Install(ctx, dir, "commit-msg", script, true)
+	require.NoError(t, err)
+
+	_, err = Install(ctx, dir, "pre-push", script, false)
+	require.ErrorContains(t, err, "unsupported hook")
+}
+
+func TestNewInput(t *testing.T) {
+	ctx := context.Background()
+	dir := newRepo(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello\n"), 0644))
+	_, err := git(ctx, dir, "add", "a.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("not staged\n"), 0644))
+
+	msgFile := filepath.Join(dir, ".git", "COMMIT_EDITMSG")
+	require.NoError(t, os.WriteFile(msgFile, []byte("Add a\n\n# Please enter the commit message\n"), 0644))
+
+	data, err := NewInput(ctx, dir, "commit-msg", []string{msgFile})
+	require.NoError(t, err)
+
+	var input Input
+	require.NoError(t, json.Unmarshal([]byte(data), &input))
+	require.Equal(t, "commit-msg", input.Hook)
+	require.Equal(t, []string{"a.txt"}, input.Files)
+	require.Contains(t, input.Diff, "+hello")
+	require.Equal(t, "Add a", input.Message)
+
+	data, err = NewInput(ctx, dir, "pre-commit", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(data), &input))
+	require.Equal(t, "pre-commit", input.Hook)
+}
+
+func TestGeneratesMessage(t *testing.T) {
+	require.True(t, GeneratesMessage("prepare-commit-msg", []string{"COMMIT_EDITMSG"}))
+	require.True(t, GeneratesMessage("prepare-commit-msg", []string{"COMMIT_EDITMSG", "template"}))
+	require.False(t, GeneratesMessage("prepare-commit-msg", []string{"COMMIT_EDITMSG", "message"}))
+	require.False(t, GeneratesMessage("prepare-commit-msg", []string{"COMMIT_EDITMSG", "commit", "HEAD"}))
+	require.False(t, GeneratesMessage("commit-msg", []string{"COMMIT_EDITMSG"}))
+}
+
+func TestWriteMessage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	require.NoError(t, os.WriteFile(file, []byte("\n# Please enter the commit message\n# On branch main\n"), 0644))
+
+	require.NoError(t, WriteMessage(file, "Add a\n\nBecause.\n\n"))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "Add a\n\nBecause.\n\n# Please enter the commit message\n# On branch main\n", string(data))
+}
@@ -0,0 +1,111 @@
+package gptscript
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// Artifacts returns the files in the workspace that match the artifact patterns declared by the tools of the
+// program. It should be called after the program has run and before the workspace is closed. Symlinks are skipped,
+// and so are files in linked directories outside of the workspace, so that a tool can't make other files artifacts.
+func (g *GPTScript) Artifacts(prg types.Program) ([]types.Artifact, error) {
+	if g.WorkspacePath == "" {
+		return nil, nil
+	}
+
+	workspace, err := filepath.EvalSymlinks(g.WorkspacePath)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := prg.ArtifactPatterns()
+	toolNames := make([]string, 0, len(patterns))
+	for name := range patterns {
+		toolNames = append(toolNames, name)
+	}
+	sort.Strings(toolNames)
+
+	var (
+		result []types.Artifact
+		seen   = map[string]struct{}{}
+	)
+	for _, toolName := range toolNames {
+		for _, pattern := range patterns[toolName] {
+			matches, err := filepath.Glob(filepath.Join(g.WorkspacePath, filepath.FromSlash(pattern)))
+			if err != nil {
+				return nil, fmt.Errorf("invalid artifact pattern %s: %w", pattern, err)
+			}
+			for _, match := range matches {
+				s, err := os.Lstat(match)
+				if err != nil {
+					return nil, err
+				} else if !s.Mode().IsRegular() {
+					continue
+				}
+
+				rel, err := filepath.Rel(g.WorkspacePath, match)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := engine.ConfinePath(workspace, rel); err != nil {
+					continue
+				}
+				name := filepath.ToSlash(rel)
+				if _, ok := seen[name]; ok {
+					continue
+				}
+				seen[name] = struct{}{}
+
+				result = append(result, types.Artifact{
+					Name: name,
+					Tool: toolName,
+					Size: s.Size(),
+				})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// SaveArtifacts copies the artifacts from the workspace to dir, keeping their paths relative to the workspace.
+func (g *GPTScript) SaveArtifacts(artifacts []types.Artifact, dir string) error {
+	for _, artifact := range artifacts {
+		if err := copyFile(filepath.Join(g.WorkspacePath, filepath.FromSlash(artifact.Name)), filepath.Join(dir, filepath.FromSlash(artifact.Name))); err != nil {
+			return fmt.Errorf("failed to save artifact %s: %w", artifact.Name, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,30 @@
+package gptscript
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifacts(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(workspace, "out"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "out", "report.txt"), []byte("report"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(workspace, "out", "secret.txt")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(workspace, "linked")))
+
+	prg := types.Program{ToolSet: types.ToolSet{"report": {ToolDef: types.ToolDef{Parameters: types.Parameters{
+		Name:      "report",
+		Artifacts: []string{"out/*.txt", "linked/*.txt"},
+	}}}}}
+
+	// Links, and files in linked directories, are not artifacts.
+	artifacts, err := (&GPTScript{WorkspacePath: workspace}).Artifacts(prg)
+	require.NoError(t, err)
+	require.Equal(t, []types.Artifact{{Name: "out/report.txt", Tool: "report", Size: 6}}, artifacts)
+}
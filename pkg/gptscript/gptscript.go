@@ -8,19 +8,26 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/gptscript-ai/gptscript/pkg/bedrock"
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/config"
 	context2 "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/cost"
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/llm"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/monitor"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/ollama"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
 	"github.com/gptscript-ai/gptscript/pkg/prompt"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/gptscript-ai/gptscript/pkg/quota"
 	"github.com/gptscript-ai/gptscript/pkg/remote"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
@@ -40,15 +47,23 @@ type GPTScript struct {
 }
 
 type Options struct {
-	Cache               cache.Options
-	OpenAI              openai.Options
-	Monitor             monitor.Options
-	Runner              runner.Options
-	CredentialContext   string
+	Cache             cache.Options
+	OpenAI            openai.Options
+	Ollama            ollama.Options
+	Monitor           monitor.Options
+	Runner            runner.Options
+	CredentialContext string
+	// CredentialCacheTTL is how long the credentials read from the store are kept in memory. Zero disables the cache.
+	CredentialCacheTTL  time.Duration
 	Quiet               *bool
 	Workspace           string
 	DisablePromptServer bool
-	Env                 []string
+	// Prompter answers sys.prompt and model provider credential prompts instead of the terminal. Confirmations and
+	// credential requests can be handled in the same way with Runner.Authorizer and Runner.CredentialRequester.
+	Prompter prompt.Handler
+	// ModelAliases are aliases of models given as alias=model, which take precedence over those of the config file.
+	ModelAliases []string
+	Env          []string
 }
 
 func complete(opts ...Options) Options {
@@ -58,12 +73,18 @@ func complete(opts ...Options) Options {
 		result.Monitor = monitor.Complete(result.Monitor, opt.Monitor)
 		result.Runner = runner.Complete(result.Runner, opt.Runner)
 		result.OpenAI = openai.Complete(result.OpenAI, opt.OpenAI)
+		result.Ollama = ollama.Complete(result.Ollama, opt.Ollama)
 
 		result.CredentialContext = types.FirstSet(opt.CredentialContext, result.CredentialContext)
+		result.CredentialCacheTTL = types.FirstSet(opt.CredentialCacheTTL, result.CredentialCacheTTL)
 		result.Quiet = types.FirstSet(opt.Quiet, result.Quiet)
 		result.Workspace = types.FirstSet(opt.Workspace, result.Workspace)
 		result.Env = append(result.Env, opt.Env...)
+		result.ModelAliases = append(result.ModelAliases, opt.ModelAliases...)
 		result.DisablePromptServer = types.FirstSet(opt.DisablePromptServer, result.DisablePromptServer)
+		if opt.Prompter != nil {
+			result.Prompter = opt.Prompter
+		}
 	}
 
 	if result.Quiet == nil {
@@ -93,6 +114,17 @@ func New(ctx context.Context, o ...Options) (*GPTScript, error) {
 		return nil, err
 	}
 
+	if err := proxy.Install(cliCfg.Proxies); err != nil {
+		return nil, err
+	}
+
+	aliases, err := llm.ParseModelAliases(opts.ModelAliases)
+	if err != nil {
+		return nil, err
+	}
+	registry.AddModelAliases(cliCfg.ModelAliases)
+	registry.AddModelAliases(aliases)
+
 	if opts.Runner.RuntimeManager == nil {
 		opts.Runner.RuntimeManager = runtimes.Default(cacheClient.CacheDir())
 	}
@@ -105,6 +137,21 @@ func New(ctx context.Context, o ...Options) (*GPTScript, error) {
 	if err != nil {
 		return nil, err
 	}
+	credStore = credentials.NewCachedStore(credStore, opts.CredentialCacheTTL)
+
+	// The prices of the quota take precedence over the prices of the configuration file, which override the defaults.
+	q, hasQuota := cliCfg.GetQuota(opts.CredentialContext)
+	opts.Runner.Prices = cost.WithDefaults(cliCfg.Prices, q.Prices, opts.Runner.Prices)
+	if hasQuota {
+		q.Prices = opts.Runner.Prices
+		tracker := quota.NewTracker(opts.CredentialContext, q, filepath.Join(filepath.Dir(cliCfg.GetFilename()), "usage"))
+		opts.OpenAI.Hooks = tracker.Hooks(opts.OpenAI.Hooks)
+	}
+	if opts.Runner.CheckpointCost > 0 && opts.OpenAI.DefaultModel != "" {
+		if _, ok := opts.Runner.Prices.Price(opts.OpenAI.DefaultModel); !ok {
+			log.WithContext(ctx).Warnf("The default model %s has no price, so its cost is not counted for cost checkpoints", opts.OpenAI.DefaultModel)
+		}
+	}
 
 	oaiClient, err := openai.NewClient(ctx, credStore, opts.OpenAI, openai.Options{
 		Cache:   cacheClient,
@@ -118,10 +165,34 @@ func New(ctx context.Context, o ...Options) (*GPTScript, error) {
 		return nil, err
 	}
 
+	bedrockClient, err := bedrock.NewClient(cacheClient, opts.OpenAI)
+	if err != nil {
+		return nil, err
+	}
+	if err := registry.AddClient(bedrockClient); err != nil {
+		return nil, err
+	}
+
+	if !opts.Ollama.Disable {
+		ollamaClient, err := ollama.New(credStore, opts.Ollama, providerOptions(opts.OpenAI, cacheClient))
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.AddClient(ollamaClient); err != nil {
+			return nil, err
+		}
+	}
+
 	if opts.Runner.MonitorFactory == nil {
 		opts.Runner.MonitorFactory = monitor.NewConsole(opts.Monitor, monitor.Options{DebugMessages: *opts.Quiet})
 	}
 
+	if len(opts.Runner.Guardrails) > 0 && opts.Runner.LoadGuardrail == nil {
+		opts.Runner.LoadGuardrail = func(ctx context.Context, tool string) (types.Program, error) {
+			return loader.Program(ctx, tool, "", loader.Options{Cache: cacheClient})
+		}
+	}
+
 	runner, err := runner.New(registry, credStore, opts.Runner)
 	if err != nil {
 		return nil, err
@@ -134,16 +205,17 @@ func New(ctx context.Context, o ...Options) (*GPTScript, error) {
 	if !opts.DisablePromptServer {
 		var ctx context.Context
 		ctx, closeServer = context.WithCancel(context2.AddPauseFuncToCtx(context.Background(), opts.Runner.MonitorFactory.Pause))
-		extraEnv, err = prompt.NewServer(ctx, opts.Env)
+		extraEnv, err = prompt.NewServer(ctx, opts.Env, opts.Prompter)
 		if err != nil {
 			closeServer()
 			return nil, err
 		}
 	}
 
-	fullEnv := append(opts.Env, extraEnv...)
+	// The prompt server started here takes precedence over one from the environment.
+	fullEnv := slices.Concat(extraEnv, opts.Env)
 
-	remoteClient := remote.New(runner, fullEnv, cacheClient, credStore)
+	remoteClient := remote.New(runner, fullEnv, cacheClient, credStore, providerOptions(opts.OpenAI, cacheClient))
 	if err := registry.AddClient(remoteClient); err != nil {
 		closeServer()
 		return nil, err
@@ -195,6 +267,22 @@ func makeAbsolute(path string) (string, error) {
 	return filepath.Abs(path)
 }
 
+// providerOptions returns the options of the OpenAI client that apply to the clients of other providers, such as
+// Ollama and remote providers: retries, rate limits, timeouts, TLS, headers, and the system prompt. The endpoint, key,
+// and default model of OpenAI are left out, as is the Batch API, which other providers don't have.
+func providerOptions(opts openai.Options, cacheClient *cache.Client) openai.Options {
+	opts.BaseURL = ""
+	opts.APIKey = ""
+	opts.OrgID = ""
+	opts.DefaultModel = ""
+	opts.ConfigFile = ""
+	opts.CacheKey = ""
+	opts.SetSeed = false
+	opts.Batch = false
+	opts.Cache = cacheClient
+	return opts
+}
+
 func (g *GPTScript) Chat(ctx context.Context, prevState runner.ChatState, prg types.Program, envs []string, input string) (runner.ChatResponse, error) {
 	envs, err := g.getEnv(envs)
 	if err != nil {
@@ -241,3 +329,9 @@ func (g *GPTScript) ListTools(_ context.Context, prg types.Program) []types.Tool
 func (g *GPTScript) ListModels(ctx context.Context, providers ...string) ([]string, error) {
 	return g.Registry.ListModels(ctx, providers...)
 }
+
+// Embeddings returns the embeddings of the inputs from the provider of the model, or from the default embedding model
+// when the model is empty.
+func (g *GPTScript) Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	return g.Registry.Embeddings(ctx, model, inputs)
+}
@@ -0,0 +1,146 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// maxMessageSize is the largest response message that is read.
+const maxMessageSize = 16 << 20
+
+// codeUnimplemented is the status of a call to a method that the server does not have.
+const codeUnimplemented = 12
+
+// Client calls the methods of a gRPC server over HTTP/2, with TLS or in plain text.
+type Client struct {
+	baseURL string
+	header  http.Header
+	client  *http.Client
+}
+
+// StatusError is an error status returned by the server.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", e.Code, e.Message)
+}
+
+// NewClient returns a client for a target such as localhost:50051. The header is sent as the metadata of each call.
+func NewClient(target string, useTLS bool, header http.Header) *Client {
+	transport := &http2.Transport{}
+	scheme := "https"
+	if !useTLS {
+		scheme = "http"
+		// HTTP/2 without TLS, which is how most internal services are served.
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+
+	return &Client{
+		baseURL: scheme + "://" + target,
+		header:  header,
+		client:  &http.Client{Transport: transport},
+	}
+}
+
+// Call sends one request message to a method, such as /helloworld.Greeter/SayHello, and returns the response
+// messages. Unary methods have one response, and server streaming methods have any number.
+func (c *Client) Call(ctx context.Context, method string, request []byte) ([][]byte, error) {
+	return c.Stream(ctx, method, [][]byte{request})
+}
+
+// Stream sends the request messages to a method and returns the response messages once the server ends the call.
+func (c *Client) Stream(ctx context.Context, method string, requests [][]byte) ([][]byte, error) {
+	u, err := url.JoinPath(c.baseURL, method)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for _, request := range requests {
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(request)))
+		body.Write(prefix[:])
+		body.Write(request)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s from %s", resp.Status, u)
+	}
+
+	var responses [][]byte
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(resp.Body, prefix[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read response of %s: %w", method, err)
+		}
+		if prefix[0] != 0 {
+			return nil, fmt.Errorf("compressed responses are not supported")
+		}
+		length := binary.BigEndian.Uint32(prefix[1:])
+		if length > maxMessageSize {
+			return nil, fmt.Errorf("response of %s is %d bytes, larger than the limit of %d bytes", method, length, maxMessageSize)
+		}
+		message := make([]byte, length)
+		if _, err := io.ReadFull(resp.Body, message); err != nil {
+			return nil, fmt.Errorf("failed to read response of %s: %w", method, err)
+		}
+		responses = append(responses, message)
+	}
+
+	// Errors without a response are sent in the headers, and otherwise in the trailers.
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if status != "" && status != "0" {
+		code, _ := strconv.Atoi(status)
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return nil, &StatusError{Code: code, Message: message}
+	}
+	return responses, nil
+}
+
+// MethodPath returns the path of a method, such as /helloworld.Greeter/SayHello.
+func MethodPath(service, method string) string {
+	return "/" + strings.TrimPrefix(service, ".") + "/" + method
+}
@@ -0,0 +1,469 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Marshal encodes the JSON value of a message, which uses the JSON or proto names of the fields, as a protocol
+// buffer. Numbers should be decoded with json.Decoder.UseNumber to keep the precision of 64-bit integers.
+func (s *Schema) Marshal(messageName string, value any) ([]byte, error) {
+	message, ok := s.Messages[messageName]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %s", messageName)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", messageName)
+	}
+
+	var b []byte
+	for _, field := range message.Fields {
+		v, ok := obj[field.JSONName]
+		if !ok {
+			v, ok = obj[field.Name]
+		}
+		if !ok || v == nil {
+			continue
+		}
+
+		var err error
+		switch {
+		case field.Repeated && s.isMap(field):
+			b, err = s.marshalMap(b, field, v)
+		case field.Repeated:
+			items, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("field %s of %s must be an array", field.JSONName, messageName)
+			}
+			if isPackable(field.Type) {
+				var packed []byte
+				for _, item := range items {
+					if packed, err = s.appendValue(packed, field, item); err != nil {
+						break
+					}
+				}
+				b = appendBytes(b, field.Number, packed)
+			} else {
+				for _, item := range items {
+					b = appendTag(b, field.Number, wireType(field.Type))
+					if b, err = s.appendValue(b, field, item); err != nil {
+						break
+					}
+				}
+			}
+		default:
+			b = appendTag(b, field.Number, wireType(field.Type))
+			b, err = s.appendValue(b, field, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.JSONName, messageName, err)
+		}
+	}
+	return b, nil
+}
+
+func (s *Schema) isMap(field Field) bool {
+	message, ok := s.Messages[field.TypeName]
+	return field.Type == TypeMessage && ok && message.MapEntry && len(message.Fields) == 2
+}
+
+func (s *Schema) marshalMap(b []byte, field Field, v any) ([]byte, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("must be an object")
+	}
+	entry := s.Messages[field.TypeName]
+	keyField, valueField := entry.Fields[0], entry.Fields[1]
+	if keyField.Number != 1 {
+		keyField, valueField = valueField, keyField
+	}
+
+	for key, value := range obj {
+		var (
+			encoded = appendTag(nil, 1, wireType(keyField.Type))
+			err     error
+		)
+		switch keyField.Type {
+		case TypeString:
+			encoded, err = s.appendValue(encoded, keyField, key)
+		case TypeBool:
+			encoded, err = s.appendValue(encoded, keyField, key == "true")
+		default:
+			encoded, err = s.appendValue(encoded, keyField, json.Number(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		encoded = appendTag(encoded, 2, wireType(valueField.Type))
+		if encoded, err = s.appendValue(encoded, valueField, value); err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		b = appendBytes(b, field.Number, encoded)
+	}
+	return b, nil
+}
+
+func isPackable(t FieldType) bool {
+	return t != TypeString && t != TypeBytes && t != TypeMessage && t != TypeGroup
+}
+
+func wireType(t FieldType) int {
+	switch t {
+	case TypeDouble, TypeFixed64, TypeSfixed64:
+		return wireFixed64
+	case TypeFloat, TypeFixed32, TypeSfixed32:
+		return wireFixed32
+	case TypeString, TypeBytes, TypeMessage:
+		return wireBytes
+	default:
+		return wireVarint
+	}
+}
+
+// appendValue appends a single value of a field, without its tag. Messages, strings, and bytes are length prefixed.
+func (s *Schema) appendValue(b []byte, field Field, v any) ([]byte, error) {
+	switch field.Type {
+	case TypeString:
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		b = binary.AppendUvarint(b, uint64(len(str)))
+		return append(b, str...), nil
+	case TypeBytes:
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a base64 string")
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			if data, err = base64.URLEncoding.DecodeString(str); err != nil {
+				return nil, fmt.Errorf("must be a base64 string")
+			}
+		}
+		b = binary.AppendUvarint(b, uint64(len(data)))
+		return append(b, data...), nil
+	case TypeMessage:
+		data, err := s.Marshal(field.TypeName, v)
+		if err != nil {
+			return nil, err
+		}
+		b = binary.AppendUvarint(b, uint64(len(data)))
+		return append(b, data...), nil
+	case TypeBool:
+		value, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		if value {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case TypeEnum:
+		if name, ok := v.(string); ok {
+			if enum, ok := s.Enums[field.TypeName]; ok {
+				for _, value := range enum.Values {
+					if value.Name == name {
+						return binary.AppendUvarint(b, uint64(int64(value.Number))), nil
+					}
+				}
+			}
+			if _, err := strconv.Atoi(name); err != nil {
+				return nil, fmt.Errorf("unknown value %s of enum %s", name, field.TypeName)
+			}
+		}
+		n, err := toInt(v, 32)
+		if err != nil {
+			return nil, err
+		}
+		return binary.AppendUvarint(b, uint64(n)), nil
+	case TypeDouble, TypeFloat:
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		if field.Type == TypeFloat {
+			return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case TypeUint64, TypeUint32, TypeFixed64, TypeFixed32:
+		bits := 64
+		if field.Type == TypeUint32 || field.Type == TypeFixed32 {
+			bits = 32
+		}
+		n, err := toUint(v, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch field.Type {
+		case TypeFixed64:
+			return binary.LittleEndian.AppendUint64(b, n), nil
+		case TypeFixed32:
+			return binary.LittleEndian.AppendUint32(b, uint32(n)), nil
+		}
+		return binary.AppendUvarint(b, n), nil
+	case TypeInt64, TypeInt32, TypeSint64, TypeSint32, TypeSfixed64, TypeSfixed32:
+		bits := 64
+		if field.Type == TypeInt32 || field.Type == TypeSint32 || field.Type == TypeSfixed32 {
+			bits = 32
+		}
+		n, err := toInt(v, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch field.Type {
+		case TypeSint64, TypeSint32:
+			return binary.AppendUvarint(b, uint64(n<<1)^uint64(n>>63)), nil
+		case TypeSfixed64:
+			return binary.LittleEndian.AppendUint64(b, uint64(n)), nil
+		case TypeSfixed32:
+			return binary.LittleEndian.AppendUint32(b, uint32(n)), nil
+		}
+		// Negative int32 values are sign extended to 64 bits.
+		return binary.AppendUvarint(b, uint64(n)), nil
+	}
+	return nil, fmt.Errorf("unsupported field type %d", field.Type)
+}
+
+// numberString returns the text of a number, which JSON can have as a number or, for 64-bit integers, a string.
+func numberString(v any) (string, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("must be a number")
+}
+
+func toInt(v any, bits int) (int64, error) {
+	s, err := numberString(v)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("must be a %d-bit integer", bits)
+	}
+	return n, nil
+}
+
+func toUint(v any, bits int) (uint64, error) {
+	s, err := numberString(v)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("must be an unsigned %d-bit integer", bits)
+	}
+	return n, nil
+}
+
+func toFloat(v any) (float64, error) {
+	s, err := numberString(v)
+	if err != nil {
+		return 0, err
+	}
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("must be a number")
+	}
+	return f, nil
+}
+
+// Unmarshal decodes a protocol buffer as the JSON value of the message, with the JSON names of the fields. As in
+// the JSON mapping of proto3, 64-bit integers are strings, bytes are base64, and enums are the names of their values.
+func (s *Schema) Unmarshal(messageName string, data []byte) (map[string]any, error) {
+	message, ok := s.Messages[messageName]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %s", messageName)
+	}
+
+	raw, err := parseFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", messageName, err)
+	}
+
+	byNumber := make(map[int]Field, len(message.Fields))
+	for _, f := range message.Fields {
+		byNumber[f.Number] = f
+	}
+
+	result := map[string]any{}
+	for _, r := range raw {
+		field, ok := byNumber[r.num]
+		if !ok {
+			continue
+		}
+
+		if field.Repeated && s.isMap(field) {
+			key, value, err := s.unmarshalMapEntry(field, r.bytes)
+			if err != nil {
+				return nil, err
+			}
+			m, _ := result[field.JSONName].(map[string]any)
+			if m == nil {
+				m = map[string]any{}
+				result[field.JSONName] = m
+			}
+			m[key] = value
+			continue
+		}
+
+		var values []any
+		if r.wireType == wireBytes && isPackable(field.Type) {
+			// Packed repeated scalars.
+			values, err = s.unpack(field, r.bytes)
+		} else {
+			var value any
+			value, err = s.value(field, r)
+			values = []any{value}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.JSONName, messageName, err)
+		}
+
+		if field.Repeated {
+			existing, _ := result[field.JSONName].([]any)
+			result[field.JSONName] = append(existing, values...)
+		} else if len(values) > 0 {
+			result[field.JSONName] = values[len(values)-1]
+		}
+	}
+	return result, nil
+}
+
+func (s *Schema) unmarshalMapEntry(field Field, data []byte) (string, any, error) {
+	entry, err := s.Unmarshal(field.TypeName, data)
+	if err != nil {
+		return "", nil, err
+	}
+	message := s.Messages[field.TypeName]
+	var key string
+	var value any
+	for _, f := range message.Fields {
+		switch f.Number {
+		case 1:
+			key = fmt.Sprint(entry[f.JSONName])
+			if entry[f.JSONName] == nil {
+				key = ""
+			}
+		case 2:
+			value = entry[f.JSONName]
+			if value == nil && f.Type == TypeMessage {
+				value = map[string]any{}
+			}
+		}
+	}
+	return key, value, nil
+}
+
+func (s *Schema) unpack(field Field, data []byte) ([]any, error) {
+	var values []any
+	for len(data) > 0 {
+		r := rawField{num: field.Number, wireType: wireType(field.Type)}
+		switch r.wireType {
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errTruncated
+			}
+			r.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errTruncated
+			}
+			r.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			var n int
+			r.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errTruncated
+			}
+			data = data[n:]
+		}
+		value, err := s.value(field, r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (s *Schema) value(field Field, r rawField) (any, error) {
+	if (r.wireType == wireBytes) != (wireType(field.Type) == wireBytes) {
+		return nil, fmt.Errorf("unexpected wire type %d", r.wireType)
+	}
+
+	switch field.Type {
+	case TypeString:
+		return string(r.bytes), nil
+	case TypeBytes:
+		return base64.StdEncoding.EncodeToString(r.bytes), nil
+	case TypeMessage:
+		return s.Unmarshal(field.TypeName, r.bytes)
+	case TypeBool:
+		return r.varint != 0, nil
+	case TypeEnum:
+		n := int(int32(r.varint))
+		if enum, ok := s.Enums[field.TypeName]; ok {
+			for _, value := range enum.Values {
+				if value.Number == n {
+					return value.Name, nil
+				}
+			}
+		}
+		return n, nil
+	case TypeDouble:
+		return jsonFloat(math.Float64frombits(r.varint)), nil
+	case TypeFloat:
+		return jsonFloat(float64(math.Float32frombits(uint32(r.varint)))), nil
+	case TypeInt32, TypeSfixed32:
+		return int32(r.varint), nil
+	case TypeUint32, TypeFixed32:
+		return uint32(r.varint), nil
+	case TypeSint32:
+		return int32(uint32(r.varint>>1) ^ -uint32(r.varint&1)), nil
+	case TypeInt64, TypeSfixed64:
+		return strconv.FormatInt(int64(r.varint), 10), nil
+	case TypeUint64, TypeFixed64:
+		return strconv.FormatUint(r.varint, 10), nil
+	case TypeSint64:
+		return strconv.FormatInt(int64(r.varint>>1)^-int64(r.varint&1), 10), nil
+	}
+	return nil, fmt.Errorf("unsupported field type %d", field.Type)
+}
+
+// jsonFloat returns the values that JSON numbers can not represent as strings.
+func jsonFloat(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
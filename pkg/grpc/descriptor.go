@@ -0,0 +1,401 @@
+// Package grpc calls the methods of gRPC services with JSON, using the descriptors of the services from server
+// reflection or a descriptor set instead of generated code.
+package grpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldType is the type of a field, as numbered in descriptor.proto.
+type FieldType int
+
+const (
+	TypeDouble   FieldType = 1
+	TypeFloat    FieldType = 2
+	TypeInt64    FieldType = 3
+	TypeUint64   FieldType = 4
+	TypeInt32    FieldType = 5
+	TypeFixed64  FieldType = 6
+	TypeFixed32  FieldType = 7
+	TypeBool     FieldType = 8
+	TypeString   FieldType = 9
+	TypeGroup    FieldType = 10
+	TypeMessage  FieldType = 11
+	TypeBytes    FieldType = 12
+	TypeUint32   FieldType = 13
+	TypeEnum     FieldType = 14
+	TypeSfixed32 FieldType = 15
+	TypeSfixed64 FieldType = 16
+	TypeSint32   FieldType = 17
+	TypeSint64   FieldType = 18
+)
+
+const labelRepeated = 3
+
+// Schema is the messages, enums, and services of a set of proto files. Names are fully qualified without a leading
+// dot, such as helloworld.HelloRequest.
+type Schema struct {
+	Messages map[string]*Message `json:"messages,omitempty"`
+	Enums    map[string]*Enum    `json:"enums,omitempty"`
+	Services []Service           `json:"services,omitempty"`
+	// files is the names of the files that were added, and dependencies is the names of the files they import.
+	files        map[string]bool
+	dependencies []string
+}
+
+type Message struct {
+	Fields []Field `json:"fields,omitempty"`
+	// MapEntry is set for the messages that are generated for the entries of map fields.
+	MapEntry bool `json:"mapEntry,omitempty"`
+}
+
+type Field struct {
+	Name     string    `json:"name"`
+	JSONName string    `json:"jsonName,omitempty"`
+	Number   int       `json:"number"`
+	Type     FieldType `json:"type"`
+	TypeName string    `json:"typeName,omitempty"`
+	Repeated bool      `json:"repeated,omitempty"`
+}
+
+type Enum struct {
+	Values []EnumValue `json:"values,omitempty"`
+}
+
+type EnumValue struct {
+	Name   string `json:"name"`
+	Number int    `json:"number"`
+}
+
+type Service struct {
+	Name    string   `json:"name"`
+	Methods []Method `json:"methods,omitempty"`
+}
+
+type Method struct {
+	Name            string `json:"name"`
+	Input           string `json:"input"`
+	Output          string `json:"output"`
+	ClientStreaming bool   `json:"clientStreaming,omitempty"`
+	ServerStreaming bool   `json:"serverStreaming,omitempty"`
+}
+
+func NewSchema() *Schema {
+	return &Schema{
+		Messages: map[string]*Message{},
+		Enums:    map[string]*Enum{},
+		files:    map[string]bool{},
+	}
+}
+
+// ParseDescriptorSet reads a FileDescriptorSet, as written by protoc --descriptor_set_out.
+func ParseDescriptorSet(data []byte) (*Schema, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	schema := NewSchema()
+	for _, f := range fields {
+		if f.num == 1 && f.wireType == wireBytes {
+			if err := schema.AddFile(f.bytes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return schema, nil
+}
+
+// MissingDependencies returns the names of the files imported by the added files that have not been added.
+func (s *Schema) MissingDependencies() []string {
+	var missing []string
+	for _, dep := range s.dependencies {
+		if !s.files[dep] {
+			missing = append(missing, dep)
+		}
+	}
+	return missing
+}
+
+// AddFile adds the definitions of an encoded FileDescriptorProto. Files that were already added are ignored.
+func (s *Schema) AddFile(data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return fmt.Errorf("invalid file descriptor: %w", err)
+	}
+
+	var (
+		name, pkg string
+		messages  [][]byte
+		enums     [][]byte
+		services  [][]byte
+	)
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			pkg = string(f.bytes)
+		case 3:
+			s.dependencies = append(s.dependencies, string(f.bytes))
+		case 4:
+			messages = append(messages, f.bytes)
+		case 5:
+			enums = append(enums, f.bytes)
+		case 6:
+			services = append(services, f.bytes)
+		}
+	}
+
+	if s.files[name] {
+		return nil
+	}
+	s.files[name] = true
+
+	for _, m := range messages {
+		if err := s.addMessage(pkg, m); err != nil {
+			return fmt.Errorf("invalid file descriptor %s: %w", name, err)
+		}
+	}
+	for _, e := range enums {
+		if err := s.addEnum(pkg, e); err != nil {
+			return fmt.Errorf("invalid file descriptor %s: %w", name, err)
+		}
+	}
+	for _, svc := range services {
+		if err := s.addService(pkg, svc); err != nil {
+			return fmt.Errorf("invalid file descriptor %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (s *Schema) addMessage(scope string, data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+
+	var (
+		message = &Message{}
+		name    string
+		nested  [][]byte
+		enums   [][]byte
+	)
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			field, err := parseField(f.bytes)
+			if err != nil {
+				return err
+			}
+			message.Fields = append(message.Fields, field)
+		case 3:
+			nested = append(nested, f.bytes)
+		case 4:
+			enums = append(enums, f.bytes)
+		case 7:
+			options, err := parseFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			for _, o := range options {
+				if o.num == 7 && o.wireType == wireVarint {
+					message.MapEntry = o.varint != 0
+				}
+			}
+		}
+	}
+
+	fullName := qualify(scope, name)
+	s.Messages[fullName] = message
+	for _, n := range nested {
+		if err := s.addMessage(fullName, n); err != nil {
+			return err
+		}
+	}
+	for _, e := range enums {
+		if err := s.addEnum(fullName, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseField(data []byte) (Field, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return Field{}, err
+	}
+
+	var field Field
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			field.Name = string(f.bytes)
+		case 3:
+			field.Number = int(f.varint)
+		case 4:
+			field.Repeated = f.varint == labelRepeated
+		case 5:
+			field.Type = FieldType(f.varint)
+		case 6:
+			field.TypeName = strings.TrimPrefix(string(f.bytes), ".")
+		case 10:
+			field.JSONName = string(f.bytes)
+		}
+	}
+	if field.JSONName == "" {
+		field.JSONName = jsonName(field.Name)
+	}
+	return field, nil
+}
+
+// jsonName converts a field name to lowerCamelCase, as protoc does for the JSON name of a field.
+func jsonName(name string) string {
+	var (
+		b     strings.Builder
+		upper bool
+	)
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *Schema) addEnum(scope string, data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+
+	var (
+		enum = &Enum{}
+		name string
+	)
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			valueFields, err := parseFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			var value EnumValue
+			for _, v := range valueFields {
+				switch v.num {
+				case 1:
+					value.Name = string(v.bytes)
+				case 2:
+					value.Number = int(int32(v.varint))
+				}
+			}
+			enum.Values = append(enum.Values, value)
+		}
+	}
+	s.Enums[qualify(scope, name)] = enum
+	return nil
+}
+
+func (s *Schema) addService(pkg string, data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+
+	var service Service
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			service.Name = qualify(pkg, string(f.bytes))
+		case 2:
+			methodFields, err := parseFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			var method Method
+			for _, m := range methodFields {
+				switch m.num {
+				case 1:
+					method.Name = string(m.bytes)
+				case 2:
+					method.Input = strings.TrimPrefix(string(m.bytes), ".")
+				case 3:
+					method.Output = strings.TrimPrefix(string(m.bytes), ".")
+				case 5:
+					method.ClientStreaming = m.varint != 0
+				case 6:
+					method.ServerStreaming = m.varint != 0
+				}
+			}
+			service.Methods = append(service.Methods, method)
+		}
+	}
+
+	for _, existing := range s.Services {
+		if existing.Name == service.Name {
+			return nil
+		}
+	}
+	s.Services = append(s.Services, service)
+	sort.Slice(s.Services, func(i, j int) bool {
+		return s.Services[i].Name < s.Services[j].Name
+	})
+	return nil
+}
+
+// Subset returns a schema with only the messages and enums used by the named messages, and no services.
+func (s *Schema) Subset(names ...string) *Schema {
+	result := NewSchema()
+
+	var add func(name string)
+	add = func(name string) {
+		if _, ok := result.Messages[name]; ok {
+			return
+		}
+		if enum, ok := s.Enums[name]; ok {
+			result.Enums[name] = enum
+			return
+		}
+		message, ok := s.Messages[name]
+		if !ok {
+			return
+		}
+		result.Messages[name] = message
+		for _, f := range message.Fields {
+			if f.TypeName != "" {
+				add(f.TypeName)
+			}
+		}
+	}
+	for _, name := range names {
+		add(name)
+	}
+	return result
+}
@@ -0,0 +1,235 @@
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func appendVarint(b []byte, num int, value uint64) []byte {
+	b = appendTag(b, num, wireVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+func fieldDescriptor(name string, number int, fieldType FieldType, typeName string, repeated bool) []byte {
+	b := appendString(nil, 1, name)
+	b = appendVarint(b, 3, uint64(number))
+	label := uint64(1)
+	if repeated {
+		label = labelRepeated
+	}
+	b = appendVarint(b, 4, label)
+	b = appendVarint(b, 5, uint64(fieldType))
+	if typeName != "" {
+		b = appendString(b, 6, "."+typeName)
+	}
+	return b
+}
+
+// greeterFile returns the FileDescriptorProto of:
+//
+//	package greeter;
+//	enum Mood { HAPPY = 0; SAD = 1; }
+//	message HelloRequest {
+//	  string name = 1;
+//	  int64 count = 2;
+//	  repeated string tags = 3;
+//	  map<string, int32> labels = 4;
+//	  Mood mood = 5;
+//	}
+//	message HelloReply { string message = 1; repeated int32 ids = 2; }
+//	service Greeter {
+//	  rpc SayHello(HelloRequest) returns (HelloReply);
+//	  rpc StreamHellos(HelloRequest) returns (stream HelloReply);
+//	  rpc Upload(stream HelloRequest) returns (HelloReply);
+//	}
+func greeterFile() []byte {
+	entry := appendString(nil, 1, "LabelsEntry")
+	entry = appendBytes(entry, 2, fieldDescriptor("key", 1, TypeString, "", false))
+	entry = appendBytes(entry, 2, fieldDescriptor("value", 2, TypeInt32, "", false))
+	entry = appendBytes(entry, 7, appendVarint(nil, 7, 1))
+
+	request := appendString(nil, 1, "HelloRequest")
+	request = appendBytes(request, 2, fieldDescriptor("name", 1, TypeString, "", false))
+	request = appendBytes(request, 2, fieldDescriptor("count", 2, TypeInt64, "", false))
+	request = appendBytes(request, 2, fieldDescriptor("tags", 3, TypeString, "", true))
+	request = appendBytes(request, 2, fieldDescriptor("labels", 4, TypeMessage, "greeter.HelloRequest.LabelsEntry", true))
+	request = appendBytes(request, 2, fieldDescriptor("mood", 5, TypeEnum, "greeter.Mood", false))
+	request = appendBytes(request, 3, entry)
+
+	reply := appendString(nil, 1, "HelloReply")
+	reply = appendBytes(reply, 2, fieldDescriptor("message", 1, TypeString, "", false))
+	reply = appendBytes(reply, 2, fieldDescriptor("ids", 2, TypeInt32, "", true))
+
+	mood := appendString(nil, 1, "Mood")
+	mood = appendBytes(mood, 2, appendVarint(appendString(nil, 1, "HAPPY"), 2, 0))
+	mood = appendBytes(mood, 2, appendVarint(appendString(nil, 1, "SAD"), 2, 1))
+
+	method := func(name string, clientStreaming, serverStreaming bool) []byte {
+		b := appendString(nil, 1, name)
+		b = appendString(b, 2, ".greeter.HelloRequest")
+		b = appendString(b, 3, ".greeter.HelloReply")
+		if clientStreaming {
+			b = appendVarint(b, 5, 1)
+		}
+		if serverStreaming {
+			b = appendVarint(b, 6, 1)
+		}
+		return b
+	}
+	service := appendString(nil, 1, "Greeter")
+	service = appendBytes(service, 2, method("SayHello", false, false))
+	service = appendBytes(service, 2, method("StreamHellos", false, true))
+	service = appendBytes(service, 2, method("Upload", true, false))
+
+	file := appendString(nil, 1, "greeter.proto")
+	file = appendString(file, 2, "greeter")
+	file = appendBytes(file, 4, request)
+	file = appendBytes(file, 4, reply)
+	file = appendBytes(file, 5, mood)
+	file = appendBytes(file, 6, service)
+	return file
+}
+
+func TestParseDescriptorSet(t *testing.T) {
+	schema, err := ParseDescriptorSet(appendBytes(nil, 1, greeterFile()))
+	require.NoError(t, err)
+
+	require.Len(t, schema.Services, 1)
+	require.Equal(t, "greeter.Greeter", schema.Services[0].Name)
+	require.Equal(t, []Method{
+		{Name: "SayHello", Input: "greeter.HelloRequest", Output: "greeter.HelloReply"},
+		{Name: "StreamHellos", Input: "greeter.HelloRequest", Output: "greeter.HelloReply", ServerStreaming: true},
+		{Name: "Upload", Input: "greeter.HelloRequest", Output: "greeter.HelloReply", ClientStreaming: true},
+	}, schema.Services[0].Methods)
+	require.True(t, schema.Messages["greeter.HelloRequest.LabelsEntry"].MapEntry)
+	require.Equal(t, []EnumValue{{Name: "HAPPY", Number: 0}, {Name: "SAD", Number: 1}}, schema.Enums["greeter.Mood"].Values)
+	require.Empty(t, schema.MissingDependencies())
+
+	subset := schema.Subset("greeter.HelloRequest")
+	require.Len(t, subset.Messages, 2)
+	require.Len(t, subset.Enums, 1)
+	require.Empty(t, subset.Services)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	schema, err := ParseDescriptorSet(appendBytes(nil, 1, greeterFile()))
+	require.NoError(t, err)
+
+	var request any
+	dec := json.NewDecoder(strings.NewReader(`{"name":"gptscript","count":9007199254740993,"tags":["a","b"],"labels":{"x":1},"mood":"SAD"}`))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&request))
+
+	data, err := schema.Marshal("greeter.HelloRequest", request)
+	require.NoError(t, err)
+
+	result, err := schema.Unmarshal("greeter.HelloRequest", data)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"name":   "gptscript",
+		"count":  "9007199254740993",
+		"tags":   []any{"a", "b"},
+		"labels": map[string]any{"x": int32(1)},
+		"mood":   "SAD",
+	}, result)
+
+	// Proto names are accepted as well as JSON names, and packed repeated fields are decoded.
+	data, err = schema.Marshal("greeter.HelloReply", map[string]any{"message": "hi", "ids": []any{json.Number("1"), json.Number("-2")}})
+	require.NoError(t, err)
+	result, err = schema.Unmarshal("greeter.HelloReply", data)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"message": "hi", "ids": []any{int32(1), int32(-2)}}, result)
+
+	_, err = schema.Marshal("greeter.HelloRequest", map[string]any{"mood": "ANGRY"})
+	require.ErrorContains(t, err, "unknown value ANGRY")
+	_, err = schema.Marshal("greeter.HelloRequest", map[string]any{"count": "many"})
+	require.ErrorContains(t, err, "must be a 64-bit integer")
+}
+
+// writeMessages writes the gRPC framing of the messages and an OK status.
+func writeMessages(w http.ResponseWriter, messages ...[]byte) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status")
+	for _, m := range messages {
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(m)))
+		_, _ = w.Write(prefix[:])
+		_, _ = w.Write(m)
+	}
+	w.Header().Set("Grpc-Status", "0")
+}
+
+func readMessage(t *testing.T, r *http.Request) []byte {
+	data, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(data), 5)
+	return data[5:]
+}
+
+func TestReflectAndCall(t *testing.T) {
+	file := greeterFile()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo":
+			request, err := parseFields(readMessage(t, r))
+			require.NoError(t, err)
+			switch request[0].num {
+			case 7:
+				services := appendBytes(nil, 1, appendString(nil, 1, "greeter.Greeter"))
+				services = appendBytes(services, 1, appendString(nil, 1, "grpc.reflection.v1alpha.ServerReflection"))
+				writeMessages(w, appendBytes(nil, 6, services))
+			case 4:
+				require.Equal(t, "greeter.Greeter", string(request[0].bytes))
+				writeMessages(w, appendBytes(nil, 4, appendBytes(nil, 1, file)))
+			}
+		case "/greeter.Greeter/SayHello":
+			require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			name, err := parseFields(readMessage(t, r))
+			require.NoError(t, err)
+			if len(name) == 0 {
+				w.Header().Set("Content-Type", "application/grpc")
+				w.Header().Set("Grpc-Status", "3")
+				w.Header().Set("Grpc-Message", "name is required")
+				return
+			}
+			writeMessages(w, appendString(nil, 1, "Hello "+string(name[0].bytes)))
+		default:
+			// Like a server without the v1 reflection service.
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "12")
+		}
+	})
+
+	s := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer s.Close()
+
+	c := NewClient(strings.TrimPrefix(s.URL, "http://"), false, http.Header{"Authorization": []string{"Bearer secret"}})
+
+	schema, err := Reflect(context.Background(), c)
+	require.NoError(t, err)
+	require.Len(t, schema.Services, 1)
+	require.Equal(t, "greeter.Greeter", schema.Services[0].Name)
+
+	request, err := schema.Marshal("greeter.HelloRequest", map[string]any{"name": "gptscript"})
+	require.NoError(t, err)
+	responses, err := c.Call(context.Background(), MethodPath("greeter.Greeter", "SayHello"), request)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+
+	reply, err := schema.Unmarshal("greeter.HelloReply", responses[0])
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"message": "Hello gptscript"}, reply)
+
+	_, err = c.Call(context.Background(), MethodPath("greeter.Greeter", "SayHello"), nil)
+	require.Equal(t, &StatusError{Code: 3, Message: "name is required"}, err)
+}
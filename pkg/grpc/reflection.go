@@ -0,0 +1,158 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var reflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Reflect reads the schema of the services of a server with server reflection. The reflection services themselves
+// are not included.
+func Reflect(ctx context.Context, c *Client) (*Schema, error) {
+	var (
+		method   string
+		services []string
+		err      error
+	)
+	for _, method = range reflectionMethods {
+		services, err = listServices(ctx, c, method)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == codeUnimplemented {
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services with server reflection: %w", err)
+	}
+
+	schema := NewSchema()
+	for _, service := range services {
+		if strings.HasPrefix(service, "grpc.reflection.") {
+			continue
+		}
+		// Field 4 is file_containing_symbol.
+		if err := addFiles(ctx, c, method, schema, appendString(nil, 4, service)); err != nil {
+			return nil, fmt.Errorf("failed to read the descriptor of %s: %w", service, err)
+		}
+	}
+
+	// Servers usually send the dependencies of a file with it, but can leave them to be requested by name.
+	for i := 0; i < 10; i++ {
+		missing := schema.MissingDependencies()
+		if len(missing) == 0 {
+			break
+		}
+		for _, name := range missing {
+			// Field 3 is file_by_filename.
+			if err := addFiles(ctx, c, method, schema, appendString(nil, 3, name)); err != nil {
+				return nil, fmt.Errorf("failed to read the descriptor of %s: %w", name, err)
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// reflect sends a ServerReflectionRequest and returns the ServerReflectionResponse.
+func reflect(ctx context.Context, c *Client, method string, request []byte) ([]rawField, error) {
+	responses, err := c.Call(ctx, method, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no response from server reflection")
+	}
+
+	fields, err := parseFields(responses[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		// Field 7 is error_response, with the error_code in field 1 and the error_message in field 2.
+		if f.num == 7 && f.wireType == wireBytes {
+			errFields, err := parseFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			statusErr := &StatusError{}
+			for _, e := range errFields {
+				switch e.num {
+				case 1:
+					statusErr.Code = int(e.varint)
+				case 2:
+					statusErr.Message = string(e.bytes)
+				}
+			}
+			return nil, statusErr
+		}
+	}
+	return fields, nil
+}
+
+func listServices(ctx context.Context, c *Client, method string) ([]string, error) {
+	// Field 7 is list_services.
+	fields, err := reflect(ctx, c, method, appendString(nil, 7, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	for _, f := range fields {
+		// Field 6 is list_services_response, with each service in field 1 and its name in field 1.
+		if f.num != 6 || f.wireType != wireBytes {
+			continue
+		}
+		list, err := parseFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range list {
+			if service.num != 1 || service.wireType != wireBytes {
+				continue
+			}
+			serviceFields, err := parseFields(service.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range serviceFields {
+				if name.num == 1 {
+					services = append(services, string(name.bytes))
+				}
+			}
+		}
+	}
+	return services, nil
+}
+
+func addFiles(ctx context.Context, c *Client, method string, schema *Schema, request []byte) error {
+	fields, err := reflect(ctx, c, method, request)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		// Field 4 is file_descriptor_response, with the encoded files in field 1.
+		if f.num != 4 || f.wireType != wireBytes {
+			continue
+		}
+		files, err := parseFields(f.bytes)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.num == 1 && file.wireType == wireBytes {
+				if err := schema.AddFile(file.bytes); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxResponseSize is the largest response that is read from the API server.
+const maxResponseSize = 32 << 20
+
+// Client makes requests to the API server of a cluster.
+type Client struct {
+	cfg    *Config
+	client *http.Client
+	token  string
+
+	// resources is the resources of the API server, read on first use.
+	resources []Resource
+}
+
+// APIError is an error response from the API server, such as a resource that was not found or forbidden.
+type APIError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%d %s", e.Code, e.Reason)
+}
+
+func NewClient(ctx context.Context, cfg *Config) (*Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, fmt.Errorf("invalid certificate authority of %s", cfg.Server)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	token, err := cfg.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		cfg:   cfg,
+		token: token,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Minute,
+		},
+	}, nil
+}
+
+// Namespace returns the default namespace of the client.
+func (c *Client) Namespace() string {
+	return c.cfg.Namespace
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	u := strings.TrimSuffix(c.cfg.Server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{
+			Code:   resp.StatusCode,
+			Reason: http.StatusText(resp.StatusCode),
+		}
+		// Errors are a Status object, with a message like kubectl shows.
+		var status struct {
+			Message string `json:"message"`
+			Reason  string `json:"reason"`
+		}
+		if json.Unmarshal(data, &status) == nil {
+			apiErr.Message = status.Message
+			if status.Reason != "" {
+				apiErr.Reason = status.Reason
+			}
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	data, err := c.do(ctx, http.MethodGet, path, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,280 @@
+// Package k8s is a small client for the Kubernetes API, with the operations of the sys.k8s tools. It authenticates
+// with a kubeconfig file or, when running in a pod, with the service account of the pod.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config is how to connect to the API server of a cluster.
+type Config struct {
+	Server string
+	// Namespace is the default namespace, from the context of the kubeconfig or the namespace of the pod.
+	Namespace string
+
+	CAData             []byte
+	InsecureSkipVerify bool
+
+	Token      string
+	TokenFile  string
+	ClientCert []byte
+	ClientKey  []byte
+	Username   string
+	Password   string
+	Exec       *ExecConfig
+}
+
+// ExecConfig is a command that prints an ExecCredential, which is how kubeconfigs for cloud providers get tokens.
+type ExecConfig struct {
+	Command string       `json:"command"`
+	Args    []string     `json:"args,omitempty"`
+	Env     []ExecEnvVar `json:"env,omitempty"`
+}
+
+type ExecEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string      `json:"token"`
+			TokenFile             string      `json:"tokenFile"`
+			ClientCertificate     string      `json:"client-certificate"`
+			ClientCertificateData string      `json:"client-certificate-data"`
+			ClientKey             string      `json:"client-key"`
+			ClientKeyData         string      `json:"client-key-data"`
+			Username              string      `json:"username"`
+			Password              string      `json:"password"`
+			Exec                  *ExecConfig `json:"exec"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// LoadConfig finds the configuration of the cluster the same way kubectl does. The kubeconfig is the first file
+// in KUBECONFIG, or ~/.kube/config, and kubeContext is the context to use, or the current context if it is empty.
+// Without a kubeconfig, the service account of the pod is used when running in a cluster.
+func LoadConfig(kubeconfigPath, kubeContext string) (*Config, error) {
+	if kubeconfigPath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if _, err := os.Stat(filepath.Join(home, ".kube", "config")); err == nil {
+				kubeconfigPath = filepath.Join(home, ".kube", "config")
+			}
+		}
+	}
+
+	for _, p := range filepath.SplitList(kubeconfigPath) {
+		if _, err := os.Stat(p); err == nil {
+			return LoadKubeconfig(p, kubeContext)
+		}
+	}
+
+	if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" && port != "" {
+		return inClusterConfig(host, port)
+	}
+
+	return nil, fmt.Errorf("no kubeconfig found, set KUBECONFIG or run in a cluster")
+}
+
+// LoadKubeconfig reads the cluster and user of a context of a kubeconfig file.
+func LoadKubeconfig(path, kubeContext string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+
+	if kubeContext == "" {
+		kubeContext = kc.CurrentContext
+	}
+	if kubeContext == "" {
+		return nil, fmt.Errorf("no current context in kubeconfig %s", path)
+	}
+
+	// Relative paths in a kubeconfig are relative to the kubeconfig.
+	dir := filepath.Dir(path)
+	readFile := func(name string) ([]byte, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return os.ReadFile(name)
+	}
+
+	cfg := &Config{}
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == kubeContext {
+			clusterName, userName, cfg.Namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+			break
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("context %s not found in kubeconfig %s", kubeContext, path)
+	}
+
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cfg.Server = c.Cluster.Server
+		cfg.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		if c.Cluster.CertificateAuthorityData != "" {
+			if cfg.CAData, err = base64.StdEncoding.DecodeString(c.Cluster.CertificateAuthorityData); err != nil {
+				return nil, fmt.Errorf("invalid certificate-authority-data of cluster %s: %w", clusterName, err)
+			}
+		} else if c.Cluster.CertificateAuthority != "" {
+			if cfg.CAData, err = readFile(c.Cluster.CertificateAuthority); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("cluster %s not found in kubeconfig %s", clusterName, path)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.Token = u.User.Token
+		cfg.Username = u.User.Username
+		cfg.Password = u.User.Password
+		cfg.Exec = u.User.Exec
+		if u.User.TokenFile != "" {
+			cfg.TokenFile = u.User.TokenFile
+			if !filepath.IsAbs(cfg.TokenFile) {
+				cfg.TokenFile = filepath.Join(dir, cfg.TokenFile)
+			}
+		}
+		if u.User.ClientCertificateData != "" {
+			if cfg.ClientCert, err = base64.StdEncoding.DecodeString(u.User.ClientCertificateData); err != nil {
+				return nil, fmt.Errorf("invalid client-certificate-data of user %s: %w", userName, err)
+			}
+		} else if u.User.ClientCertificate != "" {
+			if cfg.ClientCert, err = readFile(u.User.ClientCertificate); err != nil {
+				return nil, err
+			}
+		}
+		if u.User.ClientKeyData != "" {
+			if cfg.ClientKey, err = base64.StdEncoding.DecodeString(u.User.ClientKeyData); err != nil {
+				return nil, fmt.Errorf("invalid client-key-data of user %s: %w", userName, err)
+			}
+		} else if u.User.ClientKey != "" {
+			if cfg.ClientKey, err = readFile(u.User.ClientKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+	return cfg, nil
+}
+
+func inClusterConfig(host, port string) (*Config, error) {
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the service account of the pod: %w", err)
+	}
+
+	namespace := "default"
+	if data, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	if strings.Contains(host, ":") {
+		// IPv6
+		host = "[" + host + "]"
+	}
+
+	return &Config{
+		Server:    "https://" + host + ":" + port,
+		Namespace: namespace,
+		CAData:    ca,
+		// The token is rotated, so it is read for each request.
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+	}, nil
+}
+
+// token returns the bearer token of the user, if it has one.
+func (c *Config) token(ctx context.Context) (string, error) {
+	switch {
+	case c.Token != "":
+		return c.Token, nil
+	case c.TokenFile != "":
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	case c.Exec != nil:
+		return c.execToken(ctx)
+	}
+	return "", nil
+}
+
+func (c *Config) execToken(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, c.Exec.Command, c.Exec.Args...)
+	cmd.Env = os.Environ()
+	for _, e := range c.Exec.Env {
+		cmd.Env = append(cmd.Env, e.Name+"="+e.Value)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to get credentials from %s: %w: %s", c.Exec.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var credential struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &credential); err != nil {
+		return "", fmt.Errorf("invalid credentials from %s: %w", c.Exec.Command, err)
+	}
+	if credential.Status.Token == "" {
+		return "", errors.New("no token in the credentials from " + c.Exec.Command)
+	}
+	return credential.Status.Token, nil
+}
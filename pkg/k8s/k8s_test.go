@@ -0,0 +1,231 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	write := func(w http.ResponseWriter, obj any) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(obj))
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /apis":
+			write(w, map[string]any{"groups": []any{
+				map[string]any{"preferredVersion": map[string]any{"groupVersion": "apps/v1"}},
+				map[string]any{"preferredVersion": map[string]any{"groupVersion": "metrics.k8s.io/v1beta1"}},
+			}})
+		case "GET /api/v1":
+			write(w, map[string]any{"groupVersion": "v1", "resources": []any{
+				map[string]any{"name": "pods", "singularName": "pod", "kind": "Pod", "namespaced": true, "shortNames": []string{"po"}},
+				map[string]any{"name": "pods/log", "kind": "Pod", "namespaced": true},
+				map[string]any{"name": "secrets", "singularName": "secret", "kind": "Secret", "namespaced": true},
+				map[string]any{"name": "namespaces", "singularName": "namespace", "kind": "Namespace", "namespaced": false, "shortNames": []string{"ns"}},
+			}})
+		case "GET /apis/apps/v1":
+			write(w, map[string]any{"groupVersion": "apps/v1", "resources": []any{
+				map[string]any{"name": "deployments", "singularName": "deployment", "kind": "Deployment", "namespaced": true, "shortNames": []string{"deploy"}},
+			}})
+		case "GET /apis/metrics.k8s.io/v1beta1":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "GET /api/v1/namespaces/dev/pods":
+			require.Contains(t, r.Header.Get("Accept"), "as=Table")
+			require.Equal(t, "app=web", r.URL.Query().Get("labelSelector"))
+			write(w, map[string]any{
+				"kind": "Table",
+				"columnDefinitions": []any{
+					map[string]any{"name": "Name", "priority": 0},
+					map[string]any{"name": "Status", "priority": 0},
+					map[string]any{"name": "IP", "priority": 1},
+				},
+				"rows": []any{
+					map[string]any{"cells": []any{"web-1", "Running", "10.0.0.1"}, "object": map[string]any{"metadata": map[string]any{"namespace": "dev"}}},
+				},
+			})
+		case "GET /api/v1/namespaces/dev/pods/web-1":
+			write(w, map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": "web-1", "namespace": "dev", "uid": "1"}})
+		case "GET /api/v1/namespaces/dev/events":
+			require.Equal(t, "involvedObject.name=web-1,involvedObject.kind=Pod", r.URL.Query().Get("fieldSelector"))
+			write(w, map[string]any{"items": []any{
+				map[string]any{"type": "Warning", "reason": "BackOff", "message": "Back-off restarting failed container", "count": 3, "involvedObject": map[string]any{"uid": "1"}},
+				map[string]any{"type": "Normal", "reason": "Scheduled", "message": "an earlier pod", "involvedObject": map[string]any{"uid": "0"}},
+			}})
+		case "GET /api/v1/namespaces/dev/pods/web-1/log":
+			require.Equal(t, "app", r.URL.Query().Get("container"))
+			require.Equal(t, "10", r.URL.Query().Get("tailLines"))
+			_, _ = io.WriteString(w, "listening on :8080\n")
+		case "GET /apis/apps/v1/namespaces/dev/deployments/web":
+			write(w, map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "web", "resourceVersion": "1"}, "spec": map[string]any{"replicas": 1}})
+		case "GET /api/v1/namespaces/other", "GET /api/v1/namespaces/dev/pods/missing":
+			w.WriteHeader(http.StatusNotFound)
+			write(w, map[string]any{"kind": "Status", "reason": "NotFound", "message": fmt.Sprintf("%s not found", r.URL.Path)})
+		case "PATCH /apis/apps/v1/namespaces/dev/deployments/web", "PATCH /api/v1/namespaces/other":
+			require.Equal(t, "application/apply-patch+yaml", r.Header.Get("Content-Type"))
+			require.Equal(t, FieldManager, r.URL.Query().Get("fieldManager"))
+			require.Equal(t, "All", r.URL.Query().Get("dryRun"))
+			var obj map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&obj))
+			obj["metadata"].(map[string]any)["resourceVersion"] = "2"
+			write(w, obj)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(t *testing.T) *Client {
+	s := newTestServer(t)
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: other
+  context:
+    cluster: test
+    user: test
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: dev
+users:
+- name: test
+  user:
+    token: secret
+`, s.URL)), 0600))
+
+	cfg, err := LoadConfig(kubeconfig, "")
+	require.NoError(t, err)
+	require.Equal(t, "dev", cfg.Namespace)
+
+	other, err := LoadConfig(kubeconfig, "other")
+	require.NoError(t, err)
+	require.Equal(t, "default", other.Namespace)
+
+	_, err = LoadConfig(kubeconfig, "missing")
+	require.ErrorContains(t, err, "context missing not found")
+
+	c, err := NewClient(context.Background(), cfg)
+	require.NoError(t, err)
+	return c
+}
+
+func TestFindResource(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for _, kind := range []string{"Deployment", "deployments", "deploy", "deployments.apps"} {
+		r, err := c.FindResource(ctx, kind, "")
+		require.NoError(t, err, kind)
+		require.Equal(t, "apps/v1", r.APIVersion())
+		require.Equal(t, "deployment.apps", r.String())
+	}
+
+	r, err := c.FindResource(ctx, "po", "")
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/namespaces/dev/pods/web-1", r.path("dev", "web-1"))
+
+	r, err = c.FindResource(ctx, "Namespace", "v1")
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/namespaces/dev", r.path("ignored", "dev"))
+
+	_, err = c.FindResource(ctx, "pods/log", "")
+	require.ErrorContains(t, err, "the server does not have a resource type pods/log")
+	_, err = c.FindResource(ctx, "Deployment", "v1")
+	require.ErrorContains(t, err, "the server does not have kind Deployment in v1")
+}
+
+func TestGetListDescribeLogs(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	// Secrets are not read, even redacted.
+	_, err := c.Get(ctx, "secret", "", "db")
+	require.ErrorIs(t, err, ErrSecret)
+	_, err = c.Describe(ctx, "secrets", "dev", "db")
+	require.ErrorIs(t, err, ErrSecret)
+
+	_, err = c.Get(ctx, "pod", "", "missing")
+	require.Equal(t, &APIError{Code: http.StatusNotFound, Reason: "NotFound", Message: "/api/v1/namespaces/dev/pods/missing not found"}, err)
+
+	list, err := c.List(ctx, "pods", "", false, "app=web")
+	require.NoError(t, err)
+	require.Equal(t, "NAME    STATUS\nweb-1   Running\n", list)
+
+	description, err := c.Describe(ctx, "pod", "dev", "web-1")
+	require.NoError(t, err)
+	require.Contains(t, description, "name: web-1")
+	require.Contains(t, description, "\nEvents:\nLAST SEEN   TYPE      REASON    COUNT   MESSAGE\n")
+	require.Contains(t, description, "Back-off restarting failed container")
+	require.NotContains(t, description, "an earlier pod")
+
+	logs, err := c.Logs(ctx, "", "web-1", LogOptions{Container: "app", TailLines: 10})
+	require.NoError(t, err)
+	require.Equal(t, "listening on :8080\n", logs)
+}
+
+func TestApply(t *testing.T) {
+	c := newTestClient(t)
+
+	results, err := c.Apply(context.Background(), `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: other
+`, "", true)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "deployment.apps/web configured", results[0].String())
+	require.Equal(t, "dev", results[0].Namespace)
+	require.Equal(t, "namespace/other created", results[1].String())
+
+	_, err = c.Apply(context.Background(), "kind: Deployment", "", true)
+	require.ErrorContains(t, err, "objects must have an apiVersion, kind, and metadata.name")
+}
+
+func TestSplitManifest(t *testing.T) {
+	objects, err := splitManifest(`---
+apiVersion: v1
+kind: List
+items:
+- kind: ConfigMap
+- kind: Secret
+---
+# only a comment
+---
+{"kind": "Service"}
+`)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{{"kind": "ConfigMap"}, {"kind": "Secret"}, {"kind": "Service"}}, objects)
+}
@@ -0,0 +1,5 @@
+package k8s
+
+import "github.com/gptscript-ai/gptscript/pkg/mvl"
+
+var log = mvl.Package()
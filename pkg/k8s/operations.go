@@ -0,0 +1,511 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FieldManager is the owner of the fields set by apply.
+	FieldManager = "gptscript"
+	// maxListItems is the most objects that are listed at once.
+	maxListItems = 500
+	// maxLogBytes is the most of the end of a log that is read.
+	maxLogBytes = 1 << 20
+)
+
+// ErrSecret is returned for the secrets that Get and Describe are asked for. The tools that call them don't ask for
+// confirmation, so they don't read secrets, whose values and annotations would be sent to the model.
+var ErrSecret = errors.New("secrets can not be read, only the names of secrets can be listed")
+
+// Get returns an object as YAML.
+func (c *Client) Get(ctx context.Context, kind, namespace, name string) (string, error) {
+	r, err := c.FindResource(ctx, kind, "")
+	if err != nil {
+		return "", err
+	} else if r.isSecret() {
+		return "", ErrSecret
+	}
+
+	obj, err := c.get(ctx, r, c.namespace(namespace), name)
+	if err != nil {
+		return "", err
+	}
+	return formatObject(obj)
+}
+
+func (c *Client) get(ctx context.Context, r Resource, namespace, name string) (map[string]any, error) {
+	var obj map[string]any
+	if err := c.getJSON(ctx, r.path(namespace, name), nil, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c *Client) namespace(namespace string) string {
+	if namespace == "" {
+		return c.cfg.Namespace
+	}
+	return namespace
+}
+
+// List returns a table of the objects of a type, with the columns kubectl get shows for them. The objects are in
+// the namespace, or all namespaces if allNamespaces is set, and match the label selector if it is set.
+func (c *Client) List(ctx context.Context, kind, namespace string, allNamespaces bool, labelSelector string) (string, error) {
+	r, err := c.FindResource(ctx, kind, "")
+	if err != nil {
+		return "", err
+	}
+
+	if allNamespaces || !r.Namespaced {
+		namespace = ""
+	} else {
+		namespace = c.namespace(namespace)
+	}
+
+	query := url.Values{
+		"limit": []string{strconv.Itoa(maxListItems)},
+	}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+
+	// The API server prints the same table as kubectl get, for any type of object.
+	data, err := c.do(ctx, http.MethodGet, r.path(namespace, ""), query, http.Header{
+		"Accept": []string{"application/json;as=Table;v=v1;g=meta.k8s.io,application/json"},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var table struct {
+		Kind              string `json:"kind"`
+		ColumnDefinitions []struct {
+			Name     string `json:"name"`
+			Priority int    `json:"priority"`
+		} `json:"columnDefinitions"`
+		Rows []struct {
+			Cells  []any `json:"cells"`
+			Object struct {
+				Metadata metadata `json:"metadata"`
+			} `json:"object"`
+		} `json:"rows"`
+		Items []struct {
+			Metadata metadata `json:"metadata"`
+		} `json:"items"`
+		Metadata struct {
+			Continue string `json:"continue"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return "", err
+	}
+
+	var (
+		buf     bytes.Buffer
+		w       = tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+		showNS  = r.Namespaced && namespace == ""
+		headers []string
+		rows    int
+	)
+	if showNS {
+		headers = append(headers, "NAMESPACE")
+	}
+
+	if table.Kind == "Table" {
+		var columns []int
+		for i, col := range table.ColumnDefinitions {
+			if col.Priority == 0 {
+				columns = append(columns, i)
+				headers = append(headers, strings.ToUpper(col.Name))
+			}
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, row := range table.Rows {
+			var cells []string
+			if showNS {
+				cells = append(cells, row.Object.Metadata.Namespace)
+			}
+			for _, i := range columns {
+				if i < len(row.Cells) {
+					cells = append(cells, fmt.Sprint(row.Cells[i]))
+				}
+			}
+			_, _ = fmt.Fprintln(w, strings.Join(cells, "\t"))
+			rows++
+		}
+	} else {
+		// Servers that do not print tables return the list of objects.
+		_, _ = fmt.Fprintln(w, strings.Join(append(headers, "NAME", "AGE"), "\t"))
+		for _, item := range table.Items {
+			var cells []string
+			if showNS {
+				cells = append(cells, item.Metadata.Namespace)
+			}
+			cells = append(cells, item.Metadata.Name, age(item.Metadata.CreationTimestamp))
+			_, _ = fmt.Fprintln(w, strings.Join(cells, "\t"))
+			rows++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	if rows == 0 {
+		if namespace == "" {
+			return fmt.Sprintf("No %s found", r.Name), nil
+		}
+		return fmt.Sprintf("No %s found in %s namespace", r.Name, namespace), nil
+	}
+	if table.Metadata.Continue != "" {
+		_, _ = fmt.Fprintf(&buf, "(only the first %d %s are listed)\n", maxListItems, r.Name)
+	}
+	return buf.String(), nil
+}
+
+type metadata struct {
+	Name              string    `json:"name"`
+	Namespace         string    `json:"namespace"`
+	CreationTimestamp time.Time `json:"creationTimestamp"`
+	UID               string    `json:"uid"`
+}
+
+// age returns how long ago a time was, as kubectl shows it.
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// Describe returns an object as YAML, followed by the events of the object.
+func (c *Client) Describe(ctx context.Context, kind, namespace, name string) (string, error) {
+	r, err := c.FindResource(ctx, kind, "")
+	if err != nil {
+		return "", err
+	} else if r.isSecret() {
+		return "", ErrSecret
+	}
+
+	namespace = c.namespace(namespace)
+	obj, err := c.get(ctx, r, namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := formatObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	eventsPath := "/api/v1/events"
+	if r.Namespaced {
+		eventsPath = "/api/v1/namespaces/" + url.PathEscape(namespace) + "/events"
+	}
+	var events struct {
+		Items []struct {
+			Type           string    `json:"type"`
+			Reason         string    `json:"reason"`
+			Message        string    `json:"message"`
+			Count          int       `json:"count"`
+			LastTimestamp  time.Time `json:"lastTimestamp"`
+			EventTime      time.Time `json:"eventTime"`
+			InvolvedObject struct {
+				UID string `json:"uid"`
+			} `json:"involvedObject"`
+		} `json:"items"`
+	}
+	if err := c.getJSON(ctx, eventsPath, url.Values{
+		"fieldSelector": []string{"involvedObject.name=" + name + ",involvedObject.kind=" + r.Kind},
+	}, &events); err != nil {
+		// The object is still described to users that can not list events.
+		log.Debugf("failed to list events of %s/%s: %v", r, name, err)
+		return result + "\nEvents: <unavailable>\n", nil
+	}
+
+	uid, _ := obj["metadata"].(map[string]any)["uid"].(string)
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(events.Items[i].LastTimestamp, events.Items[i].EventTime).Before(eventTime(events.Items[j].LastTimestamp, events.Items[j].EventTime))
+	})
+
+	var (
+		buf   bytes.Buffer
+		w     = tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+		count int
+	)
+	for _, e := range events.Items {
+		// Events of an earlier object with the same name are not about this one.
+		if uid != "" && e.InvolvedObject.UID != "" && e.InvolvedObject.UID != uid {
+			continue
+		}
+		if count == 0 {
+			_, _ = fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tCOUNT\tMESSAGE")
+		}
+		count++
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", age(eventTime(e.LastTimestamp, e.EventTime)), e.Type, e.Reason, max(e.Count, 1), strings.TrimSpace(e.Message))
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return result + "\nEvents: <none>\n", nil
+	}
+	return result + "\nEvents:\n" + buf.String(), nil
+}
+
+func eventTime(lastTimestamp, eventTime time.Time) time.Time {
+	if lastTimestamp.IsZero() {
+		return eventTime
+	}
+	return lastTimestamp
+}
+
+// LogOptions selects the part of the log of a pod to read.
+type LogOptions struct {
+	Container string
+	TailLines int
+	Previous  bool
+}
+
+// Logs returns the end of the log of a container of a pod.
+func (c *Client) Logs(ctx context.Context, namespace, pod string, opts LogOptions) (string, error) {
+	query := url.Values{
+		"limitBytes": []string{strconv.Itoa(maxLogBytes)},
+	}
+	if opts.Container != "" {
+		query.Set("container", opts.Container)
+	}
+	if opts.TailLines > 0 {
+		query.Set("tailLines", strconv.Itoa(opts.TailLines))
+	}
+	if opts.Previous {
+		query.Set("previous", "true")
+	}
+
+	path := "/api/v1/namespaces/" + url.PathEscape(c.namespace(namespace)) + "/pods/" + url.PathEscape(pod) + "/log"
+	data, err := c.do(ctx, http.MethodGet, path, query, http.Header{
+		"Accept": []string{"*/*"},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ApplyResult is the outcome of applying one object of a manifest.
+type ApplyResult struct {
+	Resource  Resource
+	Namespace string
+	Name      string
+	// Action is created, configured, or unchanged.
+	Action string
+}
+
+func (r ApplyResult) String() string {
+	return r.Resource.String() + "/" + r.Name + " " + r.Action
+}
+
+// Apply creates or updates the objects of a YAML manifest with server-side apply, as kubectl apply --server-side
+// does. Objects without a namespace are applied in the namespace, or the default one if it is empty. With dryRun
+// set, the API server validates and admits the objects without saving them.
+func (c *Client) Apply(ctx context.Context, manifest, namespace string, dryRun bool) ([]ApplyResult, error) {
+	objects, err := splitManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects found in manifest")
+	}
+
+	var results []ApplyResult
+	for _, obj := range objects {
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		meta, _ := obj["metadata"].(map[string]any)
+		name, _ := meta["name"].(string)
+		if apiVersion == "" || kind == "" || name == "" {
+			return results, fmt.Errorf("objects must have an apiVersion, kind, and metadata.name")
+		}
+
+		r, err := c.FindResource(ctx, kind, apiVersion)
+		if err != nil {
+			return results, err
+		}
+
+		objNamespace := ""
+		if r.Namespaced {
+			objNamespace, _ = meta["namespace"].(string)
+			if objNamespace == "" {
+				objNamespace = c.namespace(namespace)
+				meta["namespace"] = objNamespace
+			}
+		} else {
+			delete(meta, "namespace")
+		}
+
+		existing, err := c.get(ctx, r, objNamespace, name)
+		if apiErr := (*APIError)(nil); errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			existing = nil
+		} else if err != nil {
+			return results, fmt.Errorf("failed to get %s/%s: %w", r, name, err)
+		}
+
+		body, err := json.Marshal(obj)
+		if err != nil {
+			return results, err
+		}
+
+		query := url.Values{
+			"fieldManager": []string{FieldManager},
+		}
+		if dryRun {
+			query.Set("dryRun", "All")
+		}
+		// JSON is YAML, which is the content type of server-side apply.
+		data, err := c.do(ctx, http.MethodPatch, r.path(objNamespace, name), query, http.Header{
+			"Content-Type": []string{"application/apply-patch+yaml"},
+		}, body)
+		if err != nil {
+			return results, fmt.Errorf("failed to apply %s/%s: %w", r, name, err)
+		}
+
+		var applied map[string]any
+		if err := json.Unmarshal(data, &applied); err != nil {
+			return results, err
+		}
+
+		result := ApplyResult{
+			Resource:  r,
+			Namespace: objNamespace,
+			Name:      name,
+			Action:    "configured",
+		}
+		if existing == nil {
+			result.Action = "created"
+		} else if reflect.DeepEqual(withoutVolatileFields(existing), withoutVolatileFields(applied)) {
+			result.Action = "unchanged"
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// withoutVolatileFields returns an object without the fields that the API server changes on every update.
+func withoutVolatileFields(obj map[string]any) map[string]any {
+	result := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if k != "status" {
+			result[k] = v
+		}
+	}
+	if meta, ok := obj["metadata"].(map[string]any); ok {
+		m := make(map[string]any, len(meta))
+		for k, v := range meta {
+			switch k {
+			case "managedFields", "resourceVersion", "generation":
+			default:
+				m[k] = v
+			}
+		}
+		result["metadata"] = m
+	}
+	return result
+}
+
+// splitManifest returns the objects of a YAML or JSON manifest, which can have several documents and lists of
+// objects.
+func splitManifest(manifest string) ([]map[string]any, error) {
+	var (
+		docs    []string
+		current strings.Builder
+		scanner = bufio.NewScanner(strings.NewReader(manifest))
+	)
+	scanner.Buffer(nil, len(manifest)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimRight(line, " \t") == "---" || strings.HasPrefix(line, "--- ") {
+			docs = append(docs, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	docs = append(docs, current.String())
+
+	var objects []map[string]any
+	for i, doc := range docs {
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("invalid document %d of manifest: %w", i+1, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		if items, ok := obj["items"].([]any); ok && strings.HasSuffix(fmt.Sprint(obj["kind"]), "List") {
+			for _, item := range items {
+				if itemObj, ok := item.(map[string]any); ok {
+					objects = append(objects, itemObj)
+				}
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// formatObject returns an object as YAML, without the fields that are only noise to a reader. The values of
+// secrets are redacted.
+func formatObject(obj map[string]any) (string, error) {
+	if meta, ok := obj["metadata"].(map[string]any); ok {
+		delete(meta, "managedFields")
+		if annotations, ok := meta["annotations"].(map[string]any); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			if len(annotations) == 0 {
+				delete(meta, "annotations")
+			}
+		}
+	}
+	if obj["kind"] == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := obj[field].(map[string]any); ok {
+				for k := range data {
+					data[k] = "<redacted>"
+				}
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Resource is a type of object that the API server serves, such as deployments in the apps/v1 API group.
+type Resource struct {
+	Group        string   `json:"group,omitempty"`
+	Version      string   `json:"version"`
+	Name         string   `json:"name"`
+	SingularName string   `json:"singularName,omitempty"`
+	Kind         string   `json:"kind"`
+	Namespaced   bool     `json:"namespaced"`
+	ShortNames   []string `json:"shortNames,omitempty"`
+	Verbs        []string `json:"verbs,omitempty"`
+}
+
+func (r Resource) isSecret() bool {
+	return r.Group == "" && r.Kind == "Secret"
+}
+
+// APIVersion returns the apiVersion of the objects of the resource, such as apps/v1.
+func (r Resource) APIVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+// String returns the name of the resource as kubectl prints it, such as deployment.apps.
+func (r Resource) String() string {
+	name := r.SingularName
+	if name == "" {
+		name = strings.ToLower(r.Kind)
+	}
+	if r.Group == "" {
+		return name
+	}
+	return name + "." + r.Group
+}
+
+// path returns the API path of the resource, or of one object of it if the name is set.
+func (r Resource) path(namespace, name string) string {
+	p := "/apis/" + r.Group + "/" + r.Version
+	if r.Group == "" {
+		p = "/api/" + r.Version
+	}
+	if r.Namespaced && namespace != "" {
+		p += "/namespaces/" + url.PathEscape(namespace)
+	}
+	p += "/" + r.Name
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+type apiResourceList struct {
+	GroupVersion string     `json:"groupVersion"`
+	Resources    []Resource `json:"resources"`
+}
+
+// Resources returns the resources of the API server, with the core resources first and then those of each API
+// group in the preferred version of the group.
+func (c *Client) Resources(ctx context.Context) ([]Resource, error) {
+	if c.resources != nil {
+		return c.resources, nil
+	}
+
+	groupVersions := []string{"v1"}
+	var groups struct {
+		Groups []struct {
+			PreferredVersion struct {
+				GroupVersion string `json:"groupVersion"`
+			} `json:"preferredVersion"`
+		} `json:"groups"`
+	}
+	if err := c.getJSON(ctx, "/apis", nil, &groups); err != nil {
+		return nil, fmt.Errorf("failed to list API groups: %w", err)
+	}
+	for _, g := range groups.Groups {
+		groupVersions = append(groupVersions, g.PreferredVersion.GroupVersion)
+	}
+
+	var resources []Resource
+	for _, gv := range groupVersions {
+		path := "/apis/" + gv
+		if gv == "v1" {
+			path = "/api/v1"
+		}
+
+		var list apiResourceList
+		if err := c.getJSON(ctx, path, nil, &list); err != nil {
+			if gv == "v1" {
+				return nil, fmt.Errorf("failed to list API resources: %w", err)
+			}
+			// Aggregated APIs, such as metrics, can be unavailable without the rest of the cluster being so.
+			log.Debugf("failed to list API resources of %s: %v", gv, err)
+			continue
+		}
+
+		group, version, ok := strings.Cut(gv, "/")
+		if !ok {
+			group, version = "", gv
+		}
+		for _, r := range list.Resources {
+			// Subresources, such as pods/log, are not resources of their own.
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			r.Group, r.Version = group, version
+			resources = append(resources, r)
+		}
+	}
+
+	c.resources = resources
+	return resources, nil
+}
+
+// FindResource returns the resource of a type as kubectl accepts it: a kind, plural, singular, or short name, such
+// as Deployment, deployments, or deploy, optionally qualified with the API group, such as deployments.apps. If the
+// apiVersion is set, as it is for manifests, the resource is in that group and version.
+func (c *Client) FindResource(ctx context.Context, kind, apiVersion string) (Resource, error) {
+	resources, err := c.Resources(ctx)
+	if err != nil {
+		return Resource{}, err
+	}
+
+	name, group, qualified := strings.Cut(kind, ".")
+	var version string
+	if apiVersion != "" {
+		qualified = true
+		if group, version, _ = strings.Cut(apiVersion, "/"); version == "" {
+			group, version = "", apiVersion
+		}
+	}
+
+	for _, r := range resources {
+		if qualified && r.Group != group {
+			continue
+		}
+		if strings.EqualFold(r.Kind, name) || strings.EqualFold(r.Name, name) || strings.EqualFold(r.SingularName, name) ||
+			slices.ContainsFunc(r.ShortNames, func(s string) bool { return strings.EqualFold(s, name) }) {
+			if version != "" {
+				r.Version = version
+			}
+			return r, nil
+		}
+	}
+
+	if apiVersion != "" {
+		return Resource{}, fmt.Errorf("the server does not have kind %s in %s", kind, apiVersion)
+	}
+	return Resource{}, fmt.Errorf("the server does not have a resource type %s", kind)
+}
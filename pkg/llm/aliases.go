@@ -0,0 +1,24 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseModelAliases parses aliases given as alias=model, such as gpt-4o=prod-gpt-4o or
+// claude=claude-3-5-sonnet-20240620 from github.com/gptscript-ai/claude3-anthropic-provider.
+func ParseModelAliases(aliases []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, alias := range aliases {
+		if strings.TrimSpace(alias) == "" {
+			continue
+		}
+		name, model, ok := strings.Cut(alias, "=")
+		name, model = strings.TrimSpace(name), strings.TrimSpace(model)
+		if !ok || name == "" || model == "" {
+			return nil, fmt.Errorf("invalid model alias %q, must be <alias>=<model>", alias)
+		}
+		result[name] = model
+	}
+	return result, nil
+}
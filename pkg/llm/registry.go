@@ -14,10 +14,15 @@ type Client interface {
 	Call(ctx context.Context, messageRequest types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error)
 	ListModels(ctx context.Context, providers ...string) (result []string, _ error)
 	Supports(ctx context.Context, modelName string) (bool, error)
+	// Embeddings returns the embeddings of the inputs, in the same order. An empty model is the default embedding model
+	// of the provider.
+	Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error)
 }
 
 type Registry struct {
 	clients []Client
+	// aliases map the names of models in scripts to the names that their providers know them by.
+	aliases map[string]string
 }
 
 func NewRegistry() *Registry {
@@ -29,6 +34,17 @@ func (r *Registry) AddClient(client Client) error {
 	return nil
 }
 
+// AddModelAliases adds aliases of models, which replace those of the same name that were added before. The model of an
+// alias can be that of any provider, such as the name of an Azure OpenAI deployment or a model from a remote provider.
+func (r *Registry) AddModelAliases(aliases map[string]string) {
+	if r.aliases == nil {
+		r.aliases = map[string]string{}
+	}
+	for alias, model := range aliases {
+		r.aliases[alias] = model
+	}
+}
+
 func (r *Registry) ListModels(ctx context.Context, providers ...string) (result []string, _ error) {
 	for _, v := range r.clients {
 		models, err := v.ListModels(ctx, providers...)
@@ -45,11 +61,49 @@ func (r *Registry) Call(ctx context.Context, messageRequest types.CompletionRequ
 	if messageRequest.Model == "" {
 		return nil, fmt.Errorf("model is required")
 	}
+	// The alias is replaced before the provider is chosen, so that responses are cached under the model of the provider.
+	if model, ok := r.aliases[messageRequest.Model]; ok {
+		messageRequest.Model = model
+	}
+
+	client, err := r.client(ctx, messageRequest.Model)
+	if err != nil {
+		return nil, err
+	}
+	return client.Call(ctx, messageRequest, status)
+}
+
+// Embeddings returns the embeddings of the inputs from the provider of the model. An empty model is the default
+// embedding model of OpenAI, or of the first provider when there is no OpenAI client.
+func (r *Registry) Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	if model == "" {
+		if len(r.clients) == 0 {
+			return nil, fmt.Errorf("no model provider for embeddings")
+		}
+		for _, client := range r.clients {
+			if _, ok := client.(*openai.Client); ok {
+				return client.Embeddings(ctx, "", inputs)
+			}
+		}
+		return r.clients[0].Embeddings(ctx, "", inputs)
+	}
+	if alias, ok := r.aliases[model]; ok {
+		model = alias
+	}
+
+	client, err := r.client(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return client.Embeddings(ctx, model, inputs)
+}
 
+// client returns the client of the provider of the model.
+func (r *Registry) client(ctx context.Context, model string) (Client, error) {
 	var errs []error
 	var oaiClient *openai.Client
 	for _, client := range r.clients {
-		ok, err := client.Supports(ctx, messageRequest.Model)
+		ok, err := client.Supports(ctx, model)
 		if err != nil {
 			// If we got an OpenAI invalid auth error back, store the OpenAI client for later.
 			if errors.Is(err, openai.InvalidAuthError{}) {
@@ -58,7 +112,7 @@ func (r *Registry) Call(ctx context.Context, messageRequest types.CompletionRequ
 
 			errs = append(errs, err)
 		} else if ok {
-			return client.Call(ctx, messageRequest, status)
+			return client, nil
 		}
 	}
 
@@ -67,16 +121,16 @@ func (r *Registry) Call(ctx context.Context, messageRequest types.CompletionRequ
 		if err := oaiClient.RetrieveAPIKey(ctx); err != nil {
 			return nil, err
 		}
-		ok, err := oaiClient.Supports(ctx, messageRequest.Model)
+		ok, err := oaiClient.Supports(ctx, model)
 		if err != nil {
 			return nil, err
 		} else if ok {
-			return oaiClient.Call(ctx, messageRequest, status)
+			return oaiClient, nil
 		}
 	}
 
 	if len(errs) == 0 {
-		return nil, fmt.Errorf("failed to find a model provider for model [%s]", messageRequest.Model)
+		return nil, fmt.Errorf("failed to find a model provider for model [%s]", model)
 	}
 	return nil, errors.Join(errs...)
 }
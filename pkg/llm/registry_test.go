@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	models []string
+	called []string
+}
+
+func (f *fakeClient) Call(_ context.Context, messageRequest types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	f.called = append(f.called, messageRequest.Model)
+	return &types.CompletionMessage{}, nil
+}
+
+func (f *fakeClient) Embeddings(_ context.Context, model string, inputs []string) ([][]float64, error) {
+	f.called = append(f.called, model)
+	return make([][]float64, len(inputs)), nil
+}
+
+func (f *fakeClient) ListModels(context.Context, ...string) ([]string, error) {
+	return f.models, nil
+}
+
+func (f *fakeClient) Supports(_ context.Context, modelName string) (bool, error) {
+	for _, model := range f.models {
+		if model == modelName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestModelAliases(t *testing.T) {
+	azure := &fakeClient{models: []string{"prod-gpt-4o"}}
+	remote := &fakeClient{models: []string{"claude-3-5-sonnet from anthropic"}}
+	r := NewRegistry()
+	require.NoError(t, r.AddClient(azure))
+	require.NoError(t, r.AddClient(remote))
+
+	aliases, err := ParseModelAliases([]string{"gpt-4o=prod-gpt-4o", "claude = claude-3-5-sonnet from anthropic", ""})
+	require.NoError(t, err)
+	// Later aliases replace those of the config file.
+	r.AddModelAliases(map[string]string{"gpt-4o": "gpt-4o-old"})
+	r.AddModelAliases(aliases)
+
+	for _, model := range []string{"gpt-4o", "claude", "prod-gpt-4o"} {
+		_, err = r.Call(context.Background(), types.CompletionRequest{Model: model}, nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"prod-gpt-4o", "prod-gpt-4o"}, azure.called)
+	require.Equal(t, []string{"claude-3-5-sonnet from anthropic"}, remote.called)
+
+	_, err = ParseModelAliases([]string{"gpt-4o"})
+	require.ErrorContains(t, err, "invalid model alias")
+}
+
+func TestEmbeddings(t *testing.T) {
+	openai := &fakeClient{models: []string{"text-embedding-3-small"}}
+	remote := &fakeClient{models: []string{"embed-english from cohere"}}
+	r := NewRegistry()
+	require.NoError(t, r.AddClient(openai))
+	require.NoError(t, r.AddClient(remote))
+	r.AddModelAliases(map[string]string{"embed": "embed-english from cohere"})
+
+	for _, model := range []string{"", "text-embedding-3-small", "embed"} {
+		embeddings, err := r.Embeddings(context.Background(), model, []string{"a", "b"})
+		require.NoError(t, err)
+		require.Len(t, embeddings, 2)
+	}
+	require.Equal(t, []string{"", "text-embedding-3-small"}, openai.called)
+	require.Equal(t, []string{"embed-english from cohere"}, remote.called)
+
+	_, err := r.Embeddings(context.Background(), "unknown", []string{"a"})
+	require.ErrorContains(t, err, "failed to find a model provider")
+}
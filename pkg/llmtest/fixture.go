@@ -0,0 +1,163 @@
+package llmtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Fixture is a conversation that a Client replays, such as one imported from the transcript of a production run.
+type Fixture struct {
+	// Input is the text of the first user message of the conversation, which is the input of the run
+	Input string `json:"input,omitempty"`
+	// Responses are the messages of the model, in order
+	Responses []Response `json:"responses"`
+	// Output is the text of the last message of the model, which is the output of the run
+	Output string `json:"output,omitempty"`
+}
+
+// Client returns a client that returns the responses of the fixture in order.
+func (f Fixture) Client() *Client {
+	return NewClient(f.Responses...)
+}
+
+// LoadFixture reads a fixture written by gptscript import-transcript.
+func LoadFixture(path string) (Fixture, error) {
+	var result Fixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// transcript is a conversation in the format of the messages of the OpenAI chat completions API.
+type transcript struct {
+	Messages []transcriptMessage `json:"messages"`
+}
+
+type transcriptMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	// FunctionCall is the single call of the legacy functions API
+	FunctionCall *transcriptCall `json:"function_call"`
+	ToolCalls    []struct {
+		Function transcriptCall `json:"function"`
+	} `json:"tool_calls"`
+}
+
+type transcriptCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ImportOpenAI converts chat transcripts in the OpenAI JSON format into fixtures. The input is a transcript, which is
+// an object with the messages of a conversation such as a chat completion request, an array of messages, or a JSON
+// Lines file of transcripts such as a fine-tuning dataset.
+func ImportOpenAI(r io.Reader) ([]Fixture, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var messages []transcriptMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("invalid transcript: %w", err)
+		}
+		fixture, err := toFixture(messages)
+		if err != nil {
+			return nil, err
+		}
+		return []Fixture{fixture}, nil
+	}
+
+	var (
+		result  []Fixture
+		decoder = json.NewDecoder(bytes.NewReader(data))
+	)
+	for i := 1; decoder.More(); i++ {
+		var t transcript
+		if err := decoder.Decode(&t); err != nil {
+			return nil, fmt.Errorf("invalid transcript %d: %w", i, err)
+		}
+		fixture, err := toFixture(t.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("transcript %d: %w", i, err)
+		}
+		result = append(result, fixture)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no transcripts found")
+	}
+	return result, nil
+}
+
+func toFixture(messages []transcriptMessage) (Fixture, error) {
+	var result Fixture
+	for i, msg := range messages {
+		text, err := contentText(msg.Content)
+		if err != nil {
+			return result, fmt.Errorf("invalid content of message %d: %w", i+1, err)
+		}
+
+		switch msg.Role {
+		case "user":
+			if result.Input == "" && len(result.Responses) == 0 {
+				result.Input = text
+			}
+		case "assistant":
+			response := Response{
+				Text: text,
+			}
+			if msg.FunctionCall != nil {
+				response.ToolCalls = append(response.ToolCalls, ToolCall(*msg.FunctionCall))
+			}
+			for _, call := range msg.ToolCalls {
+				response.ToolCalls = append(response.ToolCalls, ToolCall(call.Function))
+			}
+			result.Responses = append(result.Responses, response)
+			result.Output = text
+		}
+	}
+
+	if len(result.Responses) == 0 {
+		return result, fmt.Errorf("no assistant messages")
+	}
+	return result, nil
+}
+
+// contentText returns the text of the content of a message, which is either a string or an array of parts.
+func contentText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", err
+	}
+
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
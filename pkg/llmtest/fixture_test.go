@@ -0,0 +1,90 @@
+package llmtest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testTranscript = `{
+  "model": "gpt-4o",
+  "messages": [
+    {"role": "system", "content": "Greet the user"},
+    {"role": "user", "content": [{"type": "text", "text": "Say hi to Ada"}]},
+    {"role": "assistant", "content": null, "tool_calls": [
+      {"id": "call_abc", "type": "function", "function": {"name": "greet", "arguments": "{\"name\": \"Ada\"}"}}
+    ]},
+    {"role": "tool", "tool_call_id": "call_abc", "content": "Hello Ada"},
+    {"role": "assistant", "content": "Done"}
+  ]
+}`
+
+func TestImportOpenAI(t *testing.T) {
+	fixtures, err := ImportOpenAI(strings.NewReader(testTranscript))
+	require.NoError(t, err)
+	require.Equal(t, []Fixture{{
+		Input: "Say hi to Ada",
+		Responses: []Response{
+			CallTool("greet", `{"name": "Ada"}`),
+			Text("Done"),
+		},
+		Output: "Done",
+	}}, fixtures)
+
+	// The imported conversation replays against the program.
+	ctx := context.Background()
+	prg, err := loader.ProgramFromSource(ctx, testProgram, "")
+	require.NoError(t, err)
+
+	c := fixtures[0].Client()
+	out, err := NewRunner(t, c, runner.Options{Sequential: true}).Run(ctx, prg, os.Environ(), fixtures[0].Input)
+	require.NoError(t, err)
+	require.Equal(t, fixtures[0].Output, out)
+	c.AssertResponded(t)
+	c.AssertMessageContains(t, 1, types.CompletionMessageRoleTypeTool, "Hello Ada")
+
+	// A fixture can be saved and loaded.
+	data, err := json.Marshal(fixtures[0])
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "greet.fixture.json")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	loaded, err := LoadFixture(path)
+	require.NoError(t, err)
+	require.Equal(t, fixtures[0], loaded)
+}
+
+func TestImportOpenAIFormats(t *testing.T) {
+	// An array of messages, with a legacy function call.
+	fixtures, err := ImportOpenAI(strings.NewReader(`[
+		{"role": "user", "content": "What is the weather?"},
+		{"role": "assistant", "content": "", "function_call": {"name": "weather", "arguments": "{}"}},
+		{"role": "function", "name": "weather", "content": "sunny"},
+		{"role": "assistant", "content": "It is sunny."}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, fixtures, 1)
+	require.Equal(t, []Response{CallTool("weather", "{}"), Text("It is sunny.")}, fixtures[0].Responses)
+
+	// JSON Lines, such as a fine-tuning dataset.
+	fixtures, err = ImportOpenAI(strings.NewReader(`{"messages": [{"role": "user", "content": "a"}, {"role": "assistant", "content": "b"}]}
+{"messages": [{"role": "user", "content": "c"}, {"role": "assistant", "content": "d"}]}
+`))
+	require.NoError(t, err)
+	require.Equal(t, []Fixture{
+		{Input: "a", Responses: []Response{Text("b")}, Output: "b"},
+		{Input: "c", Responses: []Response{Text("d")}, Output: "d"},
+	}, fixtures)
+
+	_, err = ImportOpenAI(strings.NewReader(`{"messages": [{"role": "user", "content": "a"}]}`))
+	require.ErrorContains(t, err, "no assistant messages")
+	_, err = ImportOpenAI(strings.NewReader(``))
+	require.Error(t, err)
+}
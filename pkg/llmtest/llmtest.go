@@ -0,0 +1,223 @@
+// Package llmtest provides a fake LLM client for testing tools and programs that embed gptscript without calling a
+// real model.
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// Response is a scripted response of the Client.
+type Response struct {
+	// Text is the content of the assistant message
+	Text string `json:"text,omitempty"`
+	// ToolCalls are the tools the assistant calls, each must be one of the tools offered in the request
+	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
+	// Err is returned instead of a message
+	Err error `json:"-"`
+}
+
+// ToolCall is a call to a tool in a scripted response.
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// Text returns a response with the given content.
+func Text(text string) Response {
+	return Response{Text: text}
+}
+
+// CallTool returns a response that calls the tool with the given JSON arguments.
+func CallTool(name, arguments string) Response {
+	return Response{ToolCalls: []ToolCall{{Name: name, Arguments: arguments}}}
+}
+
+// Error returns a response that fails the call with err.
+func Error(err error) Response {
+	return Response{Err: err}
+}
+
+// Client is a fake model that returns scripted responses in order and records the requests it receives. When no
+// scripted responses remain, the Default response is returned. It is safe for concurrent use, but responses are
+// handed out in the order calls arrive.
+type Client struct {
+	// Default is returned when there are no scripted responses left, if not set an error is returned
+	Default *Response
+
+	lock      sync.Mutex
+	responses []Response
+	requests  []types.CompletionRequest
+}
+
+// NewClient returns a client that returns the responses in order.
+func NewClient(responses ...Response) *Client {
+	return &Client{
+		responses: responses,
+	}
+}
+
+// Respond adds responses to be returned after the ones already scripted.
+func (c *Client) Respond(responses ...Response) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.responses = append(c.responses, responses...)
+}
+
+// Requests returns the requests received so far.
+func (c *Client) Requests() []types.CompletionRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return slices.Clone(c.requests)
+}
+
+// Remaining returns the number of scripted responses that have not been returned.
+func (c *Client) Remaining() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.responses)
+}
+
+func (c *Client) Call(_ context.Context, messageRequest types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	c.lock.Lock()
+	c.requests = append(c.requests, messageRequest)
+	callNumber := len(c.requests)
+
+	var response Response
+	if len(c.responses) > 0 {
+		response = c.responses[0]
+		c.responses = c.responses[1:]
+	} else if c.Default != nil {
+		response = *c.Default
+	} else {
+		c.lock.Unlock()
+		return nil, fmt.Errorf("llmtest: no response scripted for call %d", callNumber)
+	}
+	c.lock.Unlock()
+
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	msg := &types.CompletionMessage{
+		Role: types.CompletionMessageRoleTypeAssistant,
+	}
+	if response.Text != "" || len(response.ToolCalls) == 0 {
+		msg.Content = types.Text(response.Text)
+	}
+
+	for i, call := range response.ToolCalls {
+		index := slices.IndexFunc(messageRequest.Tools, func(tool types.CompletionTool) bool {
+			return tool.Function.Name == call.Name
+		})
+		if index == -1 {
+			return nil, fmt.Errorf("llmtest: tool %s is not available in call %d, available tools are %s", call.Name, callNumber, strings.Join(ToolNames(messageRequest), ", "))
+		}
+		msg.Content = append(msg.Content, types.ContentPart{
+			ToolCall: &types.CompletionToolCall{
+				Index: &index,
+				ID:    fmt.Sprintf("call_%d_%d", callNumber, i),
+				Function: types.CompletionFunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			},
+		})
+	}
+
+	return msg, nil
+}
+
+// ListModels returns no models, but Supports reports that every model is supported so that the client can be added
+// to an llm.Registry.
+func (c *Client) ListModels(context.Context, ...string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *Client) Supports(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+// ToolNames returns the names of the tools offered in the request.
+func ToolNames(request types.CompletionRequest) (result []string) {
+	for _, tool := range request.Tools {
+		result = append(result, tool.Function.Name)
+	}
+	return
+}
+
+// MessageText returns the text of the messages of the request with the given role, or of all messages if role is
+// empty.
+func MessageText(request types.CompletionRequest, role types.CompletionMessageRoleType) string {
+	var parts []string
+	for _, msg := range request.Messages {
+		if role == "" || msg.Role == role {
+			parts = append(parts, msg.String())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// AssertCalls fails the test if the client did not receive exactly n requests.
+func (c *Client) AssertCalls(t testing.TB, n int) {
+	t.Helper()
+	if got := len(c.Requests()); got != n {
+		t.Errorf("expected %d LLM calls, got %d", n, got)
+	}
+}
+
+// AssertResponded fails the test if any scripted responses were not returned.
+func (c *Client) AssertResponded(t testing.TB) {
+	t.Helper()
+	if remaining := c.Remaining(); remaining != 0 {
+		t.Errorf("expected all scripted responses to be used, %d remaining", remaining)
+	}
+}
+
+// Request returns the i-th request received, starting at 0, failing the test if there is no such request.
+func (c *Client) Request(t testing.TB, i int) types.CompletionRequest {
+	t.Helper()
+	requests := c.Requests()
+	if i < 0 || i >= len(requests) {
+		t.Fatalf("expected at least %d LLM calls, got %d", i+1, len(requests))
+	}
+	return requests[i]
+}
+
+// AssertToolsOffered fails the test if the i-th request did not offer the tools, in any order.
+func (c *Client) AssertToolsOffered(t testing.TB, i int, names ...string) {
+	t.Helper()
+	got := ToolNames(c.Request(t, i))
+	for _, name := range names {
+		if !slices.Contains(got, name) {
+			t.Errorf("expected call %d to offer tool %s, offered %v", i, name, got)
+		}
+	}
+}
+
+// AssertMessageContains fails the test if no message of the i-th request with the given role, or any role if empty,
+// contains text.
+func (c *Client) AssertMessageContains(t testing.TB, i int, role types.CompletionMessageRoleType, text string) {
+	t.Helper()
+	if got := MessageText(c.Request(t, i), role); !strings.Contains(got, text) {
+		t.Errorf("expected call %d to contain %q, got %q", i, text, got)
+	}
+}
+
+// NewRunner returns a runner that uses the client as its model and does not store credentials.
+func NewRunner(t testing.TB, c *Client, opts ...runner.Options) *runner.Runner {
+	t.Helper()
+	r, err := runner.New(c, credentials.NoopStore{}, opts...)
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testProgram = `tools: greet
+
+Greet the user
+
+---
+name: greet
+description: Greets someone
+args: name: The name to greet
+
+#!/bin/echo Hello ${name}
+`
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	prg, err := loader.ProgramFromSource(ctx, testProgram, "")
+	require.NoError(t, err)
+
+	c := NewClient(
+		CallTool("greet", `{"name": "Ada"}`),
+		Text("Done"),
+	)
+	r := NewRunner(t, c, runner.Options{Sequential: true})
+
+	out, err := r.Run(ctx, prg, os.Environ(), "")
+	require.NoError(t, err)
+	require.Equal(t, "Done", out)
+
+	c.AssertResponded(t)
+	c.AssertCalls(t, 2)
+	c.AssertToolsOffered(t, 0, "greet")
+	c.AssertMessageContains(t, 0, types.CompletionMessageRoleTypeSystem, "Greet the user")
+	c.AssertMessageContains(t, 1, types.CompletionMessageRoleTypeTool, "Hello Ada")
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	prg, err := loader.ProgramFromSource(ctx, testProgram, "")
+	require.NoError(t, err)
+
+	c := NewClient(Error(errors.New("rate limited")))
+	r := NewRunner(t, c)
+	_, err = r.Run(ctx, prg, os.Environ(), "")
+	require.ErrorContains(t, err, "rate limited")
+
+	c.Respond(CallTool("missing", "{}"))
+	_, err = r.Run(ctx, prg, os.Environ(), "")
+	require.ErrorContains(t, err, "tool missing is not available")
+
+	_, err = r.Run(ctx, prg, os.Environ(), "")
+	require.ErrorContains(t, err, "no response scripted")
+
+	c.Default = &Response{Text: "default"}
+	out, err := r.Run(ctx, prg, os.Environ(), "")
+	require.NoError(t, err)
+	require.Equal(t, "default", out)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/gptscript-ai/gptscript/pkg/repos/git"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
@@ -57,7 +58,7 @@ func getCommit(ctx context.Context, account, repo, ref string) (string, error) {
 		req.Header.Add("Authorization", "Bearer "+githubAuthToken)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := proxy.Client.Do(req)
 	if err != nil {
 		return "", err
 	} else if resp.StatusCode != http.StatusOK {
@@ -79,7 +80,7 @@ func getCommit(ctx context.Context, account, repo, ref string) (string, error) {
 		return "", fmt.Errorf("failed to decode GitHub commit of %s/%s at %s: %w", account, repo, url, err)
 	}
 
-	log.Debugf("loaded github commit of %s/%s at %s as %q", account, repo, url, commit.SHA)
+	log.WithContext(ctx).Debugf("loaded github commit of %s/%s at %s as %q", account, repo, url, commit.SHA)
 
 	if commit.SHA == "" {
 		return "", fmt.Errorf("failed to find commit in response of %s, got empty string", url)
@@ -130,7 +131,7 @@ func Load(ctx context.Context, _ *cache.Client, urlName string) (string, string,
 				// two elements this loop could have been one check, but hey over-engineered code ftw.
 				break
 			}
-			if resp, err := http.Head(testURL); err == nil {
+			if resp, err := proxy.Client.Head(testURL); err == nil {
 				_ = resp.Body.Close()
 				if resp.StatusCode == 200 {
 					break
@@ -0,0 +1,425 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// maxSelectionDepth is how many levels of objects are selected from the result of a query.
+	maxSelectionDepth = 2
+	// maxInputDepth is how many levels of input objects are described in the arguments of a tool.
+	maxInputDepth = 5
+)
+
+// introspectionQuery reads the parts of a GraphQL schema needed to generate tools.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind name description
+      fields { name description args { ...InputValue } type { ...TypeRef } }
+      inputFields { ...InputValue }
+      enumValues { name }
+    }
+  }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } }
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } }
+}`
+
+// graphQLDocument is the result of an introspection query, with the endpoint that the queries are sent to.
+type graphQLDocument struct {
+	Endpoint string         `json:"endpoint,omitempty"`
+	Schema   *graphQLSchema `json:"__schema,omitempty"`
+	Data     *struct {
+		Schema *graphQLSchema `json:"__schema,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+type graphQLSchema struct {
+	QueryType    *graphQLTypeRef `json:"queryType,omitempty"`
+	MutationType *graphQLTypeRef `json:"mutationType,omitempty"`
+	Types        []graphQLType   `json:"types,omitempty"`
+}
+
+type graphQLType struct {
+	Kind        string              `json:"kind,omitempty"`
+	Name        string              `json:"name,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Fields      []graphQLField      `json:"fields,omitempty"`
+	InputFields []graphQLInputValue `json:"inputFields,omitempty"`
+	EnumValues  []struct {
+		Name string `json:"name,omitempty"`
+	} `json:"enumValues,omitempty"`
+}
+
+type graphQLField struct {
+	Name        string              `json:"name,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Args        []graphQLInputValue `json:"args,omitempty"`
+	Type        graphQLTypeRef      `json:"type"`
+}
+
+type graphQLInputValue struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Type        graphQLTypeRef `json:"type"`
+}
+
+type graphQLTypeRef struct {
+	Kind   string          `json:"kind,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	OfType *graphQLTypeRef `json:"ofType,omitempty"`
+}
+
+// named returns the type without its list and non-null wrappers.
+func (r graphQLTypeRef) named() graphQLTypeRef {
+	for r.OfType != nil && (r.Kind == "NON_NULL" || r.Kind == "LIST") {
+		r = *r.OfType
+	}
+	return r
+}
+
+// String returns the type as it is written in a query, such as [String!]!.
+func (r graphQLTypeRef) String() string {
+	switch {
+	case r.Kind == "NON_NULL" && r.OfType != nil:
+		return r.OfType.String() + "!"
+	case r.Kind == "LIST" && r.OfType != nil:
+		return "[" + r.OfType.String() + "]"
+	}
+	return r.Name
+}
+
+// loadGraphQL returns the schema if the data is the result of a GraphQL introspection query.
+func loadGraphQL(data []byte) *graphQLDocument {
+	if !bytes.Contains(data, []byte(`"__schema"`)) {
+		return nil
+	}
+
+	var doc graphQLDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	if doc.Schema == nil && doc.Data != nil {
+		doc.Schema = doc.Data.Schema
+	}
+	if doc.Schema == nil || doc.Schema.QueryType == nil && doc.Schema.MutationType == nil {
+		return nil
+	}
+	return &doc
+}
+
+// introspectGraphQL runs an introspection query against a GraphQL endpoint, and returns the result with the endpoint.
+// The bearer token of the server is used if it is set, as it is when running the tools.
+func introspectGraphQL(req *http.Request) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{
+		"query": introspectionQuery,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Method = http.MethodPost
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token := os.Getenv("GPTSCRIPT_" + env.ToEnvLike(req.URL.Hostname()) + "_BEARER_TOKEN"); token != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := proxy.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error introspecting GraphQL endpoint %s: %s", req.URL, resp.Status)
+	}
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error introspecting GraphQL endpoint %s: %w", req.URL, err)
+	}
+	result["endpoint"] = req.URL.String()
+	return json.Marshal(result)
+}
+
+// getGraphQLTools generates a tool for each field of the query and mutation types of a GraphQL schema.
+// The tool's Instructions will be in the format "#!sys.graphql '{JSON Instructions}'",
+// where the JSON Instructions are a JSON-serialized engine.GraphQLInstructions struct.
+func getGraphQLTools(doc *graphQLDocument) ([]types.Tool, error) {
+	endpoint := doc.Endpoint
+	if endpoint == "" {
+		return nil, fmt.Errorf("no endpoint found in GraphQL schema, it must be set in the endpoint field of the introspection result")
+	}
+	if !strings.HasPrefix(endpoint, "http") {
+		return nil, fmt.Errorf("invalid GraphQL endpoint: %s (must use HTTP or HTTPS)", endpoint)
+	}
+
+	schemaTypes := make(map[string]graphQLType, len(doc.Schema.Types))
+	for _, t := range doc.Schema.Types {
+		schemaTypes[t.Name] = t
+	}
+
+	var (
+		toolNames    []string
+		tools        []types.Tool
+		groups       = map[string][]string{}
+		operationNum = 1 // Each tool gets an operation number, beginning with 1
+	)
+
+	for _, root := range []struct {
+		operation, group string
+		ref              *graphQLTypeRef
+	}{
+		{"query", "queries", doc.Schema.QueryType},
+		{"mutation", "mutations", doc.Schema.MutationType},
+	} {
+		if root.ref == nil {
+			continue
+		}
+
+		fields := schemaTypes[root.ref.Name].Fields
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Name < fields[j].Name
+		})
+
+		for _, field := range fields {
+			if strings.HasPrefix(field.Name, "__") {
+				continue
+			}
+			if containsFold(toolNames, field.Name) {
+				log.Debugf("skipping %s %s of GraphQL schema because a query has the same name", root.operation, field.Name)
+				continue
+			}
+
+			desc := field.Description
+			if len(desc) > 1024 {
+				desc = desc[:1024]
+			}
+
+			tool := types.Tool{
+				ToolDef: types.ToolDef{
+					Parameters: types.Parameters{
+						Name:        field.Name,
+						Description: desc,
+					},
+				},
+				Source: types.ToolSource{
+					LineNo: operationNum,
+				},
+			}
+
+			var (
+				variables []string
+				args      []string
+			)
+			if len(field.Args) > 0 {
+				tool.Parameters.Arguments = &openapi3.Schema{
+					Type:       &openapi3.Types{"object"},
+					Properties: openapi3.Schemas{},
+					Required:   []string{},
+				}
+			}
+			for _, arg := range field.Args {
+				schema := graphQLInputSchema(schemaTypes, arg.Type, 0)
+				if arg.Description != "" {
+					schema.Description = arg.Description
+				}
+				tool.Parameters.Arguments.Properties[arg.Name] = &openapi3.SchemaRef{Value: schema}
+				if arg.Type.Kind == "NON_NULL" {
+					tool.Parameters.Arguments.Required = append(tool.Parameters.Arguments.Required, arg.Name)
+				}
+				variables = append(variables, fmt.Sprintf("$%s: %s", arg.Name, arg.Type))
+				args = append(args, fmt.Sprintf("%s: $%s", arg.Name, arg.Name))
+			}
+
+			query := root.operation + " " + field.Name
+			if len(variables) > 0 {
+				query += "(" + strings.Join(variables, ", ") + ")"
+			}
+			query += " { " + field.Name
+			if len(args) > 0 {
+				query += "(" + strings.Join(args, ", ") + ")"
+			}
+			if selection := graphQLSelection(schemaTypes, field.Type, 0); selection != "" {
+				query += " " + selection
+			}
+			query += " }"
+
+			instBytes, err := json.Marshal(engine.GraphQLInstructions{
+				Endpoint: endpoint,
+				Query:    query,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool instructions: %w", err)
+			}
+			tool.Instructions = fmt.Sprintf("%s '%s'", types.GraphQLPrefix, string(instBytes))
+
+			toolNames = append(toolNames, tool.Parameters.Name)
+			groups[root.group] = append(groups[root.group], tool.Parameters.Name)
+			tools = append(tools, tool)
+			operationNum++
+		}
+	}
+
+	// The queries and mutations are each a tool that exports them, so that a script can use only the queries.
+	for _, group := range []string{"queries", "mutations"} {
+		if len(groups[group]) == 0 || containsFold(toolNames, group) {
+			continue
+		}
+		tools = append(tools, types.Tool{
+			ToolDef: types.ToolDef{
+				Parameters: types.Parameters{
+					Name:        group,
+					Description: fmt.Sprintf("This is a tool set for the %s of the GraphQL API at %s", group, endpoint),
+					Export:      groups[group],
+				},
+			},
+			Source: types.ToolSource{
+				LineNo: operationNum,
+			},
+		})
+		operationNum++
+	}
+
+	// The first tool we generate is a special tool that just exports all the others.
+	exportTool := types.Tool{
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: fmt.Sprintf("This is a tool set for the GraphQL API at %s", endpoint),
+				Export:      toolNames,
+			},
+		},
+		Source: types.ToolSource{
+			LineNo: 0,
+		},
+	}
+
+	return append([]types.Tool{exportTool}, tools...), nil
+}
+
+func containsFold(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+// graphQLInputSchema returns the JSON schema of an argument or input field.
+func graphQLInputSchema(schemaTypes map[string]graphQLType, ref graphQLTypeRef, depth int) *openapi3.Schema {
+	switch ref.Kind {
+	case "NON_NULL":
+		if ref.OfType != nil {
+			return graphQLInputSchema(schemaTypes, *ref.OfType, depth)
+		}
+	case "LIST":
+		if ref.OfType != nil {
+			return &openapi3.Schema{
+				Type:  &openapi3.Types{"array"},
+				Items: &openapi3.SchemaRef{Value: graphQLInputSchema(schemaTypes, *ref.OfType, depth)},
+			}
+		}
+	}
+
+	t := schemaTypes[ref.Name]
+	schema := &openapi3.Schema{
+		Description: t.Description,
+	}
+	switch t.Kind {
+	case "ENUM":
+		schema.Type = &openapi3.Types{"string"}
+		for _, v := range t.EnumValues {
+			schema.Enum = append(schema.Enum, v.Name)
+		}
+	case "INPUT_OBJECT":
+		schema.Type = &openapi3.Types{"object"}
+		if depth >= maxInputDepth {
+			// Input objects can refer to themselves, so deep ones are left without a description of their fields.
+			break
+		}
+		schema.Properties = openapi3.Schemas{}
+		for _, field := range t.InputFields {
+			fieldSchema := graphQLInputSchema(schemaTypes, field.Type, depth+1)
+			if field.Description != "" {
+				fieldSchema.Description = field.Description
+			}
+			schema.Properties[field.Name] = &openapi3.SchemaRef{Value: fieldSchema}
+			if field.Type.Kind == "NON_NULL" {
+				schema.Required = append(schema.Required, field.Name)
+			}
+		}
+	default:
+		switch ref.Name {
+		case "Int":
+			schema.Type = &openapi3.Types{"integer"}
+		case "Float":
+			schema.Type = &openapi3.Types{"number"}
+		case "Boolean":
+			schema.Type = &openapi3.Types{"boolean"}
+		default:
+			// String, ID, and custom scalars are sent as strings.
+			schema.Type = &openapi3.Types{"string"}
+		}
+	}
+	return schema
+}
+
+// graphQLSelection returns the selection set of the result of a field, which is empty for scalars and enums. Fields
+// with required arguments and objects nested deeper than maxSelectionDepth are not selected.
+func graphQLSelection(schemaTypes map[string]graphQLType, ref graphQLTypeRef, depth int) string {
+	t := schemaTypes[ref.named().Name]
+	switch t.Kind {
+	case "OBJECT", "INTERFACE":
+	case "UNION":
+		return "{ __typename }"
+	default:
+		return ""
+	}
+
+	var fields []string
+fields:
+	for _, field := range t.Fields {
+		for _, arg := range field.Args {
+			if arg.Type.Kind == "NON_NULL" {
+				continue fields
+			}
+		}
+
+		switch schemaTypes[field.Type.named().Name].Kind {
+		case "OBJECT", "INTERFACE", "UNION":
+			if depth+1 >= maxSelectionDepth {
+				continue
+			}
+			if selection := graphQLSelection(schemaTypes, field.Type, depth+1); selection != "" {
+				fields = append(fields, field.Name+" "+selection)
+			}
+		default:
+			fields = append(fields, field.Name)
+		}
+	}
+	if len(fields) == 0 {
+		fields = []string{"__typename"}
+	}
+	return "{ " + strings.Join(fields, " ") + " }"
+}
@@ -0,0 +1,291 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/grpc"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// grpcDocument is the content of the source of a gRPC server, with the schema of its services.
+type grpcDocument struct {
+	GRPC *grpcServer `json:"grpc"`
+}
+
+type grpcServer struct {
+	Target string       `json:"target"`
+	TLS    bool         `json:"tls,omitempty"`
+	Schema *grpc.Schema `json:"schema"`
+}
+
+func isGRPC(name string) bool {
+	return strings.HasPrefix(name, "grpc://") || strings.HasPrefix(name, "grpcs://")
+}
+
+// loadGRPCServer reads the schema of the services of a gRPC server, such as grpc://localhost:50051, with server
+// reflection. Servers without reflection can be given a descriptor set, as written by protoc --descriptor_set_out,
+// with grpc://localhost:50051?descriptors=api.pb. A grpcs:// server is called with TLS.
+func loadGRPCServer(ctx context.Context, cache *cache.Client, base *source, name string) (*source, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gRPC server %s: %w", name, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid gRPC server %s: no host and port", name)
+	}
+
+	server := &grpcServer{
+		Target: u.Host,
+		TLS:    u.Scheme == "grpcs",
+	}
+
+	if descriptors := u.Query().Get("descriptors"); descriptors != "" {
+		// The descriptor set is found like any other file, relative to the script that references the server.
+		s, err := input(ctx, cache, base, descriptors)
+		if err != nil {
+			return nil, err
+		}
+		server.Schema, err = grpc.ParseDescriptorSet(s.Content)
+		if err != nil {
+			return nil, fmt.Errorf("error loading descriptor set %s: %w", descriptors, err)
+		}
+	} else {
+		header := http.Header{}
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+		if token := os.Getenv("GPTSCRIPT_" + env.ToEnvLike(host) + "_BEARER_TOKEN"); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		server.Schema, err = grpc.Reflect(ctx, grpc.NewClient(server.Target, server.TLS, header))
+		if err != nil {
+			return nil, fmt.Errorf("error loading gRPC server %s: %w", name, err)
+		}
+	}
+
+	data, err := json.Marshal(grpcDocument{GRPC: server})
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithContext(ctx).Debugf("opened %s", name)
+
+	return &source{
+		Content:  data,
+		Remote:   base.Remote,
+		Path:     base.Path,
+		Name:     u.Host,
+		Location: name,
+		Repo:     base.Repo,
+	}, nil
+}
+
+// loadGRPC returns the server if the data is the source of a gRPC server.
+func loadGRPC(data []byte) *grpcServer {
+	if !bytes.HasPrefix(data, []byte(`{"grpc":`)) {
+		return nil
+	}
+
+	var doc grpcDocument
+	if err := json.Unmarshal(data, &doc); err != nil || doc.GRPC == nil || doc.GRPC.Schema == nil {
+		return nil
+	}
+	return doc.GRPC
+}
+
+// getGRPCTools generates a tool for each unary and server streaming method of the services of a gRPC server.
+// The tool's Instructions will be in the format "#!sys.grpc '{JSON Instructions}'",
+// where the JSON Instructions are a JSON-serialized engine.GRPCInstructions struct.
+func getGRPCTools(server *grpcServer) ([]types.Tool, error) {
+	var (
+		toolNames    []string
+		tools        []types.Tool
+		groups       []types.Tool
+		operationNum = 1 // Each tool gets an operation number, beginning with 1
+	)
+
+	for _, service := range server.Schema.Services {
+		if strings.HasPrefix(service.Name, "grpc.reflection.") {
+			continue
+		}
+
+		shortName := service.Name[strings.LastIndex(service.Name, ".")+1:]
+
+		var serviceTools []string
+		for _, method := range service.Methods {
+			if method.ClientStreaming {
+				log.Debugf("skipping method %s of gRPC service %s because it is client streaming", method.Name, service.Name)
+				continue
+			}
+
+			name := shortName + "_" + method.Name
+			if containsFold(toolNames, name) {
+				log.Debugf("skipping method %s of gRPC service %s because a method has the same name", method.Name, service.Name)
+				continue
+			}
+
+			tool := types.Tool{
+				ToolDef: types.ToolDef{
+					Parameters: types.Parameters{
+						Name:        name,
+						Description: fmt.Sprintf("Calls the %s method of the %s gRPC service", method.Name, service.Name),
+					},
+				},
+				Source: types.ToolSource{
+					LineNo: operationNum,
+				},
+			}
+			if method.ServerStreaming {
+				tool.Parameters.Description += ", which returns a list of responses"
+			}
+
+			if input := grpcInputSchema(server.Schema, method.Input, 0); len(input.Properties) > 0 {
+				tool.Parameters.Arguments = input
+			}
+
+			instBytes, err := json.Marshal(engine.GRPCInstructions{
+				Target:          server.Target,
+				TLS:             server.TLS,
+				Method:          grpc.MethodPath(service.Name, method.Name),
+				Input:           method.Input,
+				Output:          method.Output,
+				ServerStreaming: method.ServerStreaming,
+				Schema:          server.Schema.Subset(method.Input, method.Output),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool instructions: %w", err)
+			}
+			tool.Instructions = fmt.Sprintf("%s '%s'", types.GRPCPrefix, string(instBytes))
+
+			toolNames = append(toolNames, name)
+			serviceTools = append(serviceTools, name)
+			tools = append(tools, tool)
+			operationNum++
+		}
+
+		// Each service is a tool that exports its methods, so that a script can use only one service of the server.
+		if len(serviceTools) > 0 {
+			groups = append(groups, types.Tool{
+				ToolDef: types.ToolDef{
+					Parameters: types.Parameters{
+						Name:        shortName,
+						Description: fmt.Sprintf("This is a tool set for the %s gRPC service at %s", service.Name, server.Target),
+						Export:      serviceTools,
+					},
+				},
+			})
+		}
+	}
+
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("no unary or server streaming methods found on gRPC server %s", server.Target)
+	}
+
+	for _, group := range groups {
+		if containsFold(toolNames, group.Parameters.Name) {
+			log.Debugf("skipping gRPC service %s because a method has the same name", group.Parameters.Name)
+			continue
+		}
+		group.Source.LineNo = operationNum
+		tools = append(tools, group)
+		operationNum++
+	}
+
+	// The first tool we generate is a special tool that just exports all the others.
+	exportTool := types.Tool{
+		ToolDef: types.ToolDef{
+			Parameters: types.Parameters{
+				Description: fmt.Sprintf("This is a tool set for the gRPC server at %s", server.Target),
+				Export:      toolNames,
+			},
+		},
+		Source: types.ToolSource{
+			LineNo: 0,
+		},
+	}
+
+	return append([]types.Tool{exportTool}, tools...), nil
+}
+
+// grpcInputSchema returns the JSON schema of a message, with the JSON names of its fields.
+func grpcInputSchema(schema *grpc.Schema, messageName string, depth int) *openapi3.Schema {
+	result := &openapi3.Schema{
+		Type: &openapi3.Types{"object"},
+	}
+	message, ok := schema.Messages[messageName]
+	if !ok || depth >= maxInputDepth {
+		// Messages can refer to themselves, so deep ones are left without a description of their fields.
+		return result
+	}
+
+	result.Properties = openapi3.Schemas{}
+	for _, field := range message.Fields {
+		var fieldSchema *openapi3.Schema
+		if entry, ok := schema.Messages[field.TypeName]; ok && entry.MapEntry && len(entry.Fields) == 2 {
+			valueField := entry.Fields[1]
+			if valueField.Number != 2 {
+				valueField = entry.Fields[0]
+			}
+			fieldSchema = &openapi3.Schema{
+				Type: &openapi3.Types{"object"},
+				AdditionalProperties: openapi3.AdditionalProperties{
+					Schema: &openapi3.SchemaRef{Value: grpcFieldSchema(schema, valueField, depth)},
+				},
+			}
+		} else if field.Repeated {
+			fieldSchema = &openapi3.Schema{
+				Type:  &openapi3.Types{"array"},
+				Items: &openapi3.SchemaRef{Value: grpcFieldSchema(schema, field, depth)},
+			}
+		} else {
+			fieldSchema = grpcFieldSchema(schema, field, depth)
+		}
+		result.Properties[field.JSONName] = &openapi3.SchemaRef{Value: fieldSchema}
+	}
+	return result
+}
+
+// grpcFieldSchema returns the JSON schema of a single value of a field.
+func grpcFieldSchema(schema *grpc.Schema, field grpc.Field, depth int) *openapi3.Schema {
+	switch field.Type {
+	case grpc.TypeMessage:
+		return grpcInputSchema(schema, field.TypeName, depth+1)
+	case grpc.TypeEnum:
+		result := &openapi3.Schema{
+			Type: &openapi3.Types{"string"},
+		}
+		if enum, ok := schema.Enums[field.TypeName]; ok {
+			for _, v := range enum.Values {
+				result.Enum = append(result.Enum, v.Name)
+			}
+		}
+		return result
+	case grpc.TypeBool:
+		return &openapi3.Schema{Type: &openapi3.Types{"boolean"}}
+	case grpc.TypeDouble, grpc.TypeFloat:
+		return &openapi3.Schema{Type: &openapi3.Types{"number"}}
+	case grpc.TypeString:
+		return &openapi3.Schema{Type: &openapi3.Types{"string"}}
+	case grpc.TypeBytes:
+		return &openapi3.Schema{
+			Type:        &openapi3.Types{"string"},
+			Format:      "byte",
+			Description: "base64 encoded",
+		}
+	default:
+		return &openapi3.Schema{Type: &openapi3.Types{"integer"}}
+	}
+}
@@ -174,7 +174,11 @@ func loadOpenAPI(prg *types.Program, data []byte) *openapi3.T {
 			return nil
 		}
 	case 3:
-		// Use OpenAPI v3 as is
+		// Convert OpenAPI v3.1 to v3.0, and use OpenAPI v3.0 as is
+		data, err = downgradeOpenAPI31(data)
+		if err != nil {
+			return nil
+		}
 		openAPIDocument, err = openapi3.NewLoader().LoadFromData(data)
 		if err != nil {
 			return nil
@@ -198,10 +202,15 @@ func readTool(ctx context.Context, cache *cache.Client, prg *types.Program, base
 		return []types.Tool{tool}, nil
 	}
 
-	var tools []types.Tool
+	var (
+		tools []types.Tool
+		// operations is set for OpenAPI specs, GraphQL schemas, and gRPC servers, where each operation is a tool.
+		operations bool
+	)
 
 	if openAPIDocument := loadOpenAPI(prg, data); openAPIDocument != nil {
 		var err error
+		operations = true
 		if base.Remote {
 			tools, err = getOpenAPITools(openAPIDocument, base.Location)
 		} else {
@@ -210,6 +219,20 @@ func readTool(ctx context.Context, cache *cache.Client, prg *types.Program, base
 		if err != nil {
 			return nil, fmt.Errorf("error parsing OpenAPI definition: %w", err)
 		}
+	} else if graphQLDocument := loadGraphQL(data); graphQLDocument != nil {
+		var err error
+		operations = true
+		tools, err = getGraphQLTools(graphQLDocument)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing GraphQL schema: %w", err)
+		}
+	} else if grpcServer := loadGRPC(data); grpcServer != nil {
+		var err error
+		operations = true
+		tools, err = getGRPCTools(grpcServer)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing gRPC schema: %w", err)
+		}
 	}
 
 	if ext := path.Ext(base.Name); len(tools) == 0 && ext != "" && ext != system.Suffix && utf8.Valid(data) {
@@ -252,6 +275,7 @@ func readTool(ctx context.Context, cache *cache.Client, prg *types.Program, base
 
 		// Probably a better way to come up with an ID
 		tool.ID = tool.Source.Location + ":" + tool.Name
+		tools[i] = tool
 
 		if i == 0 && targetToolName == "" {
 			targetTools = append(targetTools, tool)
@@ -269,7 +293,8 @@ func readTool(ctx context.Context, cache *cache.Client, prg *types.Program, base
 			return nil, parser.NewErrLine(tool.Source.Location, tool.Source.LineNo, fmt.Errorf("only the first tool in a file can have global tools"))
 		}
 
-		if targetToolName != "" && tool.Parameters.Name != "" {
+		// Operations of OpenAPI specs, GraphQL schemas, and gRPC servers are selected below, with filters and groups.
+		if targetToolName != "" && tool.Parameters.Name != "" && !operations {
 			if strings.EqualFold(tool.Parameters.Name, targetToolName) {
 				targetTools = append(targetTools, tool)
 			} else if strings.Contains(targetToolName, "*") {
@@ -292,6 +317,14 @@ func readTool(ctx context.Context, cache *cache.Client, prg *types.Program, base
 		localTools[strings.ToLower(tool.Parameters.Name)] = tool
 	}
 
+	if operations && targetToolName != "" {
+		var err error
+		targetTools, err = selectOperations(tools, targetToolName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection of operations %q: %w", targetToolName, err)
+		}
+	}
+
 	return linkAll(ctx, cache, prg, base, targetTools, localTools)
 }
 
@@ -356,6 +389,11 @@ func link(ctx context.Context, cache *cache.Client, prg *types.Program, base *so
 		}
 	}
 
+	tool, err := applyMixins(prg, tool)
+	if err != nil {
+		return types.Tool{}, parser.NewErrLine(tool.Source.Location, tool.Source.LineNo, err)
+	}
+
 	tool.LocalTools = localToolsMapping
 
 	tool = builtin.SetDefaults(tool)
@@ -368,7 +406,7 @@ func ProgramFromSource(ctx context.Context, content, subToolName string, opts ..
 	if log.IsDebug() {
 		start := time.Now()
 		defer func() {
-			log.Debugf("loaded program from source took %v", time.Since(start))
+			log.WithContext(ctx).Debugf("loaded program from source took %v", time.Since(start))
 		}()
 	}
 	opt := complete(opts...)
@@ -406,7 +444,7 @@ func Program(ctx context.Context, name, subToolName string, opts ...Options) (ty
 	if log.IsDebug() {
 		start := time.Now()
 		defer func() {
-			log.Debugf("loaded program %s source took %v", name, time.Since(start))
+			log.WithContext(ctx).Debugf("loaded program %s source took %v", name, time.Since(start))
 		}()
 	}
 
@@ -454,6 +492,10 @@ func resolve(ctx context.Context, cache *cache.Client, prg *types.Program, base
 }
 
 func input(ctx context.Context, cache *cache.Client, base *source, name string) (*source, error) {
+	if isGRPC(name) {
+		return loadGRPCServer(ctx, cache, base, name)
+	}
+
 	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
 		// copy and modify
 		base = base.WithRemote(true)
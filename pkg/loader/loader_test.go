@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/hexops/autogold/v2"
 	"github.com/stretchr/testify/require"
@@ -109,6 +110,152 @@ func TestLoadOpenAPI(t *testing.T) {
 	require.EqualValuesf(t, prgv2json.ToolSet, prgv2yaml.ToolSet, "expected same toolset for openapi v2 json and yaml")
 }
 
+func loadOpenAPITools(t *testing.T, file string) []types.Tool {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	doc := loadOpenAPI(&types.Program{}, data)
+	require.NotNil(t, doc)
+
+	tools, err := getOpenAPITools(doc, "")
+	require.NoError(t, err)
+	return tools
+}
+
+func TestLoadOpenAPI31(t *testing.T) {
+	tools := loadOpenAPITools(t, "testdata/openapi_v31.yaml")
+	byName := map[string]types.Tool{}
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	require.Equal(t, []string{"health", "listPets", "createPet", "deletePet"}, tools[0].Export)
+	require.Equal(t, []string{"createPet", "deletePet"}, byName["admin"].Export)
+	require.Equal(t, "Manage the pets of the store", byName["pets"].Description)
+
+	listPets := byName["listPets"]
+	limit := listPets.Arguments.Properties["limit"].Value
+	require.Equal(t, &openapi3.Types{"integer"}, limit.Type)
+	require.True(t, limit.Nullable)
+	require.True(t, limit.ExclusiveMin)
+	require.Equal(t, float64(0), *limit.Min)
+	require.Equal(t, float64(10), limit.Example)
+	require.Len(t, listPets.Credentials, 2)
+	require.Contains(t, listPets.Credentials[0]+listPets.Credentials[1], `"access token" as field`)
+
+	kind := byName["createPet"].Arguments.Properties["requestBodyContent"].Value.Properties["kind"].Value
+	require.Equal(t, []any{"dog"}, kind.Enum)
+
+	require.Empty(t, byName["health"].Credentials)
+}
+
+func TestSelectOpenAPITools(t *testing.T) {
+	tools := loadOpenAPITools(t, "testdata/openapi_v31.yaml")
+
+	for selector, expected := range map[string][]string{
+		"createPet":         {"createPet"},
+		"*Pet*":             {"listPets", "createPet", "deletePet"},
+		"pets !admin":       {"listPets"},
+		"Admin":             {"createPet", "deletePet"},
+		"!deletePet !h*":    {"listPets", "createPet"},
+		"listPets health":   {"health", "listPets"},
+		"pets !delete* !cr": {"listPets", "createPet"},
+	} {
+		selected, err := selectOperations(tools, selector)
+		require.NoError(t, err, selector)
+
+		var names []string
+		for _, tool := range selected {
+			names = append(names, tool.Name)
+		}
+		require.Equal(t, expected, names, selector)
+	}
+}
+
+func TestLoadGraphQL(t *testing.T) {
+	data, err := os.ReadFile("testdata/graphql_schema.json")
+	require.NoError(t, err)
+
+	doc := loadGraphQL(data)
+	require.NotNil(t, doc)
+	tools, err := getGraphQLTools(doc)
+	require.NoError(t, err)
+
+	byName := map[string]types.Tool{}
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	require.Equal(t, []string{"pet", "pets", "version", "adoptPet"}, tools[0].Export)
+	require.Equal(t, []string{"pet", "pets", "version"}, byName["queries"].Export)
+	require.Equal(t, []string{"adoptPet"}, byName["mutations"].Export)
+
+	require.True(t, byName["pets"].IsGraphQL())
+	require.Equal(t, `#!sys.graphql '{"endpoint":"https://api.example.com/graphql","query":"query pets($filter: PetFilter, $first: Int) { pets(filter: $filter, first: $first) { id kind owner { name } } }"}'`, byName["pets"].Instructions)
+	require.Equal(t, `#!sys.graphql '{"endpoint":"https://api.example.com/graphql","query":"query pet($id: ID!) { pet(id: $id) { __typename } }"}'`, byName["pet"].Instructions)
+	require.Equal(t, `#!sys.graphql '{"endpoint":"https://api.example.com/graphql","query":"mutation adoptPet($id: ID!) { adoptPet(id: $id) { id kind owner { name } } }"}'`, byName["adoptPet"].Instructions)
+	require.Nil(t, byName["version"].Arguments)
+
+	args := byName["pets"].Arguments
+	require.Empty(t, args.Required)
+	require.Equal(t, "The number of pets", args.Properties["first"].Value.Description)
+	require.Equal(t, &openapi3.Types{"integer"}, args.Properties["first"].Value.Type)
+	filter := args.Properties["filter"].Value
+	require.Equal(t, "Which pets to list", filter.Description)
+	require.Equal(t, []string{"kind"}, filter.Required)
+	require.Equal(t, []any{"CAT", "DOG"}, filter.Properties["kind"].Value.Enum)
+	require.Equal(t, &openapi3.Types{"string"}, filter.Properties["names"].Value.Items.Value.Type)
+	require.Equal(t, []string{"id"}, byName["pet"].Arguments.Required)
+
+	selected, err := selectOperations(tools, "!mutations !v*")
+	require.NoError(t, err)
+	require.Len(t, selected, 2)
+	require.Equal(t, "pet", selected[0].Name)
+	require.Equal(t, "pets", selected[1].Name)
+
+	require.Nil(t, loadGraphQL([]byte(`{"__schema": "a string"}`)))
+}
+
+func TestLoadGRPC(t *testing.T) {
+	s, err := input(context.Background(), nil, &source{Path: "testdata"}, "grpc://localhost:50051?descriptors=greeter.pb")
+	require.NoError(t, err)
+	require.Equal(t, "grpc://localhost:50051?descriptors=greeter.pb", s.Location)
+
+	server := loadGRPC(s.Content)
+	require.NotNil(t, server)
+	require.Equal(t, "localhost:50051", server.Target)
+	require.False(t, server.TLS)
+
+	tools, err := getGRPCTools(server)
+	require.NoError(t, err)
+
+	byName := map[string]types.Tool{}
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	// Client streaming methods are skipped.
+	require.Equal(t, []string{"Greeter_SayHello", "Greeter_StreamHellos"}, tools[0].Export)
+	require.Equal(t, []string{"Greeter_SayHello", "Greeter_StreamHellos"}, byName["Greeter"].Export)
+	require.NotContains(t, byName, "Greeter_Upload")
+
+	require.True(t, byName["Greeter_SayHello"].IsGRPC())
+	args := byName["Greeter_SayHello"].Arguments
+	require.Equal(t, &openapi3.Types{"string"}, args.Properties["name"].Value.Type)
+	require.Equal(t, &openapi3.Types{"integer"}, args.Properties["count"].Value.Type)
+	require.Equal(t, &openapi3.Types{"array"}, args.Properties["tags"].Value.Type)
+	require.Equal(t, &openapi3.Types{"integer"}, args.Properties["labels"].Value.AdditionalProperties.Schema.Value.Type)
+	require.Equal(t, []any{"HAPPY", "SAD"}, args.Properties["mood"].Value.Enum)
+
+	selected, err := selectOperations(tools, "!*Stream*")
+	require.NoError(t, err)
+	require.Len(t, selected, 1)
+	require.Equal(t, "Greeter_SayHello", selected[0].Name)
+
+	require.Nil(t, loadGRPC([]byte(`{"grpc": null}`)))
+}
+
 func TestHelloWorld(t *testing.T) {
 	prg, err := Program(context.Background(),
 		"https://raw.githubusercontent.com/ibuildthecloud/test/bafe5a62174e8a0ea162277dcfe3a2ddb7eea928/example/sub/tool.gpt",
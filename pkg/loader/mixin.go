@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// applyMixins adds the instructions, tools, agents, and context of the mixins of a linked tool to it. The references of
+// a mixin keep pointing at the tools they were resolved to, so a mixin from another file can use tools relative to it.
+func applyMixins(prg *types.Program, tool types.Tool) (types.Tool, error) {
+	for _, name := range tool.Mixins {
+		for _, ref := range tool.ToolMapping[name] {
+			mixin, ok := prg.ToolSet[ref.ToolID]
+			if !ok {
+				return tool, fmt.Errorf("mixin %s was not loaded", name)
+			}
+
+			if instructions := strings.TrimSpace(mixin.Instructions); instructions != "" {
+				if mixin.IsCommand() {
+					return tool, fmt.Errorf("mixin %s runs a command, a mixin can only have instructions", name)
+				}
+				if tool.IsCommand() {
+					return tool, fmt.Errorf("the instructions of mixin %s can't be added to a tool that runs a command", name)
+				}
+				if tool.Instructions == "" {
+					tool.Instructions = instructions
+				} else {
+					tool.Instructions += "\n\n" + instructions
+				}
+			}
+
+			tool.Tools = addMixinRefs(&tool, mixin, tool.Tools, mixin.Tools)
+			tool.Agents = addMixinRefs(&tool, mixin, tool.Agents, mixin.Agents)
+			tool.Context = addMixinRefs(&tool, mixin, tool.Context, mixin.Context)
+		}
+	}
+	return tool, nil
+}
+
+// addMixinRefs adds the references of a mixin that the tool doesn't have, with the tools they were resolved to.
+func addMixinRefs(tool *types.Tool, mixin types.Tool, names, mixinNames []string) []string {
+	for _, name := range mixinNames {
+		if slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+		// A name that the tool already resolved stays with its own tool.
+		if _, ok := tool.ToolMapping[name]; !ok {
+			tool.ToolMapping[name] = mixin.ToolMapping[name]
+		}
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixins(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "shared"), 0755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shared", "rules.gpt"), []byte(`
+Name: safety-rules
+Tools: lookup
+Context: policy
+
+Never share personal data.
+
+---
+Name: lookup
+Param: query: What to look up
+
+#!sys.echo ${query}
+
+---
+Name: policy
+
+#!sys.echo Be polite.
+`), 0644))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.gpt"), []byte(`
+Name: agent
+Mixins: safety-rules from ./shared/rules.gpt, style
+Tools: search
+
+Answer the question.
+
+---
+Name: style
+Tools: search
+
+Keep answers short.
+
+---
+Name: search
+
+#!sys.echo results
+`), 0644))
+
+	prg, err := Program(context.Background(), filepath.Join(dir, "agent.gpt"), "")
+	require.NoError(t, err)
+
+	tool := prg.ToolSet[prg.EntryToolID]
+	require.Equal(t, "Answer the question.\n\nNever share personal data.\n\nKeep answers short.", tool.Instructions)
+	require.Equal(t, []string{"search", "lookup"}, tool.Tools)
+	require.Equal(t, []string{"policy"}, tool.Context)
+
+	// The references of the mixin are resolved relative to the file it is in.
+	require.Len(t, tool.ToolMapping["lookup"], 1)
+	require.Equal(t, "lookup", prg.ToolSet[tool.ToolMapping["lookup"][0].ToolID].Name)
+	require.Equal(t, filepath.Join(dir, "shared", "rules.gpt"), prg.ToolSet[tool.ToolMapping["policy"][0].ToolID].Source.Location)
+
+	tools, err := tool.GetCompletionTools(prg)
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+}
+
+func TestMixinCommand(t *testing.T) {
+	_, err := ProgramFromSource(context.Background(), `
+Name: agent
+Mixins: script
+
+Do things.
+
+---
+Name: script
+
+#!/bin/bash
+echo hi
+`, "")
+	require.ErrorContains(t, err, "mixin script runs a command, a mixin can only have instructions")
+
+	_, err = ProgramFromSource(context.Background(), `
+Name: script
+Mixins: rules
+
+#!/bin/bash
+echo hi
+
+---
+Name: rules
+
+Be careful.
+`, "")
+	require.ErrorContains(t, err, "the instructions of mixin rules can't be added to a tool that runs a command")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	kyaml "sigs.k8s.io/yaml"
 )
 
 // getOpenAPITools parses an OpenAPI definition and generates a set of tools from it.
@@ -62,6 +64,7 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 	var (
 		toolNames    []string
 		tools        []types.Tool
+		tags         = map[string][]string{}
 		operationNum = 1 // Each tool gets an operation number, beginning with 1
 	)
 
@@ -279,13 +282,13 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 			}
 
 			if len(infos) > 0 {
+				operationServerURL, err := url.Parse(operationServer)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse operation server URL: %w", err)
+				}
 				// Set up credential tools for the first set of infos.
 				for _, info := range infos[0] {
-					operationServerURL, err := url.Parse(operationServer)
-					if err != nil {
-						return nil, fmt.Errorf("failed to parse operation server URL: %w", err)
-					}
-					tool.Credentials = info.GetCredentialToolStrings(operationServerURL.Hostname())
+					tool.Credentials = append(tool.Credentials, info.GetCredentialToolStrings(operationServerURL.Hostname())...)
 				}
 			}
 
@@ -293,9 +296,51 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 			toolNames = append(toolNames, tool.Parameters.Name)
 			tools = append(tools, tool)
 			operationNum++
+
+			for _, tag := range operation.Tags {
+				if !slices.Contains(tags[tag], tool.Parameters.Name) {
+					tags[tag] = append(tags[tag], tool.Parameters.Name)
+				}
+			}
 		}
 	}
 
+	// Each tag becomes a tool that exports the operations with that tag, so that a group of operations can be
+	// referenced by the tag name. Tags with the same name as an operation are skipped.
+	tagNames := make([]string, 0, len(tags))
+	for tag := range tags {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Strings(tagNames)
+
+	for _, tag := range tagNames {
+		if containsFold(toolNames, tag) {
+			log.Debugf("skipping tag %s of OpenAPI spec because an operation has the same name", tag)
+			continue
+		}
+
+		desc := fmt.Sprintf("This is a tool set for the %s operations of the %s OpenAPI spec", tag, t.Info.Title)
+		for _, def := range t.Tags {
+			if def != nil && def.Name == tag && def.Description != "" {
+				desc = def.Description
+			}
+		}
+
+		tools = append(tools, types.Tool{
+			ToolDef: types.ToolDef{
+				Parameters: types.Parameters{
+					Name:        tag,
+					Description: desc,
+					Export:      tags[tag],
+				},
+			},
+			Source: types.ToolSource{
+				LineNo: operationNum,
+			},
+		})
+		operationNum++
+	}
+
 	// The first tool we generate is a special tool that just exports all the others.
 	exportTool := types.Tool{
 		ToolDef: types.ToolDef{
@@ -352,3 +397,150 @@ func parseServer(server *openapi3.Server) (string, error) {
 	}
 	return s, nil
 }
+
+// selectOperations returns the operations of an OpenAPI spec, GraphQL schema, or gRPC server that are selected by a
+// space separated list of operation names, wildcards, and groups such as tags. Terms that start with ! exclude the
+// operations they match, and a list of only exclusions selects every other operation.
+func selectOperations(tools []types.Tool, selector string) ([]types.Tool, error) {
+	var (
+		operations []types.Tool
+		tags       = map[string][]string{}
+	)
+	for i, tool := range tools {
+		if tool.IsOpenAPI() || tool.IsGraphQL() || tool.IsGRPC() {
+			operations = append(operations, tool)
+		} else if i > 0 {
+			tags[strings.ToLower(tool.Parameters.Name)] = tool.Parameters.Export
+		}
+	}
+
+	matches := func(term string, tool types.Tool) (bool, error) {
+		if exports, ok := tags[strings.ToLower(term)]; ok {
+			return slices.Contains(exports, tool.Parameters.Name), nil
+		}
+		return filepath.Match(strings.ToLower(term), strings.ToLower(tool.Parameters.Name))
+	}
+
+	var includes, excludes []string
+	for _, term := range strings.Fields(selector) {
+		if exclude, ok := strings.CutPrefix(term, "!"); ok {
+			excludes = append(excludes, exclude)
+		} else {
+			includes = append(includes, term)
+		}
+	}
+
+	var result []types.Tool
+operations:
+	for _, tool := range operations {
+		included := len(includes) == 0
+		for _, term := range includes {
+			if ok, err := matches(term, tool); err != nil {
+				return nil, err
+			} else if ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			continue
+		}
+		for _, term := range excludes {
+			if ok, err := matches(term, tool); err != nil {
+				return nil, err
+			} else if ok {
+				continue operations
+			}
+		}
+		result = append(result, tool)
+	}
+	return result, nil
+}
+
+// downgradeOpenAPI31 converts the parts of an OpenAPI v3.1 spec that v3.0 can not parse to their v3.0 equivalents.
+// Specs of other versions are returned unchanged.
+func downgradeOpenAPI31(data []byte) ([]byte, error) {
+	var fragment struct {
+		OpenAPI string `json:"openapi,omitempty"`
+	}
+	if err := kyaml.Unmarshal(data, &fragment); err != nil || !strings.HasPrefix(fragment.OpenAPI, "3.1") {
+		return data, nil
+	}
+
+	jsondata, err := kyaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(jsondata, &doc); err != nil {
+		return nil, err
+	}
+	downgradeSchemas(doc)
+	doc["openapi"] = "3.0.3"
+	// Webhooks are not operations that can be called, and are not part of v3.0.
+	delete(doc, "webhooks")
+
+	return json.Marshal(doc)
+}
+
+// downgradeSchemas walks the spec and rewrites the JSON Schema keywords whose meaning changed in OpenAPI v3.1.
+func downgradeSchemas(v any) {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			downgradeSchemas(item)
+		}
+	case map[string]any:
+		for _, bound := range []struct{ exclusive, inclusive string }{
+			{"exclusiveMinimum", "minimum"},
+			{"exclusiveMaximum", "maximum"},
+		} {
+			// v3.1 sets the bound in the exclusive keyword, while v3.0 uses a boolean that applies to the inclusive one.
+			if n, ok := v[bound.exclusive].(float64); ok {
+				v[bound.inclusive] = n
+				v[bound.exclusive] = true
+			}
+		}
+
+		if types, ok := v["type"].([]any); ok {
+			var nonNull []any
+			for _, t := range types {
+				if t == "null" {
+					v["nullable"] = true
+				} else {
+					nonNull = append(nonNull, t)
+				}
+			}
+			if len(nonNull) == 1 {
+				v["type"] = nonNull[0]
+			} else {
+				v["type"] = nonNull
+			}
+		}
+
+		if c, ok := v["const"]; ok {
+			v["enum"] = []any{c}
+			delete(v, "const")
+		}
+
+		if examples, ok := v["examples"].([]any); ok {
+			// Schemas have a list of examples in v3.1, while media types and parameters have a map in both versions.
+			if len(examples) > 0 {
+				v["example"] = examples[0]
+			}
+			delete(v, "examples")
+		}
+
+		for key, value := range v {
+			if named, ok := value.(map[string]any); ok && slices.Contains([]string{"properties", "patternProperties", "schemas", "$defs"}, key) {
+				// The keys of these maps are names, which can be the same as the keywords above.
+				for _, schema := range named {
+					downgradeSchemas(schema)
+				}
+				continue
+			}
+			downgradeSchemas(value)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
@@ -116,7 +117,7 @@ func loadURL(ctx context.Context, cache *cache.Client, base *source, name string
 		return nil, false, fmt.Errorf("error loading %s: %v", url, err)
 	}
 
-	log.Debugf("opened %s", url)
+	log.WithContext(ctx).Debugf("opened %s", url)
 
 	result := &source{
 		Content:  data,
@@ -141,7 +142,7 @@ func getWithDefaults(req *http.Request) ([]byte, error) {
 	originalPath := req.URL.Path
 
 	// First, try to get the original path as is. It might be an OpenAPI definition.
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := proxy.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -153,13 +154,18 @@ func getWithDefaults(req *http.Request) ([]byte, error) {
 		}
 	}
 
+	// GraphQL endpoints are introspected to generate the tools of their schema.
+	if strings.HasSuffix(originalPath, "graphql") {
+		return introspectGraphQL(req)
+	}
+
 	for i, def := range types.DefaultFiles {
 		base := path.Base(originalPath)
 		if !strings.Contains(base, ".") {
 			req.URL.Path = path.Join(originalPath, def)
 		}
 
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := proxy.Client.Do(req)
 		if err != nil {
 			return nil, err
 		}
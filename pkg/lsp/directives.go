@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"strings"
+)
+
+type directive struct {
+	Name        string
+	Description string
+	// Keys are the normalized spellings accepted by the parser
+	Keys []string
+	// References is true if the value is a comma separated list of tool references
+	References bool
+}
+
+var directives = []directive{
+	{Name: "Name", Description: "The name of the tool.", Keys: []string{"name"}},
+	{Name: "Description", Description: "The description of the tool. This is used by the LLM to decide when to call the tool.", Keys: []string{"description"}},
+	{Name: "Model Name", Description: "The LLM model to use.", Keys: []string{"model", "modelname"}},
+	{Name: "Global Model Name", Description: "The LLM model to use for all the tools.", Keys: []string{"globalmodel", "globalmodelname"}},
+	{Name: "Model Provider", Description: "Set to `true` if this tool provides models.", Keys: []string{"modelprovider"}},
+	{Name: "Internal Prompt", Description: "Set to `false` to disable the built-in system prompt for this tool.", Keys: []string{"internalprompt"}},
+	{Name: "Chat", Description: "Set to `true` to enable an interactive chat session for the tool.", Keys: []string{"chat"}},
+	{Name: "Type", Description: "Set to `agents` to route the input of this tool to one of its agents instead of running its instructions.", Keys: []string{"type"}},
+	{Name: "Breakpoint", Description: "Set to `true` to pause the run before this tool is called, to inspect, skip, or change its input.", Keys: []string{"breakpoint"}},
+	{Name: "Tools", Description: "A comma-separated list of tools that are available to be called by this tool.", Keys: []string{"tool", "tools"}, References: true},
+	{Name: "Global Tools", Description: "A comma-separated list of tools that are available to be called by all tools.", Keys: []string{"globaltool", "globaltools"}, References: true},
+	{Name: "Share Tools", Description: "A comma-separated list of tools that are made available to the tools that reference this tool.", Keys: []string{"export", "exporttool", "exports", "exporttools", "sharetool", "sharetools"}, References: true},
+	{Name: "Agents", Description: "A comma-separated list of agents that this tool can hand off to.", Keys: []string{"agent", "agents"}, References: true},
+	{Name: "Context", Description: "A comma-separated list of tools whose output is added to the system prompt of this tool.", Keys: []string{"context"}, References: true},
+	{Name: "Mixins", Description: "A comma-separated list of tools whose instructions, tools, agents, and context are added to this tool.", Keys: []string{"mixin", "mixins"}, References: true},
+	{Name: "Share Context", Description: "A comma-separated list of context tools that are shared with the tools that reference this tool.", Keys: []string{"exportcontext", "exportcontexts", "sharecontext", "sharecontexts"}, References: true},
+	{Name: "Input Filters", Description: "A comma-separated list of tools that modify the input of this tool.", Keys: []string{"inputfilter", "inputfilters"}, References: true},
+	{Name: "Share Input Filters", Description: "A comma-separated list of input filters that are shared with the tools that reference this tool.", Keys: []string{"shareinputfilter", "shareinputfilters"}, References: true},
+	{Name: "Output Filters", Description: "A comma-separated list of tools that modify the output of this tool.", Keys: []string{"outputfilter", "outputfilters"}, References: true},
+	{Name: "Share Output Filters", Description: "A comma-separated list of output filters that are shared with the tools that reference this tool.", Keys: []string{"shareoutputfilter", "shareoutputfilters"}, References: true},
+	{Name: "Credentials", Description: "A credential tool to run before this tool.", Keys: []string{"credentials", "creds", "credential", "cred"}, References: true},
+	{Name: "Binary", Description: "A prebuilt executable of the tool for a platform, as `<os>/<arch> <url> sha256:<digest>`. May be repeated.", Keys: []string{"binary", "binaries"}},
+	{Name: "Env Vars", Description: "Environment variables that the tool needs, such as `API_URL (the URL of the API), DEBUG?`. The user is asked for the required ones that are not set.", Keys: []string{"envvars", "envvar"}},
+	{Name: "Args", Description: "An argument of the tool in the format `name: description`.", Keys: []string{"args", "arg", "param", "params", "parameters", "parameter"}},
+	{Name: "Image Args", Description: "A comma-separated list of arguments whose values are the URL or path of an image that is sent to vision-capable models.", Keys: []string{"imagearg", "imageargs", "imageparam", "imageparams", "imageparameter", "imageparameters"}},
+	{Name: "File Args", Description: "A comma-separated list of arguments whose values are the path of a file in the workspace, or a file that another tool returned, which is passed to the tool as a file instead of its content.", Keys: []string{"filearg", "fileargs", "fileparam", "fileparams", "fileparameter", "fileparameters"}},
+	{Name: "Working Dir", Description: "The directory that the tool runs in, such as `${GPTSCRIPT_WORKSPACE_DIR}/data`. The tools that it calls run in it too, and relative paths of the file tools are relative to it.", Keys: []string{"workingdir", "workingdirectory", "workdir"}},
+	{Name: "Max Tokens", Description: "The maximum number of tokens that can be generated by the LLM.", Keys: []string{"maxtoken", "maxtokens"}},
+	{Name: "Cache", Description: "Set to `false` to disable caching of LLM responses for this tool.", Keys: []string{"cache"}},
+	{Name: "JSON Response", Description: "Set to `true` to have the LLM respond in JSON, or to a JSON schema that the response must match.", Keys: []string{"jsonmode", "json", "jsonoutput", "jsonformat", "jsonresponse"}},
+	{Name: "Temperature", Description: "The temperature of the LLM, a floating-point number.", Keys: []string{"temperature"}},
+	{Name: "Top P", Description: "The nucleus sampling probability of the LLM, between 0 and 1.", Keys: []string{"topp"}},
+	{Name: "Stop", Description: "A comma-separated list of up to 4 sequences that end the response of the LLM. Quote sequences with commas or spaces, such as `\"\\n\\n\"`.", Keys: []string{"stop", "stopsequence", "stopsequences"}},
+	{Name: "Frequency Penalty", Description: "Penalizes tokens of the LLM by how often they already appeared, between -2 and 2.", Keys: []string{"frequencypenalty"}},
+	{Name: "Presence Penalty", Description: "Penalizes tokens of the LLM that already appeared, between -2 and 2.", Keys: []string{"presencepenalty"}},
+	{Name: "Parallel Tool Calls", Description: "Set to `false` to have the LLM call one tool at a time, for tools whose calls must not run at the same time.", Keys: []string{"paralleltoolcalls", "paralleltools"}},
+	{Name: "Tool Choice", Description: "Set to `required` to have the LLM call at least one tool in its first response, `none` to call none, or the name of one of the tools to call it.", Keys: []string{"toolchoice"}},
+	{Name: "Logprobs", Description: "Set to `true` to get the log probabilities of the tokens of the responses of the LLM, in the chat events of the run.", Keys: []string{"logprobs"}},
+	{Name: "Top Logprobs", Description: "The number of most likely tokens, up to 20, whose log probabilities are returned at each position of the responses of the LLM. Implies `Logprobs`.", Keys: []string{"toplogprobs"}},
+	{Name: "Examples", Description: "An example input and the expected response of the LLM, as `<input> => <output>`, that are sent to the LLM before the real input. May be repeated.", Keys: []string{"example", "examples"}},
+	{Name: "Example Input", Description: "An example input sent to the LLM before the real input, for inputs that contain ` => `. Must be followed by an `Example Output`.", Keys: []string{"exampleinput"}},
+	{Name: "Example Output", Description: "The expected response to the preceding `Example Input`.", Keys: []string{"exampleoutput"}},
+	{Name: "Artifacts", Description: "A comma-separated list of files or glob patterns in the workspace that are collected as outputs of the run.", Keys: []string{"artifact", "artifacts"}},
+	{Name: "Validate", Description: "A tool or inline JSON schema that validates the response of the LLM. Invalid responses are sent back to the LLM to be corrected.", Keys: []string{"validate", "validator"}, References: true},
+	{Name: "Validate Retries", Description: "The number of times the LLM is asked to correct an invalid response before the tool fails, defaults to 3.", Keys: []string{"validateretries", "validateretry"}},
+	{Name: "Output Regex", Description: "A regular expression that constrains the response of local models that support guided decoding.", Keys: []string{"outputregex"}},
+	{Name: "Choices", Description: "The number of candidate responses the LLM generates, one of which is chosen as the response.", Keys: []string{"choices"}},
+	{Name: "Best Of", Description: "The number of completions requested in parallel, optionally followed by `judge=<tool>` for the tool that chooses the response.", Keys: []string{"bestof"}},
+	{Name: "Choose", Description: "How the response is chosen from the candidates: `first`, `shortest`, `longest`, `majority` (the default), or a judge tool.", Keys: []string{"choose"}, References: true},
+	{Name: "Output Grammar", Description: "A grammar that constrains the response of local models that support guided decoding.", Keys: []string{"outputgrammar"}},
+}
+
+// lookupDirective finds the directive for the key of a tool parameter line.
+func lookupDirective(key string) (directive, bool) {
+	key = strings.TrimSpace(strings.ToLower(strings.ReplaceAll(key, " ", "")))
+	for _, d := range directives {
+		for _, k := range d.Keys {
+			if k == key {
+				return d, true
+			}
+		}
+	}
+	return directive{}, false
+}
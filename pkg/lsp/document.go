@@ -0,0 +1,387 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/parser"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+var (
+	sepRegex  = regexp.MustCompile(`^\s*---+\s*$`)
+	skipRegex = regexp.MustCompile(`^![-\w]+\s*$`)
+)
+
+// paramLine is a line in the preamble of a tool that sets a parameter.
+type paramLine struct {
+	line       int
+	directive  directive
+	keyEnd     int
+	valueStart int
+}
+
+// reference is a tool reference in the value of a parameter line. Start and end are byte offsets in the line.
+type reference struct {
+	line  int
+	start int
+	end   int
+	text  string
+}
+
+type document struct {
+	uri      string
+	path     string
+	lines    []string
+	tools    []types.Tool
+	params   []paramLine
+	parseErr error
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:   uri,
+		path:  uriToPath(uri),
+		lines: strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"),
+	}
+
+	d.tools, d.parseErr = parser.ParseTools(strings.NewReader(text), parser.Options{
+		Location: d.path,
+	})
+	d.params = findParams(d.lines)
+	return d
+}
+
+// findParams finds the parameter lines in the preamble of each tool following the same rules as the parser.
+func findParams(lines []string) (result []paramLine) {
+	var (
+		inBody, seenParam, skip bool
+	)
+
+	for i, line := range lines {
+		if sepRegex.MatchString(line) && (!skip || line == "---") {
+			inBody, seenParam, skip = false, false, false
+			continue
+		}
+		if inBody || skip {
+			continue
+		}
+		if strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "#!") {
+			continue
+		}
+		if !seenParam && skipRegex.MatchString(line) {
+			skip = true
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, _, ok := strings.Cut(line, ":")
+		if !ok {
+			inBody = true
+			continue
+		}
+		d, ok := lookupDirective(key)
+		if !ok {
+			inBody = true
+			continue
+		}
+
+		seenParam = true
+		valueStart := len(key) + 1
+		for valueStart < len(line) && line[valueStart] == ' ' {
+			valueStart++
+		}
+		result = append(result, paramLine{
+			line:       i,
+			directive:  d,
+			keyEnd:     len(key),
+			valueStart: valueStart,
+		})
+	}
+
+	return
+}
+
+func (d *document) param(line int) (paramLine, bool) {
+	for _, p := range d.params {
+		if p.line == line {
+			return p, true
+		}
+	}
+	return paramLine{}, false
+}
+
+// references returns the tool references in the value of the parameter line.
+func (d *document) references(p paramLine) (result []reference) {
+	if !p.directive.References {
+		return nil
+	}
+
+	line := d.lines[p.line]
+	value := line[p.valueStart:]
+
+	// Credentials, Validate, and Choose take a single reference and the value is not split by the parser
+	parts := []string{value}
+	switch p.directive.Name {
+	case "Credentials":
+	case "Validate":
+		// An inline JSON schema is not a reference
+		if strings.HasPrefix(strings.TrimSpace(value), "{") {
+			return nil
+		}
+	case "Choose":
+		// A built-in strategy is not a reference
+		if types.IsChooseStrategy(value) {
+			return nil
+		}
+	default:
+		parts = strings.Split(value, ",")
+	}
+
+	offset := p.valueStart
+	for _, part := range parts {
+		text := strings.TrimSpace(part)
+		if text != "" {
+			start := offset + strings.Index(part, text)
+			result = append(result, reference{
+				line:  p.line,
+				start: start,
+				end:   start + len(text),
+				text:  text,
+			})
+		}
+		offset += len(part) + 1
+	}
+
+	return
+}
+
+func (d *document) referenceAt(pos position) (reference, bool) {
+	p, ok := d.param(pos.Line)
+	if !ok {
+		return reference{}, false
+	}
+	offset := byteOffset(d.lines[pos.Line], pos.Character)
+	for _, ref := range d.references(p) {
+		if offset >= ref.start && offset <= ref.end {
+			return ref, true
+		}
+	}
+	return reference{}, false
+}
+
+func (d *document) localTool(name string) (types.Tool, bool) {
+	for _, tool := range d.tools {
+		if tool.Name != "" && strings.EqualFold(tool.Name, name) {
+			return tool, true
+		}
+	}
+	return types.Tool{}, false
+}
+
+// nameLine returns the line that starts the tool, preferring the line declaring the name.
+func (d *document) nameLine(tool types.Tool) int {
+	start := max(tool.Source.LineNo-1, 0)
+	for _, p := range d.params {
+		if p.line >= start && p.directive.Name == "Name" {
+			return p.line
+		}
+	}
+	return start
+}
+
+// resolve returns the location of the target of the reference, if it can be found locally.
+func (d *document) resolve(ref string) (location, string, bool) {
+	name, _ := types.SplitArg(ref)
+	if tool, ok := d.localTool(name); ok {
+		return d.lineLocation(d.nameLine(tool)), tool.Description, true
+	}
+
+	toolName, subTool := types.SplitToolRef(ref)
+	path, ok := d.localPath(toolName)
+	if !ok {
+		return location{}, "", false
+	}
+
+	target := location{
+		URI: pathToURI(path),
+	}
+	if subTool == "" {
+		return target, "", true
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return location{}, "", false
+	}
+	other := newDocument(target.URI, string(data))
+	if tool, ok := other.localTool(subTool); ok {
+		return other.lineLocation(other.nameLine(tool)), tool.Description, true
+	}
+	return target, "", true
+}
+
+// localPath returns the path of a reference to a local file or directory, if it exists.
+func (d *document) localPath(toolName string) (string, bool) {
+	if d.path == "" || !isLocalReference(toolName) {
+		return "", false
+	}
+
+	path := toolName
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(d.path), toolName)
+	}
+	if s, err := os.Stat(path); err == nil && s.IsDir() {
+		path = filepath.Join(path, "tool.gpt")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+func (d *document) lineLocation(line int) location {
+	return location{
+		URI:   d.uri,
+		Range: d.lineRange(line),
+	}
+}
+
+func (d *document) lineRange(line int) textRange {
+	var end int
+	if line < len(d.lines) {
+		end = charOffset(d.lines[line], len(d.lines[line]))
+	}
+	return textRange{
+		Start: position{Line: line},
+		End:   position{Line: line, Character: end},
+	}
+}
+
+func (d *document) referenceRange(ref reference) textRange {
+	line := d.lines[ref.line]
+	return textRange{
+		Start: position{Line: ref.line, Character: charOffset(line, ref.start)},
+		End:   position{Line: ref.line, Character: charOffset(line, ref.end)},
+	}
+}
+
+func (d *document) diagnostics() (result []diagnostic) {
+	if d.parseErr != nil {
+		line := 0
+		if errLine := (*parser.ErrLine)(nil); errors.As(d.parseErr, &errLine) && errLine.Line > 0 {
+			line = errLine.Line - 1
+		}
+		result = append(result, diagnostic{
+			Range:    d.lineRange(line),
+			Severity: severityError,
+			Source:   "gptscript",
+			Message:  d.parseErr.Error(),
+		})
+	}
+
+	for _, p := range d.params {
+		for _, ref := range d.references(p) {
+			if msg := d.checkReference(ref.text); msg != "" {
+				result = append(result, diagnostic{
+					Range:    d.referenceRange(ref),
+					Severity: severityWarning,
+					Source:   "gptscript",
+					Message:  msg,
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// checkReference returns a message describing why the reference can not be resolved, or an empty string. Only
+// references that can be checked without going to the network are checked.
+func (d *document) checkReference(ref string) string {
+	name, _ := types.SplitArg(ref)
+	if strings.HasPrefix(name, "sys.") {
+		if _, ok := builtin.Builtin(name); !ok {
+			return fmt.Sprintf("unknown system tool %s", name)
+		}
+		return ""
+	}
+
+	if _, ok := d.localTool(name); ok {
+		return ""
+	}
+
+	toolName, _ := types.SplitToolRef(ref)
+	if d.path == "" || !isLocalReference(toolName) {
+		return ""
+	}
+	if _, ok := d.localPath(toolName); ok {
+		return ""
+	}
+	if !strings.ContainsAny(toolName, "./") {
+		return fmt.Sprintf("no tool named %s in this file", toolName)
+	}
+	return fmt.Sprintf("file %s not found", toolName)
+}
+
+// isLocalReference returns true if the reference is to a local tool or file instead of a remote location.
+func isLocalReference(toolName string) bool {
+	if strings.Contains(toolName, "://") {
+		return false
+	}
+	if strings.HasPrefix(toolName, ".") || strings.HasPrefix(toolName, "/") {
+		return true
+	}
+	first, _, ok := strings.Cut(toolName, "/")
+	// A first path element with a dot looks like a host, such as github.com
+	return !ok || !strings.Contains(first, ".")
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// byteOffset converts a UTF-16 character offset, as used by the protocol, to a byte offset in the line.
+func byteOffset(line string, char int) int {
+	var units int
+	for i, r := range line {
+		if units >= char {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// charOffset converts a byte offset in the line to a UTF-16 character offset.
+func charOffset(line string, offset int) int {
+	var units int
+	for len(line) > 0 && offset > 0 {
+		r, size := utf8.DecodeRuneInString(line)
+		units += len(utf16.Encode([]rune{r}))
+		line = line[size:]
+		offset -= size
+	}
+	return units
+}
@@ -0,0 +1,344 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+)
+
+// Server is a Language Server Protocol server for .gpt files. It communicates over a single stream, typically
+// stdin and stdout of the process started by the editor.
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	writeLock sync.Mutex
+	docs      map[string]*document
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*document{},
+	}
+}
+
+// Serve handles requests until the client sends exit, the input is closed, or the context is canceled.
+func (s *Server) Serve(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := readMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			log.Errorf("invalid message: %v", err)
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit requested without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(req)
+		if len(req.ID) == 0 {
+			// Notifications have no response
+			if err != nil {
+				log.Errorf("failed to handle %s: %v", req.Method, err)
+			}
+			continue
+		}
+
+		if err := s.respond(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) (any, error) {
+	log.Debugf("handling %s", req.Method)
+
+	switch req.Method {
+	case "initialize":
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync: textDocumentSyncFull,
+				CompletionProvider: completionOptions{
+					TriggerCharacters: []string{":", ",", " "},
+				},
+				DefinitionProvider: true,
+				HoverProvider:      true,
+			},
+			ServerInfo: serverInfo{
+				Name:    version.ProgramName,
+				Version: version.Get().String(),
+			},
+		}, nil
+	case "initialized", "$/setTrace", "$/cancelRequest", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Full sync, so the last change is the whole document
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case "textDocument/completion":
+		doc, params, err := s.positionParams(req)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.completion(params.Position), nil
+	case "textDocument/definition":
+		doc, params, err := s.positionParams(req)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.definition(params.Position), nil
+	case "textDocument/hover":
+		doc, params, err := s.positionParams(req)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.hover(params.Position), nil
+	}
+
+	if len(req.ID) == 0 {
+		// Unknown notifications are ignored
+		return nil, nil
+	}
+	return nil, &responseError{
+		Code:    errMethodNotFound,
+		Message: fmt.Sprintf("method not found: %s", req.Method),
+	}
+}
+
+func decodeParams(req request, params any) error {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return &responseError{
+			Code:    errInvalidParams,
+			Message: fmt.Sprintf("invalid params for %s: %v", req.Method, err),
+		}
+	}
+	return nil
+}
+
+func (s *Server) positionParams(req request) (*document, textDocumentPositionParams, error) {
+	var params textDocumentPositionParams
+	if err := decodeParams(req, &params); err != nil {
+		return nil, params, err
+	}
+	doc := s.docs[params.TextDocument.URI]
+	if doc != nil && params.Position.Line >= len(doc.lines) {
+		return nil, params, nil
+	}
+	return doc, params, nil
+}
+
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diagnostics := doc.diagnostics()
+	if diagnostics == nil {
+		diagnostics = []diagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) respond(id json.RawMessage, result any, err error) error {
+	resp := response{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+
+	if err != nil {
+		if respErr := (*responseError)(nil); errors.As(err, &respErr) {
+			resp.Error = respErr
+		} else {
+			resp.Error = &responseError{
+				Code:    errInternal,
+				Message: err.Error(),
+			}
+		}
+	} else {
+		resp.Result, err = json.Marshal(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.write(resp)
+}
+
+func (s *Server) notify(method string, params any) error {
+	return s.write(notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (s *Server) write(msg any) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return writeMessage(s.out, msg)
+}
+
+func (d *document) completion(pos position) []completionItem {
+	line := d.lines[pos.Line]
+	prefix := line[:byteOffset(line, pos.Character)]
+
+	key, _, ok := strings.Cut(prefix, ":")
+	if !ok {
+		if strings.TrimSpace(line) != strings.TrimSpace(prefix) {
+			// The cursor is not at the end of the line, so this is not a new directive
+			return nil
+		}
+		result := make([]completionItem, 0, len(directives))
+		for _, directive := range directives {
+			result = append(result, completionItem{
+				Label:      directive.Name,
+				Kind:       completionKindKeyword,
+				InsertText: directive.Name + ": ",
+				Documentation: &markupContent{
+					Kind:  "markdown",
+					Value: directive.Description,
+				},
+			})
+		}
+		return result
+	}
+
+	directive, ok := lookupDirective(key)
+	if !ok || !directive.References {
+		return nil
+	}
+
+	var result []completionItem
+	for _, tool := range d.tools {
+		if tool.Name == "" {
+			continue
+		}
+		result = append(result, completionItem{
+			Label:  tool.Name,
+			Kind:   completionKindFunction,
+			Detail: tool.Description,
+		})
+	}
+	for _, tool := range builtin.ListTools() {
+		result = append(result, completionItem{
+			Label:  tool.Name,
+			Kind:   completionKindFunction,
+			Detail: tool.Description,
+		})
+	}
+	return result
+}
+
+func (d *document) definition(pos position) []location {
+	ref, ok := d.referenceAt(pos)
+	if !ok {
+		return nil
+	}
+	loc, _, ok := d.resolve(ref.text)
+	if !ok {
+		return nil
+	}
+	return []location{loc}
+}
+
+func (d *document) hover(pos position) *hover {
+	p, ok := d.param(pos.Line)
+	if !ok {
+		return nil
+	}
+
+	line := d.lines[pos.Line]
+	if offset := byteOffset(line, pos.Character); offset <= p.keyEnd {
+		r := textRange{
+			Start: position{Line: pos.Line},
+			End:   position{Line: pos.Line, Character: charOffset(line, p.keyEnd)},
+		}
+		return &hover{
+			Contents: markupContent{
+				Kind:  "markdown",
+				Value: fmt.Sprintf("**%s**\n\n%s", p.directive.Name, p.directive.Description),
+			},
+			Range: &r,
+		}
+	}
+
+	ref, ok := d.referenceAt(pos)
+	if !ok {
+		return nil
+	}
+
+	var description string
+	if name, _ := types.SplitArg(ref.text); strings.HasPrefix(name, "sys.") {
+		tool, _ := builtin.Builtin(name)
+		description = tool.Description
+	} else if _, desc, ok := d.resolve(ref.text); ok {
+		description = desc
+	} else {
+		return nil
+	}
+
+	r := d.referenceRange(ref)
+	value := fmt.Sprintf("**%s**", ref.text)
+	if description != "" {
+		value += "\n\n" + description
+	}
+	return &hover{
+		Contents: markupContent{
+			Kind:  "markdown",
+			Value: value,
+		},
+		Range: &r,
+	}
+}
@@ -0,0 +1,118 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScript = `tools: helper, missing, sys.read, ./other.gpt
+
+Use the helper
+
+---
+name: helper
+description: Helps with things
+
+Help
+`
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.gpt"), []byte("Other tool\n"), 0644))
+	uri := pathToURI(filepath.Join(dir, "test.gpt"))
+
+	in := &bytes.Buffer{}
+	send := func(id int, method string, params any) {
+		msg := map[string]any{
+			"jsonrpc": "2.0",
+			"method":  method,
+			"params":  params,
+		}
+		if id != 0 {
+			msg["id"] = id
+		}
+		require.NoError(t, writeMessage(in, msg))
+	}
+
+	at := func(line, character int) map[string]any {
+		return map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": line, "character": character},
+		}
+	}
+
+	send(1, "initialize", map[string]any{})
+	send(0, "initialized", map[string]any{})
+	send(0, "textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "gptscript", "version": 1, "text": testScript},
+	})
+	send(2, "textDocument/definition", at(0, 9))
+	send(3, "textDocument/definition", at(0, 40))
+	send(4, "textDocument/hover", at(0, 2))
+	send(5, "textDocument/completion", at(0, 7))
+	send(6, "unknown/method", map[string]any{})
+	send(7, "shutdown", nil)
+	send(0, "exit", nil)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, NewServer(in, out).Serve(context.Background()))
+
+	var messages []map[string]json.RawMessage
+	reader := bufio.NewReader(out)
+	for reader.Buffered() > 0 || out.Len() > 0 {
+		data, err := readMessage(reader)
+		require.NoError(t, err)
+		var msg map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		messages = append(messages, msg)
+	}
+	require.Len(t, messages, 8)
+
+	var diagnostics publishDiagnosticsParams
+	require.NoError(t, json.Unmarshal(messages[1]["params"], &diagnostics))
+	require.Len(t, diagnostics.Diagnostics, 1)
+	assert.Equal(t, "no tool named missing in this file", diagnostics.Diagnostics[0].Message)
+	assert.Equal(t, textRange{Start: position{Character: 15}, End: position{Character: 22}}, diagnostics.Diagnostics[0].Range)
+
+	var locations []location
+	require.NoError(t, json.Unmarshal(messages[2]["result"], &locations))
+	require.Len(t, locations, 1)
+	assert.Equal(t, uri, locations[0].URI)
+	assert.Equal(t, 5, locations[0].Range.Start.Line)
+
+	require.NoError(t, json.Unmarshal(messages[3]["result"], &locations))
+	require.Len(t, locations, 1)
+	assert.Equal(t, pathToURI(filepath.Join(dir, "other.gpt")), locations[0].URI)
+
+	var h hover
+	require.NoError(t, json.Unmarshal(messages[4]["result"], &h))
+	assert.Contains(t, h.Contents.Value, "**Tools**")
+
+	var items []completionItem
+	require.NoError(t, json.Unmarshal(messages[5]["result"], &items))
+	var labels []string
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	assert.Contains(t, labels, "helper")
+	assert.Contains(t, labels, "sys.read")
+
+	assert.Contains(t, string(messages[6]["error"]), "method not found")
+	assert.Equal(t, "null", string(messages[7]["result"]))
+}
+
+func TestDiagnosticsParseError(t *testing.T) {
+	doc := newDocument("untitled:test", "name: test\ntemperature: hot\n\nbody\n")
+	diagnostics := doc.diagnostics()
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, severityError, diagnostics[0].Severity)
+	assert.Equal(t, 1, diagnostics[0].Range.Start.Line)
+}
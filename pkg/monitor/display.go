@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/counter"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
@@ -22,12 +23,17 @@ import (
 type Options struct {
 	DumpState     string `usage:"Dump the internal execution state to a file"`
 	DebugMessages bool   `usage:"Enable logging of chat completion calls"`
+	// Output receives the output of runs as it is generated.
+	Output io.Writer `usage:"-"`
 }
 
 func Complete(opts ...Options) (result Options) {
 	for _, opt := range opts {
 		result.DumpState = types.FirstSet(opt.DumpState, result.DumpState)
 		result.DebugMessages = types.FirstSet(opt.DebugMessages, result.DebugMessages)
+		if opt.Output != nil {
+			result.Output = opt.Output
+		}
 	}
 	return
 }
@@ -35,20 +41,22 @@ func Complete(opts ...Options) (result Options) {
 type Console struct {
 	dumpState     string
 	printMessages bool
+	output        io.Writer
 	callLock      sync.Mutex
 }
 
 var prettyIDCounter int64
 
-func (c *Console) Start(_ context.Context, prg *types.Program, _ []string, input string) (runner.Monitor, error) {
+func (c *Console) Start(ctx context.Context, prg *types.Program, _ []string, input string) (runner.Monitor, error) {
 	id := counter.Next()
 	mon := newDisplay(c.dumpState, c.printMessages)
+	mon.output = c.output
 	mon.callLock = &c.callLock
 	mon.dump.ID = fmt.Sprint(id)
 	mon.dump.Program = prg
 	mon.dump.Input = input
 
-	log.Fields("runID", mon.dump.ID, "input", input, "program", prg, "type", runner.EventTypeRunStart).Debugf("Run started")
+	log.Fields("runID", mon.dump.ID, "input", input, "program", prg, "labels", gcontext.GetLabels(ctx), "type", runner.EventTypeRunStart).Debugf("Run started")
 	return mon, nil
 }
 
@@ -66,7 +74,39 @@ type display struct {
 	dumpState     string
 	callIDMap     map[string]string
 	callLock      *sync.Mutex
+	output        io.Writer
 	usage         types.Usage
+	cost          float64
+	timing        timingSummary
+}
+
+// timingSummary adds up the timings of the completions of a run that were not cached.
+type timingSummary struct {
+	completions      int
+	timeToFirstToken time.Duration
+	maxFirstToken    time.Duration
+	tokens           int
+	generation       time.Duration
+}
+
+func (t *timingSummary) add(timing *types.Timing) {
+	if timing == nil {
+		return
+	}
+	t.completions++
+	t.timeToFirstToken += timing.TimeToFirstToken
+	t.maxFirstToken = max(t.maxFirstToken, timing.TimeToFirstToken)
+	if timing.TokensPerSecond > 0 {
+		t.tokens += timing.CompletionTokens
+		t.generation += time.Duration(float64(timing.CompletionTokens) / timing.TokensPerSecond * float64(time.Second))
+	}
+}
+
+func (t *timingSummary) tokensPerSecond() float64 {
+	if t.generation <= 0 {
+		return 0
+	}
+	return float64(t.tokens) / t.generation.Seconds()
 }
 
 type livePrinter struct {
@@ -187,6 +227,12 @@ func (d *display) Event(event runner.Event) {
 	d.callLock.Lock()
 	defer d.callLock.Unlock()
 
+	// The summary of the run is not for a call, and its usage was already added up from the calls.
+	if event.Type == runner.EventTypeRunSummary {
+		d.cost = event.Cost
+		return
+	}
+
 	var (
 		currentIndex = -1
 		currentCall  call
@@ -211,6 +257,9 @@ func (d *display) Event(event runner.Event) {
 	}
 
 	log := log.Fields(
+		"runID", d.dump.ID,
+		"callID", currentCall.ID,
+		"tool", event.CallContext.Tool.Name,
 		"id", currentCall.ID,
 		"parentID", currentCall.ParentID,
 		"toolID", currentCall.ToolID,
@@ -235,6 +284,9 @@ func (d *display) Event(event runner.Event) {
 	d.usage.PromptTokens += event.Usage.PromptTokens
 	d.usage.CompletionTokens += event.Usage.CompletionTokens
 	d.usage.TotalTokens += event.Usage.TotalTokens
+	d.usage.CacheReadTokens += event.Usage.CacheReadTokens
+	d.usage.CacheWriteTokens += event.Usage.CacheWriteTokens
+	d.timing.add(event.ChatTiming)
 
 	switch event.Type {
 	case runner.EventTypeCallStart:
@@ -247,10 +299,26 @@ func (d *display) Event(event runner.Event) {
 		d.livePrinter.progressEnd(currentCall)
 	case runner.EventTypeCallProgress:
 		d.livePrinter.print(event, currentCall)
+	case runner.EventTypeRunOutput:
+		if d.output != nil {
+			_, _ = io.WriteString(d.output, event.Content)
+		}
 	case runner.EventTypeCallContinue:
 		d.livePrinter.progressStart(currentCall)
 		d.livePrinter.end()
 		log.Fields("toolResults", event.ToolResults).Infof("continue [%s]", callName)
+	case runner.EventTypeCallSummarize:
+		if summary := event.ToolOutputSummary; summary != nil {
+			log.Fields("callID", summary.CallID, "tokens", summary.Tokens, "summaryTokens", summary.SummaryTokens, "chunks", summary.Chunks).Infof("summary  [%s]", callName)
+		}
+	case runner.EventTypeCallRoute:
+		if route := event.Route; route != nil {
+			log.Fields("agent", route.Agent, "agentToolID", route.ToolID, "model", route.Model).Infof("route    [%s]", callName)
+		}
+	case runner.EventTypeCallGuardrail:
+		if g := event.Guardrail; g != nil {
+			log.Fields("guardrail", g.Tool, "target", g.Target, "action", g.Action, "categories", g.Categories, "reason", g.Reason).Infof("guard    [%s]", callName)
+		}
 	case runner.EventTypeChat:
 		d.livePrinter.end()
 		if event.ChatRequest == nil {
@@ -259,6 +327,21 @@ func (d *display) Event(event runner.Event) {
 				"response", toJSON(event.ChatResponse),
 				"cached", event.ChatResponseCached,
 			)
+			if event.ChatResponseApproximate {
+				log = log.Fields("approximate", true)
+			}
+			if event.ChatContentFilter != nil {
+				log = log.Fields("contentFilter", toJSON(event.ChatContentFilter))
+			}
+			if event.ChatRateLimit != nil {
+				log = log.Fields("rateLimit", toJSON(event.ChatRateLimit))
+			}
+			if event.ChatTiming != nil {
+				log = log.Fields(
+					"timeToFirstToken", event.ChatTiming.TimeToFirstToken.String(),
+					"tokensPerSecond", fmt.Sprintf("%.1f", event.ChatTiming.TokensPerSecond),
+				)
+			}
 		} else {
 			log.Infof("sent     [%s]", callName)
 			log = log.Fields(
@@ -276,6 +359,8 @@ func (d *display) Event(event runner.Event) {
 			Request:      event.ChatRequest,
 			Response:     event.ChatResponse,
 			Cached:       event.ChatResponseCached,
+			Approximate:  event.ChatResponseApproximate,
+			Timing:       event.ChatTiming,
 		})
 	case runner.EventTypeCallFinish:
 		d.livePrinter.progressEnd(currentCall)
@@ -294,7 +379,20 @@ func (d *display) Stop(_ context.Context, output string, err error) {
 
 	log.Fields("runID", d.dump.ID, "output", output, "err", err, "type", runner.EventTypeRunFinish).Debugf("Run stopped")
 	if d.usage.TotalTokens > 0 {
-		log.Fields("runID", d.dump.ID, "total", d.usage.TotalTokens, "prompt", d.usage.PromptTokens, "completion", d.usage.CompletionTokens).Infof("usage   ")
+		log := log.Fields("runID", d.dump.ID, "total", d.usage.TotalTokens, "prompt", d.usage.PromptTokens, "completion", d.usage.CompletionTokens)
+		if d.usage.CacheReadTokens > 0 || d.usage.CacheWriteTokens > 0 {
+			log = log.Fields("cacheRead", d.usage.CacheReadTokens, "cacheWrite", d.usage.CacheWriteTokens)
+		}
+		if d.cost > 0 {
+			log = log.Fields("cost", fmt.Sprintf("$%.4f", d.cost))
+		}
+		log.Infof("usage   ")
+	}
+	if d.timing.completions > 0 {
+		log.Fields("runID", d.dump.ID, "completions", d.timing.completions,
+			"timeToFirstToken", (d.timing.timeToFirstToken / time.Duration(d.timing.completions)).Round(time.Millisecond).String(),
+			"maxTimeToFirstToken", d.timing.maxFirstToken.Round(time.Millisecond).String(),
+			"tokensPerSecond", fmt.Sprintf("%.1f", d.timing.tokensPerSecond())).Infof("timing  ")
 	}
 	d.dump.Output = output
 	d.dump.Err = err
@@ -312,6 +410,7 @@ func NewConsole(opts ...Options) *Console {
 	return &Console{
 		dumpState:     opt.DumpState,
 		printMessages: opt.DebugMessages,
+		output:        opt.Output,
 	}
 }
 
@@ -425,10 +524,12 @@ type dump struct {
 }
 
 type message struct {
-	CompletionID string `json:"completionID,omitempty"`
-	Request      any    `json:"request,omitempty"`
-	Response     any    `json:"response,omitempty"`
-	Cached       bool   `json:"cached,omitempty"`
+	CompletionID string        `json:"completionID,omitempty"`
+	Request      any           `json:"request,omitempty"`
+	Response     any           `json:"response,omitempty"`
+	Cached       bool          `json:"cached,omitempty"`
+	Approximate  bool          `json:"approximate,omitempty"`
+	Timing       *types.Timing `json:"timing,omitempty"`
 }
 
 type call struct {
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
@@ -39,7 +40,7 @@ func NewFileFactory(loc string) (runner.MonitorFactory, error) {
 	}, nil
 }
 
-func (s *fileFactory) Start(_ context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+func (s *fileFactory) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
 	s.lock.Lock()
 	s.runningCount++
 	if s.runningCount == 1 {
@@ -61,8 +62,9 @@ func (s *fileFactory) Start(_ context.Context, prg *types.Program, env []string,
 
 	fd.event(Event{
 		Event: runner.Event{
-			Time: time.Now(),
-			Type: runner.EventTypeRunStart,
+			Time:   time.Now(),
+			Type:   runner.EventTypeRunStart,
+			Labels: gcontext.GetLabels(ctx),
 		},
 		Program: prg,
 	})
@@ -139,11 +141,12 @@ func (f *fd) event(event Event) {
 	}
 }
 
-func (f *fd) Stop(_ context.Context, output string, err error) {
+func (f *fd) Stop(ctx context.Context, output string, err error) {
 	e := Event{
 		Event: runner.Event{
-			Time: time.Now(),
-			Type: runner.EventTypeRunFinish,
+			Time:   time.Now(),
+			Type:   runner.EventTypeRunFinish,
+			Labels: gcontext.GetLabels(ctx),
 		},
 		Input:  f.input,
 		Output: output,
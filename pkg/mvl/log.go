@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"runtime"
 	"strings"
@@ -85,6 +86,44 @@ func SetError() {
 	logrus.SetLevel(logrus.ErrorLevel)
 }
 
+// SetJSONFormat switches all logging to single line JSON records suitable for log shippers. Records logged
+// through the standard library slog package are also written as JSON to the same output.
+func SetJSONFormat() {
+	logrus.SetFormatter(&jsonFormatter{
+		JSONFormatter: logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		},
+	})
+
+	level := slog.LevelInfo
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logrus.StandardLogger().Out, &slog.HandlerOptions{
+		Level: level,
+	})))
+}
+
+type jsonFormatter struct {
+	logrus.JSONFormatter
+}
+
+func (j *jsonFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	// The logger field is the package the record came from, expose it under a more descriptive name
+	if name, ok := entry.Data["logger"]; ok {
+		data := make(logrus.Fields, len(entry.Data))
+		for k, v := range entry.Data {
+			data[k] = v
+		}
+		delete(data, "logger")
+		data["subsystem"] = strings.TrimPrefix(fmt.Sprint(name), "/pkg/")
+		cp := *entry
+		cp.Data = data
+		entry = &cp
+	}
+	return j.JSONFormatter.Format(entry)
+}
+
 func Package() Logger {
 	_, p, _, _ := runtime.Caller(1)
 	_, suffix, _ := strings.Cut(p, "gptscript")
@@ -157,6 +196,33 @@ func (l *Logger) Fields(kv ...any) *Logger {
 	}
 }
 
+type fieldsKey struct{}
+
+// WithFields returns a context that carries the given key value pairs. Any logger that is passed this context
+// through WithContext will include these fields on every record.
+func WithFields(ctx context.Context, kv ...any) context.Context {
+	fields := logrus.Fields{}
+	for k, v := range FieldsFromContext(ctx) {
+		fields[k] = v
+	}
+	for i, v := range kv {
+		if i%2 == 1 {
+			fields[kv[i-1].(string)] = v
+		}
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// FieldsFromContext returns the fields added to the context with WithFields.
+func FieldsFromContext(ctx context.Context) logrus.Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return l.FieldsMap(FieldsFromContext(ctx))
+}
+
 type InfoLogger interface {
 	Infof(msg string, args ...any)
 }
@@ -173,7 +239,7 @@ func (l *Logger) InfofCtx(ctx context.Context, msg string, args ...any) {
 		il.Infof(msg, args...)
 		return
 	}
-	l.log.WithFields(l.fields).Infof(msg, args...)
+	l.WithContext(ctx).Infof(msg, args...)
 }
 
 func (l *Logger) Infof(msg string, args ...any) {
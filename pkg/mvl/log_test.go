@@ -0,0 +1,49 @@
+package mvl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := New("/pkg/runner")
+	l.log = &logrus.Logger{
+		Out:       &buf,
+		Formatter: &jsonFormatter{},
+		Level:     logrus.InfoLevel,
+	}
+
+	// The fields of a call are added to the fields of its run.
+	runCtx := WithFields(context.Background(), "runID", "1")
+	callCtx := WithFields(runCtx, "callID", "2", "tool", "search")
+	logger := l.WithContext(callCtx)
+	logger.Infof("calling %s", "search")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "calling search", record["msg"])
+	require.Equal(t, "info", record["level"])
+	require.Equal(t, "1", record["runID"])
+	require.Equal(t, "2", record["callID"])
+	require.Equal(t, "search", record["tool"])
+	// The package of the logger is the subsystem of the record.
+	require.Equal(t, "runner", record["subsystem"])
+	require.NotContains(t, record, "logger")
+
+	// Records logged with InfofCtx carry the fields too.
+	buf.Reset()
+	l.InfofCtx(callCtx, "downloading %s", "python")
+	record = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "downloading python", record["msg"])
+	require.Equal(t, "2", record["callID"])
+
+	// The fields of the call don't leak into its run.
+	require.Equal(t, logrus.Fields{"runID": "1"}, FieldsFromContext(runCtx))
+}
@@ -0,0 +1,306 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const defaultHost = "127.0.0.1:11434"
+
+var log = mvl.Package()
+
+type Options struct {
+	Host    string `usage:"Ollama host that serves local models, detected when Ollama is running (default 127.0.0.1:11434)" name:"ollama-host" env:"OLLAMA_HOST"`
+	Pull    bool   `usage:"Pull models that Ollama doesn't have the first time they are used" name:"ollama-pull"`
+	Disable bool   `usage:"Don't use models served by a local Ollama" name:"disable-ollama"`
+}
+
+func Complete(opts ...Options) (result Options) {
+	for _, opt := range opts {
+		result.Host = types.FirstSet(opt.Host, result.Host)
+		result.Pull = types.FirstSet(opt.Pull, result.Pull)
+		result.Disable = types.FirstSet(opt.Disable, result.Disable)
+	}
+	return
+}
+
+// Client serves the models of an Ollama instance. Ollama is only used if it is running, which is checked the first
+// time a model is looked up.
+type Client struct {
+	baseURL    string
+	pull       bool
+	http       *http.Client
+	credStore  credentials.CredentialStore
+	clientOpts openai.Options
+
+	lock      sync.Mutex
+	detected  bool
+	available bool
+	client    *openai.Client
+	models    []string
+}
+
+// New returns a client for the Ollama at the host of the options. The clientOpts are applied to the OpenAI compatible
+// client that is used to call the models.
+func New(credStore credentials.CredentialStore, opts Options, clientOpts openai.Options) (*Client, error) {
+	opts = Complete(opts)
+	baseURL, err := hostURL(types.FirstSet(opts.Host, defaultHost))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		pull:       opts.Pull,
+		http:       &http.Client{},
+		credStore:  credStore,
+		clientOpts: clientOpts,
+	}, nil
+}
+
+// hostURL returns the URL of a host in the format of OLLAMA_HOST, which can leave out the scheme and port.
+func hostURL(host string) (string, error) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid Ollama host %q", host)
+	}
+	if u.Port() == "" {
+		port := "11434"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// detect checks whether Ollama is running, once, and creates the client for its OpenAI compatible API if it is.
+func (c *Client) detect(ctx context.Context) (bool, error) {
+	if c.detected {
+		return c.available, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := c.get(ctx, "/api/version", &version); err != nil {
+		log.Debugf("Ollama is not available at %s: %v", c.baseURL, err)
+		c.detected = true
+		return false, nil
+	}
+
+	client, err := openai.NewClient(ctx, c.credStore, c.clientOpts, openai.Options{
+		BaseURL: c.baseURL + "/v1",
+		// Ollama doesn't check the key, but the OpenAI client needs one.
+		APIKey: "ollama",
+		// Ollama streams each tool call whole, rather than in deltas of the same call.
+		WholeToolCalls: true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	log.Debugf("Using Ollama %s at %s", version.Version, c.baseURL)
+	c.detected, c.available, c.client = true, true, client
+	return true, nil
+}
+
+func (c *Client) Call(ctx context.Context, messageRequest types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	c.lock.Lock()
+	client := c.client
+	c.lock.Unlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("failed to find Ollama model %s", messageRequest.Model)
+	}
+	return client.Call(ctx, messageRequest, status)
+}
+
+// Embeddings returns the embeddings of the OpenAI compatible API of Ollama, which has embedding models such as
+// nomic-embed-text.
+func (c *Client) Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	c.lock.Lock()
+	client := c.client
+	c.lock.Unlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("failed to find Ollama model %s", model)
+	}
+	return client.Embeddings(ctx, model, inputs)
+}
+
+func (c *Client) ListModels(ctx context.Context, providers ...string) ([]string, error) {
+	// Like the models of OpenAI, the models of Ollama don't have a provider.
+	if len(providers) != 0 && !slices.Contains(providers, "") {
+		return nil, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if ok, err := c.detect(ctx); err != nil || !ok {
+		return nil, err
+	}
+	return c.listModels(ctx)
+}
+
+func (c *Client) listModels(ctx context.Context) ([]string, error) {
+	if c.models != nil {
+		return c.models, nil
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := c.get(ctx, "/api/tags", &tags); err != nil {
+		return nil, fmt.Errorf("failed to list Ollama models: %w", err)
+	}
+
+	models := make([]string, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		models = append(models, model.Name)
+	}
+	sort.Strings(models)
+	c.models = models
+	return models, nil
+}
+
+// Supports returns true for the models that Ollama has, or can pull if pulling is enabled.
+func (c *Client) Supports(ctx context.Context, modelName string) (bool, error) {
+	if _, provider := types.SplitToolRef(modelName); provider != "" {
+		return false, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if ok, err := c.detect(ctx); err != nil || !ok {
+		return false, err
+	}
+
+	models, err := c.listModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	// Ollama uses the latest tag of a model that is named without one.
+	if slices.Contains(models, modelName) || (!strings.Contains(modelName, ":") && slices.Contains(models, modelName+":latest")) {
+		return true, nil
+	}
+	if !c.pull {
+		return false, nil
+	}
+
+	ok, err := c.pullModel(ctx, modelName)
+	if ok {
+		c.models = nil
+	}
+	return ok, err
+}
+
+// pullModel downloads a model to Ollama, and returns false if Ollama doesn't know the model.
+func (c *Client) pullModel(ctx context.Context, modelName string) (bool, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":  modelName,
+		"stream": true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	log.Infof("Pulling model %s with Ollama", modelName)
+
+	var (
+		lastStatus string
+		scanner    = bufio.NewScanner(resp.Body)
+	)
+	for scanner.Scan() {
+		var progress struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			return false, fmt.Errorf("invalid response while pulling Ollama model %s: %w", modelName, err)
+		}
+		if progress.Error != "" {
+			return pullFailed(modelName, progress.Error)
+		}
+		if progress.Status != lastStatus {
+			log.Debugf("Pulling model %s: %s", modelName, progress.Status)
+			lastStatus = progress.Status
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return pullFailed(modelName, resp.Status)
+	}
+	if lastStatus != "success" {
+		return false, fmt.Errorf("pulling Ollama model %s did not finish", modelName)
+	}
+	return true, nil
+}
+
+func pullFailed(modelName, msg string) (bool, error) {
+	// This is how Ollama reports a model that isn't in its library, which is not an error because the model may be
+	// served by another provider.
+	if strings.Contains(msg, "file does not exist") {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to pull Ollama model %s: %s", modelName, msg)
+}
+
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,167 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostURL(t *testing.T) {
+	for host, expected := range map[string]string{
+		"127.0.0.1:11434":         "http://127.0.0.1:11434",
+		"0.0.0.0":                 "http://0.0.0.0:11434",
+		"http://ollama:8080/":     "http://ollama:8080",
+		"https://ollama.example":  "https://ollama.example:443",
+		"https://ollama.example/": "https://ollama.example:443",
+	} {
+		actual, err := hostURL(host)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, host)
+	}
+
+	_, err := hostURL("ftp://ollama")
+	require.EqualError(t, err, `invalid Ollama host "ftp://ollama"`)
+}
+
+// fakeOllama serves the parts of the Ollama API that the client uses. Pulling adds the model, unless it is unknown.
+func fakeOllama(t *testing.T, models ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/version", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"version":"0.4.0"}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, _ *http.Request) {
+		var tags struct {
+			Models []map[string]string `json:"models"`
+		}
+		for _, model := range models {
+			tags.Models = append(tags.Models, map[string]string{"name": model})
+		}
+		_ = json.NewEncoder(w).Encode(tags)
+	})
+	mux.HandleFunc("POST /api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_, _ = fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		if req.Model == "unknown" {
+			_, _ = fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+			return
+		}
+		models = append(models, req.Model)
+		_, _ = fmt.Fprintln(w, `{"status":"success"}`)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Like Ollama, every tool call is sent whole with the same index.
+		for _, name := range []string{"first", "second"} {
+			_, _ = fmt.Fprintf(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_%s","type":"function","function":{"name":"%s","arguments":"{}"}}]}}]}`+"\n\n", name, name)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSupports(t *testing.T) {
+	ctx := context.Background()
+	s := fakeOllama(t, "llama3.1:latest", "qwen2.5:7b")
+
+	c, err := New(nil, Options{Host: s.URL}, openai.Options{})
+	require.NoError(t, err)
+
+	models, err := c.ListModels(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"llama3.1:latest", "qwen2.5:7b"}, models)
+
+	models, err = c.ListModels(ctx, "github.com/gptscript-ai/claude3-anthropic-provider")
+	require.NoError(t, err)
+	require.Empty(t, models)
+
+	for model, expected := range map[string]bool{
+		"llama3.1":        true,
+		"llama3.1:latest": true,
+		"qwen2.5":         false,
+		"qwen2.5:7b":      true,
+		"mistral":         false,
+		"llama3.1 from github.com/gptscript-ai/ollama-provider": false,
+	} {
+		ok, err := c.Supports(ctx, model)
+		require.NoError(t, err)
+		require.Equal(t, expected, ok, model)
+	}
+}
+
+func TestSupportsPull(t *testing.T) {
+	ctx := context.Background()
+	s := fakeOllama(t)
+
+	c, err := New(nil, Options{Host: s.URL, Pull: true}, openai.Options{})
+	require.NoError(t, err)
+
+	ok, err := c.Supports(ctx, "mistral")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	models, err := c.ListModels(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"mistral"}, models)
+
+	// A model that Ollama doesn't know may be served by another provider.
+	ok, err = c.Supports(ctx, "unknown")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestNotRunning(t *testing.T) {
+	ctx := context.Background()
+	s := fakeOllama(t, "llama3.1:latest")
+	s.Close()
+
+	c, err := New(nil, Options{Host: s.URL, Pull: true}, openai.Options{})
+	require.NoError(t, err)
+
+	ok, err := c.Supports(ctx, "llama3.1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	models, err := c.ListModels(ctx)
+	require.NoError(t, err)
+	require.Empty(t, models)
+}
+
+func TestCallToolCalls(t *testing.T) {
+	ctx := context.Background()
+	s := fakeOllama(t, "llama3.1:latest")
+
+	c, err := New(nil, Options{Host: s.URL}, openai.Options{})
+	require.NoError(t, err)
+
+	ok, err := c.Supports(ctx, "llama3.1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	status := make(chan types.CompletionStatus, 100)
+	msg, err := c.Call(ctx, types.CompletionRequest{
+		Model:    "llama3.1",
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+	}, status)
+	require.NoError(t, err)
+
+	var names []string
+	for _, content := range msg.Content {
+		require.NotNil(t, content.ToolCall)
+		names = append(names, content.ToolCall.Function.Name)
+		require.Equal(t, "{}", content.ToolCall.Function.Arguments)
+	}
+	require.Equal(t, []string{"first", "second"}, names)
+}
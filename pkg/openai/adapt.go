@@ -0,0 +1,269 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/adrg/xdg"
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	roleSystem = "system"
+	roleUser   = "user"
+
+	toolCallFormatNative = "native"
+	toolCallFormatText   = "text"
+)
+
+// ModelsConfig is the content of a models.yaml file.
+type ModelsConfig struct {
+	Models []ModelAdaptation `json:"models,omitempty"`
+}
+
+// ModelAdaptation describes how the messages of a request are rewritten for a family of models so that the same
+// script behaves consistently across backends.
+type ModelAdaptation struct {
+	// Match is a list of glob patterns matched against the lower case model name, such as claude-* or *llama*.
+	Match []string `json:"match,omitempty"`
+	// SystemRole is the role that system messages are sent as, either system (the default) or user. With user the
+	// system prompt is prepended to the first user message.
+	SystemRole string `json:"systemRole,omitempty"`
+	// ToolCallFormat is either native (the default) or text. With text, tool calls and tool results from earlier in
+	// the conversation are sent as plain assistant and user messages for backends that accept tool definitions but
+	// not tool call history.
+	ToolCallFormat string `json:"toolCallFormat,omitempty"`
+	// Stop is a list of stop sequences added to every request.
+	Stop []string `json:"stop,omitempty"`
+	// GuidedDecoding is either vllm or llamacpp to constrain responses to the output schema, regex, or grammar of
+	// the tool using the guided decoding fields of that server.
+	GuidedDecoding string `json:"guidedDecoding,omitempty"`
+	// Reasoning marks the models as reasoning models, or not, when their names aren't those of well known reasoning
+	// models. Sampling parameters are not sent to reasoning models, and max_tokens is sent as max_completion_tokens.
+	Reasoning *bool `json:"reasoning,omitempty"`
+	// ReasoningEffort is low, medium, or high and is sent to reasoning models as their reasoning_effort.
+	ReasoningEffort string `json:"reasoningEffort,omitempty"`
+	// PromptCaching marks the system prompt and the tools of requests with cache_control, for providers of Anthropic
+	// models that only cache prompts up to those marks.
+	PromptCaching bool `json:"promptCaching,omitempty"`
+	// Fallbacks are the providers that requests are sent to, in order, when the provider of the options fails with an
+	// authentication error, a server error, or a timeout.
+	Fallbacks []Fallback `json:"fallbacks,omitempty"`
+	// ModelLimits override the sizes of the context window and responses of the models, which are otherwise taken
+	// from the name of the model or the built-in limits of well known models.
+	ModelLimits `json:",inline"`
+}
+
+// loadModelAdaptations reads the models file. If no file is given, gptscript/models.yaml in the XDG config
+// directories is used if it exists.
+func loadModelAdaptations(file string) ([]ModelAdaptation, error) {
+	if file == "" {
+		var err error
+		file, err = xdg.SearchConfigFile("gptscript/models.yaml")
+		if err != nil {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("models file %s not found", file)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var config ModelsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid models file %s: %w", file, err)
+	}
+
+	for i, model := range config.Models {
+		if err := model.validate(); err != nil {
+			return nil, fmt.Errorf("invalid models file %s, entry %d: %w", file, i+1, err)
+		}
+	}
+
+	return config.Models, nil
+}
+
+func (m ModelAdaptation) validate() error {
+	if len(m.Match) == 0 {
+		return fmt.Errorf("match is required")
+	}
+	for _, pattern := range m.Match {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid match pattern %q: %w", pattern, err)
+		}
+	}
+	switch m.SystemRole {
+	case "", roleSystem, roleUser:
+	default:
+		return fmt.Errorf("invalid systemRole %q, must be %s or %s", m.SystemRole, roleSystem, roleUser)
+	}
+	switch m.ToolCallFormat {
+	case "", toolCallFormatNative, toolCallFormatText:
+	default:
+		return fmt.Errorf("invalid toolCallFormat %q, must be %s or %s", m.ToolCallFormat, toolCallFormatNative, toolCallFormatText)
+	}
+	switch m.GuidedDecoding {
+	case "", guidedDecodingVLLM, guidedDecodingLlamaCPP:
+	default:
+		return fmt.Errorf("invalid guidedDecoding %q, must be %s or %s", m.GuidedDecoding, guidedDecodingVLLM, guidedDecodingLlamaCPP)
+	}
+	if m.ReasoningEffort != "" {
+		if err := validateReasoningEffort(m.ReasoningEffort); err != nil {
+			return err
+		}
+	}
+	for _, fallback := range m.Fallbacks {
+		if err := fallback.validate(); err != nil {
+			return err
+		}
+	}
+	if m.ContextWindow < 0 || m.MaxOutputTokens < 0 {
+		return fmt.Errorf("contextWindow and maxOutputTokens must not be negative")
+	}
+	if m.ContextWindow != 0 && m.MaxOutputTokens > m.ContextWindow {
+		return fmt.Errorf("maxOutputTokens %d is larger than contextWindow %d", m.MaxOutputTokens, m.ContextWindow)
+	}
+	return nil
+}
+
+func (m ModelAdaptation) matches(model string) bool {
+	model = strings.ToLower(model)
+	for _, pattern := range m.Match {
+		if ok, _ := path.Match(strings.ToLower(pattern), model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptRequest rewrites the request using the first adaptation that matches the model.
+func adaptRequest(adaptations []ModelAdaptation, request *openai.ChatCompletionRequest) {
+	for _, adaptation := range adaptations {
+		if adaptation.matches(request.Model) {
+			adaptation.apply(request)
+			return
+		}
+	}
+}
+
+func (m ModelAdaptation) apply(request *openai.ChatCompletionRequest) {
+	var rewritten bool
+	if m.ToolCallFormat == toolCallFormatText {
+		request.Messages = toolCallsToText(request.Messages)
+		rewritten = true
+	}
+	if m.SystemRole == roleUser {
+		request.Messages = systemToUser(request.Messages)
+		rewritten = true
+	}
+	if rewritten {
+		// Roles were changed, so merge messages to keep user and assistant messages alternating
+		request.Messages = mergeConsecutive(request.Messages)
+	}
+	request.Stop = append(request.Stop, m.Stop...)
+}
+
+func toolCallsToText(msgs []openai.ChatCompletionMessage) (result []openai.ChatCompletionMessage) {
+	for _, msg := range msgs {
+		switch {
+		case len(msg.ToolCalls) > 0:
+			var lines []string
+			if msg.Content != "" {
+				lines = append(lines, msg.Content)
+			}
+			for _, call := range msg.ToolCalls {
+				lines = append(lines, fmt.Sprintf("Calling tool %s with arguments %s", call.Function.Name, call.Function.Arguments))
+			}
+			msg.Content = strings.Join(lines, "\n")
+			msg.ToolCalls = nil
+		case msg.Role == openai.ChatMessageRoleTool:
+			msg.Role = roleUser
+			msg.Content = fmt.Sprintf("Result of tool %s:\n%s", msg.Name, messageText(msg))
+			msg.MultiContent = nil
+			msg.Name = ""
+			msg.ToolCallID = ""
+		}
+		result = append(result, msg)
+	}
+	return
+}
+
+func systemToUser(msgs []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	var (
+		systemPrompts []string
+		result        []openai.ChatCompletionMessage
+	)
+
+	for _, msg := range msgs {
+		if msg.Role == roleSystem {
+			systemPrompts = append(systemPrompts, messageText(msg))
+			continue
+		}
+		result = append(result, msg)
+	}
+
+	if len(systemPrompts) == 0 {
+		return msgs
+	}
+
+	prompt := strings.Join(systemPrompts, "\n")
+	for i, msg := range result {
+		if msg.Role != roleUser {
+			continue
+		}
+		if len(msg.MultiContent) > 0 {
+			msg.MultiContent = append([]openai.ChatMessagePart{{
+				Type: openai.ChatMessagePartTypeText,
+				Text: prompt,
+			}}, msg.MultiContent...)
+		} else {
+			msg.Content = prompt + "\n\n" + msg.Content
+		}
+		result[i] = msg
+		return result
+	}
+
+	return append([]openai.ChatCompletionMessage{{
+		Role:    roleUser,
+		Content: prompt,
+	}}, result...)
+}
+
+func mergeConsecutive(msgs []openai.ChatCompletionMessage) (result []openai.ChatCompletionMessage) {
+	for _, msg := range msgs {
+		if len(result) > 0 {
+			last := &result[len(result)-1]
+			if isPlainText(*last) && isPlainText(msg) && last.Role == msg.Role {
+				last.Content += "\n\n" + msg.Content
+				continue
+			}
+		}
+		result = append(result, msg)
+	}
+	return
+}
+
+func isPlainText(msg openai.ChatCompletionMessage) bool {
+	return len(msg.MultiContent) == 0 && len(msg.ToolCalls) == 0 && msg.ToolCallID == ""
+}
+
+func messageText(msg openai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	var parts []string
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
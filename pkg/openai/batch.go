@@ -0,0 +1,516 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/counter"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// defaultBatchWindow is how long requests are collected before they are sent as one batch.
+	defaultBatchWindow = 2 * time.Second
+	defaultBatchPoll   = 30 * time.Second
+	// maxBatchRequests is the most requests the Batch API accepts in one batch.
+	maxBatchRequests     = 50000
+	maxBatchPollFailures = 10
+)
+
+// batcher sends the completion requests of a client to the Batch API of the provider, which costs less than sending
+// them one by one but can take up to a day. Requests that are made at about the same time are sent in one batch, and
+// each request waits until the batch is done. The batch of each request is kept in the cache until it is done, so that
+// a request that is made again, such as by a run that was interrupted, waits for the batch it was sent in instead of
+// being sent again.
+type batcher struct {
+	url    string
+	orgID  string
+	client *http.Client
+	cache  *cache.Client
+	apiKey func() string
+	window time.Duration
+	poll   time.Duration
+	sleep  func(context.Context, time.Duration) error
+
+	lock    sync.Mutex
+	pending []*batchRequest
+}
+
+type batchRequest struct {
+	ctx context.Context
+	id  string
+	// key is the cache key of the request, which its batch is cached under.
+	key      any
+	body     json.RawMessage
+	done     chan struct{}
+	response openai.ChatCompletionResponse
+	err      error
+	// answered is set when the batch had a result for the request.
+	answered bool
+}
+
+// pendingBatch is the batch that a request was sent in, which is cached until the batch is done.
+type pendingBatch struct {
+	BatchID  string
+	CustomID string
+}
+
+type batchLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type batchResult struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type batchStatus struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	OutputFileID string `json:"output_file_id"`
+	ErrorFileID  string `json:"error_file_id"`
+	Errors       *struct {
+		Data []struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"errors"`
+}
+
+func newBatcher(enabled bool, pollInterval, baseURL, orgID string, client *http.Client, cache *cache.Client, apiKey func() string) (*batcher, error) {
+	if !enabled {
+		return nil, nil
+	}
+	poll := defaultBatchPoll
+	if pollInterval != "" {
+		var err error
+		if poll, err = time.ParseDuration(pollInterval); err != nil || poll <= 0 {
+			return nil, fmt.Errorf("invalid batch poll interval %q", pollInterval)
+		}
+	}
+	return &batcher{
+		url:    strings.TrimRight(baseURL, "/"),
+		orgID:  orgID,
+		client: client,
+		cache:  cache,
+		apiKey: apiKey,
+		window: defaultBatchWindow,
+		poll:   poll,
+		sleep:  sleep,
+	}, nil
+}
+
+// callBatch sends the request in a batch and waits for its response, which is cached like a streamed one.
+func (c *Client) callBatch(ctx context.Context, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus) ([]openai.ChatCompletionStreamResponse, *types.Timing, error) {
+	partial <- types.CompletionStatus{
+		CompletionID: transactionID,
+		PartialResponse: &types.CompletionMessage{
+			Role:    types.CompletionMessageRoleTypeAssistant,
+			Content: types.Text("Waiting for the batch of the request..."),
+		},
+		Placeholder: true,
+		Candidate:   candidate(ctx),
+	}
+
+	// The response is cached under the request as it was made, so that it is found like a streamed one.
+	key := c.cacheKey(ctx, request)
+	timing := newTimer()
+	request.Stream = false
+	request.StreamOptions = nil
+	resp, err := c.batch.complete(ctx, key, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l := getLogProbs(ctx); l != nil {
+		l.addResponse(resp)
+	}
+	response := toStreamResponse(resp)
+	timing.received(response)
+	responses := []openai.ChatCompletionStreamResponse{response}
+	if filteredResponse(responses...) {
+		return responses, timing.done(), nil
+	}
+	if err := c.cache.Store(ctx, key, responses); err != nil {
+		return nil, nil, err
+	}
+	return responses, timing.done(), nil
+}
+
+// batchKey is the cache key of the batch of the request of the cache key.
+func batchKey(key any) any {
+	return map[string]any{
+		"batch": key,
+	}
+}
+
+// complete waits for the response of the request from the batch that it was already sent in, or adds it to the next
+// batch and waits for its response.
+func (b *batcher) complete(ctx context.Context, key any, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	var pending pendingBatch
+	if ok, err := b.cache.Get(ctx, batchKey(key), &pending); err != nil {
+		log.WithContext(ctx).Warnf("Failed to read the batch of the request from the cache: %v", err)
+	} else if ok {
+		resp, answered, err := b.resume(ctx, key, pending)
+		if answered || ctx.Err() != nil {
+			return resp, err
+		}
+		log.WithContext(ctx).Warnf("Sending the request in a new batch, since it has no response in batch %s: %v", pending.BatchID, err)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	// The fields that are not part of the OpenAI API are added like they are to the body of other requests.
+	if fields := bodyFields(ctx); len(fields) > 0 {
+		var merged map[string]any
+		if err := json.Unmarshal(body, &merged); err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		if body, err = json.Marshal(merged); err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+	}
+
+	req := &batchRequest{
+		ctx:  ctx,
+		id:   counter.Next(),
+		key:  key,
+		body: body,
+		done: make(chan struct{}),
+	}
+
+	b.lock.Lock()
+	b.pending = append(b.pending, req)
+	switch len(b.pending) {
+	case 1:
+		time.AfterFunc(b.window, b.flush)
+	case maxBatchRequests:
+		go b.flush()
+	}
+	b.lock.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return openai.ChatCompletionResponse{}, ctx.Err()
+	case <-req.done:
+		return req.response, req.err
+	}
+}
+
+// flush sends the pending requests as a batch.
+func (b *batcher) flush() {
+	b.lock.Lock()
+	requests := b.pending
+	b.pending = nil
+	b.lock.Unlock()
+
+	if len(requests) == 0 {
+		return
+	}
+
+	// The batch is not canceled with the context of any one request, because it serves all of them, but it is no
+	// longer waited for when all of them are canceled. It is waited for again when they are made again.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for _, req := range requests {
+			select {
+			case <-req.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	err := b.run(ctx, requests)
+	for _, req := range requests {
+		if !req.answered {
+			req.err = err
+			if req.err == nil {
+				req.err = fmt.Errorf("the batch has no result for the request")
+			}
+		}
+		close(req.done)
+	}
+}
+
+// run sends the requests as a batch, waits until the batch is done, and sets the response or error of each request.
+func (b *batcher) run(ctx context.Context, requests []*batchRequest) error {
+	var input bytes.Buffer
+	for _, req := range requests {
+		line, err := json.Marshal(batchLine{
+			CustomID: req.id,
+			Method:   http.MethodPost,
+			URL:      "/v1/chat/completions",
+			Body:     req.body,
+		})
+		if err != nil {
+			return err
+		}
+		input.Write(line)
+		input.WriteByte('\n')
+	}
+
+	fileID, err := b.upload(ctx, input.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to upload the requests of the batch: %w", err)
+	}
+
+	var status batchStatus
+	if err := b.do(ctx, http.MethodPost, "/batches", map[string]any{
+		"input_file_id":     fileID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	}, &status); err != nil {
+		return fmt.Errorf("failed to create the batch: %w", err)
+	}
+	log.WithContext(ctx).Infof("Sent %d requests in batch %s, waiting for the results", len(requests), status.ID)
+
+	for _, req := range requests {
+		if err := b.cache.Store(ctx, batchKey(req.key), pendingBatch{BatchID: status.ID, CustomID: req.id}); err != nil {
+			log.WithContext(ctx).Warnf("Failed to cache the batch of the request: %v", err)
+		}
+	}
+
+	err = b.wait(ctx, status, requests)
+	if ctx.Err() == nil {
+		b.forget(requests)
+	}
+	return err
+}
+
+// resume waits for the response of the request from the batch that it was sent in before, and returns whether the batch
+// had a result for it.
+func (b *batcher) resume(ctx context.Context, key any, pending pendingBatch) (openai.ChatCompletionResponse, bool, error) {
+	req := &batchRequest{
+		ctx: ctx,
+		id:  pending.CustomID,
+		key: key,
+	}
+
+	var status batchStatus
+	if err := b.do(ctx, http.MethodGet, "/batches/"+pending.BatchID, nil, &status); err != nil {
+		return openai.ChatCompletionResponse{}, false, fmt.Errorf("failed to get the status of batch %s: %w", pending.BatchID, err)
+	}
+	log.WithContext(ctx).Infof("Waiting for the result of the request in batch %s", pending.BatchID)
+
+	err := b.wait(ctx, status, []*batchRequest{req})
+	if ctx.Err() == nil {
+		b.forget([]*batchRequest{req})
+	}
+	if req.answered {
+		return req.response, true, req.err
+	} else if err == nil {
+		err = fmt.Errorf("the batch has no result for the request")
+	}
+	return openai.ChatCompletionResponse{}, false, err
+}
+
+// forget removes the batches of the requests from the cache once the batch is done.
+func (b *batcher) forget(requests []*batchRequest) {
+	for _, req := range requests {
+		// Storing without the cache removes the cached value.
+		_ = b.cache.Store(cache.WithNoCache(context.Background()), batchKey(req.key), pendingBatch{})
+	}
+}
+
+// wait waits until the batch is done, and sets the response or error of each request.
+func (b *batcher) wait(ctx context.Context, status batchStatus, requests []*batchRequest) error {
+	var failures int
+	for {
+		switch status.Status {
+		case "completed":
+			return b.results(ctx, status, requests)
+		case "failed", "expired", "cancelled":
+			msg := status.Status
+			if status.Errors != nil && len(status.Errors.Data) > 0 {
+				msg += ": " + status.Errors.Data[0].Message
+			}
+			// An expired batch has the results of the requests that were done in time.
+			if status.Status == "expired" {
+				if err := b.results(ctx, status, requests); err != nil {
+					log.WithContext(ctx).Warnf("Failed to read the results of expired batch %s: %v", status.ID, err)
+				}
+			}
+			return fmt.Errorf("batch %s %s", status.ID, msg)
+		}
+
+		if err := b.sleep(ctx, b.poll); err != nil {
+			return err
+		}
+		// A batch can take hours, so it is not given up on when checking its status fails a few times.
+		var next batchStatus
+		if err := b.do(ctx, http.MethodGet, "/batches/"+status.ID, nil, &next); err != nil {
+			if failures++; failures >= maxBatchPollFailures {
+				return fmt.Errorf("failed to get the status of batch %s: %w", status.ID, err)
+			}
+			log.WithContext(ctx).Warnf("Failed to get the status of batch %s: %v", status.ID, err)
+			continue
+		}
+		status, failures = next, 0
+	}
+}
+
+// results sets the responses and errors of the requests from the output and error files of a batch.
+func (b *batcher) results(ctx context.Context, status batchStatus, requests []*batchRequest) error {
+	byID := make(map[string]*batchRequest, len(requests))
+	for _, req := range requests {
+		byID[req.id] = req
+	}
+
+	for _, fileID := range []string{status.OutputFileID, status.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		if err := b.readResults(ctx, fileID, byID); err != nil {
+			return fmt.Errorf("failed to read the results of batch %s: %w", status.ID, err)
+		}
+	}
+	return nil
+}
+
+func (b *batcher) readResults(ctx context.Context, fileID string, requests map[string]*batchRequest) error {
+	resp, err := b.send(ctx, http.MethodGet, "/files/"+fileID+"/content", nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var result batchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return err
+		}
+		req, ok := requests[result.CustomID]
+		if !ok {
+			continue
+		}
+		req.answered = true
+
+		switch {
+		case result.Error != nil:
+			req.err = fmt.Errorf("batch request failed: %s: %s", result.Error.Code, result.Error.Message)
+		case result.Response == nil:
+			req.err = fmt.Errorf("batch request has no response")
+		case result.Response.StatusCode != http.StatusOK:
+			req.err = fmt.Errorf("batch request failed with status %d: %s", result.Response.StatusCode, result.Response.Body)
+		default:
+			req.err = json.Unmarshal(result.Response.Body, &req.response)
+		}
+	}
+	return scanner.Err()
+}
+
+// upload uploads the input file of a batch and returns its ID.
+func (b *batcher) upload(ctx context.Context, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("purpose", "batch"); err != nil {
+		return "", err
+	}
+	file, err := form.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	resp, err := b.send(ctx, http.MethodPost, "/files", &body, form.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// do sends a JSON request to the API of the provider and decodes the JSON response into out.
+func (b *batcher) do(ctx context.Context, method, path string, in, out any) error {
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	resp, err := b.send(ctx, method, path, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request to the API of the provider, and returns an error for a response that is not successful.
+func (b *batcher) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if key := b.apiKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	if b.orgID != "" {
+		req.Header.Set("OpenAI-Organization", b.orgID)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, b.url+path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
@@ -0,0 +1,244 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		inputs  = map[string][]batchLine{}
+		polls   atomic.Int32
+		batches atomic.Int32
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/files":
+			file, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			require.Equal(t, "batch", r.FormValue("purpose"))
+			var lines []batchLine
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var line batchLine
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				lines = append(lines, line)
+			}
+			id := fmt.Sprintf("file-%d", len(inputs))
+			inputs[id] = lines
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		case r.Method == http.MethodPost && r.URL.Path == "/batches":
+			var req map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, "/v1/chat/completions", req["endpoint"])
+			batches.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": req["input_file_id"], "status": "validating"})
+		case r.Method == http.MethodGet && r.URL.Path == "/batches/file-0":
+			if polls.Add(1) < 2 {
+				_ = json.NewEncoder(w).Encode(map[string]any{"id": "file-0", "status": "in_progress"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "file-0", "status": "completed", "output_file_id": "output", "error_file_id": "errors"})
+		case r.Method == http.MethodGet && r.URL.Path == "/files/output/content":
+			for _, line := range inputs["file-0"] {
+				content := batchContent(t, line)
+				if content == "fail" {
+					continue
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"custom_id": line.CustomID,
+					"response": map[string]any{
+						"status_code": 200,
+						"body": map[string]any{
+							"choices": []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": "re: " + content}}},
+							"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12},
+						},
+					},
+				})
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/files/errors/content":
+			for _, line := range inputs["file-0"] {
+				if batchContent(t, line) == "fail" {
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"custom_id": line.CustomID,
+						"error":     map[string]any{"code": "invalid_request", "message": "bad request"},
+					})
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+		Batch:      true,
+	})
+	require.NoError(t, err)
+	c.batch.window = 100 * time.Millisecond
+	c.batch.poll = time.Millisecond
+
+	call := func(content string) (*types.CompletionMessage, error) {
+		status := make(chan types.CompletionStatus)
+		go func() {
+			for range status {
+			}
+		}()
+		defer close(status)
+		return c.Call(context.Background(), types.CompletionRequest{
+			Model:    "gpt-4o",
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text(content)}},
+		}, status)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		resps = make([]*types.CompletionMessage, 2)
+		errs  = make([]error, 3)
+	)
+	for i, content := range []string{"one", "two", "fail"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := call(content)
+			if i < len(resps) {
+				resps[i] = resp
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	// The requests are sent in one batch.
+	require.Equal(t, int32(1), batches.Load())
+	require.Len(t, inputs["file-0"], 3)
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.Equal(t, "re: one", resps[0].Content[0].Text)
+	require.Equal(t, "re: two", resps[1].Content[0].Text)
+	require.Equal(t, 12, resps[0].Usage.TotalTokens)
+	require.ErrorContains(t, errs[2], "bad request")
+
+	// The responses are cached, so running again doesn't send another batch.
+	resp, err := call("one")
+	require.NoError(t, err)
+	require.Equal(t, "re: one", resp.Content[0].Text)
+	require.Equal(t, int32(1), batches.Load())
+}
+
+// batchContent returns the content of the message of a request in a batch, which must not be streamed.
+func batchContent(t *testing.T, line batchLine) string {
+	var body struct {
+		Stream   bool `json:"stream"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(line.Body, &body))
+	require.False(t, body.Stream)
+	return body.Messages[0].Content
+}
+
+func TestBatchResume(t *testing.T) {
+	var (
+		batches  atomic.Int32
+		done     atomic.Bool
+		customID atomic.Value
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/files":
+			file, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			var line batchLine
+			require.NoError(t, json.NewDecoder(file).Decode(&line))
+			customID.Store(line.CustomID)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "input"})
+		case r.Method == http.MethodPost && r.URL.Path == "/batches":
+			batches.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "batch-1", "status": "validating"})
+		case r.Method == http.MethodGet && r.URL.Path == "/batches/batch-1":
+			if !done.Load() {
+				_ = json.NewEncoder(w).Encode(map[string]any{"id": "batch-1", "status": "in_progress"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "batch-1", "status": "completed", "output_file_id": "output"})
+		case r.Method == http.MethodGet && r.URL.Path == "/files/output/content":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"custom_id": customID.Load(),
+				"response": map[string]any{
+					"status_code": 200,
+					"body": map[string]any{
+						"choices": []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": "done"}}},
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+		Batch:      true,
+	})
+	require.NoError(t, err)
+	c.batch.window = time.Millisecond
+	c.batch.poll = time.Millisecond
+
+	call := func(ctx context.Context) (*types.CompletionMessage, error) {
+		status := make(chan types.CompletionStatus)
+		go func() {
+			for range status {
+			}
+		}()
+		defer close(status)
+		return c.Call(ctx, types.CompletionRequest{
+			Model:    "gpt-4o",
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+		}, status)
+	}
+
+	// The request is canceled while its batch is in progress.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = call(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int32(1), batches.Load())
+
+	// Making the request again waits for the batch it was sent in instead of sending another one.
+	done.Store(true)
+	resp, err := call(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", resp.Content[0].Text)
+	require.Equal(t, int32(1), batches.Load())
+}
@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"context"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+)
+
+type candidateKey struct{}
+
+// withCandidate stores the Candidate of a request, which is part of its cache key so that the parallel completions of
+// a tool with Best Of set are not served from the same cache entry.
+func withCandidate(ctx context.Context, candidate int) context.Context {
+	if candidate == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, candidateKey{}, candidate)
+}
+
+func candidate(ctx context.Context) int {
+	candidate, _ := ctx.Value(candidateKey{}).(int)
+	return candidate
+}
+
+// responseChoices splits the streamed responses of a request with several choices into the responses of each choice,
+// ordered by index. Responses without choices, such as the one with the usage, are kept with the first choice.
+func responseChoices(responses []openai.ChatCompletionStreamResponse) (result [][]openai.ChatCompletionStreamResponse) {
+	result = make([][]openai.ChatCompletionStreamResponse, 1)
+	for _, response := range responses {
+		if len(response.Choices) == 0 {
+			result[0] = append(result[0], response)
+			continue
+		}
+		for _, choice := range response.Choices {
+			for len(result) <= choice.Index {
+				result = append(result, nil)
+			}
+			choiceResponse := response
+			choiceResponse.Choices = []openai.ChatCompletionStreamChoice{choice}
+			result[choice.Index] = append(result[choice.Index], choiceResponse)
+		}
+	}
+	return result
+}
+
+// firstChoice returns the response with only the first choice, or false if it has choices but not the first.
+func firstChoice(response openai.ChatCompletionStreamResponse) (openai.ChatCompletionStreamResponse, bool) {
+	if len(response.Choices) <= 1 {
+		return response, len(response.Choices) == 0 || response.Choices[0].Index == 0
+	}
+	for _, choice := range response.Choices {
+		if choice.Index == 0 {
+			response.Choices = []openai.ChatCompletionStreamChoice{choice}
+			return response, true
+		}
+	}
+	return response, false
+}
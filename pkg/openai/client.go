@@ -2,15 +2,18 @@ package openai
 
 import (
 	"context"
+	"fmt"
 	"io"
-	"log/slog"
+	"net/http"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	openai "github.com/gptscript-ai/chat-completion-client"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/cassette"
 	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/counter"
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
@@ -19,6 +22,7 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/prompt"
 	"github.com/gptscript-ai/gptscript/pkg/system"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -45,18 +49,83 @@ type Client struct {
 	invalidAuth  bool
 	cacheKeyBase string
 	setSeed      bool
+	sendLabels   bool
 	credStore    credentials.CredentialStore
+	hooks        *ClientHooks
+	systemPrompt *SystemPrompt
+	adaptations  []ModelAdaptation
+	// wholeToolCalls is set for providers that stream every tool call in one piece, and reuse the same index for the
+	// tool calls that follow.
+	wholeToolCalls bool
+	apiKey         string
+	semantic       *semanticCache
+	embeddingModel string
+	embeddings     embeddingsAPI
+	limiter        *rateLimiter
+	providerLimits *providerLimits
+	baseURL        string
+	// fallbacks are the fallback providers of each of the adaptations.
+	fallbacks [][]provider
+	// contentFilterModel is the model that requests blocked by the content filter of the provider are retried with.
+	contentFilterModel string
+	// inflight makes one call for identical requests that are made at the same time.
+	inflight singleflight.Group
+	// flights are the requests that wait for each call that inflight makes.
+	flights flights
+	// reasoningEffort is sent to reasoning models that don't have a reasoning effort in the models file.
+	reasoningEffort string
+	// modelsTTL is how long the list of models is cached, and refreshModels ignores the cached list.
+	modelsTTL     time.Duration
+	refreshModels bool
+	// batch sends requests to the Batch API of the provider when it is set.
+	batch *batcher
+	// payloads picks the completions whose payloads are recorded, none when it is nil.
+	payloads *payloadSampler
 }
 
 type Options struct {
-	BaseURL      string `usage:"OpenAI base URL" name:"openai-base-url" env:"OPENAI_BASE_URL"`
-	APIKey       string `usage:"OpenAI API KEY" name:"openai-api-key" env:"OPENAI_API_KEY"`
-	OrgID        string `usage:"OpenAI organization ID" name:"openai-org-id" env:"OPENAI_ORG_ID"`
-	DefaultModel string `usage:"Default LLM model to use" default:"gpt-4o"`
-	ConfigFile   string `usage:"Path to GPTScript config file" name:"config"`
-	SetSeed      bool   `usage:"-"`
-	CacheKey     string `usage:"-"`
-	Cache        *cache.Client
+	BaseURL              string `usage:"OpenAI base URL" name:"openai-base-url" env:"OPENAI_BASE_URL"`
+	APIKey               string `usage:"OpenAI API KEY, or several separated by commas that requests take in turn" name:"openai-api-key" env:"OPENAI_API_KEY"`
+	OrgID                string `usage:"OpenAI organization ID" name:"openai-org-id" env:"OPENAI_ORG_ID"`
+	DefaultModel         string `usage:"Default LLM model to use" default:"gpt-4o"`
+	ConfigFile           string `usage:"Path to GPTScript config file" name:"config"`
+	InternalSystemPrompt string `usage:"Replace the internal system prompt"`
+	AppendSystemPrompt   string `usage:"Text appended to the internal system prompt"`
+	SystemPromptTemplate bool   `usage:"Render the internal system prompt as a Go template, which may reference {{.Model}} and {{.Date}}"`
+	ModelsFile           string `usage:"Path to a models.yaml file describing how requests are adapted per model family"`
+	Cassette             string `usage:"Record the HTTP interactions with model providers to this file, or replay them from it"`
+	CassetteMode         string `usage:"One of record, replay, or auto to replay the cassette if it exists and record it otherwise (default auto)"`
+	SendLabels           bool   `usage:"Send the labels of a run to the model provider as request metadata, and the user label as the user"`
+	MaxRetries           int    `usage:"Maximum number of times a model request is retried after a rate limit or server error (default 5, -1 to never retry)"`
+	RetryBackoff         string `usage:"Delay before the first retry of a model request, doubled after each retry up to a minute (default 1s)"`
+	RequestsPerMinute    int    `usage:"Maximum number of model requests per minute, requests over it wait (default no limit)"`
+	TokensPerMinute      int    `usage:"Maximum number of prompt and completion tokens per minute, requests over it wait (default no limit)"`
+	SemanticCache        string `usage:"Serve the cached response of a prompt whose embedding is at least this similar (0 to 1) to that of the prompt, marked as approximate (ex: 0.95)"`
+	EmbeddingModel       string `usage:"Model of embeddings, which embeds the prompts for the semantic cache (default text-embedding-3-small)"`
+	ContentFilterModel   string `usage:"Model that requests blocked by the content filter of the provider are retried with (default no retry)"`
+	ReasoningEffort      string `usage:"Reasoning effort of reasoning models such as o1 and o3-mini, one of low, medium, or high (default that of the model)"`
+	ModelTimeout         string `usage:"Maximum time a model request may take, including its retries and streamed response (ex: 10m, default no limit)"`
+	ModelKeepAlive       string `usage:"How long idle connections to model providers are kept open, 0 to close them after each request (default 90s)"`
+	ModelProxy           string `usage:"URL of the proxy of model requests (default that of HTTPS_PROXY and NO_PROXY)"`
+	ModelCACert          string `usage:"Path of a PEM file of CA certificates to trust for model providers, besides those of the system" name:"model-ca-cert"`
+	ModelClientCert      string `usage:"Path of a PEM client certificate to present to model providers that require mutual TLS"`
+	ModelClientKey       string `usage:"Path of the PEM key of the model client certificate"`
+	APIKeyCooldown       string `usage:"How long an API key that was rate limited or rejected is skipped when there are several keys (default 1m)"`
+	ModelsCacheTTL       string `usage:"How long the list of models of a provider is cached, 0 to list them every time (default 1h)"`
+	RefreshModels        bool   `usage:"List the models of providers again instead of using the cached lists"`
+	Batch                bool   `usage:"Send model requests to the Batch API of the provider and wait for their results, which costs less but can take up to a day"`
+	BatchPollInterval    string `usage:"How often the status of a batch of model requests is checked (default 30s)"`
+	PayloadSampleRate    string `usage:"Percentage of model requests whose HTTP request and response are recorded, with secrets redacted, in the events of their runs (ex: 1, default none)"`
+	SetSeed              bool   `usage:"-"`
+	WholeToolCalls       bool   `usage:"-"`
+	CacheKey             string `usage:"-"`
+	Cache                *cache.Client
+	Hooks                *ClientHooks `usage:"-" json:"-"`
+
+	// ExtraHeaders are added to every request to the provider, such as those that a gateway requires.
+	ExtraHeaders []string `usage:"Header added to the requests to model providers, such as for a gateway (ex: --model-header 'X-Portkey-Api-Key: abc')" name:"model-header" env:"GPTSCRIPT_MODEL_HEADERS" split:"false"`
+	// PayloadRedact are regular expressions of the text that is redacted from recorded payloads.
+	PayloadRedact []string `usage:"Regular expression of text that is redacted from recorded model requests and responses, besides API keys and tokens" split:"false"`
 }
 
 func Complete(opts ...Options) (result Options) {
@@ -68,6 +137,37 @@ func Complete(opts ...Options) (result Options) {
 		result.DefaultModel = types.FirstSet(opt.DefaultModel, result.DefaultModel)
 		result.SetSeed = types.FirstSet(opt.SetSeed, result.SetSeed)
 		result.CacheKey = types.FirstSet(opt.CacheKey, result.CacheKey)
+		result.Hooks = types.FirstSet(opt.Hooks, result.Hooks)
+		result.InternalSystemPrompt = types.FirstSet(opt.InternalSystemPrompt, result.InternalSystemPrompt)
+		result.AppendSystemPrompt = types.FirstSet(opt.AppendSystemPrompt, result.AppendSystemPrompt)
+		result.SystemPromptTemplate = types.FirstSet(opt.SystemPromptTemplate, result.SystemPromptTemplate)
+		result.ModelsFile = types.FirstSet(opt.ModelsFile, result.ModelsFile)
+		result.Cassette = types.FirstSet(opt.Cassette, result.Cassette)
+		result.CassetteMode = types.FirstSet(opt.CassetteMode, result.CassetteMode)
+		result.SendLabels = types.FirstSet(opt.SendLabels, result.SendLabels)
+		result.WholeToolCalls = types.FirstSet(opt.WholeToolCalls, result.WholeToolCalls)
+		result.MaxRetries = types.FirstSet(opt.MaxRetries, result.MaxRetries)
+		result.RetryBackoff = types.FirstSet(opt.RetryBackoff, result.RetryBackoff)
+		result.RequestsPerMinute = types.FirstSet(opt.RequestsPerMinute, result.RequestsPerMinute)
+		result.TokensPerMinute = types.FirstSet(opt.TokensPerMinute, result.TokensPerMinute)
+		result.SemanticCache = types.FirstSet(opt.SemanticCache, result.SemanticCache)
+		result.EmbeddingModel = types.FirstSet(opt.EmbeddingModel, result.EmbeddingModel)
+		result.ContentFilterModel = types.FirstSet(opt.ContentFilterModel, result.ContentFilterModel)
+		result.ReasoningEffort = types.FirstSet(opt.ReasoningEffort, result.ReasoningEffort)
+		result.ModelTimeout = types.FirstSet(opt.ModelTimeout, result.ModelTimeout)
+		result.ModelKeepAlive = types.FirstSet(opt.ModelKeepAlive, result.ModelKeepAlive)
+		result.ModelProxy = types.FirstSet(opt.ModelProxy, result.ModelProxy)
+		result.ModelCACert = types.FirstSet(opt.ModelCACert, result.ModelCACert)
+		result.ModelClientCert = types.FirstSet(opt.ModelClientCert, result.ModelClientCert)
+		result.ModelClientKey = types.FirstSet(opt.ModelClientKey, result.ModelClientKey)
+		result.APIKeyCooldown = types.FirstSet(opt.APIKeyCooldown, result.APIKeyCooldown)
+		result.ModelsCacheTTL = types.FirstSet(opt.ModelsCacheTTL, result.ModelsCacheTTL)
+		result.RefreshModels = types.FirstSet(opt.RefreshModels, result.RefreshModels)
+		result.Batch = types.FirstSet(opt.Batch, result.Batch)
+		result.BatchPollInterval = types.FirstSet(opt.BatchPollInterval, result.BatchPollInterval)
+		result.PayloadSampleRate = types.FirstSet(opt.PayloadSampleRate, result.PayloadSampleRate)
+		result.ExtraHeaders = append(result.ExtraHeaders, opt.ExtraHeaders...)
+		result.PayloadRedact = append(result.PayloadRedact, opt.PayloadRedact...)
 	}
 
 	return result
@@ -113,21 +213,168 @@ func NewClient(ctx context.Context, credStore credentials.CredentialStore, opts
 	cfg := openai.DefaultConfig(opt.APIKey)
 	cfg.BaseURL = types.FirstSet(opt.BaseURL, cfg.BaseURL)
 	cfg.OrgID = types.FirstSet(opt.OrgID, cfg.OrgID)
+	transport, err := newHTTPTransport(opt)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseModelTimeout(opt.ModelTimeout)
+	if err != nil {
+		return nil, err
+	}
+	extraHeaders, err := parseHeaders(opt.ExtraHeaders)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := newKeyPool(opt.APIKey, opt.APIKeyCooldown)
+	if err != nil {
+		return nil, err
+	}
+	// The rate limits that a provider reports are those of one key, so they are only followed when there is one.
+	var limits *providerLimits
+	if keys == nil {
+		limits = newProviderLimits()
+	} else {
+		transport = &keyTransport{
+			base:          transport,
+			pool:          keys,
+			authorization: "Bearer " + opt.APIKey,
+		}
+	}
+	// Only the final response to a retried request is recorded in a cassette.
+	base, err := newRetryTransport(transport, opt.MaxRetries, opt.RetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	var replaying bool
+	if opt.Cassette != "" {
+		c, err := cassette.Open(opt.Cassette, opt.CassetteMode)
+		if err != nil {
+			return nil, err
+		}
+		base = c.Transport(base)
+		replaying = c.Replaying()
+	}
+	// Replayed responses say nothing of the current limits of the provider.
+	if replaying {
+		limits = nil
+	}
+	primary := base
+	if limits != nil {
+		primary = &rateLimitTransport{
+			base:   base,
+			limits: limits,
+		}
+	}
+	cfg.HTTPClient = &http.Client{
+		Transport: &headerTransport{
+			base: &bodyTransport{
+				base: &payloadTransport{
+					base: &logProbsTransport{
+						base: &cacheUsageTransport{base: primary},
+					},
+				},
+			},
+			header: extraHeaders,
+		},
+		Timeout: timeout,
+	}
+	// The extra headers are only for the provider of the options, not for its fallbacks.
+	fallbackClient := &http.Client{
+		Transport: &headerTransport{
+			base: &bodyTransport{
+				base: &payloadTransport{
+					base: &logProbsTransport{
+						base: &cacheUsageTransport{base: base},
+					},
+				},
+			},
+		},
+		Timeout: timeout,
+	}
+
+	semantic, err := newSemanticCache(opt.SemanticCache)
+	if err != nil {
+		return nil, err
+	}
+	payloads, err := newPayloadSampler(opt.PayloadSampleRate, opt.PayloadRedact, opt.APIKey)
+	if err != nil {
+		return nil, err
+	}
 
 	cacheKeyBase := opt.CacheKey
-	if cacheKeyBase == "" {
+	if cacheKeyBase == "" && opt.Cache.Shared() {
+		// The responses in a shared cache are shared by everyone who uses the provider, whatever their API key.
+		cacheKeyBase = hash.ID(opt.BaseURL)
+	} else if cacheKeyBase == "" {
 		cacheKeyBase = hash.ID(opt.APIKey, opt.BaseURL)
 	}
 
-	return &Client{
+	systemPrompt, err := NewSystemPrompt(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	adaptations, err := loadModelAdaptations(opt.ModelsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.ReasoningEffort != "" {
+		if err := validateReasoningEffort(opt.ReasoningEffort); err != nil {
+			return nil, err
+		}
+	}
+
+	modelsTTL := defaultModelsTTL
+	if opt.ModelsCacheTTL != "" {
+		if modelsTTL, err = time.ParseDuration(opt.ModelsCacheTTL); err != nil || modelsTTL < 0 {
+			return nil, fmt.Errorf("invalid models cache TTL %q", opt.ModelsCacheTTL)
+		}
+	}
+
+	// Requests to the embeddings and Batch APIs don't carry the fields that are added to the body of chat requests.
+	apiClient := &http.Client{Transport: &headerTransport{base: base, header: extraHeaders}, Timeout: timeout}
+
+	c := &Client{
 		c:            openai.NewClientWithConfig(cfg),
 		cache:        opt.Cache,
 		defaultModel: opt.DefaultModel,
 		cacheKeyBase: cacheKeyBase,
-		invalidAuth:  opt.APIKey == "" && opt.BaseURL == "",
-		setSeed:      opt.SetSeed,
-		credStore:    credStore,
-	}, nil
+		// A replayed cassette does not need credentials
+		invalidAuth:    opt.APIKey == "" && opt.BaseURL == "" && !replaying,
+		setSeed:        opt.SetSeed,
+		sendLabels:     opt.SendLabels,
+		credStore:      credStore,
+		hooks:          opt.Hooks,
+		systemPrompt:   systemPrompt,
+		adaptations:    adaptations,
+		wholeToolCalls: opt.WholeToolCalls,
+		apiKey:         opt.APIKey,
+		semantic:       semantic,
+		payloads:       payloads,
+		embeddingModel: types.FirstSet(opt.EmbeddingModel, defaultEmbeddingModel),
+		embeddings: embeddingsAPI{
+			url:    strings.TrimRight(cfg.BaseURL, "/") + "/embeddings",
+			orgID:  cfg.OrgID,
+			client: apiClient,
+		},
+		limiter:        newRateLimiter(opt.RequestsPerMinute, opt.TokensPerMinute),
+		providerLimits: limits,
+		baseURL:        cfg.BaseURL,
+		fallbacks:      newFallbacks(adaptations, fallbackClient),
+
+		contentFilterModel: opt.ContentFilterModel,
+		reasoningEffort:    opt.ReasoningEffort,
+		modelsTTL:          modelsTTL,
+		refreshModels:      opt.RefreshModels,
+	}
+	c.batch, err = newBatcher(opt.Batch, opt.BatchPollInterval, cfg.BaseURL, cfg.OrgID, apiClient, c.cache, func() string {
+		return c.apiKey
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *Client) ValidAuth() error {
@@ -166,6 +413,10 @@ func (c *Client) ListModels(ctx context.Context, providers ...string) (result []
 		return nil, nil
 	}
 
+	if models, ok, err := c.cachedModels(ctx); err != nil || ok {
+		return models, err
+	}
+
 	models, err := c.c.ListModels(ctx)
 	if err != nil {
 		return nil, err
@@ -174,17 +425,62 @@ func (c *Client) ListModels(ctx context.Context, providers ...string) (result []
 		result = append(result, model.ID)
 	}
 	sort.Strings(result)
-	return result, nil
+	return result, c.storeModels(ctx, result)
 }
 
-func (c *Client) cacheKey(request openai.ChatCompletionRequest) any {
-	return map[string]any{
+func (c *Client) cacheKey(ctx context.Context, request openai.ChatCompletionRequest) any {
+	if c.cache.CanonicalKeys() {
+		request = canonicalRequest(request)
+	}
+	key := map[string]any{
 		"base":    c.cacheKeyBase,
 		"request": request,
 	}
+	if fields := bodyFields(ctx); len(fields) > 0 {
+		key["fields"] = fields
+	}
+	if candidate := candidate(ctx); candidate > 0 {
+		key["candidate"] = candidate
+	}
+	return key
 }
 
-func (c *Client) seed(request openai.ChatCompletionRequest) int {
+// canonicalRequest removes the differences between requests that do not change the response, such as the order of
+// the tools, the whitespace of system prompts, and the user field, and derives the seed from what is left.
+func canonicalRequest(request openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	request.User = ""
+
+	request.Tools = slices.Clone(request.Tools)
+	slices.SortStableFunc(request.Tools, func(a, b openai.Tool) int {
+		var nameA, nameB string
+		if a.Function != nil {
+			nameA = a.Function.Name
+		}
+		if b.Function != nil {
+			nameB = b.Function.Name
+		}
+		return strings.Compare(nameA, nameB)
+	})
+
+	request.Messages = slices.Clone(request.Messages)
+	for i, msg := range request.Messages {
+		if msg.Role == openai.ChatMessageRoleSystem {
+			msg.Content = strings.Join(strings.Fields(msg.Content), " ")
+			request.Messages[i] = msg
+		}
+	}
+
+	// The seed is derived from the request, so it is derived again from the canonical request. The candidate that is
+	// added to it is already part of the key.
+	if request.Seed != nil {
+		request.Seed = nil
+		request.Seed = ptr(seed(request))
+	}
+
+	return request
+}
+
+func seed(request openai.ChatCompletionRequest) int {
 	newRequest := request
 	newRequest.Messages = nil
 
@@ -207,13 +503,30 @@ func (c *Client) fromCache(ctx context.Context, messageRequest types.CompletionR
 	if !messageRequest.GetCache() {
 		return nil, false, nil
 	}
-	found, err := c.cache.Get(ctx, c.cacheKey(request), &result)
+	reader, found, err := c.cache.NewReader(ctx, c.cacheKey(ctx, request))
 	if err != nil {
 		return nil, false, err
 	} else if !found {
-		return nil, false, nil
+		// Responses used to be cached as a single value.
+		found, err = c.cache.Get(ctx, c.cacheKey(ctx, request), &result)
+		if err != nil || !found {
+			return nil, false, err
+		}
+		return result, true, nil
+	}
+	defer reader.Close()
+
+	for {
+		var response openai.ChatCompletionStreamResponse
+		if err := reader.Next(&response); err == io.EOF {
+			return result, true, nil
+		} else if err != nil {
+			// Like an entry that can't be decoded, a broken stream is a miss.
+			log.WithContext(ctx).Debugf("ignoring cached response: %v", err)
+			return nil, false, nil
+		}
+		result = append(result, response)
 	}
-	return result, true, nil
 }
 
 func toToolCall(call types.CompletionToolCall) openai.ToolCall {
@@ -227,14 +540,19 @@ func toToolCall(call types.CompletionToolCall) openai.ToolCall {
 	}
 }
 
-func toMessages(request types.CompletionRequest, compat bool) (result []openai.ChatCompletionMessage, err error) {
+// internalSystemPrompt renders the internal system prompt for the model.
+func (c *Client) internalSystemPrompt(model string) (string, error) {
+	return c.systemPrompt.Render(model)
+}
+
+func toMessages(request types.CompletionRequest, compat bool, internalSystemPrompt string) (result []openai.ChatCompletionMessage, err error) {
 	var (
 		systemPrompts []string
 		msgs          []types.CompletionMessage
 	)
 
 	if !compat && (request.InternalSystemPrompt == nil || *request.InternalSystemPrompt) {
-		systemPrompts = append(systemPrompts, system.InternalSystemPrompt)
+		systemPrompts = append(systemPrompts, internalSystemPrompt)
 	}
 
 	for _, message := range request.Messages {
@@ -273,6 +591,15 @@ func toMessages(request types.CompletionRequest, compat bool) (result []openai.C
 					Text: content.Text,
 				})
 			}
+			if content.Image != nil {
+				chatMessage.MultiContent = append(chatMessage.MultiContent, openai.ChatMessagePart{
+					Type: openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{
+						URL:    content.Image.DataURL(),
+						Detail: openai.ImageURLDetail(content.Image.Detail),
+					},
+				})
+			}
 		}
 
 		if len(chatMessage.MultiContent) == 1 && chatMessage.MultiContent[0].Type == openai.ChatMessagePartTypeText {
@@ -304,17 +631,23 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 		messageRequest.Model = c.defaultModel
 	}
 
-	msgs, err := toMessages(messageRequest, !c.setSeed)
+	internalSystemPrompt, err := c.internalSystemPrompt(messageRequest.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := toMessages(messageRequest, !c.setSeed && !c.systemPrompt.Custom(), internalSystemPrompt)
 	if err != nil {
 		return nil, err
 	}
 
+	limits := modelLimits(c.adaptations, messageRequest.Model)
 	if messageRequest.Chat {
-		msgs = dropMessagesOverCount(messageRequest.MaxTokens, msgs)
+		msgs = dropMessagesOverCount(limits.inputBudget(messageRequest.MaxTokens), msgs)
 	}
 
 	if len(msgs) == 0 {
-		log.Errorf("invalid request, no messages to send to LLM")
+		log.WithContext(ctx).Errorf("invalid request, no messages to send to LLM")
 		return &types.CompletionMessage{
 			Role:    types.CompletionMessageRoleTypeAssistant,
 			Content: types.Text(""),
@@ -327,12 +660,29 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 		MaxTokens: messageRequest.MaxTokens,
 	}
 
+	if messageRequest.Choices > 1 {
+		request.N = messageRequest.Choices
+	}
+
 	if messageRequest.Temperature == nil {
 		request.Temperature = new(float32)
 	} else {
 		request.Temperature = messageRequest.Temperature
 	}
 
+	if messageRequest.TopP != nil {
+		request.TopP = *messageRequest.TopP
+	}
+	if messageRequest.FrequencyPenalty != nil {
+		request.FrequencyPenalty = *messageRequest.FrequencyPenalty
+	}
+	if messageRequest.PresencePenalty != nil {
+		request.PresencePenalty = *messageRequest.PresencePenalty
+	}
+	request.Stop = messageRequest.Stop
+	request.LogProbs = messageRequest.LogProbs || messageRequest.TopLogProbs > 0
+	request.TopLogProbs = messageRequest.TopLogProbs
+
 	if messageRequest.JSONResponse {
 		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
 			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
@@ -358,49 +708,136 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 		})
 	}
 
+	if len(request.Tools) > 0 {
+		request.ToolChoice = toolChoice(messageRequest.ToolChoice)
+	}
+
+	adaptRequest(c.adaptations, &request)
+
+	promptTokens := countRequest(request)
+	if limits.ContextWindow != 0 && promptTokens > limits.ContextWindow*9/10 {
+		log.WithContext(ctx).Warnf("The request to %s is about %d tokens, which is close to or over its context window of %d tokens", request.Model, promptTokens, limits.ContextWindow)
+	}
+	request.MaxTokens = limits.maxTokens(request.MaxTokens, promptTokens)
+	// Providers count the tokens a request may generate against the limit until it is done.
+	reserveTokens := promptTokens + request.MaxTokens
+	fields := parallelToolCallsFields(responseFormatFields(guidedDecodingFields(c.adaptations, messageRequest, request.Model), messageRequest), messageRequest)
+	if reasoning, ok := findReasoningModel(c.adaptations, request.Model); ok {
+		fields = reasoning.adapt(ctx, &request, fields, c.reasoningEffort)
+		if reasoning.noStreaming {
+			ctx = withoutStreaming(ctx)
+		}
+	}
+	ctx = withBodyFields(ctx, fields)
+	ctx = withCandidate(ctx, messageRequest.Candidate)
+	ctx = withLogProbs(ctx, request.LogProbs)
+	ctx = withCacheControl(ctx, promptCaching(c.adaptations, request.Model))
+	ctx = withCacheUsage(ctx)
+	ctx = withPayload(ctx, c.payloads)
+
+	ctx, err = c.hooks.beforeRequest(ctx, &request)
+	if err != nil {
+		return nil, err
+	}
+
 	id := counter.Next()
 	status <- types.CompletionStatus{
 		CompletionID: id,
 		Request:      request,
+		Candidate:    messageRequest.Candidate,
 	}
 
-	var cacheResponse bool
+	var cacheResponse, approximate, shared bool
 	if c.setSeed {
-		request.Seed = ptr(c.seed(request))
+		request.Seed = ptr(seed(request) + messageRequest.Candidate)
 		request.StreamOptions = &openai.StreamOptions{
 			IncludeUsage: true,
 		}
 	}
+	var (
+		timing *types.Timing
+		sent   *rateEntry
+	)
 	response, ok, err := c.fromCache(ctx, messageRequest, request)
 	if err != nil {
 		return nil, err
 	} else if !ok {
-		response, err = c.call(ctx, request, id, status)
-		if err != nil {
-			return nil, err
+		var key *semanticKey
+		if messageRequest.GetCache() {
+			response, key, approximate = c.fromSemanticCache(ctx, request)
+		}
+		if !approximate {
+			if c.sendLabels {
+				ctx = withLabelFields(ctx)
+			}
+			response, timing, shared, err = c.dedupe(ctx, messageRequest, request, status, func(ctx context.Context, status chan<- types.CompletionStatus) (responses []openai.ChatCompletionStreamResponse, _ *types.Timing, err error) {
+				// The log probabilities are cached before the response is shared with identical requests, which read
+				// them from the cache.
+				defer func() {
+					if err == nil {
+						c.storeLogProbs(ctx, request)
+					}
+				}()
+				// The Batch API has its own limits, which are much higher than those of requests that are sent one by one.
+				if c.batch != nil {
+					return c.callBatch(ctx, request, id, status)
+				}
+				sent, err = c.limiter.wait(ctx, reserveTokens)
+				if err != nil {
+					return nil, nil, err
+				}
+				if err := c.providerLimits.wait(ctx, request.Model, reserveTokens); err != nil {
+					return nil, nil, err
+				}
+				return c.callWithFailover(withLimitsModel(ctx, request.Model), request, id, status)
+			})
+			if filter, ok := contentFilterError(err); ok {
+				return c.contentFiltered(ctx, messageRequest, request.Model, id, filter, status)
+			} else if err != nil {
+				return nil, err
+			}
+			if !shared && !filteredResponse(response...) {
+				c.storeSemantic(ctx, key, response)
+			}
 		}
+		// A response shared with an identical request is not generated for this one, so like a cached one it costs
+		// nothing.
+		cacheResponse = approximate || shared
 	} else {
 		cacheResponse = true
 	}
 
-	result := types.CompletionMessage{}
-	for _, response := range response {
-		result = appendMessage(result, response)
-	}
-
-	for i, content := range result.Content {
-		if content.ToolCall != nil && content.ToolCall.ID == "" {
-			content.ToolCall.ID = "call_" + hash.ID(content.ToolCall.Function.Name, content.ToolCall.Function.Arguments)[:8]
-			result.Content[i] = content
+	var (
+		result    types.CompletionMessage
+		rateLimit *types.RateLimit
+		payload   *types.CompletionPayload
+	)
+	for i, choice := range responseChoices(response) {
+		message := toCompletionMessage(ctx, choice)
+		if i == 0 {
+			result = message
+		} else {
+			message.Usage = types.Usage{}
+			result.Candidates = append(result.Candidates, message)
 		}
 	}
 
-	if result.Role == "" {
-		result.Role = types.CompletionMessageRoleTypeAssistant
-	}
-
 	if cacheResponse {
 		result.Usage = types.Usage{}
+	} else {
+		getCacheUsage(ctx).set(&result.Usage)
+		payload = getPayload(ctx).result()
+		c.limiter.settle(sent, result.Usage.TotalTokens)
+		rateLimit = c.providerLimits.latest(request.Model)
+		setRate(timing, result)
+		if timing != nil {
+			log.WithContext(ctx).Debugf("Response from %s took %s, first token after %s, %.1f tokens/s", request.Model,
+				timing.Duration, timing.TimeToFirstToken, timing.TokensPerSecond)
+		}
+	}
+
+	if err := c.hooks.afterResponse(ctx, request, &result, cacheResponse); err != nil {
+		return nil, err
 	}
 
 	status <- types.CompletionStatus{
@@ -409,11 +846,77 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 		Response:     result,
 		Usage:        result.Usage,
 		Cached:       cacheResponse,
+		Approximate:  approximate,
+		Candidate:    messageRequest.Candidate,
+		Model:        request.Model,
+		Timing:       timing,
+		RateLimit:    rateLimit,
+		LogProbs:     c.responseLogProbs(ctx, request),
+		Payload:      payload,
+
+		ContentFilter: result.ContentFilter,
 	}
 
+	if retry, ok := c.contentFilterRetry(ctx, messageRequest, result.ContentFilter); ok {
+		return c.Call(ctx, retry, status)
+	}
 	return &result, nil
 }
 
+// toolChoice returns the tool_choice of a request, which is a string for auto, none, and required, and an object that
+// names the function otherwise.
+func toolChoice(choice string) any {
+	switch choice {
+	case "":
+		return nil
+	case types.ToolChoiceAuto, types.ToolChoiceNone, types.ToolChoiceRequired:
+		return choice
+	default:
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: choice},
+		}
+	}
+}
+
+// ToCompletionMessage merges the streamed responses of a request into a message. Providers that don't speak the OpenAI
+// API convert their streams into OpenAI stream responses to use it.
+func ToCompletionMessage(ctx context.Context, responses []openai.ChatCompletionStreamResponse) types.CompletionMessage {
+	return toCompletionMessage(ctx, responses)
+}
+
+// AppendMessage adds a streamed response to a message that is being generated.
+func AppendMessage(msg types.CompletionMessage, response openai.ChatCompletionStreamResponse) types.CompletionMessage {
+	return appendMessage(msg, response)
+}
+
+func toCompletionMessage(ctx context.Context, responses []openai.ChatCompletionStreamResponse) types.CompletionMessage {
+	result := types.CompletionMessage{}
+	for _, response := range responses {
+		result = appendMessage(result, response)
+	}
+
+	for i, content := range result.Content {
+		if content.ToolCall == nil {
+			continue
+		}
+		if args, ok := repairArguments(content.ToolCall.Function.Arguments); ok {
+			log.WithContext(ctx).Debugf("Repaired the invalid JSON arguments of the call to %s: %s", content.ToolCall.Function.Name, content.ToolCall.Function.Arguments)
+			content.ToolCall.Function.Arguments = args
+			result.Content[i] = content
+		}
+		if content.ToolCall.ID == "" {
+			content.ToolCall.ID = "call_" + hash.ID(content.ToolCall.Function.Name, content.ToolCall.Function.Arguments)[:8]
+			result.Content[i] = content
+		}
+	}
+
+	if result.Role == "" {
+		result.Role = types.CompletionMessageRoleTypeAssistant
+	}
+	return result
+}
+
 func appendMessage(msg types.CompletionMessage, response openai.ChatCompletionStreamResponse) types.CompletionMessage {
 	msg.Usage.CompletionTokens = types.FirstSet(msg.Usage.CompletionTokens, response.Usage.CompletionTokens)
 	msg.Usage.PromptTokens = types.FirstSet(msg.Usage.PromptTokens, response.Usage.PromptTokens)
@@ -423,6 +926,8 @@ func appendMessage(msg types.CompletionMessage, response openai.ChatCompletionSt
 		return msg
 	}
 
+	msg.ContentFilter = appendContentFilter(msg.ContentFilter, response.Choices[0])
+
 	delta := response.Choices[0].Delta
 	msg.Role = types.CompletionMessageRoleType(override(string(msg.Role), delta.Role))
 
@@ -486,8 +991,8 @@ func override(left, right string) string {
 	return left
 }
 
-func (c *Client) call(ctx context.Context, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus) (responses []openai.ChatCompletionStreamResponse, _ error) {
-	streamResponse := os.Getenv("GPTSCRIPT_INTERNAL_OPENAI_STREAMING") != "false"
+func (c *Client) call(ctx context.Context, p provider, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus) (responses []openai.ChatCompletionStreamResponse, _ *types.Timing, _ error) {
+	streamResponse := os.Getenv("GPTSCRIPT_INTERNAL_OPENAI_STREAMING") != "false" && streaming(ctx)
 
 	partial <- types.CompletionStatus{
 		CompletionID: transactionID,
@@ -495,67 +1000,120 @@ func (c *Client) call(ctx context.Context, request openai.ChatCompletionRequest,
 			Role:    types.CompletionMessageRoleTypeAssistant,
 			Content: types.Text("Waiting for model response..."),
 		},
+		Placeholder: true,
+		Candidate:   candidate(ctx),
 	}
 
-	slog.Debug("calling openai", "message", request.Messages)
+	log.WithContext(ctx).Fields("message", request.Messages).Debugf("calling openai")
+
+	timing := newTimer()
 
 	if !streamResponse {
 		request.StreamOptions = nil
-		resp, err := c.c.CreateChatCompletion(ctx, request)
+		resp, err := p.client.CreateChatCompletion(ctx, p.request(request))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return []openai.ChatCompletionStreamResponse{
-			{
-				ID:      resp.ID,
-				Object:  resp.Object,
-				Created: resp.Created,
-				Model:   resp.Model,
-				Usage:   resp.Usage,
-				Choices: []openai.ChatCompletionStreamChoice{
-					{
-						Index: resp.Choices[0].Index,
-						Delta: openai.ChatCompletionStreamChoiceDelta{
-							Content:      resp.Choices[0].Message.Content,
-							Role:         resp.Choices[0].Message.Role,
-							FunctionCall: resp.Choices[0].Message.FunctionCall,
-							ToolCalls:    resp.Choices[0].Message.ToolCalls,
-						},
-						FinishReason: resp.Choices[0].FinishReason,
-					},
-				},
-			},
-		}, nil
+		if l := getLogProbs(ctx); l != nil {
+			l.addResponse(resp)
+		}
+		response := toStreamResponse(resp)
+		// Without streaming, the first token arrives with the rest of the response.
+		timing.received(response)
+		return []openai.ChatCompletionStreamResponse{response}, timing.done(), nil
 	}
 
-	stream, err := c.c.CreateChatCompletionStream(ctx, request)
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.request(request))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer stream.Close()
 
-	var partialMessage types.CompletionMessage
+	// The responses are cached as they arrive, so a long stream is not encoded in one piece at the end.
+	cacheWriter, err := c.cache.NewWriter(ctx, c.cacheKey(ctx, request))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cacheWriter.Abort()
+
+	var (
+		partialMessage types.CompletionMessage
+		toolCalls      map[int]int
+		filtered       bool
+	)
 	for {
 		response, err := stream.Recv()
 		if err == io.EOF {
-			return responses, c.cache.Store(ctx, c.cacheKey(request), responses)
+			if filtered {
+				return responses, timing.done(), nil
+			}
+			return responses, timing.done(), cacheWriter.Close()
 		} else if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if c.wholeToolCalls {
+			toolCalls = indexToolCalls(response, toolCalls)
+		}
+		timing.received(response)
+		filtered = filtered || filteredResponse(response)
+		if err := cacheWriter.Write(response); err != nil {
+			return nil, nil, err
 		}
 		if len(response.Choices) > 0 {
-			slog.Debug("stream", "content", response.Choices[0].Delta.Content)
+			log.WithContext(ctx).Fields("content", response.Choices[0].Delta.Content).Debugf("stream")
 		}
-		if partial != nil {
-			partialMessage = appendMessage(partialMessage, response)
+		// Only the first choice is shown as it is generated.
+		if first, ok := firstChoice(response); partial != nil && ok {
+			partialMessage = appendMessage(partialMessage, first)
 			partial <- types.CompletionStatus{
 				CompletionID:    transactionID,
 				PartialResponse: &partialMessage,
+				Candidate:       candidate(ctx),
 			}
 		}
 		responses = append(responses, response)
 	}
 }
 
+// toStreamResponse converts a response that was not streamed to a streamed response with the whole response.
+func toStreamResponse(resp openai.ChatCompletionResponse) openai.ChatCompletionStreamResponse {
+	response := openai.ChatCompletionStreamResponse{
+		ID:      resp.ID,
+		Object:  resp.Object,
+		Created: resp.Created,
+		Model:   resp.Model,
+		Usage:   resp.Usage,
+	}
+	for _, choice := range resp.Choices {
+		response.Choices = append(response.Choices, openai.ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: openai.ChatCompletionStreamChoiceDelta{
+				Content:      choice.Message.Content,
+				Role:         choice.Message.Role,
+				FunctionCall: choice.Message.FunctionCall,
+				ToolCalls:    choice.Message.ToolCalls,
+			},
+			FinishReason: choice.FinishReason,
+		})
+	}
+	return response
+}
+
+// indexToolCalls numbers the tool calls of each choice in the order they are streamed, for providers that send every
+// tool call whole, so that calls with the same index are not merged. It returns the number of calls seen per choice.
+func indexToolCalls(response openai.ChatCompletionStreamResponse, seen map[int]int) map[int]int {
+	if seen == nil {
+		seen = map[int]int{}
+	}
+	for i, choice := range response.Choices {
+		for j := range choice.Delta.ToolCalls {
+			response.Choices[i].Delta.ToolCalls[j].Index = ptr(seen[choice.Index])
+			seen[choice.Index]++
+		}
+	}
+	return seen
+}
+
 func (c *Client) RetrieveAPIKey(ctx context.Context) error {
 	k, err := prompt.GetModelProviderCredential(ctx, c.credStore, BuiltinCredName, "OPENAI_API_KEY", "Please provide your OpenAI API key:", gcontext.GetEnv(ctx))
 	if err != nil {
@@ -563,6 +1121,7 @@ func (c *Client) RetrieveAPIKey(ctx context.Context) error {
 	}
 
 	c.c.SetAPIKey(k)
+	c.apiKey = k
 	c.invalidAuth = false
 	return nil
 }
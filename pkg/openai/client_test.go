@@ -1,12 +1,26 @@
 package openai
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/hexops/autogold/v2"
 	"github.com/hexops/valast"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_appendMessage(t *testing.T) {
@@ -48,3 +62,352 @@ func Test_appendMessage(t *testing.T) {
 		},
 	}))
 }
+
+func TestClientHooksVeto(t *testing.T) {
+	vetoErr := errors.New("vetoed")
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		APIKey: "test",
+		Hooks: &ClientHooks{
+			BeforeRequest: func(context.Context, *openai.ChatCompletionRequest, http.Header) error {
+				return vetoErr
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = c.Call(context.Background(), types.CompletionRequest{
+		Model:    "test",
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+	}, nil)
+	require.ErrorIs(t, err, vetoErr)
+}
+
+func TestHeaderTransport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		autogold.Expect("injected").Equal(t, r.Header.Get("X-Test"))
+	}))
+	defer s.Close()
+
+	ctx, err := (&ClientHooks{
+		BeforeRequest: func(_ context.Context, _ *openai.ChatCompletionRequest, header http.Header) error {
+			header.Set("X-Test", "injected")
+			return nil
+		},
+	}).beforeRequest(context.Background(), &openai.ChatCompletionRequest{})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: &headerTransport{base: http.DefaultTransport}}).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
+
+func TestInternalSystemPrompt(t *testing.T) {
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		APIKey:               "test",
+		InternalSystemPrompt: "You are {{.Model}}.",
+		AppendSystemPrompt:   "Be brief.",
+		SystemPromptTemplate: true,
+	})
+	require.NoError(t, err)
+
+	prompt, err := c.internalSystemPrompt("gpt-4o-mini")
+	require.NoError(t, err)
+	require.Equal(t, "You are gpt-4o-mini.\nBe brief.", prompt)
+
+	_, err = NewClient(context.Background(), credentials.NoopStore{}, Options{
+		APIKey:               "test",
+		InternalSystemPrompt: "{{.Model",
+		SystemPromptTemplate: true,
+	})
+	require.Error(t, err)
+
+	// Prompts are sent as is unless they are templates.
+	c, err = NewClient(context.Background(), credentials.NoopStore{}, Options{
+		APIKey:             "test",
+		AppendSystemPrompt: "Reply with {{.Name}} or {{",
+	})
+	require.NoError(t, err)
+
+	prompt, err = c.internalSystemPrompt("gpt-4o-mini")
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(prompt, "\nReply with {{.Name}} or {{"))
+
+	// Custom prompts are sent to providers that don't get the default one.
+	require.True(t, c.systemPrompt.Custom())
+	msgs, err := toMessages(types.CompletionRequest{}, !c.setSeed && !c.systemPrompt.Custom(), prompt)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, prompt, msgs[0].Content)
+}
+
+func TestModelAdaptation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`models:
+- match: ["*llama*"]
+  systemRole: user
+  toolCallFormat: text
+  stop: ["<|eot_id|>"]
+`), 0644))
+
+	adaptations, err := loadModelAdaptations(file)
+	require.NoError(t, err)
+
+	request := openai.ChatCompletionRequest{
+		Model: "Meta-Llama-3-70B",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: "Be helpful."},
+			{Role: "user", Content: "What time is it?"},
+			{Role: "assistant", ToolCalls: []openai.ToolCall{{ID: "call_1", Function: openai.FunctionCall{Name: "time", Arguments: "{}"}}}},
+			{Role: "tool", ToolCallID: "call_1", Name: "time", Content: "noon"},
+		},
+	}
+	adaptRequest(adaptations, &request)
+
+	autogold.Expect(openai.ChatCompletionRequest{
+		Model: "Meta-Llama-3-70B",
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    "user",
+				Content: "Be helpful.\n\nWhat time is it?",
+			},
+			{
+				Role:    "assistant",
+				Content: "Calling tool time with arguments {}",
+			},
+			{
+				Role:    "user",
+				Content: "Result of tool time:\nnoon",
+			},
+		},
+		Stop: []string{"<|eot_id|>"},
+	}).Equal(t, request)
+
+	require.NoError(t, os.WriteFile(file, []byte("models:\n- match: [\"gpt-*\"]\n  systemRole: developer\n"), 0644))
+	_, err = loadModelAdaptations(file)
+	require.ErrorContains(t, err, "invalid systemRole")
+}
+
+func TestModelLimits(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`models:
+- match: ["gpt-4o-mini*"]
+  maxOutputTokens: 1000
+- match: ["my-model"]
+  contextWindow: 10000
+`), 0644))
+
+	adaptations, err := loadModelAdaptations(file)
+	require.NoError(t, err)
+
+	require.Equal(t, ModelLimits{ContextWindow: 128_000, MaxOutputTokens: 16_384}, modelLimits(adaptations, "GPT-4o"))
+	require.Equal(t, ModelLimits{ContextWindow: 128_000, MaxOutputTokens: 1000}, modelLimits(adaptations, "gpt-4o-mini"))
+	require.Equal(t, ModelLimits{ContextWindow: 32_768}, modelLimits(adaptations, "gpt-4-32k-0613"))
+	require.Equal(t, ModelLimits{ContextWindow: 65_536}, modelLimits(adaptations, "qwen2.5-coder-64k"))
+	require.Equal(t, ModelLimits{ContextWindow: 10_000}, modelLimits(adaptations, "my-model"))
+	require.Equal(t, ModelLimits{}, modelLimits(adaptations, "unknown"))
+
+	limits := ModelLimits{ContextWindow: 10_000, MaxOutputTokens: 4_000}
+	// The provider's default is kept when the response fits.
+	require.Equal(t, 0, limits.maxTokens(0, 1_000))
+	// The response is limited to what is left of the context window.
+	require.Equal(t, 2_000, limits.maxTokens(0, 8_000))
+	require.Equal(t, 2_000, limits.maxTokens(3_000, 8_000))
+	// The max tokens of a tool is lowered to what the model can generate.
+	require.Equal(t, 4_000, limits.maxTokens(5_000, 1_000))
+	require.Equal(t, 500, limits.maxTokens(500, 1_000))
+	require.Equal(t, 7_500, limits.inputBudget(0))
+	require.Equal(t, 9_500, limits.inputBudget(500))
+	require.Equal(t, 300_000, ModelLimits{}.inputBudget(0))
+
+	require.NoError(t, os.WriteFile(file, []byte("models:\n- match: [\"gpt-*\"]\n  contextWindow: 100\n  maxOutputTokens: 200\n"), 0644))
+	_, err = loadModelAdaptations(file)
+	require.ErrorContains(t, err, "maxOutputTokens 200 is larger than contextWindow 100")
+}
+
+func TestResponseChoices(t *testing.T) {
+	chunk := func(index int, content string) openai.ChatCompletionStreamChoice {
+		return openai.ChatCompletionStreamChoice{Index: index, Delta: openai.ChatCompletionStreamChoiceDelta{Content: content}}
+	}
+	responses := []openai.ChatCompletionStreamResponse{
+		{Choices: []openai.ChatCompletionStreamChoice{chunk(0, "Pos"), chunk(1, "Neg")}},
+		{Choices: []openai.ChatCompletionStreamChoice{chunk(1, "ative")}},
+		{Choices: []openai.ChatCompletionStreamChoice{chunk(0, "itive")}},
+		{Usage: openai.Usage{TotalTokens: 10}},
+	}
+
+	choices := responseChoices(responses)
+	require.Len(t, choices, 2)
+	require.Equal(t, "Positive", toCompletionMessage(context.Background(), choices[0]).ChatText())
+	require.Equal(t, 10, toCompletionMessage(context.Background(), choices[0]).Usage.TotalTokens)
+	require.Equal(t, "Negative", toCompletionMessage(context.Background(), choices[1]).ChatText())
+
+	first, ok := firstChoice(responses[0])
+	require.True(t, ok)
+	require.Equal(t, "Pos", first.Choices[0].Delta.Content)
+	_, ok = firstChoice(responses[1])
+	require.False(t, ok)
+	_, ok = firstChoice(responses[3])
+	require.True(t, ok)
+}
+
+func TestGuidedDecoding(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`models:
+- match: ["qwen*"]
+  guidedDecoding: vllm
+`), 0644))
+
+	adaptations, err := loadModelAdaptations(file)
+	require.NoError(t, err)
+
+	messageRequest := types.CompletionRequest{
+		OutputSchema: []byte(`{"type": "object"}`),
+		OutputRegex:  "[a-z]+",
+	}
+	fields := guidedDecodingFields(adaptations, messageRequest, "qwen2.5-coder")
+	require.Len(t, fields, 2)
+	require.Nil(t, guidedDecodingFields(adaptations, messageRequest, "gpt-4o"))
+
+	// A constrained response could not call a tool
+	messageRequest.Tools = []types.CompletionTool{{}}
+	require.Nil(t, guidedDecodingFields(adaptations, messageRequest, "qwen2.5-coder"))
+
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"model": "qwen2.5-coder", "guided_json": {"type": "object"}, "guided_regex": "[a-z]+"}`, string(data))
+	}))
+	defer s.Close()
+
+	req, err := http.NewRequestWithContext(withBodyFields(context.Background(), fields), http.MethodPost, s.URL, strings.NewReader(`{"model": "qwen2.5-coder"}`))
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: &bodyTransport{base: http.DefaultTransport}}).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
+
+func TestResponseFormatFields(t *testing.T) {
+	messageRequest := types.CompletionRequest{
+		OutputSchema: []byte(`{"type": "object"}`),
+	}
+	// The schema is only sent as the response format of a JSON response.
+	require.Nil(t, responseFormatFields(nil, messageRequest))
+
+	messageRequest.JSONResponse = true
+	fields := responseFormatFields(map[string]any{"guided_regex": "[a-z]+"}, messageRequest)
+	data, err := json.Marshal(fields)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"guided_regex": "[a-z]+", "response_format": {"type": "json_schema", "json_schema": {"name": "response", "schema": {"type": "object"}}}}`, string(data))
+}
+
+func TestParallelToolCallsFields(t *testing.T) {
+	messageRequest := types.CompletionRequest{
+		ParallelToolCalls: new(bool),
+	}
+	// Requests without tools must not have the field.
+	require.Nil(t, parallelToolCallsFields(nil, messageRequest))
+
+	messageRequest.Tools = []types.CompletionTool{{Function: types.CompletionFunctionDefinition{Name: "deploy"}}}
+	require.Equal(t, map[string]any{"parallel_tool_calls": false}, parallelToolCallsFields(nil, messageRequest))
+
+	messageRequest.ParallelToolCalls = ptr(true)
+	require.Nil(t, parallelToolCallsFields(nil, messageRequest))
+}
+
+func TestToolChoice(t *testing.T) {
+	require.Nil(t, toolChoice(""))
+	require.Equal(t, "required", toolChoice(types.ToolChoiceRequired))
+	require.Equal(t, openai.ToolChoice{
+		Type:     openai.ToolTypeFunction,
+		Function: openai.ToolFunction{Name: "deploy"},
+	}, toolChoice("deploy"))
+}
+
+func TestLabelFields(t *testing.T) {
+	ctx := withBodyFields(context.Background(), map[string]any{"guided_regex": "[a-z]+"})
+	ctx = gcontext.WithLabels(ctx, map[string]string{"team": "payments", "user": "alice"})
+
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"model": "gpt-4o", "guided_regex": "[a-z]+", "user": "alice", "metadata": {"team": "payments", "user": "alice"}}`, string(data))
+	}))
+	defer s.Close()
+
+	req, err := http.NewRequestWithContext(withLabelFields(ctx), http.MethodPost, s.URL, strings.NewReader(`{"model": "gpt-4o"}`))
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: &bodyTransport{base: http.DefaultTransport}}).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// The fields used for the cache key are not changed.
+	require.Equal(t, map[string]any{"guided_regex": "[a-z]+"}, bodyFields(ctx))
+}
+
+func TestCanonicalCacheKey(t *testing.T) {
+	tool := func(name string) openai.Tool {
+		return openai.Tool{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: name}}
+	}
+	a := openai.ChatCompletionRequest{
+		Model: "gpt-4o",
+		User:  "alice",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: "You are helpful.\n\nBe brief."},
+			{Role: "user", Content: "hi"},
+		},
+		Tools: []openai.Tool{tool("b"), tool("a")},
+	}
+	b := openai.ChatCompletionRequest{
+		Model: "gpt-4o",
+		User:  "bob",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: "  You are helpful. Be brief.\n"},
+			{Role: "user", Content: "hi"},
+		},
+		Tools: []openai.Tool{tool("a"), tool("b")},
+	}
+
+	// The seed is derived from the request as it is sent.
+	a.Seed, b.Seed = ptr(seed(a)), ptr(seed(b))
+	require.NotEqual(t, *a.Seed, *b.Seed)
+
+	for _, canonical := range []bool{false, true} {
+		cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir(), CanonicalCacheKeys: canonical})
+		require.NoError(t, err)
+		c := &Client{cache: cacheClient}
+		require.Equal(t, canonical, reflect.DeepEqual(c.cacheKey(context.Background(), a), c.cacheKey(context.Background(), b)))
+	}
+
+	// The original request must not be modified
+	require.Equal(t, "b", a.Tools[0].Function.Name)
+	require.Equal(t, "alice", a.User)
+	require.Equal(t, seed(openai.ChatCompletionRequest{Model: a.Model, User: a.User, Messages: a.Messages, Tools: a.Tools}), *a.Seed)
+}
+
+func TestSharedCacheKey(t *testing.T) {
+	request := openai.ChatCompletionRequest{Model: "gpt-4o", Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}}
+	key := func(cacheOpts cache.Options, apiKey string) any {
+		cacheOpts.CacheDir = t.TempDir()
+		cacheClient, err := cache.New(cacheOpts)
+		require.NoError(t, err)
+		c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+			BaseURL: "http://127.0.0.1:1/v1",
+			APIKey:  apiKey,
+			Cache:   cacheClient,
+		})
+		require.NoError(t, err)
+		return c.cacheKey(context.Background(), request)
+	}
+
+	// The local cache of each API key is its own.
+	require.NotEqual(t, key(cache.Options{}, "alice"), key(cache.Options{}, "bob"))
+
+	// Everyone who uses a shared cache gets the responses of the others.
+	shared := cache.Options{CacheURL: "http://127.0.0.1:1"}
+	require.Equal(t, key(shared, "alice"), key(shared, "bob"))
+}
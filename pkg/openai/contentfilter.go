@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// appendContentFilter adds the categories that the provider filtered from a streamed choice to the content filter of
+// the message. A choice that finished because of the content filter sets it even without categories.
+func appendContentFilter(filter *types.ContentFilter, choice openai.ChatCompletionStreamChoice) *types.ContentFilter {
+	categories := contentFilterCategories(choice.ContentFilterResults)
+	if len(categories) == 0 && choice.FinishReason != openai.FinishReasonContentFilter {
+		return filter
+	}
+
+	if filter == nil {
+		filter = &types.ContentFilter{
+			Filtered: true,
+		}
+	}
+	for _, category := range categories {
+		if !slices.Contains(filter.Categories, category) {
+			filter.Categories = append(filter.Categories, category)
+		}
+	}
+	return filter
+}
+
+// filteredResponse returns whether the content filter of the provider filtered any choice of the responses. Those
+// responses are not cached, since the same request may pass the filter, or be retried with another model, next time.
+func filteredResponse(responses ...openai.ChatCompletionStreamResponse) bool {
+	for _, response := range responses {
+		for _, choice := range response.Choices {
+			if appendContentFilter(nil, choice) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contentFilterCategories(results openai.ContentFilterResults) (categories []string) {
+	if results.Hate.Filtered {
+		categories = append(categories, "hate")
+	}
+	if results.SelfHarm.Filtered {
+		categories = append(categories, "self_harm")
+	}
+	if results.Sexual.Filtered {
+		categories = append(categories, "sexual")
+	}
+	if results.Violence.Filtered {
+		categories = append(categories, "violence")
+	}
+	return
+}
+
+// contentFilterError returns the content filter result of a request that the provider rejected because of its
+// content policy. Azure OpenAI rejects them with the content_filter code, and OpenAI with content_policy_violation.
+func contentFilterError(err error) (*types.ContentFilter, bool) {
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest {
+		return nil, false
+	}
+
+	code, _ := apiErr.Code.(string)
+	if code != "content_filter" && code != "content_policy_violation" &&
+		(apiErr.InnerError == nil || apiErr.InnerError.Code != "ResponsibleAIPolicyViolation") {
+		return nil, false
+	}
+
+	filter := &types.ContentFilter{
+		Filtered: true,
+		Prompt:   true,
+	}
+	if apiErr.InnerError != nil {
+		filter.Categories = contentFilterCategories(apiErr.InnerError.ContentFilterResults)
+	}
+	return filter, true
+}
+
+// contentFiltered returns the content filter result of a request that the provider rejected, or the response of the
+// request retried with the content filter model.
+func (c *Client) contentFiltered(ctx context.Context, messageRequest types.CompletionRequest, model, id string, filter *types.ContentFilter, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	result := types.CompletionMessage{
+		Role:          types.CompletionMessageRoleTypeAssistant,
+		ContentFilter: filter,
+	}
+
+	status <- types.CompletionStatus{
+		CompletionID:  id,
+		Response:      result,
+		Candidate:     messageRequest.Candidate,
+		Model:         model,
+		ContentFilter: filter,
+	}
+
+	if retry, ok := c.contentFilterRetry(ctx, messageRequest, filter); ok {
+		return c.Call(ctx, retry, status)
+	}
+	return &result, nil
+}
+
+// contentFilterRetry returns the request to retry with the content filter model, if one is set and the request was
+// not already sent to it.
+func (c *Client) contentFilterRetry(ctx context.Context, messageRequest types.CompletionRequest, filter *types.ContentFilter) (types.CompletionRequest, bool) {
+	if filter == nil || c.contentFilterModel == "" || messageRequest.Model == c.contentFilterModel {
+		return messageRequest, false
+	}
+
+	log.WithContext(ctx).Warnf("The content filter of the provider blocked the request to %s, retrying it with %s", messageRequest.Model, c.contentFilterModel)
+	messageRequest.Model = c.contentFilterModel
+	return messageRequest, true
+}
@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	var models []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		models = append(models, req.Model)
+
+		switch req.Model {
+		case "gpt-4o":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "content_filter", "message": "filtered", "innererror": {"code": "ResponsibleAIPolicyViolation", "content_filter_result": {"hate": {"filtered": false}, "violence": {"filtered": true, "severity": "medium"}}}}}`)
+		case "gpt-4o-mini":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Once upon\"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\",\"content_filter_results\":{\"self_harm\":{\"filtered\":true}}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"from \"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\""+req.Model+"\"},\"finish_reason\":\"stop\"}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+	defer s.Close()
+
+	call := func(c *Client, model string) (*types.CompletionMessage, []types.CompletionStatus) {
+		var statuses []types.CompletionStatus
+		status := make(chan types.CompletionStatus)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for s := range status {
+				if s.Response != nil {
+					statuses = append(statuses, s)
+				}
+			}
+		}()
+		resp, err := c.Call(context.Background(), types.CompletionRequest{
+			Model:    model,
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+		}, status)
+		close(status)
+		<-done
+		require.NoError(t, err)
+		return resp, statuses
+	}
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+	})
+	require.NoError(t, err)
+
+	// A rejected request is a filtered result instead of an error.
+	resp, statuses := call(c, "gpt-4o")
+	require.Equal(t, &types.ContentFilter{Filtered: true, Prompt: true, Categories: []string{"violence"}}, resp.ContentFilter)
+	require.Len(t, statuses, 1)
+	require.Equal(t, resp.ContentFilter, statuses[0].ContentFilter)
+
+	// A response that is cut off keeps what was generated.
+	resp, _ = call(c, "gpt-4o-mini")
+	require.Equal(t, &types.ContentFilter{Filtered: true, Categories: []string{"self_harm"}}, resp.ContentFilter)
+	require.Equal(t, "Once upon", resp.Content[0].Text)
+
+	// A filtered response is not cached, so the request is sent again.
+	models = nil
+	resp, _ = call(c, "gpt-4o-mini")
+	require.NotNil(t, resp.ContentFilter)
+	require.Equal(t, []string{"gpt-4o-mini"}, models)
+
+	c, err = NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:            s.URL,
+		APIKey:             "key",
+		MaxRetries:         -1,
+		ContentFilterModel: "llama3",
+	})
+	require.NoError(t, err)
+
+	models = nil
+	resp, statuses = call(c, "gpt-4o")
+	require.Nil(t, resp.ContentFilter)
+	require.Equal(t, "from llama3", resp.Content[0].Text)
+	require.Equal(t, []string{"gpt-4o", "llama3"}, models)
+	require.Len(t, statuses, 2)
+	require.NotNil(t, statuses[0].ContentFilter)
+}
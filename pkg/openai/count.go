@@ -2,19 +2,14 @@ package openai
 
 import openai "github.com/gptscript-ai/chat-completion-client"
 
-func dropMessagesOverCount(maxTokens int, msgs []openai.ChatCompletionMessage) (result []openai.ChatCompletionMessage) {
+// dropMessagesOverCount drops the oldest messages after the system messages so that the messages fit in the budget
+// of tokens.
+func dropMessagesOverCount(budget int, msgs []openai.ChatCompletionMessage) (result []openai.ChatCompletionMessage) {
 	var (
 		lastSystem   int
 		withinBudget int
-		budget       = maxTokens
 	)
 
-	if maxTokens == 0 {
-		budget = 300_000
-	} else {
-		budget *= 3
-	}
-
 	for i, msg := range msgs {
 		if msg.Role == openai.ChatMessageRoleSystem {
 			budget -= countMessage(msg)
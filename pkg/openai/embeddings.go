@@ -0,0 +1,129 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// embeddingsAPI calls the embeddings API of a provider.
+type embeddingsAPI struct {
+	url    string
+	orgID  string
+	client *http.Client
+}
+
+func (c *Client) embeddingKey(model, input string) any {
+	return map[string]any{
+		"embedding": c.cacheKeyBase,
+		"model":     model,
+		"input":     input,
+	}
+}
+
+// Embeddings returns the embeddings of the inputs, in the same order, from the embeddings API of the provider. The
+// model defaults to the embedding model of the options. Each embedding is cached, so that only the inputs that were
+// not embedded before are sent to the provider.
+func (c *Client) Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	if err := c.ValidAuth(); err != nil {
+		if err := c.RetrieveAPIKey(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if model == "" {
+		model = c.embeddingModel
+	}
+
+	var (
+		result  = make([][]float64, len(inputs))
+		missing []string
+		indexes = map[string][]int{}
+	)
+	for i, input := range inputs {
+		var embedding []float64
+		if ok, err := c.cache.Get(ctx, c.embeddingKey(model, input), &embedding); err != nil {
+			log.WithContext(ctx).Debugf("ignoring cached embedding: %v", err)
+		} else if ok && len(embedding) > 0 {
+			result[i] = embedding
+			continue
+		}
+		if _, ok := indexes[input]; !ok {
+			missing = append(missing, input)
+		}
+		indexes[input] = append(indexes[input], i)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	embeddings, err := c.requestEmbeddings(ctx, model, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, input := range missing {
+		for _, j := range indexes[input] {
+			result[j] = embeddings[i]
+		}
+		if err := c.cache.Store(ctx, c.embeddingKey(model, input), embeddings[i]); err != nil {
+			log.WithContext(ctx).Warnf("Failed to cache the embedding: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// requestEmbeddings sends the inputs to the embeddings API of the provider.
+func (c *Client) requestEmbeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.embeddings.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.embeddings.orgID != "" {
+		req.Header.Set("OpenAI-Organization", c.embeddings.orgID)
+	}
+
+	resp, err := c.embeddings.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings request to %s failed with status %d", c.embeddings.url, resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(inputs) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs from %s", len(result.Data), len(inputs), c.embeddings.url)
+	}
+
+	// The embeddings are in the order of the inputs.
+	embeddings := make([][]float64, len(inputs))
+	for i, data := range result.Data {
+		if len(data.Embedding) == 0 {
+			return nil, fmt.Errorf("no embedding for input %d in the response of %s", i, c.embeddings.url)
+		}
+		embeddings[i] = data.Embedding
+	}
+	return embeddings, nil
+}
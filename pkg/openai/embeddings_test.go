@@ -0,0 +1,92 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddings(t *testing.T) {
+	var requests [][]string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "/embeddings", r.URL.Path)
+		requests = append(requests, append([]string{req.Model}, req.Input...))
+
+		var data []any
+		for _, input := range req.Input {
+			data = append(data, map[string]any{"embedding": []float64{float64(len(input)), 1}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:        s.URL,
+		APIKey:         "test",
+		Cache:          cacheClient,
+		EmbeddingModel: "test-embedding",
+	})
+	require.NoError(t, err)
+
+	embeddings, err := c.Embeddings(context.Background(), "", []string{"a", "bb", "a"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{1, 1}, {2, 1}, {1, 1}}, embeddings)
+
+	// Only the inputs that were not embedded before are sent.
+	embeddings, err = c.Embeddings(context.Background(), "", []string{"ccc", "bb"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{3, 1}, {2, 1}}, embeddings)
+
+	_, err = c.Embeddings(context.Background(), "other-embedding", []string{"a"})
+	require.NoError(t, err)
+
+	_, err = c.Embeddings(cache.WithNoCache(context.Background()), "", []string{"a"})
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{
+		{"test-embedding", "a", "bb"},
+		{"test-embedding", "ccc"},
+		{"other-embedding", "a"},
+		{"test-embedding", "a"},
+	}, requests)
+}
+
+// keyStore has the OpenAI API key that the user entered before.
+type keyStore struct {
+	credentials.NoopStore
+}
+
+func (keyStore) Get(context.Context, string) (*credentials.Credential, bool, error) {
+	return &credentials.Credential{Env: map[string]string{"OPENAI_API_KEY": "stored"}}, true, nil
+}
+
+func TestEmbeddingsRetrieveAPIKey(t *testing.T) {
+	var authorization string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []any{map[string]any{"embedding": []float64{1}}}})
+	}))
+	defer s.Close()
+
+	// Like calls, embeddings use the stored API key when none is set.
+	c, err := NewClient(context.Background(), keyStore{}, Options{})
+	require.NoError(t, err)
+	c.embeddings.url = s.URL + "/embeddings"
+
+	_, err = c.Embeddings(context.Background(), "", []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer stored", authorization)
+}
@@ -0,0 +1,145 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const apiTypeAzure = "azure"
+
+// Fallback is a provider that requests for a family of models are sent to when the providers before it fail with an
+// authentication error, a server error, or a timeout.
+type Fallback struct {
+	// BaseURL is the URL of the OpenAI compatible API of the provider, or the endpoint of an Azure OpenAI resource.
+	BaseURL string `json:"baseURL,omitempty"`
+	// APIKeyEnv is the environment variable with the API key of the provider, so that it is not written in the file.
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+	// Model replaces the name of the model, such as with the name of an Azure OpenAI deployment.
+	Model string `json:"model,omitempty"`
+	// APIType is openai (the default) or azure.
+	APIType string `json:"apiType,omitempty"`
+	// APIVersion is the API version of an Azure OpenAI resource.
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+func (f Fallback) validate() error {
+	if f.BaseURL == "" {
+		return fmt.Errorf("baseURL of fallback is required")
+	}
+	switch f.APIType {
+	case "", "openai":
+	case apiTypeAzure:
+		if f.APIVersion == "" {
+			return fmt.Errorf("apiVersion of azure fallback %s is required", f.BaseURL)
+		}
+	default:
+		return fmt.Errorf("invalid apiType %q of fallback %s, must be openai or %s", f.APIType, f.BaseURL, apiTypeAzure)
+	}
+	return nil
+}
+
+// provider is a client that requests are sent to, either the one of the options or a fallback.
+type provider struct {
+	name   string
+	client *openai.Client
+	model  string
+}
+
+func newFallbackProvider(f Fallback, httpClient *http.Client) provider {
+	apiKey := os.Getenv(f.APIKeyEnv)
+
+	cfg := openai.DefaultConfig(apiKey)
+	if f.APIType == apiTypeAzure {
+		cfg = openai.DefaultAzureConfig(apiKey, f.BaseURL)
+		cfg.APIVersion = f.APIVersion
+	}
+	cfg.BaseURL = f.BaseURL
+	cfg.HTTPClient = httpClient
+
+	return provider{
+		name:   f.BaseURL,
+		client: openai.NewClientWithConfig(cfg),
+		model:  f.Model,
+	}
+}
+
+// newFallbacks returns the fallback providers of each adaptation, in the same order.
+func newFallbacks(adaptations []ModelAdaptation, httpClient *http.Client) (result [][]provider) {
+	for _, adaptation := range adaptations {
+		var providers []provider
+		for _, fallback := range adaptation.Fallbacks {
+			providers = append(providers, newFallbackProvider(fallback, httpClient))
+		}
+		result = append(result, providers)
+	}
+	return
+}
+
+// providers returns the providers that a request for the model is sent to, in order. Like the rest of an adaptation,
+// the fallbacks of the first adaptation that matches the model are used.
+func (c *Client) providers(model string) []provider {
+	primary := provider{
+		name:   c.baseURL,
+		client: c.c,
+	}
+	for i, adaptation := range c.adaptations {
+		if adaptation.matches(model) {
+			return append([]provider{primary}, c.fallbacks[i]...)
+		}
+	}
+	return []provider{primary}
+}
+
+func (p provider) request(request openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	if p.model != "" {
+		request.Model = p.model
+	}
+	return request
+}
+
+// callWithFailover sends the request to each provider of the model in turn, until one does not fail with an error
+// that another provider may not have.
+func (c *Client) callWithFailover(ctx context.Context, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus) (responses []openai.ChatCompletionStreamResponse, timing *types.Timing, err error) {
+	providers := c.providers(request.Model)
+	for i, p := range providers {
+		responses, timing, err = c.call(ctx, p, request, transactionID, partial)
+		if err == nil || i == len(providers)-1 || !failover(ctx, err) {
+			return responses, timing, err
+		}
+		log.WithContext(ctx).Warnf("The request to %s failed, sending it to %s instead: %v", p.name, providers[i+1].name, err)
+	}
+	return
+}
+
+// failover returns true for errors that are specific to a provider: authentication errors, server errors, and
+// timeouts. A canceled call is not sent to another provider.
+func failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var (
+		apiErr     *openai.APIError
+		requestErr *openai.RequestError
+		netErr     net.Error
+		status     int
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	return status == http.StatusUnauthorized || status == http.StatusForbidden || status >= http.StatusInternalServerError
+}
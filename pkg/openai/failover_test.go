@@ -0,0 +1,92 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailover(t *testing.T) {
+	primaryStatus := http.StatusServiceUnavailable
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(primaryStatus)
+		_, _ = fmt.Fprint(w, `{"error": {"message": "unavailable"}}`)
+	}))
+	defer primary.Close()
+
+	var models []string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "Bearer fallback-key", r.Header.Get("Authorization"))
+		models = append(models, req.Model)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"from fallback\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer fallback.Close()
+
+	t.Setenv("TEST_FALLBACK_KEY", "fallback-key")
+	modelsFile := filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(modelsFile, []byte(fmt.Sprintf(`models:
+- match: ["gpt-4o*"]
+  fallbacks:
+  - baseURL: %s
+    apiKeyEnv: TEST_FALLBACK_KEY
+    model: gpt-4o-deployment
+`, fallback.URL)), 0644))
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    primary.URL,
+		APIKey:     "primary-key",
+		ModelsFile: modelsFile,
+		MaxRetries: -1,
+	})
+	require.NoError(t, err)
+
+	call := func(model string) (*types.CompletionMessage, error) {
+		status := make(chan types.CompletionStatus)
+		go func() {
+			for range status {
+			}
+		}()
+		defer close(status)
+		return c.Call(context.Background(), types.CompletionRequest{
+			Model:    model,
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+		}, status)
+	}
+
+	resp, err := call("gpt-4o")
+	require.NoError(t, err)
+	require.Equal(t, "from fallback", resp.Content[0].Text)
+	require.Equal(t, []string{"gpt-4o-deployment"}, models)
+
+	// Models without fallbacks fail with the error of the provider.
+	_, err = call("o1-mini")
+	require.ErrorContains(t, err, "unavailable")
+
+	// Errors of the request itself are not sent to another provider.
+	primaryStatus = http.StatusBadRequest
+	_, err = call("gpt-4o")
+	require.ErrorContains(t, err, "unavailable")
+	require.Len(t, models, 1)
+}
+
+func TestFallbackValidation(t *testing.T) {
+	require.NoError(t, Fallback{BaseURL: "https://example.openai.azure.com", APIType: "azure", APIVersion: "2024-06-01"}.validate())
+	require.Error(t, Fallback{BaseURL: "https://example.openai.azure.com", APIType: "azure"}.validate())
+	require.Error(t, Fallback{BaseURL: "https://example.com", APIType: "bedrock"}.validate())
+	require.Error(t, Fallback{}.validate())
+}
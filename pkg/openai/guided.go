@@ -0,0 +1,152 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	guidedDecodingVLLM     = "vllm"
+	guidedDecodingLlamaCPP = "llamacpp"
+)
+
+// guidedDecoding returns the fields added to the body of the request to constrain the response to the output
+// schema, regex, or grammar of the tool. Constraints are only sent when no tools are offered, because a constrained
+// response can not call a tool.
+func (m ModelAdaptation) guidedDecoding(messageRequest types.CompletionRequest) map[string]any {
+	if m.GuidedDecoding == "" || len(messageRequest.Tools) > 0 {
+		return nil
+	}
+
+	result := map[string]any{}
+	switch m.GuidedDecoding {
+	case guidedDecodingVLLM:
+		if len(messageRequest.OutputSchema) > 0 {
+			result["guided_json"] = messageRequest.OutputSchema
+		}
+		if messageRequest.OutputRegex != "" {
+			result["guided_regex"] = messageRequest.OutputRegex
+		}
+		if messageRequest.OutputGrammar != "" {
+			result["guided_grammar"] = messageRequest.OutputGrammar
+		}
+	case guidedDecodingLlamaCPP:
+		if len(messageRequest.OutputSchema) > 0 {
+			result["json_schema"] = messageRequest.OutputSchema
+		}
+		if messageRequest.OutputGrammar != "" {
+			result["grammar"] = messageRequest.OutputGrammar
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// guidedDecodingFields returns the guided decoding fields of the first adaptation that matches the model.
+func guidedDecodingFields(adaptations []ModelAdaptation, messageRequest types.CompletionRequest, model string) map[string]any {
+	for _, adaptation := range adaptations {
+		if adaptation.matches(model) {
+			return adaptation.guidedDecoding(messageRequest)
+		}
+	}
+	return nil
+}
+
+// responseFormatFields adds a response_format with the output schema of a JSON response to the fields, so that
+// models that support structured outputs respond with JSON that matches it. The chat completion client has no
+// json_schema response format, so it replaces the json_object one in the body of the request.
+func responseFormatFields(fields map[string]any, messageRequest types.CompletionRequest) map[string]any {
+	if !messageRequest.JSONResponse || len(messageRequest.OutputSchema) == 0 {
+		return fields
+	}
+
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["response_format"] = map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "response",
+			"schema": messageRequest.OutputSchema,
+		},
+	}
+	return fields
+}
+
+// parallelToolCallsFields adds parallel_tool_calls to the fields when a request that offers tools disables them, which
+// the chat completion client has no field for either. Providers reject it for requests without tools.
+func parallelToolCallsFields(fields map[string]any, messageRequest types.CompletionRequest) map[string]any {
+	if messageRequest.ParallelToolCalls == nil || *messageRequest.ParallelToolCalls || len(messageRequest.Tools) == 0 {
+		return fields
+	}
+
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["parallel_tool_calls"] = false
+	return fields
+}
+
+type bodyKey struct{}
+
+func withBodyFields(ctx context.Context, fields map[string]any) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, bodyKey{}, fields)
+}
+
+func bodyFields(ctx context.Context) map[string]any {
+	fields, _ := ctx.Value(bodyKey{}).(map[string]any)
+	return fields
+}
+
+// bodyTransport adds any fields stored in the request context to the JSON body of the request, and marks its prompt
+// for the prompt cache when the context asks for it. The chat completion client has no way to send fields that are not
+// part of the OpenAI API, such as those used for guided decoding.
+type bodyTransport struct {
+	base http.RoundTripper
+}
+
+func (b *bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fields, cached := bodyFields(req.Context()), cacheControl(req.Context())
+	if len(fields) == 0 && !cached || req.Body == nil {
+		return b.base.RoundTrip(req)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to add fields to request body: %w", err)
+	}
+	for k, v := range fields {
+		body[k] = v
+	}
+	if cached {
+		markCacheControl(body)
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return b.base.RoundTrip(req)
+}
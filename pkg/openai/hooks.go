@@ -0,0 +1,67 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// ClientHooks are callbacks invoked around every chat completion request made by a Client. They are intended for
+// embedders that need to audit calls, account for tokens, or route requests through a gateway.
+type ClientHooks struct {
+	// BeforeRequest is called before the request is sent to the model. The request may be mutated and headers
+	// may be added to the outgoing HTTP request. Returning an error vetoes the call and the error is returned
+	// to the caller.
+	BeforeRequest func(ctx context.Context, request *openai.ChatCompletionRequest, header http.Header) error
+	// AfterResponse is called with the final response, including responses served from the cache. Returning an
+	// error fails the call.
+	AfterResponse func(ctx context.Context, request openai.ChatCompletionRequest, response *types.CompletionMessage, cached bool) error
+}
+
+func (h *ClientHooks) beforeRequest(ctx context.Context, request *openai.ChatCompletionRequest) (context.Context, error) {
+	if h == nil || h.BeforeRequest == nil {
+		return ctx, nil
+	}
+
+	header := http.Header{}
+	if err := h.BeforeRequest(ctx, request, header); err != nil {
+		return ctx, err
+	}
+
+	if len(header) == 0 {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, headerKey{}, header), nil
+}
+
+func (h *ClientHooks) afterResponse(ctx context.Context, request openai.ChatCompletionRequest, response *types.CompletionMessage, cached bool) error {
+	if h == nil || h.AfterResponse == nil {
+		return nil
+	}
+	return h.AfterResponse(ctx, request, response, cached)
+}
+
+type headerKey struct{}
+
+// headerTransport adds the extra headers of the options, and any headers stored in the request context by the
+// BeforeRequest hook, which take precedence.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header, _ := req.Context().Value(headerKey{}).(http.Header)
+	if len(h.header) > 0 || len(header) > 0 {
+		req = req.Clone(req.Context())
+		for k, v := range h.header {
+			req.Header[k] = v
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+	}
+	return h.base.RoundTrip(req)
+}
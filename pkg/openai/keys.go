@@ -0,0 +1,139 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultKeyCooldown = time.Minute
+
+// keyPool is the API keys of a provider, which requests take in turn. A key that is rate limited or rejected is parked
+// until its cooldown ends, unless every key is parked.
+type keyPool struct {
+	lock     sync.Mutex
+	keys     []string
+	parked   []time.Time
+	next     int
+	cooldown time.Duration
+	now      func() time.Time
+}
+
+// newKeyPool returns the pool of the comma separated API keys, or nil when there is only one key.
+func newKeyPool(apiKeys, cooldown string) (*keyPool, error) {
+	var keys []string
+	for _, key := range strings.Split(apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) < 2 {
+		return nil, nil
+	}
+
+	d := defaultKeyCooldown
+	if cooldown != "" {
+		var err error
+		if d, err = time.ParseDuration(cooldown); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid API key cooldown %q", cooldown)
+		}
+	}
+
+	return &keyPool{
+		keys:     keys,
+		parked:   make([]time.Time, len(keys)),
+		cooldown: d,
+		now:      time.Now,
+	}, nil
+}
+
+// get returns the index of the next key that isn't parked, or of the key whose cooldown ends first when they all are.
+func (p *keyPool) get() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	soonest := -1
+	for n := range p.keys {
+		i := (p.next + n) % len(p.keys)
+		if !p.parked[i].After(now) {
+			p.next = i + 1
+			return i
+		}
+		if soonest < 0 || p.parked[i].Before(p.parked[soonest]) {
+			soonest = i
+		}
+	}
+	p.next = soonest + 1
+	return soonest
+}
+
+// park skips the key until its cooldown ends, or for as long as the provider asked to wait if that is longer. It
+// returns whether another key can be used in the meantime.
+func (p *keyPool) park(i int, wait time.Duration) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	p.parked[i] = now.Add(max(p.cooldown, wait))
+	for j := range p.keys {
+		if !p.parked[j].After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyTransport sends each request with the next key of the pool. Requests that are rate limited or rejected are sent
+// again right away with another key, when one isn't parked, and otherwise left to the retries. Only the requests that
+// carry the keys of the options are changed, so that fallbacks keep their own keys.
+type keyTransport struct {
+	base http.RoundTripper
+	pool *keyPool
+	// authorization is the Authorization header that the client sends for the comma separated keys of the options.
+	authorization string
+}
+
+func (k *keyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != k.authorization {
+		return k.base.RoundTrip(req)
+	}
+
+	// The body is read once so that it can be sent again.
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		i := k.pool.get()
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+k.pool.keys[i])
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := k.base.RoundTrip(req)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusUnauthorized) {
+			return resp, err
+		}
+
+		wait := max(retryAfter(resp.Header), 0)
+		log.WithContext(req.Context()).Infof("API key %d of %s failed with status %d, skipping it for %s", i+1, req.URL.Host, resp.StatusCode,
+			max(k.pool.cooldown, wait).Round(time.Millisecond))
+		if !k.pool.park(i, wait) || attempt+1 >= len(k.pool.keys) {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyTransport(t *testing.T) {
+	var (
+		keys    []string
+		bodies  []string
+		limited = map[string]bool{"Bearer key-2": true}
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get("Authorization"))
+		bodies = append(bodies, string(data))
+		if limited[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer s.Close()
+
+	pool, err := newKeyPool("key-1, key-2,key-3", "")
+	require.NoError(t, err)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	client := &http.Client{Transport: &keyTransport{base: http.DefaultTransport, pool: pool, authorization: "Bearer key-1, key-2,key-3"}}
+	post := func(authorization string) int {
+		req, err := http.NewRequest(http.MethodPost, s.URL, strings.NewReader(`{"model":"gpt-4o"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The rate limited key is sent again right away with the next key, and skipped until its cooldown ends.
+	for range 4 {
+		require.Equal(t, http.StatusOK, post("Bearer key-1, key-2,key-3"))
+	}
+	require.Equal(t, []string{"Bearer key-1", "Bearer key-2", "Bearer key-3", "Bearer key-1", "Bearer key-3"}, keys)
+	require.Equal(t, `{"model":"gpt-4o"}`, bodies[2])
+
+	keys = nil
+	now = now.Add(defaultKeyCooldown)
+	limited = map[string]bool{}
+	require.Equal(t, http.StatusOK, post("Bearer key-1, key-2,key-3"))
+	require.Equal(t, http.StatusOK, post("Bearer key-1, key-2,key-3"))
+	require.Equal(t, []string{"Bearer key-1", "Bearer key-2"}, keys)
+
+	// When every key is limited the response is returned for the retries to handle.
+	keys = nil
+	limited = map[string]bool{"Bearer key-1": true, "Bearer key-2": true, "Bearer key-3": true}
+	require.Equal(t, http.StatusTooManyRequests, post("Bearer key-1, key-2,key-3"))
+	require.Equal(t, []string{"Bearer key-3", "Bearer key-1", "Bearer key-2"}, keys)
+
+	// Requests with other keys, such as those of fallbacks, are sent as they are.
+	keys = nil
+	require.Equal(t, http.StatusOK, post("Bearer fallback"))
+	require.Equal(t, []string{"Bearer fallback"}, keys)
+}
+
+func TestNewKeyPool(t *testing.T) {
+	pool, err := newKeyPool("sk-only", "")
+	require.NoError(t, err)
+	require.Nil(t, pool)
+
+	_, err = newKeyPool("key-1,key-2", "soon")
+	require.ErrorContains(t, err, "invalid API key cooldown")
+}
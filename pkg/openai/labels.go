@@ -0,0 +1,27 @@
+package openai
+
+import (
+	"context"
+	"maps"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+)
+
+// withLabelFields adds the labels of the run to the body of the request as metadata, along with a label named user as
+// the user field. The fields are added after the cache lookup so that labels do not change the cache key.
+func withLabelFields(ctx context.Context) context.Context {
+	labels := gcontext.GetLabels(ctx)
+	if len(labels) == 0 {
+		return ctx
+	}
+
+	fields := maps.Clone(bodyFields(ctx))
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["metadata"] = labels
+	if user, ok := labels["user"]; ok {
+		fields["user"] = user
+	}
+	return withBodyFields(ctx, fields)
+}
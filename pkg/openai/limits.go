@@ -0,0 +1,145 @@
+package openai
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+)
+
+// ModelLimits are the sizes of the context window of a model and of its responses, in tokens. Zero is unknown.
+type ModelLimits struct {
+	ContextWindow   int `json:"contextWindow,omitempty"`
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
+
+// builtinModelLimits are the limits of well known models. The first entry that matches the lower case model name is
+// used, so more specific patterns come first.
+var builtinModelLimits = []struct {
+	match  []string
+	limits ModelLimits
+}{
+	{[]string{"gpt-4o-2024-05-13"}, ModelLimits{128_000, 4_096}},
+	{[]string{"gpt-4o*", "chatgpt-4o*"}, ModelLimits{128_000, 16_384}},
+	{[]string{"gpt-4.1*"}, ModelLimits{1_047_576, 32_768}},
+	{[]string{"gpt-4-turbo*", "gpt-4-*-preview"}, ModelLimits{128_000, 4_096}},
+	{[]string{"gpt-4-32k*"}, ModelLimits{32_768, 0}},
+	{[]string{"gpt-4", "gpt-4-0613", "gpt-4-0314"}, ModelLimits{8_192, 0}},
+	{[]string{"gpt-3.5-turbo*"}, ModelLimits{16_385, 4_096}},
+	{[]string{"o1-mini*"}, ModelLimits{128_000, 65_536}},
+	{[]string{"o1-preview*"}, ModelLimits{128_000, 32_768}},
+	{[]string{"o1*", "o3*", "o4-mini*"}, ModelLimits{200_000, 100_000}},
+	{[]string{"claude-3-5-*", "claude-3.5-*"}, ModelLimits{200_000, 8_192}},
+	{[]string{"claude-3-*"}, ModelLimits{200_000, 4_096}},
+	{[]string{"claude-*"}, ModelLimits{200_000, 0}},
+	{[]string{"gemini-1.5-pro*"}, ModelLimits{2_097_152, 8_192}},
+	{[]string{"gemini-1.5-*", "gemini-2*"}, ModelLimits{1_048_576, 8_192}},
+	{[]string{"*llama-3.1*", "*llama3.1*", "*llama-3.2*", "*llama3.2*", "*llama-3.3*", "*llama3.3*"}, ModelLimits{131_072, 0}},
+	{[]string{"*llama-3*", "*llama3*"}, ModelLimits{8_192, 0}},
+	{[]string{"mistral-large*", "mistral-small*"}, ModelLimits{128_000, 0}},
+	{[]string{"deepseek-*"}, ModelLimits{64_000, 8_192}},
+}
+
+// contextSizeInName matches the size of the context window that many model names include, such as gpt-4-32k.
+var contextSizeInName = regexp.MustCompile(`(?:^|[-_:.])(\d+)k(?:$|[-_:.])`)
+
+// modelLimits returns the limits of a model. The limits set in the models file are used first, then the size of the
+// context window in the name of the model, and then the limits of well known models.
+func modelLimits(adaptations []ModelAdaptation, model string) (result ModelLimits) {
+	model = strings.ToLower(model)
+
+builtin:
+	for _, entry := range builtinModelLimits {
+		for _, pattern := range entry.match {
+			if ok, _ := path.Match(pattern, model); ok {
+				result = entry.limits
+				break builtin
+			}
+		}
+	}
+
+	if match := contextSizeInName.FindStringSubmatch(model); match != nil {
+		if size, err := strconv.Atoi(match[1]); err == nil && size > 0 {
+			result.ContextWindow = size * 1024
+		}
+	}
+
+	for _, adaptation := range adaptations {
+		if adaptation.matches(model) {
+			if adaptation.ContextWindow != 0 {
+				result.ContextWindow = adaptation.ContextWindow
+			}
+			if adaptation.MaxOutputTokens != 0 {
+				result.MaxOutputTokens = adaptation.MaxOutputTokens
+			}
+			break
+		}
+	}
+
+	return result
+}
+
+// outputTokens returns the number of tokens reserved for the response to a request with the given max tokens.
+func (l ModelLimits) outputTokens(maxTokens int) int {
+	if maxTokens != 0 {
+		return maxTokens
+	}
+	if l.MaxOutputTokens != 0 {
+		return min(l.MaxOutputTokens, l.ContextWindow/4)
+	}
+	return min(4_096, l.ContextWindow/4)
+}
+
+// inputBudget returns the number of tokens that the messages of a request can use.
+func (l ModelLimits) inputBudget(maxTokens int) int {
+	if l.ContextWindow == 0 {
+		// Without a known context window, the messages are allowed a multiple of the response.
+		if maxTokens == 0 {
+			return 300_000
+		}
+		return maxTokens * 3
+	}
+	return l.ContextWindow - l.outputTokens(maxTokens)
+}
+
+// maxTokens returns the max tokens to send with a request whose messages and tools are about promptTokens long. The
+// max tokens of a tool are lowered to what the model can generate, and to what is left of the context window after
+// the prompt, so that the request is not rejected by the provider. Otherwise, the provider's default is kept.
+func (l ModelLimits) maxTokens(maxTokens, promptTokens int) int {
+	limit := l.MaxOutputTokens
+	if l.ContextWindow != 0 && promptTokens < l.ContextWindow {
+		remaining := l.ContextWindow - promptTokens
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	if limit == 0 {
+		return maxTokens
+	}
+	if maxTokens == 0 && l.MaxOutputTokens != 0 && limit < l.MaxOutputTokens {
+		return limit
+	}
+	if maxTokens > limit {
+		return limit
+	}
+	return maxTokens
+}
+
+// countRequest estimates the number of tokens of the messages and tool definitions of a request.
+func countRequest(request openai.ChatCompletionRequest) (count int) {
+	for _, msg := range request.Messages {
+		count += countMessage(msg)
+	}
+	for _, tool := range request.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		params, _ := json.Marshal(tool.Function.Parameters)
+		count += (len(tool.Function.Name) + len(tool.Function.Description) + len(params)) / 3
+	}
+	return count
+}
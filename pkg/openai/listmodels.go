@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"context"
+	"time"
+)
+
+const defaultModelsTTL = time.Hour
+
+// modelList is the list of models of a provider that is cached, and when it was listed.
+type modelList struct {
+	Models []string
+	Listed time.Time
+}
+
+func (c *Client) modelsKey() any {
+	return map[string]any{
+		"models": c.cacheKeyBase,
+	}
+}
+
+// cachedModels returns the cached list of models of the provider, unless it is older than the TTL or the models are
+// refreshed.
+func (c *Client) cachedModels(ctx context.Context) ([]string, bool, error) {
+	if c.modelsTTL <= 0 || c.refreshModels {
+		return nil, false, nil
+	}
+
+	var list modelList
+	if ok, err := c.cache.Get(ctx, c.modelsKey(), &list); err != nil || !ok {
+		return nil, false, err
+	}
+	if age := time.Since(list.Listed); age < 0 || age >= c.modelsTTL || len(list.Models) == 0 {
+		return nil, false, nil
+	}
+	return list.Models, true, nil
+}
+
+// storeModels caches the list of models of the provider. An empty list is not cached, because it means that the
+// credentials of the provider are not valid.
+func (c *Client) storeModels(ctx context.Context, models []string) error {
+	if c.modelsTTL <= 0 || len(models) == 0 {
+		return nil
+	}
+	return c.cache.Store(ctx, c.modelsKey(), modelList{
+		Models: models,
+		Listed: time.Now(),
+	})
+}
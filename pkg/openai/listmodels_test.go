@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListModelsCache(t *testing.T) {
+	var lists int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		lists++
+		_, _ = w.Write([]byte(`{"data": [{"id": "gpt-4o"}, {"id": "gpt-4o-mini"}]}`))
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	newClient := func(opts Options) *Client {
+		opts.APIKey, opts.BaseURL, opts.Cache = "test", s.URL, cacheClient
+		c, err := NewClient(context.Background(), credentials.NoopStore{}, opts)
+		require.NoError(t, err)
+		return c
+	}
+
+	// The list is shared by the clients of the provider until it expires.
+	for range 3 {
+		ok, err := newClient(Options{}).Supports(context.Background(), "gpt-4o-mini")
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	require.Equal(t, 1, lists)
+
+	models, err := newClient(Options{RefreshModels: true}).ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, models)
+	require.Equal(t, 2, lists)
+
+	c := newClient(Options{ModelsCacheTTL: "1m"})
+	require.NoError(t, c.cache.Store(context.Background(), c.modelsKey(), modelList{
+		Models: []string{"gpt-4o"},
+		Listed: time.Now().Add(-time.Hour),
+	}))
+	_, err = c.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, lists)
+
+	_, err = newClient(Options{ModelsCacheTTL: "0"}).ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4, lists)
+
+	_, err = NewClient(context.Background(), credentials.NoopStore{}, Options{APIKey: "test", ModelsCacheTTL: "soon"})
+	require.ErrorContains(t, err, "invalid models cache TTL")
+}
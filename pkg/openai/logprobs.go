@@ -0,0 +1,196 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type logProbsKey struct{}
+
+// logProbs collects the log probabilities of the tokens of a response per choice. The chat completion client drops
+// them from streamed responses, so those are read from the body of the response by logProbsTransport.
+type logProbs struct {
+	lock    sync.Mutex
+	choices map[int][]types.TokenLogProb
+}
+
+// withLogProbs collects the log probabilities of the response to the request of the context, when it asks for them.
+func withLogProbs(ctx context.Context, enabled bool) context.Context {
+	if !enabled {
+		return ctx
+	}
+	return context.WithValue(ctx, logProbsKey{}, &logProbs{})
+}
+
+func getLogProbs(ctx context.Context) *logProbs {
+	l, _ := ctx.Value(logProbsKey{}).(*logProbs)
+	return l
+}
+
+// reset forgets the log probabilities of a response that was retried or failed over.
+func (l *logProbs) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.choices = nil
+}
+
+func (l *logProbs) add(index int, probs *openai.LogProbs) {
+	if probs == nil || len(probs.Content) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.choices == nil {
+		l.choices = map[int][]types.TokenLogProb{}
+	}
+	for _, p := range probs.Content {
+		token := types.TokenLogProb{
+			Token:   p.Token,
+			LogProb: p.LogProb,
+		}
+		for _, top := range p.TopLogProbs {
+			token.TopLogProbs = append(token.TopLogProbs, types.TopTokenProb{
+				Token:   top.Token,
+				LogProb: top.LogProb,
+			})
+		}
+		l.choices[index] = append(l.choices[index], token)
+	}
+}
+
+// result returns the log probabilities in the order of the choices, or nil if there are none.
+func (l *logProbs) result() [][]types.TokenLogProb {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.choices) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(l.choices))
+	for i := range l.choices {
+		indexes = append(indexes, i)
+	}
+	slices.Sort(indexes)
+
+	result := make([][]types.TokenLogProb, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, l.choices[i])
+	}
+	return result
+}
+
+// logProbsChunk is the part of a response, or of a chunk of a streamed response, with the log probabilities.
+type logProbsChunk struct {
+	Choices []struct {
+		Index    int              `json:"index"`
+		LogProbs *openai.LogProbs `json:"logprobs"`
+	} `json:"choices"`
+}
+
+// addResponse adds the log probabilities of a response that was not streamed.
+func (l *logProbs) addResponse(resp openai.ChatCompletionResponse) {
+	l.reset()
+	for _, choice := range resp.Choices {
+		l.add(choice.Index, choice.LogProbs)
+	}
+}
+
+func (l *logProbs) addChunk(data []byte) {
+	var chunk logProbsChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+	for _, choice := range chunk.Choices {
+		l.add(choice.Index, choice.LogProbs)
+	}
+}
+
+func (c *Client) logProbsKey(ctx context.Context, request openai.ChatCompletionRequest) any {
+	return map[string]any{"logprobs": c.cacheKey(ctx, request)}
+}
+
+// storeLogProbs caches the log probabilities of a response that was generated for the request, next to the response,
+// because the cached response can't carry them.
+func (c *Client) storeLogProbs(ctx context.Context, request openai.ChatCompletionRequest) {
+	l := getLogProbs(ctx)
+	if l == nil {
+		return
+	}
+	if result := l.result(); len(result) > 0 {
+		if err := c.cache.Store(ctx, c.logProbsKey(ctx, request), result); err != nil {
+			log.WithContext(ctx).Warnf("Failed to cache the log probabilities of the response: %v", err)
+		}
+	}
+}
+
+// responseLogProbs returns the log probabilities of the response to a request that asked for them, which are those
+// that were collected for a response that was generated for it, or those that were cached for a cached or shared one.
+func (c *Client) responseLogProbs(ctx context.Context, request openai.ChatCompletionRequest) [][]types.TokenLogProb {
+	l := getLogProbs(ctx)
+	if l == nil {
+		return nil
+	}
+	if result := l.result(); len(result) > 0 {
+		return result
+	}
+
+	var result [][]types.TokenLogProb
+	if ok, err := c.cache.Get(ctx, c.logProbsKey(ctx, request), &result); err != nil || !ok {
+		return nil
+	}
+	return result
+}
+
+// logProbsTransport reads the log probabilities from the body of the responses to requests that collect them.
+type logProbsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *logProbsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := getLogProbs(req.Context())
+	resp, err := t.base.RoundTrip(req)
+	if l == nil || err != nil || resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, err
+	}
+
+	l.reset()
+	resp.Body = &logProbsReader{
+		ReadCloser: resp.Body,
+		probs:      l,
+	}
+	return resp, nil
+}
+
+// logProbsReader collects the log probabilities of the chunks of a streamed response as they are read.
+type logProbsReader struct {
+	io.ReadCloser
+	probs *logProbs
+	buf   bytes.Buffer
+}
+
+func (r *logProbsReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+
+	for {
+		i := bytes.IndexByte(r.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		if data, ok := bytes.CutPrefix(bytes.TrimSpace(r.buf.Next(i+1)), []byte("data:")); ok {
+			r.probs.addChunk(bytes.TrimSpace(data))
+		}
+	}
+	return n, err
+}
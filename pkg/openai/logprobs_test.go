@@ -0,0 +1,78 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogProbs(t *testing.T) {
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, true, req["logprobs"])
+		require.Equal(t, float64(2), req["top_logprobs"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"yes", "!"} {
+			_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%q},\"logprobs\":{\"content\":[{\"token\":%[1]q,\"logprob\":-0.5,\"top_logprobs\":[{\"token\":%[1]q,\"logprob\":-0.5},{\"token\":\"no\",\"logprob\":-1}]}]}}]}\n\n", token)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+	})
+	require.NoError(t, err)
+
+	call := func() [][]types.TokenLogProb {
+		var logProbs [][]types.TokenLogProb
+		status := make(chan types.CompletionStatus)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for s := range status {
+				if s.Response != nil {
+					logProbs = s.LogProbs
+				}
+			}
+		}()
+		resp, err := c.Call(context.Background(), types.CompletionRequest{
+			Model:       "gpt-4o",
+			TopLogProbs: 2,
+			Messages:    []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+		}, status)
+		close(status)
+		<-done
+		require.NoError(t, err)
+		require.Equal(t, "yes!", resp.Content[0].Text)
+		return logProbs
+	}
+
+	expected := [][]types.TokenLogProb{{
+		{Token: "yes", LogProb: -0.5, TopLogProbs: []types.TopTokenProb{{Token: "yes", LogProb: -0.5}, {Token: "no", LogProb: -1}}},
+		{Token: "!", LogProb: -0.5, TopLogProbs: []types.TopTokenProb{{Token: "!", LogProb: -0.5}, {Token: "no", LogProb: -1}}},
+	}}
+	require.Equal(t, expected, call())
+
+	// The log probabilities are cached with the response.
+	require.Equal(t, expected, call())
+	require.Equal(t, int32(1), requests.Load())
+}
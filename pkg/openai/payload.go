@@ -0,0 +1,216 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/cassette"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultRedactions are the patterns of the secrets that are always redacted from recorded payloads, such as bearer
+// tokens and the API keys of well known providers.
+var defaultRedactions = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9._~+/=-]+`),
+	regexp.MustCompile(`\b(sk|pk|rk)-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`),
+}
+
+// payloadSampler picks the completions whose requests and responses are recorded as they were sent over HTTP, and
+// redacts the secrets in them.
+type payloadSampler struct {
+	// rate is the percentage of completions that are recorded.
+	rate    float64
+	redact  []*regexp.Regexp
+	secrets []string
+}
+
+// newPayloadSampler returns nil, so that no payload is recorded, when the rate is empty or 0. The API keys of the
+// client are redacted from payloads, besides what matches the default redactions and the redact patterns.
+func newPayloadSampler(rate string, redact []string, apiKeys string) (*payloadSampler, error) {
+	if rate == "" {
+		return nil, nil
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 || r > 100 {
+		return nil, fmt.Errorf("invalid payload sample rate %q, must be a percentage from 0 to 100", rate)
+	} else if r == 0 {
+		return nil, nil
+	}
+
+	sampler := &payloadSampler{
+		rate:   r,
+		redact: defaultRedactions,
+	}
+	for _, pattern := range redact {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload redaction %q: %w", pattern, err)
+		}
+		sampler.redact = append(sampler.redact, re)
+	}
+	for _, key := range strings.Split(apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			sampler.secrets = append(sampler.secrets, key)
+		}
+	}
+	return sampler, nil
+}
+
+func (p *payloadSampler) sample() bool {
+	return p != nil && rand.Float64()*100 < p.rate
+}
+
+func (p *payloadSampler) redactText(s string) string {
+	for _, secret := range p.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, re := range p.redact {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+func (p *payloadSampler) redactHeader(header http.Header) map[string][]string {
+	result := cassette.Scrub(header)
+	for key, values := range result {
+		for i, v := range values {
+			values[i] = p.redactText(v)
+		}
+		result[key] = values
+	}
+	return result
+}
+
+type payloadKey struct{}
+
+// payloadRecorder has the request and response of the last attempt of a completion that was sampled.
+type payloadRecorder struct {
+	lock         sync.Mutex
+	sampler      *payloadSampler
+	req          *http.Request
+	requestBody  []byte
+	resp         *http.Response
+	responseBody []byte
+}
+
+// withPayload records the payload of the completion of the context if the sampler picks it.
+func withPayload(ctx context.Context, sampler *payloadSampler) context.Context {
+	if !sampler.sample() {
+		return ctx
+	}
+	return context.WithValue(ctx, payloadKey{}, &payloadRecorder{sampler: sampler})
+}
+
+func getPayload(ctx context.Context) *payloadRecorder {
+	r, _ := ctx.Value(payloadKey{}).(*payloadRecorder)
+	return r
+}
+
+func (r *payloadRecorder) setRequest(req *http.Request, body []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.req, r.requestBody, r.resp, r.responseBody = req, body, nil, nil
+}
+
+func (r *payloadRecorder) setResponse(resp *http.Response, body []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.resp, r.responseBody = resp, body
+}
+
+// result returns the redacted payload, or nil if the completion was not sampled or got no response.
+func (r *payloadRecorder) result() *types.CompletionPayload {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.req == nil || r.resp == nil {
+		return nil
+	}
+
+	return &types.CompletionPayload{
+		Request: types.PayloadRequest{
+			Method: r.req.Method,
+			URL:    r.sampler.redactText(cassette.ScrubURL(r.req.URL)),
+			Header: r.sampler.redactHeader(r.req.Header),
+			Body:   r.sampler.redactText(string(r.requestBody)),
+		},
+		Response: types.PayloadResponse{
+			StatusCode: r.resp.StatusCode,
+			Header:     r.sampler.redactHeader(r.resp.Header),
+			Body:       r.sampler.redactText(string(r.responseBody)),
+		},
+	}
+}
+
+// payloadTransport records the requests of completions that were sampled and their responses.
+type payloadTransport struct {
+	base http.RoundTripper
+}
+
+func (t *payloadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := getPayload(req.Context())
+	if rec == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	rec.setRequest(req, body)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	rec.setResponse(resp, nil)
+	resp.Body = &payloadReader{
+		ReadCloser: resp.Body,
+		rec:        rec,
+		resp:       resp,
+	}
+	return resp, nil
+}
+
+// payloadReader records the body of a response as it is read.
+type payloadReader struct {
+	io.ReadCloser
+	rec  *payloadRecorder
+	resp *http.Response
+	buf  bytes.Buffer
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+	if err != nil {
+		r.rec.setResponse(r.resp, r.buf.Bytes())
+	}
+	return n, err
+}
+
+func (r *payloadReader) Close() error {
+	r.rec.setResponse(r.resp, r.buf.Bytes())
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadSampling(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"the password is hunter2\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer s.Close()
+
+	call := func(rate string) *types.CompletionPayload {
+		c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+			BaseURL:           s.URL,
+			APIKey:            "key-1,key-2",
+			MaxRetries:        -1,
+			PayloadSampleRate: rate,
+			PayloadRedact:     []string{`hunter\d`},
+		})
+		require.NoError(t, err)
+
+		var payload *types.CompletionPayload
+		status := make(chan types.CompletionStatus)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for s := range status {
+				if s.Response != nil {
+					payload = s.Payload
+				}
+			}
+		}()
+		_, err = c.Call(context.Background(), types.CompletionRequest{
+			Model:    "gpt-4o",
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("is hunter2 the password of key-2 or sk-abcdefghijklmnopqrstuvwxyz?")}},
+		}, status)
+		close(status)
+		<-done
+		require.NoError(t, err)
+		return payload
+	}
+
+	require.Nil(t, call(""))
+	require.Nil(t, call("0"))
+
+	payload := call("100")
+	require.NotNil(t, payload)
+	require.Equal(t, http.MethodPost, payload.Request.Method)
+	require.Equal(t, s.URL+"/chat/completions", payload.Request.URL)
+	require.Equal(t, []string{redacted}, payload.Request.Header["Authorization"])
+	require.Contains(t, payload.Request.Body, `"is [REDACTED] the password of [REDACTED] or [REDACTED]?"`)
+	require.Equal(t, http.StatusOK, payload.Response.StatusCode)
+	require.Contains(t, payload.Response.Body, "the password is [REDACTED]")
+	require.NotContains(t, payload.Response.Body, "hunter2")
+
+	_, err := newPayloadSampler("101", nil, "")
+	require.Error(t, err)
+	_, err = newPayloadSampler("1", []string{"("}, "")
+	require.Error(t, err)
+}
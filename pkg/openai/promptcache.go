@@ -0,0 +1,195 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type cacheControlKey struct{}
+
+// withCacheControl marks the stable prefix of the request of the context for the prompt cache of the provider.
+func withCacheControl(ctx context.Context, enabled bool) context.Context {
+	if !enabled {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheControlKey{}, true)
+}
+
+func cacheControl(ctx context.Context) bool {
+	enabled, _ := ctx.Value(cacheControlKey{}).(bool)
+	return enabled
+}
+
+// promptCaching returns whether the models file marks the model as one whose provider caches prompts that are marked
+// with cache_control, like Anthropic models do. OpenAI caches prompts without them.
+func promptCaching(adaptations []ModelAdaptation, model string) bool {
+	for _, a := range adaptations {
+		if a.matches(model) {
+			return a.PromptCaching
+		}
+	}
+	return false
+}
+
+// markCacheControl marks the last tool and the last system message of the JSON body of a request as the end of
+// prefixes that the provider caches, because they are the same for every request of a tool. A system message with
+// text content is sent as a text part to have a cache_control.
+func markCacheControl(body map[string]any) {
+	ephemeral := map[string]any{"type": "ephemeral"}
+
+	if tools, ok := body["tools"].([]any); ok && len(tools) > 0 {
+		if tool, ok := tools[len(tools)-1].(map[string]any); ok {
+			tool["cache_control"] = ephemeral
+		}
+	}
+
+	messages, _ := body["messages"].([]any)
+	for i := len(messages) - 1; i >= 0; i-- {
+		message, ok := messages[i].(map[string]any)
+		if !ok || message["role"] != roleSystem {
+			continue
+		}
+		switch content := message["content"].(type) {
+		case string:
+			message["content"] = []any{map[string]any{
+				"type":          "text",
+				"text":          content,
+				"cache_control": ephemeral,
+			}}
+		case []any:
+			if len(content) == 0 {
+				break
+			}
+			if part, ok := content[len(content)-1].(map[string]any); ok {
+				part["cache_control"] = ephemeral
+			}
+		}
+		return
+	}
+}
+
+type cacheUsageKey struct{}
+
+// cacheUsage is what the provider reported of the prompt tokens of a response that were read from or written to its
+// prompt cache. The chat completion client drops these details of the usage, so they are read from the body of the
+// response by cacheUsageTransport.
+type cacheUsage struct {
+	lock        sync.Mutex
+	read, write int
+}
+
+func withCacheUsage(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheUsageKey{}, &cacheUsage{})
+}
+
+func getCacheUsage(ctx context.Context) *cacheUsage {
+	u, _ := ctx.Value(cacheUsageKey{}).(*cacheUsage)
+	return u
+}
+
+// usageDetails are the fields of the usage of OpenAI, and of providers of Anthropic models, for the prompt cache.
+type usageDetails struct {
+	Usage *struct {
+		PromptTokensDetails *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
+}
+
+func (u *cacheUsage) reset() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.read, u.write = 0, 0
+}
+
+// addChunk reads the usage of a response, or of the chunk of a streamed response that has it.
+func (u *cacheUsage) addChunk(data []byte) {
+	if !bytes.Contains(data, []byte(`"usage"`)) {
+		return
+	}
+	var details usageDetails
+	if err := json.Unmarshal(data, &details); err != nil || details.Usage == nil {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.read = details.Usage.CacheReadInputTokens
+	if d := details.Usage.PromptTokensDetails; d != nil && d.CachedTokens > 0 {
+		u.read = d.CachedTokens
+	}
+	u.write = details.Usage.CacheCreationInputTokens
+}
+
+// set sets the prompt cache tokens of the usage of a response.
+func (u *cacheUsage) set(usage *types.Usage) {
+	if u == nil {
+		return
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	usage.CacheReadTokens = u.read
+	usage.CacheWriteTokens = u.write
+}
+
+// cacheUsageTransport reads the prompt cache tokens from the usage in the body of the responses to chat requests.
+type cacheUsageTransport struct {
+	base http.RoundTripper
+}
+
+func (t *cacheUsageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := getCacheUsage(req.Context())
+	resp, err := t.base.RoundTrip(req)
+	if u == nil || err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	u.reset()
+	resp.Body = &cacheUsageReader{
+		ReadCloser: resp.Body,
+		usage:      u,
+		stream:     strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+	}
+	return resp, nil
+}
+
+// cacheUsageReader reads the usage from the chunks of a streamed response as they are read, or from a whole response
+// when it is closed.
+type cacheUsageReader struct {
+	io.ReadCloser
+	usage  *cacheUsage
+	stream bool
+	buf    bytes.Buffer
+}
+
+func (r *cacheUsageReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+
+	for r.stream {
+		i := bytes.IndexByte(r.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		if data, ok := bytes.CutPrefix(bytes.TrimSpace(r.buf.Next(i+1)), []byte("data:")); ok {
+			r.usage.addChunk(bytes.TrimSpace(data))
+		}
+	}
+	return n, err
+}
+
+func (r *cacheUsageReader) Close() error {
+	if !r.stream {
+		r.usage.addChunk(r.buf.Bytes())
+	}
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,76 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptCaching(t *testing.T) {
+	var body map[string]any
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"hi\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":1,\"total_tokens\":101,\"cache_read_input_tokens\":80,\"cache_creation_input_tokens\":20}}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer s.Close()
+
+	modelsFile := filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(modelsFile, []byte("models:\n- match: [\"claude-*\"]\n  promptCaching: true\n"), 0644))
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		SetSeed:    true,
+		ModelsFile: modelsFile,
+	})
+	require.NoError(t, err)
+
+	call := func(model string) types.Usage {
+		status := make(chan types.CompletionStatus, 10)
+		resp, err := c.Call(context.Background(), types.CompletionRequest{
+			Model: model,
+			Tools: []types.CompletionTool{{Function: types.CompletionFunctionDefinition{Name: "a"}}, {Function: types.CompletionFunctionDefinition{Name: "b"}}},
+			Messages: []types.CompletionMessage{
+				{Role: types.CompletionMessageRoleTypeSystem, Content: types.Text("be nice")},
+				{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")},
+			},
+		}, status)
+		require.NoError(t, err)
+		return resp.Usage
+	}
+
+	usage := call("claude-3-5-sonnet")
+	require.Equal(t, 80, usage.CacheReadTokens)
+	require.Equal(t, 20, usage.CacheWriteTokens)
+
+	ephemeral := map[string]any{"type": "ephemeral"}
+	tools := body["tools"].([]any)
+	require.Nil(t, tools[0].(map[string]any)["cache_control"])
+	require.Equal(t, ephemeral, tools[1].(map[string]any)["cache_control"])
+	system := body["messages"].([]any)[0].(map[string]any)
+	parts := system["content"].([]any)
+	require.Len(t, parts, 1)
+	require.Equal(t, ephemeral, parts[0].(map[string]any)["cache_control"])
+	require.Contains(t, parts[0].(map[string]any)["text"], "be nice")
+
+	// Other models are not marked, but the tokens they read from the cache are still reported.
+	usage = call("gpt-4o")
+	require.Equal(t, 80, usage.CacheReadTokens)
+	require.Nil(t, body["tools"].([]any)[1].(map[string]any)["cache_control"])
+	require.IsType(t, "", body["messages"].([]any)[0].(map[string]any)["content"])
+}
@@ -0,0 +1,210 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// providerLimits paces the requests to a provider by the remaining requests and tokens that it reported in the
+// x-ratelimit headers of its latest response, so that requests wait for the limits to reset instead of being rejected.
+// Providers limit each model separately, so the limits are kept by model.
+type providerLimits struct {
+	lock   sync.Mutex
+	models map[string]*modelRateLimit
+	now    func() time.Time
+	sleep  func(context.Context, time.Duration) error
+}
+
+type modelRateLimit struct {
+	// reported is the rate limit of the latest response that had one, received at reportedAt.
+	reported   *types.RateLimit
+	reportedAt time.Time
+	// requests and tokens are what remain of the reported limits after the requests sent since, or -1 when unknown.
+	requests int
+	tokens   int
+}
+
+func newProviderLimits() *providerLimits {
+	return &providerLimits{
+		models: map[string]*modelRateLimit{},
+		now:    time.Now,
+		sleep:  sleep,
+	}
+}
+
+type limitsModelKey struct{}
+
+// withLimitsModel returns a context for the requests to the model, so that the rate limits of their responses are
+// recorded for it.
+func withLimitsModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, limitsModelKey{}, model)
+}
+
+// parseRateLimit returns the rate limit of the x-ratelimit headers, such as those of OpenAI, or nil when there are none.
+func parseRateLimit(header http.Header) *types.RateLimit {
+	var (
+		result types.RateLimit
+		found  bool
+	)
+	number := func(name string) (int, bool) {
+		n, err := strconv.Atoi(strings.TrimSpace(header.Get(name)))
+		if err == nil && n >= 0 {
+			found = true
+			return n, true
+		}
+		return 0, false
+	}
+
+	result.LimitRequests, _ = number("X-Ratelimit-Limit-Requests")
+	result.LimitTokens, _ = number("X-Ratelimit-Limit-Tokens")
+	if n, ok := number("X-Ratelimit-Remaining-Requests"); ok {
+		result.RemainingRequests = &n
+	}
+	if n, ok := number("X-Ratelimit-Remaining-Tokens"); ok {
+		result.RemainingTokens = &n
+	}
+	if !found {
+		return nil
+	}
+	result.ResetRequests = parseReset(header.Get("X-Ratelimit-Reset-Requests"))
+	result.ResetTokens = parseReset(header.Get("X-Ratelimit-Reset-Tokens"))
+	return &result
+}
+
+// parseReset parses a reset time such as 6m0s or 20ms, or a number of seconds.
+func parseReset(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return 0
+}
+
+// report records the rate limit of the headers of a response from the model, if they have one.
+func (p *providerLimits) report(model string, header http.Header) {
+	if p == nil {
+		return
+	}
+	rateLimit := parseRateLimit(header)
+	if rateLimit == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	m := &modelRateLimit{
+		reported:   rateLimit,
+		reportedAt: p.now(),
+		requests:   -1,
+		tokens:     -1,
+	}
+	if rateLimit.RemainingRequests != nil {
+		m.requests = *rateLimit.RemainingRequests
+	}
+	if rateLimit.RemainingTokens != nil {
+		m.tokens = *rateLimit.RemainingTokens
+	}
+	p.models[model] = m
+}
+
+// latest returns the rate limit of the latest response from the model that had one, or nil.
+func (p *providerLimits) latest(model string) *types.RateLimit {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if m := p.models[model]; m != nil {
+		return m.reported
+	}
+	return nil
+}
+
+// wait blocks until the provider has requests and about the given number of tokens left for the model, and counts
+// the request. A nil providerLimits never blocks.
+func (p *providerLimits) wait(ctx context.Context, model string, tokens int) error {
+	if p == nil {
+		return nil
+	}
+	for {
+		delay := p.delay(model, tokens)
+		if delay <= 0 {
+			return nil
+		}
+		log.WithContext(ctx).Infof("Waiting %s for the rate limit of %s to reset", delay.Round(time.Second), model)
+		if err := p.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// delay returns how long to wait before the provider is expected to accept a request to the model of the given number
+// of tokens, and counts the request when it needn't wait. Limits whose reset time has passed are unknown until the
+// next report.
+func (p *providerLimits) delay(model string, tokens int) time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	m := p.models[model]
+	if m == nil {
+		return 0
+	}
+
+	now := p.now()
+	var delay time.Duration
+	if m.requests == 0 {
+		if reset := m.reportedAt.Add(m.reported.ResetRequests); reset.After(now) {
+			delay = reset.Sub(now)
+		} else {
+			m.requests = -1
+		}
+	}
+	// A request bigger than the limit is sent once the tokens reset.
+	need := tokens
+	if m.reported.LimitTokens > 0 {
+		need = min(tokens, m.reported.LimitTokens)
+	}
+	if m.tokens >= 0 && m.tokens < need {
+		if reset := m.reportedAt.Add(m.reported.ResetTokens); reset.After(now) {
+			delay = max(delay, reset.Sub(now))
+		} else {
+			m.tokens = -1
+		}
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	if m.requests > 0 {
+		m.requests--
+	}
+	if m.tokens >= 0 {
+		m.tokens = max(m.tokens-tokens, 0)
+	}
+	return 0
+}
+
+// rateLimitTransport reports the rate limits of the responses of a provider to its limits, for the model of the
+// context of the request.
+type rateLimitTransport struct {
+	base   http.RoundTripper
+	limits *providerLimits
+}
+
+func (r *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if model, ok := req.Context().Value(limitsModelKey{}).(string); ok && err == nil {
+		r.limits.report(model, resp.Header)
+	}
+	return resp, err
+}
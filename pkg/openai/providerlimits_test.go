@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	header := http.Header{}
+	require.Nil(t, parseRateLimit(header))
+
+	header.Set("X-Ratelimit-Limit-Requests", "500")
+	header.Set("X-Ratelimit-Remaining-Requests", "0")
+	header.Set("X-Ratelimit-Reset-Requests", "1m12s")
+	header.Set("X-Ratelimit-Limit-Tokens", "30000")
+	header.Set("X-Ratelimit-Remaining-Tokens", "29500")
+	header.Set("X-Ratelimit-Reset-Tokens", "1.5")
+	rateLimit := parseRateLimit(header)
+	require.NotNil(t, rateLimit)
+	require.Equal(t, 500, rateLimit.LimitRequests)
+	require.Equal(t, 0, *rateLimit.RemainingRequests)
+	require.Equal(t, 72*time.Second, rateLimit.ResetRequests)
+	require.Equal(t, 30000, rateLimit.LimitTokens)
+	require.Equal(t, 29500, *rateLimit.RemainingTokens)
+	require.Equal(t, 1500*time.Millisecond, rateLimit.ResetTokens)
+}
+
+func TestProviderLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	var delays []time.Duration
+	limits := newProviderLimits()
+	limits.now = func() time.Time { return now }
+	limits.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		now = now.Add(d)
+		return nil
+	}
+	ctx := context.Background()
+
+	// Nothing is known before the first response.
+	require.NoError(t, limits.wait(ctx, "gpt-4o", 1000))
+	require.Empty(t, delays)
+
+	limits.report("gpt-4o", http.Header{
+		"X-Ratelimit-Limit-Requests":     {"100"},
+		"X-Ratelimit-Remaining-Requests": {"2"},
+		"X-Ratelimit-Reset-Requests":     {"10s"},
+		"X-Ratelimit-Limit-Tokens":       {"1000"},
+		"X-Ratelimit-Remaining-Tokens":   {"900"},
+		"X-Ratelimit-Reset-Tokens":       {"20s"},
+	})
+
+	// The remaining requests and tokens are counted down by the requests sent since.
+	require.NoError(t, limits.wait(ctx, "gpt-4o", 500))
+	require.Empty(t, delays)
+	require.NoError(t, limits.wait(ctx, "gpt-4o", 500))
+	require.Equal(t, []time.Duration{20 * time.Second}, delays)
+
+	// Once the limits reset they are unknown until the next response.
+	delays = nil
+	require.NoError(t, limits.wait(ctx, "gpt-4o", 500))
+	require.Empty(t, delays)
+
+	limits.report("gpt-4o", http.Header{
+		"X-Ratelimit-Remaining-Requests": {"0"},
+		"X-Ratelimit-Reset-Requests":     {"3s"},
+	})
+	require.NoError(t, limits.wait(ctx, "gpt-4o", 500))
+	require.Equal(t, []time.Duration{3 * time.Second}, delays)
+	require.Equal(t, 0, *limits.latest("gpt-4o").RemainingRequests)
+
+	// The limits of one model don't hold back the requests to another.
+	delays = nil
+	limits.report("gpt-4o", http.Header{
+		"X-Ratelimit-Remaining-Requests": {"0"},
+		"X-Ratelimit-Reset-Requests":     {"3s"},
+	})
+	require.NoError(t, limits.wait(ctx, "gpt-4o-mini", 500))
+	require.Empty(t, delays)
+	require.Nil(t, limits.latest("gpt-4o-mini"))
+
+	var none *providerLimits
+	require.NoError(t, none.wait(ctx, "gpt-4o", 100))
+	require.Nil(t, none.latest("gpt-4o"))
+}
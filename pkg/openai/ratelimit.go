@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const rateWindow = time.Minute
+
+// rateLimiter keeps the requests to a provider within a number of requests and tokens per minute. Calls over a limit
+// wait, in the order they arrived, until enough of the requests of the last minute have aged out.
+type rateLimiter struct {
+	requests int
+	tokens   int
+	// turn is held by the call that is waiting for the limits, so that the others queue behind it.
+	turn chan struct{}
+	// lock guards sent, which are the requests of the last minute.
+	lock  sync.Mutex
+	sent  []*rateEntry
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+type rateEntry struct {
+	time   time.Time
+	tokens int
+}
+
+func newRateLimiter(requestsPerMinute, tokensPerMinute int) *rateLimiter {
+	if requestsPerMinute <= 0 && tokensPerMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		requests: requestsPerMinute,
+		tokens:   tokensPerMinute,
+		turn:     make(chan struct{}, 1),
+		now:      time.Now,
+		sleep:    sleep,
+	}
+}
+
+// wait blocks until a request of about the given number of tokens can be sent, and counts it. The tokens of the
+// returned entry are corrected with settle once the usage of the request is known. A nil limiter never blocks.
+func (r *rateLimiter) wait(ctx context.Context, tokens int) (*rateEntry, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	select {
+	case r.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		<-r.turn
+	}()
+
+	for {
+		delay := r.delay(tokens)
+		if delay <= 0 {
+			break
+		}
+		log.WithContext(ctx).Infof("Waiting %s to stay within the rate limit of the model provider", delay.Round(time.Second))
+		if err := r.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := &rateEntry{
+		time:   r.now(),
+		tokens: tokens,
+	}
+	r.sent = append(r.sent, entry)
+	return entry, nil
+}
+
+// delay returns how long to wait before a request of the given number of tokens is within the limits. It must only be
+// called by the holder of the turn.
+func (r *rateLimiter) delay(tokens int) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	for len(r.sent) > 0 && now.Sub(r.sent[0].time) >= rateWindow {
+		r.sent = r.sent[1:]
+	}
+
+	var delay time.Duration
+	if r.requests > 0 && len(r.sent) >= r.requests {
+		delay = r.sent[len(r.sent)-r.requests].time.Add(rateWindow).Sub(now)
+	}
+
+	if r.tokens > 0 {
+		// A request bigger than the limit is sent once nothing else was sent in the last minute.
+		used := min(tokens, r.tokens)
+		for _, entry := range r.sent {
+			used += entry.tokens
+		}
+		for _, entry := range r.sent {
+			if used <= r.tokens {
+				break
+			}
+			used -= entry.tokens
+			delay = max(delay, entry.time.Add(rateWindow).Sub(now))
+		}
+	}
+
+	return delay
+}
+
+// settle sets the tokens of a request to what it actually used.
+func (r *rateLimiter) settle(entry *rateEntry, tokens int) {
+	if r == nil || entry == nil || tokens <= 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry.tokens = tokens
+}
@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	var delays []time.Duration
+	limiter := newRateLimiter(2, 1000)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		now = now.Add(d)
+		return nil
+	}
+	ctx := context.Background()
+
+	first, err := limiter.wait(ctx, 300)
+	require.NoError(t, err)
+	now = now.Add(10 * time.Second)
+	_, err = limiter.wait(ctx, 300)
+	require.NoError(t, err)
+	require.Empty(t, delays)
+
+	// The third request waits for the first to age out of the minute.
+	_, err = limiter.wait(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{50 * time.Second}, delays)
+
+	// The estimate is corrected once the usage is known.
+	limiter.settle(first, 50)
+	require.Equal(t, 50, first.tokens)
+
+	// The second and third requests leave room for 600 tokens until the second ages out.
+	delays = nil
+	now = now.Add(5 * time.Second)
+	_, err = limiter.wait(ctx, 700)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{5 * time.Second}, delays)
+
+	// A request bigger than the limit waits for everything else to age out.
+	delays = nil
+	_, err = limiter.wait(ctx, 5000)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{60 * time.Second}, delays)
+
+	var none *rateLimiter
+	entry, err := none.wait(ctx, 100)
+	require.NoError(t, err)
+	require.Nil(t, entry)
+	require.Nil(t, newRateLimiter(0, 0))
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	limiter := newRateLimiter(1, 0)
+	_, err := limiter.wait(context.Background(), 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.wait(ctx, 0)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,132 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+var reasoningEfforts = []string{"low", "medium", "high"}
+
+// reasoningModel is what a reasoning model supports. Reasoning models reject sampling parameters and max_tokens, and
+// some of them also system messages and streaming.
+type reasoningModel struct {
+	noSystem    bool
+	noStreaming bool
+	effort      string
+}
+
+// builtinReasoningModels are the well known reasoning models. The first entry that matches the lower case model name
+// is used, so more specific patterns come first.
+var builtinReasoningModels = []struct {
+	match []string
+	model reasoningModel
+}{
+	{[]string{"o1-mini*", "o1-preview*"}, reasoningModel{noSystem: true, noStreaming: true}},
+	{[]string{"o1*"}, reasoningModel{noStreaming: true}},
+	{[]string{"o3*", "o4-mini*"}, reasoningModel{}},
+}
+
+func validateReasoningEffort(effort string) error {
+	for _, e := range reasoningEfforts {
+		if effort == e {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid reasoning effort %q, must be one of %s", effort, strings.Join(reasoningEfforts, ", "))
+}
+
+// findReasoningModel returns what a model supports if it is a reasoning model. A matching adaptation in the models
+// file that sets reasoning decides whether the model is one, otherwise the model name is matched against the well known
+// reasoning models.
+func findReasoningModel(adaptations []ModelAdaptation, model string) (result reasoningModel, _ bool) {
+	var adaptation ModelAdaptation
+	for _, a := range adaptations {
+		if a.matches(model) {
+			adaptation = a
+			break
+		}
+	}
+
+	found := adaptation.Reasoning != nil && *adaptation.Reasoning
+	if adaptation.Reasoning == nil {
+		model = strings.ToLower(model)
+	builtin:
+		for _, entry := range builtinReasoningModels {
+			for _, pattern := range entry.match {
+				if ok, _ := path.Match(pattern, model); ok {
+					result, found = entry.model, true
+					break builtin
+				}
+			}
+		}
+	}
+
+	result.effort = adaptation.ReasoningEffort
+	return result, found
+}
+
+// adapt rewrites the request for the reasoning model and returns the fields of the body that the chat completion
+// client has no field for. The effort is used if the model doesn't have its own.
+func (r reasoningModel) adapt(ctx context.Context, request *openai.ChatCompletionRequest, fields map[string]any, effort string) map[string]any {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+
+	var dropped []string
+	if request.Temperature != nil && *request.Temperature != 0 {
+		dropped = append(dropped, "temperature")
+	}
+	if request.TopP != 0 {
+		dropped = append(dropped, "top p")
+	}
+	if request.FrequencyPenalty != 0 || request.PresencePenalty != 0 {
+		dropped = append(dropped, "penalties")
+	}
+	if len(request.Stop) > 0 {
+		dropped = append(dropped, "stop sequences")
+	}
+	if len(dropped) > 0 {
+		log.WithContext(ctx).Debugf("Not sending the %s of the request to the reasoning model %s, which doesn't support them", strings.Join(dropped, ", "), request.Model)
+	}
+	request.Temperature = nil
+	request.TopP = 0
+	request.FrequencyPenalty = 0
+	request.PresencePenalty = 0
+	request.Stop = nil
+	delete(fields, "parallel_tool_calls")
+
+	if request.MaxTokens != 0 {
+		// The limit of reasoning models includes the tokens they reason with, which max_tokens does not.
+		fields["max_completion_tokens"] = request.MaxTokens
+		request.MaxTokens = 0
+	}
+	if effort := strings.ToLower(types.FirstSet(r.effort, effort)); effort != "" {
+		fields["reasoning_effort"] = effort
+	}
+
+	if r.noSystem {
+		request.Messages = mergeConsecutive(systemToUser(request.Messages))
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+type noStreamingKey struct{}
+
+// withoutStreaming makes the request with the context wait for the whole response, for models that can't stream it.
+func withoutStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noStreamingKey{}, true)
+}
+
+func streaming(ctx context.Context) bool {
+	noStreaming, _ := ctx.Value(noStreamingKey{}).(bool)
+	return !noStreaming
+}
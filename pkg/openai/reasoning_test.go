@@ -0,0 +1,90 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindReasoningModel(t *testing.T) {
+	model, ok := findReasoningModel(nil, "o1-mini-2024-09-12")
+	require.True(t, ok)
+	require.Equal(t, reasoningModel{noSystem: true, noStreaming: true}, model)
+
+	model, ok = findReasoningModel(nil, "O3-mini")
+	require.True(t, ok)
+	require.Equal(t, reasoningModel{}, model)
+
+	_, ok = findReasoningModel(nil, "gpt-4o")
+	require.False(t, ok)
+
+	adaptations := []ModelAdaptation{
+		{Match: []string{"deepseek-r1*"}, Reasoning: ptr(true), ReasoningEffort: "high"},
+		{Match: []string{"o1-proxy"}, Reasoning: ptr(false)},
+	}
+	model, ok = findReasoningModel(adaptations, "deepseek-r1-distill")
+	require.True(t, ok)
+	require.Equal(t, reasoningModel{effort: "high"}, model)
+
+	_, ok = findReasoningModel(adaptations, "o1-proxy")
+	require.False(t, ok)
+}
+
+func TestReasoningRequest(t *testing.T) {
+	var body map[string]any
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = map[string]any{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "42"}, "finish_reason": "stop"}]}`)
+	}))
+	defer s.Close()
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:         s.URL,
+		APIKey:          "key",
+		MaxRetries:      -1,
+		ReasoningEffort: "low",
+	})
+	require.NoError(t, err)
+
+	status := make(chan types.CompletionStatus)
+	go func() {
+		for range status {
+		}
+	}()
+	defer close(status)
+
+	resp, err := c.Call(context.Background(), types.CompletionRequest{
+		Model:       "o1-mini",
+		MaxTokens:   1000,
+		Temperature: ptr[float32](0.5),
+		Stop:        []string{"END"},
+		Messages: []types.CompletionMessage{
+			{Role: types.CompletionMessageRoleTypeSystem, Content: types.Text("Answer briefly.")},
+			{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("What is 6 times 7?")},
+		},
+	}, status)
+	require.NoError(t, err)
+	require.Equal(t, "42", resp.Content[0].Text)
+
+	require.Equal(t, map[string]any{
+		"model":                 "o1-mini",
+		"max_completion_tokens": float64(1000),
+		"reasoning_effort":      "low",
+		"messages": []any{map[string]any{
+			"role":    "user",
+			"content": "Answer briefly.\n\nWhat is 6 times 7?",
+		}},
+	}, body)
+
+	_, err = NewClient(context.Background(), credentials.NoopStore{}, Options{ReasoningEffort: "extreme"})
+	require.ErrorContains(t, err, "invalid reasoning effort")
+}
@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// repairArguments fixes the mistakes that models make in the JSON arguments of tool calls: code fences around them,
+// trailing commas, and strings, arrays, and objects that are not closed because the response was cut off. The
+// arguments are returned unchanged if they are valid or can't be repaired.
+func repairArguments(args string) (string, bool) {
+	if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+		return args, false
+	}
+
+	s := strings.TrimSpace(args)
+	if trimmed, ok := strings.CutPrefix(s, "```"); ok {
+		trimmed = strings.TrimPrefix(trimmed, "json")
+		s = strings.TrimSpace(strings.TrimSuffix(trimmed, "```"))
+	}
+
+	var (
+		out      strings.Builder
+		closers  []byte
+		inString bool
+		escaped  bool
+	)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			out.WriteByte(ch)
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			if len(closers) == 0 || closers[len(closers)-1] != ch {
+				return args, false
+			}
+			closers = closers[:len(closers)-1]
+			trimTrailingComma(&out)
+		}
+		out.WriteByte(ch)
+	}
+
+	if inString {
+		if escaped {
+			// Drop the backslash that the string was cut off after.
+			str := out.String()
+			out.Reset()
+			out.WriteString(str[:len(str)-1])
+		}
+		out.WriteByte('"')
+	}
+	trimTrailingComma(&out)
+	if strings.HasSuffix(strings.TrimSpace(out.String()), ":") {
+		out.WriteString("null")
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		out.WriteByte(closers[i])
+	}
+
+	if repaired := out.String(); json.Valid([]byte(repaired)) {
+		return repaired, true
+	}
+	return args, false
+}
+
+func trimTrailingComma(out *strings.Builder) {
+	str := strings.TrimRight(out.String(), " \t\r\n")
+	if trimmed, ok := strings.CutSuffix(str, ","); ok {
+		out.Reset()
+		out.WriteString(trimmed)
+	}
+}
@@ -0,0 +1,48 @@
+package openai
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairArguments(t *testing.T) {
+	for args, expected := range map[string]string{
+		`{"a": [1, 2,], "b": "c",}`:          `{"a": [1, 2], "b": "c"}`,
+		"```json\n{\"a\": 1}\n```":           `{"a": 1}`,
+		`{"text": "cut off`:                  `{"text": "cut off"}`,
+		`{"text": "cut off \`:                `{"text": "cut off "}`,
+		`{"items": [{"name": "x"}, {"name":`: `{"items": [{"name": "x"}, {"name":null}]}`,
+	} {
+		repaired, ok := repairArguments(args)
+		require.True(t, ok, args)
+		require.Equal(t, expected, repaired)
+	}
+
+	for _, args := range []string{`{"a": 1}`, ``, `{"a": 1]`, `{"a" 1}`} {
+		repaired, ok := repairArguments(args)
+		require.False(t, ok, args)
+		require.Equal(t, args, repaired)
+	}
+}
+
+func TestToCompletionMessageRepairsArguments(t *testing.T) {
+	var responses []openai.ChatCompletionStreamResponse
+	for _, args := range []string{`{"query": "weather",`, ` "limit": 3,}`} {
+		responses = append(responses, openai.ChatCompletionStreamResponse{
+			Choices: []openai.ChatCompletionStreamChoice{{
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					ToolCalls: []openai.ToolCall{{
+						Index:    ptr(0),
+						Function: openai.FunctionCall{Name: "search", Arguments: args},
+					}},
+				},
+			}},
+		})
+	}
+
+	msg := toCompletionMessage(context.Background(), responses)
+	require.Equal(t, `{"query": "weather", "limit": 3}`, msg.Content[0].ToolCall.Function.Arguments)
+}
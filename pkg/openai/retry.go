@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 5
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = time.Minute
+)
+
+// retryTransport retries requests that fail with a rate limit or a server error. The delay between attempts doubles
+// each time, with jitter so that parallel calls don't retry together, unless the response says how long to wait. No
+// delay is longer than maxRetryBackoff.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	backoff    time.Duration
+	sleep      func(context.Context, time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, maxRetries int, backoff string) (http.RoundTripper, error) {
+	if maxRetries < 0 {
+		return base, nil
+	}
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	delay := defaultRetryBackoff
+	if backoff != "" {
+		var err error
+		if delay, err = time.ParseDuration(backoff); err != nil || delay <= 0 {
+			return nil, fmt.Errorf("invalid retry backoff %q", backoff)
+		}
+	}
+
+	return &retryTransport{
+		base:       base,
+		maxRetries: maxRetries,
+		backoff:    delay,
+		sleep:      sleep,
+	}, nil
+}
+
+func (r *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is read once so that it can be sent again.
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if body != nil {
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := r.base.RoundTrip(req)
+		if err != nil || attempt >= r.maxRetries || !retryable(resp.StatusCode) {
+			return resp, err
+		}
+
+		delay := retryAfter(resp.Header)
+		if delay < 0 {
+			delay = r.delay(attempt)
+		} else {
+			// A server can ask for any delay, but a run shouldn't hang on one request for longer than it backs off.
+			delay = min(delay, maxRetryBackoff)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		log.WithContext(req.Context()).Infof("Request to %s failed with status %d, retrying in %s (attempt %d of %d)", req.URL.Host, resp.StatusCode,
+			delay.Round(time.Millisecond), attempt+1, r.maxRetries)
+		if err := r.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delay is the exponential backoff before the given retry, between half and all of the doubled delay.
+func (r *retryTransport) delay(attempt int) time.Duration {
+	delay := r.backoff << attempt
+	if delay <= 0 || delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError && status != http.StatusNotImplemented
+}
+
+// retryAfter returns how long the response asks the client to wait, or -1 if it doesn't say. The retry-after-ms header
+// is sent by OpenAI and Azure, Retry-After is either a number of seconds or a date.
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return -1
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return -1
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	var (
+		bodies   []string
+		statuses = []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		switch len(bodies) {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+		case 2:
+			w.Header().Set("Retry-After", "3600")
+		}
+		w.WriteHeader(statuses[len(bodies)-1])
+	}))
+	defer s.Close()
+
+	transport, err := newRetryTransport(http.DefaultTransport, 0, "2s")
+	require.NoError(t, err)
+
+	var delays []time.Duration
+	transport.(*retryTransport).sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Post(s.URL, "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The body is sent again, the first retry waits as long as the server asked, the second no longer than the maximum
+	// backoff, and the third backs off with jitter.
+	require.Equal(t, []string{`{"model":"gpt-4o"}`, `{"model":"gpt-4o"}`, `{"model":"gpt-4o"}`, `{"model":"gpt-4o"}`}, bodies)
+	require.Len(t, delays, 3)
+	require.Equal(t, 7*time.Second, delays[0])
+	require.Equal(t, maxRetryBackoff, delays[1])
+	require.GreaterOrEqual(t, delays[2], 4*time.Second)
+	require.LessOrEqual(t, delays[2], 8*time.Second)
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	var attempts int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer s.Close()
+
+	transport, err := newRetryTransport(http.DefaultTransport, 2, "")
+	require.NoError(t, err)
+	transport.(*retryTransport).sleep = func(context.Context, time.Duration) error { return nil }
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Equal(t, 3, attempts)
+
+	// Client errors are not retried.
+	attempts = 0
+	resp, err = client.Get(s.URL + "/missing")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, 1, attempts)
+
+	// A negative number of retries disables them.
+	transport, err = newRetryTransport(http.DefaultTransport, -1, "")
+	require.NoError(t, err)
+	require.Equal(t, http.DefaultTransport, transport)
+
+	_, err = newRetryTransport(http.DefaultTransport, 0, "soon")
+	require.EqualError(t, err, `invalid retry backoff "soon"`)
+}
+
+func TestRetryAfter(t *testing.T) {
+	require.Equal(t, time.Duration(-1), retryAfter(http.Header{}))
+	require.Equal(t, 1500*time.Millisecond, retryAfter(http.Header{"Retry-After": []string{"1.5"}}))
+	require.Equal(t, 250*time.Millisecond, retryAfter(http.Header{"Retry-After-Ms": []string{"250"}, "Retry-After": []string{"1"}}))
+	require.Equal(t, time.Duration(0), retryAfter(http.Header{"Retry-After": []string{"Wed, 21 Oct 2015 07:28:00 GMT"}}))
+	require.Equal(t, time.Duration(-1), retryAfter(http.Header{"Retry-After": []string{"later"}}))
+}
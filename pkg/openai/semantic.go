@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+)
+
+const (
+	defaultEmbeddingModel = "text-embedding-3-small"
+	// maxSemanticEntries is how many prompts are kept for requests that differ only by their prompt, the oldest are
+	// dropped first.
+	maxSemanticEntries = 1000
+)
+
+// semanticCache serves the cached response of a request whose prompt, the text of the last user message, is similar
+// enough to the prompt of a request that was cached when the rest of the two requests is the same. The similarity of
+// prompts is the cosine similarity of their embeddings.
+type semanticCache struct {
+	threshold float64
+	// lock keeps concurrent calls from dropping each other's entries.
+	lock sync.Mutex
+}
+
+type semanticEntry struct {
+	Embedding []float64                             `json:"embedding"`
+	Responses []openai.ChatCompletionStreamResponse `json:"responses"`
+}
+
+// semanticKey is where the response to a request is added to the semantic cache once it is received.
+type semanticKey struct {
+	key       any
+	embedding []float64
+}
+
+func newSemanticCache(threshold string) (*semanticCache, error) {
+	if threshold == "" {
+		return nil, nil
+	}
+	t, err := strconv.ParseFloat(threshold, 64)
+	if err != nil || t <= 0 || t > 1 {
+		return nil, fmt.Errorf("invalid semantic cache threshold %q, must be a number greater than 0 and at most 1", threshold)
+	}
+	return &semanticCache{
+		threshold: t,
+	}, nil
+}
+
+// splitPrompt returns the request without its prompt, and the prompt.
+func splitPrompt(request openai.ChatCompletionRequest) (openai.ChatCompletionRequest, string, bool) {
+	if len(request.Messages) == 0 {
+		return request, "", false
+	}
+	last := request.Messages[len(request.Messages)-1]
+	if last.Role != openai.ChatMessageRoleUser || strings.TrimSpace(last.Content) == "" || len(last.MultiContent) > 0 {
+		return request, "", false
+	}
+
+	request.Messages = append(request.Messages[:len(request.Messages)-1:len(request.Messages)-1], openai.ChatCompletionMessage{
+		Role: last.Role,
+		Name: last.Name,
+	})
+	// The seed is derived from the whole request, prompt included.
+	request.Seed = nil
+	return request, last.Content, true
+}
+
+// fromSemanticCache returns the cached response of the most similar prompt that is similar enough. The returned key,
+// if not nil, is where the response is cached when there is none.
+func (c *Client) fromSemanticCache(ctx context.Context, request openai.ChatCompletionRequest) ([]openai.ChatCompletionStreamResponse, *semanticKey, bool) {
+	scope, prompt, ok := splitPrompt(request)
+	if c.semantic == nil || !ok {
+		return nil, nil, false
+	}
+
+	embeddings, err := c.Embeddings(ctx, "", []string{prompt})
+	if err != nil {
+		log.WithContext(ctx).Warnf("Not using the semantic cache, failed to embed the prompt: %v", err)
+		return nil, nil, false
+	}
+
+	key := &semanticKey{
+		key:       map[string]any{"semantic": c.cacheKey(ctx, scope), "model": c.embeddingModel},
+		embedding: embeddings[0],
+	}
+
+	var entries []semanticEntry
+	if _, err := c.cache.Get(ctx, key.key, &entries); err != nil {
+		log.WithContext(ctx).Debugf("ignoring semantic cache entries: %v", err)
+		return nil, key, false
+	}
+
+	var (
+		best       []openai.ChatCompletionStreamResponse
+		similarity float64
+	)
+	for _, entry := range entries {
+		if s := cosineSimilarity(key.embedding, entry.Embedding); s >= c.semantic.threshold && s > similarity {
+			best, similarity = entry.Responses, s
+		}
+	}
+	if best == nil {
+		return nil, key, false
+	}
+
+	log.WithContext(ctx).Debugf("Using the cached response of a prompt with similarity %.3f", similarity)
+	return best, nil, true
+}
+
+// storeSemantic adds a response to the semantic cache. Failing to do so doesn't fail the call.
+func (c *Client) storeSemantic(ctx context.Context, key *semanticKey, responses []openai.ChatCompletionStreamResponse) {
+	if key == nil {
+		return
+	}
+
+	c.semantic.lock.Lock()
+	defer c.semantic.lock.Unlock()
+
+	var entries []semanticEntry
+	if _, err := c.cache.Get(ctx, key.key, &entries); err != nil {
+		entries = nil
+	}
+	entries = append(entries, semanticEntry{
+		Embedding: key.embedding,
+		Responses: responses,
+	})
+	if len(entries) > maxSemanticEntries {
+		entries = entries[len(entries)-maxSemanticEntries:]
+	}
+	if err := c.cache.Store(ctx, key.key, entries); err != nil {
+		log.WithContext(ctx).Warnf("Failed to add the response to the semantic cache: %v", err)
+	}
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,120 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemanticCache(t *testing.T) {
+	embeddings := map[string][]float64{
+		"I love this product":          {1, 0, 0},
+		"I really love this product":   {0.98, 0.2, 0},
+		"This product is a waste":      {0, 1, 0},
+		"Is this product any good?":    {0.5, 0.5, 0.7},
+		"Is this product really good?": {0.5, 0.5, 0.71},
+	}
+	var completions int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/embeddings":
+			var req struct {
+				Model string   `json:"model"`
+				Input []string `json:"input"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, "test-embedding", req.Model)
+			require.Len(t, req.Input, 1)
+			require.Equal(t, "Bearer test", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": []any{map[string]any{"embedding": embeddings[req.Input[0]]}},
+			})
+		case "/chat/completions":
+			completions++
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"label %d\"}}]}\n\n", completions)
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:        s.URL,
+		APIKey:         "test",
+		Cache:          cacheClient,
+		SemanticCache:  "0.95",
+		EmbeddingModel: "test-embedding",
+	})
+	require.NoError(t, err)
+
+	classify := func(prompt string, useCache bool) (string, types.CompletionStatus) {
+		status := make(chan types.CompletionStatus)
+		done := make(chan types.CompletionStatus)
+		go func() {
+			var last types.CompletionStatus
+			for s := range status {
+				if s.Response != nil {
+					last = s
+				}
+			}
+			done <- last
+		}()
+
+		resp, err := c.Call(context.Background(), types.CompletionRequest{
+			Model: "gpt-4o",
+			Cache: &useCache,
+			Messages: []types.CompletionMessage{
+				{Role: types.CompletionMessageRoleTypeSystem, Content: types.Text("Label the sentiment of the review.")},
+				{Role: types.CompletionMessageRoleTypeUser, Content: types.Text(prompt)},
+			},
+		}, status)
+		close(status)
+		require.NoError(t, err)
+		return resp.Content[0].Text, <-done
+	}
+
+	text, status := classify("I love this product", true)
+	require.Equal(t, "label 1", text)
+	require.False(t, status.Cached)
+
+	// A similar prompt gets the response of the first one, marked as approximate.
+	text, status = classify("I really love this product", true)
+	require.Equal(t, "label 1", text)
+	require.True(t, status.Cached)
+	require.True(t, status.Approximate)
+
+	// A different prompt is sent to the model.
+	text, status = classify("This product is a waste", true)
+	require.Equal(t, "label 2", text)
+	require.False(t, status.Approximate)
+
+	// Without the cache, similar prompts are neither served nor stored.
+	text, _ = classify("Is this product any good?", false)
+	require.Equal(t, "label 3", text)
+	text, status = classify("Is this product really good?", true)
+	require.Equal(t, "label 4", text)
+	require.False(t, status.Approximate)
+
+	_, err = NewClient(context.Background(), credentials.NoopStore{}, Options{APIKey: "test", SemanticCache: "high"})
+	require.Error(t, err)
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	require.InDelta(t, 1, cosineSimilarity([]float64{1, 2, 3}, []float64{2, 4, 6}), 1e-9)
+	require.InDelta(t, 0, cosineSimilarity([]float64{1, 0}, []float64{0, 1}), 1e-9)
+	require.Zero(t, cosineSimilarity([]float64{1, 0}, []float64{1, 0, 0}))
+	require.Zero(t, cosineSimilarity([]float64{0, 0}, []float64{1, 0}))
+}
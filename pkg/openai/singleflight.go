@@ -0,0 +1,158 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type flight struct {
+	responses []openai.ChatCompletionStreamResponse
+	timing    *types.Timing
+}
+
+type flightCall func(ctx context.Context, status chan<- types.CompletionStatus) ([]openai.ChatCompletionStreamResponse, *types.Timing, error)
+
+// dedupe makes one call for identical requests that are in flight at the same time, such as those of sub-agents that
+// a map step fans out to, keyed by their cache key. The requests that waited for the call of another get its responses
+// without its timing, and shared is set for them because they cost nothing. Requests that are not cached are always
+// called, because they are expected to get responses of their own.
+//
+// A request stops waiting when its context is done, but the call goes on without it for the requests that share it,
+// so the call is not canceled by the request that made it. It is canceled once no request waits for it.
+func (c *Client) dedupe(ctx context.Context, messageRequest types.CompletionRequest, request openai.ChatCompletionRequest, status chan<- types.CompletionStatus, call flightCall) (_ []openai.ChatCompletionStreamResponse, _ *types.Timing, shared bool, _ error) {
+	var (
+		key []byte
+		err error
+	)
+	if messageRequest.GetCache() {
+		// The key is encoded as JSON like it is by the cache, because unlike gob it encodes maps in a stable order.
+		key, err = json.Marshal(c.cacheKey(ctx, request))
+	}
+	if key == nil || err != nil {
+		responses, timing, err := call(ctx, status)
+		return responses, timing, false, err
+	}
+
+	var (
+		called bool
+		relay  = &statusRelay{status: status}
+	)
+	// The relay is detached before returning, because the status channel is closed once the request is done.
+	defer relay.detach()
+
+	w := c.flights.join(ctx, hash.Digest(key))
+	defer c.flights.leave(w)
+
+	results := c.inflight.DoChan(w.key, func() (any, error) {
+		called = true
+		responses, timing, err := relay.run(w.ctx, call)
+		return flight{
+			responses: responses,
+			timing:    timing,
+		}, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, nil, false, context.Cause(ctx)
+	case result := <-results:
+		f, _ := result.Val.(flight)
+		if !called {
+			log.WithContext(ctx).Debugf("Shared the response of an identical request to %s", request.Model)
+			return f.responses, nil, true, result.Err
+		}
+		return f.responses, f.timing, false, result.Err
+	}
+}
+
+// statusRelay sends the status of a call to the request that made it, until the request stops waiting for the call.
+type statusRelay struct {
+	lock   sync.Mutex
+	status chan<- types.CompletionStatus
+}
+
+// run runs the call with a status channel whose statuses are relayed, and returns once they all were.
+func (s *statusRelay) run(ctx context.Context, call flightCall) ([]openai.ChatCompletionStreamResponse, *types.Timing, error) {
+	var (
+		statuses = make(chan types.CompletionStatus)
+		done     = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for status := range statuses {
+			s.lock.Lock()
+			if s.status != nil {
+				s.status <- status
+			}
+			s.lock.Unlock()
+		}
+	}()
+	defer func() {
+		close(statuses)
+		<-done
+	}()
+	return call(ctx, statuses)
+}
+
+// detach drops the statuses that are relayed after it.
+func (s *statusRelay) detach() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status = nil
+}
+
+// flights counts the requests that wait for each call, so that the call is canceled when the last of them stops waiting.
+type flights struct {
+	lock    sync.Mutex
+	waiting map[string]*waiters
+}
+
+// waiters are the requests that wait for a call, which runs with ctx. The call is made under key, which is unique to
+// the waiters, so that a request that comes after the call was canceled makes a call of its own instead of sharing it.
+type waiters struct {
+	key    string
+	digest string
+	count  int
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// join adds a request to the waiters of the call of the digest.
+func (f *flights) join(ctx context.Context, digest string) *waiters {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w, ok := f.waiting[digest]
+	if !ok {
+		w = &waiters{digest: digest}
+		w.key = fmt.Sprintf("%s/%p", digest, w)
+		w.ctx, w.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		if f.waiting == nil {
+			f.waiting = map[string]*waiters{}
+		}
+		f.waiting[digest] = w
+	}
+	w.count++
+	return w
+}
+
+// leave removes a request from the waiters, and cancels their call when it was the last of them.
+func (f *flights) leave(w *waiters) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w.count--
+	if w.count > 0 {
+		return
+	}
+	w.cancel()
+	if f.waiting[w.digest] == w {
+		delete(f.waiting, w.digest)
+	}
+}
@@ -0,0 +1,190 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupe(t *testing.T) {
+	var (
+		requests atomic.Int32
+		release  = make(chan struct{})
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"shared\"}}],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":1,\"total_tokens\":11}}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer s.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	var started atomic.Int32
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+		Hooks: &ClientHooks{
+			BeforeRequest: func(context.Context, *openai.ChatCompletionRequest, http.Header) error {
+				started.Add(1)
+				return nil
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	callWithContext := func(ctx context.Context, cache *bool, text string) (*types.CompletionMessage, error) {
+		status := make(chan types.CompletionStatus)
+		go func() {
+			for range status {
+			}
+		}()
+		defer close(status)
+		return c.Call(ctx, types.CompletionRequest{
+			Model:    "gpt-4o",
+			Cache:    cache,
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text(text)}},
+		}, status)
+	}
+	call := func(cache *bool) *types.CompletionMessage {
+		resp, err := callWithContext(context.Background(), cache, "hi")
+		require.NoError(t, err)
+		return resp
+	}
+
+	var (
+		wg    sync.WaitGroup
+		resps = make([]*types.CompletionMessage, 3)
+	)
+	for i := range resps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i] = call(nil)
+		}()
+	}
+	require.Eventually(t, func() bool { return started.Load() == 3 && requests.Load() == 1 }, 5*time.Second, time.Millisecond)
+	// Give the requests that are not sent time to wait for the one that is.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), requests.Load())
+	var usage int
+	for _, resp := range resps {
+		require.Equal(t, "shared", resp.Content[0].Text)
+		usage += resp.Usage.TotalTokens
+	}
+	// Only the request that was sent is charged for.
+	require.Equal(t, 11, usage)
+
+	// Requests that are not cached get responses of their own.
+	noCache := false
+	wg.Add(2)
+	for range 2 {
+		go func() {
+			defer wg.Done()
+			call(&noCache)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(3), requests.Load())
+
+	// The request that made the call stops waiting when it is canceled, and the call goes on for the others.
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := callWithContext(ctx, nil, "bye")
+		canceled <- err
+	}()
+	require.Eventually(t, func() bool { return requests.Load() == 4 }, 5*time.Second, time.Millisecond)
+	shared := make(chan *types.CompletionMessage)
+	go func() {
+		resp, err := callWithContext(context.Background(), nil, "bye")
+		require.NoError(t, err)
+		shared <- resp
+	}()
+	require.Eventually(t, func() bool { return started.Load() == 7 }, 5*time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-canceled, context.Canceled)
+	close(release)
+	require.Equal(t, "shared", (<-shared).Content[0].Text)
+	require.Equal(t, int32(4), requests.Load())
+}
+
+func TestDedupeCancel(t *testing.T) {
+	aborted := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices that the client went away once the body was read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Once\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer s.Close()
+
+	cacheClient, err := cache.New(cache.Options{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		BaseURL:    s.URL,
+		APIKey:     "key",
+		MaxRetries: -1,
+		Cache:      cacheClient,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	status := make(chan types.CompletionStatus)
+	go func() {
+		// The request is canceled once the provider started to respond.
+		for s := range status {
+			if s.PartialResponse != nil && !s.Placeholder {
+				cancel()
+			}
+		}
+	}()
+	defer close(status)
+
+	// A canceled request that no other request shares aborts the call to the provider.
+	_, err = c.Call(ctx, types.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("hi")}},
+	}, status)
+	require.ErrorIs(t, err, context.Canceled)
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the call to the provider was not aborted")
+	}
+	require.Empty(t, c.flights.waiting)
+}
@@ -0,0 +1,63 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// SystemPrompt is the internal system prompt of the options, which clients of other providers send as well.
+type SystemPrompt struct {
+	text     string
+	template *template.Template
+	custom   bool
+}
+
+// NewSystemPrompt returns the internal system prompt, replaced by or appended to with the system prompt of the
+// options. The prompt is only rendered as a template when the options ask for it, so that prompts that happen to
+// contain {{ aren't broken.
+func NewSystemPrompt(opts ...Options) (*SystemPrompt, error) {
+	opt := Complete(opts...)
+
+	prompt := types.FirstSet(opt.InternalSystemPrompt, system.InternalSystemPrompt)
+	if opt.AppendSystemPrompt != "" {
+		prompt = strings.TrimRight(prompt, "\n") + "\n" + opt.AppendSystemPrompt
+	}
+	custom := opt.InternalSystemPrompt != "" || opt.AppendSystemPrompt != ""
+	if !opt.SystemPromptTemplate {
+		return &SystemPrompt{text: prompt, custom: custom}, nil
+	}
+
+	t, err := template.New("system").Option("missingkey=error").Parse(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid internal system prompt: %w", err)
+	}
+	return &SystemPrompt{template: t, custom: custom}, nil
+}
+
+// Custom reports whether the prompt was replaced or appended to by the options. A custom prompt is sent to the models
+// of every provider, including those that don't get the default prompt, such as Ollama and remote providers.
+func (s *SystemPrompt) Custom() bool {
+	return s.custom
+}
+
+// Render renders the internal system prompt for the model.
+func (s *SystemPrompt) Render(model string) (string, error) {
+	if s.template == nil {
+		return s.text, nil
+	}
+
+	buf := &strings.Builder{}
+	err := s.template.Execute(buf, map[string]string{
+		"Model": model,
+		"Date":  time.Now().Format(time.DateOnly),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render internal system prompt: %w", err)
+	}
+	return buf.String(), nil
+}
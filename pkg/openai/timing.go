@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// timer measures how fast a model responds to a request.
+type timer struct {
+	start      time.Time
+	firstToken time.Time
+}
+
+func newTimer() *timer {
+	return &timer{
+		start: time.Now(),
+	}
+}
+
+// received records the time of the first response that has content. Responses before it only have the role, or only
+// the usage.
+func (t *timer) received(response openai.ChatCompletionStreamResponse) {
+	if !t.firstToken.IsZero() {
+		return
+	}
+	for _, choice := range response.Choices {
+		if choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0 || choice.Delta.FunctionCall != nil {
+			t.firstToken = time.Now()
+			return
+		}
+	}
+}
+
+func (t *timer) done() *types.Timing {
+	end := time.Now()
+	firstToken := t.firstToken
+	if firstToken.IsZero() {
+		firstToken = end
+	}
+	return &types.Timing{
+		TimeToFirstToken: firstToken.Sub(t.start),
+		Duration:         end.Sub(t.start),
+	}
+}
+
+// setRate sets the tokens per second of the response. The completion tokens are estimated from the content if the
+// provider didn't report them.
+func setRate(timing *types.Timing, result types.CompletionMessage) {
+	if timing == nil {
+		return
+	}
+
+	timing.CompletionTokens = result.Usage.CompletionTokens
+	if timing.CompletionTokens == 0 {
+		timing.CompletionTokens = countCompletion(result)
+	}
+
+	// Without streaming, all tokens arrive at once, so the rate is over the whole request.
+	generation := timing.Duration - timing.TimeToFirstToken
+	if generation <= 0 {
+		generation = timing.Duration
+	}
+	if generation > 0 {
+		timing.TokensPerSecond = float64(timing.CompletionTokens) / generation.Seconds()
+	}
+}
+
+func countCompletion(result types.CompletionMessage) (count int) {
+	for _, content := range result.Content {
+		count += len(content.Text)
+		if content.ToolCall != nil {
+			count += len(content.ToolCall.Function.Name)
+			count += len(content.ToolCall.Function.Arguments)
+		}
+	}
+	return count / 3
+}
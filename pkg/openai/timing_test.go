@@ -0,0 +1,31 @@
+package openai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRate(t *testing.T) {
+	// The reported usage is preferred, and the rate is after the first token.
+	timing := &types.Timing{TimeToFirstToken: time.Second, Duration: 3 * time.Second}
+	setRate(timing, types.CompletionMessage{
+		Content: types.Text("hello"),
+		Usage:   types.Usage{CompletionTokens: 100},
+	})
+	require.Equal(t, 100, timing.CompletionTokens)
+	require.Equal(t, 50.0, timing.TokensPerSecond)
+
+	// Without usage, the tokens are estimated, and without streaming the rate is over the whole request.
+	timing = &types.Timing{TimeToFirstToken: 2 * time.Second, Duration: 2 * time.Second}
+	setRate(timing, types.CompletionMessage{
+		Content: []types.ContentPart{
+			{Text: "123456"},
+			{ToolCall: &types.CompletionToolCall{Function: types.CompletionFunctionCall{Name: "abc", Arguments: "{}"}}},
+		},
+	})
+	require.Equal(t, 3, timing.CompletionTokens)
+	require.Equal(t, 1.5, timing.TokensPerSecond)
+}
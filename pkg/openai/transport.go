@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+)
+
+// newHTTPTransport returns the transport of the requests to the model provider, which uses the proxy, certificates,
+// and keep alive of the options instead of those of the proxy transport when they are set.
+func newHTTPTransport(opt Options) (http.RoundTripper, error) {
+	if opt.ModelProxy == "" && opt.ModelCACert == "" && opt.ModelClientCert == "" && opt.ModelClientKey == "" && opt.ModelKeepAlive == "" {
+		return proxy.Transport, nil
+	}
+
+	transport := proxy.Transport.Clone()
+
+	if opt.ModelProxy != "" {
+		proxy, err := nurl.Parse(opt.ModelProxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid model proxy %q", opt.ModelProxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opt.ModelKeepAlive != "" {
+		keepAlive, err := time.ParseDuration(opt.ModelKeepAlive)
+		if err != nil || keepAlive < 0 {
+			return nil, fmt.Errorf("invalid model keep alive %q", opt.ModelKeepAlive)
+		}
+		if keepAlive == 0 {
+			transport.DisableKeepAlives = true
+		} else {
+			transport.IdleConnTimeout = keepAlive
+		}
+	}
+
+	if opt.ModelCACert != "" || opt.ModelClientCert != "" || opt.ModelClientKey != "" {
+		tlsConfig, err := newTLSConfig(opt.ModelCACert, opt.ModelClientCert, opt.ModelClientKey)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// newTLSConfig trusts the certificate authorities in the PEM file as well as those of the system, and presents the
+// client certificate for mutual TLS.
+func newTLSConfig(caCert, clientCert, clientKey string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caCert != "" {
+		data, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read model CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in model CA certificate %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("the model client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseModelTimeout returns the time that a model request may take, including reading its response, where 0 is no
+// limit.
+func parseModelTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid model timeout %q", timeout)
+	}
+	return d, nil
+}
+
+// parseHeaders returns the extra headers of model requests, which are each given as "Name: value" like those of curl.
+// A header may also hold several of them on separate lines, such as when they are set with an environment variable.
+func parseHeaders(headers []string) (http.Header, error) {
+	result := http.Header{}
+	for _, header := range headers {
+		for _, line := range strings.Split(header, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(line, ":")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("invalid model header %q, must be \"Name: value\"", line)
+			}
+			result.Set(name, strings.TrimSpace(value))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name, typ string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: data}), 0600))
+	return path
+}
+
+func TestHTTPTransportTLS(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	// The certificate of the server is signed by an authority that the system doesn't trust.
+	_, err := (&http.Client{Transport: http.DefaultTransport}).Get(s.URL)
+	require.Error(t, err)
+
+	caCert := writePEM(t, "ca.pem", "CERTIFICATE", s.Certificate().Raw)
+	transport, err := newHTTPTransport(Options{ModelCACert: caCert})
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: transport}).Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	transport, err = newHTTPTransport(Options{
+		ModelCACert:     caCert,
+		ModelClientCert: writePEM(t, "client.pem", "CERTIFICATE", der),
+		ModelClientKey:  writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER),
+	})
+	require.NoError(t, err)
+	resp, err = (&http.Client{Transport: transport}).Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHTTPTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	transport, err := newHTTPTransport(Options{ModelProxy: proxy.URL, ModelKeepAlive: "0"})
+	require.NoError(t, err)
+	require.True(t, transport.(*http.Transport).DisableKeepAlives)
+
+	resp, err := (&http.Client{Transport: transport}).Get("http://models.internal/v1/models")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, "http://models.internal/v1/models", proxied)
+}
+
+func TestHTTPTransportInvalid(t *testing.T) {
+	transport, err := newHTTPTransport(Options{})
+	require.NoError(t, err)
+	require.Equal(t, proxy.Transport, transport)
+
+	_, err = newHTTPTransport(Options{ModelProxy: "proxy:3128"})
+	require.ErrorContains(t, err, "invalid model proxy")
+
+	_, err = newHTTPTransport(Options{ModelClientCert: "client.pem"})
+	require.ErrorContains(t, err, "must be set together")
+
+	_, err = newHTTPTransport(Options{ModelKeepAlive: "-1s"})
+	require.ErrorContains(t, err, "invalid model keep alive")
+
+	_, err = parseModelTimeout("soon")
+	require.ErrorContains(t, err, "invalid model timeout")
+}
+
+func TestExtraHeaders(t *testing.T) {
+	var header http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_, _ = w.Write([]byte(`{"data": [{"id": "gpt-4o"}]}`))
+	}))
+	defer s.Close()
+
+	c, err := NewClient(context.Background(), credentials.NoopStore{}, Options{
+		APIKey:       "test",
+		BaseURL:      s.URL,
+		ExtraHeaders: []string{"X-Portkey-Api-Key: abc", "anthropic-version: 2023-06-01\nX-Team:  payments "},
+	})
+	require.NoError(t, err)
+
+	models, err := c.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"gpt-4o"}, models)
+	require.Equal(t, "abc", header.Get("X-Portkey-Api-Key"))
+	require.Equal(t, "2023-06-01", header.Get("Anthropic-Version"))
+	require.Equal(t, "payments", header.Get("X-Team"))
+	require.Equal(t, "Bearer test", header.Get("Authorization"))
+
+	_, err = parseHeaders([]string{"X-Portkey-Api-Key=abc"})
+	require.ErrorContains(t, err, "invalid model header")
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// deprecatedDirectives maps the normalized keys of deprecated directives to the names they have now.
+var deprecatedDirectives = map[string]string{
+	"export":         "Share Tools",
+	"exports":        "Share Tools",
+	"exporttool":     "Share Tools",
+	"exporttools":    "Share Tools",
+	"exportcontext":  "Share Context",
+	"exportcontexts": "Share Context",
+	"jsonmode":       "JSON Response",
+	"json":           "JSON Response",
+	"jsonoutput":     "JSON Response",
+	"jsonformat":     "JSON Response",
+}
+
+// Migrate rewrites the deprecated directives and syntax of a gptscript file to their current forms, leaving everything
+// else in the file as it is, and returns the result. The input must be a valid file.
+func Migrate(input string) (string, error) {
+	if _, err := parse(strings.NewReader(input)); err != nil {
+		return "", err
+	}
+
+	var (
+		result    strings.Builder
+		arg       *argDescription
+		inBody    bool
+		skipNode  bool
+		seenParam bool
+	)
+	for i, line := range strings.SplitAfter(input, "\n") {
+		// Lines are matched like the parser sees them, with a newline, but written as they were.
+		text := strings.TrimSuffix(line, "\n") + "\n"
+
+		if arg != nil {
+			if ok, _ := arg.next(text); ok {
+				result.WriteString(line)
+				continue
+			}
+			arg = nil
+		}
+
+		switch {
+		case (skipNode && strictSepRegex.MatchString(text)) || (!skipNode && sepRegex.MatchString(text)):
+			inBody, skipNode, seenParam = false, false, false
+		case skipNode || inBody:
+		case i == 0 && isGPTScriptHashBang(text):
+		case strings.HasPrefix(text, "#") && !strings.HasPrefix(text, "#!"):
+		case !seenParam && skipRegex.MatchString(text):
+			skipNode = true
+		case strings.TrimSpace(text) == "":
+		default:
+			if value, ok := isArg(text); ok {
+				arg, _ = newArgDescription(value, i+1)
+				seenParam = true
+			} else if ok, _ := isParam(text, &types.Tool{}); ok {
+				line = migrateDirective(line)
+				seenParam = true
+			} else {
+				inBody = true
+			}
+		}
+
+		result.WriteString(line)
+	}
+
+	return result.String(), nil
+}
+
+// migrateDirective renames a deprecated directive and drops the trailing ? that system tools used to be referenced with.
+func migrateDirective(line string) string {
+	key, value, _ := strings.Cut(line, ":")
+	normalized := normalize(key)
+	if name, ok := deprecatedDirectives[normalized]; ok {
+		key = key[:len(key)-len(strings.TrimLeft(key, " \t"))] + name
+	}
+
+	switch normalized {
+	case "tool", "tools", "globaltool", "globaltools", "agent", "agents", "context", "export", "exports", "exporttool",
+		"exporttools", "sharetool", "sharetools", "exportcontext", "exportcontexts", "sharecontext", "sharecontexts":
+		refs := csv(value)
+		changed := false
+		for i, ref := range refs {
+			if strings.HasPrefix(ref, "sys.") && strings.HasSuffix(ref, "?") {
+				refs[i] = strings.TrimSuffix(ref, "?")
+				changed = true
+			}
+		}
+		if changed {
+			newline := value[len(strings.TrimRight(value, "\r\n")):]
+			value = " " + strings.Join(refs, ", ") + newline
+		}
+	}
+
+	return key + ":" + value
+}
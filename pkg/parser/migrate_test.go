@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	input := `#!/usr/bin/env gptscript
+name: main
+# the tools shared with callers
+Export: helper, sys.read?
+export context: background
+JSON Mode: true
+tools: sys.write?,  helper
+Args: query: <<END
+Export: this is part of the description
+END
+
+Do the research.
+Export: this is part of the body
+---
+!text
+Export: this is text
+---
+Name: helper
+Tools: other?
+
+JSON Mode is described here.
+`
+
+	result, err := Migrate(input)
+	require.NoError(t, err)
+	require.Equal(t, `#!/usr/bin/env gptscript
+name: main
+# the tools shared with callers
+Share Tools: helper, sys.read
+Share Context: background
+JSON Response: true
+tools: sys.write, helper
+Args: query: <<END
+Export: this is part of the description
+END
+
+Do the research.
+Export: this is part of the body
+---
+!text
+Export: this is text
+---
+Name: helper
+Tools: other?
+
+JSON Mode is described here.
+`, result)
+
+	// A file that is up to date is not changed.
+	again, err := Migrate(result)
+	require.NoError(t, err)
+	require.Equal(t, result, again)
+
+	_, err = Migrate("Name: main\nMax Tokens: many\n")
+	require.Error(t, err)
+}
@@ -2,8 +2,10 @@ package parser
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"regexp"
 	"slices"
 	"strconv"
@@ -17,6 +19,10 @@ var (
 	sepRegex       = regexp.MustCompile(`^\s*---+\s*$`)
 	strictSepRegex = regexp.MustCompile(`^---\n$`)
 	skipRegex      = regexp.MustCompile(`^![-\w]+\s*$`)
+	envVarRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	platformRegex  = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+	sha256Regex    = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	heredocRegex   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 )
 
 func normalize(key string) string {
@@ -42,6 +48,59 @@ func toFloatPtr(line string) (*float32, error) {
 	return &f32, nil
 }
 
+// toFloatInRange parses a float between low and high, inclusive.
+func toFloatInRange(line string, low, high float32) (*float32, error) {
+	f, err := toFloatPtr(line)
+	if err != nil {
+		return nil, err
+	}
+	if *f < low || *f > high {
+		return nil, fmt.Errorf("%s is not between %g and %g", line, low, high)
+	}
+	return f, nil
+}
+
+// maxStopSequences is the most stop sequences that the OpenAI API accepts.
+const maxStopSequences = 4
+
+// parseStop parses a comma-separated list of stop sequences. Sequences with commas, quotes, newlines, or leading or
+// trailing spaces are written as quoted strings, such as "\n\n".
+func parseStop(line string) (result []string, _ error) {
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		var sequence string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stop sequence %s: %w", rest, err)
+			}
+			if sequence, err = strconv.Unquote(quoted); err != nil {
+				return nil, fmt.Errorf("invalid stop sequence %s: %w", quoted, err)
+			}
+			rest = strings.TrimSpace(rest[len(quoted):])
+			if rest != "" && !strings.HasPrefix(rest, ",") {
+				return nil, fmt.Errorf("invalid stop sequences, expected a comma after %s", quoted)
+			}
+		} else {
+			sequence, _, _ = strings.Cut(rest, ",")
+			rest = rest[len(sequence):]
+			sequence = strings.TrimSpace(sequence)
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+		if sequence == "" {
+			return nil, fmt.Errorf("stop sequences must not be empty")
+		}
+		result = append(result, sequence)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("stop sequences must not be empty")
+	}
+	if len(result) > maxStopSequences {
+		return nil, fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	return result, nil
+}
+
 func csv(line string) (result []string) {
 	for _, part := range strings.Split(line, ",") {
 		result = append(result, strings.TrimSpace(part))
@@ -49,22 +108,226 @@ func csv(line string) (result []string) {
 	return
 }
 
-func addArg(line string, tool *types.Tool) error {
-	if tool.Parameters.Arguments == nil {
-		tool.Parameters.Arguments = &openapi3.Schema{
-			Type:       &openapi3.Types{"object"},
-			Properties: openapi3.Schemas{},
+// parseEnvVars parses a list of environment variables such as "FOO (the description), BAR?". The descriptions can
+// contain commas, and a trailing ? makes the variable optional.
+func parseEnvVars(line string) (result []types.EnvVar, _ error) {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, c := range line {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth = max(depth-1, 0)
+		case ',':
+			if depth == 0 {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
 		}
 	}
+	parts = append(parts, line[start:])
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
 
+		var envVar types.EnvVar
+		name, description, hasDescription := strings.Cut(part, "(")
+		if hasDescription {
+			description, ok := strings.CutSuffix(strings.TrimSpace(description), ")")
+			if !ok {
+				return nil, fmt.Errorf("invalid env var %q, the description must be in parentheses", part)
+			}
+			envVar.Description = strings.TrimSpace(description)
+		}
+		name, envVar.Optional = strings.CutSuffix(strings.TrimSpace(name), "?")
+		envVar.Name = strings.TrimSpace(name)
+		if !envVarRegex.MatchString(envVar.Name) {
+			return nil, fmt.Errorf("invalid env var name %q", envVar.Name)
+		}
+		result = append(result, envVar)
+	}
+
+	return result, nil
+}
+
+// parseBinary parses a prebuilt executable for a platform, such as
+// "linux/amd64 https://example.com/tool_linux_amd64.tar.gz sha256:<hex digest>".
+func parseBinary(line string) (types.Binary, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return types.Binary{}, fmt.Errorf("invalid binary %q, expected <os>/<arch> <url> sha256:<digest>", line)
+	}
+
+	binary := types.Binary{
+		Platform: fields[0],
+		URL:      fields[1],
+	}
+	if !platformRegex.MatchString(binary.Platform) {
+		return types.Binary{}, fmt.Errorf("invalid binary platform %q, expected <os>/<arch> such as linux/amd64", binary.Platform)
+	}
+	if u, err := url.Parse(binary.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return types.Binary{}, fmt.Errorf("invalid binary URL %q", binary.URL)
+	}
+	digest, ok := strings.CutPrefix(fields[2], "sha256:")
+	if !ok || !sha256Regex.MatchString(strings.ToLower(digest)) {
+		return types.Binary{}, fmt.Errorf("invalid binary digest %q, expected sha256:<hex digest>", fields[2])
+	}
+	binary.SHA256 = strings.ToLower(digest)
+
+	return binary, nil
+}
+
+// argDescription collects the description of an argument, which can continue on the lines after the Args line in one
+// of three ways:
+//
+//	Args: query: The search query, which can use operators
+//	  such as site: and filetype:, on indented lines
+//	Args: format: "A quoted description, in Go syntax, that
+//	can span lines and contain \"quotes\""
+//	Args: schema: <<EOF
+//	A heredoc that ends with a line of its delimiter.
+//	EOF
+type argDescription struct {
+	key     string
+	lineNo  int
+	lines   []string
+	heredoc string
+	quoted  bool
+	done    bool
+}
+
+func newArgDescription(line string, lineNo int) (*argDescription, error) {
 	key, value, ok := strings.Cut(line, ":")
 	if !ok {
-		return fmt.Errorf("invalid arg format: %s", line)
+		return nil, fmt.Errorf("invalid arg format: %s", line)
+	}
+	value = strings.TrimSpace(value)
+
+	arg := &argDescription{
+		key:    key,
+		lineNo: lineNo,
+	}
+	if delim, ok := strings.CutPrefix(value, "<<"); ok && heredocRegex.MatchString(strings.TrimSpace(delim)) {
+		arg.heredoc = strings.TrimSpace(delim)
+		return arg, nil
+	}
+
+	arg.quoted = strings.HasPrefix(value, `"`)
+	return arg, arg.add(value)
+}
+
+// next adds the line to the description if it continues the description, and returns false otherwise.
+func (a *argDescription) next(line string) (bool, error) {
+	line = strings.TrimSuffix(line, "\n")
+	switch {
+	case a.done:
+		return false, nil
+	case a.heredoc != "":
+		if strings.TrimSpace(line) == a.heredoc {
+			a.done = true
+		} else {
+			a.lines = append(a.lines, line)
+		}
+		return true, nil
+	case a.quoted:
+		return true, a.add(line)
+	case strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t'):
+		return true, a.add(strings.TrimSpace(line))
+	}
+	return false, nil
+}
+
+func (a *argDescription) add(line string) error {
+	a.lines = append(a.lines, line)
+	if !a.quoted {
+		return nil
+	}
+
+	text := strings.Join(a.lines, "\n")
+	end := closingQuote(text)
+	if end < 0 {
+		return nil
+	}
+	if strings.TrimSpace(text[end+1:]) != "" {
+		if len(a.lines) == 1 {
+			// A description is only quoted if all of it is, such as "quoted" but not "quoted" words.
+			a.quoted = false
+			return nil
+		}
+		return fmt.Errorf("unexpected text after the quoted description of argument %s", a.key)
+	}
+	a.done = true
+	return nil
+}
+
+// closingQuote returns the index of the quote that closes the string that starts with a quote, or -1 if it isn't closed.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *argDescription) addTo(tool *types.Tool) error {
+	switch {
+	case a.heredoc != "":
+		if !a.done {
+			return fmt.Errorf("argument %s is missing the end of its description, %s", a.key, a.heredoc)
+		}
+		return addArg(a.key, strings.TrimSpace(strings.Join(a.lines, "\n")), tool)
+	case a.quoted:
+		if !a.done {
+			return fmt.Errorf("argument %s is missing the closing quote of its description", a.key)
+		}
+		// Quoted descriptions can contain newlines, which Go strings can't.
+		description, err := strconv.Unquote(strings.ReplaceAll(strings.TrimSpace(strings.Join(a.lines, "\n")), "\n", `\n`))
+		if err != nil {
+			return fmt.Errorf("invalid quoted description of argument %s: %w", a.key, err)
+		}
+		return addArg(a.key, description, tool)
+	}
+
+	description := strings.TrimSpace(strings.Join(a.lines, " "))
+
+	// Parameters filled from a credential are not added to the arguments, so that the LLM never sees them.
+	if from, ok := strings.CutPrefix(description, "from:"); ok {
+		source, ok := strings.CutPrefix(strings.TrimSpace(from), types.CredentialArgumentPrefix)
+		if credName, _ := types.ParseCredentialArgument(source); !ok || credName == "" {
+			return fmt.Errorf("invalid source of parameter %s, expected %s<name>: %s", a.key, types.CredentialArgumentPrefix, strings.TrimSpace(from))
+		}
+		if tool.Parameters.CredentialArguments == nil {
+			tool.Parameters.CredentialArguments = map[string]string{}
+		}
+		tool.Parameters.CredentialArguments[a.key] = strings.TrimSpace(source)
+		return nil
+	}
+
+	return addArg(a.key, description, tool)
+}
+
+func addArg(key, description string, tool *types.Tool) error {
+	if tool.Parameters.Arguments == nil {
+		tool.Parameters.Arguments = &openapi3.Schema{
+			Type:       &openapi3.Types{"object"},
+			Properties: openapi3.Schemas{},
+		}
 	}
 
 	tool.Parameters.Arguments.Properties[key] = &openapi3.SchemaRef{
 		Value: &openapi3.Schema{
-			Description: strings.TrimSpace(value),
+			Description: description,
 			Type:        &openapi3.Types{"string"},
 		},
 	}
@@ -72,6 +335,19 @@ func addArg(line string, tool *types.Tool) error {
 	return nil
 }
 
+// isArg returns the value of the line if it is an Args line.
+func isArg(line string) (string, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", false
+	}
+	switch normalize(key) {
+	case "args", "arg", "param", "params", "parameters", "parameter":
+		return strings.TrimSpace(value), true
+	}
+	return "", false
+}
+
 func isParam(line string, tool *types.Tool) (_ bool, err error) {
 	key, value, ok := strings.Cut(line, ":")
 	if !ok {
@@ -101,6 +377,17 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 			return false, err
 		}
 		tool.Parameters.Chat = v
+	case "type":
+		// Any other value is the start of the instructions, such as "Type: a haiku about the sea".
+		if normalize(value) != types.ToolTypeAgents {
+			return false, nil
+		}
+		tool.Parameters.Type = types.ToolTypeAgents
+	case "breakpoint":
+		tool.Parameters.Breakpoint, err = toBool(value)
+		if err != nil {
+			return false, err
+		}
 	case "export", "exporttool", "exports", "exporttools", "sharetool", "sharetools":
 		tool.Parameters.Export = append(tool.Parameters.Export, csv(value)...)
 	case "tool", "tools":
@@ -121,10 +408,8 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		tool.Parameters.ExportContext = append(tool.Parameters.ExportContext, csv(value)...)
 	case "context":
 		tool.Parameters.Context = append(tool.Parameters.Context, csv(value)...)
-	case "args", "arg", "param", "params", "parameters", "parameter":
-		if err := addArg(value, tool); err != nil {
-			return false, err
-		}
+	case "mixin", "mixins":
+		tool.Parameters.Mixins = append(tool.Parameters.Mixins, csv(value)...)
 	case "maxtoken", "maxtokens":
 		tool.Parameters.MaxTokens, err = strconv.Atoi(value)
 		if err != nil {
@@ -137,10 +422,66 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		}
 		tool.Parameters.Cache = &b
 	case "jsonmode", "json", "jsonoutput", "jsonformat", "jsonresponse":
+		if strings.HasPrefix(value, "{") {
+			// A JSON schema of the response is the same as an inline validate schema with JSON Response: true.
+			if tool.Parameters.Validate != "" {
+				return false, fmt.Errorf("only one validate directive is allowed")
+			}
+			if err := json.Unmarshal([]byte(value), &openapi3.Schema{}); err != nil {
+				return false, fmt.Errorf("invalid JSON response schema: %w", err)
+			}
+			tool.Parameters.JSONResponse = true
+			tool.Parameters.Validate = value
+			break
+		}
 		tool.Parameters.JSONResponse, err = toBool(value)
 		if err != nil {
 			return false, err
 		}
+	case "topp":
+		tool.Parameters.TopP, err = toFloatInRange(value, 0, 1)
+		if err != nil {
+			return false, fmt.Errorf("invalid top p: %w", err)
+		}
+	case "stop", "stopsequence", "stopsequences":
+		tool.Parameters.Stop, err = parseStop(value)
+		if err != nil {
+			return false, err
+		}
+	case "frequencypenalty":
+		tool.Parameters.FrequencyPenalty, err = toFloatInRange(value, -2, 2)
+		if err != nil {
+			return false, fmt.Errorf("invalid frequency penalty: %w", err)
+		}
+	case "presencepenalty":
+		tool.Parameters.PresencePenalty, err = toFloatInRange(value, -2, 2)
+		if err != nil {
+			return false, fmt.Errorf("invalid presence penalty: %w", err)
+		}
+	case "paralleltoolcalls", "paralleltools":
+		b, err := toBool(value)
+		if err != nil {
+			return false, err
+		}
+		tool.Parameters.ParallelToolCalls = &b
+	case "toolchoice":
+		switch strings.ToLower(value) {
+		case types.ToolChoiceAuto, types.ToolChoiceNone, types.ToolChoiceRequired:
+			tool.Parameters.ToolChoice = strings.ToLower(value)
+		default:
+			tool.Parameters.ToolChoice = value
+		}
+	case "logprobs":
+		tool.Parameters.LogProbs, err = toBool(value)
+		if err != nil {
+			return false, err
+		}
+	case "toplogprobs":
+		tool.Parameters.TopLogProbs, err = strconv.Atoi(value)
+		if err != nil || tool.Parameters.TopLogProbs < 0 || tool.Parameters.TopLogProbs > 20 {
+			return false, fmt.Errorf("invalid top logprobs %q, must be a number between 0 and 20", value)
+		}
+		tool.Parameters.LogProbs = true
 	case "temperature":
 		tool.Parameters.Temperature, err = toFloatPtr(value)
 		if err != nil {
@@ -148,6 +489,132 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		}
 	case "credentials", "creds", "credential", "cred":
 		tool.Parameters.Credentials = append(tool.Parameters.Credentials, value)
+	case "binary", "binaries":
+		binary, err := parseBinary(value)
+		if err != nil {
+			return false, err
+		}
+		tool.Parameters.Binaries = append(tool.Parameters.Binaries, binary)
+	case "envvar", "envvars":
+		envVars, err := parseEnvVars(value)
+		if err != nil {
+			return false, err
+		}
+		tool.Parameters.EnvVars = append(tool.Parameters.EnvVars, envVars...)
+	case "example", "examples":
+		// Without an output, the line is the start of the instructions, such as "Example: summarize the input".
+		input, output, ok := strings.Cut(value, " => ")
+		if !ok || strings.TrimSpace(output) == "" {
+			return false, nil
+		}
+		if n := len(tool.Parameters.Examples); n > 0 && tool.Parameters.Examples[n-1].Output == "" {
+			return false, fmt.Errorf("example input must be followed by an example output")
+		}
+		tool.Parameters.Examples = append(tool.Parameters.Examples, types.Example{
+			Input:  strings.TrimSpace(input),
+			Output: strings.TrimSpace(output),
+		})
+	case "exampleinput":
+		if n := len(tool.Parameters.Examples); n > 0 && tool.Parameters.Examples[n-1].Output == "" {
+			return false, fmt.Errorf("example input must be followed by an example output")
+		}
+		tool.Parameters.Examples = append(tool.Parameters.Examples, types.Example{
+			Input: value,
+		})
+	case "exampleoutput":
+		n := len(tool.Parameters.Examples)
+		if n == 0 || tool.Parameters.Examples[n-1].Output != "" {
+			return false, fmt.Errorf("example output must follow an example input")
+		}
+		if value == "" {
+			return false, fmt.Errorf("example output must not be empty")
+		}
+		tool.Parameters.Examples[n-1].Output = value
+	case "artifact", "artifacts":
+		for _, pattern := range csv(value) {
+			if err := types.ValidateArtifactPattern(pattern); err != nil {
+				return false, err
+			}
+			tool.Parameters.Artifacts = append(tool.Parameters.Artifacts, pattern)
+		}
+	case "validate", "validator":
+		if tool.Parameters.Validate != "" {
+			return false, fmt.Errorf("only one validate directive is allowed")
+		}
+		tool.Parameters.Validate = value
+		if tool.Parameters.ValidateSchema() {
+			if err := json.Unmarshal([]byte(value), &openapi3.Schema{}); err != nil {
+				return false, fmt.Errorf("invalid validate JSON schema: %w", err)
+			}
+		}
+	case "outputregex":
+		if _, err := regexp.Compile(value); err != nil {
+			return false, fmt.Errorf("invalid output regex: %w", err)
+		}
+		tool.Parameters.OutputRegex = value
+	case "outputgrammar":
+		tool.Parameters.OutputGrammar = value
+	case "choices":
+		tool.Parameters.Choices, err = strconv.Atoi(value)
+		if err != nil {
+			return false, err
+		}
+		if tool.Parameters.Choices < 0 {
+			return false, fmt.Errorf("choices must not be negative")
+		}
+	case "bestof":
+		// Best Of: 5 judge=scorer-tool
+		count, judge, _ := strings.Cut(strings.TrimSpace(value), " ")
+		tool.Parameters.BestOf, err = strconv.Atoi(count)
+		if err != nil {
+			return false, fmt.Errorf("invalid best of %q, must be a number optionally followed by judge=<tool>", value)
+		}
+		if tool.Parameters.BestOf < 0 {
+			return false, fmt.Errorf("best of must not be negative")
+		}
+		if judge = strings.TrimSpace(judge); judge != "" {
+			name, ok := strings.CutPrefix(judge, "judge=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return false, fmt.Errorf("invalid best of %q, must be a number optionally followed by judge=<tool>", value)
+			}
+			if tool.Parameters.Choose != "" {
+				return false, fmt.Errorf("only one choose directive is allowed")
+			}
+			tool.Parameters.Choose = strings.TrimSpace(name)
+		}
+	case "choose":
+		if tool.Parameters.Choose != "" {
+			return false, fmt.Errorf("only one choose directive is allowed")
+		}
+		tool.Parameters.Choose = value
+		if types.IsChooseStrategy(value) {
+			tool.Parameters.Choose = strings.ToLower(value)
+		}
+	case "imagearg", "imageargs", "imageparam", "imageparams", "imageparameter", "imageparameters":
+		for _, name := range csv(value) {
+			if name == "" {
+				return false, fmt.Errorf("image args must not be empty")
+			}
+			tool.Parameters.ImageArguments = append(tool.Parameters.ImageArguments, name)
+		}
+	case "workingdir", "workingdirectory", "workdir":
+		tool.Parameters.WorkDir = value
+	case "filearg", "fileargs", "fileparam", "fileparams", "fileparameter", "fileparameters":
+		for _, name := range csv(value) {
+			if name == "" {
+				return false, fmt.Errorf("file args must not be empty")
+			}
+			tool.Parameters.FileArguments = append(tool.Parameters.FileArguments, name)
+		}
+	case "validateretries", "validateretry":
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			return false, err
+		}
+		if retries < 0 {
+			return false, fmt.Errorf("validate retries must not be negative")
+		}
+		tool.Parameters.ValidateRetries = &retries
 	default:
 		return false, nil
 	}
@@ -187,10 +654,34 @@ type context struct {
 	skipNode     bool
 	skipLines    []string
 	seenParam    bool
+	arg          *argDescription
 }
 
-func (c *context) finish(tools *[]Node) {
+// finishArg adds the argument whose description was being collected to the tool.
+func (c *context) finishArg() error {
+	if c.arg == nil {
+		return nil
+	}
+	arg := c.arg
+	c.arg = nil
+	if err := arg.addTo(&c.tool); err != nil {
+		return NewErrLine("", arg.lineNo, err)
+	}
+	return nil
+}
+
+func (c *context) finish(fn func(Node) error) error {
 	c.tool.Instructions = strings.TrimSpace(strings.Join(c.instructions, ""))
+	for _, name := range c.tool.Parameters.ImageArguments {
+		if c.tool.Parameters.Arguments == nil || c.tool.Parameters.Arguments.Properties[name] == nil {
+			return fmt.Errorf("image arg %s of tool %s is not a parameter of the tool", name, c.tool.Parameters.Name)
+		}
+	}
+	for _, name := range c.tool.Parameters.FileArguments {
+		if c.tool.Parameters.Arguments == nil || c.tool.Parameters.Arguments.Properties[name] == nil {
+			return fmt.Errorf("file arg %s of tool %s is not a parameter of the tool", name, c.tool.Parameters.Name)
+		}
+	}
 	if c.tool.Instructions != "" ||
 		c.tool.Parameters.Name != "" ||
 		len(c.tool.Export) > 0 ||
@@ -200,20 +691,25 @@ func (c *context) finish(tools *[]Node) {
 		len(c.tool.ExportInputFilters) > 0 ||
 		len(c.tool.ExportOutputFilters) > 0 ||
 		c.tool.Chat {
-		*tools = append(*tools, Node{
+		if err := fn(Node{
 			ToolNode: &ToolNode{
 				Tool: c.tool,
 			},
-		})
+		}); err != nil {
+			return err
+		}
 	}
 	if c.skipNode && len(c.skipLines) > 0 {
-		*tools = append(*tools, Node{
+		if err := fn(Node{
 			TextNode: &TextNode{
 				Text: strings.Join(c.skipLines, ""),
 			},
-		})
+		}); err != nil {
+			return err
+		}
 	}
 	*c = context{}
+	return nil
 }
 
 type Options struct {
@@ -354,6 +850,32 @@ func Parse(input io.Reader, opts ...Options) (Document, error) {
 	}, nil
 }
 
+// ParseStream calls fn with each node of the input as soon as it is parsed, so that large files don't have to be held in
+// memory as a whole. Assigning globals needs every tool of the file, so with AssignGlobals the whole input is parsed
+// before fn is called. An error returned by fn stops the parsing and is returned.
+func ParseStream(input io.Reader, fn func(Node) error, opts ...Options) error {
+	opt := complete(opts...)
+	if opt.AssignGlobals {
+		doc, err := Parse(input, opt)
+		if err != nil {
+			return err
+		}
+		for _, node := range doc.Nodes {
+			if err := fn(node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return parseStream(input, func(node Node) error {
+		if node.ToolNode != nil && node.ToolNode.Tool.Source.Location == "" {
+			node.ToolNode.Tool.Source.Location = opt.Location
+		}
+		return fn(node)
+	})
+}
+
 func isGPTScriptHashBang(line string) bool {
 	if !strings.HasPrefix(line, "#!") {
 		return false
@@ -378,11 +900,19 @@ func isGPTScriptHashBang(line string) bool {
 	return false
 }
 
-func parse(input io.Reader) ([]Node, error) {
+func parse(input io.Reader) (nodes []Node, _ error) {
+	err := parseStream(input, func(node Node) error {
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, err
+}
+
+// parseStream calls fn with each node as soon as it is parsed.
+func parseStream(input io.Reader, fn func(Node) error) error {
 	scan := bufio.NewScanner(input)
 
 	var (
-		tools   []Node
 		context context
 		lineNo  int
 	)
@@ -395,13 +925,28 @@ func parse(input io.Reader) ([]Node, error) {
 
 		line := scan.Text() + "\n"
 
+		if context.arg != nil {
+			if ok, err := context.arg.next(line); err != nil {
+				return NewErrLine("", lineNo, err)
+			} else if ok {
+				continue
+			}
+			if err := context.finishArg(); err != nil {
+				return err
+			}
+		}
+
 		if context.skipNode {
 			if strictSepRegex.MatchString(line) {
-				context.finish(&tools)
+				if err := context.finish(fn); err != nil {
+					return err
+				}
 				continue
 			}
 		} else if sepRegex.MatchString(line) {
-			context.finish(&tools)
+			if err := context.finish(fn); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -432,9 +977,19 @@ func parse(input io.Reader) ([]Node, error) {
 				continue
 			}
 
+			if value, ok := isArg(line); ok {
+				arg, err := newArgDescription(value, lineNo)
+				if err != nil {
+					return NewErrLine("", lineNo, err)
+				}
+				context.arg = arg
+				context.seenParam = true
+				continue
+			}
+
 			// Look for params
 			if isParam, err := isParam(line, &context.tool); err != nil {
-				return nil, NewErrLine("", lineNo, err)
+				return NewErrLine("", lineNo, err)
 			} else if isParam {
 				context.seenParam = true
 				continue
@@ -445,6 +1000,8 @@ func parse(input io.Reader) ([]Node, error) {
 		context.instructions = append(context.instructions, line)
 	}
 
-	context.finish(&tools)
-	return tools, nil
+	if err := context.finishArg(); err != nil {
+		return err
+	}
+	return context.finish(fn)
 }
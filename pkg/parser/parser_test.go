@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -239,3 +240,383 @@ share output filters: shared
 		}},
 	}}).Equal(t, out)
 }
+
+func TestParseExamples(t *testing.T) {
+	input := `
+examples: {"city": "Paris"} => Sunny, 20C
+example input: {"city": "Oslo"}
+example output: Snow, -5C
+
+Report the weather
+`
+	out, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	autogold.Expect(Document{Nodes: []Node{
+		{ToolNode: &ToolNode{
+			Tool: types.Tool{
+				ToolDef: types.ToolDef{
+					Parameters: types.Parameters{
+						Examples: []types.Example{
+							{
+								Input:  `{"city": "Paris"}`,
+								Output: "Sunny, 20C",
+							},
+							{
+								Input:  `{"city": "Oslo"}`,
+								Output: "Snow, -5C",
+							},
+						},
+					},
+					Instructions: "Report the weather",
+				},
+				Source: types.ToolSource{LineNo: 1},
+			},
+		}},
+	}}).Equal(t, out)
+
+	// Examples are printed in the short form unless their input contains the separator.
+	require.Contains(t, out.Nodes[0].ToolNode.Tool.String(), `Examples: {"city": "Paris"} => Sunny, 20C`+"\nExamples: ")
+
+	// A line without an output is an instruction, like it was before the directive.
+	out, err = Parse(strings.NewReader("name: a\n\nExample: summarize the input briefly\n"))
+	require.NoError(t, err)
+	require.Empty(t, out.Nodes[0].ToolNode.Tool.Examples)
+	require.Equal(t, "Example: summarize the input briefly", out.Nodes[0].ToolNode.Tool.Instructions)
+
+	_, err = Parse(strings.NewReader("example output: alone\n"))
+	require.Error(t, err)
+
+	_, err = Parse(strings.NewReader("example input: one\nexample input: two\n"))
+	require.Error(t, err)
+}
+
+func TestParseArtifacts(t *testing.T) {
+	input := `
+artifacts: report.md, charts/*.png
+
+Write the report
+`
+	out, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	autogold.Expect(Document{Nodes: []Node{
+		{ToolNode: &ToolNode{
+			Tool: types.Tool{
+				ToolDef: types.ToolDef{
+					Parameters: types.Parameters{
+						Artifacts: []string{"report.md", "charts/*.png"},
+					},
+					Instructions: "Write the report",
+				},
+				Source: types.ToolSource{LineNo: 1},
+			},
+		}},
+	}}).Equal(t, out)
+
+	_, err = Parse(strings.NewReader("artifacts: ../secrets.txt\n"))
+	require.Error(t, err)
+
+	_, err = Parse(strings.NewReader("artifacts: /etc/passwd\n"))
+	require.Error(t, err)
+}
+
+func TestParseJSONResponseSchema(t *testing.T) {
+	out, err := Parse(strings.NewReader("json response: {\"type\": \"object\", \"required\": [\"name\"]}\n\nDescribe the user\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.True(t, tool.JSONResponse)
+	require.Equal(t, `{"type": "object", "required": ["name"]}`, tool.Validate)
+
+	_, err = Parse(strings.NewReader("validate: checker\njson response: {\"type\": \"object\"}\n\nDescribe the user\n"))
+	require.ErrorContains(t, err, "only one validate directive is allowed")
+
+	_, err = Parse(strings.NewReader("json response: {\"type\": 1}\n\nDescribe the user\n"))
+	require.ErrorContains(t, err, "invalid JSON response schema")
+}
+
+func TestParseSamplingParameters(t *testing.T) {
+	out, err := Parse(strings.NewReader("top p: 0.9\nstop: END, \"\\n\\n\", \"a, b\"\nfrequency penalty: 0.5\npresence penalty: -1\n\nWrite a poem\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, float32(0.9), *tool.TopP)
+	require.Equal(t, []string{"END", "\n\n", "a, b"}, tool.Stop)
+	require.Equal(t, float32(0.5), *tool.FrequencyPenalty)
+	require.Equal(t, float32(-1), *tool.PresencePenalty)
+
+	// The stop sequences are written so that they are parsed back as they are.
+	out, err = Parse(strings.NewReader(tool.String()))
+	require.NoError(t, err)
+	require.Equal(t, tool.Stop, out.Nodes[0].ToolNode.Tool.Stop)
+
+	for _, line := range []string{"top p: 1.5", "presence penalty: 3", "stop: a, b, c, d, e", "stop: a,,b", `stop: "a" b`} {
+		_, err = Parse(strings.NewReader(line + "\n\nWrite a poem\n"))
+		require.Error(t, err, line)
+	}
+}
+
+func TestParseParallelToolCalls(t *testing.T) {
+	out, err := Parse(strings.NewReader("tools: deploy, migrate\nparallel tool calls: false\n\nDeploy the service\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.False(t, *tool.ParallelToolCalls)
+	require.Contains(t, tool.String(), "Parallel Tool Calls: false\n")
+
+	out, err = Parse(strings.NewReader("tools: deploy\n\nDeploy the service\n"))
+	require.NoError(t, err)
+	require.Nil(t, out.Nodes[0].ToolNode.Tool.ParallelToolCalls)
+}
+
+func TestParseToolChoice(t *testing.T) {
+	out, err := Parse(strings.NewReader("tools: search, summarize\ntool choice: Required\n\nResearch the topic\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, types.ToolChoiceRequired, tool.ToolChoice)
+	require.Contains(t, tool.String(), "Tool Choice: required\n")
+
+	out, err = Parse(strings.NewReader("tools: search, summarize\ntool choice: search\n\nResearch the topic\n"))
+	require.NoError(t, err)
+	require.Equal(t, "search", out.Nodes[0].ToolNode.Tool.ToolChoice)
+}
+
+func TestParseLogProbs(t *testing.T) {
+	out, err := Parse(strings.NewReader("logprobs: true\n\nClassify the review\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.True(t, tool.LogProbs)
+	require.Contains(t, tool.String(), "Logprobs: true\n")
+
+	// Top logprobs imply logprobs.
+	out, err = Parse(strings.NewReader("top logprobs: 5\n\nClassify the review\n"))
+	require.NoError(t, err)
+	tool = out.Nodes[0].ToolNode.Tool
+	require.True(t, tool.LogProbs)
+	require.Equal(t, 5, tool.TopLogProbs)
+	require.Contains(t, tool.String(), "Top Logprobs: 5\n")
+
+	_, err = Parse(strings.NewReader("top logprobs: 21\n\nClassify the review\n"))
+	require.ErrorContains(t, err, "invalid top logprobs")
+}
+
+func TestParseBestOf(t *testing.T) {
+	out, err := Parse(strings.NewReader("best of: 5 judge=scorer\n\nWrite a slogan\n"))
+	require.NoError(t, err)
+	require.Equal(t, 5, out.Nodes[0].ToolNode.Tool.BestOf)
+	require.Equal(t, "scorer", out.Nodes[0].ToolNode.Tool.Choose)
+
+	out, err = Parse(strings.NewReader("choices: 3\nchoose: Majority\n\nClassify the input\n"))
+	require.NoError(t, err)
+	require.Equal(t, 3, out.Nodes[0].ToolNode.Tool.Choices)
+	require.Equal(t, types.ChooseMajority, out.Nodes[0].ToolNode.Tool.Choose)
+
+	_, err = Parse(strings.NewReader("best of: 5 scorer\n"))
+	require.ErrorContains(t, err, "must be a number optionally followed by judge=<tool>")
+
+	_, err = Parse(strings.NewReader("choose: shortest\nbest of: 5 judge=scorer\n"))
+	require.ErrorContains(t, err, "only one choose directive is allowed")
+}
+
+func TestParseValidateRetries(t *testing.T) {
+	// Zero retries is kept, so that the first invalid response fails the tool.
+	out, err := Parse(strings.NewReader("validate: check\nvalidate retries: 0\n\nSay OK\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.NotNil(t, tool.ValidateRetries)
+	require.Equal(t, 0, *tool.ValidateRetries)
+	require.Contains(t, tool.String(), "Validate Retries: 0\n")
+
+	out, err = Parse(strings.NewReader("validate: check\n\nSay OK\n"))
+	require.NoError(t, err)
+	require.Nil(t, out.Nodes[0].ToolNode.Tool.ValidateRetries)
+
+	_, err = Parse(strings.NewReader("validate retries: -1\n"))
+	require.ErrorContains(t, err, "validate retries must not be negative")
+}
+
+func TestParseCredentialArguments(t *testing.T) {
+	out, err := Parse(strings.NewReader("param: repo: The repository\nparam: token: from: credential:github-token\n#!/bin/bash\necho ${repo}\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, map[string]string{"token": "github-token"}, tool.CredentialArguments)
+	require.Len(t, tool.Arguments.Properties, 1)
+	require.Contains(t, tool.String(), "Parameter: repo: The repository\nParameter: token: from: credential:github-token\n")
+
+	_, err = Parse(strings.NewReader("param: token: from: env:GITHUB_TOKEN\n"))
+	require.ErrorContains(t, err, "invalid source of parameter token, expected credential:<name>")
+}
+
+func TestParseEnvVars(t *testing.T) {
+	out, err := Parse(strings.NewReader("env vars: API_URL (the URL of the API, including the scheme), DEBUG?\nenv var: API_KEY? (only for private instances)\n#!/bin/bash\necho ${API_URL}\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, []types.EnvVar{
+		{Name: "API_URL", Description: "the URL of the API, including the scheme"},
+		{Name: "DEBUG", Optional: true},
+		{Name: "API_KEY", Description: "only for private instances", Optional: true},
+	}, tool.EnvVars)
+	require.Contains(t, tool.String(), "Env Vars: API_URL (the URL of the API, including the scheme), DEBUG?, API_KEY? (only for private instances)\n")
+
+	_, err = Parse(strings.NewReader("env vars: API-URL\n"))
+	require.ErrorContains(t, err, `invalid env var name "API-URL"`)
+
+	_, err = Parse(strings.NewReader("env vars: API_URL (the URL\n"))
+	require.ErrorContains(t, err, "the description must be in parentheses")
+}
+
+func TestParseImageArgs(t *testing.T) {
+	out, err := Parse(strings.NewReader("image args: screenshot\nargs: screenshot: the screenshot of the page\nargs: question: what to ask about the page\n\nAnswer the question\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, []string{"screenshot"}, tool.ImageArguments)
+	require.Contains(t, tool.String(), "Image Args: screenshot\n")
+
+	_, err = Parse(strings.NewReader("image args: screenshot\n\nDescribe the screenshot\n"))
+	require.ErrorContains(t, err, "image arg screenshot")
+}
+
+func TestParseFileArgs(t *testing.T) {
+	out, err := Parse(strings.NewReader("file args: report, chart\nargs: report: the report to summarize\nargs: chart: a chart of the report\n\n#!/usr/bin/env python3 summarize.py\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, []string{"report", "chart"}, tool.FileArguments)
+	require.Contains(t, tool.String(), "File Args: report, chart\n")
+
+	_, err = Parse(strings.NewReader("file args: report\n\n#!/usr/bin/env python3 summarize.py\n"))
+	require.ErrorContains(t, err, "file arg report")
+}
+
+func TestParseWorkingDir(t *testing.T) {
+	out, err := Parse(strings.NewReader("working dir: ${GPTSCRIPT_WORKSPACE_DIR}/data\ntools: sys.read, sys.write\n\nSummarize notes.txt\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, "${GPTSCRIPT_WORKSPACE_DIR}/data", tool.WorkDir)
+	require.Contains(t, tool.String(), "Working Dir: ${GPTSCRIPT_WORKSPACE_DIR}/data\n")
+}
+
+func TestParseType(t *testing.T) {
+	out, err := Parse(strings.NewReader("agents: billing, shipping\ntype: Agents\n\nQuestions about refunds go to billing.\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.True(t, tool.IsAgents())
+	require.Contains(t, tool.String(), "Type: agents\n")
+
+	// Any other type is an instruction, like it was before the directive.
+	out, err = Parse(strings.NewReader("name: a\n\nType: a haiku about the sea\n"))
+	require.NoError(t, err)
+	tool = out.Nodes[0].ToolNode.Tool
+	require.False(t, tool.IsAgents())
+	require.Equal(t, "Type: a haiku about the sea", tool.Instructions)
+}
+
+func TestParseBinaries(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	out, err := Parse(strings.NewReader("binary: linux/amd64 https://example.com/tool_linux_amd64.tar.gz sha256:" + digest + "\nbinary: darwin/arm64 https://example.com/tool_darwin_arm64 sha256:" + strings.ToUpper(digest) + "\n#!tool ${input}\n"))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, []types.Binary{
+		{Platform: "linux/amd64", URL: "https://example.com/tool_linux_amd64.tar.gz", SHA256: digest},
+		{Platform: "darwin/arm64", URL: "https://example.com/tool_darwin_arm64", SHA256: digest},
+	}, tool.Binaries)
+	require.Contains(t, tool.String(), "Binary: linux/amd64 https://example.com/tool_linux_amd64.tar.gz sha256:"+digest+"\n")
+
+	_, err = Parse(strings.NewReader("binary: linux https://example.com/tool sha256:" + digest + "\n"))
+	require.ErrorContains(t, err, `invalid binary platform "linux"`)
+
+	_, err = Parse(strings.NewReader("binary: linux/amd64 https://example.com/tool md5:abc\n"))
+	require.ErrorContains(t, err, `invalid binary digest "md5:abc"`)
+
+	_, err = Parse(strings.NewReader("binary: linux/amd64 https://example.com/tool\n"))
+	require.ErrorContains(t, err, "expected <os>/<arch> <url> sha256:<digest>")
+}
+
+func TestParseMultiLineArgs(t *testing.T) {
+	out, err := Parse(strings.NewReader(`Name: search
+Args: query: The search query, which can use operators
+  such as site: and filetype:,
+	to narrow the results
+Args: format: "One of: json, yaml, or text.
+Defaults to \"text\"."
+Args: schema: <<EOF
+The JSON schema of the results:
+
+  {"type": "object"}
+EOF
+Args: limit: The maximum number of results
+
+Search for the query.
+`))
+	require.NoError(t, err)
+	tool := out.Nodes[0].ToolNode.Tool
+	require.Equal(t, "Search for the query.", tool.Instructions)
+	require.Len(t, tool.Arguments.Properties, 4)
+	require.Equal(t, "The search query, which can use operators such as site: and filetype:, to narrow the results", tool.Arguments.Properties["query"].Value.Description)
+	require.Equal(t, "One of: json, yaml, or text.\nDefaults to \"text\".", tool.Arguments.Properties["format"].Value.Description)
+	require.Equal(t, "The JSON schema of the results:\n\n  {\"type\": \"object\"}", tool.Arguments.Properties["schema"].Value.Description)
+	require.Equal(t, "The maximum number of results", tool.Arguments.Properties["limit"].Value.Description)
+
+	// Descriptions that span lines are quoted when the tool is printed, so that they are parsed back the same.
+	again, err := ParseTools(strings.NewReader(tool.String()))
+	require.NoError(t, err)
+	require.Equal(t, tool.Arguments, again[0].Arguments)
+
+	_, err = Parse(strings.NewReader("Args: format: \"json or yaml\n\nBody\n"))
+	require.ErrorContains(t, err, "line 1: argument format is missing the closing quote of its description")
+
+	_, err = Parse(strings.NewReader("Args: schema: <<EOF\nThe schema\n"))
+	require.ErrorContains(t, err, "argument schema is missing the end of its description, EOF")
+
+	_, err = Parse(strings.NewReader("Args: format: \"json\nor\" yaml\n"))
+	require.ErrorContains(t, err, "unexpected text after the quoted description of argument format")
+
+	// Descriptions that only start with a quoted word are not quoted.
+	out, err = Parse(strings.NewReader("Name: convert\nArgs: format: \"json\" or \"yaml\"\n  for the output\n\nConvert the file.\n"))
+	require.NoError(t, err)
+	require.Equal(t, `"json" or "yaml" for the output`, out.Nodes[0].ToolNode.Tool.Arguments.Properties["format"].Value.Description)
+}
+
+func TestParseStream(t *testing.T) {
+	input := `Name: first
+Global Tools: helper
+
+First.
+---
+!text
+Some text.
+---
+Name: second
+
+Second.
+`
+
+	var nodes []Node
+	err := ParseStream(strings.NewReader(input), func(node Node) error {
+		nodes = append(nodes, node)
+		return nil
+	}, Options{Location: "tools.gpt"})
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	require.Equal(t, "first", nodes[0].ToolNode.Tool.Name)
+	require.Equal(t, "tools.gpt", nodes[0].ToolNode.Tool.Source.Location)
+	require.Equal(t, "!text\nSome text.\n", nodes[1].TextNode.Text)
+	require.Equal(t, "second", nodes[2].ToolNode.Tool.Name)
+	require.Empty(t, nodes[2].ToolNode.Tool.Tools)
+
+	// The nodes are the same as a parsed document, including assigned globals.
+	nodes = nil
+	err = ParseStream(strings.NewReader(input), func(node Node) error {
+		nodes = append(nodes, node)
+		return nil
+	}, Options{AssignGlobals: true})
+	require.NoError(t, err)
+	doc, err := Parse(strings.NewReader(input), Options{AssignGlobals: true})
+	require.NoError(t, err)
+	require.Equal(t, doc.Nodes, nodes)
+	require.Equal(t, []string{"helper"}, nodes[2].ToolNode.Tool.Tools)
+
+	// An error stops the parsing.
+	var count int
+	err = ParseStream(strings.NewReader(input), func(Node) error {
+		count++
+		return io.EOF
+	})
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 1, count)
+}
@@ -34,7 +34,7 @@ func GetModelProviderCredential(ctx context.Context, credStore credentials.Crede
 		}); err != nil {
 			return "", err
 		}
-		log.Infof("Saved API key as credential %s", credName)
+		log.WithContext(ctx).Infof("Saved API key as credential %s", credName)
 	}
 
 	return k, nil
@@ -73,6 +73,23 @@ func SysPrompt(ctx context.Context, envs []string, input string, _ chan<- string
 	return "", fmt.Errorf("no prompt server found, can not continue")
 }
 
+func handlePrompt(ctx context.Context, handler Handler, req types.Prompt) (string, error) {
+	results, err := handler(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if results == nil {
+		results = map[string]string{}
+	}
+
+	resultsStr, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultsStr), nil
+}
+
 func sysPrompt(ctx context.Context, req types.Prompt) (_ string, err error) {
 	defer context2.GetPauseFuncFromCtx(ctx)()()
 
@@ -13,14 +13,16 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
-func NewServer(ctx context.Context, envs []string) ([]string, error) {
-	for _, env := range envs {
-		for _, k := range []string{types.PromptURLEnvVar, types.PromptTokenEnvVar} {
-			v, ok := strings.CutPrefix(env, k+"=")
-			if ok && v != "" {
-				return nil, nil
-			}
-		}
+// Handler answers a prompt with the values of its fields. Embedders set a Handler to show prompts in their own UI
+// instead of the terminal.
+type Handler func(ctx context.Context, req types.Prompt) (map[string]string, error)
+
+// NewServer starts a prompt server and returns the environment variables that direct sys.prompt to it. Prompts are
+// answered by the handler, or on the terminal if the handler is nil. If the handler is nil and the environment
+// already points to a prompt server, no server is started.
+func NewServer(ctx context.Context, envs []string, handler Handler) ([]string, error) {
+	if handler == nil && hasPromptServer(envs) {
+		return nil, nil
 	}
 
 	l, err := net.Listen("tcp", "127.0.0.1:0")
@@ -47,7 +49,15 @@ func NewServer(ctx context.Context, envs []string) ([]string, error) {
 				return
 			}
 
-			resp, err := sysPrompt(r.Context(), req)
+			var (
+				resp string
+				err  error
+			)
+			if handler == nil {
+				resp, err = sysPrompt(r.Context(), req)
+			} else {
+				resp, err = handlePrompt(r.Context(), handler, req)
+			}
 			if err != nil {
 				rw.WriteHeader(http.StatusInternalServerError)
 				_, _ = rw.Write([]byte(err.Error()))
@@ -75,3 +85,15 @@ func NewServer(ctx context.Context, envs []string) ([]string, error) {
 		fmt.Sprintf("%s=%s", types.PromptTokenEnvVar, token),
 	}, nil
 }
+
+func hasPromptServer(envs []string) bool {
+	for _, env := range envs {
+		for _, k := range []string{types.PromptURLEnvVar, types.PromptTokenEnvVar} {
+			v, ok := strings.CutPrefix(env, k+"=")
+			if ok && v != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
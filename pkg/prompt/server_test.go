@@ -0,0 +1,35 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var prompts []types.Prompt
+	handler := func(_ context.Context, req types.Prompt) (map[string]string, error) {
+		prompts = append(prompts, req)
+		return map[string]string{"token": "secret"}, nil
+	}
+
+	// A handler is used even when the environment points to another prompt server.
+	envs, err := NewServer(ctx, []string{types.PromptURLEnvVar + "=http://127.0.0.1:1/prompt"}, handler)
+	require.NoError(t, err)
+	require.Len(t, envs, 2)
+
+	out, err := SysPrompt(ctx, envs, `{"message": "Enter your token", "fields": "token", "sensitive": "true"}`, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"token": "secret"}`, out)
+	require.Equal(t, []types.Prompt{{Message: "Enter your token", Fields: []string{"token"}, Sensitive: true}}, prompts)
+
+	// Without a handler, the prompt server of the environment is used.
+	envs, err = NewServer(ctx, []string{types.PromptURLEnvVar + "=http://127.0.0.1:1/prompt"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, envs)
+}
@@ -0,0 +1,136 @@
+// Package proxy routes the HTTP requests of GPTScript, such as those of the loader, the model clients, and the HTTP
+// builtins, through proxies that are chosen per host.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+)
+
+// Direct is the URL of a rule whose hosts are not proxied.
+const Direct = "direct"
+
+var (
+	current atomic.Pointer[Rules]
+
+	// Transport is the HTTP transport of the requests that GPTScript makes itself, which routes them through the proxies
+	// of the installed rules. Other transports, such as the default transport of net/http, are not affected by the rules.
+	Transport = newTransport()
+	// Client is the HTTP client of Transport.
+	Client = &http.Client{Transport: Transport}
+)
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if rules := current.Load(); rules != nil {
+			return rules.Proxy(req)
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return transport
+}
+
+// Rules chooses the proxy of a request by the first rule that matches its host. A request that matches no rule uses
+// the proxy of the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	hosts []string
+	nets  []*net.IPNet
+	proxy *url.URL
+}
+
+// New validates the proxy rules of the configuration file. The URL of a rule may be an http, https, socks5, or socks5h
+// URL, or direct. A host pattern is a host name, a domain such as *.example.com or .example.com that matches the
+// domain and its subdomains, an IP address or CIDR range, or * to match every host.
+func New(configRules []config.ProxyRule) (*Rules, error) {
+	result := &Rules{}
+	for i, configRule := range configRules {
+		if len(configRule.Hosts) == 0 {
+			return nil, fmt.Errorf("proxy rule %d has no hosts", i+1)
+		}
+
+		var r rule
+		if configRule.URL != Direct {
+			u, err := url.Parse(configRule.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL of proxy rule %d: %w", i+1, err)
+			}
+			switch u.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				return nil, fmt.Errorf("invalid URL %q of proxy rule %d, must be an http, https, socks5, or socks5h URL, or %s", configRule.URL, i+1, Direct)
+			}
+			if u.Host == "" {
+				return nil, fmt.Errorf("invalid URL %q of proxy rule %d, it has no host", configRule.URL, i+1)
+			}
+			r.proxy = u
+		}
+
+		for _, host := range configRule.Hosts {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if _, ipNet, err := net.ParseCIDR(host); err == nil {
+				r.nets = append(r.nets, ipNet)
+				continue
+			}
+			r.hosts = append(r.hosts, strings.TrimPrefix(host, "*"))
+		}
+		result.rules = append(result.rules, r)
+	}
+	return result, nil
+}
+
+// Proxy returns the proxy of a request, nil if it is not proxied. It can be used as the Proxy of an http.Transport.
+func (r *Rules) Proxy(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, rule := range r.rules {
+		if rule.matches(host) {
+			return rule.proxy, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+func (r rule) matches(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, ipNet := range r.nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range r.hosts {
+		switch {
+		case pattern == "":
+			// The pattern was *
+			return true
+		case strings.HasPrefix(pattern, "."):
+			if host == pattern[1:] || strings.HasSuffix(host, pattern) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// Install routes the requests made with Transport, which the HTTP clients of GPTScript use, through the proxies of
+// the rules. Installing new rules replaces the previous ones.
+func Install(configRules []config.ProxyRule) error {
+	rules, err := New(configRules)
+	if err != nil {
+		return err
+	}
+	current.Store(rules)
+	return nil
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+
+	rules, err := New([]config.ProxyRule{
+		{Hosts: []string{"*.corp.example.com", "10.0.0.0/8"}, URL: "socks5://corp-proxy:1080"},
+		{Hosts: []string{"api.openai.com"}, URL: Direct},
+		{Hosts: []string{".internal"}, URL: "http://internal-proxy:8080"},
+	})
+	require.NoError(t, err)
+
+	for host, proxy := range map[string]string{
+		"corp.example.com":      "socks5://corp-proxy:1080",
+		"git.corp.example.com":  "socks5://corp-proxy:1080",
+		"10.1.2.3":              "socks5://corp-proxy:1080",
+		"API.openai.com":        "",
+		"wiki.internal":         "http://internal-proxy:8080",
+		"notcorp.example.com":   "http://env-proxy:3128",
+		"github.com":            "http://env-proxy:3128",
+		"api.openai.com.evil.x": "http://env-proxy:3128",
+	} {
+		req, err := http.NewRequest(http.MethodGet, "https://"+host+"/path", nil)
+		require.NoError(t, err)
+		u, err := rules.Proxy(req)
+		require.NoError(t, err)
+		if proxy == "" {
+			require.Nil(t, u, host)
+		} else {
+			require.NotNil(t, u, host)
+			require.Equal(t, proxy, u.String(), host)
+		}
+	}
+
+	all, err := New([]config.ProxyRule{{Hosts: []string{"*"}, URL: "socks5h://proxy:1080"}})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	require.NoError(t, err)
+	u, err := all.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "socks5h://proxy:1080", u.String())
+
+	_, err = New([]config.ProxyRule{{Hosts: []string{"*"}, URL: "ftp://proxy"}})
+	require.Error(t, err)
+	_, err = New([]config.ProxyRule{{URL: "http://proxy:3128"}})
+	require.Error(t, err)
+}
+
+func TestInstall(t *testing.T) {
+	require.NoError(t, Install([]config.ProxyRule{{Hosts: []string{"*"}, URL: "http://corp-proxy:8080"}}))
+	defer func() {
+		require.NoError(t, Install(nil))
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, "https://github.com/path", nil)
+	require.NoError(t, err)
+	u, err := Transport.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "http://corp-proxy:8080", u.String())
+
+	// The default transport of net/http is left as is.
+	u, err = http.DefaultTransport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	require.True(t, u == nil || u.String() != "http://corp-proxy:8080")
+}
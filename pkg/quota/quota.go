@@ -0,0 +1,255 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/cost"
+	"github.com/gptscript-ai/gptscript/pkg/flock"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	gopenai "github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const defaultWarnAt = 0.8
+
+// safeFileName matches the credential contexts that are used as the names of their usage files as is.
+var safeFileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ExceededError is returned before a call to the LLM when the credential context has used up a quota.
+type ExceededError struct {
+	CredentialContext string
+	Limit             string
+	Used              string
+	Max               string
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("credential context %s exceeded its %s quota: used %s of %s", e.CredentialContext, e.Limit, e.Used, e.Max)
+}
+
+// Usage is the usage of a credential context in the current day and month.
+type Usage struct {
+	Day           string  `json:"day"`
+	DailyTokens   int     `json:"dailyTokens"`
+	DailyCost     float64 `json:"dailyCost"`
+	Month         string  `json:"month"`
+	MonthlyTokens int     `json:"monthlyTokens"`
+	MonthlyCost   float64 `json:"monthlyCost"`
+}
+
+// Tracker records the token usage and cost of a credential context and enforces its quota. Usage is persisted to
+// a file so that it is shared by all runs using the credential context on this machine, and updates of the file are
+// locked across processes.
+type Tracker struct {
+	credCtx string
+	quota   config.Quota
+	file    string
+	lock    sync.Mutex
+	now     func() time.Time
+}
+
+// NewTracker returns a tracker that persists usage in dir.
+func NewTracker(credCtx string, quota config.Quota, dir string) *Tracker {
+	return &Tracker{
+		credCtx: credCtx,
+		quota:   quota,
+		file:    filepath.Join(dir, usageFileName(credCtx)),
+		now:     time.Now,
+	}
+}
+
+// usageFileName returns the name of the usage file of a credential context. Names that aren't safe file names, such
+// as those with path separators, are hashed.
+func usageFileName(credCtx string) string {
+	if safeFileName.MatchString(credCtx) {
+		return credCtx + ".json"
+	}
+	return hash.ID(credCtx) + ".json"
+}
+
+// Hooks returns client hooks that check the quota before each call and record usage after it. The next hooks, if
+// any, are called after the quota is checked and before usage is recorded.
+func (t *Tracker) Hooks(next *gopenai.ClientHooks) *gopenai.ClientHooks {
+	return &gopenai.ClientHooks{
+		BeforeRequest: func(ctx context.Context, request *openai.ChatCompletionRequest, header http.Header) error {
+			if err := t.Check(); err != nil {
+				return err
+			}
+			if next != nil && next.BeforeRequest != nil {
+				return next.BeforeRequest(ctx, request, header)
+			}
+			return nil
+		},
+		AfterResponse: func(ctx context.Context, request openai.ChatCompletionRequest, response *types.CompletionMessage, cached bool) error {
+			if next != nil && next.AfterResponse != nil {
+				if err := next.AfterResponse(ctx, request, response, cached); err != nil {
+					return err
+				}
+			}
+			if cached {
+				return nil
+			}
+			return t.Record(ctx, request.Model, response.Usage)
+		},
+	}
+}
+
+// Check returns an ExceededError if any limit of the quota has been reached.
+func (t *Tracker) Check() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	usage, err := t.load()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range t.limits(usage) {
+		if l.max > 0 && l.used >= l.max {
+			return &ExceededError{
+				CredentialContext: t.credCtx,
+				Limit:             l.name,
+				Used:              l.format(l.used),
+				Max:               l.format(l.max),
+			}
+		}
+	}
+	return nil
+}
+
+// Record adds the usage of a call to the model and logs a warning when a limit is close to being reached.
+func (t *Tracker) Record(ctx context.Context, model string, usage types.Usage) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Other processes using the credential context could update the file between loading and saving it.
+	unlock, err := flock.Lock(ctx, t.file+".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock usage of credential context %s: %w", t.credCtx, err)
+	}
+	defer unlock()
+
+	current, err := t.load()
+	if err != nil {
+		return err
+	}
+	before := t.limits(current)
+
+	callCost := cost.Prices(t.quota.Prices).Cost(model, usage)
+
+	current.DailyTokens += usage.TotalTokens
+	current.MonthlyTokens += usage.TotalTokens
+	current.DailyCost += callCost
+	current.MonthlyCost += callCost
+
+	if err := t.save(current); err != nil {
+		return err
+	}
+
+	warnAt := t.quota.WarnAt
+	if warnAt <= 0 {
+		warnAt = defaultWarnAt
+	}
+	for i, l := range t.limits(current) {
+		threshold := l.max * warnAt
+		if l.max > 0 && before[i].used < threshold && l.used >= threshold {
+			log.WithContext(ctx).Warnf("credential context %s has used %s of its %s quota of %s", t.credCtx, l.format(l.used), l.name, l.format(l.max))
+		}
+	}
+	return nil
+}
+
+// Usage returns the current usage of the credential context.
+func (t *Tracker) Usage() (Usage, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.load()
+}
+
+type limit struct {
+	name      string
+	used, max float64
+	format    func(float64) string
+}
+
+func formatTokens(v float64) string {
+	return fmt.Sprintf("%d tokens", int(v))
+}
+
+func formatCost(v float64) string {
+	return fmt.Sprintf("$%.2f", v)
+}
+
+func (t *Tracker) limits(usage Usage) []limit {
+	return []limit{
+		{name: "daily token", used: float64(usage.DailyTokens), max: float64(t.quota.DailyTokens), format: formatTokens},
+		{name: "monthly token", used: float64(usage.MonthlyTokens), max: float64(t.quota.MonthlyTokens), format: formatTokens},
+		{name: "daily cost", used: usage.DailyCost, max: t.quota.DailyCost, format: formatCost},
+		{name: "monthly cost", used: usage.MonthlyCost, max: t.quota.MonthlyCost, format: formatCost},
+	}
+}
+
+// load reads the usage from disk, resetting the counters of a day or month that has passed.
+func (t *Tracker) load() (Usage, error) {
+	var usage Usage
+	data, err := os.ReadFile(t.file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return usage, fmt.Errorf("failed to read usage of credential context %s: %w", t.credCtx, err)
+	} else if err == nil {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return usage, fmt.Errorf("failed to parse usage of credential context %s: %w", t.credCtx, err)
+		}
+	}
+
+	now := t.now()
+	if day := now.Format(time.DateOnly); usage.Day != day {
+		usage.Day = day
+		usage.DailyTokens = 0
+		usage.DailyCost = 0
+	}
+	if month := now.Format("2006-01"); usage.Month != month {
+		usage.Month = month
+		usage.MonthlyTokens = 0
+		usage.MonthlyCost = 0
+	}
+	return usage, nil
+}
+
+func (t *Tracker) save(usage Usage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.file), 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename so that concurrent runs never read a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(t.file), filepath.Base(t.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.file)
+}
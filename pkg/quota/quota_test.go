@@ -0,0 +1,98 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker("team", config.Quota{
+		DailyTokens: 1000,
+		MonthlyCost: 1,
+		Prices: map[string]config.Price{
+			"gpt-4o": {Prompt: 100, Completion: 200},
+		},
+	}, t.TempDir())
+	tracker.now = func() time.Time { return now }
+
+	hooks := tracker.Hooks(nil)
+	request := openai.ChatCompletionRequest{Model: "gpt-4o"}
+	call := func(promptTokens, completionTokens int) error {
+		if err := hooks.BeforeRequest(context.Background(), &request, nil); err != nil {
+			return err
+		}
+		return hooks.AfterResponse(context.Background(), request, &types.CompletionMessage{
+			Usage: types.Usage{
+				PromptTokens:     promptTokens,
+				CompletionTokens: completionTokens,
+				TotalTokens:      promptTokens + completionTokens,
+			},
+		}, false)
+	}
+
+	require.NoError(t, call(500, 100))
+	usage, err := tracker.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 600, usage.DailyTokens)
+	assert.InDelta(t, 0.07, usage.MonthlyCost, 0.0001)
+
+	require.NoError(t, call(400, 0))
+
+	var exceeded *ExceededError
+	require.ErrorAs(t, call(1, 1), &exceeded)
+	assert.Equal(t, "daily token", exceeded.Limit)
+
+	// The limit still applies later the same day and resets with the next day
+	now = now.Add(time.Hour)
+	require.Error(t, tracker.Check())
+	now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, tracker.Check())
+	usage, err = tracker.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 0, usage.MonthlyTokens)
+}
+
+func TestTrackerCachedResponses(t *testing.T) {
+	tracker := NewTracker("team", config.Quota{DailyTokens: 10}, t.TempDir())
+	hooks := tracker.Hooks(nil)
+
+	require.NoError(t, hooks.AfterResponse(context.Background(), openai.ChatCompletionRequest{}, &types.CompletionMessage{
+		Usage: types.Usage{TotalTokens: 100},
+	}, true))
+	require.NoError(t, tracker.Check())
+}
+
+func TestTrackerConcurrentProcesses(t *testing.T) {
+	dir := t.TempDir()
+
+	// Trackers of the same credential context in different processes share the usage file.
+	var wg sync.WaitGroup
+	for range 2 {
+		tracker := NewTracker("team/a", config.Quota{}, dir)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				assert.NoError(t, tracker.Record(context.Background(), "gpt-4o", types.Usage{TotalTokens: 10}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	usage, err := NewTracker("team/a", config.Quota{}, dir).Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 100, usage.DailyTokens)
+
+	// Credential contexts that aren't file names are hashed.
+	assert.Equal(t, "team.json", usageFileName("team"))
+	assert.NotContains(t, usageFileName("../team/a"), "/")
+}
@@ -29,14 +29,18 @@ type Client struct {
 	runner      *runner.Runner
 	envs        []string
 	credStore   credentials.CredentialStore
+	clientOpts  openai.Options
 }
 
-func New(r *runner.Runner, envs []string, cache *cache.Client, credStore credentials.CredentialStore) *Client {
+// New creates a client for remote model providers. The clientOpts are applied to every model provider client that
+// is created, with the provider specific settings taking precedence.
+func New(r *runner.Runner, envs []string, cache *cache.Client, credStore credentials.CredentialStore, clientOpts openai.Options) *Client {
 	return &Client{
-		cache:     cache,
-		runner:    r,
-		envs:      envs,
-		credStore: credStore,
+		cache:      cache,
+		runner:     r,
+		envs:       envs,
+		credStore:  credStore,
+		clientOpts: clientOpts,
 	}
 }
 
@@ -54,6 +58,19 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 	return client.Call(ctx, messageRequest, status)
 }
 
+func (c *Client) Embeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	c.clientsLock.Lock()
+	client, ok := c.models[model]
+	c.clientsLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("failed to find remote model %s", model)
+	}
+
+	_, modelName := types.SplitToolRef(model)
+	return client.Embeddings(ctx, modelName, inputs)
+}
+
 func (c *Client) ListModels(ctx context.Context, providers ...string) (result []string, _ error) {
 	for _, provider := range providers {
 		client, err := c.load(ctx, provider)
@@ -105,7 +122,8 @@ func (c *Client) clientFromURL(ctx context.Context, apiURL string) (*openai.Clie
 	if err != nil {
 		return nil, err
 	}
-	env := "GPTSCRIPT_PROVIDER_" + env2.ToEnvLike(parsed.Hostname()) + "_API_KEY"
+	envPrefix := "GPTSCRIPT_PROVIDER_" + env2.ToEnvLike(parsed.Hostname())
+	env := envPrefix + "_API_KEY"
 	key := os.Getenv(env)
 
 	if key == "" && !isLocalhost(apiURL) {
@@ -116,10 +134,12 @@ func (c *Client) clientFromURL(ctx context.Context, apiURL string) (*openai.Clie
 		}
 	}
 
-	return openai.NewClient(ctx, c.credStore, openai.Options{
+	return openai.NewClient(ctx, c.credStore, c.clientOpts, openai.Options{
 		BaseURL: apiURL,
 		Cache:   c.cache,
 		APIKey:  key,
+		// Several headers of the provider are given on separate lines.
+		ExtraHeaders: []string{os.Getenv(envPrefix + "_HEADERS")},
 	})
 }
 
@@ -163,7 +183,7 @@ func (c *Client) load(ctx context.Context, toolName string) (*openai.Client, err
 		url += "/v1"
 	}
 
-	client, err = openai.NewClient(ctx, c.credStore, openai.Options{
+	client, err = openai.NewClient(ctx, c.credStore, c.clientOpts, openai.Options{
 		BaseURL:  url,
 		Cache:    c.cache,
 		CacheKey: prg.EntryToolID,
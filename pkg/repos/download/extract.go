@@ -4,59 +4,155 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
 	"github.com/mholt/archiver/v4"
 )
 
 func Extract(ctx context.Context, downloadURL, digest, targetDir string) error {
-	if err := os.RemoveAll(targetDir); err != nil {
-		return nil
+	tmpFile, err := download(downloadURL, digest, targetDir)
+	if err != nil {
+		return err
 	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
 
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("mkdir %s: %w", targetDir, err)
-	}
+	return extract(ctx, downloadURL, tmpFile, targetDir)
+}
 
-	tmpFile, err := os.CreateTemp("", "gptscript-download")
+// Executable downloads a prebuilt executable and checks its digest like Extract. Archives are extracted to targetDir,
+// and any other file is saved to targetDir as the executable of the given name, which is the command that runs it,
+// after decompressing it if it is compressed. Release files are usually named after their platform, such as
+// scanner_linux_amd64.gz, so the name of the file is only used when no name is given.
+func Executable(ctx context.Context, downloadURL, digest, targetDir, name string) error {
+	tmpFile, err := download(downloadURL, digest, targetDir)
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tmpFile.Name())
 	defer tmpFile.Close()
 
-	resp, err := http.Get(downloadURL)
+	parsedURL, err := url.Parse(downloadURL)
+	if err != nil {
+		return err
+	}
+	file := path.Base(parsedURL.Path)
+
+	format, input, err := archiver.Identify(file, tmpFile)
+	if errors.Is(err, archiver.ErrNoMatch) {
+		return writeExecutable(filepath.Join(targetDir, executableName(name, file)), input)
+	} else if err != nil {
+		return err
+	}
+
+	if _, ok := format.(archiver.Extractor); ok {
+		if _, err := tmpFile.Seek(0, 0); err != nil {
+			return err
+		}
+		return extract(ctx, downloadURL, tmpFile, targetDir)
+	}
+
+	decompressor, ok := format.(archiver.Decompressor)
+	if !ok {
+		return fmt.Errorf("unsupported format of executable %s: %s", downloadURL, format.Name())
+	}
+	r, err := decompressor.OpenReader(input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return writeExecutable(filepath.Join(targetDir, executableName(name, strings.TrimSuffix(file, format.Name()))), r)
+}
+
+// executableName returns the name of the executable, or the name of the file if it is empty, with the .exe extension
+// that Windows needs to run it.
+func executableName(name, file string) string {
+	if name == "" {
+		name = file
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		name += ".exe"
+	}
+	return name
+}
+
+func writeExecutable(target string, r io.Reader) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// download saves the file at downloadURL to a temporary file after checking its digest, and prepares targetDir.
+func download(downloadURL, digest, targetDir string) (*os.File, error) {
+	if err := os.RemoveAll(targetDir); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", targetDir, err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "gptscript-download")
+	if err != nil {
+		return nil, err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+
+	resp, err := proxy.Client.Get(downloadURL)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	digester := sha256.New()
 	input := io.TeeReader(resp.Body, digester)
 
 	if _, err = io.Copy(tmpFile, input); err != nil {
-		return err
+		return nil, err
 	}
 
 	resultDigest := digester.Sum(nil)
 	resultDigestString := hex.EncodeToString(resultDigest[:])
 
 	if resultDigestString != digest {
-		return fmt.Errorf("downloaded %s and expected digest %s but got %s", downloadURL, digest, resultDigestString)
+		return nil, fmt.Errorf("downloaded %s and expected digest %s but got %s", downloadURL, digest, resultDigestString)
 	}
 
-	parsedURL, err := url.Parse(downloadURL)
-	if err != nil {
-		return err
+	if _, err := tmpFile.Seek(0, 0); err != nil {
+		return nil, err
 	}
 
-	if _, err := tmpFile.Seek(0, 0); err != nil {
+	ok = true
+	return tmpFile, nil
+}
+
+func extract(ctx context.Context, downloadURL string, tmpFile *os.File, targetDir string) error {
+	parsedURL, err := url.Parse(downloadURL)
+	if err != nil {
 		return err
 	}
 
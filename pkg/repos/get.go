@@ -9,6 +9,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	goruntime "runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,10 +18,15 @@ import (
 	"github.com/BurntSushi/locker"
 	"github.com/gptscript-ai/gptscript/pkg/config"
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/flock"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/loader/github"
+	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 	"github.com/gptscript-ai/gptscript/pkg/repos/git"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/golang"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"golang.org/x/exp/maps"
 )
 
 const credentialHelpersRepo = "github.com/gptscript-ai/gptscript-credential-helpers"
@@ -30,6 +37,14 @@ type Runtime interface {
 	Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error)
 }
 
+// InlineDependencies is implemented by runtimes that install the dependencies that a tool declares in its body, so
+// that a single-file tool doesn't need a requirements.txt or package.json next to it.
+type InlineDependencies interface {
+	// InlineDependencies returns the files, by name, that list the dependencies declared in the body of a tool for
+	// the runtime to install, or nil if the body doesn't declare any.
+	InlineDependencies(body string) (map[string]string, error)
+}
+
 type noopRuntime struct {
 }
 
@@ -52,6 +67,7 @@ type Manager struct {
 	credHelperDirs   credentials.CredentialHelperDirs
 	runtimes         []Runtime
 	credHelperConfig *credHelperConfig
+	pruneOnce        sync.Once
 }
 
 type credHelperConfig struct {
@@ -114,6 +130,13 @@ func (m *Manager) deferredSetUpCredentialHelpers(ctx context.Context, cliCfg *co
 	locker.Lock("gptscript-credential-helpers")
 	defer locker.Unlock("gptscript-credential-helpers")
 
+	// Other processes that share the cache directory may be checking the helpers at the same time.
+	unlock, err := flock.Lock(ctx, m.credHelperDirs.LastCheckedFile+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load the last-checked file to make sure we haven't checked the repo in the last 24 hours.
 	now := time.Now()
 	lastChecked, err := os.ReadFile(m.credHelperDirs.LastCheckedFile)
@@ -121,7 +144,7 @@ func (m *Manager) deferredSetUpCredentialHelpers(ctx context.Context, cliCfg *co
 		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(lastChecked))); err == nil && now.Sub(t) < 24*time.Hour {
 			// Make sure the binary still exists, and if it does, return.
 			if _, err := os.Stat(filepath.Join(m.credHelperDirs.BinDir, "gptscript-credential-"+helperName+suffix)); err == nil {
-				log.Debugf("Credential helper %s up-to-date as of %v, checking for updates after %v", helperName, t, t.Add(24*time.Hour))
+				log.WithContext(ctx).Debugf("Credential helper %s up-to-date as of %v, checking for updates after %v", helperName, t, t.Add(24*time.Hour))
 				return nil
 			}
 		}
@@ -184,12 +207,22 @@ func (m *Manager) setup(ctx context.Context, runtime Runtime, tool types.Tool, e
 	defer locker.Unlock(tool.ID)
 
 	target := filepath.Join(m.storageDir, tool.Source.Repo.Revision, tool.Source.Repo.Path, tool.Source.Repo.Name, runtime.ID())
+
+	// Another process may be setting up the same environment. Once it releases the lock, its .done file is reused
+	// below instead of setting the environment up again.
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
 	targetFinal := filepath.Join(target, tool.Source.Repo.Path)
 	doneFile := targetFinal + ".done"
 	envData, err := os.ReadFile(doneFile)
 	if err == nil {
 		var savedEnv []string
 		if err := json.Unmarshal(envData, &savedEnv); err == nil {
+			m.markUsed(ctx, target, doneFile, savedEnv)
 			return targetFinal, append(env, savedEnv...), nil
 		}
 	} else if !errors.Is(err, fs.ErrNotExist) {
@@ -205,30 +238,205 @@ func (m *Manager) setup(ctx context.Context, runtime Runtime, tool types.Tool, e
 		return "", nil, err
 	}
 
+	// The dependencies declared in the body are only used when the repository doesn't list them in a file.
+	deps, err := inlineDependencies(runtime, tool)
+	if err != nil {
+		return "", nil, err
+	}
+	for name, content := range deps {
+		if _, err := os.Stat(filepath.Join(targetFinal, name)); err == nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(targetFinal, name), []byte(content), 0644); err != nil {
+			return "", nil, err
+		}
+	}
+
 	newEnv, err := runtime.Setup(ctx, m.runtimeDir, targetFinal, env)
 	if err != nil {
 		return "", nil, err
 	}
 
+	if err := writeDoneFile(doneFile, newEnv); err != nil {
+		return "", nil, err
+	}
+
+	m.markUsed(ctx, target, doneFile, newEnv)
+	return targetFinal, append(env, newEnv...), nil
+}
+
+// writeDoneFile saves the environment of a tool that was set up, which marks it as done. The file is renamed into
+// place so that it is never seen partially written.
+func writeDoneFile(doneFile string, env []string) error {
 	out, err := os.Create(doneFile + ".tmp")
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 	defer out.Close()
 
-	if err := json.NewEncoder(out).Encode(newEnv); err != nil {
-		return "", nil, err
+	if err := json.NewEncoder(out).Encode(env); err != nil {
+		return err
 	}
 
 	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(doneFile+".tmp", doneFile)
+}
+
+// inlineDependencies returns the files that list the dependencies declared in the body of the tool, if the runtime
+// supports them.
+func inlineDependencies(runtime Runtime, tool types.Tool) (map[string]string, error) {
+	inline, ok := runtime.(InlineDependencies)
+	if !ok {
+		return nil, nil
+	}
+	deps, err := inline.InlineDependencies(tool.Instructions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dependencies in the body of tool %s: %w", tool.Name, err)
+	}
+	return deps, nil
+}
+
+// setupInline sets up the dependencies that a tool which is not in a repository declares in its body. The environment
+// is shared by every tool with the same dependencies, and the tool still runs in its own working directory.
+func (m *Manager) setupInline(ctx context.Context, runtime Runtime, tool types.Tool, deps map[string]string, env []string) (string, []string, error) {
+	names := maps.Keys(deps)
+	sort.Strings(names)
+	key := []string{runtime.ID()}
+	for _, name := range names {
+		key = append(key, name, deps[name])
+	}
+	target := filepath.Join(m.storageDir, "inline", hash.ID(key...))
+
+	locker.Lock(target)
+	defer locker.Unlock(target)
+
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
+	doneFile := target + ".done"
+	envData, err := os.ReadFile(doneFile)
+	if err == nil {
+		var savedEnv []string
+		if err := json.Unmarshal(envData, &savedEnv); err == nil {
+			m.markUsed(ctx, target, doneFile, savedEnv)
+			return tool.WorkingDir, append(env, savedEnv...), nil
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, err
+	}
+
+	// Cleanup previous failed runs
+	_ = os.RemoveAll(doneFile)
+	_ = os.RemoveAll(target)
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return "", nil, err
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(deps[name]), 0644); err != nil {
+			return "", nil, err
+		}
+	}
+
+	newEnv, err := runtime.Setup(ctx, m.runtimeDir, target, env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := writeDoneFile(doneFile, newEnv); err != nil {
 		return "", nil, err
 	}
 
-	return targetFinal, append(env, newEnv...), os.Rename(doneFile+".tmp", doneFile)
+	m.markUsed(ctx, target, doneFile, newEnv)
+	return tool.WorkingDir, append(env, newEnv...), nil
+}
+
+// setupBinary downloads the prebuilt executable of the tool for this platform, unless it was already downloaded, and
+// adds its directory to the PATH. An executable that isn't in an archive is named after the command of the tool.
+func (m *Manager) setupBinary(ctx context.Context, tool types.Tool, cmd, env []string) ([]string, error) {
+	platform := goruntime.GOOS + "/" + goruntime.GOARCH
+	var (
+		binary    *types.Binary
+		platforms []string
+	)
+	for i, b := range tool.Binaries {
+		if b.Platform == platform {
+			binary = &tool.Binaries[i]
+			break
+		}
+		platforms = append(platforms, b.Platform)
+	}
+	if binary == nil {
+		return nil, fmt.Errorf("tool %s has no binary for %s, only for %s", tool.Name, platform, strings.Join(platforms, ", "))
+	}
+
+	var name string
+	if len(cmd) > 0 {
+		name = filepath.Base(cmd[0])
+	}
+	target := filepath.Join(m.storageDir, "binaries", hash.ID(binary.URL, binary.SHA256, name))
+
+	locker.Lock(target)
+	defer locker.Unlock(target)
+
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(target); errors.Is(err, fs.ErrNotExist) {
+		log.InfofCtx(ctx, "Downloading %s", binary.URL)
+		tmp := target + ".download"
+		defer os.RemoveAll(tmp)
+
+		if err := download.Executable(ctx, binary.URL, binary.SHA256, tmp, name); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp, target); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	m.markUsed(ctx, target, "", nil)
+
+	binDir := target
+	// Archives usually have the executable in a bin directory.
+	if s, err := os.Stat(filepath.Join(target, "bin")); err == nil && s.IsDir() {
+		binDir = filepath.Join(target, "bin")
+	}
+
+	return append(append(env, runtimeEnv.AppendPath(env, binDir)...), "GPTSCRIPT_BINARY_DIR="+binDir), nil
 }
 
 func (m *Manager) GetContext(ctx context.Context, tool types.Tool, cmd, env []string) (string, []string, error) {
+	if len(tool.Binaries) > 0 {
+		var err error
+		if env, err = m.setupBinary(ctx, tool, cmd, env); err != nil {
+			return "", nil, err
+		}
+	}
+
 	if tool.Source.Repo == nil {
+		for _, runtime := range m.runtimes {
+			if !runtime.Supports(cmd) {
+				continue
+			}
+			deps, err := inlineDependencies(runtime, tool)
+			if err != nil || len(deps) == 0 {
+				return tool.WorkingDir, env, err
+			}
+			m.autoPrune()
+			return m.setupInline(ctx, runtime, tool, deps, env)
+		}
 		return tool.WorkingDir, env, nil
 	}
 
@@ -236,9 +444,11 @@ func (m *Manager) GetContext(ctx context.Context, tool types.Tool, cmd, env []st
 		return "", nil, fmt.Errorf("only git is supported, found VCS %s for %s", tool.Source.Repo.VCS, tool.ID)
 	}
 
+	m.autoPrune()
+
 	for _, runtime := range m.runtimes {
 		if runtime.Supports(cmd) {
-			log.Debugf("Runtime %s supports %v", runtime.ID(), cmd)
+			log.WithContext(ctx).Debugf("Runtime %s supports %v", runtime.ID(), cmd)
 			return m.setup(ctx, runtime, tool, env)
 		}
 	}
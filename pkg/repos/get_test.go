@@ -1,9 +1,18 @@
 package repos
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
 	"testing"
 
 	"github.com/adrg/xdg"
@@ -37,3 +46,157 @@ func TestManager_GetContext(t *testing.T) {
 	fmt.Print(cwd)
 	fmt.Print(env)
 }
+
+// inlineRuntime declares each line of a tool body that starts with "dep " as a dependency.
+type inlineRuntime struct {
+	setups []string
+}
+
+func (r *inlineRuntime) ID() string {
+	return "inline"
+}
+
+func (r *inlineRuntime) Supports(cmd []string) bool {
+	return len(cmd) > 0 && cmd[0] == "inline"
+}
+
+func (r *inlineRuntime) Setup(_ context.Context, _, toolSource string, _ []string) ([]string, error) {
+	r.setups = append(r.setups, toolSource)
+	return []string{"DEPS_DIR=" + toolSource}, nil
+}
+
+func (r *inlineRuntime) InlineDependencies(body string) (map[string]string, error) {
+	var deps []string
+	for _, line := range strings.Split(body, "\n") {
+		if dep, ok := strings.CutPrefix(line, "dep "); ok {
+			deps = append(deps, dep)
+		}
+	}
+	if len(deps) == 0 {
+		return nil, nil
+	}
+	return map[string]string{"deps.txt": strings.Join(deps, "\n")}, nil
+}
+
+func TestManager_GetContextInlineDependencies(t *testing.T) {
+	runtime := &inlineRuntime{}
+	m := New(t.TempDir(), runtime)
+
+	tool := types.Tool{
+		WorkingDir: "/tools",
+	}
+	tool.Instructions = "#!inline\ndep requests\nprint('hi')"
+
+	cwd, env, err := m.GetContext(context.Background(), tool, []string{"inline"}, []string{"PATH=/bin"})
+	require.NoError(t, err)
+	require.Equal(t, "/tools", cwd)
+	require.Len(t, runtime.setups, 1)
+	require.Equal(t, []string{"PATH=/bin", "DEPS_DIR=" + runtime.setups[0]}, env)
+
+	deps, err := os.ReadFile(filepath.Join(runtime.setups[0], "deps.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "requests", string(deps))
+
+	// Tools with the same dependencies share the environment.
+	tool.Instructions = "#!inline\ndep requests\nprint('bye')"
+	_, env, err = m.GetContext(context.Background(), tool, []string{"inline"}, []string{"PATH=/bin"})
+	require.NoError(t, err)
+	require.Len(t, runtime.setups, 1)
+	require.Equal(t, []string{"PATH=/bin", "DEPS_DIR=" + runtime.setups[0]}, env)
+
+	// Tools without dependencies are run as is.
+	tool.Instructions = "#!inline\nprint('hi')"
+	cwd, env, err = m.GetContext(context.Background(), tool, []string{"inline"}, []string{"PATH=/bin"})
+	require.NoError(t, err)
+	require.Equal(t, "/tools", cwd)
+	require.Equal(t, []string{"PATH=/bin"}, env)
+	require.Len(t, runtime.setups, 1)
+}
+
+func TestManager_GetContextBinary(t *testing.T) {
+	var (
+		content   = []byte("#!/bin/sh\necho hello\n")
+		digest    = sha256.Sum256(content)
+		downloads int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads++
+		_, _ = w.Write(content)
+	}))
+	defer s.Close()
+
+	m := New(t.TempDir())
+	tool := types.Tool{
+		WorkingDir: "/tools",
+	}
+	tool.Name = "hello"
+	tool.Binaries = []types.Binary{
+		{Platform: "plan9/386", URL: s.URL + "/other", SHA256: hex.EncodeToString(digest[:])},
+		{Platform: goruntime.GOOS + "/" + goruntime.GOARCH, URL: s.URL + "/hello", SHA256: hex.EncodeToString(digest[:])},
+	}
+
+	for range 2 {
+		cwd, env, err := m.GetContext(context.Background(), tool, []string{"hello"}, []string{"PATH=/bin"})
+		require.NoError(t, err)
+		require.Equal(t, "/tools", cwd)
+		require.Len(t, env, 3)
+
+		binDir, ok := strings.CutPrefix(env[2], "GPTSCRIPT_BINARY_DIR=")
+		require.True(t, ok)
+		require.Equal(t, "PATH="+binDir+string(os.PathListSeparator)+"/bin", env[1])
+
+		if goruntime.GOOS == "windows" {
+			_, err = os.Stat(filepath.Join(binDir, "hello.exe"))
+			require.NoError(t, err)
+		} else {
+			info, err := os.Stat(filepath.Join(binDir, "hello"))
+			require.NoError(t, err)
+			require.NotZero(t, info.Mode()&0111)
+		}
+	}
+	require.Equal(t, 1, downloads)
+
+	tool.Binaries = tool.Binaries[:1]
+	_, _, err := m.GetContext(context.Background(), tool, []string{"hello"}, nil)
+	require.ErrorContains(t, err, "tool hello has no binary for "+goruntime.GOOS+"/"+goruntime.GOARCH+", only for plan9/386")
+
+	tool.Binaries = []types.Binary{{Platform: goruntime.GOOS + "/" + goruntime.GOARCH, URL: s.URL + "/tampered", SHA256: strings.Repeat("0", 64)}}
+	_, _, err = m.GetContext(context.Background(), tool, []string{"hello"}, nil)
+	require.ErrorContains(t, err, "expected digest "+strings.Repeat("0", 64))
+}
+
+func TestManager_GetContextCompressedBinary(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte("#!/bin/sh\necho scanned\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	digest := sha256.Sum256(compressed.Bytes())
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer s.Close()
+
+	// The documented form: a compressed file named after its platform that is run by the name of the executable.
+	platform := goruntime.GOOS + "/" + goruntime.GOARCH
+	tool := types.Tool{}
+	tool.Name = "scan"
+	tool.Instructions = "#!scanner --dir ${path}"
+	tool.Binaries = []types.Binary{
+		{Platform: platform, URL: s.URL + "/releases/v1.2.0/scanner_" + strings.ReplaceAll(platform, "/", "_") + ".gz", SHA256: hex.EncodeToString(digest[:])},
+	}
+
+	_, env, err := New(t.TempDir()).GetContext(context.Background(), tool, []string{"scanner", "--dir", "${path}"}, nil)
+	require.NoError(t, err)
+	binDir, ok := strings.CutPrefix(env[len(env)-1], "GPTSCRIPT_BINARY_DIR=")
+	require.True(t, ok)
+
+	name := "scanner"
+	if goruntime.GOOS == "windows" {
+		name += ".exe"
+	}
+	data, err := os.ReadFile(filepath.Join(binDir, name))
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho scanned\n", string(data))
+}
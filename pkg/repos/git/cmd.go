@@ -10,7 +10,7 @@ import (
 
 func newGitCommand(ctx context.Context, args ...string) *debugcmd.WrappedCmd {
 	if log.IsDebug() {
-		log.Debugf("running git command: %s", strings.Join(args, " "))
+		log.WithContext(ctx).Debugf("running git command: %s", strings.Join(args, " "))
 	}
 	cmd := debugcmd.New(ctx, "git", args...)
 	return cmd
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/gptscript-ai/gptscript/pkg/flock"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 )
 
@@ -29,6 +30,13 @@ func Checkout(ctx context.Context, base, repo, commit, toDir string) error {
 		return err
 	}
 
+	// Processes that share the base directory clone, fetch, and add worktrees to the same repository one at a time.
+	unlock, err := flock.Lock(ctx, gitDir(base, repo)+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if err := Fetch(ctx, base, repo, commit); err != nil {
 		return err
 	}
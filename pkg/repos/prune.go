@@ -0,0 +1,410 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/locker"
+)
+
+// lastUsedSuffix is appended to the directory of a tool environment to name the file that records when the
+// environment was last used. The modification time of the file is the last use, its content is a usageRecord.
+const lastUsedSuffix = ".last-used"
+
+// autoPruneInterval is how often the automatic prune runs at most.
+const autoPruneInterval = 24 * time.Hour
+
+type usageRecord struct {
+	// Paths are the files and directories outside the environment directory that belong to the environment, such as
+	// its .done file and virtual env.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Usage is the disk space used by the repos cache.
+type Usage struct {
+	Environments     int   `json:"environments"`
+	EnvironmentBytes int64 `json:"environmentBytes"`
+	Runtimes         int   `json:"runtimes"`
+	RuntimeBytes     int64 `json:"runtimeBytes"`
+	GitBytes         int64 `json:"gitBytes"`
+	TotalBytes       int64 `json:"totalBytes"`
+}
+
+type PruneOptions struct {
+	// MaxUnused is how long a tool environment can go unused before it is pruned.
+	MaxUnused time.Duration
+	// Runtimes also prunes the downloaded runtimes and virtual envs that no remaining environment uses.
+	Runtimes bool
+	// DryRun reports what would be pruned without removing anything.
+	DryRun bool
+}
+
+type PruneResult struct {
+	Removed []string `json:"removed"`
+	Bytes   int64    `json:"bytes"`
+}
+
+type environment struct {
+	dir      string
+	lastUsed time.Time
+	record   usageRecord
+}
+
+// markUsed records that the environment in the target directory was used now. Downloaded binaries are recorded
+// the same way, without a .done file.
+func (m *Manager) markUsed(ctx context.Context, target, doneFile string, env []string) {
+	usedFile := target + lastUsedSuffix
+	now := time.Now()
+	if err := os.Chtimes(usedFile, now, now); err == nil {
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.WithContext(ctx).Debugf("failed to record use of %s: %v", target, err)
+		return
+	}
+
+	var record usageRecord
+	if doneFile != "" {
+		record.Paths = append(record.Paths, doneFile)
+	}
+	for _, e := range env {
+		if venv, ok := strings.CutPrefix(e, "VIRTUAL_ENV="); ok && strings.HasPrefix(venv, m.runtimeDir) {
+			record.Paths = append(record.Paths, venv)
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.WithContext(ctx).Debugf("failed to record use of %s: %v", target, err)
+		return
+	}
+	if err := os.WriteFile(usedFile, data, 0644); err != nil {
+		log.WithContext(ctx).Debugf("failed to record use of %s: %v", target, err)
+	}
+}
+
+// Usage returns the disk space used by tool environments, downloaded runtimes, and git repositories.
+func (m *Manager) Usage() (Usage, error) {
+	var (
+		result Usage
+		err    error
+	)
+
+	envs, _, err := m.scan()
+	if err != nil {
+		return result, err
+	}
+	owned := map[string]bool{}
+	for _, env := range envs {
+		result.Environments++
+		for _, p := range append([]string{env.dir}, env.record.Paths...) {
+			size, err := diskUsage(p)
+			if err != nil {
+				return result, err
+			}
+			result.EnvironmentBytes += size
+			owned[p] = true
+		}
+	}
+
+	runtimes, err := m.installedRuntimes()
+	if err != nil {
+		return result, err
+	}
+	for _, runtime := range runtimes {
+		if owned[runtime] {
+			continue
+		}
+		size, err := diskUsage(runtime)
+		if err != nil {
+			return result, err
+		}
+		result.Runtimes++
+		result.RuntimeBytes += size
+	}
+
+	result.GitBytes, err = diskUsage(m.gitDir)
+	if err != nil {
+		return result, err
+	}
+
+	result.TotalBytes, err = diskUsage(m.storageDir)
+	return result, err
+}
+
+// Prune removes the tool environments that have not been used within opts.MaxUnused and, if requested, their virtual
+// envs and the runtimes and virtual envs that are no longer used by any environment and were not installed within
+// opts.MaxUnused.
+func (m *Manager) Prune(opts PruneOptions) (PruneResult, error) {
+	var result PruneResult
+
+	envs, doneFiles, err := m.scan()
+	if err != nil {
+		return result, err
+	}
+
+	var (
+		cutoff = time.Now().Add(-opts.MaxUnused)
+		pruned = map[string]bool{}
+	)
+	for _, env := range envs {
+		if env.lastUsed.After(cutoff) {
+			continue
+		}
+		paths := []string{env.dir}
+		for _, p := range env.record.Paths {
+			// Virtual envs are runtimes, which are left for a prune of the runtimes to remove once nothing uses them.
+			if !opts.Runtimes && strings.HasPrefix(p, m.runtimeDir) {
+				continue
+			}
+			pruned[p] = true
+			paths = append(paths, p)
+		}
+		if err := m.remove(&result, opts.DryRun, paths...); err != nil {
+			return result, err
+		}
+		if !opts.DryRun {
+			// Removing the record last makes an interrupted prune leave a record behind to finish on the next prune.
+			if err := os.Remove(env.dir + lastUsedSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return result, err
+			}
+		}
+	}
+
+	if !opts.Runtimes {
+		return result, nil
+	}
+
+	var inUse []string
+	for _, doneFile := range doneFiles {
+		if !pruned[doneFile] {
+			inUse = append(inUse, doneFile)
+		}
+	}
+	references := references(inUse)
+
+	runtimes, err := m.installedRuntimes()
+	if err != nil {
+		return result, err
+	}
+	for _, runtime := range runtimes {
+		if pruned[runtime] || strings.Contains(references, runtime) {
+			continue
+		}
+		info, err := os.Stat(runtime)
+		if err != nil {
+			return result, err
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := m.remove(&result, opts.DryRun, runtime); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// autoPrune prunes environments unused for the configured number of days, at most once per autoPruneInterval.
+func (m *Manager) autoPrune() {
+	if m.credHelperConfig == nil || m.credHelperConfig.cliCfg == nil || m.credHelperConfig.cliCfg.PruneUnusedDays <= 0 {
+		return
+	}
+
+	m.pruneOnce.Do(func() {
+		locker.Lock("gptscript-repos-prune")
+		defer locker.Unlock("gptscript-repos-prune")
+
+		lastPrunedFile := filepath.Join(m.storageDir, "last-pruned")
+		if info, err := os.Stat(lastPrunedFile); err == nil && time.Since(info.ModTime()) < autoPruneInterval {
+			return
+		}
+		if err := os.MkdirAll(m.storageDir, 0755); err != nil {
+			log.Debugf("failed to prune repos cache: %v", err)
+			return
+		}
+		if err := os.WriteFile(lastPrunedFile, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
+			log.Debugf("failed to prune repos cache: %v", err)
+			return
+		}
+
+		result, err := m.Prune(PruneOptions{
+			MaxUnused: time.Duration(m.credHelperConfig.cliCfg.PruneUnusedDays) * 24 * time.Hour,
+			Runtimes:  true,
+		})
+		if err != nil {
+			log.Errorf("failed to prune repos cache: %v", err)
+			return
+		}
+		if len(result.Removed) > 0 {
+			log.Infof("Pruned %d unused tool environments and runtimes, freeing %d bytes", len(result.Removed), result.Bytes)
+		}
+	})
+}
+
+func (m *Manager) remove(result *PruneResult, dryRun bool, paths ...string) error {
+	for _, p := range paths {
+		size, err := diskUsage(p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if !dryRun {
+			log.Debugf("Pruning %s", p)
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+		result.Removed = append(result.Removed, p)
+		result.Bytes += size
+	}
+	return nil
+}
+
+// scan returns the tool environments that have a record of their last use, and the .done files of all tool
+// environments including the ones that were set up before their use was recorded.
+func (m *Manager) scan() (envs []environment, doneFiles []string, _ error) {
+	skip := map[string]bool{
+		m.gitDir:                              true,
+		m.runtimeDir:                          true,
+		filepath.Dir(m.credHelperDirs.BinDir): true,
+	}
+
+	err := filepath.WalkDir(m.storageDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skip[path] {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(path + lastUsedSuffix); err == nil {
+				// The usage record lists the .done file of the environment, so there is no need to walk its contents.
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasSuffix(path, ".done") {
+			doneFiles = append(doneFiles, path)
+			return nil
+		}
+
+		dir, ok := strings.CutSuffix(path, lastUsedSuffix)
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		env := environment{
+			dir:      dir,
+			lastUsed: info.ModTime(),
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &env.record); err != nil {
+			log.Debugf("ignoring invalid usage record %s: %v", path, err)
+			return nil
+		}
+
+		for _, p := range env.record.Paths {
+			if strings.HasSuffix(p, ".done") {
+				doneFiles = append(doneFiles, p)
+			}
+		}
+		envs = append(envs, env)
+		return nil
+	})
+	return envs, doneFiles, err
+}
+
+// installedRuntimes returns the directories of the downloaded runtimes and virtual envs.
+func (m *Manager) installedRuntimes() (result []string, _ error) {
+	kinds, err := os.ReadDir(m.runtimeDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, kind := range kinds {
+		if !kind.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(m.runtimeDir, kind.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			// Skip the archives and temporary directories of downloads in progress.
+			if entry.IsDir() && !strings.HasSuffix(entry.Name(), ".tmp") {
+				result = append(result, filepath.Join(m.runtimeDir, kind.Name(), entry.Name()))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// references returns the saved environments of the tool environments along with the configuration of their virtual
+// envs, which together reference every runtime and virtual env that is in use.
+func references(doneFiles []string) string {
+	var buf strings.Builder
+	for _, doneFile := range doneFiles {
+		data, err := os.ReadFile(doneFile)
+		if err != nil {
+			continue
+		}
+		var savedEnv []string
+		if err := json.Unmarshal(data, &savedEnv); err != nil {
+			continue
+		}
+		for _, e := range savedEnv {
+			buf.WriteString(e)
+			buf.WriteString("\n")
+			if venv, ok := strings.CutPrefix(e, "VIRTUAL_ENV="); ok {
+				if cfg, err := os.ReadFile(filepath.Join(venv, "pyvenv.cfg")); err == nil {
+					buf.Write(cfg)
+					buf.WriteString("\n")
+				}
+			}
+		}
+	}
+	return buf.String()
+}
+
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,70 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEnvironment(t *testing.T, m *Manager, name string, env []string, lastUsed time.Time) string {
+	t.Helper()
+	target := filepath.Join(m.storageDir, "rev-"+name, "tool", "node21")
+	require.NoError(t, os.MkdirAll(target, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, "tool.gpt"), []byte("echo hi"), 0644))
+
+	doneFile := target + ".done"
+	data, err := json.Marshal(env)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(doneFile, data, 0644))
+
+	m.markUsed(context.Background(), target, doneFile, env)
+	require.NoError(t, os.Chtimes(target+lastUsedSuffix, lastUsed, lastUsed))
+	return target
+}
+
+func TestPrune(t *testing.T) {
+	m := New(t.TempDir())
+	old := time.Now().Add(-60 * 24 * time.Hour)
+
+	usedRuntime := filepath.Join(m.runtimeDir, "node", "used")
+	unusedRuntime := filepath.Join(m.runtimeDir, "node", "unused")
+	venv := filepath.Join(m.runtimeDir, "venv", "stale")
+	for _, dir := range []string{usedRuntime, unusedRuntime, venv} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+		require.NoError(t, os.Chtimes(dir, old, old))
+	}
+
+	recent := setupEnvironment(t, m, "recent", []string{"PATH=" + filepath.Join(usedRuntime, "bin")}, time.Now())
+	stale := setupEnvironment(t, m, "stale", []string{"PATH=" + filepath.Join(unusedRuntime, "bin"), "VIRTUAL_ENV=" + venv}, old)
+
+	usage, err := m.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 2, usage.Environments)
+	assert.Equal(t, 2, usage.Runtimes)
+
+	result, err := m.Prune(PruneOptions{MaxUnused: 30 * 24 * time.Hour, DryRun: true, Runtimes: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{stale, stale + ".done", venv, unusedRuntime}, result.Removed)
+	assert.DirExists(t, stale)
+
+	// Virtual envs are only pruned with the runtimes.
+	result, err = m.Prune(PruneOptions{MaxUnused: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{stale, stale + ".done"}, result.Removed)
+	assert.NoDirExists(t, stale)
+	assert.NoFileExists(t, stale+lastUsedSuffix)
+	assert.DirExists(t, venv)
+
+	result, err = m.Prune(PruneOptions{MaxUnused: 30 * 24 * time.Hour, Runtimes: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{venv, unusedRuntime}, result.Removed)
+	assert.NoDirExists(t, unusedRuntime)
+	assert.DirExists(t, recent)
+	assert.DirExists(t, usedRuntime)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/flock"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
@@ -128,6 +129,13 @@ func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
 	}
 
 	target := filepath.Join(cwd, "golang", hash.ID(url, sha))
+
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	if _, err := os.Stat(target); err == nil {
 		return r.binDir(target), nil
 	} else if !errors.Is(err, fs.ErrNotExist) {
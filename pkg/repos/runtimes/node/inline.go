@@ -0,0 +1,61 @@
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// InlineDependencies returns a package.json with the dependencies that are declared in comments of a tool body, or nil
+// if there are none. Each package can have a version, and the comments can be repeated:
+//
+//	// deps: lodash@4, @octokit/rest@^20
+//	// deps: axios
+func (r *Runtime) InlineDependencies(body string) (map[string]string, error) {
+	deps := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "//")
+		if !ok {
+			continue
+		}
+		line, ok = strings.CutPrefix(strings.TrimSpace(line), "deps:")
+		if !ok {
+			continue
+		}
+		for _, dep := range strings.Split(line, ",") {
+			dep = strings.TrimSpace(dep)
+			if dep == "" {
+				continue
+			}
+			// Scoped packages start with @, so the version is after the last @ that isn't the first character.
+			name, version := dep, "*"
+			if i := strings.LastIndex(dep, "@"); i > 0 {
+				name, version = dep[:i], dep[i+1:]
+			}
+			if name == "" || version == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("invalid dependency %q, must be a package name optionally followed by @<version>", dep)
+			}
+			deps[name] = version
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(deps) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"private":      true,
+		"dependencies": deps,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"package.json": string(data) + "\n",
+	}, nil
+}
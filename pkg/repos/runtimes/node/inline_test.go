@@ -0,0 +1,27 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineDependencies(t *testing.T) {
+	r := &Runtime{}
+
+	deps, err := r.InlineDependencies(`#!/usr/bin/env node
+// deps: lodash@4, @octokit/rest@^20
+// deps: axios
+
+const _ = require('lodash')
+`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"private": true, "dependencies": {"lodash": "4", "@octokit/rest": "^20", "axios": "*"}}`, deps["package.json"])
+
+	deps, err = r.InlineDependencies("#!/usr/bin/env node\nconsole.log('hi')\n")
+	require.NoError(t, err)
+	require.Nil(t, deps)
+
+	_, err = r.InlineDependencies("// deps: lodash@\n")
+	require.ErrorContains(t, err, `invalid dependency "lodash@"`)
+}
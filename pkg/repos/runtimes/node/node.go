@@ -15,6 +15,7 @@ import (
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/flock"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
@@ -65,7 +66,9 @@ func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []
 		return nil, err
 	}
 
-	return newEnv, nil
+	// Tools with inline dependencies run in their own directory instead of the tool source, so they find the
+	// packages through NODE_PATH.
+	return append(newEnv, "NODE_PATH="+filepath.Join(toolSource, "node_modules")), nil
 }
 
 func osName() string {
@@ -135,6 +138,13 @@ func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
 	}
 
 	target := filepath.Join(cwd, "node", hash.ID(url, sha))
+
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	if _, err := os.Stat(target); err == nil {
 		return r.binDir(target)
 	} else if !errors.Is(err, fs.ErrNotExist) {
@@ -0,0 +1,110 @@
+package python
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// InlineDependencies returns a requirements.txt with the dependencies that are declared in the inline script metadata
+// of PEP 723 at the top of a tool body, or nil if there are none:
+//
+//	# /// script
+//	# dependencies = [
+//	#   "requests<3",
+//	# ]
+//	# ///
+func (r *Runtime) InlineDependencies(body string) (map[string]string, error) {
+	metadata, ok, err := scriptMetadata(body)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	deps, err := tomlStringArray(metadata, "dependencies")
+	if err != nil || len(deps) == 0 {
+		return nil, err
+	}
+
+	return map[string]string{
+		"requirements.txt": strings.Join(deps, "\n") + "\n",
+	}, nil
+}
+
+// scriptMetadata returns the TOML content of the script block of the inline metadata, without the comment prefixes.
+func scriptMetadata(body string) (string, bool, error) {
+	var (
+		scanner = bufio.NewScanner(strings.NewReader(body))
+		content strings.Builder
+		inBlock bool
+	)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case !inBlock && line == "# /// script":
+			inBlock = true
+		case inBlock && line == "# ///":
+			return content.String(), true, nil
+		case inBlock && (line == "#" || strings.HasPrefix(line, "# ")):
+			content.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "))
+			content.WriteString("\n")
+		case inBlock:
+			return "", false, fmt.Errorf("invalid line in script metadata, each line must start with #: %s", line)
+		}
+	}
+	if inBlock {
+		return "", false, fmt.Errorf("script metadata is not closed with # ///")
+	}
+	return "", false, scanner.Err()
+}
+
+// tomlStringArray returns the value of a top-level array of strings in a TOML document. This is all that is needed of
+// the script metadata, so the rest of the document is ignored.
+func tomlStringArray(doc, key string) ([]string, error) {
+	var (
+		lines = strings.Split(doc, "\n")
+		value string
+	)
+	for i, line := range lines {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		// The array can span lines, so take everything after the key up to the closing bracket.
+		value = strings.TrimSpace(strings.Join(append([]string{v}, lines[i+1:]...), "\n"))
+		break
+	}
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return nil, fmt.Errorf("invalid %s in script metadata, must be an array of strings", key)
+	}
+
+	var (
+		result  []string
+		quote   rune
+		comment bool
+		item    strings.Builder
+	)
+	for _, c := range value[1:] {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case quote != 0 && c == quote:
+			result = append(result, item.String())
+			item.Reset()
+			quote = 0
+		case quote != 0:
+			item.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			return result, nil
+		case c == '#':
+			comment = true
+		case c != ',' && c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			return nil, fmt.Errorf("invalid %s in script metadata, must be an array of strings", key)
+		}
+	}
+	return nil, fmt.Errorf("invalid %s in script metadata, the array is not closed", key)
+}
@@ -0,0 +1,35 @@
+package python
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineDependencies(t *testing.T) {
+	r := &Runtime{}
+
+	deps, err := r.InlineDependencies(`#!/usr/bin/env python3
+# /// script
+# requires-python = ">=3.11"
+# dependencies = [
+#   "requests<3",  # for the API
+#   'rich',
+# ]
+# ///
+
+import requests
+`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"requirements.txt": "requests<3\nrich\n"}, deps)
+
+	deps, err = r.InlineDependencies("#!/usr/bin/env python3\nprint('hi')\n")
+	require.NoError(t, err)
+	require.Nil(t, deps)
+
+	_, err = r.InlineDependencies("# /// script\n# dependencies = [\"requests\"\n")
+	require.ErrorContains(t, err, "script metadata is not closed")
+
+	_, err = r.InlineDependencies("# /// script\n# dependencies = \"requests\"\n# ///\n")
+	require.ErrorContains(t, err, "must be an array of strings")
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/flock"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
@@ -197,6 +198,13 @@ func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
 
 	target := filepath.Join(cwd, "python", hash.ID(url, sha, uvVersion))
 	binDir := pythonBin(target)
+
+	unlock, err := flock.Lock(ctx, target+".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	if _, err := os.Stat(target); err == nil {
 		return binDir, nil
 	} else if !errors.Is(err, fs.ErrNotExist) {
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// BreakpointResponse is what to do with a tool call that is paused at a breakpoint.
+type BreakpointResponse struct {
+	// Skip doesn't call the tool, and returns Output as its result instead.
+	Skip   bool
+	Output string
+	// Input replaces the input of the tool call if it is set.
+	Input *string
+}
+
+// BreakpointFunc is called before a tool that matches a breakpoint is called. It should block until the user decides
+// how to continue.
+type BreakpointFunc func(ctx engine.Context, input string) (BreakpointResponse, error)
+
+// breakpoint matches the tools that the run pauses before.
+type breakpoint struct {
+	tool string
+}
+
+// parseBreakpoints parses breakpoints in the format tool=<name>, where the name can be a glob pattern.
+func parseBreakpoints(specs []string) (result []breakpoint, _ error) {
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(key) != "tool" || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid breakpoint %q, must be tool=<name>", spec)
+		}
+		value = strings.TrimSpace(value)
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid breakpoint %q: %w", spec, err)
+		}
+		result = append(result, breakpoint{
+			tool: value,
+		})
+	}
+	return
+}
+
+func (b breakpoint) matches(callCtx engine.Context) bool {
+	// The tool can be matched by its own name, or the name that the calling tool references it by.
+	for _, name := range []string{callCtx.Tool.Name, callCtx.GetCallContext().ToolName} {
+		if name == "" {
+			continue
+		}
+		if ok, _ := path.Match(b.tool, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBreakpoint pauses before the tool is called if it has the Breakpoint directive or matches a breakpoint. It
+// returns the input to call the tool with, or the result of a skipped call.
+func (r *Runner) handleBreakpoint(callCtx engine.Context, input string) (string, *string, error) {
+	if r.breakpointHandler == nil || !r.isBreakpoint(callCtx) {
+		return input, nil, nil
+	}
+
+	resp, err := r.breakpointHandler(callCtx, input)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if resp.Skip {
+		output := resp.Output
+		if output == "" {
+			output = "The tool call was skipped at a breakpoint."
+		}
+		return input, &output, nil
+	}
+	if resp.Input != nil {
+		input = *resp.Input
+	}
+	return input, nil, nil
+}
+
+func (r *Runner) isBreakpoint(callCtx engine.Context) bool {
+	if callCtx.Tool.Breakpoint {
+		return true
+	}
+	for _, b := range r.breakpoints {
+		if b.matches(callCtx) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBreakpoints(t *testing.T) {
+	breakpoints, err := parseBreakpoints([]string{"tool=search", "tool = github-*"})
+	require.NoError(t, err)
+	require.Equal(t, []breakpoint{{tool: "search"}, {tool: "github-*"}}, breakpoints)
+
+	_, err = parseBreakpoints([]string{"search"})
+	require.EqualError(t, err, `invalid breakpoint "search", must be tool=<name>`)
+
+	_, err = parseBreakpoints([]string{"tool=["})
+	require.ErrorContains(t, err, `invalid breakpoint "tool=["`)
+}
+
+func TestHandleBreakpoint(t *testing.T) {
+	var (
+		calls    []string
+		response BreakpointResponse
+	)
+	breakpoints, err := parseBreakpoints([]string{"tool=github-*"})
+	require.NoError(t, err)
+	r := &Runner{
+		breakpoints: breakpoints,
+		breakpointHandler: func(ctx engine.Context, input string) (BreakpointResponse, error) {
+			calls = append(calls, ctx.Tool.Name+": "+input)
+			return response, nil
+		},
+	}
+
+	call := func(name string, directive bool) engine.Context {
+		var callCtx engine.Context
+		callCtx.Tool.Name = name
+		callCtx.Tool.Breakpoint = directive
+		return callCtx
+	}
+
+	// Tools that don't match are not paused.
+	input, skipped, err := r.handleBreakpoint(call("search", false), `{"q": "go"}`)
+	require.NoError(t, err)
+	require.Nil(t, skipped)
+	require.Equal(t, `{"q": "go"}`, input)
+	require.Empty(t, calls)
+
+	// Continuing keeps the input, and changing it replaces it.
+	input, skipped, err = r.handleBreakpoint(call("github-issues", false), `{"repo": "a"}`)
+	require.NoError(t, err)
+	require.Nil(t, skipped)
+	require.Equal(t, `{"repo": "a"}`, input)
+
+	newInput := `{"repo": "b"}`
+	response = BreakpointResponse{Input: &newInput}
+	input, _, err = r.handleBreakpoint(call("search", true), `{"q": "go"}`)
+	require.NoError(t, err)
+	require.Equal(t, newInput, input)
+	require.Equal(t, []string{`github-issues: {"repo": "a"}`, `search: {"q": "go"}`}, calls)
+
+	// A skipped tool returns the output.
+	response = BreakpointResponse{Skip: true}
+	_, skipped, err = r.handleBreakpoint(call("github-issues", false), "")
+	require.NoError(t, err)
+	require.Equal(t, "The tool call was skipped at a breakpoint.", *skipped)
+
+	// Without a handler, breakpoints are ignored.
+	r.breakpointHandler = nil
+	input, skipped, err = r.handleBreakpoint(call("search", true), "input")
+	require.NoError(t, err)
+	require.Nil(t, skipped)
+	require.Equal(t, "input", input)
+}
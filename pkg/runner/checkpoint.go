@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cost"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// CheckpointSummary describes what a run has done, for the user to decide whether it should continue.
+type CheckpointSummary struct {
+	ToolCalls int
+	// Cost is in dollars, and is only known for the models that have a price.
+	Cost    float64
+	Usage   types.Usage
+	Elapsed time.Duration
+	// RecentCalls counts the calls of each tool since the previous checkpoint.
+	RecentCalls map[string]int
+}
+
+func (s CheckpointSummary) String() string {
+	buf := &strings.Builder{}
+	_, _ = fmt.Fprintf(buf, "The run has made %d tool calls", s.ToolCalls)
+	if s.Cost > 0 {
+		_, _ = fmt.Fprintf(buf, " and spent $%.2f", s.Cost)
+	}
+	_, _ = fmt.Fprintf(buf, " using %d tokens in %s.", s.Usage.TotalTokens, s.Elapsed.Round(time.Second))
+
+	if len(s.RecentCalls) > 0 {
+		names := make([]string, 0, len(s.RecentCalls))
+		for name := range s.RecentCalls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s (%d)", name, s.RecentCalls[name])
+		}
+		_, _ = fmt.Fprintf(buf, "\nSince the last checkpoint: %s", strings.Join(names, ", "))
+	}
+	return buf.String()
+}
+
+// CheckpointFunc is called when a run reaches a checkpoint, before its next tool call. The run stops unless the
+// response accepts it.
+type CheckpointFunc func(ctx engine.Context, summary CheckpointSummary) (AuthorizerResponse, error)
+
+// CheckpointStopError is returned when the user stops a run at a checkpoint.
+type CheckpointStopError struct {
+	Summary CheckpointSummary
+	Message string
+}
+
+func (e *CheckpointStopError) Error() string {
+	msg := fmt.Sprintf("run stopped at checkpoint after %d tool calls", e.Summary.ToolCalls)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+type checkpointKey struct{}
+
+// checkpoints tracks the tool calls and cost of a run between checkpoints.
+type checkpoints struct {
+	calls   int
+	cost    float64
+	prices  cost.Prices
+	handler CheckpointFunc
+
+	lock        sync.Mutex
+	start       time.Time
+	toolCalls   int
+	usage       types.Usage
+	spent       float64
+	recentCalls map[string]int
+	nextCalls   int
+	nextCost    float64
+}
+
+// withCheckpoints adds the checkpoint state of a new run to the context if checkpoints are enabled.
+func (r *Runner) withCheckpoints(ctx context.Context) context.Context {
+	if r.checkpointHandler == nil || (r.checkpointCalls <= 0 && r.checkpointCost <= 0) {
+		return ctx
+	}
+	return context.WithValue(ctx, checkpointKey{}, &checkpoints{
+		calls:       r.checkpointCalls,
+		cost:        r.checkpointCost,
+		prices:      r.prices,
+		handler:     r.checkpointHandler,
+		start:       time.Now(),
+		recentCalls: map[string]int{},
+		nextCalls:   r.checkpointCalls,
+		nextCost:    r.checkpointCost,
+	})
+}
+
+func getCheckpoints(ctx context.Context) *checkpoints {
+	c, _ := ctx.Value(checkpointKey{}).(*checkpoints)
+	return c
+}
+
+// addUsage adds the usage of a completion to the run, priced by the model.
+func (c *checkpoints) addUsage(model string, usage types.Usage) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.usage.PromptTokens += usage.PromptTokens
+	c.usage.CompletionTokens += usage.CompletionTokens
+	c.usage.TotalTokens += usage.TotalTokens
+	c.usage.CacheReadTokens += usage.CacheReadTokens
+	c.usage.CacheWriteTokens += usage.CacheWriteTokens
+	c.spent += c.prices.Cost(model, usage)
+}
+
+// toolCall counts a call of a tool, and first asks the user whether to continue if the run has reached a checkpoint.
+// Parallel calls wait for the answer, so the user is only asked once.
+func (c *checkpoints) toolCall(callCtx engine.Context) error {
+	if c == nil || callCtx.Parent == nil || callCtx.ToolCategory != engine.NoCategory {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if (c.calls > 0 && c.toolCalls >= c.nextCalls) || (c.cost > 0 && c.spent >= c.nextCost) {
+		summary := CheckpointSummary{
+			ToolCalls:   c.toolCalls,
+			Cost:        c.spent,
+			Usage:       c.usage,
+			Elapsed:     time.Since(c.start),
+			RecentCalls: c.recentCalls,
+		}
+		resp, err := c.handler(callCtx, summary)
+		if err != nil {
+			return err
+		}
+		if !resp.Accept {
+			return &CheckpointStopError{
+				Summary: summary,
+				Message: resp.Message,
+			}
+		}
+
+		// Both budgets start over at each checkpoint, so that the user isn't asked again right away.
+		c.recentCalls = map[string]int{}
+		c.nextCalls = c.toolCalls + c.calls
+		c.nextCost = c.spent + c.cost
+	}
+
+	name := callCtx.Tool.Name
+	if name == "" {
+		name = callCtx.Tool.ID
+	}
+	c.toolCalls++
+	c.recentCalls[name]++
+	return nil
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoints(t *testing.T) {
+	var (
+		summaries []CheckpointSummary
+		accept    = true
+	)
+	r := &Runner{
+		checkpointCalls: 2,
+		checkpointCost:  1,
+		prices: map[string]config.Price{
+			"gpt-4o": {Prompt: 5, Completion: 15},
+		},
+		checkpointHandler: func(_ engine.Context, summary CheckpointSummary) (AuthorizerResponse, error) {
+			summaries = append(summaries, summary)
+			return AuthorizerResponse{Accept: accept, Message: "too expensive"}, nil
+		},
+	}
+
+	c := getCheckpoints(r.withCheckpoints(context.Background()))
+	require.NotNil(t, c)
+
+	var root engine.Context
+	call := func(name string) engine.Context {
+		var callCtx engine.Context
+		callCtx.Parent = &root
+		callCtx.Tool.Name = name
+		return callCtx
+	}
+
+	// The root tool and tools that are not called by the LLM are not counted.
+	require.NoError(t, c.toolCall(root))
+	credential := call("cred")
+	credential.ToolCategory = engine.CredentialToolCategory
+	require.NoError(t, c.toolCall(credential))
+
+	require.NoError(t, c.toolCall(call("search")))
+	require.NoError(t, c.toolCall(call("search")))
+	require.Empty(t, summaries)
+
+	// The third call pauses after two calls.
+	require.NoError(t, c.toolCall(call("fetch")))
+	require.Len(t, summaries, 1)
+	require.Equal(t, 2, summaries[0].ToolCalls)
+	require.Equal(t, map[string]int{"search": 2}, summaries[0].RecentCalls)
+
+	// Spending a dollar also pauses, and only the models with a price have a cost.
+	c.addUsage("gpt-4o", types.Usage{PromptTokens: 100_000, CompletionTokens: 50_000, TotalTokens: 150_000})
+	c.addUsage("local", types.Usage{PromptTokens: 1_000_000, TotalTokens: 1_000_000})
+	require.NoError(t, c.toolCall(call("fetch")))
+	require.Len(t, summaries, 2)
+	require.Equal(t, 1.25, summaries[1].Cost)
+	require.Equal(t, 1_150_000, summaries[1].Usage.TotalTokens)
+	require.Equal(t, "The run has made 3 tool calls and spent $1.25 using 1150000 tokens in 0s.\nSince the last checkpoint: fetch (1)", summaries[1].String())
+
+	// Declining stops the run.
+	accept = false
+	require.NoError(t, c.toolCall(call("fetch")))
+	err := c.toolCall(call("fetch"))
+	require.EqualError(t, err, "run stopped at checkpoint after 5 tool calls: too expensive")
+	require.ErrorAs(t, err, new(*CheckpointStopError))
+
+	// Without a handler, there are no checkpoints.
+	r.checkpointHandler = nil
+	require.Nil(t, getCheckpoints(r.withCheckpoints(context.Background())))
+}
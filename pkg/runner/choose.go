@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+var candidateNumber = regexp.MustCompile(`\d+`)
+
+// choose replaces the response of the tool with the candidate that is selected by the Choose strategy of the tool.
+func (r *Runner) choose(callCtx engine.Context, monitor Monitor, env []string, ret *engine.Return) error {
+	if len(ret.Candidates) < 2 {
+		ret.Candidates = nil
+		return nil
+	}
+
+	var chosen int
+	switch callCtx.Tool.Choose {
+	case types.ChooseFirst:
+	case types.ChooseShortest:
+		chosen = chooseByLength(ret.Candidates, func(a, b int) bool { return a < b })
+	case types.ChooseLongest:
+		chosen = chooseByLength(ret.Candidates, func(a, b int) bool { return a > b })
+	case "", types.ChooseMajority:
+		chosen = chooseMajority(ret.Candidates)
+	default:
+		var err error
+		chosen, err = r.judge(callCtx, monitor, env, ret.Candidates)
+		if err != nil {
+			return err
+		}
+	}
+
+	candidates := ret.Candidates
+	ret.Choose(chosen)
+	event := Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeCallChoose,
+		Content:     getEventContent(candidates[chosen], callCtx),
+		Candidates:  candidates,
+	}
+	if r.guardsOutput(callCtx) {
+		// The candidates are not checked by the guardrails, only the chosen response is.
+		event.Content, event.Candidates = "", nil
+	}
+	monitor.Event(event)
+	return nil
+}
+
+// judge asks the judge tool of the tool for the number of the best candidate.
+func (r *Runner) judge(callCtx engine.Context, monitor Monitor, env []string, candidates []string) (int, error) {
+	judgeToolRef, ok, err := callCtx.Tool.GetChooseTool()
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	inputData, err := json.Marshal(map[string]any{
+		"candidates": candidates,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal input for choose tool: %w", err)
+	}
+	res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, judgeToolRef.ToolID, string(inputData), "", engine.ChooseToolCategory, nil)
+	if err != nil {
+		return 0, err
+	}
+	if res.Result == nil {
+		return 0, fmt.Errorf("invalid state: choose tool [%s] can not result in a chat continuation", judgeToolRef.Reference)
+	}
+
+	// The judge responds with the number of the candidate, counting from 1, and possibly more text.
+	number, err := strconv.Atoi(candidateNumber.FindString(*res.Result))
+	if err != nil || number < 1 || number > len(candidates) {
+		return 0, fmt.Errorf("choose tool [%s] did not respond with the number of one of the %d candidates: %s", judgeToolRef.Reference, len(candidates), strings.TrimSpace(*res.Result))
+	}
+	return number - 1, nil
+}
+
+// chooseByLength returns the index of the first candidate whose length is better than all the others.
+func chooseByLength(candidates []string, better func(a, b int) bool) int {
+	var chosen int
+	for i, candidate := range candidates {
+		if better(len(strings.TrimSpace(candidate)), len(strings.TrimSpace(candidates[chosen]))) {
+			chosen = i
+		}
+	}
+	return chosen
+}
+
+// chooseMajority returns the index of the first candidate of the most common answer.
+func chooseMajority(candidates []string) int {
+	var (
+		chosen int
+		counts = map[string]int{}
+		first  = map[string]int{}
+	)
+	for i, candidate := range candidates {
+		answer := normalizedAnswer(candidate)
+		if _, ok := first[answer]; !ok {
+			first[answer] = i
+		}
+		counts[answer]++
+		if counts[answer] > counts[normalizedAnswer(candidates[chosen])] {
+			chosen = first[answer]
+		}
+	}
+	return chosen
+}
+
+// normalizedAnswer ignores the case, whitespace, and surrounding punctuation and quotes of an answer, so that
+// "Positive." and "positive" are the same answer.
+func normalizedAnswer(answer string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Trim(strings.TrimSpace(answer), ".!?\"'`")), " "))
+}
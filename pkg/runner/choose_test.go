@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMonitor struct {
+	noopMonitor
+	events []Event
+}
+
+func (r *recordingMonitor) Event(event Event) {
+	r.events = append(r.events, event)
+}
+
+func TestChoose(t *testing.T) {
+	candidates := []string{"Negative", "positive.", "a longer answer", "Positive", "no"}
+
+	for choose, expected := range map[string]string{
+		"":                   "positive.",
+		types.ChooseMajority: "positive.",
+		types.ChooseFirst:    "Negative",
+		types.ChooseShortest: "no",
+		types.ChooseLongest:  "a longer answer",
+	} {
+		ret := &engine.Return{
+			Result:     &candidates[0],
+			Candidates: candidates,
+			State: &engine.State{
+				Completion: types.CompletionRequest{
+					Messages: []types.CompletionMessage{{Content: types.Text(candidates[0])}},
+				},
+			},
+		}
+		var callCtx engine.Context
+		callCtx.Tool.Choose = choose
+
+		monitor := &recordingMonitor{}
+		require.NoError(t, (&Runner{}).choose(callCtx, monitor, nil, ret), choose)
+		require.Equal(t, expected, *ret.Result, choose)
+		require.Equal(t, expected, ret.State.Completion.Messages[0].ChatText(), choose)
+		require.Nil(t, ret.Candidates)
+
+		require.Len(t, monitor.events, 1)
+		require.Equal(t, EventTypeCallChoose, monitor.events[0].Type)
+		require.Equal(t, expected, monitor.events[0].Content)
+		require.Equal(t, candidates, monitor.events[0].Candidates)
+	}
+}
+
+func TestChooseMajorityTie(t *testing.T) {
+	// The answer that reaches the most votes first wins a tie.
+	require.Equal(t, 1, chooseMajority([]string{"a", "b", "b", "a"}))
+	require.Equal(t, 0, chooseMajority([]string{"a", "b", "c"}))
+}
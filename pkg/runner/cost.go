@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cost"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type runCostKey struct{}
+
+// runCost adds up the usage and cost of the calls that a run makes to models.
+type runCost struct {
+	prices cost.Prices
+
+	lock  sync.Mutex
+	usage types.Usage
+	cost  float64
+}
+
+// withRunCost adds the cost of a new run to the context. A run that is started by another one, such as to get a
+// credential, adds to the cost of the run that started it, and nil is returned for it.
+func (r *Runner) withRunCost(ctx context.Context) (context.Context, *runCost) {
+	if getRunCost(ctx) != nil {
+		return ctx, nil
+	}
+	c := &runCost{
+		prices: r.prices,
+	}
+	return context.WithValue(ctx, runCostKey{}, c), c
+}
+
+func getRunCost(ctx context.Context) *runCost {
+	c, _ := ctx.Value(runCostKey{}).(*runCost)
+	return c
+}
+
+// add adds the usage of a completion to the run and returns its cost.
+func (c *runCost) add(model string, usage types.Usage) float64 {
+	if c == nil {
+		return 0
+	}
+
+	callCost := c.prices.Cost(model, usage)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.usage.PromptTokens += usage.PromptTokens
+	c.usage.CompletionTokens += usage.CompletionTokens
+	c.usage.TotalTokens += usage.TotalTokens
+	c.usage.CacheReadTokens += usage.CacheReadTokens
+	c.usage.CacheWriteTokens += usage.CacheWriteTokens
+	c.cost += callCost
+	return callCost
+}
+
+// send sends the summary of the run to the monitor.
+func (c *runCost) send(monitor Monitor) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	monitor.Event(Event{
+		Time:  time.Now(),
+		Type:  EventTypeRunSummary,
+		Usage: c.usage,
+		Cost:  c.cost,
+	})
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cost"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCost(t *testing.T) {
+	r := &Runner{prices: cost.Prices{"model": {Prompt: 2, Completion: 10}}}
+
+	ctx, c := r.withRunCost(context.Background())
+	require.NotNil(t, c)
+
+	// A run started by the run adds to its cost.
+	nested, nestedCost := r.withRunCost(ctx)
+	require.Nil(t, nestedCost)
+
+	require.InDelta(t, 0.007, getRunCost(ctx).add("model", types.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}), 1e-9)
+	require.Zero(t, getRunCost(nested).add("unknown", types.Usage{PromptTokens: 100, TotalTokens: 100}))
+
+	monitor := &recordingMonitor{}
+	c.send(monitor)
+	nestedCost.send(monitor)
+	require.Len(t, monitor.events, 1)
+	require.Equal(t, EventTypeRunSummary, monitor.events[0].Type)
+	require.Equal(t, types.Usage{PromptTokens: 1100, CompletionTokens: 500, TotalTokens: 1600}, monitor.events[0].Usage)
+	require.InDelta(t, 0.007, monitor.events[0].Cost, 1e-9)
+
+	// Without a run, completions cost nothing.
+	require.Zero(t, getRunCost(context.Background()).add("model", types.Usage{PromptTokens: 1000}))
+}
@@ -1,9 +1,15 @@
 package runner
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"golang.org/x/exp/maps"
 )
 
 // parseCredentialOverrides parses a string of credential overrides that the user provided as a command line arg.
@@ -41,3 +47,114 @@ func parseCredentialOverrides(overrides []string) (map[string]map[string]string,
 
 	return credentialOverrides, nil
 }
+
+// handleCredentialArguments adds the parameters of the tool that are filled from a credential to its input. The
+// credentials of the tool are used when they have the name, and otherwise the credential is read from the store.
+func (r *Runner) handleCredentialArguments(callCtx engine.Context, monitor Monitor, creds map[string]map[string]string, input string) (string, error) {
+	// The input of a prompt is sent to the LLM, which is what the credential parameters are meant to prevent.
+	if !callCtx.Tool.IsCommand() || callCtx.Tool.IsAgents() {
+		return "", fmt.Errorf("tool %s has parameters from credentials, which are only supported by tools that are not prompts", callCtx.Tool.Name)
+	}
+
+	args := map[string]any{}
+	if strings.TrimSpace(input) != "" {
+		if err := json.Unmarshal([]byte(input), &args); err != nil {
+			return "", fmt.Errorf("input of tool %s must be a JSON object to add parameters from credentials: %w", callCtx.Tool.Name, err)
+		}
+	}
+
+	for name, source := range callCtx.Tool.CredentialArguments {
+		credName, key := types.ParseCredentialArgument(source)
+
+		values, ok := creds[credName]
+		if !ok {
+			var err error
+			values, err = r.getStoredCredential(callCtx, monitor, credName)
+			if err != nil {
+				return "", fmt.Errorf("failed to get credential %s for parameter %s: %w", credName, name, err)
+			}
+		}
+
+		value, err := credentialValue(values, credName, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to get parameter %s: %w", name, err)
+		}
+		args[name] = value
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// getStoredCredential returns the values of a credential that the tool doesn't declare, from the credential overrides
+// or the store.
+func (r *Runner) getStoredCredential(callCtx engine.Context, monitor Monitor, credName string) (map[string]string, error) {
+	r.credMutex.Lock()
+	defer r.credMutex.Unlock()
+
+	if r.credOverrides != nil {
+		credOverrides, err := parseCredentialOverrides(r.credOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse credential overrides: %w", err)
+		}
+		if override, exists := credOverrides[credName]; exists {
+			return override, nil
+		}
+	}
+
+	rm := runtimeWithLogger(callCtx, monitor, r.runtimeManager)
+	if err := rm.EnsureCredentialHelpers(callCtx.Ctx); err != nil {
+		return nil, fmt.Errorf("failed to setup credential helpers: %w", err)
+	}
+
+	c, exists, err := r.credStore.Get(callCtx.Ctx, credName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || c.IsExpired() {
+		return nil, fmt.Errorf("credential is not in the store, add a credential tool with the alias %s to the tool to set it", credName)
+	}
+
+	if err := r.authorizeCredential(callCtx, credName); err != nil {
+		return nil, err
+	}
+	return c.Env, nil
+}
+
+// credentialValue returns the value with the key of a credential, or its only value when the key is empty.
+func credentialValue(values map[string]string, credName, key string) (string, error) {
+	if key != "" {
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("credential %s has no value %s", credName, key)
+		}
+		return value, nil
+	}
+
+	if len(values) == 1 {
+		for _, value := range values {
+			return value, nil
+		}
+	}
+
+	keys := maps.Keys(values)
+	sort.Strings(keys)
+	return "", fmt.Errorf("credential %s has %d values, use %s%s#<name> with one of %s", credName, len(values), types.CredentialArgumentPrefix, credName, strings.Join(keys, ", "))
+}
+
+// credentialSecrets returns the values of the credentials, sorted, so that they are redacted from the command lines in
+// the events of the call.
+func credentialSecrets(creds map[string]map[string]string) (result []string) {
+	for _, values := range creds {
+		for _, value := range values {
+			if value != "" {
+				result = append(result, value)
+			}
+		}
+	}
+	sort.Strings(result)
+	return
+}
@@ -1,9 +1,14 @@
 package runner
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -131,3 +136,135 @@ func TestParseCredentialOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeCredential(t *testing.T) {
+	var (
+		asked    []string
+		response AuthorizerResponse
+	)
+	r := &Runner{
+		credAuth: func(ctx engine.Context, credName string) (AuthorizerResponse, error) {
+			asked = append(asked, ctx.Tool.ID+" "+credName)
+			return response, nil
+		},
+		credApprovals: map[string]bool{},
+	}
+
+	call := func(runID, toolID string) engine.Context {
+		var root, callCtx engine.Context
+		root.ID = runID
+		callCtx.Ctx = context.Background()
+		callCtx.ID = runID + "-call"
+		callCtx.Parent = &root
+		callCtx.Tool.ID = toolID
+		return callCtx
+	}
+
+	// A denied credential is asked for again.
+	err := r.authorizeCredential(call("run1", "tool1"), "github")
+	require.Equal(t, &CredentialDeniedError{CredName: "github"}, err)
+	require.EqualError(t, err, "Use of credential github has been denied")
+
+	// Approving is remembered for the tool in the run.
+	response.Accept = true
+	require.NoError(t, r.authorizeCredential(call("run1", "tool1"), "github"))
+	require.NoError(t, r.authorizeCredential(call("run1", "tool1"), "github"))
+	require.NoError(t, r.authorizeCredential(call("run1", "tool2"), "github"))
+	require.NoError(t, r.authorizeCredential(call("run2", "tool1"), "github"))
+	require.Equal(t, []string{"tool1 github", "tool1 github", "tool2 github", "tool1 github"}, asked)
+
+	// Remembering applies to the later runs as well.
+	asked = nil
+	response.Remember = true
+	require.NoError(t, r.authorizeCredential(call("run3", "tool1"), "slack"))
+	require.NoError(t, r.authorizeCredential(call("run4", "tool1"), "slack"))
+	require.Equal(t, []string{"tool1 slack"}, asked)
+
+	// Nothing is asked without an authorizer.
+	require.NoError(t, (&Runner{}).authorizeCredential(call("run1", "tool1"), "github"))
+}
+
+func TestHandleCredentialArguments(t *testing.T) {
+	var callCtx engine.Context
+	callCtx.Tool.Name = "clone"
+	callCtx.Tool.Instructions = "#!/bin/bash"
+	callCtx.Tool.CredentialArguments = map[string]string{
+		"token": "github-token",
+		"user":  "login#USER",
+	}
+
+	creds := map[string]map[string]string{
+		"github-token": {"GITHUB_TOKEN": "secret"},
+		"login":        {"USER": "me", "PASSWORD": "hunter2"},
+	}
+
+	input, err := (&Runner{}).handleCredentialArguments(callCtx, noopMonitor{}, creds, `{"repo": "gptscript"}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"repo": "gptscript", "token": "secret", "user": "me"}`, input)
+
+	callCtx.Tool.CredentialArguments["password"] = "login"
+	_, err = (&Runner{}).handleCredentialArguments(callCtx, noopMonitor{}, creds, "")
+	require.EqualError(t, err, "failed to get parameter password: credential login has 2 values, use credential:login#<name> with one of PASSWORD, USER")
+
+	callCtx.Tool.Instructions = "Clone the repository"
+	_, err = (&Runner{}).handleCredentialArguments(callCtx, noopMonitor{}, creds, "")
+	require.ErrorContains(t, err, "only supported by tools that are not prompts")
+}
+
+type noopRuntimeManager struct{}
+
+func (noopRuntimeManager) GetContext(_ context.Context, _ types.Tool, cmd, env []string) (string, []string, error) {
+	return "", env, nil
+}
+
+func (noopRuntimeManager) EnsureCredentialHelpers(context.Context) error {
+	return nil
+}
+
+func (noopRuntimeManager) SetUpCredentialHelpers(context.Context, *config.CLIConfig, []string) error {
+	return nil
+}
+
+type memoryCredentialStore struct {
+	credentials.NoopStore
+	added []credentials.Credential
+}
+
+func (m *memoryCredentialStore) Add(_ context.Context, cred credentials.Credential) error {
+	m.added = append(m.added, cred)
+	return nil
+}
+
+func TestCredentialRequester(t *testing.T) {
+	store := &memoryCredentialStore{}
+	var requested []string
+	r := &Runner{
+		credStore:      store,
+		runtimeManager: noopRuntimeManager{},
+		credRequester: func(_ engine.Context, credName string, args map[string]any) (*credentials.Credential, error) {
+			requested = append(requested, credName)
+			return &credentials.Credential{Env: map[string]string{"CRM_TOKEN": "secret"}}, nil
+		},
+	}
+
+	var callCtx engine.Context
+	callCtx.Ctx = context.Background()
+	callCtx.Program = &types.Program{ToolSet: types.ToolSet{}}
+	callCtx.Tool.Name = "support"
+	callCtx.Tool.Credentials = []string{"crm-login as crm"}
+	callCtx.Tool.ToolMapping = map[string][]types.ToolReference{
+		"crm-login as crm": {{Reference: "crm-login", ToolID: "crm.gpt:crm-login"}},
+	}
+
+	// The credential tool isn't run when the embedder provides the credential, which is stored like its output.
+	env, creds, err := r.handleCredentials(callCtx, noopMonitor{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"crm"}, requested)
+	require.Equal(t, []string{"CRM_TOKEN=secret"}, env)
+	require.Equal(t, map[string]map[string]string{"crm": {"CRM_TOKEN": "secret"}}, creds)
+	require.Equal(t, []credentials.Credential{{
+		ToolName: "crm",
+		Type:     credentials.CredentialTypeTool,
+		Env:      map[string]string{"CRM_TOKEN": "secret"},
+	}}, store.added)
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/prompt"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// EnvVarCredentialPrefix starts the name of the credential that an environment variable of a tool is saved as.
+const EnvVarCredentialPrefix = "env:"
+
+// envVarCredentialName returns the name of the credential that an environment variable of the tool is saved as. It is
+// scoped to the tool, so that other tools that declare a variable of the same name don't get its value.
+func envVarCredentialName(tool types.Tool, name string) string {
+	return EnvVarCredentialPrefix + name + "@" + tool.ID
+}
+
+// handleEnvVars adds the required environment variables of the tool that are not set to the environment. Their values
+// come from an earlier call of the tool in the run, a credential saved for the tool, or else the user is prompted for
+// them.
+func (r *Runner) handleEnvVars(callCtx engine.Context, monitor Monitor, env []string) ([]string, error) {
+	var missing []string
+	for _, envVar := range callCtx.Tool.EnvVars {
+		if !envVar.Optional && lookupEnv(env, envVar.Name) == "" {
+			missing = append(missing, envVar.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return env, nil
+	}
+
+	// Like credential tools, only prompt for one tool at a time.
+	r.credMutex.Lock()
+	defer r.credMutex.Unlock()
+
+	var (
+		prefix   = sessionID(callCtx) + "\x00" + callCtx.Tool.ID + "\x00"
+		prompted []string
+	)
+	for _, name := range missing {
+		if value := r.envVarValues[prefix+name]; value != "" {
+			env = append(env, name+"="+value)
+			continue
+		}
+
+		value, err := r.getSavedEnvVar(callCtx, monitor, name)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			r.envVarValues[prefix+name] = value
+			env = append(env, name+"="+value)
+			continue
+		}
+
+		prompted = append(prompted, name)
+	}
+	if len(prompted) == 0 {
+		return env, nil
+	}
+
+	values, err := r.promptEnvVars(callCtx, env, prompted)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range prompted {
+		value := values[name]
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s is required by tool %s", name, callCtx.Tool.Name)
+		}
+
+		if r.saveEnvVars && r.credStore != nil {
+			if err := r.credStore.Add(callCtx.Ctx, credentials.Credential{
+				ToolName: envVarCredentialName(callCtx.Tool, name),
+				Type:     credentials.CredentialTypeTool,
+				Env: map[string]string{
+					name: value,
+				},
+			}); err != nil {
+				return nil, fmt.Errorf("failed to save environment variable %s: %w", name, err)
+			}
+		}
+
+		r.envVarValues[prefix+name] = value
+		env = append(env, name+"="+value)
+	}
+
+	return env, nil
+}
+
+// getSavedEnvVar returns the value of an environment variable that was saved as a credential, or an empty string if
+// it wasn't saved. It must be called with the credMutex held.
+func (r *Runner) getSavedEnvVar(callCtx engine.Context, monitor Monitor, name string) (string, error) {
+	if r.credStore == nil {
+		return "", nil
+	}
+
+	rm := runtimeWithLogger(callCtx, monitor, r.runtimeManager)
+	if err := rm.EnsureCredentialHelpers(callCtx.Ctx); err != nil {
+		return "", fmt.Errorf("failed to setup credential helpers: %w", err)
+	}
+
+	credName := envVarCredentialName(callCtx.Tool, name)
+	c, exists, err := r.credStore.Get(callCtx.Ctx, credName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get saved environment variable %s: %w", name, err)
+	}
+	if !exists || c.IsExpired() || c.Env[name] == "" {
+		return "", nil
+	}
+
+	if err := r.authorizeCredential(callCtx, credName); err != nil {
+		return "", err
+	}
+	return c.Env[name], nil
+}
+
+// promptEnvVars asks the user for the values of environment variables through the prompt server, which is the
+// terminal, or a prompt event of the SDK server.
+func (r *Runner) promptEnvVars(callCtx engine.Context, env, names []string) (map[string]string, error) {
+	message := fmt.Sprintf("Tool %s needs the following environment variables:", callCtx.Tool.Name)
+	for _, envVar := range callCtx.Tool.EnvVars {
+		if !slices.Contains(names, envVar.Name) {
+			continue
+		}
+		message += "\n  " + envVar.Name
+		if envVar.Description != "" {
+			message += ": " + envVar.Description
+		}
+	}
+
+	input, err := json.Marshal(map[string]string{
+		"message":   message,
+		"fields":    strings.Join(names, ","),
+		"sensitive": "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := prompt.SysPrompt(callCtx.Ctx, env, string(input), nil)
+	if err != nil {
+		return nil, fmt.Errorf("environment variables %s are required by tool %s and could not be prompted for: %w", strings.Join(names, ", "), callCtx.Tool.Name, err)
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(result), &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the values of environment variables %s: %w", strings.Join(names, ", "), err)
+	}
+	return values, nil
+}
+
+// lookupEnv returns the value of the last definition of the environment variable, like the command would see it.
+func lookupEnv(env []string, name string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(env[i], name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
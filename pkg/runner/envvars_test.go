@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEnvVars(t *testing.T) {
+	var (
+		prompts []types.Prompt
+		answer  map[string]string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.Prompt
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		prompts = append(prompts, req)
+		require.NoError(t, json.NewEncoder(w).Encode(answer))
+	}))
+	defer s.Close()
+
+	r := &Runner{
+		envVarValues: map[string]string{},
+	}
+
+	call := func(runID string) engine.Context {
+		var root, callCtx engine.Context
+		root.ID = runID
+		callCtx.Ctx = context.Background()
+		callCtx.ID = runID + "-call"
+		callCtx.Parent = &root
+		callCtx.Tool.ID = "deploy.gpt:deploy"
+		callCtx.Tool.Name = "deploy"
+		callCtx.Tool.EnvVars = []types.EnvVar{
+			{Name: "API_URL", Description: "the URL of the API"},
+			{Name: "REGION"},
+			{Name: "DEBUG", Optional: true},
+		}
+		return callCtx
+	}
+	env := []string{types.PromptURLEnvVar + "=" + s.URL, "REGION=us-east-1"}
+
+	// Only the required variables that are not set are prompted for.
+	answer = map[string]string{"API_URL": "https://api.example.com"}
+	newEnv, err := r.handleEnvVars(call("run1"), noopMonitor{}, env)
+	require.NoError(t, err)
+	require.Equal(t, append(env, "API_URL=https://api.example.com"), newEnv)
+	require.Equal(t, []types.Prompt{{
+		Message:   "Tool deploy needs the following environment variables:\n  API_URL: the URL of the API",
+		Fields:    []string{"API_URL"},
+		Sensitive: true,
+	}}, prompts)
+
+	// The value is reused for the rest of the run.
+	newEnv, err = r.handleEnvVars(call("run1"), noopMonitor{}, env)
+	require.NoError(t, err)
+	require.Equal(t, append(env, "API_URL=https://api.example.com"), newEnv)
+	require.Len(t, prompts, 1)
+
+	// Other tools that declare the variable are asked for their own value.
+	other := call("run1")
+	other.Tool.ID = "other.gpt:other"
+	answer = map[string]string{"API_URL": "https://other.example.com"}
+	newEnv, err = r.handleEnvVars(other, noopMonitor{}, env)
+	require.NoError(t, err)
+	require.Equal(t, append(env, "API_URL=https://other.example.com"), newEnv)
+	require.Len(t, prompts, 2)
+	require.Equal(t, "env:API_URL@deploy.gpt:deploy", envVarCredentialName(call("run1").Tool, "API_URL"))
+
+	// A value that is left empty fails the call.
+	answer = map[string]string{"API_URL": ""}
+	_, err = r.handleEnvVars(call("run2"), noopMonitor{}, env)
+	require.EqualError(t, err, "environment variable API_URL is required by tool deploy")
+
+	// Without a prompt server, the error names the missing variables.
+	_, err = r.handleEnvVars(call("run3"), noopMonitor{}, []string{"PATH=/bin"})
+	require.ErrorContains(t, err, "environment variables API_URL, REGION are required by tool deploy and could not be prompted for")
+}
@@ -0,0 +1,320 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+type GuardrailAction string
+
+const (
+	// GuardrailBlock fails the call, GuardrailRedact replaces the text with the redacted text from the classifier
+	// tool, and GuardrailFlag only sends the EventTypeCallGuardrail event.
+	GuardrailBlock  GuardrailAction = "block"
+	GuardrailRedact GuardrailAction = "redact"
+	GuardrailFlag   GuardrailAction = "flag"
+
+	guardrailInput  = "input"
+	guardrailOutput = "output"
+)
+
+// GuardrailLoadFunc loads the program of the classifier tool of a guardrail.
+type GuardrailLoadFunc func(ctx context.Context, tool string) (types.Program, error)
+
+// GuardrailResult is what a guardrail flagged, for EventTypeCallGuardrail events.
+type GuardrailResult struct {
+	// Tool is the classifier tool of the guardrail.
+	Tool string `json:"tool"`
+	// Target is input for the input of a run or a chat, and output for a response of the model.
+	Target     string          `json:"target"`
+	Action     GuardrailAction `json:"action"`
+	Categories []string        `json:"categories,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+}
+
+type guardrail struct {
+	tool          string
+	input, output bool
+	action        GuardrailAction
+
+	lock    sync.Mutex
+	program *types.Program
+}
+
+// parseGuardrails parses guardrails in the format [input:|output:]<block|redact|flag>=<tool>. A guardrail without
+// input: or output: checks both.
+func parseGuardrails(specs []string) (result []*guardrail, _ error) {
+	for _, spec := range specs {
+		g := &guardrail{
+			input:  true,
+			output: true,
+		}
+
+		rest := spec
+		if target, after, ok := strings.Cut(spec, ":"); ok && (target == guardrailInput || target == guardrailOutput) {
+			g.input, g.output = target == guardrailInput, target == guardrailOutput
+			rest = after
+		}
+
+		action, tool, _ := strings.Cut(rest, "=")
+		g.action, g.tool = GuardrailAction(strings.TrimSpace(action)), strings.TrimSpace(tool)
+		switch g.action {
+		case GuardrailBlock, GuardrailRedact, GuardrailFlag:
+		default:
+			return nil, fmt.Errorf("invalid guardrail %q, must be [input:|output:]<block|redact|flag>=<tool>", spec)
+		}
+		if g.tool == "" {
+			return nil, fmt.Errorf("invalid guardrail %q, must be [input:|output:]<block|redact|flag>=<tool>", spec)
+		}
+		result = append(result, g)
+	}
+	return
+}
+
+// load loads the program of the classifier tool the first time the guardrail is used.
+func (g *guardrail) load(ctx context.Context, load GuardrailLoadFunc) (*types.Program, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.program == nil {
+		prg, err := load(ctx, g.tool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load guardrail tool %s: %w", g.tool, err)
+		}
+		g.program = &prg
+	}
+	return g.program, nil
+}
+
+// guardrailResponse is what the classifier tool of a guardrail responds with, as a JSON object.
+type guardrailResponse struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories"`
+	Reason     string   `json:"reason"`
+	// Redacted is the text without what was flagged, which replaces it for guardrails that redact.
+	Redacted *string `json:"redacted"`
+}
+
+// inGuardrail returns whether the call is made for a guardrail, so that its classifier is not checked by guardrails.
+func inGuardrail(callCtx engine.Context) bool {
+	for c := &callCtx; c != nil; c = c.Parent {
+		if c.ToolCategory == engine.GuardrailToolCategory {
+			return true
+		}
+	}
+	return false
+}
+
+// guardsOutput returns whether the responses of the model to the call are checked by guardrails, so that their text
+// is withheld from events until they were.
+func (r *Runner) guardsOutput(callCtx engine.Context) bool {
+	for _, g := range r.guardrails {
+		if g.output {
+			return !inGuardrail(callCtx)
+		}
+	}
+	return false
+}
+
+// withheldEvents are the EventTypeChat events with the responses of calls whose output is checked by guardrails, by
+// the ID of the call, until the guardrails checked them.
+type withheldEvents struct {
+	lock   sync.Mutex
+	events map[string][]Event
+}
+
+func (w *withheldEvents) add(callID string, event Event) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.events == nil {
+		w.events = map[string][]Event{}
+	}
+	w.events[callID] = append(w.events[callID], event)
+}
+
+func (w *withheldEvents) take(callID string) []Event {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	events := w.events[callID]
+	delete(w.events, callID)
+	return events
+}
+
+// withholdFunc returns the function that withholds the responses of the call, or nil if they are sent as they are.
+func (r *Runner) withholdFunc(callCtx engine.Context) func(Event) {
+	if !r.guardsOutput(callCtx) {
+		return nil
+	}
+	return func(event Event) {
+		r.withheld.add(callCtx.ID, event)
+	}
+}
+
+// releaseWithheld sends the withheld events of the call as they are, for responses that are not the output of the
+// call, such as the ones with tool calls.
+func (r *Runner) releaseWithheld(callCtx engine.Context, monitor Monitor) {
+	for _, event := range r.withheld.take(callCtx.ID) {
+		monitor.Event(event)
+	}
+}
+
+// dropWithheld sends the withheld events of the call without their responses, so that their usage is still
+// reported, for responses that failed validation or were blocked.
+func (r *Runner) dropWithheld(callCtx engine.Context, monitor Monitor) {
+	for _, event := range r.withheld.take(callCtx.ID) {
+		monitor.Event(withoutResponse(event))
+	}
+}
+
+// releaseOutput sends the withheld events of the call once the guardrails checked its output. The response is
+// replaced by the output when a guardrail redacted it. The output of the run is sent in one EventTypeRunOutput event,
+// since it was not streamed.
+func (r *Runner) releaseOutput(callCtx engine.Context, monitor Monitor, output string, redacted bool) {
+	if !r.guardsOutput(callCtx) {
+		return
+	}
+
+	for _, event := range r.withheld.take(callCtx.ID) {
+		if redacted {
+			event = withoutResponse(event)
+			event.ChatResponse = types.CompletionMessage{
+				Role:    types.CompletionMessageRoleTypeAssistant,
+				Content: types.Text(output),
+			}
+		}
+		monitor.Event(event)
+	}
+
+	if callCtx.Parent == nil && callCtx.ToolCategory == engine.NoCategory && output != "" {
+		monitor.Event(Event{
+			Time:        time.Now(),
+			CallContext: callCtx.GetCallContext(),
+			Type:        EventTypeRunOutput,
+			Content:     output,
+		})
+	}
+}
+
+func withoutResponse(event Event) Event {
+	event.ChatResponse = nil
+	event.ChatLogProbs = nil
+	event.ChatPayload = nil
+	return event
+}
+
+// guardrailMonitor sends the events of the calls of classifier tools without their content, which has the text
+// that they check.
+type guardrailMonitor struct {
+	Monitor
+}
+
+func (m guardrailMonitor) Event(event Event) {
+	event = withoutResponse(event)
+	event.Content = ""
+	event.ChatRequest = nil
+	event.Candidates = nil
+	m.Monitor.Event(event)
+}
+
+// guard checks the user input or the response of the model of a call with the guardrails for the target, and returns
+// the text with the actions of the guardrails that flagged it taken.
+func (r *Runner) guard(callCtx engine.Context, monitor Monitor, env []string, target, text string) (string, error) {
+	if len(r.guardrails) == 0 || inGuardrail(callCtx) {
+		return text, nil
+	}
+
+	for _, g := range r.guardrails {
+		if target == guardrailInput && !g.input || target == guardrailOutput && !g.output {
+			continue
+		}
+
+		resp, err := r.classify(callCtx, monitor, env, g, target, text)
+		if err != nil {
+			return "", err
+		}
+		if !resp.Flagged {
+			continue
+		}
+
+		monitor.Event(Event{
+			Time:        time.Now(),
+			CallContext: callCtx.GetCallContext(),
+			Type:        EventTypeCallGuardrail,
+			Guardrail: &GuardrailResult{
+				Tool:       g.tool,
+				Target:     target,
+				Action:     g.action,
+				Categories: resp.Categories,
+				Reason:     resp.Reason,
+			},
+		})
+
+		switch g.action {
+		case GuardrailBlock:
+			reason := resp.Reason
+			if reason == "" {
+				reason = strings.Join(resp.Categories, ", ")
+			}
+			return "", fmt.Errorf("the %s of tool %s was blocked by guardrail %s: %s", target, types.FirstSet(callCtx.Tool.Name, callCtx.Tool.ID), g.tool, reason)
+		case GuardrailRedact:
+			if resp.Redacted != nil {
+				text = *resp.Redacted
+			} else {
+				text = "[REDACTED]"
+			}
+		}
+	}
+
+	return text, nil
+}
+
+// classify calls the classifier tool of the guardrail with the text, as a call of the tool that the text is the input
+// or the response of.
+func (r *Runner) classify(callCtx engine.Context, monitor Monitor, env []string, g *guardrail, target, text string) (guardrailResponse, error) {
+	var resp guardrailResponse
+
+	prg, err := g.load(callCtx.Ctx, r.loadGuardrail)
+	if err != nil {
+		return resp, err
+	}
+
+	input, err := json.Marshal(map[string]any{
+		"text":   text,
+		"target": target,
+	})
+	if err != nil {
+		return resp, fmt.Errorf("failed to marshal input for guardrail tool: %w", err)
+	}
+
+	guardCtx, err := engine.NewContext(engine.WithToolCategory(callCtx.Ctx, engine.GuardrailToolCategory), prg, string(input))
+	if err != nil {
+		return resp, err
+	}
+	guardCtx.Parent = &callCtx
+
+	res, err := r.call(guardCtx, guardrailMonitor{Monitor: monitor}, env, string(input))
+	if err != nil {
+		return resp, err
+	}
+	if res.Result == nil {
+		return resp, fmt.Errorf("invalid state: guardrail tool [%s] can not result in a chat continuation", g.tool)
+	}
+
+	// Classifiers that are prompts may wrap the object in other text.
+	output := *res.Result
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return resp, fmt.Errorf("guardrail tool [%s] did not respond with a JSON object: %s", g.tool, strings.TrimSpace(output))
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &resp); err != nil {
+		return resp, fmt.Errorf("guardrail tool [%s] did not respond with a valid JSON object: %w", g.tool, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+const piiClassifier = `
+name: pii
+args: text: the text to check
+args: target: input or output
+
+#!/bin/bash
+case "${TEXT}" in
+*555-1234*) echo "The text has a phone number: {\"flagged\": true, \"categories\": [\"pii\"], \"reason\": \"phone number\", \"redacted\": \"${TEXT//555-1234/[PHONE]}\"}" ;;
+*) echo '{"flagged": false}' ;;
+esac
+`
+
+// phoneModel responds with a phone number, and records the last user message of each request.
+type phoneModel struct {
+	inputs []string
+}
+
+func (m *phoneModel) Call(_ context.Context, req types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	m.inputs = append(m.inputs, req.Messages[len(req.Messages)-1].ChatText())
+	resp := &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text("my number is 555-1234"),
+	}
+	status <- types.CompletionStatus{CompletionID: "1", PartialResponse: resp}
+	status <- types.CompletionStatus{CompletionID: "1", Request: req, Response: resp}
+	return resp, nil
+}
+
+type recordingFactory struct {
+	noopFactory
+	monitor *recordingMonitor
+}
+
+func (f recordingFactory) Start(context.Context, *types.Program, []string, string) (Monitor, error) {
+	return f.monitor, nil
+}
+
+func TestParseGuardrails(t *testing.T) {
+	guardrails, err := parseGuardrails([]string{"block=github.com/acme/jailbreak", "output:redact=./pii.gpt", "input:flag=https://example.com/toxicity.gpt"})
+	require.NoError(t, err)
+	require.Len(t, guardrails, 3)
+	require.Equal(t, "github.com/acme/jailbreak", guardrails[0].tool)
+	require.True(t, guardrails[0].input && guardrails[0].output)
+	require.Equal(t, GuardrailRedact, guardrails[1].action)
+	require.True(t, !guardrails[1].input && guardrails[1].output)
+	require.Equal(t, "https://example.com/toxicity.gpt", guardrails[2].tool)
+	require.True(t, guardrails[2].input && !guardrails[2].output)
+
+	for _, spec := range []string{"pii.gpt", "warn=pii.gpt", "input:block=", "both:block=pii.gpt"} {
+		_, err = parseGuardrails([]string{spec})
+		require.ErrorContains(t, err, "invalid guardrail", spec)
+	}
+}
+
+func TestGuardrails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	prg, err := loader.ProgramFromSource(context.Background(), "name: phone\n\nRepeat the phone number of the user", "")
+	require.NoError(t, err)
+
+	run := func(guardrails ...string) (*phoneModel, *recordingMonitor, string, error) {
+		model, monitor := &phoneModel{}, &recordingMonitor{}
+		r, err := New(model, credentials.NoopStore{}, Options{
+			Sequential:     true,
+			MonitorFactory: recordingFactory{monitor: monitor},
+			Guardrails:     guardrails,
+			LoadGuardrail: func(ctx context.Context, _ string) (types.Program, error) {
+				return loader.ProgramFromSource(ctx, piiClassifier, "")
+			},
+		})
+		require.NoError(t, err)
+		output, err := r.Run(context.Background(), prg, nil, "call me at 555-1234")
+		return model, monitor, output, err
+	}
+
+	guardrailEvents := func(monitor *recordingMonitor) (result []GuardrailResult) {
+		for _, e := range monitor.events {
+			if e.Type == EventTypeCallGuardrail {
+				result = append(result, *e.Guardrail)
+			}
+		}
+		return
+	}
+
+	// leaked returns the events that have the text, which is only sent when the guardrails let it through.
+	leaked := func(monitor *recordingMonitor, text string) (result []EventType) {
+		for _, e := range monitor.events {
+			data, err := json.Marshal(e)
+			require.NoError(t, err)
+			if strings.Contains(string(data), text) {
+				result = append(result, e.Type)
+			}
+		}
+		return
+	}
+
+	// The input is redacted before the model sees it, and the response is only flagged.
+	model, monitor, output, err := run("input:redact=pii", "output:flag=pii")
+	require.NoError(t, err)
+	require.Equal(t, []string{"call me at [PHONE]"}, model.inputs)
+	require.Equal(t, "my number is 555-1234", output)
+	require.Equal(t, []GuardrailResult{
+		{Tool: "pii", Target: "input", Action: GuardrailRedact, Categories: []string{"pii"}, Reason: "phone number"},
+		{Tool: "pii", Target: "output", Action: GuardrailFlag, Categories: []string{"pii"}, Reason: "phone number"},
+	}, guardrailEvents(monitor))
+	require.Empty(t, leaked(monitor, "call me at 555-1234"))
+
+	// The response is not streamed, and only the redacted response is sent.
+	_, monitor, output, err = run("output:redact=pii")
+	require.NoError(t, err)
+	require.Equal(t, "my number is [PHONE]", output)
+	require.Empty(t, leaked(monitor, "my number is 555-1234"))
+	require.Contains(t, leaked(monitor, "my number is [PHONE]"), EventTypeChat)
+	require.Contains(t, leaked(monitor, "my number is [PHONE]"), EventTypeRunOutput)
+
+	_, monitor, _, err = run("output:block=pii")
+	require.EqualError(t, err, "the output of tool phone was blocked by guardrail pii: phone number")
+	require.Empty(t, leaked(monitor, "my number is"))
+
+	model, monitor, _, err = run("input:block=pii")
+	require.EqualError(t, err, "the input of tool phone was blocked by guardrail pii: phone number")
+	require.Empty(t, model.inputs)
+	require.Empty(t, leaked(monitor, "call me at"))
+}
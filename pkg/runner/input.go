@@ -20,7 +20,7 @@ func (r *Runner) handleInput(callCtx engine.Context, monitor Monitor, env []stri
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal input: %w", err)
 		}
-		res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, inputToolRef.ToolID, string(inputData), "", engine.InputToolCategory)
+		res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, inputToolRef.ToolID, string(inputData), "", engine.InputToolCategory, nil)
 		if err != nil {
 			return "", err
 		}
@@ -6,6 +6,12 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
+// NoopMonitorFactory returns a factory of monitors that ignore the events of runs, for when their progress must not
+// be shown.
+func NoopMonitorFactory() MonitorFactory {
+	return noopFactory{}
+}
+
 type noopFactory struct{}
 
 func (n noopFactory) Start(context.Context, *types.Program, []string, string) (Monitor, error) {
@@ -26,3 +32,26 @@ func (n noopMonitor) Stop(context.Context, string, error) {}
 func (n noopMonitor) Pause() func() {
 	return func() {}
 }
+
+// labelMonitor adds the labels of the run to every event.
+type labelMonitor struct {
+	Monitor
+	labels map[string]string
+}
+
+func withLabels(monitor Monitor, labels map[string]string) Monitor {
+	if len(labels) == 0 {
+		return monitor
+	}
+	return labelMonitor{
+		Monitor: monitor,
+		labels:  labels,
+	}
+}
+
+func (l labelMonitor) Event(event Event) {
+	if event.Labels == nil {
+		event.Labels = l.labels
+	}
+	l.Monitor.Event(event)
+}
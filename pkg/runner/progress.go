@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// ProgressPhase is what a run is doing, at a level that is stable enough for a UI to show.
+type ProgressPhase string
+
+const (
+	// ProgressPhaseLoading is reported when a run starts, before the entry tool is prepared.
+	ProgressPhaseLoading ProgressPhase = "loading"
+	// ProgressPhaseResolvingTools is reported while the context and credential tools of a tool run.
+	ProgressPhaseResolvingTools ProgressPhase = "resolving-tools"
+	// ProgressPhaseInstallingRuntime is reported while the runtime or dependencies of a tool are set up.
+	ProgressPhaseInstallingRuntime ProgressPhase = "installing-runtime"
+	// ProgressPhaseCallingModel is reported when a request is sent to the model.
+	ProgressPhaseCallingModel ProgressPhase = "calling-model"
+	// ProgressPhaseRunningTool is reported when a tool is called, and as the tool calls that the model requested
+	// together finish.
+	ProgressPhaseRunningTool ProgressPhase = "running-tool"
+)
+
+// Progress is the high-level state of a run, sent in EventTypeProgress events.
+type Progress struct {
+	Phase ProgressPhase `json:"phase"`
+	// Tool is the name of the tool that the phase is for.
+	Tool string `json:"tool,omitempty"`
+	// Percent is how much of the phase is done, and is only set when it is known.
+	Percent *int   `json:"percent,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func sendProgress(callCtx *engine.Context, monitor Monitor, progress Progress) {
+	if progress.Tool == "" {
+		progress.Tool = callCtx.Tool.Name
+	}
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeProgress,
+		Progress:    &progress,
+	})
+}
+
+// percent returns done as a percentage of total.
+func percent(done, total int) *int {
+	if total <= 0 {
+		return nil
+	}
+	p := done * 100 / total
+	return &p
+}
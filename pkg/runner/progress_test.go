@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	var callCtx engine.Context
+	callCtx.ID = "1"
+	callCtx.Tool.Name = "setup"
+
+	monitor := &recordingMonitor{}
+	runtimeManagerLogger{callCtx: callCtx, monitor: monitor}.Infof("Downloading %s", "node")
+
+	// The detailed event is still sent, followed by the progress.
+	require.Len(t, monitor.events, 2)
+	require.Equal(t, EventTypeCallProgress, monitor.events[0].Type)
+	require.Equal(t, EventTypeProgress, monitor.events[1].Type)
+	require.Equal(t, "1", monitor.events[1].CallContext.ID)
+	require.Equal(t, &Progress{
+		Phase:   ProgressPhaseInstallingRuntime,
+		Tool:    "setup",
+		Message: "Downloading node",
+	}, monitor.events[1].Progress)
+
+	require.Nil(t, percent(1, 0))
+	require.Equal(t, 33, *percent(1, 3))
+	require.Equal(t, 100, *percent(3, 3))
+}
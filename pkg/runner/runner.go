@@ -12,6 +12,7 @@ import (
 
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	context2 "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/cost"
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/types"
@@ -36,16 +37,62 @@ type Options struct {
 	EndPort             int64                 `usage:"-"`
 	CredentialOverrides []string              `usage:"-"`
 	Sequential          bool                  `usage:"-"`
+	MaxParallel         int                   `usage:"-"`
+	MaxLLMConcurrency   int                   `usage:"-"`
+	MaxToolConcurrency  int                   `usage:"-"`
 	Authorizer          AuthorizerFunc        `usage:"-"`
+	CredentialRequester CredentialRequestFunc `usage:"-"`
+	// CredentialAuthorizer is asked before a tool is given a credential from the store for the first time in a run.
+	CredentialAuthorizer CredentialAuthorizerFunc `usage:"-"`
+	// SaveEnvVars saves the environment variables that the user is prompted for as credentials, so that they are only
+	// asked for once.
+	SaveEnvVars bool `usage:"-"`
+	// Breakpoints pause the run before the tools that match them are called, in the format tool=<name>.
+	Breakpoints []string `usage:"-"`
+	// BreakpointHandler is called at breakpoints, which are ignored without it.
+	BreakpointHandler BreakpointFunc `usage:"-"`
+	// CheckpointCalls pauses the run after every this many tool calls to ask whether it should continue.
+	CheckpointCalls int `usage:"-"`
+	// CheckpointCost pauses the run every time it spends this many more dollars, as priced by Prices.
+	CheckpointCost float64 `usage:"-"`
+	// Prices are keyed by model name and used to compute the cost of a run.
+	Prices cost.Prices `usage:"-"`
+	// CheckpointHandler is called at checkpoints, which are ignored without it.
+	CheckpointHandler CheckpointFunc `usage:"-"`
+	// SummarizeToolOutputTokens is the number of tokens over which the output of a tool call is summarized before it
+	// is added to the chat, so that it doesn't overflow the context window of the model.
+	SummarizeToolOutputTokens int `usage:"-"`
+	// SummaryModel summarizes the outputs of tool calls. The model of the calling tool is used without it.
+	SummaryModel string `usage:"-"`
+	// ConfineTools keeps the file tools called by tools with a Working Dir from using files outside of it.
+	ConfineTools bool `usage:"-"`
+	// WorkDir is the directory that the tools without a Working Dir run in, instead of the current directory.
+	WorkDir string `usage:"-"`
+	// Guardrails are classifier tools that check every user input and every response of the model, in the format
+	// [input:|output:]<block|redact|flag>=<tool>.
+	Guardrails []string `usage:"-"`
+	// LoadGuardrail loads the classifier tools of the guardrails, which require it.
+	LoadGuardrail GuardrailLoadFunc `usage:"-"`
 }
 
 type AuthorizerResponse struct {
 	Accept  bool
 	Message string
+	// Remember is only used when authorizing credentials. It allows the tool to use the credential for the rest of
+	// the session instead of only for the current run.
+	Remember bool
 }
 
 type AuthorizerFunc func(ctx engine.Context, input string) (AuthorizerResponse, error)
 
+// CredentialAuthorizerFunc is called the first time a tool uses a credential that is already in the store, which
+// means that the user didn't just enter it.
+type CredentialAuthorizerFunc func(ctx engine.Context, credName string) (AuthorizerResponse, error)
+
+// CredentialRequestFunc is called for a credential that is missing or expired in the store before its credential tool
+// is run. Returning a nil credential runs the credential tool as usual.
+type CredentialRequestFunc func(ctx engine.Context, credName string, args map[string]any) (*credentials.Credential, error)
+
 func DefaultAuthorizer(engine.Context, string) (AuthorizerResponse, error) {
 	return AuthorizerResponse{
 		Accept: true,
@@ -59,9 +106,39 @@ func Complete(opts ...Options) (result Options) {
 		result.StartPort = types.FirstSet(opt.StartPort, result.StartPort)
 		result.EndPort = types.FirstSet(opt.EndPort, result.EndPort)
 		result.Sequential = types.FirstSet(opt.Sequential, result.Sequential)
+		result.MaxParallel = types.FirstSet(opt.MaxParallel, result.MaxParallel)
+		result.MaxLLMConcurrency = types.FirstSet(opt.MaxLLMConcurrency, result.MaxLLMConcurrency)
+		result.MaxToolConcurrency = types.FirstSet(opt.MaxToolConcurrency, result.MaxToolConcurrency)
+		result.SaveEnvVars = types.FirstSet(opt.SaveEnvVars, result.SaveEnvVars)
+		result.CheckpointCalls = types.FirstSet(opt.CheckpointCalls, result.CheckpointCalls)
+		result.CheckpointCost = types.FirstSet(opt.CheckpointCost, result.CheckpointCost)
+		result.SummarizeToolOutputTokens = types.FirstSet(opt.SummarizeToolOutputTokens, result.SummarizeToolOutputTokens)
+		result.SummaryModel = types.FirstSet(opt.SummaryModel, result.SummaryModel)
+		result.ConfineTools = types.FirstSet(opt.ConfineTools, result.ConfineTools)
+		result.WorkDir = types.FirstSet(opt.WorkDir, result.WorkDir)
 		if opt.Authorizer != nil {
 			result.Authorizer = opt.Authorizer
 		}
+		if opt.CredentialRequester != nil {
+			result.CredentialRequester = opt.CredentialRequester
+		}
+		if opt.CredentialAuthorizer != nil {
+			result.CredentialAuthorizer = opt.CredentialAuthorizer
+		}
+		if opt.BreakpointHandler != nil {
+			result.BreakpointHandler = opt.BreakpointHandler
+		}
+		result.Breakpoints = append(result.Breakpoints, opt.Breakpoints...)
+		result.Guardrails = append(result.Guardrails, opt.Guardrails...)
+		if opt.LoadGuardrail != nil {
+			result.LoadGuardrail = opt.LoadGuardrail
+		}
+		if opt.CheckpointHandler != nil {
+			result.CheckpointHandler = opt.CheckpointHandler
+		}
+		if opt.Prices != nil {
+			result.Prices = opt.Prices
+		}
 		if opt.CredentialOverrides != nil {
 			result.CredentialOverrides = append(result.CredentialOverrides, opt.CredentialOverrides...)
 		}
@@ -87,28 +164,81 @@ func complete(opts ...Options) Options {
 }
 
 type Runner struct {
-	c              engine.Model
-	auth           AuthorizerFunc
-	factory        MonitorFactory
-	runtimeManager engine.RuntimeManager
-	credMutex      sync.Mutex
-	credOverrides  []string
-	credStore      credentials.CredentialStore
-	sequential     bool
+	c                 engine.Model
+	auth              AuthorizerFunc
+	credRequester     CredentialRequestFunc
+	credAuth          CredentialAuthorizerFunc
+	credApprovals     map[string]bool
+	saveEnvVars       bool
+	envVarValues      map[string]string
+	breakpoints       []breakpoint
+	breakpointHandler BreakpointFunc
+	checkpointCalls   int
+	checkpointCost    float64
+	prices            cost.Prices
+	checkpointHandler CheckpointFunc
+	summarizeTokens   int
+	summaryModel      string
+	confineTools      bool
+	workDir           string
+	guardrails        []*guardrail
+	loadGuardrail     GuardrailLoadFunc
+	withheld          withheldEvents
+	factory           MonitorFactory
+	runtimeManager    engine.RuntimeManager
+	credMutex         sync.Mutex
+	credOverrides     []string
+	credStore         credentials.CredentialStore
+	sequential        bool
+	scheduler         *scheduler
+	llmScheduler      *scheduler
+	toolScheduler     *scheduler
 }
 
 func New(client engine.Model, credStore credentials.CredentialStore, opts ...Options) (*Runner, error) {
 	opt := complete(opts...)
 
+	breakpoints, err := parseBreakpoints(opt.Breakpoints)
+	if err != nil {
+		return nil, err
+	}
+	guardrails, err := parseGuardrails(opt.Guardrails)
+	if err != nil {
+		return nil, err
+	}
+	if len(guardrails) > 0 && opt.LoadGuardrail == nil {
+		return nil, fmt.Errorf("guardrails require a loader of their tools")
+	}
+
 	runner := &Runner{
-		c:              client,
-		factory:        opt.MonitorFactory,
-		runtimeManager: opt.RuntimeManager,
-		credMutex:      sync.Mutex{},
-		credOverrides:  opt.CredentialOverrides,
-		credStore:      credStore,
-		sequential:     opt.Sequential,
-		auth:           opt.Authorizer,
+		c:                 client,
+		factory:           opt.MonitorFactory,
+		runtimeManager:    opt.RuntimeManager,
+		credMutex:         sync.Mutex{},
+		credOverrides:     opt.CredentialOverrides,
+		credStore:         credStore,
+		sequential:        opt.Sequential,
+		scheduler:         newScheduler(opt.MaxParallel),
+		llmScheduler:      newScheduler(opt.MaxLLMConcurrency),
+		toolScheduler:     newScheduler(opt.MaxToolConcurrency),
+		auth:              opt.Authorizer,
+		credRequester:     opt.CredentialRequester,
+		credAuth:          opt.CredentialAuthorizer,
+		credApprovals:     map[string]bool{},
+		saveEnvVars:       opt.SaveEnvVars,
+		envVarValues:      map[string]string{},
+		breakpoints:       breakpoints,
+		breakpointHandler: opt.BreakpointHandler,
+		checkpointCalls:   opt.CheckpointCalls,
+		checkpointCost:    opt.CheckpointCost,
+		prices:            opt.Prices,
+		checkpointHandler: opt.CheckpointHandler,
+		summarizeTokens:   opt.SummarizeToolOutputTokens,
+		summaryModel:      opt.SummaryModel,
+		confineTools:      opt.ConfineTools,
+		workDir:           opt.WorkDir,
+		guardrails:        guardrails,
+		loadGuardrail:     opt.LoadGuardrail,
 	}
 
 	if opt.StartPort != 0 {
@@ -159,11 +289,16 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 		}
 	}
 
+	ctx = r.withCheckpoints(ctx)
+	ctx, runCost := r.withRunCost(ctx)
+
 	monitor, err := r.factory.Start(ctx, &prg, env, input)
 	if err != nil {
 		return resp, err
 	}
+	monitor = withLabels(monitor, context2.GetLabels(ctx))
 	defer func() {
+		runCost.send(monitor)
 		monitor.Stop(ctx, resp.Content, err)
 	}()
 
@@ -171,6 +306,7 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 	if err != nil {
 		return resp, err
 	}
+	sendProgress(&callCtx, monitor, Progress{Phase: ProgressPhaseLoading})
 
 	if state == nil || state.StartContinuation {
 		if state != nil {
@@ -236,7 +372,38 @@ type Event struct {
 	ChatResponse       any                    `json:"chatResponse,omitempty"`
 	Usage              types.Usage            `json:"usage,omitempty"`
 	ChatResponseCached bool                   `json:"chatResponseCached,omitempty"`
+	ChatTiming         *types.Timing          `json:"chatTiming,omitempty"`
 	Content            string                 `json:"content,omitempty"`
+	Candidates         []string               `json:"candidates,omitempty"`
+	Labels             map[string]string      `json:"labels,omitempty"`
+	Progress           *Progress              `json:"progress,omitempty"`
+
+	// Cost is in dollars, for EventTypeChat events the cost of the completion and for EventTypeRunSummary events the
+	// cost of the run. Completions of models without a price cost nothing.
+	Cost float64 `json:"cost,omitempty"`
+	// ChatResponseApproximate is set with ChatResponseCached when the response was cached for a prompt that is only
+	// similar to that of the request.
+	ChatResponseApproximate bool `json:"chatResponseApproximate,omitempty"`
+	// ChatModel is the model that the request of an EventTypeChat event with a response was sent to.
+	ChatModel string `json:"chatModel,omitempty"`
+	// ChatContentFilter is set on an EventTypeChat event when the content filter of the provider blocked the request
+	// or the response.
+	ChatContentFilter *types.ContentFilter `json:"chatContentFilter,omitempty"`
+	// ChatRateLimit is set on an EventTypeChat event with a response when the provider reported what remains of its
+	// rate limits.
+	ChatRateLimit *types.RateLimit `json:"chatRateLimit,omitempty"`
+	// ChatLogProbs are the log probabilities of the tokens of each choice of the response of an EventTypeChat event,
+	// when the tool asked for them.
+	ChatLogProbs [][]types.TokenLogProb `json:"chatLogProbs,omitempty"`
+	// ChatPayload is the HTTP request and response of an EventTypeChat event with a response, with secrets redacted,
+	// when the request was sampled for its payload to be recorded.
+	ChatPayload *types.CompletionPayload `json:"chatPayload,omitempty"`
+	// ToolOutputSummary describes the output of a tool call that an EventTypeCallSummarize event was sent for.
+	ToolOutputSummary *ToolOutputSummary `json:"toolOutputSummary,omitempty"`
+	// Route is the agent that the input of a tool of the agents type was routed to, for EventTypeCallRoute events.
+	Route *engine.Route `json:"route,omitempty"`
+	// Guardrail is what a guardrail flagged, for EventTypeCallGuardrail events.
+	Guardrail *GuardrailResult `json:"guardrail,omitempty"`
 }
 
 type EventType string
@@ -250,6 +417,26 @@ var (
 	EventTypeChat         EventType = "callChat"
 	EventTypeCallFinish   EventType = "callFinish"
 	EventTypeRunFinish    EventType = "runFinish"
+	// EventTypeRunOutput events have the text of the responses to the top-level tool as it is generated, which is
+	// the output of the run. Their Content is the text since the last event of the same completion.
+	EventTypeRunOutput EventType = "runOutput"
+	// EventTypeCallChoose events have the Candidates of the response of a tool with more than one choice, and the
+	// chosen response as their Content.
+	EventTypeCallChoose EventType = "callChoose"
+	// EventTypeCallRoute events are sent when a tool of the agents type routes its input to one of its agents.
+	EventTypeCallRoute EventType = "callRoute"
+	// EventTypeProgress events have the Progress of the run. Unlike the other events, their format is meant to stay the
+	// same as the internals of the runner change.
+	EventTypeProgress EventType = "progress"
+	// EventTypeRunSummary events are sent when a run finishes, with the Usage and Cost of all the calls it made to
+	// models. They have no CallContext.
+	EventTypeRunSummary EventType = "runSummary"
+	// EventTypeCallSummarize events are sent when the output of a tool call was summarized before it was added to the
+	// chat, because it was over the SummarizeToolOutputTokens of the runner.
+	EventTypeCallSummarize EventType = "callSummarize"
+	// EventTypeCallGuardrail events are sent when a guardrail flags the input of a run or a response of the model,
+	// before the action of the guardrail is taken.
+	EventTypeCallGuardrail EventType = "callGuardrail"
 )
 
 func getToolRefInput(prg *types.Program, ref types.ToolReference, input string) (string, error) {
@@ -357,6 +544,11 @@ func (r *Runner) getContext(callCtx engine.Context, state *State, monitor Monito
 			continue
 		}
 
+		sendProgress(&callCtx, monitor, Progress{
+			Phase:   ProgressPhaseResolvingTools,
+			Percent: percent(i, len(toolRefs)),
+		})
+
 		contextInput, err := getToolRefInput(callCtx.Program, toolRef, input)
 		if err != nil {
 			return nil, nil, err
@@ -364,9 +556,9 @@ func (r *Runner) getContext(callCtx engine.Context, state *State, monitor Monito
 
 		var content *State
 		if state != nil && state.InputContextContinuation != nil {
-			content, err = r.subCallResume(callCtx.Ctx, callCtx, monitor, env, toolRef.ToolID, "", state.InputContextContinuation.WithResumeInput(state.ResumeInput), engine.ContextToolCategory)
+			content, err = r.subCallResume(callCtx.Ctx, callCtx, monitor, env, toolRef.ToolID, "", state.InputContextContinuation.WithResumeInput(state.ResumeInput), engine.ContextToolCategory, nil)
 		} else {
-			content, err = r.subCall(callCtx.Ctx, callCtx, monitor, env, toolRef.ToolID, contextInput, "", engine.ContextToolCategory)
+			content, err = r.subCall(callCtx.Ctx, callCtx, monitor, env, toolRef.ToolID, contextInput, "", engine.ContextToolCategory, nil)
 		}
 		if err != nil {
 			return nil, nil, err
@@ -404,7 +596,17 @@ func (r *Runner) call(callCtx engine.Context, monitor Monitor, env []string, inp
 }
 
 func (r *Runner) start(callCtx engine.Context, state *State, monitor Monitor, env []string, input string) (*State, error) {
-	progress, progressClose := streamProgress(&callCtx, monitor)
+	// Only the input of the run is from the user, the input of other calls is from the model or from tools. It is
+	// checked before any event has it.
+	if callCtx.Parent == nil {
+		guarded, err := r.guard(callCtx, monitor, env, guardrailInput, input)
+		if err != nil {
+			return nil, err
+		}
+		input = guarded
+	}
+
+	progress, progressClose, _ := streamProgress(&callCtx, monitor, r.withholdFunc(callCtx))
 	defer progressClose()
 
 	monitor.Event(Event{
@@ -419,10 +621,32 @@ func (r *Runner) start(callCtx engine.Context, state *State, monitor Monitor, en
 		return nil, err
 	}
 
+	var creds map[string]map[string]string
 	if len(callCtx.Tool.Credentials) > 0 {
 		var err error
-		env, err = r.handleCredentials(callCtx, monitor, env)
-		if err != nil {
+		env, creds, err = r.handleCredentials(callCtx, monitor, env)
+		if denied := (*CredentialDeniedError)(nil); errors.As(err, &denied) {
+			msg := denied.Error()
+			return &State{
+				Continuation: &engine.Return{
+					Result: &msg,
+				},
+			}, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(callCtx.Tool.EnvVars) > 0 {
+		env, err = r.handleEnvVars(callCtx, monitor, env)
+		if denied := (*CredentialDeniedError)(nil); errors.As(err, &denied) {
+			msg := denied.Error()
+			return &State{
+				Continuation: &engine.Return{
+					Result: &msg,
+				},
+			}, nil
+		} else if err != nil {
 			return nil, err
 		}
 	}
@@ -438,14 +662,33 @@ func (r *Runner) start(callCtx engine.Context, state *State, monitor Monitor, en
 	}
 
 	e := engine.Engine{
-		Model:          r.c,
-		RuntimeManager: runtimeWithLogger(callCtx, monitor, r.runtimeManager),
-		Progress:       progress,
-		Env:            env,
+		Model:            r.c,
+		RuntimeManager:   runtimeWithLogger(callCtx, monitor, r.runtimeManager),
+		Progress:         progress,
+		Env:              env,
+		Secrets:          credentialSecrets(creds),
+		ConfineTools:     r.confineTools,
+		Dir:              r.workDir,
+		AcquireCandidate: r.acquireCandidate(callCtx),
 	}
 
 	callCtx.Ctx = context2.AddPauseFuncToCtx(callCtx.Ctx, monitor.Pause)
 
+	if err := getCheckpoints(callCtx.Ctx).toolCall(callCtx); err != nil {
+		return nil, err
+	}
+
+	input, skipped, err := r.handleBreakpoint(callCtx, input)
+	if err != nil {
+		return nil, err
+	} else if skipped != nil {
+		return &State{
+			Continuation: &engine.Return{
+				Result: skipped,
+			},
+		}, nil
+	}
+
 	_, safe := builtin.SafeTools[callCtx.Tool.ID]
 	if callCtx.Tool.IsCommand() && !safe {
 		authResp, err := r.auth(callCtx, input)
@@ -466,10 +709,36 @@ func (r *Runner) start(callCtx engine.Context, state *State, monitor Monitor, en
 		}
 	}
 
+	// The arguments from credentials are added after the call was confirmed, so that they aren't shown in the prompt.
+	if len(callCtx.Tool.CredentialArguments) > 0 {
+		input, err = r.handleCredentialArguments(callCtx, monitor, creds, input)
+		if denied := (*CredentialDeniedError)(nil); errors.As(err, &denied) {
+			msg := denied.Error()
+			return &State{
+				Continuation: &engine.Return{
+					Result: &msg,
+				},
+			}, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if callCtx.Parent != nil && callCtx.ToolCategory == engine.NoCategory {
+		sendProgress(&callCtx, monitor, Progress{Phase: ProgressPhaseRunningTool})
+	}
+
+	release, err := r.acquireCall(callCtx, !callCtx.Tool.IsCommand() || callCtx.Tool.IsAgents())
+	if err != nil {
+		return nil, err
+	}
+
 	ret, err := e.Start(callCtx, input)
+	release()
 	if err != nil {
 		return nil, err
 	}
+	routeEvent(callCtx, monitor, ret)
 
 	return &State{
 		Continuation: ret,
@@ -549,21 +818,83 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 		return nil, errors.New("invalid state, resume should have Continuation data")
 	}
 
-	progress, progressClose := streamProgress(&callCtx, monitor)
+	progress, progressClose, progressSync := streamProgress(&callCtx, monitor, r.withholdFunc(callCtx))
+	// The responses that are still withheld when the call fails or is blocked are sent without their text.
+	defer r.dropWithheld(callCtx, monitor)
 	defer progressClose()
 
+	var creds map[string]map[string]string
 	if len(callCtx.Tool.Credentials) > 0 {
 		var err error
-		env, err = r.handleCredentials(callCtx, monitor, env)
+		env, creds, err = r.handleCredentials(callCtx, monitor, env)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	e := engine.Engine{
+		Model:            r.c,
+		RuntimeManager:   runtimeWithLogger(callCtx, monitor, r.runtimeManager),
+		Progress:         progress,
+		Env:              env,
+		Secrets:          credentialSecrets(creds),
+		ConfineTools:     r.confineTools,
+		Dir:              r.workDir,
+		AcquireCandidate: r.acquireCandidate(callCtx),
+	}
+
+	var validateAttempts int
+
 	for {
 		callCtx.CurrentReturn = state.Continuation
 
 		if state.Continuation.Result != nil && len(state.Continuation.Calls) == 0 && state.SubCallID == "" && state.ResumeInput == nil {
+			// Only the responses of the LLM can be validated, the output of commands is left to output filters
+			if state.Continuation.State != nil {
+				if err := r.choose(callCtx, monitor, env, state.Continuation); err != nil {
+					return nil, err
+				}
+				problem, err := r.validate(callCtx, monitor, env, *state.Continuation.Result)
+				if err != nil {
+					return nil, err
+				}
+				if problem != "" {
+					progressSync()
+					r.dropWithheld(callCtx, monitor)
+					if validateAttempts >= validateRetries(callCtx) {
+						return nil, fmt.Errorf("response of tool %s failed validation after %d retries: %s", callCtx.Tool.Name, validateAttempts, problem)
+					}
+					validateAttempts++
+
+					release, err := r.acquireCall(callCtx, true)
+					if err != nil {
+						return nil, err
+					}
+					nextContinuation, err := e.Continue(callCtx, state.Continuation.State, engine.CallResult{
+						User: validateFeedback(problem),
+					})
+					release()
+					if err != nil {
+						return nil, err
+					}
+					state = &State{
+						Continuation: nextContinuation,
+					}
+					continue
+				}
+
+				output, err := r.guard(callCtx, monitor, env, guardrailOutput, *state.Continuation.Result)
+				if err != nil {
+					return nil, err
+				}
+				redacted := output != *state.Continuation.Result
+				if redacted {
+					state.Continuation.SetResult(output)
+				}
+				progressSync()
+				r.releaseOutput(callCtx, monitor, output, redacted)
+			}
+
 			progressClose()
 			monitor.Event(Event{
 				Time:        time.Now(),
@@ -582,6 +913,10 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			}, nil
 		}
 
+		// Responses with tool calls are not the output of the call, so they are sent as they are.
+		progressSync()
+		r.releaseWithheld(callCtx, monitor)
+
 		monitor.Event(Event{
 			Time:         time.Now(),
 			CallContext:  callCtx.GetCallContext(),
@@ -620,6 +955,10 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			}
 		}
 
+		if err := r.summarizeResults(callCtx, monitor, &e, engineResults); err != nil {
+			return nil, err
+		}
+
 		monitor.Event(Event{
 			Time:        time.Now(),
 			CallContext: callCtx.GetCallContext(),
@@ -627,13 +966,6 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			ToolResults: len(callResults),
 		})
 
-		e := engine.Engine{
-			Model:          r.c,
-			RuntimeManager: runtimeWithLogger(callCtx, monitor, r.runtimeManager),
-			Progress:       progress,
-			Env:            env,
-		}
-
 		var contentInput string
 
 		if state.Continuation != nil && state.Continuation.State != nil {
@@ -646,7 +978,11 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 		}
 
 		if state.ResumeInput != nil {
-			input, err := r.handleInput(callCtx, monitor, env, *state.ResumeInput)
+			input, err := r.guard(callCtx, monitor, env, guardrailInput, *state.ResumeInput)
+			if err != nil {
+				return state, err
+			}
+			input, err = r.handleInput(callCtx, monitor, env, input)
 			if err != nil {
 				return state, err
 			}
@@ -655,10 +991,17 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			})
 		}
 
+		release, err := r.acquireCall(callCtx, true)
+		if err != nil {
+			return nil, err
+		}
+
 		nextContinuation, err := e.Continue(callCtx, state.Continuation.State, engineResults...)
+		release()
 		if err != nil {
 			return nil, err
 		}
+		routeEvent(callCtx, monitor, nextContinuation)
 
 		state = &State{
 			Continuation: nextContinuation,
@@ -667,51 +1010,169 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 	}
 }
 
-func streamProgress(callCtx *engine.Context, monitor Monitor) (chan<- types.CompletionStatus, func()) {
+// routeEvent sends the EventTypeCallRoute event of a return that routed the input of the tool to an agent.
+func routeEvent(callCtx engine.Context, monitor Monitor, ret *engine.Return) {
+	if ret == nil || ret.Route == nil {
+		return
+	}
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeCallRoute,
+		Content:     ret.Route.Agent,
+		Route:       ret.Route,
+	})
+}
+
+// streamProgress sends the events of the progress of the completions of the call. When withhold is set, the text of
+// the responses is withheld: progress events are not sent and the EventTypeChat events with a response are passed to
+// withhold instead. The returned sync function waits until the progress that was sent has been handled.
+func streamProgress(callCtx *engine.Context, monitor Monitor, withhold func(Event)) (chan<- types.CompletionStatus, func(), func()) {
 	progress := make(chan types.CompletionStatus)
+	flush := make(chan struct{})
+	output := outputStreamer{
+		root: callCtx.Parent == nil && callCtx.ToolCategory == engine.NoCategory,
+	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for status := range progress {
-			if message := status.PartialResponse; message != nil {
+	handle := func(status types.CompletionStatus) {
+		if message := status.PartialResponse; message != nil {
+			if withhold != nil {
+				return
+			}
+			monitor.Event(Event{
+				Time:             time.Now(),
+				CallContext:      callCtx.GetCallContext(),
+				Type:             EventTypeCallProgress,
+				ChatCompletionID: status.CompletionID,
+				Content:          getEventContent(message.String(), *callCtx),
+			})
+			if delta := output.next(status); delta != "" {
 				monitor.Event(Event{
 					Time:             time.Now(),
 					CallContext:      callCtx.GetCallContext(),
-					Type:             EventTypeCallProgress,
+					Type:             EventTypeRunOutput,
 					ChatCompletionID: status.CompletionID,
-					Content:          getEventContent(message.String(), *callCtx),
-				})
-			} else {
-				monitor.Event(Event{
-					Time:               time.Now(),
-					CallContext:        callCtx.GetCallContext(),
-					Type:               EventTypeChat,
-					ChatCompletionID:   status.CompletionID,
-					ChatRequest:        status.Request,
-					ChatResponse:       status.Response,
-					Usage:              status.Usage,
-					ChatResponseCached: status.Cached,
+					Content:          delta,
 				})
 			}
+			return
+		}
+
+		if status.Request != nil {
+			sendProgress(callCtx, monitor, Progress{Phase: ProgressPhaseCallingModel})
+		}
+		getCheckpoints(callCtx.Ctx).addUsage(status.Model, status.Usage)
+		event := Event{
+			Time:               time.Now(),
+			CallContext:        callCtx.GetCallContext(),
+			Type:               EventTypeChat,
+			ChatCompletionID:   status.CompletionID,
+			ChatRequest:        status.Request,
+			ChatResponse:       status.Response,
+			Usage:              status.Usage,
+			Cost:               getRunCost(callCtx.Ctx).add(status.Model, status.Usage),
+			ChatResponseCached: status.Cached,
+			ChatTiming:         status.Timing,
+
+			ChatResponseApproximate: status.Approximate,
+			ChatModel:               status.Model,
+			ChatContentFilter:       status.ContentFilter,
+			ChatRateLimit:           status.RateLimit,
+			ChatLogProbs:            status.LogProbs,
+			ChatPayload:             status.Payload,
+		}
+		if withhold != nil && status.Response != nil {
+			withhold(event)
+			return
+		}
+		monitor.Event(event)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case status, ok := <-progress:
+				if !ok {
+					return
+				}
+				handle(status)
+			case <-flush:
+			}
 		}
 	}()
 
 	var once sync.Once
-	return progress, func() {
+	closeProgress := func() {
 		once.Do(func() {
 			close(progress)
 			wg.Wait()
 		})
 	}
+	syncProgress := func() {
+		// The progress of a completion is sent before it returns, so once the loop takes from flush, it has handled
+		// all of it.
+		select {
+		case flush <- struct{}{}:
+		case <-callCtx.Ctx.Done():
+		}
+	}
+	return progress, closeProgress, syncProgress
+}
+
+// outputStreamer turns the partial responses of the top-level tool into the text that has been added to them, so
+// that the output of a run can be shown as it is generated.
+type outputStreamer struct {
+	root         bool
+	completionID string
+	sent         string
+	wrote        bool
+	separate     bool
+}
+
+func (o *outputStreamer) next(status types.CompletionStatus) string {
+	// Only the first of the completions of a tool with Best Of set is shown as it is generated.
+	if !o.root || status.Placeholder || status.PartialResponse == nil || status.Candidate > 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, content := range status.PartialResponse.Content {
+		text.WriteString(content.Text)
+	}
+
+	if status.CompletionID != o.completionID {
+		o.completionID, o.sent = status.CompletionID, ""
+		// Only the text of the last response is the output of the tool, but the text of each response is shown
+		// as it is generated, so they are separated.
+		o.separate = o.wrote
+	}
+	if !strings.HasPrefix(text.String(), o.sent) {
+		// The partial response does not continue what was sent, so all of it is new.
+		o.sent = ""
+	}
+
+	delta := text.String()[len(o.sent):]
+	o.sent = text.String()
+	if delta == "" {
+		return ""
+	}
+	if o.separate {
+		delta = "\n\n" + delta
+		o.separate = false
+	}
+	o.wrote = true
+	return delta
 }
 
-func (r *Runner) subCall(ctx context.Context, parentContext engine.Context, monitor Monitor, env []string, toolID, input, callID string, toolCategory engine.ToolCategory) (*State, error) {
+func (r *Runner) subCall(ctx context.Context, parentContext engine.Context, monitor Monitor, env []string, toolID, input, callID string, toolCategory engine.ToolCategory, overrides *types.ToolOverrides) (*State, error) {
 	callCtx, err := parentContext.SubCallContext(ctx, input, toolID, callID, toolCategory)
 	if err != nil {
 		return nil, err
 	}
+	callCtx.Tool = overrides.Apply(callCtx.Tool)
 
 	if toolCategory == engine.ContextToolCategory && callCtx.Tool.IsNoop() {
 		return &State{
@@ -722,11 +1183,12 @@ func (r *Runner) subCall(ctx context.Context, parentContext engine.Context, moni
 	return r.call(callCtx, monitor, env, input)
 }
 
-func (r *Runner) subCallResume(ctx context.Context, parentContext engine.Context, monitor Monitor, env []string, toolID, callID string, state *State, toolCategory engine.ToolCategory) (*State, error) {
+func (r *Runner) subCallResume(ctx context.Context, parentContext engine.Context, monitor Monitor, env []string, toolID, callID string, state *State, toolCategory engine.ToolCategory, overrides *types.ToolOverrides) (*State, error) {
 	callCtx, err := parentContext.SubCallContext(ctx, "", toolID, callID, toolCategory)
 	if err != nil {
 		return nil, err
 	}
+	callCtx.Tool = overrides.Apply(callCtx.Tool)
 
 	return r.resume(callCtx, monitor, env, state)
 }
@@ -737,8 +1199,10 @@ type SubCallResult struct {
 	State  *State `json:"state,omitempty"`
 }
 
-func (r *Runner) newDispatcher(ctx context.Context) dispatcher {
-	if r.sequential {
+// newDispatcher returns the dispatcher of the calls of a tool. The calls of a tool that disables parallel tool calls
+// are run one at a time, because their side effects must not overlap.
+func (r *Runner) newDispatcher(ctx context.Context, tool types.Tool) dispatcher {
+	if r.sequential || (tool.Parameters.ParallelToolCalls != nil && !*tool.Parameters.ParallelToolCalls) {
 		return newSerialDispatcher(ctx)
 	}
 	return newParallelDispatcher(ctx)
@@ -765,7 +1229,11 @@ func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string,
 				found = true
 				subState := *subCall.State
 				subState.ResumeInput = state.ResumeInput
-				result, err := r.subCallResume(callCtx.Ctx, callCtx, monitor, env, subCall.ToolID, subCall.CallID, subCall.State.WithResumeInput(state.ResumeInput), toolCategory)
+				var overrides *types.ToolOverrides
+				if state.Continuation != nil {
+					overrides = state.Continuation.Calls[subCall.CallID].Overrides
+				}
+				result, err := r.subCallResume(callCtx.Ctx, callCtx, monitor, env, subCall.ToolID, subCall.CallID, subCall.State.WithResumeInput(state.ResumeInput), toolCategory, overrides)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -786,7 +1254,7 @@ func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string,
 		return state, callResults, nil
 	}
 
-	d := r.newDispatcher(callCtx.Ctx)
+	d := r.newDispatcher(callCtx.Ctx, callCtx.Tool)
 
 	// Sort the id so if sequential the results are predictable
 	ids := maps.Keys(state.Continuation.Calls)
@@ -795,7 +1263,7 @@ func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string,
 	for _, id := range ids {
 		call := state.Continuation.Calls[id]
 		d.Run(func(ctx context.Context) error {
-			result, err := r.subCall(ctx, callCtx, monitor, env, call.ToolID, call.Input, id, toolCategory)
+			result, err := r.subCall(ctx, callCtx, monitor, env, call.ToolID, call.Input, id, toolCategory, call.Overrides)
 			if err != nil {
 				return err
 			}
@@ -807,6 +1275,13 @@ func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string,
 				CallID: id,
 				State:  result,
 			})
+			if toolCategory == engine.NoCategory {
+				sendProgress(&callCtx, monitor, Progress{
+					Phase:   ProgressPhaseRunningTool,
+					Tool:    callCtx.Program.ToolSet[call.ToolID].Name,
+					Percent: percent(len(callResults), len(ids)),
+				})
+			}
 
 			return nil
 		})
@@ -828,7 +1303,9 @@ func getEventContent(content string, callCtx engine.Context) string {
 	return content
 }
 
-func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env []string) ([]string, error) {
+// handleCredentials adds the credentials of the tool to the environment, and returns the values of each credential by
+// name as well.
+func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env []string) ([]string, map[string]map[string]string, error) {
 	// Since credential tools (usually) prompt the user, we want to only run one at a time.
 	r.credMutex.Lock()
 	defer r.credMutex.Unlock()
@@ -841,14 +1318,20 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 	if r.credOverrides != nil {
 		credOverrides, err = parseCredentialOverrides(r.credOverrides)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse credential overrides: %w", err)
+			return nil, nil, fmt.Errorf("failed to parse credential overrides: %w", err)
 		}
 	}
 
-	for _, credToolName := range callCtx.Tool.Credentials {
+	creds := map[string]map[string]string{}
+	for i, credToolName := range callCtx.Tool.Credentials {
+		sendProgress(&callCtx, monitor, Progress{
+			Phase:   ProgressPhaseResolvingTools,
+			Percent: percent(i, len(callCtx.Tool.Credentials)),
+		})
+
 		toolName, credentialAlias, args, err := types.ParseCredentialArgs(credToolName, callCtx.Input)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse credential tool %q: %w", credToolName, err)
+			return nil, nil, fmt.Errorf("failed to parse credential tool %q: %w", credToolName, err)
 		}
 
 		credName := toolName
@@ -861,6 +1344,7 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 			for k, v := range override {
 				env = append(env, fmt.Sprintf("%s=%s", k, v))
 			}
+			creds[credName] = override
 			continue
 		}
 
@@ -871,7 +1355,7 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 
 		rm := runtimeWithLogger(callCtx, monitor, r.runtimeManager)
 		if err := rm.EnsureCredentialHelpers(callCtx.Ctx); err != nil {
-			return nil, fmt.Errorf("failed to setup credential helpers: %w", err)
+			return nil, nil, fmt.Errorf("failed to setup credential helpers: %w", err)
 		}
 
 		// Only try to look up the cred if the tool is on GitHub or has an alias.
@@ -879,17 +1363,21 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 		if isGitHubTool(toolName) && credentialAlias == "" {
 			c, exists, err = r.credStore.Get(callCtx.Ctx, toolName)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get credentials for tool %s: %w", toolName, err)
+				return nil, nil, fmt.Errorf("failed to get credentials for tool %s: %w", toolName, err)
 			}
 		} else if credentialAlias != "" {
 			c, exists, err = r.credStore.Get(callCtx.Ctx, credentialAlias)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get credentials for tool %s: %w", credentialAlias, err)
+				return nil, nil, fmt.Errorf("failed to get credentials for tool %s: %w", credentialAlias, err)
 			}
 		}
 
 		if c == nil {
 			c = &credentials.Credential{}
+		} else if exists && !c.IsExpired() {
+			if err := r.authorizeCredential(callCtx, credName); err != nil {
+				return nil, nil, err
+			}
 		}
 
 		// If the credential doesn't already exist in the store, run the credential tool in order to get the value,
@@ -897,39 +1385,51 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 		if !exists || c.IsExpired() {
 			credToolRefs, ok := callCtx.Tool.ToolMapping[credToolName]
 			if !ok || len(credToolRefs) != 1 {
-				return nil, fmt.Errorf("failed to find ID for tool %s", credToolName)
+				return nil, nil, fmt.Errorf("failed to find ID for tool %s", credToolName)
 			}
 
 			// If the existing credential is expired, we need to provide it to the cred tool through the environment.
 			if exists && c.IsExpired() {
 				credJSON, err := json.Marshal(c)
 				if err != nil {
-					return nil, fmt.Errorf("failed to marshal credential: %w", err)
+					return nil, nil, fmt.Errorf("failed to marshal credential: %w", err)
 				}
 				env = append(env, fmt.Sprintf("%s=%s", credentials.ExistingCredential, string(credJSON)))
 			}
 
-			// Get the input for the credential tool, if there is any.
-			var input string
-			if args != nil {
-				inputBytes, err := json.Marshal(args)
+			var requested *credentials.Credential
+			if r.credRequester != nil {
+				requested, err = r.credRequester(callCtx, credName, args)
 				if err != nil {
-					return nil, fmt.Errorf("failed to marshal args for tool %s: %w", credToolName, err)
+					return nil, nil, fmt.Errorf("failed to request credential %s: %w", credName, err)
 				}
-				input = string(inputBytes)
 			}
 
-			res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, credToolRefs[0].ToolID, input, "", engine.CredentialToolCategory)
-			if err != nil {
-				return nil, fmt.Errorf("failed to run credential tool %s: %w", credToolName, err)
-			}
+			if requested != nil {
+				c = requested
+			} else {
+				// Get the input for the credential tool, if there is any.
+				var input string
+				if args != nil {
+					inputBytes, err := json.Marshal(args)
+					if err != nil {
+						return nil, nil, fmt.Errorf("failed to marshal args for tool %s: %w", credToolName, err)
+					}
+					input = string(inputBytes)
+				}
 
-			if res.Result == nil {
-				return nil, fmt.Errorf("invalid state: credential tool [%s] can not result in a continuation", credToolName)
-			}
+				res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, credToolRefs[0].ToolID, input, "", engine.CredentialToolCategory, nil)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to run credential tool %s: %w", credToolName, err)
+				}
+
+				if res.Result == nil {
+					return nil, nil, fmt.Errorf("invalid state: credential tool [%s] can not result in a continuation", credToolName)
+				}
 
-			if err := json.Unmarshal([]byte(*res.Result), &c); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal credential tool %s response: %w", credToolName, err)
+				if err := json.Unmarshal([]byte(*res.Result), &c); err != nil {
+					return nil, nil, fmt.Errorf("failed to unmarshal credential tool %s response: %w", credToolName, err)
+				}
 			}
 			c.ToolName = credName
 			c.Type = credentials.CredentialTypeTool
@@ -945,21 +1445,69 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 			// Only store the credential if the tool is on GitHub or has an alias, and the credential is non-empty.
 			if (isGitHubTool(toolName) && callCtx.Program.ToolSet[credToolRefs[0].ToolID].Source.Repo != nil) || credentialAlias != "" {
 				if isEmpty {
-					log.Warnf("Not saving empty credential for tool %s", toolName)
+					log.WithContext(callCtx.Ctx).Warnf("Not saving empty credential for tool %s", toolName)
 				} else if err := r.credStore.Add(callCtx.Ctx, *c); err != nil {
-					return nil, fmt.Errorf("failed to add credential for tool %s: %w", toolName, err)
+					return nil, nil, fmt.Errorf("failed to add credential for tool %s: %w", toolName, err)
 				}
 			} else {
-				log.Warnf("Not saving credential for tool %s - credentials will only be saved for tools from GitHub, or tools that use aliases.", toolName)
+				log.WithContext(callCtx.Ctx).Warnf("Not saving credential for tool %s - credentials will only be saved for tools from GitHub, or tools that use aliases.", toolName)
 			}
 		}
 
 		for k, v := range c.Env {
 			env = append(env, fmt.Sprintf("%s=%s", k, v))
 		}
+		creds[credName] = c.Env
+	}
+
+	return env, creds, nil
+}
+
+// CredentialDeniedError is returned when the user doesn't allow a tool to use a stored credential.
+type CredentialDeniedError struct {
+	CredName string
+	Message  string
+}
+
+func (e *CredentialDeniedError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("Use of credential %s has been denied", e.CredName)
+}
+
+// authorizeCredential asks the credential authorizer whether the tool can use a credential from the store, unless the
+// tool was already allowed to in this run or for the session. It must be called with the credMutex held.
+func (r *Runner) authorizeCredential(callCtx engine.Context, credName string) error {
+	if r.credAuth == nil {
+		return nil
+	}
+
+	var (
+		sessionKey = callCtx.Tool.ID + "\x00" + credName
+		runKey     = sessionID(callCtx) + "\x00" + sessionKey
+	)
+	if r.credApprovals[sessionKey] || r.credApprovals[runKey] {
+		return nil
+	}
+
+	resp, err := r.credAuth(callCtx, credName)
+	if err != nil {
+		return fmt.Errorf("failed to authorize use of credential %s: %w", credName, err)
+	}
+	if !resp.Accept {
+		return &CredentialDeniedError{
+			CredName: credName,
+			Message:  resp.Message,
+		}
 	}
 
-	return env, nil
+	if resp.Remember {
+		r.credApprovals[sessionKey] = true
+	} else {
+		r.credApprovals[runKey] = true
+	}
+	return nil
 }
 
 func isGitHubTool(toolName string) bool {
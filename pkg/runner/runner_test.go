@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputStreamer(t *testing.T) {
+	partial := func(id, text string, placeholder bool) types.CompletionStatus {
+		return types.CompletionStatus{
+			CompletionID: id,
+			PartialResponse: &types.CompletionMessage{
+				Role:    types.CompletionMessageRoleTypeAssistant,
+				Content: types.Text(text),
+			},
+			Placeholder: placeholder,
+		}
+	}
+
+	o := outputStreamer{root: true}
+	var deltas []string
+	for _, status := range []types.CompletionStatus{
+		partial("1", "Waiting for model response...", true),
+		partial("1", "Let me", false),
+		partial("1", "Let me check.", false),
+		partial("2", "Waiting for model response...", true),
+		partial("2", "", false),
+		partial("2", "The answer", false),
+		partial("2", "The answer is 42.", false),
+	} {
+		if delta := o.next(status); delta != "" {
+			deltas = append(deltas, delta)
+		}
+	}
+	require.Equal(t, []string{"Let me", " check.", "\n\nThe answer", " is 42."}, deltas)
+
+	sub := outputStreamer{}
+	require.Empty(t, sub.next(partial("1", "Hello", false)))
+}
+
+func TestNewDispatcher(t *testing.T) {
+	r := &Runner{}
+	require.IsType(t, &parallelDispatcher{}, r.newDispatcher(context.Background(), types.Tool{}))
+
+	parallel := false
+	tool := types.Tool{ToolDef: types.ToolDef{Parameters: types.Parameters{ParallelToolCalls: &parallel}}}
+	require.IsType(t, &serialDispatcher{}, r.newDispatcher(context.Background(), tool))
+}
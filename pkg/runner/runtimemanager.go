@@ -35,6 +35,11 @@ func (r runtimeManagerLogger) Infof(msg string, args ...any) {
 		CallContext: r.callCtx.GetCallContext(),
 		Content:     fmt.Sprintf(msg, args...),
 	})
+	// The runtime manager only logs while it sets up runtimes and dependencies.
+	sendProgress(&r.callCtx, r.monitor, Progress{
+		Phase:   ProgressPhaseInstallingRuntime,
+		Message: fmt.Sprintf(msg, args...),
+	})
 }
 
 func (r runtimeManagerLogger) GetContext(ctx context.Context, tool types.Tool, cmd, env []string) (string, []string, error) {
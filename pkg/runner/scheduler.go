@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// scheduler limits the number of in-flight LLM calls and tool executions across all sessions. Waiters are queued
+// per session and sessions are served round-robin so that a single session fanning out to many sub-agents can not
+// starve the others.
+type scheduler struct {
+	lock     sync.Mutex
+	limit    int
+	running  int
+	sessions []string
+	waiting  map[string][]chan struct{}
+}
+
+func newScheduler(limit int) *scheduler {
+	if limit <= 0 {
+		return nil
+	}
+	return &scheduler{
+		limit:   limit,
+		waiting: map[string][]chan struct{}{},
+	}
+}
+
+// acquire blocks until a slot is available for the given session. The returned function must be called to release
+// the slot. A nil scheduler never blocks.
+func (s *scheduler) acquire(ctx context.Context, session string) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	s.lock.Lock()
+	if s.running < s.limit && len(s.sessions) == 0 {
+		s.running++
+		s.lock.Unlock()
+		return s.releaseFunc(), nil
+	}
+
+	ready := make(chan struct{})
+	if len(s.waiting[session]) == 0 {
+		s.sessions = append(s.sessions, session)
+	}
+	s.waiting[session] = append(s.waiting[session], ready)
+	s.lock.Unlock()
+
+	select {
+	case <-ready:
+		return s.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	s.lock.Lock()
+	removed := s.remove(session, ready)
+	s.lock.Unlock()
+
+	if !removed {
+		// The slot was handed to us while we were giving up, so pass it on.
+		s.release()
+	}
+	return nil, ctx.Err()
+}
+
+func (s *scheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(s.release)
+	}
+}
+
+func (s *scheduler) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.sessions) == 0 {
+		s.running--
+		return
+	}
+
+	session := s.sessions[0]
+	s.sessions = s.sessions[1:]
+
+	queue := s.waiting[session]
+	next := queue[0]
+	if len(queue) == 1 {
+		delete(s.waiting, session)
+	} else {
+		s.waiting[session] = queue[1:]
+		s.sessions = append(s.sessions, session)
+	}
+
+	// The slot is handed directly to the next waiter, so running is unchanged.
+	close(next)
+}
+
+// remove must be called with the lock held. It returns false if the waiter is no longer queued.
+func (s *scheduler) remove(session string, ready chan struct{}) bool {
+	queue := s.waiting[session]
+	for i, waiter := range queue {
+		if waiter != ready {
+			continue
+		}
+		queue = append(queue[:i:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			s.waiting[session] = queue
+			return true
+		}
+		delete(s.waiting, session)
+		for j, name := range s.sessions {
+			if name == session {
+				s.sessions = append(s.sessions[:j:j], s.sessions[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// acquire blocks until the call can run within the limit of all LLM calls and tool executions, and the limit of either
+// LLM calls or tool executions. The returned function must be called to release the slots.
+func (r *Runner) acquire(callCtx engine.Context, llm bool) (func(), error) {
+	session := sessionID(callCtx)
+
+	limit := r.toolScheduler
+	if llm {
+		limit = r.llmScheduler
+	}
+	// The slot of the narrower limit is acquired first, so that waiting for it doesn't hold a slot of the overall limit.
+	releaseLimit, err := limit.acquire(callCtx.Ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := r.scheduler.acquire(callCtx.Ctx, session)
+	if err != nil {
+		releaseLimit()
+		return nil, err
+	}
+
+	return func() {
+		release()
+		releaseLimit()
+	}, nil
+}
+
+// acquireCall acquires the slot of a call to the engine. A model call of a tool with Best Of set doesn't take a slot
+// of its own, since each of its completions acquires one through acquireCandidate, and waiting for those while holding
+// a slot would never end under a limit lower than Best Of.
+func (r *Runner) acquireCall(callCtx engine.Context, llm bool) (func(), error) {
+	if llm && callCtx.Tool.BestOf > 1 && !callCtx.Tool.IsAgents() {
+		return func() {}, nil
+	}
+	return r.acquire(callCtx, llm)
+}
+
+// acquireCandidate returns the function that acquires the slot of an LLM call for each completion of a request of the
+// call with Best Of set.
+func (r *Runner) acquireCandidate(callCtx engine.Context) func(context.Context) (func(), error) {
+	return func(ctx context.Context) (func(), error) {
+		callCtx.Ctx = ctx
+		return r.acquire(callCtx, true)
+	}
+}
+
+// sessionID returns the ID of the root call, which identifies the session the call belongs to.
+func sessionID(callCtx engine.Context) string {
+	root := &callCtx
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root.ID
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+
+	"github.com/stretchr/testify/require"
+)
+
+func queued(s *scheduler) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var n int
+	for _, queue := range s.waiting {
+		n += len(queue)
+	}
+	return n
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	s := newScheduler(1)
+	release, err := s.acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	order := make(chan string, 4)
+	enqueue := func(session, name string) {
+		n := queued(s)
+		go func() {
+			release, err := s.acquire(context.Background(), session)
+			require.NoError(t, err)
+			order <- name
+			release()
+		}()
+		require.Eventually(t, func() bool { return queued(s) == n+1 }, time.Second, time.Millisecond)
+	}
+
+	enqueue("a", "a1")
+	enqueue("a", "a2")
+	enqueue("a", "a3")
+	enqueue("b", "b1")
+
+	release()
+
+	var got []string
+	for range 4 {
+		got = append(got, <-order)
+	}
+	require.Equal(t, []string{"a1", "b1", "a2", "a3"}, got)
+	require.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.running == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(1)
+	release, err := s.acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := s.acquire(ctx, "b")
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return queued(s) == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	require.Equal(t, 0, queued(s))
+	require.Empty(t, s.sessions)
+
+	release()
+	require.Equal(t, 0, s.running)
+}
+
+func TestSchedulerUnlimited(t *testing.T) {
+	s := newScheduler(0)
+	require.Nil(t, s)
+	release, err := s.acquire(context.Background(), "a")
+	require.NoError(t, err)
+	release()
+}
+
+func TestRunnerConcurrencyLimits(t *testing.T) {
+	r := &Runner{
+		scheduler:     newScheduler(2),
+		llmScheduler:  newScheduler(1),
+		toolScheduler: newScheduler(2),
+	}
+	callCtx := engine.Context{Ctx: context.Background()}
+
+	releaseLLM, err := r.acquire(callCtx, true)
+	require.NoError(t, err)
+
+	// A second LLM call waits for the first, but a tool can still run.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = r.acquire(engine.Context{Ctx: ctx}, true)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, r.scheduler.running)
+
+	releaseTool, err := r.acquire(callCtx, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, r.scheduler.running)
+
+	releaseLLM()
+	releaseTool()
+	require.Equal(t, 0, r.scheduler.running)
+	require.Equal(t, 0, r.llmScheduler.running)
+	require.Equal(t, 0, r.toolScheduler.running)
+
+	// The completions of a tool with Best Of set take the slots, not its call, so they don't wait for their own call.
+	callCtx.Tool.BestOf = 2
+	releaseCall, err := r.acquireCall(callCtx, true)
+	require.NoError(t, err)
+	releaseCandidate, err := r.acquireCandidate(callCtx)(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, r.llmScheduler.running)
+	releaseCandidate()
+	releaseCall()
+	require.Equal(t, 0, r.llmScheduler.running)
+}
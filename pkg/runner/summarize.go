@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// ToolOutputSummary describes how the output of a tool call was summarized.
+type ToolOutputSummary struct {
+	ToolID string `json:"toolID,omitempty"`
+	CallID string `json:"callID,omitempty"`
+	// Tokens and SummaryTokens are estimates of the size of the output and of its summary.
+	Tokens        int `json:"tokens,omitempty"`
+	SummaryTokens int `json:"summaryTokens,omitempty"`
+	// Chunks is the number of parts that the output was split into to be summarized, when it was too large for one.
+	Chunks int `json:"chunks,omitempty"`
+}
+
+// summarizeResults replaces the outputs of tool calls that are over the token threshold with their summaries.
+func (r *Runner) summarizeResults(callCtx engine.Context, monitor Monitor, e *engine.Engine, results []engine.CallResult) error {
+	if r.summarizeTokens <= 0 {
+		return nil
+	}
+
+	for i, result := range results {
+		tokens := engine.CountTokens(result.Result)
+		if result.CallID == "" || tokens <= r.summarizeTokens {
+			continue
+		}
+
+		toolName := result.ToolID
+		if tool, ok := callCtx.Program.ToolSet[result.ToolID]; ok && tool.Name != "" {
+			toolName = tool.Name
+		}
+
+		// Each chunk of the output is summarized by an LLM call of its own, within the limit of LLM calls.
+		summary, chunks, err := e.Summarize(callCtx.Ctx, types.FirstSet(r.summaryModel, callCtx.Tool.ModelName), toolName, result.Result, r.summarizeTokens, func() (func(), error) {
+			return r.acquire(callCtx, true)
+		})
+		if err != nil {
+			return err
+		}
+
+		// The model is told that it is not seeing the whole output, so that it doesn't take the summary for it.
+		results[i].Result = fmt.Sprintf("[The output of this tool was about %d tokens, which is too long, so this is a summary of it]\n%s", tokens, summary)
+		monitor.Event(Event{
+			Time:        time.Now(),
+			CallContext: callCtx.GetCallContext(),
+			Type:        EventTypeCallSummarize,
+			Content:     getEventContent(summary, callCtx),
+			ToolOutputSummary: &ToolOutputSummary{
+				ToolID:        result.ToolID,
+				CallID:        result.CallID,
+				Tokens:        tokens,
+				SummaryTokens: engine.CountTokens(summary),
+				Chunks:        chunks,
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+const defaultValidateRetries = 3
+
+// validate checks the output of the tool with its validator and returns what is wrong with it, or an empty string if
+// the output is valid.
+func (r *Runner) validate(callCtx engine.Context, monitor Monitor, env []string, output string) (string, error) {
+	if callCtx.Tool.Validate == "" {
+		return "", nil
+	}
+
+	if callCtx.Tool.ValidateSchema() {
+		return validateSchema(callCtx.Tool.Validate, output)
+	}
+
+	validateToolRef, ok, err := callCtx.Tool.GetValidateTool()
+	if err != nil || !ok {
+		return "", err
+	}
+
+	inputData, err := json.Marshal(map[string]any{
+		"output": output,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal input for validate tool: %w", err)
+	}
+	res, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, validateToolRef.ToolID, string(inputData), "", engine.ValidateToolCategory, nil)
+	if err != nil {
+		return "", err
+	}
+	if res.Result == nil {
+		return "", fmt.Errorf("invalid state: validate tool [%s] can not result in a chat continuation", validateToolRef.Reference)
+	}
+	return strings.TrimSpace(*res.Result), nil
+}
+
+func validateSchema(schemaData, output string) (string, error) {
+	var schema openapi3.Schema
+	if err := json.Unmarshal([]byte(schemaData), &schema); err != nil {
+		return "", fmt.Errorf("invalid validate JSON schema: %w", err)
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return fmt.Sprintf("the response is not valid JSON: %v", err), nil
+	}
+	if err := schema.VisitJSON(value); err != nil {
+		// The full error includes the schema and value, only the reason is useful to the LLM
+		if schemaErr := (*openapi3.SchemaError)(nil); errors.As(err, &schemaErr) {
+			if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+				return fmt.Sprintf("%s at /%s", schemaErr.Reason, strings.Join(pointer, "/")), nil
+			}
+			return schemaErr.Reason, nil
+		}
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+func validateRetries(callCtx engine.Context) int {
+	if callCtx.Tool.ValidateRetries != nil {
+		return *callCtx.Tool.ValidateRetries
+	}
+	return defaultValidateRetries
+}
+
+func validateFeedback(problem string) string {
+	return fmt.Sprintf("Your response failed validation: %s\n\nRespond again with a corrected response.", problem)
+}
@@ -0,0 +1,80 @@
+package scaffold
+
+// builtinTemplates are the files of the template used when no template repository is given, keyed by runtime.
+var builtinTemplates = map[string]map[string]string{
+	"": {
+		"tool.gpt": `Name: {{name}}
+Description: Describe what {{name}} does
+Param: input: The input to {{name}}
+
+Do something useful with ${input}.
+`,
+		"README.md": readme,
+	},
+	"python": {
+		"tool.gpt": `Name: {{name}}
+Description: Describe what {{name}} does
+Param: input: The input to {{name}}
+
+#!/usr/bin/env python3 ${GPTSCRIPT_TOOL_DIR}/tool.py
+`,
+		"tool.py": `import os
+
+print(f"Hello from {{name}}: {os.environ.get('INPUT', '')}")
+`,
+		"requirements.txt": "",
+		"README.md":        readme,
+	},
+	"node": {
+		"tool.gpt": `Name: {{name}}
+Description: Describe what {{name}} does
+Param: input: The input to {{name}}
+
+#!/usr/bin/env node ${GPTSCRIPT_TOOL_DIR}/index.js
+`,
+		"index.js": "console.log(`Hello from {{name}}: ${process.env.INPUT ?? ''}`)\n",
+		"package.json": `{
+  "name": "{{name}}",
+  "version": "0.0.1",
+  "author": "{{author}}",
+  "private": true
+}
+`,
+		"README.md": readme,
+	},
+	"go": {
+		"tool.gpt": `Name: {{name}}
+Description: Describe what {{name}} does
+Param: input: The input to {{name}}
+
+#!${GPTSCRIPT_TOOL_DIR}/bin/gptscript-go-tool
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Printf("Hello from {{name}}: %s\n", os.Getenv("INPUT"))
+}
+`,
+		"go.mod": `module {{name}}
+
+go 1.22.1
+`,
+		"README.md": readme,
+	},
+}
+
+const readme = `# {{name}}
+
+A GPTScript tool by {{author}}.
+
+Run it with:
+
+` + "```" + `
+gptscript tool.gpt --input "some input"
+` + "```" + `
+`
@@ -0,0 +1,190 @@
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
+)
+
+// Options configure the scaffolding of a new tool.
+type Options struct {
+	// Name of the tool, also used as the directory to create if Dir is not set.
+	Name string
+	// Dir is the directory to create the tool in, it must not exist or be empty.
+	Dir string
+	// Author defaults to the git user.name, or the name of the current user.
+	Author string
+	// Runtime is one of python, node, go, or empty for a tool without code.
+	Runtime string
+	// Template is a local directory, git URL, or github.com/org/repo reference, optionally followed by @ref, to copy
+	// instead of the built-in template.
+	Template string
+	// Vars are additional variables to substitute in the template.
+	Vars map[string]string
+}
+
+// New creates a new tool from a template. Every {{name}}, {{author}}, {{runtime}}, and {{key}} of Vars in the file
+// names and contents of the template is substituted with its value.
+func New(ctx context.Context, opts Options) (string, error) {
+	if opts.Name == "" {
+		return "", fmt.Errorf("tool name is required")
+	}
+	if _, ok := builtinTemplates[opts.Runtime]; !ok {
+		return "", fmt.Errorf("invalid runtime %q, must be one of %s", opts.Runtime, strings.Join(runtimes(), ", "))
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		dir = opts.Name
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("%s already exists and is not empty", dir)
+	}
+
+	vars := map[string]string{
+		"name":    opts.Name,
+		"author":  opts.Author,
+		"runtime": opts.Runtime,
+	}
+	if vars["author"] == "" {
+		vars["author"] = defaultAuthor(ctx)
+	}
+	for k, v := range opts.Vars {
+		vars[k] = v
+	}
+	replacer := newReplacer(vars)
+
+	if opts.Template == "" {
+		return dir, writeBuiltin(dir, builtinTemplates[opts.Runtime], replacer)
+	}
+
+	src, cleanup, err := fetchTemplate(ctx, opts.Template)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	return dir, copyTemplate(src, dir, replacer)
+}
+
+func runtimes() (result []string) {
+	for k := range builtinTemplates {
+		if k != "" {
+			result = append(result, k)
+		}
+	}
+	sort.Strings(result)
+	return
+}
+
+func newReplacer(vars map[string]string) *strings.Replacer {
+	var oldNew []string
+	for k, v := range vars {
+		oldNew = append(oldNew, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(oldNew...)
+}
+
+func defaultAuthor(ctx context.Context) string {
+	if out, err := exec.CommandContext(ctx, "git", "config", "user.name").Output(); err == nil {
+		if name := strings.TrimSpace(string(out)); name != "" {
+			return name
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		if u.Name != "" {
+			return u.Name
+		}
+		return u.Username
+	}
+	return ""
+}
+
+func writeBuiltin(dir string, files map[string]string, replacer *strings.Replacer) error {
+	for name, content := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(replacer.Replace(content)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchTemplate returns the directory of the template, cloning it to a temporary directory if it is not local.
+func fetchTemplate(ctx context.Context, template string) (string, func(), error) {
+	if info, err := os.Stat(template); err == nil && info.IsDir() {
+		return template, func() {}, nil
+	}
+
+	repo, ref := template, ""
+	if i := strings.LastIndex(template, "@"); i > strings.LastIndex(template, "/") {
+		repo, ref = template[:i], template[i+1:]
+	}
+	if !strings.Contains(repo, "://") && !strings.HasPrefix(repo, "git@") {
+		repo = "https://" + repo
+	}
+
+	tmp, err := os.MkdirTemp("", "gptscript-template-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(tmp)
+	}
+
+	args := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	if err := debugcmd.New(ctx, "git", append(args, repo, tmp)...).Run(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to clone template %s: %w", template, err)
+	}
+
+	return tmp, cleanup, nil
+}
+
+func copyTemplate(src, dir string, replacer *strings.Replacer) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(dir, replacer.Replace(rel))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, []byte(replacer.Replace(string(data))), info.Mode().Perm())
+	})
+}
@@ -0,0 +1,55 @@
+package scaffold
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBuiltin(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello")
+	_, err := New(context.Background(), Options{
+		Name:    "hello",
+		Dir:     dir,
+		Author:  "Jane",
+		Runtime: "node",
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"name": "hello"`)
+	assert.Contains(t, string(data), `"author": "Jane"`)
+	assert.FileExists(t, filepath.Join(dir, "tool.gpt"))
+	assert.FileExists(t, filepath.Join(dir, "index.js"))
+}
+
+func TestNewTemplate(t *testing.T) {
+	template := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(template, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(template, ".git", "HEAD"), []byte("ref"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(template, "{{name}}.gpt"), []byte("Name: {{name}}\nDescription: by {{author}} ({{license}}) in {{runtime}}\n{{unknown}}\n"), 0644))
+
+	dir := filepath.Join(t.TempDir(), "out")
+	_, err := New(context.Background(), Options{
+		Name:     "hello",
+		Dir:      dir,
+		Author:   "Jane",
+		Runtime:  "python",
+		Template: template,
+		Vars:     map[string]string{"license": "MIT"},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "hello.gpt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Name: hello\nDescription: by Jane (MIT) in python\n{{unknown}}\n", string(data))
+	assert.NoDirExists(t, filepath.Join(dir, ".git"))
+
+	_, err = New(context.Background(), Options{Name: "hello", Dir: dir, Template: template})
+	assert.ErrorContains(t, err, "not empty")
+}
@@ -0,0 +1,261 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"sigs.k8s.io/yaml"
+)
+
+var log = mvl.Package()
+
+// maxRecordedOutput is how much of the output of a run is kept in its history record.
+const maxRecordedOutput = 4096
+
+// Job is a tool run on a schedule.
+type Job struct {
+	// Name identifies the job in logs and names its history file, it defaults to the tool.
+	Name string `json:"name,omitempty"`
+	// Cron is a five field cron expression, a descriptor such as @daily, or @every followed by a duration.
+	Cron    string `json:"cron,omitempty"`
+	Tool    string `json:"tool,omitempty"`
+	SubTool string `json:"subTool,omitempty"`
+	Input   string `json:"input,omitempty"`
+	// NotifyURL overrides the NotifyURL of the scheduler for this job.
+	NotifyURL string `json:"notifyURL,omitempty"`
+}
+
+type File struct {
+	Schedules []Job `json:"schedules,omitempty"`
+}
+
+// Record is the history of a single run of a job.
+type Record struct {
+	Job     string    `json:"job"`
+	Tool    string    `json:"tool"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end,omitempty"`
+	Skipped bool      `json:"skipped,omitempty"`
+	Output  string    `json:"output,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// RunFunc runs the tool of the job and returns its output.
+type RunFunc func(ctx context.Context, job Job) (string, error)
+
+type Options struct {
+	// HistoryDir is where the history of each job is appended to <job name>.jsonl, no history is kept if empty.
+	HistoryDir string
+	// NotifyURL is sent a POST of the JSON Record of every failed run.
+	NotifyURL string
+	Run       RunFunc
+}
+
+type Scheduler struct {
+	opts      Options
+	historyMu sync.Mutex
+}
+
+// ReadFile reads a YAML or JSON file of schedules.
+func ReadFile(path string) ([]Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules file %s: %w", path, err)
+	}
+	return file.Schedules, nil
+}
+
+func New(opts Options) *Scheduler {
+	return &Scheduler{
+		opts: opts,
+	}
+}
+
+// Run runs the jobs on their schedules until the context is cancelled. A job is skipped if its previous run has not
+// finished by the time it is next scheduled.
+func (s *Scheduler) Run(ctx context.Context, jobs []Job) error {
+	schedules := make([]Schedule, len(jobs))
+	names := map[string]bool{}
+	for i := range jobs {
+		if jobs[i].Tool == "" {
+			return fmt.Errorf("schedule %d has no tool", i)
+		}
+		if jobs[i].Name == "" {
+			jobs[i].Name = strings.TrimSuffix(filepath.Base(jobs[i].Tool), ".gpt")
+		}
+		if names[jobs[i].Name] {
+			return fmt.Errorf("duplicate schedule name %q", jobs[i].Name)
+		}
+		names[jobs[i].Name] = true
+
+		var err error
+		schedules[i], err = Parse(jobs[i].Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", jobs[i].Name, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runJob(ctx, jobs[i], schedules[i])
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job Job, schedule Schedule) {
+	var (
+		running atomic.Bool
+		wg      sync.WaitGroup
+	)
+	defer wg.Wait()
+
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Errorf("Schedule %s will never run again", job.Name)
+			return
+		}
+		log.Debugf("Next run of %s at %s", job.Name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !running.CompareAndSwap(false, true) {
+			log.Infof("Skipping run of %s, the previous run has not finished", job.Name)
+			s.record(job, Record{
+				Job:     job.Name,
+				Tool:    job.Tool,
+				Start:   time.Now(),
+				Skipped: true,
+			})
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer running.Store(false)
+			s.runOnce(ctx, job)
+		}()
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	record := Record{
+		Job:   job.Name,
+		Tool:  job.Tool,
+		Start: time.Now(),
+	}
+	log.Infof("Running %s", job.Name)
+
+	output, err := s.opts.Run(ctx, job)
+	record.End = time.Now()
+	if len(output) > maxRecordedOutput {
+		output = output[:maxRecordedOutput]
+	}
+	record.Output = output
+	if err != nil {
+		record.Error = err.Error()
+		log.Errorf("Run of %s failed: %v", job.Name, err)
+	} else {
+		log.Infof("Run of %s finished in %s", job.Name, record.End.Sub(record.Start).Round(time.Millisecond))
+	}
+
+	s.record(job, record)
+	if err != nil {
+		s.notify(ctx, job, record)
+	}
+}
+
+func (s *Scheduler) record(job Job, record Record) {
+	if s.opts.HistoryDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf("Failed to record run of %s: %v", job.Name, err)
+		return
+	}
+
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	if err := os.MkdirAll(s.opts.HistoryDir, 0755); err != nil {
+		log.Errorf("Failed to record run of %s: %v", job.Name, err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(s.opts.HistoryDir, job.Name+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf("Failed to record run of %s: %v", job.Name, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Errorf("Failed to record run of %s: %v", job.Name, err)
+	}
+}
+
+func (s *Scheduler) notify(ctx context.Context, job Job, record Record) {
+	url := job.NotifyURL
+	if url == "" {
+		url = s.opts.NotifyURL
+	}
+	if url == "" {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf("Failed to send failure notification for %s: %v", job.Name, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		log.Errorf("Failed to send failure notification for %s: %v", job.Name, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := proxy.Client.Do(req)
+	if err != nil {
+		log.Errorf("Failed to send failure notification for %s: %v", job.Name, err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Errorf("Failed to send failure notification for %s: %s", job.Name, resp.Status)
+	}
+}
@@ -0,0 +1,48 @@
+package sdkserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// saveArtifacts copies the artifacts of a run out of its workspace so that they can be downloaded after the
+// workspace is deleted.
+func (s *server) saveArtifacts(g *gptscript.GPTScript, prg types.Program, runID string) ([]types.Artifact, error) {
+	artifacts, err := g.Artifacts(prg)
+	if err != nil || len(artifacts) == 0 {
+		return nil, err
+	}
+
+	if err := g.SaveArtifacts(artifacts, filepath.Join(s.artifactsDir, runID)); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// downloadArtifact serves an artifact saved from a previous run.
+func (s *server) downloadArtifact(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	runID, name := r.PathValue("run"), r.PathValue("name")
+	if strings.ContainsAny(runID, `/\`) || runID == "." || runID == ".." || types.ValidateArtifactPattern(name) != nil || path.Clean(name) != name {
+		writeError(logger, w, http.StatusBadRequest, fmt.Errorf("invalid artifact %s/%s", runID, name))
+		return
+	}
+
+	file := filepath.Join(s.artifactsDir, runID, filepath.FromSlash(name))
+	if st, err := os.Lstat(file); err != nil || !st.Mode().IsRegular() {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("artifact %s not found for run %s", name, runID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+	http.ServeFile(w, r, file)
+}
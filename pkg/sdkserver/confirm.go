@@ -22,6 +22,18 @@ func (s *server) authorize(ctx engine.Context, input string) (runner.AuthorizerR
 		}, nil
 	}
 
+	return s.waitForConfirmation(ctx, CallConfirm, input)
+}
+
+// authorizeCredential sends a credentialConfirm event, with the name of the credential as the input, and waits for
+// the response. Responding with remember set allows the tool to use the credential for the rest of the run.
+func (s *server) authorizeCredential(ctx engine.Context, credName string) (runner.AuthorizerResponse, error) {
+	defer gcontext.GetPauseFuncFromCtx(ctx.Ctx)()()
+
+	return s.waitForConfirmation(ctx, CredentialConfirm, credName)
+}
+
+func (s *server) waitForConfirmation(ctx engine.Context, eventType runner.EventType, input string) (runner.AuthorizerResponse, error) {
 	s.lock.RLock()
 	authChan := s.waitingToConfirm[ctx.ID]
 	s.lock.RUnlock()
@@ -46,7 +58,7 @@ func (s *server) authorize(ctx engine.Context, input string) (runner.AuthorizerR
 			Event: runner.Event{
 				Time:        time.Now(),
 				CallContext: ctx.GetCallContext(),
-				Type:        CallConfirm,
+				Type:        eventType,
 			},
 			Input: input,
 			RunID: runID,
@@ -0,0 +1,102 @@
+package sdkserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+)
+
+// defaultListRuns is the number of runs that are listed without a limit in the query.
+const defaultListRuns = 100
+
+// listRuns returns the most recent runs, without their events.
+func (s *server) listRuns(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+
+	limit := defaultListRuns
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			writeError(logger, w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+	}
+
+	runs, err := s.storage.ListRuns(r.Context(), limit)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to list runs: %w", err))
+		return
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": runs})
+}
+
+func (s *server) getRun(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	run, err := s.storage.GetRun(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to get run %s: %w", id, err))
+		return
+	} else if run == nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("run %s not found", id))
+		return
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": run})
+}
+
+// listRunEvents returns the events that were streamed for a run, other than the progress of its calls.
+func (s *server) listRunEvents(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	run, err := s.storage.GetRun(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to get run %s: %w", id, err))
+		return
+	} else if run == nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("run %s not found", id))
+		return
+	}
+
+	events, err := s.storage.ListEvents(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to list events of run %s: %w", id, err))
+		return
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": events})
+}
+
+func (s *server) getChat(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	state, ok, err := s.storage.GetChat(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to get chat %s: %w", id, err))
+		return
+	} else if !ok {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("chat %s not found", id))
+		return
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": map[string]any{"chatState": json.RawMessage(state)}})
+}
+
+func (s *server) deleteChat(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	if err := s.storage.DeleteChat(r.Context(), id); err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to delete chat %s: %w", id, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gptscript-ai/broadcaster"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	gserver "github.com/gptscript-ai/gptscript/pkg/server"
@@ -30,8 +31,9 @@ func (s SessionFactory) Start(ctx context.Context, prg *types.Program, env []str
 		s.events.C <- event{
 			Event: gserver.Event{
 				Event: runner.Event{
-					Time: time.Now(),
-					Type: runner.EventTypeRunStart,
+					Time:   time.Now(),
+					Type:   runner.EventTypeRunStart,
+					Labels: gcontext.GetLabels(ctx),
 				},
 				RunID:   id,
 				Program: prg,
@@ -83,8 +85,9 @@ func (s *Session) Stop(ctx context.Context, output string, err error) {
 	e := event{
 		Event: gserver.Event{
 			Event: runner.Event{
-				Time: time.Now(),
-				Type: runner.EventTypeRunFinish,
+				Time:   time.Now(),
+				Type:   runner.EventTypeRunFinish,
+				Labels: gcontext.GetLabels(ctx),
 			},
 			RunID:  s.id,
 			Input:  s.input,
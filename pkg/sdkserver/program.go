@@ -0,0 +1,160 @@
+package sdkserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// programInfo describes the tools of a program so that clients can render them without parsing the program.
+type programInfo struct {
+	Name        string     `json:"name,omitempty"`
+	EntryToolID string     `json:"entryToolId,omitempty"`
+	Tools       []toolInfo `json:"tools"`
+}
+
+// toolInfo describes a tool of a program. References to other tools are resolved to the IDs of tools in the program.
+type toolInfo struct {
+	ID            string           `json:"id"`
+	Name          string           `json:"name,omitempty"`
+	Description   string           `json:"description,omitempty"`
+	Entry         bool             `json:"entry,omitempty"`
+	Chat          bool             `json:"chat,omitempty"`
+	ModelName     string           `json:"modelName,omitempty"`
+	Arguments     *openapi3.Schema `json:"arguments,omitempty"`
+	Credentials   []string         `json:"credentials,omitempty"`
+	Tools         []string         `json:"tools,omitempty"`
+	Agents        []string         `json:"agents,omitempty"`
+	Context       []string         `json:"context,omitempty"`
+	Export        []string         `json:"export,omitempty"`
+	ExportContext []string         `json:"exportContext,omitempty"`
+	Source        types.ToolSource `json:"source"`
+}
+
+// programTools returns the tools of a program.
+func (s *server) programTools(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	prg, ok := s.requestProgram(w, r)
+	if !ok {
+		return
+	}
+
+	info := programInfo{
+		Name:        prg.Name,
+		EntryToolID: prg.EntryToolID,
+		Tools:       make([]toolInfo, 0, len(prg.ToolSet)),
+	}
+	for _, id := range sortedToolIDs(prg) {
+		ti, err := newToolInfo(prg, prg.ToolSet[id])
+		if err != nil {
+			writeError(logger, w, http.StatusInternalServerError, err)
+			return
+		}
+		info.Tools = append(info.Tools, ti)
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": info})
+}
+
+// programTool returns the tool of a program with the name in the path.
+func (s *server) programTool(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	prg, ok := s.requestProgram(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	for _, id := range sortedToolIDs(prg) {
+		tool := prg.ToolSet[id]
+		if tool.Name != name && (name != prg.Name || id != prg.EntryToolID) {
+			continue
+		}
+		ti, err := newToolInfo(prg, tool)
+		if err != nil {
+			writeError(logger, w, http.StatusInternalServerError, err)
+			return
+		}
+		writeResponse(logger, w, map[string]any{"stdout": ti})
+		return
+	}
+
+	writeError(logger, w, http.StatusNotFound, fmt.Errorf("tool %s not found", name))
+}
+
+// requestProgram loads the program from the file and subTool query parameters of a GET request, or from the body of
+// a POST request.
+func (s *server) requestProgram(w http.ResponseWriter, r *http.Request) (types.Program, bool) {
+	logger := gcontext.GetLogger(r.Context())
+	reqObject := new(toolOrFileRequest)
+	if r.Method == http.MethodGet {
+		reqObject.File = r.URL.Query().Get("file")
+		reqObject.SubTool = r.URL.Query().Get("subTool")
+		if reqObject.File == "" {
+			writeError(logger, w, http.StatusBadRequest, fmt.Errorf("file query parameter is required"))
+			return types.Program{}, false
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(reqObject); err != nil {
+		writeError(logger, w, http.StatusBadRequest, fmt.Errorf("failed to decode request body: %w", err))
+		return types.Program{}, false
+	}
+
+	prg, err := s.loadProgram(r.Context(), reqObject)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to load program: %w", err))
+		return types.Program{}, false
+	}
+	return prg, true
+}
+
+func newToolInfo(prg types.Program, tool types.Tool) (toolInfo, error) {
+	info := toolInfo{
+		ID:          tool.ID,
+		Name:        tool.Name,
+		Description: tool.Description,
+		Entry:       tool.ID == prg.EntryToolID,
+		Chat:        tool.Chat,
+		ModelName:   tool.ModelName,
+		Arguments:   tool.Arguments,
+		Credentials: tool.Credentials,
+		Source:      tool.Source,
+	}
+	if info.Name == "" && info.Entry {
+		info.Name = prg.Name
+	}
+
+	for _, refs := range []struct {
+		names  []string
+		target *[]string
+	}{
+		{tool.Tools, &info.Tools},
+		{tool.Agents, &info.Agents},
+		{tool.Context, &info.Context},
+		{tool.Export, &info.Export},
+		{tool.ExportContext, &info.ExportContext},
+	} {
+		toolRefs, err := tool.GetToolRefsFromNames(refs.names)
+		if err != nil {
+			return info, fmt.Errorf("failed to resolve references of tool %s: %w", tool.ID, err)
+		}
+		for _, ref := range toolRefs {
+			*refs.target = append(*refs.target, ref.ToolID)
+		}
+	}
+
+	return info, nil
+}
+
+func sortedToolIDs(prg types.Program) []string {
+	ids := make([]string, 0, len(prg.ToolSet))
+	for id := range prg.ToolSet {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
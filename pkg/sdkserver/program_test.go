@@ -0,0 +1,89 @@
+package sdkserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/stretchr/testify/require"
+)
+
+const supportProgram = `Name: support
+Description: Answers support questions
+Tools: lookup
+Credentials: crm-token
+Args: question: The question of the customer
+
+Answer the question.
+
+---
+Name: lookup
+Description: Looks up an order
+Args: order: The ID of the order
+
+#!/bin/echo ${order}
+
+---
+Name: crm-token
+
+#!/bin/echo {"env": {"CRM_TOKEN": "secret"}}
+`
+
+func TestProgramTools(t *testing.T) {
+	s := &server{client: &gptscript.GPTScript{}}
+	mux := http.NewServeMux()
+	s.addRoutes(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Stdout json.RawMessage `json:"stdout"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NoError(t, json.Unmarshal(resp.Stdout, v))
+	}
+
+	body, err := json.Marshal(map[string]string{"content": supportProgram})
+	require.NoError(t, err)
+
+	var info programInfo
+	decode(do(http.MethodPost, "/program/tools", string(body)), &info)
+	require.Len(t, info.Tools, 3)
+
+	tools := map[string]toolInfo{}
+	for _, tool := range info.Tools {
+		tools[tool.Name] = tool
+	}
+	support, lookup := tools["support"], tools["lookup"]
+	require.Equal(t, info.EntryToolID, support.ID)
+	require.True(t, support.Entry)
+	require.Equal(t, "Answers support questions", support.Description)
+	require.Equal(t, []string{"crm-token"}, support.Credentials)
+	require.Contains(t, support.Arguments.Properties, "question")
+	// References are resolved to the IDs of the tools.
+	require.Equal(t, []string{lookup.ID}, support.Tools)
+	require.False(t, lookup.Entry)
+
+	// Files are loaded from the query of GET requests.
+	file := filepath.Join(t.TempDir(), "support.gpt")
+	require.NoError(t, os.WriteFile(file, []byte(supportProgram), 0644))
+
+	var tool toolInfo
+	decode(do(http.MethodGet, "/program/tools/lookup?file="+file, ""), &tool)
+	require.Equal(t, "Looks up an order", tool.Description)
+	require.Contains(t, tool.Arguments.Properties, "order")
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/program/tools/missing?file="+file, "").Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/program/tools", "").Code)
+}
@@ -0,0 +1,119 @@
+package sdkserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// priorities are in the order that waiting runs are started.
+var priorities = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// priorityIndex returns the index of the priority of a run in priorities. Runs without a priority are normal.
+func priorityIndex(priority string) (int, error) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	for i, p := range priorities {
+		if p == priority {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid priority %q, must be %s, %s, or %s", priority, PriorityLow, PriorityNormal, PriorityHigh)
+}
+
+// runQueue limits the number of runs of the server at once. Runs over the limit wait for a running one to finish,
+// and are started in order of priority, then in the order they were submitted, so that interactive runs are not
+// stuck behind batches of low priority runs.
+type runQueue struct {
+	lock    sync.Mutex
+	limit   int
+	running int
+	waiting [][]chan struct{}
+}
+
+func newRunQueue(limit int) *runQueue {
+	if limit <= 0 {
+		return nil
+	}
+	return &runQueue{
+		limit:   limit,
+		waiting: make([][]chan struct{}, len(priorities)),
+	}
+}
+
+// acquire blocks until the run can start. The returned function must be called when the run finishes. A nil queue
+// never blocks.
+func (q *runQueue) acquire(ctx context.Context, priority int) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+
+	q.lock.Lock()
+	if q.running < q.limit {
+		q.running++
+		q.lock.Unlock()
+		return q.releaseFunc(), nil
+	}
+
+	ready := make(chan struct{})
+	q.waiting[priority] = append(q.waiting[priority], ready)
+	q.lock.Unlock()
+
+	select {
+	case <-ready:
+		return q.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	q.lock.Lock()
+	removed := q.remove(priority, ready)
+	q.lock.Unlock()
+
+	if !removed {
+		// The slot was handed to us while we were giving up, so pass it on.
+		q.release()
+	}
+	return nil, ctx.Err()
+}
+
+func (q *runQueue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(q.release)
+	}
+}
+
+func (q *runQueue) release() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i, queue := range q.waiting {
+		if len(queue) == 0 {
+			continue
+		}
+		q.waiting[i] = queue[1:]
+		// The slot is handed directly to the next waiter, so running is unchanged.
+		close(queue[0])
+		return
+	}
+	q.running--
+}
+
+// remove must be called with the lock held. It returns false if the waiter is no longer queued.
+func (q *runQueue) remove(priority int, ready chan struct{}) bool {
+	queue := q.waiting[priority]
+	for i, waiter := range queue {
+		if waiter == ready {
+			q.waiting[priority] = append(queue[:i:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package sdkserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func queuedRuns(q *runQueue) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var n int
+	for _, queue := range q.waiting {
+		n += len(queue)
+	}
+	return n
+}
+
+func TestRunQueuePriority(t *testing.T) {
+	q := newRunQueue(1)
+	release, err := q.acquire(context.Background(), 0)
+	require.NoError(t, err)
+
+	order := make(chan string, 4)
+	enqueue := func(priority, name string) {
+		index, err := priorityIndex(priority)
+		require.NoError(t, err)
+		n := queuedRuns(q)
+		go func() {
+			release, err := q.acquire(context.Background(), index)
+			require.NoError(t, err)
+			order <- name
+			release()
+		}()
+		require.Eventually(t, func() bool { return queuedRuns(q) == n+1 }, time.Second, time.Millisecond)
+	}
+
+	enqueue(PriorityLow, "eval1")
+	enqueue(PriorityLow, "eval2")
+	enqueue("", "default")
+	enqueue(PriorityHigh, "chat")
+
+	release()
+
+	var got []string
+	for range 4 {
+		got = append(got, <-order)
+	}
+	require.Equal(t, []string{"chat", "default", "eval1", "eval2"}, got)
+	require.Eventually(t, func() bool {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		return q.running == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestRunQueueCancel(t *testing.T) {
+	q := newRunQueue(1)
+	release, err := q.acquire(context.Background(), 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.acquire(ctx, 2)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, queuedRuns(q))
+
+	release()
+	release, err = q.acquire(context.Background(), 2)
+	require.NoError(t, err)
+	release()
+
+	_, err = priorityIndex("urgent")
+	require.Error(t, err)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/gptscript"
 	"github.com/gptscript-ai/gptscript/pkg/input"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
 	"github.com/gptscript-ai/gptscript/pkg/parser"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
@@ -33,10 +34,18 @@ type server struct {
 	address, token string
 	client         *gptscript.GPTScript
 	events         *broadcaster.Broadcaster[event]
+	artifactsDir   string
+	// workspacesDir has the workspaces of the chats of the UI, and uiTool is the tool the UI runs by default.
+	workspacesDir string
+	uiTool        string
 
+	storage          Storage
+	runs             *runQueue
 	lock             sync.RWMutex
 	waitingToConfirm map[string]chan runner.AuthorizerResponse
 	waitingToPrompt  map[string]chan map[string]string
+	// streams has the streams of the runs that are running or finished recently, by ID.
+	streams map[string]*runStream
 }
 
 func (s *server) addRoutes(mux *http.ServeMux) {
@@ -50,16 +59,39 @@ func (s *server) addRoutes(mux *http.ServeMux) {
 	// Listing models supports listing OpenAI models (GET) or listing models from providers (POST).
 	mux.HandleFunc("POST /list-models", s.listModels)
 	mux.HandleFunc("GET /list-models", s.listModels)
+	mux.HandleFunc("POST /embeddings", s.embeddings)
 
 	mux.HandleFunc("POST /run", s.execHandler)
 	mux.HandleFunc("POST /evaluate", s.execHandler)
 
+	mux.HandleFunc("GET /artifacts/{run}/{name...}", s.downloadArtifact)
+
+	mux.HandleFunc("GET /runs", s.listRuns)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("GET /runs/{id}/events", s.listRunEvents)
+	mux.HandleFunc("GET /runs/{id}/stream", s.resumeRun)
+	mux.HandleFunc("POST /runs/{id}/cancel", s.cancelRun)
+	mux.HandleFunc("GET /chats/{id}", s.getChat)
+	mux.HandleFunc("DELETE /chats/{id}", s.deleteChat)
+
+	// Introspecting a program supports a file or URL in the query (GET) or any program accepted by run (POST).
+	mux.HandleFunc("GET /program/tools", s.programTools)
+	mux.HandleFunc("POST /program/tools", s.programTools)
+	mux.HandleFunc("GET /program/tools/{name}", s.programTool)
+	mux.HandleFunc("POST /program/tools/{name}", s.programTool)
+
 	mux.HandleFunc("POST /parse", s.parse)
 	mux.HandleFunc("POST /fmt", s.fmtDocument)
 
 	mux.HandleFunc("POST /confirm/{id}", s.confirm)
 	mux.HandleFunc("POST /prompt/{id}", s.prompt)
 	mux.HandleFunc("POST /prompt-response/{id}", s.promptResponse)
+
+	// The chat UI runs tools with the routes above, and uploads files to the workspaces of its chats.
+	mux.HandleFunc("GET /ui", s.serveUI)
+	mux.HandleFunc("GET /ui/{name}", s.serveUI)
+	mux.HandleFunc("POST /ui/workspaces", s.createWorkspace)
+	mux.HandleFunc("POST /ui/workspaces/{id}/files", s.uploadFile)
 }
 
 // health just provides an endpoint for checking whether the server is running and accessible.
@@ -84,13 +116,7 @@ func (s *server) listTools(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if reqObject.Content != "" {
-			prg, err = loader.ProgramFromSource(r.Context(), reqObject.Content, reqObject.SubTool, loader.Options{Cache: s.client.Cache})
-		} else if reqObject.File != "" {
-			prg, err = loader.Program(r.Context(), reqObject.File, reqObject.SubTool, loader.Options{Cache: s.client.Cache})
-		} else {
-			prg, err = loader.ProgramFromSource(r.Context(), reqObject.ToolDefs.String(), reqObject.SubTool, loader.Options{Cache: s.client.Cache})
-		}
+		prg, err = s.loadProgram(r.Context(), reqObject)
 		if err != nil {
 			writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to load program: %w", err))
 			return
@@ -117,6 +143,16 @@ func (s *server) listTools(w http.ResponseWriter, r *http.Request) {
 	writeResponse(logger, w, map[string]any{"stdout": strings.Join(lines, "\n---\n")})
 }
 
+// loadProgram loads the program of the content, file, or tool definitions of the request.
+func (s *server) loadProgram(ctx context.Context, reqObject *toolOrFileRequest) (types.Program, error) {
+	if reqObject.Content != "" {
+		return loader.ProgramFromSource(ctx, reqObject.Content, reqObject.SubTool, loader.Options{Cache: s.client.Cache})
+	} else if reqObject.File != "" {
+		return loader.Program(ctx, reqObject.File, reqObject.SubTool, loader.Options{Cache: s.client.Cache})
+	}
+	return loader.ProgramFromSource(ctx, reqObject.ToolDefs.String(), reqObject.SubTool, loader.Options{Cache: s.client.Cache})
+}
+
 // listModels will return the output of `gptscript --list-models`
 func (s *server) listModels(w http.ResponseWriter, r *http.Request) {
 	logger := gcontext.GetLogger(r.Context())
@@ -140,6 +176,28 @@ func (s *server) listModels(w http.ResponseWriter, r *http.Request) {
 	writeResponse(logger, w, map[string]any{"stdout": strings.Join(out, "\n")})
 }
 
+// embeddings returns the embeddings of the inputs of the request, in the same order.
+func (s *server) embeddings(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	reqObject := new(embeddingsRequest)
+	if err := json.NewDecoder(r.Body).Decode(reqObject); err != nil {
+		writeError(logger, w, http.StatusBadRequest, fmt.Errorf("failed to decode request body: %w", err))
+		return
+	}
+
+	ctx := r.Context()
+	if reqObject.DisableCache {
+		ctx = cache.WithNoCache(ctx)
+	}
+	out, err := s.client.Embeddings(ctx, reqObject.Model, reqObject.Input)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to compute embeddings: %w", err))
+		return
+	}
+
+	writeResponse(logger, w, map[string]any{"stdout": out})
+}
+
 // execHandler is a general handler for executing tools with gptscript. This is mainly responsible for parsing the request body.
 // Then the options and tool are passed to the process function.
 func (s *server) execHandler(w http.ResponseWriter, r *http.Request) {
@@ -156,11 +214,46 @@ func (s *server) execHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := gserver.ContextWithNewRunID(r.Context())
+	priority, err := priorityIndex(reqObject.Priority)
+	if err != nil {
+		writeError(logger, w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The run goes on when the client disconnects, so that the client can resume the stream of its events, and is
+	// canceled when no client follows it for the reconnect window, or by POST /runs/{id}/cancel.
+	ctx := gcontext.WithLabels(gserver.ContextWithNewRunID(context.WithoutCancel(r.Context())), reqObject.Labels)
 	runID := gserver.RunIDFromContext(ctx)
-	ctx, cancel := context.WithTimeout(ctx, toolRunTimeout)
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	stream := s.startStream(runID, cancel)
+	defer s.endStream(runID, stream)
+	stream.watch(r.Context())
+
+	release, err := s.runs.acquire(ctx, priority)
+	if err != nil {
+		writeError(logger, w, http.StatusServiceUnavailable, fmt.Errorf("run was not started: %w", err))
+		return
+	}
+	defer release()
+
+	// The time a run waits in the queue doesn't count against its timeout.
+	ctx, cancelTimeout := context.WithTimeout(ctx, toolRunTimeout)
+	defer cancelTimeout()
+
+	// A chat that is continued by ID gets its state from the storage, so that any replica of the server can continue it.
+	if reqObject.ChatID != "" && reqObject.ChatState == "" {
+		state, ok, err := s.storage.GetChat(ctx, reqObject.ChatID)
+		if err != nil {
+			writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to get state of chat %s: %w", reqObject.ChatID, err))
+			return
+		}
+		if ok {
+			reqObject.ChatState = state
+		}
+	}
+
 	// Ensure chat state is not empty.
 	if reqObject.ChatState == "" {
 		reqObject.ChatState = "null"
@@ -202,14 +295,30 @@ func (s *server) execHandler(w http.ResponseWriter, r *http.Request) {
 			// Set the monitor factory so that we can get events from the server.
 			MonitorFactory:      NewSessionFactory(s.events),
 			CredentialOverrides: reqObject.CredentialOverrides,
+			WorkDir:             reqObject.WorkingDir,
 		},
 	}
 
 	if reqObject.Confirm {
 		opts.Runner.Authorizer = s.authorize
 	}
+	if reqObject.ConfirmCredentials {
+		opts.Runner.CredentialAuthorizer = s.authorizeCredential
+	}
+	opts.Runner.SaveEnvVars = reqObject.SaveEnvVars
+
+	if overrides := reqObject.ToolOverrides; overrides != nil {
+		load := programLoader
+		programLoader = func(ctx context.Context, toolDef, subTool string, opts ...loader.Options) (types.Program, error) {
+			prg, err := load(ctx, toolDef, subTool, opts...)
+			if err != nil {
+				return prg, err
+			}
+			return prg.SetOverrides(*overrides), nil
+		}
+	}
 
-	s.execAndStream(ctx, programLoader, logger, w, opts, reqObject.ChatState, reqObject.Input, reqObject.SubTool, def)
+	s.execAndStream(ctx, r.Context(), stream, programLoader, logger, w, opts, reqObject.ChatID, reqObject.ChatState, reqObject.Input, reqObject.SubTool, def)
 }
 
 // parse will parse the file and return the corresponding Document.
@@ -223,23 +332,23 @@ func (s *server) parse(w http.ResponseWriter, r *http.Request) {
 
 	logger.Debugf("parsing file: file=%s, content=%s", reqObject.File, reqObject.Content)
 
-	var (
-		out parser.Document
-		err error
-	)
-
-	if reqObject.Content != "" {
-		out, err = parser.Parse(strings.NewReader(reqObject.Content), reqObject.Options)
-	} else {
-		content, loadErr := input.FromLocation(reqObject.File)
-		if loadErr != nil {
-			logger.Errorf(loadErr.Error())
-			writeError(logger, w, http.StatusInternalServerError, loadErr)
+	content := reqObject.Content
+	if content == "" {
+		var err error
+		content, err = input.FromLocation(reqObject.File)
+		if err != nil {
+			logger.Errorf(err.Error())
+			writeError(logger, w, http.StatusInternalServerError, err)
 			return
 		}
+	}
 
-		out, err = parser.Parse(strings.NewReader(content), reqObject.Options)
+	if reqObject.Stream {
+		streamParse(logger, w, content, reqObject.Options)
+		return
 	}
+
+	out, err := parser.Parse(strings.NewReader(content), reqObject.Options)
 	if err != nil {
 		logger.Errorf("failed to parse file: %v", err)
 		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to parse file: %w", err))
@@ -249,6 +358,26 @@ func (s *server) parse(w http.ResponseWriter, r *http.Request) {
 	writeResponse(logger, w, map[string]any{"stdout": map[string]any{"nodes": out.Nodes}})
 }
 
+// streamParse sends the nodes of the file as server sent events while it is parsed. A parse error is sent as the last
+// event before the DONE event, because the response has started by then.
+func streamParse(logger mvl.Logger, w http.ResponseWriter, content string, opts parser.Options) {
+	setStreamingHeaders(w)
+
+	err := parser.ParseStream(strings.NewReader(content), func(node parser.Node) error {
+		writeServerSentEvent(logger, w, 0, map[string]any{"node": node})
+		return nil
+	}, opts)
+	if err != nil {
+		logger.Errorf("failed to parse file: %v", err)
+		writeServerSentEvent(logger, w, 0, map[string]any{"stderr": fmt.Sprintf("failed to parse file: %v", err)})
+	}
+
+	_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // fmtDocument will produce a string representation of the document.
 func (s *server) fmtDocument(w http.ResponseWriter, r *http.Request) {
 	logger := gcontext.GetLogger(r.Context())
@@ -16,7 +16,10 @@ import (
 
 type loaderFunc func(context.Context, string, string, ...loader.Options) (types.Program, error)
 
-func (s *server) execAndStream(ctx context.Context, programLoader loaderFunc, logger mvl.Logger, w http.ResponseWriter, opts gptscript.Options, chatState, input, subTool string, toolDef fmt.Stringer) {
+// execAndStream runs the program and streams its events to the client, and to the clients that resume the stream of
+// the run. The run is not canceled when the client disconnects, only when the stream cancels it, so clientCtx is the
+// context of the request of the client.
+func (s *server) execAndStream(ctx, clientCtx context.Context, stream *runStream, programLoader loaderFunc, logger mvl.Logger, w http.ResponseWriter, opts gptscript.Options, chatID, chatState, input, subTool string, toolDef fmt.Stringer) {
 	g, err := gptscript.New(ctx, s.gptscriptOpts, opts)
 	if err != nil {
 		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to initialize gptscript: %w", err))
@@ -31,58 +34,88 @@ func (s *server) execAndStream(ctx context.Context, programLoader loaderFunc, lo
 	}
 
 	errChan := make(chan error)
-	programOutput := make(chan runner.ChatResponse)
+	programOutput := make(chan runOutput)
 	events := s.events.Subscribe()
 	defer events.Close()
 
 	go func() {
 		run, err := g.Chat(ctx, chatState, prg, opts.Env, input)
+		var artifacts []types.Artifact
+		if err == nil {
+			artifacts, err = s.saveArtifacts(g, prg, gserver.RunIDFromContext(ctx))
+		}
 		if err != nil {
 			errChan <- err
 		} else {
-			programOutput <- run
+			programOutput <- runOutput{
+				response:  run,
+				artifacts: artifacts,
+			}
 		}
 		close(errChan)
 		close(programOutput)
 	}()
 
-	processEventStreamOutput(ctx, logger, w, gserver.RunIDFromContext(ctx), events.C, programOutput, errChan)
+	rec := &runRecorder{
+		ctx:     context.WithoutCancel(ctx),
+		logger:  logger,
+		storage: s.storage,
+		chatID:  chatID,
+	}
+
+	setStreamingHeaders(w)
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		stream.follow(clientCtx, logger, w, 0)
+	}()
+	processEventStreamOutput(ctx, logger, stream, rec, gserver.RunIDFromContext(ctx), events.C, programOutput, errChan)
+	<-followed
 }
 
-// processEventStreamOutput will stream the events of the tool to the response as server sent events.
-// If an error occurs, then an event with the error will also be sent.
-func processEventStreamOutput(ctx context.Context, logger mvl.Logger, w http.ResponseWriter, id string, events <-chan event, output <-chan runner.ChatResponse, errChan chan error) {
+// runOutput is the result of a run and the artifacts collected from its workspace.
+type runOutput struct {
+	response  runner.ChatResponse
+	artifacts []types.Artifact
+}
+
+// processEventStreamOutput will add the events of the tool to the stream of the run.
+// If an error occurs, then an event with the error will also be added.
+func processEventStreamOutput(ctx context.Context, logger mvl.Logger, stream *runStream, rec *runRecorder, id string, events <-chan event, output <-chan runOutput, errChan chan error) {
 	run := newRun(id)
-	setStreamingHeaders(w)
+	rec.run = run
+	rec.saveRun()
+	// Now that we have added all events, the stream sends the DONE event.
+	defer stream.close()
 
-	streamEvents(ctx, logger, w, run, events)
+	streamEvents(ctx, logger, stream, rec, run, events)
 
-	var out runner.ChatResponse
 	select {
 	case <-ctx.Done():
-	case out = <-output:
-		run.processStdout(out)
+	case out := <-output:
+		run.processStdout(out.response)
 
-		writeServerSentEvent(logger, w, map[string]any{
-			"stdout": out,
-		})
-	case err := <-errChan:
-		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to run file: %w", err))
-	}
-
-	// Now that we have received all events, send the DONE event.
-	_, err := w.Write([]byte("data: [DONE]\n\n"))
-	if err == nil {
-		if f, ok := w.(http.Flusher); ok {
-			f.Flush()
+		result := map[string]any{
+			"stdout": out.response,
 		}
+		if len(out.artifacts) > 0 {
+			result["artifacts"] = out.artifacts
+		}
+		stream.add(logger, result, false)
+		rec.addEvent(result)
+		rec.saveRun()
+		rec.saveChat(out.response)
+	case err := <-errChan:
+		run.State = Error
+		run.Error = err.Error()
+		rec.saveRun()
+		logger.Debugf("Writing error event: %v", err)
+		stream.add(logger, map[string]any{"stderr": fmt.Sprintf("failed to run file: %v", err)}, false)
 	}
-
-	logger.Debugf("wrote DONE event")
 }
 
-// streamEvents will stream the events of the tool to the response as server sent events.
-func streamEvents(ctx context.Context, logger mvl.Logger, w http.ResponseWriter, run *runInfo, events <-chan event) {
+// streamEvents will add the events of the tool to the stream of the run.
+func streamEvents(ctx context.Context, logger mvl.Logger, stream *runStream, rec *runRecorder, run *runInfo, events <-chan event) {
 	logger.Debugf("receiving events")
 	for {
 		select {
@@ -104,7 +137,17 @@ func streamEvents(ctx context.Context, logger mvl.Logger, w http.ResponseWriter,
 				return
 			}
 
-			writeServerSentEvent(logger, w, run.process(e))
+			processed := run.process(e)
+			// The progress of calls is only streamed, because the finish event of the call has the whole output.
+			progress := e.Type == runner.EventTypeCallProgress || e.Type == runner.EventTypeProgress
+			// The sequence number is set before the event is saved, so that a stream can be resumed from the storage.
+			stream.add(logger, processed, progress)
+			if !progress {
+				rec.addEvent(processed)
+			}
+			if e.Type == runner.EventTypeRunStart || e.Type == runner.EventTypeRunFinish {
+				rec.saveRun()
+			}
 
 			if e.Type == runner.EventTypeRunFinish {
 				logger.Debugf("finished receiving events")
@@ -114,6 +157,57 @@ func streamEvents(ctx context.Context, logger mvl.Logger, w http.ResponseWriter,
 	}
 }
 
+// runRecorder saves a run, the events that are sent for it, and the state of its chat to the storage of the server.
+// Failing to save them is logged instead of failing the run.
+type runRecorder struct {
+	ctx     context.Context
+	logger  mvl.Logger
+	storage Storage
+	run     *runInfo
+	chatID  string
+}
+
+func (r *runRecorder) saveRun() {
+	data, err := json.Marshal(r.run)
+	if err == nil {
+		err = r.storage.SaveRun(r.ctx, r.run.ID, data)
+	}
+	if err != nil {
+		r.logger.Warnf("failed to save run %s: %v", r.run.ID, err)
+	}
+}
+
+func (r *runRecorder) addEvent(e any) {
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = r.storage.AddEvent(r.ctx, r.run.ID, data)
+	}
+	if err != nil {
+		r.logger.Warnf("failed to save event of run %s: %v", r.run.ID, err)
+	}
+}
+
+// saveChat saves the state of the chat for the next request with its ID, or deletes it when the chat is done.
+func (r *runRecorder) saveChat(resp runner.ChatResponse) {
+	if r.chatID == "" {
+		return
+	}
+
+	var err error
+	if resp.Done {
+		err = r.storage.DeleteChat(r.ctx, r.chatID)
+	} else {
+		var state []byte
+		state, err = json.Marshal(resp.State)
+		if err == nil {
+			err = r.storage.SaveChat(r.ctx, r.chatID, string(state))
+		}
+	}
+	if err != nil {
+		r.logger.Warnf("failed to save state of chat %s: %v", r.chatID, err)
+	}
+}
+
 func writeResponse(logger mvl.Logger, w http.ResponseWriter, v any) {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -147,14 +241,20 @@ func writeError(logger mvl.Logger, w http.ResponseWriter, code int, err error) {
 	}
 }
 
-func writeServerSentEvent(logger mvl.Logger, w http.ResponseWriter, event any) {
+// writeServerSentEvent writes the event, with the sequence number as its ID if it is not 0, so that clients send it as
+// the Last-Event-ID header when they reconnect.
+func writeServerSentEvent(logger mvl.Logger, w http.ResponseWriter, seq int, event any) {
 	ev, err := json.Marshal(event)
 	if err != nil {
 		logger.Warnf("failed to marshal event: %v", err)
 		return
 	}
 
-	_, err = w.Write([]byte(fmt.Sprintf("data: %s\n\n", ev)))
+	var id string
+	if seq != 0 {
+		id = fmt.Sprintf("id: %d\n", seq)
+	}
+	_, err = w.Write([]byte(fmt.Sprintf("%sdata: %s\n\n", id, ev)))
 	if err == nil {
 		if f, ok := w.(http.Flusher); ok {
 			f.Flush()
@@ -164,6 +264,18 @@ func writeServerSentEvent(logger mvl.Logger, w http.ResponseWriter, event any) {
 	logger.Debugf("wrote event: %v", string(ev))
 }
 
+// writeDone writes the event that ends a stream of server sent events.
+func writeDone(logger mvl.Logger, w http.ResponseWriter) {
+	_, err := w.Write([]byte("data: [DONE]\n\n"))
+	if err == nil {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	logger.Debugf("wrote DONE event")
+}
+
 func setStreamingHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log/slog"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,20 +27,34 @@ import (
 type Options struct {
 	gptscript.Options
 
+	// ListenAddress is a TCP address, or unix:// followed by the path of a socket.
 	ListenAddress string
 	Debug         bool
+	// StorageURL is a postgres:// URL of the database that stores the runs and chats of the server, so that replicas of
+	// the server share them. They are kept in memory by default.
+	StorageURL string
+	// EventRetention is how long the events of runs are kept in the database of StorageURL, 0 to keep them forever.
+	EventRetention time.Duration
+	// MaxRuns is the maximum number of runs at once, 0 for no limit. Runs over it are queued by priority.
+	MaxRuns int
+	// KeepAlive keeps the server running when stdin is closed, for servers that are not run by an SDK.
+	KeepAlive bool
+	// UITool is the tool that the chat UI at /ui runs when no file is given in its URL.
+	UITool string
 }
 
 func Start(ctx context.Context, opts Options) error {
 	sigCtx, cancel := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGKILL)
 	defer cancel()
-	go func() {
-		// This is a hack. This server will be run as a forked process in the SDKs. The SDKs will hold stdin open for as long
-		// as it wants the server running. When stdin is closed (or the parent process dies), then this will unblock and the
-		// server will be shutdown.
-		_, _ = io.ReadAll(os.Stdin)
-		cancel()
-	}()
+	if !opts.KeepAlive {
+		go func() {
+			// This is a hack. This server will be run as a forked process in the SDKs. The SDKs will hold stdin open for as long
+			// as it wants the server running. When stdin is closed (or the parent process dies), then this will unblock and the
+			// server will be shutdown.
+			_, _ = io.ReadAll(os.Stdin)
+			cancel()
+		}()
+	}
 
 	if opts.Debug {
 		mvl.SetDebug()
@@ -58,19 +74,45 @@ func Start(ctx context.Context, opts Options) error {
 		return err
 	}
 
-	listener, err := net.Listen("tcp", opts.ListenAddress)
+	storage, err := OpenStorage(ctx, opts.StorageURL, opts.EventRetention)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	listener, err := listen(opts.ListenAddress)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", opts.ListenAddress, err)
 	}
 
+	// The directories are only created once the server is listening, so that they don't outlive a server that fails
+	// to start.
+	artifactsDir, err := os.MkdirTemp("", "gptscript-artifacts-*")
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	workspacesDir, err := os.MkdirTemp("", "gptscript-ui-workspaces-*")
+	if err != nil {
+		_ = os.RemoveAll(artifactsDir)
+		_ = listener.Close()
+		return err
+	}
+
 	s := &server{
 		gptscriptOpts:    opts.Options,
 		address:          listener.Addr().String(),
 		token:            token,
 		client:           g,
 		events:           events,
+		artifactsDir:     artifactsDir,
+		workspacesDir:    workspacesDir,
+		uiTool:           opts.UITool,
+		storage:          storage,
+		runs:             newRunQueue(opts.MaxRuns),
 		waitingToConfirm: make(map[string]chan runner.AuthorizerResponse),
 		waitingToPrompt:  make(map[string]chan map[string]string),
+		streams:          make(map[string]*runStream),
 	}
 	defer s.Close()
 
@@ -86,15 +128,15 @@ func Start(ctx context.Context, opts Options) error {
 		),
 	}
 
-	slog.Info("Starting server", "addr", s.address)
+	log.Infof("Starting server on %s", s.address)
 
 	context.AfterFunc(sigCtx, func() {
 		ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 		defer cancel()
 
-		slog.Info("Shutting down server")
+		log.Infof("Shutting down server")
 		_ = server.Shutdown(ctx)
-		slog.Info("Server stopped")
+		log.Infof("Server stopped")
 	})
 
 	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -104,7 +146,46 @@ func Start(ctx context.Context, opts Options) error {
 	return nil
 }
 
+// listen listens on a TCP address, or on a unix socket for an address that starts with unix://. Only the user can
+// connect to the socket, and a socket left behind by a server that is no longer running is replaced.
+func listen(address string) (net.Listener, error) {
+	socket, ok := strings.CutPrefix(address, "unix://")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if conn, err := net.Dial("unix", socket); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("a server is already listening on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
 func (s *server) Close() {
 	s.client.Close(true)
 	s.events.Close()
+	if err := s.storage.Close(); err != nil {
+		log.Errorf("failed to close storage: %v", err)
+	}
+	if err := os.RemoveAll(s.artifactsDir); err != nil {
+		log.Errorf("failed to delete artifacts directory %s: %v", s.artifactsDir, err)
+	}
+	if err := os.RemoveAll(s.workspacesDir); err != nil {
+		log.Errorf("failed to delete workspaces directory %s: %v", s.workspacesDir, err)
+	}
 }
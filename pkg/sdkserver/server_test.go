@@ -0,0 +1,36 @@
+package sdkserver
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "run", "gptscriptd.sock")
+
+	listener, err := listen("unix://" + socket)
+	require.NoError(t, err)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A second server can't take over the socket of a running one.
+	_, err = listen("unix://" + socket)
+	require.ErrorContains(t, err, "already listening")
+	require.NoError(t, listener.Close())
+
+	// The socket left behind by a server that didn't close it is replaced.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listener, err = listen("unix://" + socket)
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+}
@@ -0,0 +1,322 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Storage keeps the history of the runs of the server, the events of each run, and the state of chats that are
+// continued by ID. The server keeps them in memory by default, and in Postgres so that the replicas of a server share
+// them. Runs and events are stored as the JSON that is sent to the clients.
+type Storage interface {
+	// SaveRun adds or replaces a run.
+	SaveRun(ctx context.Context, id string, run json.RawMessage) error
+	// GetRun returns nil if the run doesn't exist.
+	GetRun(ctx context.Context, id string) (json.RawMessage, error)
+	// ListRuns returns the most recent runs first.
+	ListRuns(ctx context.Context, limit int) ([]json.RawMessage, error)
+	// ListRunsBetween returns the runs created at or after from and before to, the oldest first.
+	ListRunsBetween(ctx context.Context, from, to time.Time) ([]json.RawMessage, error)
+	AddEvent(ctx context.Context, runID string, event json.RawMessage) error
+	// ListEvents returns the events of a run in the order they were added.
+	ListEvents(ctx context.Context, runID string) ([]json.RawMessage, error)
+	SaveChat(ctx context.Context, id, state string) error
+	GetChat(ctx context.Context, id string) (string, bool, error)
+	DeleteChat(ctx context.Context, id string) error
+	Close() error
+}
+
+// OpenStorage returns the storage of a postgres:// or postgresql:// URL, or storage in memory for an empty URL. The events
+// of runs are kept in Postgres for the event retention, or forever if it is 0.
+func OpenStorage(ctx context.Context, storageURL string, eventRetention time.Duration) (Storage, error) {
+	switch {
+	case storageURL == "":
+		return newMemoryStorage(defaultMemoryRuns), nil
+	case strings.HasPrefix(storageURL, "postgres://"), strings.HasPrefix(storageURL, "postgresql://"):
+		return newPostgresStorage(ctx, storageURL, eventRetention)
+	default:
+		return nil, fmt.Errorf("unsupported storage URL %q, must be a postgres:// URL", storageURL)
+	}
+}
+
+// defaultMemoryRuns is the number of runs that are kept in memory, with their events.
+const defaultMemoryRuns = 100
+
+type memoryStorage struct {
+	lock    sync.RWMutex
+	maxRuns int
+	order   []string
+	created map[string]time.Time
+	runs    map[string]json.RawMessage
+	events  map[string][]json.RawMessage
+	chats   map[string]string
+}
+
+func newMemoryStorage(maxRuns int) *memoryStorage {
+	return &memoryStorage{
+		maxRuns: maxRuns,
+		created: map[string]time.Time{},
+		runs:    map[string]json.RawMessage{},
+		events:  map[string][]json.RawMessage{},
+		chats:   map[string]string{},
+	}
+}
+
+func (m *memoryStorage) SaveRun(_ context.Context, id string, run json.RawMessage) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.runs[id]; !ok {
+		m.order = append(m.order, id)
+		m.created[id] = time.Now()
+	}
+	m.runs[id] = run
+
+	for len(m.order) > m.maxRuns {
+		delete(m.runs, m.order[0])
+		delete(m.created, m.order[0])
+		delete(m.events, m.order[0])
+		m.order = m.order[1:]
+	}
+	return nil
+}
+
+func (m *memoryStorage) GetRun(_ context.Context, id string) (json.RawMessage, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.runs[id], nil
+}
+
+func (m *memoryStorage) ListRuns(_ context.Context, limit int) ([]json.RawMessage, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	runs := make([]json.RawMessage, 0, min(limit, len(m.order)))
+	for i := len(m.order) - 1; i >= 0 && len(runs) < limit; i-- {
+		runs = append(runs, m.runs[m.order[i]])
+	}
+	return runs, nil
+}
+
+func (m *memoryStorage) ListRunsBetween(_ context.Context, from, to time.Time) ([]json.RawMessage, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var runs []json.RawMessage
+	for _, id := range m.order {
+		if created := m.created[id]; !created.Before(from) && created.Before(to) {
+			runs = append(runs, m.runs[id])
+		}
+	}
+	return runs, nil
+}
+
+func (m *memoryStorage) AddEvent(_ context.Context, runID string, event json.RawMessage) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// The events of runs that are no longer kept are dropped.
+	if _, ok := m.runs[runID]; ok {
+		m.events[runID] = append(m.events[runID], event)
+	}
+	return nil
+}
+
+func (m *memoryStorage) ListEvents(_ context.Context, runID string) ([]json.RawMessage, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]json.RawMessage{}, m.events[runID]...), nil
+}
+
+func (m *memoryStorage) SaveChat(_ context.Context, id, state string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.chats[id] = state
+	return nil
+}
+
+func (m *memoryStorage) GetChat(_ context.Context, id string) (string, bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	state, ok := m.chats[id]
+	return state, ok, nil
+}
+
+func (m *memoryStorage) DeleteChat(_ context.Context, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.chats, id)
+	return nil
+}
+
+func (m *memoryStorage) Close() error {
+	return nil
+}
+
+// postgresSchema creates the tables if they don't exist. The advisory lock keeps replicas that start at the same time
+// from creating them concurrently, which fails.
+const postgresSchema = `DO $$
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext('gptscript_sdkserver_schema'));
+	CREATE TABLE IF NOT EXISTS gptscript_runs (
+		id TEXT PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		data TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS gptscript_runs_created_at ON gptscript_runs (created_at);
+	CREATE TABLE IF NOT EXISTS gptscript_run_events (
+		id BIGSERIAL PRIMARY KEY,
+		run_id TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	ALTER TABLE gptscript_run_events ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+	CREATE INDEX IF NOT EXISTS gptscript_run_events_run_id ON gptscript_run_events (run_id, id);
+	CREATE INDEX IF NOT EXISTS gptscript_run_events_created_at ON gptscript_run_events (created_at);
+	CREATE TABLE IF NOT EXISTS gptscript_chats (
+		id TEXT PRIMARY KEY,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		state TEXT NOT NULL
+	);
+END
+$$`
+
+// pruneInterval is how often the events that are older than the retention are deleted.
+const pruneInterval = time.Hour
+
+type postgresStorage struct {
+	pool   *pgxpool.Pool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newPostgresStorage connects to the database and creates the tables. The events of runs that are older than the
+// retention are deleted every hour, unless the retention is 0. The runs are kept, because usage reports need them and
+// they are much smaller than their events.
+func newPostgresStorage(ctx context.Context, storageURL string, eventRetention time.Duration) (*postgresStorage, error) {
+	pool, err := pgxpool.New(ctx, storageURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, postgresSchema); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	pruneCtx, cancel := context.WithCancel(context.Background())
+	p := &postgresStorage{
+		pool:   pool,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.prune(pruneCtx, eventRetention)
+	return p, nil
+}
+
+func (p *postgresStorage) prune(ctx context.Context, retention time.Duration) {
+	defer close(p.done)
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.pruneEvents(ctx, time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+			log.Errorf("failed to delete old run events: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *postgresStorage) pruneEvents(ctx context.Context, before time.Time) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM gptscript_run_events WHERE created_at < $1`, before)
+	return err
+}
+
+func (p *postgresStorage) SaveRun(ctx context.Context, id string, run json.RawMessage) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO gptscript_runs (id, data) VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`, id, string(run))
+	return err
+}
+
+func (p *postgresStorage) GetRun(ctx context.Context, id string) (json.RawMessage, error) {
+	var data string
+	err := p.pool.QueryRow(ctx, `SELECT data FROM gptscript_runs WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+func (p *postgresStorage) ListRuns(ctx context.Context, limit int) ([]json.RawMessage, error) {
+	return p.list(ctx, `SELECT data FROM gptscript_runs ORDER BY created_at DESC LIMIT $1`, limit)
+}
+
+func (p *postgresStorage) ListRunsBetween(ctx context.Context, from, to time.Time) ([]json.RawMessage, error) {
+	return p.list(ctx, `SELECT data FROM gptscript_runs WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at`, from, to)
+}
+
+func (p *postgresStorage) AddEvent(ctx context.Context, runID string, event json.RawMessage) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO gptscript_run_events (run_id, data) VALUES ($1, $2)`, runID, string(event))
+	return err
+}
+
+func (p *postgresStorage) ListEvents(ctx context.Context, runID string) ([]json.RawMessage, error) {
+	return p.list(ctx, `SELECT data FROM gptscript_run_events WHERE run_id = $1 ORDER BY id`, runID)
+}
+
+func (p *postgresStorage) SaveChat(ctx context.Context, id, state string) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO gptscript_chats (id, state) VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state, updated_at = now()`, id, state)
+	return err
+}
+
+func (p *postgresStorage) GetChat(ctx context.Context, id string) (string, bool, error) {
+	var state string
+	err := p.pool.QueryRow(ctx, `SELECT state FROM gptscript_chats WHERE id = $1`, id).Scan(&state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return state, true, nil
+}
+
+func (p *postgresStorage) DeleteChat(ctx context.Context, id string) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM gptscript_chats WHERE id = $1`, id)
+	return err
+}
+
+func (p *postgresStorage) Close() error {
+	p.cancel()
+	<-p.done
+	p.pool.Close()
+	return nil
+}
+
+// list returns the first column of the rows of a query, which is the JSON of a run or event.
+func (p *postgresStorage) list(ctx context.Context, query string, args ...any) ([]json.RawMessage, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (json.RawMessage, error) {
+		var data string
+		err := row.Scan(&data)
+		return json.RawMessage(data), err
+	})
+}
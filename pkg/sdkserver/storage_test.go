@@ -0,0 +1,52 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(2)
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, m.SaveRun(ctx, id, json.RawMessage(`{"id":"`+id+`"}`)))
+		require.NoError(t, m.AddEvent(ctx, id, json.RawMessage(`{"run":"`+id+`"}`)))
+	}
+	require.NoError(t, m.SaveRun(ctx, "2", json.RawMessage(`{"id":"2","state":"finished"}`)))
+
+	// The oldest run is dropped with its events.
+	runs, err := m.ListRuns(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"id":"3"}`), json.RawMessage(`{"id":"2","state":"finished"}`)}, runs)
+	run, err := m.GetRun(ctx, "1")
+	require.NoError(t, err)
+	require.Nil(t, run)
+	events, err := m.ListEvents(ctx, "1")
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	runs, err = m.ListRuns(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+
+	require.NoError(t, m.SaveChat(ctx, "chat", `{"continuation":{}}`))
+	state, ok, err := m.GetChat(ctx, "chat")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"continuation":{}}`, state)
+	require.NoError(t, m.DeleteChat(ctx, "chat"))
+	_, ok, err = m.GetChat(ctx, "chat")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = OpenStorage(ctx, "mysql://localhost/gptscript", 0)
+	require.ErrorContains(t, err, "unsupported storage URL")
+
+	_, err = OpenStorage(ctx, "postgres://gptscript@127.0.0.1:1/gptscript?sslmode=disable", time.Hour)
+	require.ErrorContains(t, err, "failed to create tables")
+}
@@ -0,0 +1,251 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+)
+
+const (
+	// streamReconnectWindow is how long a run goes on after its last client disconnects, for a client to resume its
+	// stream.
+	streamReconnectWindow = 30 * time.Second
+	// streamRetention is how long the events of a run are kept in memory after it finishes, after which a resumed
+	// stream replays the events in the storage of the server.
+	streamRetention = time.Minute
+)
+
+// runStream has the events of a run, numbered from 1 in the order they are sent, so that a client that is
+// disconnected can resume the stream after the last event it got, and several clients can follow the same run.
+type runStream struct {
+	lock sync.Mutex
+	// events are kept in the order of their sequence numbers, which has gaps where progress events were dropped.
+	events   []streamEvent
+	seq      int
+	done     bool
+	changed  chan struct{}
+	watchers int
+	idle     *time.Timer
+	// cancel cancels the run when no client has followed it for reconnectWindow.
+	cancel          context.CancelFunc
+	reconnectWindow time.Duration
+}
+
+func newRunStream(cancel context.CancelFunc) *runStream {
+	return &runStream{
+		changed:         make(chan struct{}),
+		cancel:          cancel,
+		reconnectWindow: streamReconnectWindow,
+	}
+}
+
+type streamEvent struct {
+	seq  int
+	data json.RawMessage
+	// progress is set for the progress events of calls, which have the whole state of the call, so that they are
+	// dropped once a later event is added instead of being kept until the run is done.
+	progress bool
+}
+
+// add sets the sequence number of the event, under seq, and sends it to the clients that follow the run. A progress
+// event is only kept until the next event is added, so a client that is behind skips it.
+func (r *runStream) add(logger mvl.Logger, event map[string]any, progress bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.seq++
+	event["seq"] = r.seq
+	data, err := json.Marshal(event)
+	if err != nil {
+		logger.Warnf("failed to marshal event: %v", err)
+		// The number is still used, so that clients don't wait for it.
+		data, _ = json.Marshal(map[string]any{"seq": r.seq})
+	}
+	if n := len(r.events); n > 0 && r.events[n-1].progress {
+		r.events = r.events[:n-1]
+	}
+	r.events = append(r.events, streamEvent{seq: r.seq, data: data, progress: progress})
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// after returns the events after the sequence number.
+func (r *runStream) after(seq int) []streamEvent {
+	i := sort.Search(len(r.events), func(i int) bool {
+		return r.events[i].seq > seq
+	})
+	return r.events[i:]
+}
+
+// close ends the stream after the events that were added.
+func (r *runStream) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	if r.idle != nil {
+		r.idle.Stop()
+	}
+	close(r.changed)
+}
+
+// watch counts a client as following the run until ctx is done. When the last client is gone, the run is canceled
+// unless a client follows it again within the reconnect window.
+func (r *runStream) watch(ctx context.Context) {
+	r.lock.Lock()
+	r.watchers++
+	if r.idle != nil {
+		r.idle.Stop()
+		r.idle = nil
+	}
+	r.lock.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		if r.watchers--; r.watchers == 0 && !r.done {
+			r.idle = time.AfterFunc(r.reconnectWindow, r.cancel)
+		}
+	})
+}
+
+// follow writes the events after the sequence number after as server sent events, until the stream ends or ctx is
+// done.
+func (r *runStream) follow(ctx context.Context, logger mvl.Logger, w http.ResponseWriter, after int) {
+	for {
+		r.lock.Lock()
+		events, done, changed := r.after(after), r.done, r.changed
+		r.lock.Unlock()
+
+		for _, event := range events {
+			writeServerSentEvent(logger, w, event.seq, event.data)
+			after = event.seq
+		}
+
+		if done {
+			writeDone(logger, w)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// startStream adds the stream of a run, which can be resumed by ID.
+func (s *server) startStream(id string, cancel context.CancelFunc) *runStream {
+	stream := newRunStream(cancel)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.streams[id] = stream
+	return stream
+}
+
+// endStream ends the stream of a run, and forgets it after streamRetention.
+func (s *server) endStream(id string, stream *runStream) {
+	stream.close()
+	time.AfterFunc(streamRetention, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.streams, id)
+	})
+}
+
+// resumeRun streams the events of a run after the sequence number in the Last-Event-ID header, or all of them without
+// it. A run that is running or finished recently is followed until it is done. The events of other runs are replayed
+// from the storage of the server, without the progress events, which are not stored.
+func (s *server) resumeRun(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	var after int
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		var err error
+		if after, err = strconv.Atoi(v); err != nil || after < 0 {
+			writeError(logger, w, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID %q", v))
+			return
+		}
+	}
+
+	s.lock.RLock()
+	stream := s.streams[id]
+	s.lock.RUnlock()
+
+	if stream != nil {
+		setStreamingHeaders(w)
+		stream.watch(r.Context())
+		stream.follow(r.Context(), logger, w, after)
+		return
+	}
+
+	run, err := s.storage.GetRun(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to get run %s: %w", id, err))
+		return
+	} else if run == nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("run %s not found", id))
+		return
+	}
+
+	var info struct {
+		State runState `json:"state"`
+	}
+	if err := json.Unmarshal(run, &info); err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to read run %s: %w", id, err))
+		return
+	} else if info.State == Creating || info.State == Running {
+		// The run is streamed by another replica of the server.
+		writeError(logger, w, http.StatusConflict, fmt.Errorf("run %s is not running on this server", id))
+		return
+	}
+
+	events, err := s.storage.ListEvents(r.Context(), id)
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to list events of run %s: %w", id, err))
+		return
+	}
+
+	setStreamingHeaders(w)
+	for _, event := range events {
+		var seq struct {
+			Seq int `json:"seq"`
+		}
+		if err := json.Unmarshal(event, &seq); err == nil && seq.Seq > after {
+			writeServerSentEvent(logger, w, seq.Seq, event)
+		}
+	}
+	writeDone(logger, w)
+}
+
+// cancelRun cancels a run that is running on this server, since a run is not canceled when its client disconnects.
+func (s *server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+
+	s.lock.RLock()
+	stream := s.streams[id]
+	s.lock.RUnlock()
+
+	if stream == nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("run %s is not running on this server", id))
+		return
+	}
+
+	stream.cancel()
+	writeResponse(logger, w, map[string]any{"stdout": "run " + id + " was canceled"})
+}
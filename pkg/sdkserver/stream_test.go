@@ -0,0 +1,140 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceNumbers returns the sequence numbers of the events in a stream, and whether it ended with DONE. The ID of
+// each event must be its sequence number.
+func sequenceNumbers(t *testing.T, body string) ([]int, bool) {
+	var (
+		seqs []int
+		done bool
+	)
+	for _, chunk := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		data := strings.TrimPrefix(chunk, "data: ")
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		id, data, ok := strings.Cut(strings.TrimPrefix(chunk, "id: "), "\ndata: ")
+		require.True(t, ok, chunk)
+		var event struct {
+			Seq int `json:"seq"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		require.Equal(t, strconv.Itoa(event.Seq), id)
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs, done
+}
+
+func TestResumeRun(t *testing.T) {
+	s := &server{storage: newMemoryStorage(defaultMemoryRuns), streams: map[string]*runStream{}}
+	mux := http.NewServeMux()
+	s.addRoutes(mux)
+	logger := mvl.Package()
+
+	resume := func(id, lastEventID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/runs/"+id+"/stream", nil)
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	canceled := make(chan struct{})
+	stream := s.startStream("1", func() { close(canceled) })
+	for range 3 {
+		stream.add(logger, map[string]any{"run": map[string]any{}}, false)
+	}
+
+	// A client that resumes a run that is running gets the events it missed, then the events that follow.
+	resumed := make(chan *httptest.ResponseRecorder)
+	go func() {
+		resumed <- resume("1", "1")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	stream.add(logger, map[string]any{"stdout": "done"}, false)
+	s.endStream("1", stream)
+
+	w := <-resumed
+	require.Equal(t, http.StatusOK, w.Code)
+	seqs, done := sequenceNumbers(t, w.Body.String())
+	require.Equal(t, []int{2, 3, 4}, seqs)
+	require.True(t, done)
+
+	// Progress events are dropped once a later event is added, so they are not kept until the run is done.
+	stream = s.startStream("5", func() {})
+	for _, progress := range []bool{false, true, true, false, true} {
+		stream.add(logger, map[string]any{"call": map[string]any{}}, progress)
+	}
+	s.endStream("5", stream)
+	seqs, _ = sequenceNumbers(t, resume("5", "").Body.String())
+	require.Equal(t, []int{1, 4, 5}, seqs)
+
+	// A run is canceled by a request to cancel it, since it goes on when its client disconnects.
+	r := httptest.NewRequest(http.MethodPost, "/runs/1/cancel", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	<-canceled
+	r = httptest.NewRequest(http.MethodPost, "/runs/4/cancel", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// A run that is no longer streamed is replayed from the storage.
+	require.NoError(t, s.storage.SaveRun(context.Background(), "2", json.RawMessage(`{"state":"finished"}`)))
+	for _, event := range []string{`{"run":{},"seq":1}`, `{"call":{},"seq":3}`, `{"stdout":"done","seq":4}`} {
+		require.NoError(t, s.storage.AddEvent(context.Background(), "2", json.RawMessage(event)))
+	}
+	seqs, done = sequenceNumbers(t, resume("2", "1").Body.String())
+	require.Equal(t, []int{3, 4}, seqs)
+	require.True(t, done)
+
+	require.NoError(t, s.storage.SaveRun(context.Background(), "3", json.RawMessage(`{"state":"running"}`)))
+	require.Equal(t, http.StatusConflict, resume("3", "").Code)
+	require.Equal(t, http.StatusNotFound, resume("4", "").Code)
+	require.Equal(t, http.StatusBadRequest, resume("2", "x").Code)
+}
+
+func TestRunStreamCancelsAbandonedRun(t *testing.T) {
+	canceled := make(chan struct{})
+	stream := newRunStream(func() { close(canceled) })
+	stream.reconnectWindow = 10 * time.Millisecond
+
+	// A client that reconnects in time keeps the run going.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream.watch(ctx)
+	cancel()
+	stream.watch(context.Background())
+	select {
+	case <-canceled:
+		t.Fatal("run was canceled while a client follows it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	stream = newRunStream(func() { close(canceled) })
+	stream.reconnectWindow = 10 * time.Millisecond
+	ctx, cancel = context.WithCancel(context.Background())
+	stream.watch(ctx)
+	cancel()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run that no client follows was not canceled")
+	}
+}
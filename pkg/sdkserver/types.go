@@ -2,6 +2,7 @@ package sdkserver
 
 import (
 	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -23,8 +24,9 @@ const (
 	Finished runState = "finished"
 	Error    runState = "error"
 
-	CallConfirm runner.EventType = "callConfirm"
-	Prompt      runner.EventType = "prompt"
+	CallConfirm       runner.EventType = "callConfirm"
+	CredentialConfirm runner.EventType = "credentialConfirm"
+	Prompt            runner.EventType = "prompt"
 )
 
 type toolDefs []types.ToolDef
@@ -52,15 +54,31 @@ type toolOrFileRequest struct {
 	cacheOptions  `json:",inline"`
 	openAIOptions `json:",inline"`
 
-	ToolDefs            toolDefs `json:"toolDefs,inline"`
-	SubTool             string   `json:"subTool"`
-	Input               string   `json:"input"`
-	ChatState           string   `json:"chatState"`
+	ToolDefs  toolDefs `json:"toolDefs,inline"`
+	SubTool   string   `json:"subTool"`
+	Input     string   `json:"input"`
+	ChatState string   `json:"chatState"`
+	// ChatID continues the chat with the state that was saved for it, when ChatState is empty, and saves its new state.
+	ChatID              string   `json:"chatID"`
 	Workspace           string   `json:"workspace"`
 	Env                 []string `json:"env"`
 	CredentialContext   string   `json:"credentialContext"`
 	CredentialOverrides []string `json:"credentialOverrides"`
 	Confirm             bool     `json:"confirm"`
+	// ConfirmCredentials asks for confirmation, with a credentialConfirm event, the first time a tool uses a credential
+	// from the store in the run.
+	ConfirmCredentials bool `json:"confirmCredentials"`
+	// SaveEnvVars saves the environment variables that tools prompt for, with prompt events, as credentials.
+	SaveEnvVars bool `json:"saveEnvVars"`
+	// ToolOverrides replace the model parameters of the tool being run for this request only.
+	ToolOverrides *types.ToolOverrides `json:"toolOverrides"`
+	// Labels are attached to the run, included in its events, and passed to its tools.
+	Labels map[string]string `json:"labels"`
+	// Priority is low, normal (the default), or high. When the server is running its maximum number of runs, waiting
+	// runs are started in order of priority.
+	Priority string `json:"priority"`
+	// WorkingDir is the directory that tools without a Working Dir run in, instead of that of the server.
+	WorkingDir string `json:"workingDir"`
 }
 
 type content struct {
@@ -84,12 +102,21 @@ type parseRequest struct {
 	content        `json:",inline"`
 
 	File string `json:"file"`
+	// Stream sends each node as a server sent event as soon as it is parsed, instead of the whole document at the end.
+	Stream bool `json:"stream"`
 }
 
 type modelsRequest struct {
 	Providers []string `json:"providers"`
 }
 
+type embeddingsRequest struct {
+	// Model is the embedding model, which can be that of any provider, or empty for the default embedding model.
+	Model        string   `json:"model"`
+	Input        []string `json:"input"`
+	DisableCache bool     `json:"disableCache"`
+}
+
 type runInfo struct {
 	Calls     map[string]call `json:"-"`
 	ID        string          `json:"id"`
@@ -101,6 +128,40 @@ type runInfo struct {
 	End       time.Time       `json:"end"`
 	State     runState        `json:"state"`
 	ChatState any             `json:"chatState"`
+	// Usage and Cost, in dollars, are the totals of the calls to models, and are set when the run finishes.
+	Usage types.Usage `json:"usage"`
+	Cost  float64     `json:"cost"`
+	// Labels are the labels of the run request.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ModelUsage is the usage and cost of the run per model and tool, for usage reports.
+	ModelUsage []modelUsage `json:"modelUsage,omitempty"`
+}
+
+type modelUsage struct {
+	Model string      `json:"model"`
+	Tool  string      `json:"tool"`
+	Usage types.Usage `json:"usage"`
+	Cost  float64     `json:"cost"`
+}
+
+// addModelUsage adds the usage of a completion by a tool to the usage of its model and tool.
+func (r *runInfo) addModelUsage(model, tool string, usage types.Usage, cost float64) {
+	i := slices.IndexFunc(r.ModelUsage, func(u modelUsage) bool {
+		return u.Model == model && u.Tool == tool
+	})
+	if i == -1 {
+		r.ModelUsage = append(r.ModelUsage, modelUsage{
+			Model: model,
+			Tool:  tool,
+		})
+		i = len(r.ModelUsage) - 1
+	}
+	r.ModelUsage[i].Usage.PromptTokens += usage.PromptTokens
+	r.ModelUsage[i].Usage.CompletionTokens += usage.CompletionTokens
+	r.ModelUsage[i].Usage.TotalTokens += usage.TotalTokens
+	r.ModelUsage[i].Usage.CacheReadTokens += usage.CacheReadTokens
+	r.ModelUsage[i].Usage.CacheWriteTokens += usage.CacheWriteTokens
+	r.ModelUsage[i].Cost += cost
 }
 
 func newRun(id string) *runInfo {
@@ -125,10 +186,27 @@ func (r *runInfo) process(e event) map[string]any {
 			Type:   e.Type,
 			Time:   e.Time,
 		}}
+	case runner.EventTypeProgress:
+		p := progress{
+			ID:   e.RunID,
+			Type: e.Type,
+			Time: e.Time,
+		}
+		if e.Progress != nil {
+			p.Progress = *e.Progress
+		}
+		if e.CallContext != nil {
+			p.CallID = e.CallContext.ID
+		}
+		return map[string]any{"progress": p}
 	case runner.EventTypeRunStart:
 		r.Start = e.Time
 		r.Program = *e.Program
 		r.State = Running
+		r.Labels = e.Labels
+	case runner.EventTypeRunSummary:
+		r.Usage = e.Usage
+		r.Cost = e.Cost
 	case runner.EventTypeRunFinish:
 		r.End = e.Time
 		r.Output = e.Output
@@ -166,12 +244,31 @@ func (r *runInfo) process(e event) map[string]any {
 		call.End = e.Time
 		call.setOutput(e.Content)
 
+	case runner.EventTypeCallRoute:
+		call.Route = e.Route
+
+	case runner.EventTypeCallGuardrail:
+		if e.Guardrail != nil {
+			call.Guardrails = append(call.Guardrails, *e.Guardrail)
+		}
+
 	case runner.EventTypeChat:
+		call.Usage.PromptTokens += e.Usage.PromptTokens
+		call.Usage.CompletionTokens += e.Usage.CompletionTokens
+		call.Usage.TotalTokens += e.Usage.TotalTokens
+		call.Usage.CacheReadTokens += e.Usage.CacheReadTokens
+		call.Usage.CacheWriteTokens += e.Usage.CacheWriteTokens
+		call.Cost += e.Cost
 		if e.ChatRequest != nil {
 			call.LLMRequest = e.ChatRequest
 		}
 		if e.ChatResponse != nil {
 			call.LLMResponse = e.ChatResponse
+			call.LLMLogProbs = e.ChatLogProbs
+			call.LLMPayload = e.ChatPayload
+		}
+		if e.ChatModel != "" {
+			r.addModelUsage(e.ChatModel, types.FirstSet(call.Tool.Name, call.Tool.ID), e.Usage, e.Cost)
 		}
 	}
 
@@ -198,8 +295,19 @@ type call struct {
 	Input       string           `json:"input"`
 	Output      []output         `json:"output"`
 	Usage       types.Usage      `json:"usage"`
+	Cost        float64          `json:"cost"`
 	LLMRequest  any              `json:"llmRequest"`
 	LLMResponse any              `json:"llmResponse"`
+	// Route is the agent that a tool of the agents type routed its input to.
+	Route *engine.Route `json:"route,omitempty"`
+	// LLMLogProbs are the log probabilities of the tokens of the choices of the latest response of the model, when the
+	// tool asked for them.
+	LLMLogProbs [][]types.TokenLogProb `json:"llmLogProbs,omitempty"`
+	// LLMPayload is the HTTP request and response of the latest response of the model, when it was sampled for them to
+	// be recorded in the history of the run.
+	LLMPayload *types.CompletionPayload `json:"llmPayload,omitempty"`
+	// Guardrails are what the guardrails flagged in the input or the responses of the call.
+	Guardrails []runner.GuardrailResult `json:"guardrails,omitempty"`
 }
 
 func (c *call) setSubCalls(subCalls map[string]engine.Call) {
@@ -228,6 +336,16 @@ type event struct {
 	types.Prompt  `json:",inline"`
 }
 
+// progress is the simple progress of a run, which is sent separately from the calls so that it can be shown without
+// following them.
+type progress struct {
+	runner.Progress `json:",inline"`
+	ID              string           `json:"id,omitempty"`
+	CallID          string           `json:"callID,omitempty"`
+	Type            runner.EventType `json:"type,omitempty"`
+	Time            time.Time        `json:"time,omitempty"`
+}
+
 type prompt struct {
 	types.Prompt `json:",inline"`
 	ID           string           `json:"id,omitempty"`
@@ -0,0 +1,108 @@
+package sdkserver
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+)
+
+// maxUploadSize is the largest file that can be uploaded to the workspace of a chat of the UI.
+const maxUploadSize = 100 * 1024 * 1024
+
+//go:embed ui
+var uiFiles embed.FS
+
+// serveUI serves the chat UI. Without a file in the query, the UI runs the tool of the options of the server, if it
+// has one.
+func (s *server) serveUI(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name == "" {
+		if r.URL.Query().Get("file") == "" && s.uiTool != "" {
+			http.Redirect(w, r, "/ui?file="+url.QueryEscape(s.uiTool), http.StatusFound)
+			return
+		}
+		name = "index.html"
+	}
+
+	data, err := fs.ReadFile(uiFiles, path.Join("ui", path.Clean("/"+name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		writeError(gcontext.GetLogger(r.Context()), w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(data)
+}
+
+// createWorkspace creates a workspace for a chat of the UI, which is deleted when the server stops.
+func (s *server) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := uuid.NewString()
+	dir := filepath.Join(s.workspacesDir, id)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to create workspace: %w", err))
+		return
+	}
+	writeResponse(logger, w, map[string]string{"id": id, "path": dir})
+}
+
+// uploadFile saves the file of a multipart form to a workspace of the UI.
+func (s *server) uploadFile(w http.ResponseWriter, r *http.Request) {
+	logger := gcontext.GetLogger(r.Context())
+	id := r.PathValue("id")
+	dir := filepath.Join(s.workspacesDir, id)
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("no workspace found with id %q", id))
+		return
+	} else if _, err := os.Stat(dir); err != nil {
+		writeError(logger, w, http.StatusNotFound, fmt.Errorf("no workspace found with id %q", id))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(logger, w, http.StatusBadRequest, fmt.Errorf("failed to read file: %w", err))
+		return
+	}
+	defer file.Close()
+
+	name := filepath.Base(filepath.FromSlash(header.Filename))
+	if name == "." || name == ".." || name == string(filepath.Separator) || strings.HasPrefix(name, ".") {
+		writeError(logger, w, http.StatusBadRequest, fmt.Errorf("invalid file name %q", header.Filename))
+		return
+	}
+
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to save file: %w", err))
+		return
+	}
+	_, err = io.Copy(f, file)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		writeError(logger, w, http.StatusInternalServerError, fmt.Errorf("failed to save file: %w", err))
+		return
+	}
+
+	writeResponse(logger, w, map[string]string{"path": name})
+}
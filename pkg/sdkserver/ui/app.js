@@ -0,0 +1,272 @@
+// The chat UI of the SDK server. It runs the tool with POST /run, reads the events of the run from the response as
+// they are streamed, and answers the confirmations and prompts of the run.
+(() => {
+  const params = new URLSearchParams(location.search);
+  const $ = (id) => document.getElementById(id);
+  const messages = $("messages");
+  const input = $("input");
+
+  let chatID = "";
+  let workspace = null;
+  let uploaded = [];
+  let busy = false;
+
+  $("tool").value = params.get("file") || "";
+
+  function newID() {
+    if (window.crypto && crypto.randomUUID) {
+      return crypto.randomUUID();
+    }
+    return Date.now().toString(36) + Math.random().toString(36).slice(2);
+  }
+
+  function addMessage(kind, text) {
+    const el = document.createElement("div");
+    el.className = "message " + kind;
+    el.textContent = text;
+    messages.appendChild(el);
+    messages.scrollTop = messages.scrollHeight;
+    return el;
+  }
+
+  function setBusy(value) {
+    busy = value;
+    $("send").disabled = value;
+    $("upload").disabled = value;
+  }
+
+  function newChat() {
+    chatID = "";
+    workspace = null;
+    uploaded = [];
+    $("files").textContent = "";
+    messages.textContent = "";
+  }
+
+  async function request(method, url, body) {
+    const resp = await fetch(url, { method, body });
+    const text = await resp.text();
+    let data = {};
+    try {
+      data = text ? JSON.parse(text) : {};
+    } catch (e) {
+      data = { stderr: text };
+    }
+    if (!resp.ok) {
+      throw new Error(data.stderr || resp.statusText);
+    }
+    return data;
+  }
+
+  async function upload(files) {
+    if (!workspace) {
+      workspace = await request("POST", "/ui/workspaces");
+    }
+    for (const file of files) {
+      const form = new FormData();
+      form.append("file", file);
+      const result = await request("POST", `/ui/workspaces/${workspace.id}/files`, form);
+      uploaded.push(result.path);
+    }
+    $("files").textContent = "Files to send: " + uploaded.join(", ");
+  }
+
+  function callOutput(call) {
+    const output = call.output || [];
+    for (let i = output.length - 1; i >= 0; i--) {
+      if (output[i].content) {
+        return output[i].content;
+      }
+    }
+    return "";
+  }
+
+  function confirmCall(call) {
+    const dialog = $("confirm");
+    $("confirm-tool").textContent = "Tool: " + (call.displayText || call.toolName || call.tool?.name || "");
+    $("confirm-input").textContent = call.input || "";
+    dialog.onclose = () => {
+      const accept = dialog.returnValue === "allow";
+      request("POST", `/confirm/${call.id}`, JSON.stringify({
+        accept,
+        message: accept ? "" : "The user denied the call",
+      })).catch((e) => addMessage("error", e.message));
+    };
+    dialog.returnValue = "deny";
+    dialog.showModal();
+  }
+
+  function answerPrompt(prompt) {
+    const dialog = $("prompt");
+    const fields = $("prompt-fields");
+    $("prompt-message").textContent = prompt.message || "";
+    fields.textContent = "";
+    for (const field of prompt.fields || []) {
+      const label = document.createElement("label");
+      label.textContent = field;
+      const value = document.createElement("input");
+      value.name = field;
+      value.type = prompt.sensitive ? "password" : "text";
+      fields.append(label, value);
+    }
+    dialog.onclose = () => {
+      const response = {};
+      for (const value of fields.querySelectorAll("input")) {
+        response[value.name] = value.value;
+      }
+      request("POST", `/prompt-response/${prompt.id}`, JSON.stringify(response))
+        .catch((e) => addMessage("error", e.message));
+    };
+    dialog.showModal();
+  }
+
+  // handle updates the reply of the run with an event.
+  function handle(ev, reply) {
+    if (ev.call) {
+      const call = ev.call;
+      const root = !call.parentID && !call.toolCategory;
+      if (root && (call.type === "callProgress" || call.type === "callFinish")) {
+        const text = callOutput(call);
+        if (text) {
+          reply.el.className = "message assistant";
+          reply.el.textContent = text;
+        }
+      } else if (!root && call.type === "callStart" && !call.toolCategory) {
+        reply.status.textContent = "Running " + (call.displayText || call.toolName || call.tool?.name || "a tool") + "…";
+      } else if (call.type === "callConfirm") {
+        confirmCall(call);
+      }
+    } else if (ev.prompt) {
+      answerPrompt(ev.prompt);
+    } else if (ev.run && ev.run.type === "runFinish" && ev.run.error) {
+      addMessage("error", ev.run.error);
+    } else if (ev.stdout) {
+      reply.el.className = "message assistant";
+      reply.el.textContent = ev.stdout.content || reply.el.textContent;
+      if (ev.stdout.done) {
+        // The tool ended the chat, so the next message starts a new one.
+        chatID = "";
+        addMessage("status", "The chat has ended.");
+      }
+    } else if (ev.stderr) {
+      addMessage("error", ev.stderr);
+    }
+    messages.scrollTop = messages.scrollHeight;
+  }
+
+  async function send(text) {
+    const file = $("tool").value.trim();
+    if (!file) {
+      $("tool").focus();
+      return;
+    }
+    if (!chatID) {
+      chatID = newID();
+    }
+
+    let message = text;
+    if (uploaded.length > 0) {
+      message += (message ? "\n\n" : "") + "Uploaded files in the workspace: " + uploaded.join(", ");
+    }
+    addMessage("user", message);
+    const reply = {
+      status: addMessage("status", "Thinking…"),
+      el: addMessage("status", ""),
+    };
+    uploaded = [];
+    $("files").textContent = "";
+
+    const resp = await fetch("/run", {
+      method: "POST",
+      body: JSON.stringify({
+        file,
+        input: message,
+        chatID,
+        workspace: workspace ? workspace.path : "",
+        confirm: true,
+      }),
+    });
+    if (!resp.ok) {
+      const data = await resp.json().catch(() => ({}));
+      throw new Error(data.stderr || resp.statusText);
+    }
+
+    const reader = resp.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = "";
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += decoder.decode(value, { stream: true });
+      let end;
+      while ((end = buffer.indexOf("\n\n")) >= 0) {
+        let chunk = buffer.slice(0, end).trim();
+        buffer = buffer.slice(end + 2);
+        if (chunk.startsWith("data: ")) {
+          chunk = chunk.slice(6);
+        }
+        if (!chunk || chunk === "[DONE]") {
+          continue;
+        }
+        try {
+          handle(JSON.parse(chunk), reply);
+        } catch (e) {
+          // An error written after the stream started is not an event, and is followed by the DONE event.
+          const i = chunk.indexOf("data: ");
+          try {
+            handle(JSON.parse(i > 0 ? chunk.slice(0, i) : chunk), reply);
+          } catch (e) {
+            addMessage("error", chunk);
+          }
+        }
+      }
+    }
+    reply.status.remove();
+    if (!reply.el.textContent) {
+      reply.el.remove();
+    }
+  }
+
+  $("tool-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    const url = new URL(location.href);
+    url.searchParams.set("file", $("tool").value.trim());
+    history.replaceState(null, "", url);
+    newChat();
+    input.focus();
+  });
+
+  $("chat-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    const text = input.value.trim();
+    if (busy || (!text && uploaded.length === 0)) {
+      return;
+    }
+    input.value = "";
+    setBusy(true);
+    send(text)
+      .catch((err) => addMessage("error", err.message))
+      .finally(() => {
+        setBusy(false);
+        input.focus();
+      });
+  });
+
+  input.addEventListener("keydown", (e) => {
+    if (e.key === "Enter" && !e.shiftKey) {
+      e.preventDefault();
+      $("chat-form").requestSubmit();
+    }
+  });
+
+  $("upload").addEventListener("change", (e) => {
+    const files = Array.from(e.target.files);
+    e.target.value = "";
+    setBusy(true);
+    upload(files)
+      .catch((err) => addMessage("error", err.message))
+      .finally(() => setBusy(false));
+  });
+})();
@@ -0,0 +1,72 @@
+package sdkserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUI(t *testing.T) {
+	s := &server{workspacesDir: t.TempDir(), uiTool: "./chat.gpt"}
+	mux := http.NewServeMux()
+	s.addRoutes(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The UI runs the tool of the server unless another is given.
+	w := get("/ui")
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "/ui?file=.%2Fchat.gpt", w.Header().Get("Location"))
+
+	w = get("/ui?file=other.gpt")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, w.Body.String(), "/ui/app.js")
+
+	require.Equal(t, http.StatusOK, get("/ui/app.js").Code)
+	require.Equal(t, http.StatusNotFound, get("/ui/missing.js").Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ui/workspaces", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var workspace map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspace))
+	require.DirExists(t, workspace["path"])
+
+	upload := func(id, name string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", name)
+		require.NoError(t, err)
+		_, _ = part.Write([]byte("a,b\n1,2\n"))
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/ui/workspaces/"+id+"/files", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Files are saved in the workspace under their base name.
+	w = upload(workspace["id"], "../report.csv")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"path": "report.csv"}`, w.Body.String())
+	data, err := os.ReadFile(filepath.Join(workspace["path"], "report.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n1,2\n", string(data))
+
+	require.Equal(t, http.StatusBadRequest, upload(workspace["id"], ".env").Code)
+	require.Equal(t, http.StatusNotFound, upload("missing", "report.csv").Code)
+}
@@ -0,0 +1,163 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GroupByModel = "model"
+	GroupByTool  = "tool"
+	// GroupByLabel groups by all the labels of a run, and label:<name> by the value of one label.
+	GroupByLabel = "label"
+)
+
+// UsageRow is the usage and cost of the runs of a group in a usage report.
+type UsageRow struct {
+	// Group has the value of each dimension that the report is grouped by.
+	Group            map[string]string `json:"group"`
+	Runs             int               `json:"runs"`
+	PromptTokens     int               `json:"promptTokens"`
+	CompletionTokens int               `json:"completionTokens"`
+	TotalTokens      int               `json:"totalTokens"`
+	Cost             float64           `json:"cost"`
+}
+
+// ValidateGroupBy returns an error for dimensions that a usage report can't be grouped by.
+func ValidateGroupBy(groupBy []string) error {
+	for _, dimension := range groupBy {
+		switch {
+		case dimension == GroupByModel, dimension == GroupByTool, dimension == GroupByLabel:
+		case strings.HasPrefix(dimension, GroupByLabel+":") && len(dimension) > len(GroupByLabel)+1:
+		default:
+			return fmt.Errorf("invalid group by %q, must be %s, %s, %s, or %s:<name>", dimension, GroupByModel, GroupByTool, GroupByLabel, GroupByLabel)
+		}
+	}
+	return nil
+}
+
+// UsageReport adds up the usage and cost of the runs in the storage that were created at or after from and before to,
+// grouped by the dimensions. Runs that were stored before the usage of each model was recorded are counted with an
+// empty model and tool.
+func UsageReport(ctx context.Context, storage Storage, from, to time.Time, groupBy []string) ([]UsageRow, error) {
+	if err := ValidateGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+
+	runs, err := storage.ListRunsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows    = map[string]*UsageRow{}
+		lastRun = map[string]int{}
+	)
+	for i, data := range runs {
+		var run runInfo
+		if err := json.Unmarshal(data, &run); err != nil {
+			return nil, fmt.Errorf("invalid run: %w", err)
+		}
+
+		entries := run.ModelUsage
+		if len(entries) == 0 && run.Usage.TotalTokens > 0 {
+			entries = []modelUsage{{Usage: run.Usage, Cost: run.Cost}}
+		}
+
+		for _, entry := range entries {
+			group := usageGroup(groupBy, run.Labels, entry)
+			var values []string
+			for _, dimension := range groupBy {
+				values = append(values, group[dimension])
+			}
+			key := strings.Join(values, "\x00")
+
+			row, ok := rows[key]
+			if !ok {
+				row = &UsageRow{Group: group}
+				rows[key] = row
+			}
+			// A run with several entries in a group is counted once.
+			if last, ok := lastRun[key]; !ok || last != i {
+				row.Runs++
+				lastRun[key] = i
+			}
+			row.PromptTokens += entry.Usage.PromptTokens
+			row.CompletionTokens += entry.Usage.CompletionTokens
+			row.TotalTokens += entry.Usage.TotalTokens
+			row.Cost += entry.Cost
+		}
+	}
+
+	result := make([]UsageRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	slices.SortFunc(result, func(a, b UsageRow) int {
+		for _, dimension := range groupBy {
+			if c := strings.Compare(a.Group[dimension], b.Group[dimension]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return result, nil
+}
+
+func usageGroup(groupBy []string, labels map[string]string, entry modelUsage) map[string]string {
+	group := make(map[string]string, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByModel:
+			group[dimension] = entry.Model
+		case GroupByTool:
+			group[dimension] = entry.Tool
+		case GroupByLabel:
+			var pairs []string
+			for key, value := range labels {
+				pairs = append(pairs, key+"="+value)
+			}
+			slices.Sort(pairs)
+			group[dimension] = strings.Join(pairs, ",")
+		default:
+			group[dimension] = labels[strings.TrimPrefix(dimension, GroupByLabel+":")]
+		}
+	}
+	return group
+}
+
+// WriteUsageCSV writes a usage report as CSV, with a column for each dimension that it is grouped by.
+func WriteUsageCSV(w io.Writer, groupBy []string, rows []UsageRow) error {
+	out := csv.NewWriter(w)
+	header := append(slices.Clone(groupBy), "runs", "promptTokens", "completionTokens", "totalTokens", "cost")
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		var record []string
+		for _, dimension := range groupBy {
+			record = append(record, row.Group[dimension])
+		}
+		record = append(record,
+			strconv.Itoa(row.Runs),
+			strconv.Itoa(row.PromptTokens),
+			strconv.Itoa(row.CompletionTokens),
+			strconv.Itoa(row.TotalTokens),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		)
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
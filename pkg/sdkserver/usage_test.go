@@ -0,0 +1,81 @@
+package sdkserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	gserver "github.com/gptscript-ai/gptscript/pkg/server"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageReport(t *testing.T) {
+	ctx := context.Background()
+	storage := newMemoryStorage(10)
+
+	chat := func(tool, model string, tokens int, cost float64) runner.Event {
+		return runner.Event{
+			Type: runner.EventTypeChat,
+			CallContext: &engine.CallContext{
+				ToolName: tool,
+			},
+			Usage:     types.Usage{PromptTokens: tokens, TotalTokens: tokens},
+			Cost:      cost,
+			ChatModel: model,
+		}
+	}
+	saveRun := func(id string, labels map[string]string, events ...runner.Event) {
+		run := newRun(id)
+		run.process(event{Event: gserver.Event{RunID: id, Program: &types.Program{}, Event: runner.Event{Type: runner.EventTypeRunStart, Labels: labels}}})
+		for i, e := range events {
+			e.CallContext.ID = id + "-" + string(rune('a'+i))
+			e.CallContext.Tool.Name = e.CallContext.ToolName
+			run.process(event{Event: gserver.Event{RunID: id, Event: e}})
+		}
+		data, err := json.Marshal(run)
+		require.NoError(t, err)
+		require.NoError(t, storage.SaveRun(ctx, id, data))
+	}
+
+	saveRun("1", map[string]string{"team": "payments"},
+		chat("classify", "gpt-4o", 100, 0.5),
+		chat("classify", "gpt-4o", 50, 0.25),
+		chat("summarize", "gpt-4o-mini", 10, 0.01),
+	)
+	saveRun("2", map[string]string{"team": "search", "env": "prod"},
+		chat("classify", "gpt-4o", 200, 1),
+	)
+
+	rows, err := UsageReport(ctx, storage, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), []string{"label:team", "model"})
+	require.NoError(t, err)
+	require.Equal(t, []UsageRow{
+		{Group: map[string]string{"label:team": "payments", "model": "gpt-4o"}, Runs: 1, PromptTokens: 150, TotalTokens: 150, Cost: 0.75},
+		{Group: map[string]string{"label:team": "payments", "model": "gpt-4o-mini"}, Runs: 1, PromptTokens: 10, TotalTokens: 10, Cost: 0.01},
+		{Group: map[string]string{"label:team": "search", "model": "gpt-4o"}, Runs: 1, PromptTokens: 200, TotalTokens: 200, Cost: 1},
+	}, rows)
+
+	rows, err = UsageReport(ctx, storage, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), []string{"tool", "label"})
+	require.NoError(t, err)
+	require.Equal(t, []UsageRow{
+		{Group: map[string]string{"tool": "classify", "label": "env=prod,team=search"}, Runs: 1, PromptTokens: 200, TotalTokens: 200, Cost: 1},
+		{Group: map[string]string{"tool": "classify", "label": "team=payments"}, Runs: 1, PromptTokens: 150, TotalTokens: 150, Cost: 0.75},
+		{Group: map[string]string{"tool": "summarize", "label": "team=payments"}, Runs: 1, PromptTokens: 10, TotalTokens: 10, Cost: 0.01},
+	}, rows)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteUsageCSV(buf, []string{"tool", "label"}, rows[:1]))
+	require.Equal(t, "tool,label,runs,promptTokens,completionTokens,totalTokens,cost\nclassify,\"env=prod,team=search\",1,200,0,200,1.000000\n", buf.String())
+
+	// Runs outside of the time range are not counted.
+	rows, err = UsageReport(ctx, storage, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), []string{"model"})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+
+	_, err = UsageReport(ctx, storage, time.Time{}, time.Now(), []string{"cost center"})
+	require.Error(t, err)
+}
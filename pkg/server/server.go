@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/gptscript-ai/gptscript/pkg/counter"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
@@ -20,7 +22,8 @@ type Event struct {
 type execKey struct{}
 
 func ContextWithNewRunID(ctx context.Context) context.Context {
-	return context.WithValue(ctx, execKey{}, counter.Next())
+	id := counter.Next()
+	return mvl.WithFields(engine.WithRunID(context.WithValue(ctx, execKey{}, id), id), "runID", id)
 }
 
 func RunIDFromContext(ctx context.Context) string {
@@ -0,0 +1,463 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gptscript-ai/gptscript/pkg/auth"
+	gcontext "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/proxy"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	slackapi "github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+var (
+	log = mvl.Package()
+
+	mentionRegexp = regexp.MustCompile(`<@[A-Z0-9]+>`)
+)
+
+const (
+	confirmAction = "gptscript_confirm"
+	allow         = "allow"
+	deny          = "deny"
+)
+
+// ChatFunc runs a turn of the chat, like GPTScript.Chat.
+type ChatFunc func(ctx context.Context, prevState runner.ChatState, input string) (runner.ChatResponse, error)
+
+type Options struct {
+	// AppToken is the app-level token (xapp-) with the connections:write scope used to open Socket Mode connections.
+	AppToken string
+	// BotToken is the bot token (xoxb-) used to post messages.
+	BotToken string
+	// StateDir is where the chat state of each channel is persisted, the state is kept in memory if empty.
+	StateDir string
+	// APIURL is the base URL of the Slack Web API.
+	APIURL string
+}
+
+// Adapter connects a chat tool to Slack with Socket Mode. Direct messages to the app and mentions of it in channels
+// are turns of the chat of their channel, and tool confirmations are posted as messages with buttons.
+type Adapter struct {
+	opts Options
+	api  api
+	chat ChatFunc
+
+	lock     sync.Mutex
+	channels map[string]*sync.Mutex
+	states   map[string]string
+	confirms map[string]confirmation
+}
+
+// confirmation is a pending confirmation of a tool run, which only the user that sent the message can answer.
+type confirmation struct {
+	user   string
+	accept chan bool
+}
+
+func New(opts Options, chat ChatFunc) (*Adapter, error) {
+	if opts.AppToken == "" || opts.BotToken == "" {
+		return nil, fmt.Errorf("both a Slack app token and bot token are required")
+	}
+	if opts.APIURL == "" {
+		opts.APIURL = defaultAPIURL
+	}
+	if !strings.HasSuffix(opts.APIURL, "/") {
+		opts.APIURL += "/"
+	}
+
+	return &Adapter{
+		opts: opts,
+		api: api{
+			url:    opts.APIURL,
+			client: proxy.Client,
+		},
+		chat:     chat,
+		channels: map[string]*sync.Mutex{},
+		states:   map[string]string{},
+		confirms: map[string]confirmation{},
+	}, nil
+}
+
+// Run connects to Slack and handles events until the context is cancelled. The Socket Mode client reconnects whenever
+// Slack asks it to, or doesn't ping it in time, and Run reconnects when the client fails to.
+func (a *Adapter) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Turns of the chats outlive the connection they arrived on, but not Run.
+	turnCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client := socketmode.New(slackapi.New(a.opts.BotToken,
+		slackapi.OptionAppLevelToken(a.opts.AppToken),
+		slackapi.OptionAPIURL(a.opts.APIURL),
+		slackapi.OptionHTTPClient(proxy.Client),
+	), socketmode.OptionDialer(&websocket.Dialer{
+		Proxy:            proxy.Transport.Proxy,
+		HandshakeTimeout: 45 * time.Second,
+	}))
+
+	events := make(chan struct{})
+	go func() {
+		defer close(events)
+		a.handleEvents(ctx, turnCtx, &wg, client)
+	}()
+	defer func() {
+		cancel()
+		<-events
+	}()
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := client.RunContext(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+
+		log.Errorf("Slack connection failed, reconnecting in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+// handleEvents acknowledges and handles the events of the Socket Mode client until the context is cancelled.
+func (a *Adapter) handleEvents(ctx, turnCtx context.Context, wg *sync.WaitGroup, client *socketmode.Client) {
+	for {
+		var evt socketmode.Event
+		select {
+		case <-ctx.Done():
+			return
+		case evt = <-client.Events:
+		}
+
+		switch evt.Type {
+		case socketmode.EventTypeConnected:
+			log.Infof("Connected to Slack")
+		case socketmode.EventTypeErrorBadMessage:
+			log.Debugf("Ignoring invalid Slack message: %v", evt.Data)
+		case socketmode.EventTypeEventsAPI:
+			_ = client.AckCtx(ctx, evt.Request.EnvelopeID, nil)
+			a.handleEvent(turnCtx, wg, evt.Request.Payload)
+		case socketmode.EventTypeInteractive:
+			_ = client.AckCtx(ctx, evt.Request.EnvelopeID, nil)
+			a.handleInteraction(evt.Request.Payload)
+		}
+	}
+}
+
+type eventPayload struct {
+	Event struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		User        string `json:"user"`
+		BotID       string `json:"bot_id"`
+		Text        string `json:"text"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+func (a *Adapter) handleEvent(ctx context.Context, wg *sync.WaitGroup, payload json.RawMessage) {
+	var p eventPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Debugf("Ignoring invalid Slack event: %v", err)
+		return
+	}
+
+	event := p.Event
+	if event.BotID != "" || event.Subtype != "" || event.User == "" {
+		return
+	}
+	// Mentions in channels arrive as app_mention events, so only direct messages are handled as message events.
+	if event.Type != "app_mention" && (event.Type != "message" || event.ChannelType != "im") {
+		return
+	}
+
+	input := strings.TrimSpace(mentionRegexp.ReplaceAllString(event.Text, ""))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.turn(ctx, event.Channel, event.ThreadTS, event.User, input)
+	}()
+}
+
+// turn runs a turn of the chat of the channel, one at a time per channel.
+func (a *Adapter) turn(ctx context.Context, channel, threadTS, user, input string) {
+	lock := a.channelLock(channel)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx = withChannel(ctx, channel, threadTS, user)
+	ctx = gcontext.WithLabels(ctx, map[string]string{"slack.channel": channel})
+
+	state, err := a.loadState(channel)
+	if err != nil {
+		a.post(ctx, channel, threadTS, fmt.Sprintf("Failed to load the chat state: %v", err))
+		return
+	}
+
+	resp, err := a.chat(ctx, state, input)
+	if err != nil {
+		log.Errorf("Chat in Slack channel %s failed: %v", channel, err)
+		a.post(ctx, channel, threadTS, fmt.Sprintf("Error: %v", err))
+		return
+	}
+
+	if resp.Content != "" {
+		a.post(ctx, channel, threadTS, resp.Content)
+	}
+
+	if resp.Done {
+		// Start a new chat on the next message.
+		err = a.saveState(channel, nil)
+	} else {
+		err = a.saveState(channel, resp.State)
+	}
+	if err != nil {
+		log.Errorf("Failed to save chat state of Slack channel %s: %v", channel, err)
+	}
+}
+
+func (a *Adapter) post(ctx context.Context, channel, threadTS, content string) {
+	if _, err := a.api.postMessage(ctx, a.opts.BotToken, message{
+		Channel:  channel,
+		ThreadTS: threadTS,
+		Text:     content,
+	}); err != nil {
+		log.Errorf("Failed to post to Slack channel %s: %v", channel, err)
+	}
+}
+
+func (a *Adapter) channelLock(channel string) *sync.Mutex {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	lock, ok := a.channels[channel]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.channels[channel] = lock
+	}
+	return lock
+}
+
+func (a *Adapter) statePath(channel string) string {
+	return filepath.Join(a.opts.StateDir, filepath.Base(channel)+".json")
+}
+
+func (a *Adapter) loadState(channel string) (runner.ChatState, error) {
+	if a.opts.StateDir == "" {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		return a.states[channel], nil
+	}
+
+	data, err := os.ReadFile(a.statePath(channel))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *Adapter) saveState(channel string, state runner.ChatState) error {
+	var data []byte
+	if state != nil {
+		var err error
+		data, err = json.Marshal(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.opts.StateDir == "" {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		if data == nil {
+			delete(a.states, channel)
+		} else {
+			a.states[channel] = string(data)
+		}
+		return nil
+	}
+
+	if data == nil {
+		if err := os.Remove(a.statePath(channel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(a.opts.StateDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(a.statePath(channel), data, 0600)
+}
+
+type interactionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Message struct {
+		TS string `json:"ts"`
+	} `json:"message"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+func (a *Adapter) handleInteraction(payload json.RawMessage) {
+	var p interactionPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Debugf("Ignoring invalid Slack interaction: %v", err)
+		return
+	}
+	if p.Type != "block_actions" {
+		return
+	}
+
+	for _, action := range p.Actions {
+		if action.ActionID != confirmAction {
+			continue
+		}
+		id, decision, _ := strings.Cut(action.Value, ":")
+
+		a.lock.Lock()
+		confirm, ok := a.confirms[id]
+		if ok && confirm.user != p.User.ID {
+			// Anyone in the channel can click the buttons, but only the user that asked for the run can confirm it.
+			log.Debugf("Ignoring confirmation of %s by Slack user %s", id, p.User.ID)
+			ok = false
+		} else if ok {
+			delete(a.confirms, id)
+		}
+		a.lock.Unlock()
+
+		if ok {
+			confirm.accept <- decision == allow
+		}
+	}
+}
+
+// Authorize asks for confirmation in the Slack channel of the chat before running a tool, to be used as the
+// Authorizer of the runner.
+func (a *Adapter) Authorize(ctx engine.Context, input string) (runner.AuthorizerResponse, error) {
+	if auth.IsSafe(ctx) {
+		return runner.AuthorizerResponse{
+			Accept: true,
+		}, nil
+	}
+
+	channel, threadTS, user, ok := channelFromContext(ctx.Ctx)
+	if !ok {
+		return runner.AuthorizerResponse{}, fmt.Errorf("no Slack channel to confirm the run of %s in", ctx.Tool.Name)
+	}
+
+	confirm := make(chan bool, 1)
+	a.lock.Lock()
+	a.confirms[ctx.ID] = confirmation{user: user, accept: confirm}
+	a.lock.Unlock()
+	defer func() {
+		a.lock.Lock()
+		delete(a.confirms, ctx.ID)
+		a.lock.Unlock()
+	}()
+
+	prompt := auth.ConfirmMessage(ctx, input)
+	ts, err := a.api.postMessage(ctx.Ctx, a.opts.BotToken, message{
+		Channel:  channel,
+		ThreadTS: threadTS,
+		Text:     prompt,
+		Blocks: []block{
+			{
+				Type: "section",
+				Text: &text{Type: "mrkdwn", Text: "```" + prompt + "```"},
+			},
+			{
+				Type: "actions",
+				Elements: []element{
+					{Type: "button", Text: &text{Type: "plain_text", Text: "Allow"}, ActionID: confirmAction, Value: ctx.ID + ":" + allow, Style: "primary"},
+					{Type: "button", Text: &text{Type: "plain_text", Text: "Deny"}, ActionID: confirmAction, Value: ctx.ID + ":" + deny, Style: "danger"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return runner.AuthorizerResponse{}, err
+	}
+
+	var accept bool
+	select {
+	case <-ctx.Ctx.Done():
+		return runner.AuthorizerResponse{}, ctx.Ctx.Err()
+	case accept = <-confirm:
+	}
+
+	decision := "Allowed"
+	if !accept {
+		decision = "Denied"
+	}
+	if err := a.api.updateMessage(ctx.Ctx, a.opts.BotToken, message{
+		Channel: channel,
+		TS:      ts,
+		Text:    prompt + "\n" + decision,
+		Blocks: []block{
+			{
+				Type: "section",
+				Text: &text{Type: "mrkdwn", Text: "```" + prompt + "```\n*" + decision + "*"},
+			},
+		},
+	}); err != nil {
+		log.Debugf("Failed to update confirmation in Slack channel %s: %v", channel, err)
+	}
+
+	return runner.AuthorizerResponse{
+		Accept:  accept,
+		Message: "Request denied, blocking execution.",
+	}, nil
+}
+
+type channelKey struct{}
+
+type channelValue struct {
+	channel, threadTS, user string
+}
+
+func withChannel(ctx context.Context, channel, threadTS, user string) context.Context {
+	return context.WithValue(ctx, channelKey{}, channelValue{channel: channel, threadTS: threadTS, user: user})
+}
+
+// channelFromContext returns the channel and thread of the chat, and the user that sent the message of the turn.
+func channelFromContext(ctx context.Context) (string, string, string, bool) {
+	v, ok := ctx.Value(channelKey{}).(channelValue)
+	return v.channel, v.threadTS, v.user, ok
+}
@@ -0,0 +1,146 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlack serves the Web API methods used by the adapter and a Socket Mode endpoint that sends the given envelopes.
+type fakeSlack struct {
+	envelopes []string
+
+	lock   sync.Mutex
+	posted []message
+	acks   []string
+}
+
+func (f *fakeSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/apps.connections.open":
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "url": "ws://" + r.Host + "/socket"})
+	case "/api/chat.postMessage":
+		var msg message
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		f.lock.Lock()
+		f.posted = append(f.posted, msg)
+		f.lock.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "ts": "1.2"})
+	case "/socket":
+		f.serveSocket(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeSlack) serveSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, env := range f.envelopes {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(env)); err != nil {
+			return
+		}
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		f.lock.Lock()
+		f.acks = append(f.acks, string(data))
+		f.lock.Unlock()
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	fake := &fakeSlack{
+		envelopes: []string{
+			`{"type":"hello"}`,
+			`{"envelope_id":"1","type":"events_api","payload":{"type":"event_callback","event":{"type":"message","channel_type":"im","channel":"D1","user":"U1","text":"hi"}}}`,
+			`{"envelope_id":"2","type":"events_api","payload":{"type":"event_callback","event":{"type":"message","channel_type":"im","channel":"D1","bot_id":"B1","text":"ignored"}}}`,
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inputs := make(chan string, 10)
+	a, err := New(Options{
+		AppToken: "xapp",
+		BotToken: "xoxb",
+		StateDir: t.TempDir(),
+		APIURL:   server.URL + "/api",
+	}, func(ctx context.Context, prevState runner.ChatState, input string) (runner.ChatResponse, error) {
+		channel, _, _, _ := channelFromContext(ctx)
+		inputs <- channel + ": " + input
+		return runner.ChatResponse{Content: "hello", State: map[string]string{"turn": "1"}}, nil
+	})
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	select {
+	case input := <-inputs:
+		assert.Equal(t, "D1: hi", input)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for chat")
+	}
+
+	assert.Eventually(t, func() bool {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		return len(fake.posted) == 1 && len(fake.acks) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, message{Channel: "D1", Text: "hello"}, fake.posted[0])
+	assert.JSONEq(t, `{"envelope_id":"1"}`, fake.acks[0])
+
+	assert.Eventually(t, func() bool {
+		state, err := a.loadState("D1")
+		return err == nil && state == `{"turn":"1"}`
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, inputs)
+}
+
+func TestHandleInteraction(t *testing.T) {
+	a, err := New(Options{AppToken: "xapp", BotToken: "xoxb"}, nil)
+	require.NoError(t, err)
+
+	confirm := make(chan bool, 1)
+	a.confirms["call1"] = confirmation{user: "U1", accept: confirm}
+
+	// Only the user that asked for the run can confirm it.
+	a.handleInteraction(json.RawMessage(`{"type":"block_actions","user":{"id":"U2"},"actions":[{"action_id":"` + confirmAction + `","value":"call1:allow"}]}`))
+	assert.Empty(t, confirm)
+	assert.Contains(t, a.confirms, "call1")
+
+	a.handleInteraction(json.RawMessage(`{"type":"block_actions","user":{"id":"U1"},"actions":[{"action_id":"` + confirmAction + `","value":"call1:allow"}]}`))
+	assert.True(t, <-confirm)
+	assert.NotContains(t, a.confirms, "call1")
+
+	// Unknown calls, such as ones that were already answered, are ignored.
+	a.handleInteraction(json.RawMessage(`{"type":"block_actions","user":{"id":"U1"},"actions":[{"action_id":"` + confirmAction + `","value":"call1:deny"}]}`))
+	assert.Empty(t, confirm)
+}
@@ -0,0 +1,106 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const defaultAPIURL = "https://slack.com/api/"
+
+type api struct {
+	url    string
+	client *http.Client
+}
+
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// call invokes a Slack Web API method with a JSON body and decodes the response into result, if not nil.
+func (a *api) call(ctx context.Context, token, method string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s failed: %s", method, resp.Status)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("slack %s failed: %w", method, err)
+	}
+
+	var status apiResponse
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("slack %s failed: %w", method, err)
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s failed: %s", method, status.Error)
+	}
+
+	if result != nil {
+		return json.Unmarshal(raw, result)
+	}
+	return nil
+}
+
+type message struct {
+	Channel  string  `json:"channel"`
+	TS       string  `json:"ts,omitempty"`
+	ThreadTS string  `json:"thread_ts,omitempty"`
+	Text     string  `json:"text"`
+	Blocks   []block `json:"blocks,omitempty"`
+}
+
+type block struct {
+	Type     string    `json:"type"`
+	BlockID  string    `json:"block_id,omitempty"`
+	Text     *text     `json:"text,omitempty"`
+	Elements []element `json:"elements,omitempty"`
+}
+
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type element struct {
+	Type     string `json:"type"`
+	Text     *text  `json:"text,omitempty"`
+	ActionID string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+func (a *api) postMessage(ctx context.Context, token string, msg message) (string, error) {
+	var result struct {
+		TS string `json:"ts"`
+	}
+	if err := a.call(ctx, token, "chat.postMessage", msg, &result); err != nil {
+		return "", err
+	}
+	return result.TS, nil
+}
+
+func (a *api) updateMessage(ctx context.Context, token string, msg message) error {
+	return a.call(ctx, token, "chat.update", msg, nil)
+}
@@ -0,0 +1,24 @@
+package system
+
+import "os/exec"
+
+// StartProcessTree starts cmd so that cancelling it kills the command along with every process the command started,
+// instead of only the command itself. This replaces the Cancel func of cmd. The returned function releases the
+// resources held to track the process tree and must be called once the command has exited.
+func StartProcessTree(cmd *exec.Cmd) (func(), error) {
+	tree, err := newProcessTree(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		tree.release()
+		return nil, err
+	}
+	if err := tree.track(cmd); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		tree.release()
+		return nil, err
+	}
+	return tree.release, nil
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// processTree puts the command in a new process group so that the whole group can be killed on cancellation.
+type processTree struct{}
+
+func newProcessTree(cmd *exec.Cmd) (*processTree, error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return &processTree{}, nil
+}
+
+func (*processTree) track(*exec.Cmd) error {
+	return nil
+}
+
+func (*processTree) release() {}
@@ -0,0 +1,73 @@
+//go:build windows
+
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processTree assigns the command to a job object. Processes started by the command join the same job, so terminating
+// the job on cancellation tears down the whole tree. The job is created with JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE so
+// that the tree is also torn down if gptscript exits without cleaning up. As a result, processes the command leaves
+// running are killed once the job is released.
+type processTree struct {
+	lock   sync.Mutex
+	job    windows.Handle
+	closed bool
+}
+
+func newProcessTree(cmd *exec.Cmd) (*processTree, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job object: %w", err)
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		_ = windows.CloseHandle(job)
+		return nil, fmt.Errorf("failed to configure job object: %w", err)
+	}
+
+	tree := &processTree{job: job}
+	cmd.Cancel = func() error {
+		tree.lock.Lock()
+		defer tree.lock.Unlock()
+		if tree.closed {
+			return cmd.Process.Kill()
+		}
+		return windows.TerminateJobObject(tree.job, 1)
+	}
+	return tree, nil
+}
+
+func (p *processTree) track(cmd *exec.Cmd) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open process %d: %w", cmd.Process.Pid, err)
+	}
+	defer windows.CloseHandle(process)
+
+	if err := windows.AssignProcessToJobObject(p.job, process); err != nil {
+		return fmt.Errorf("failed to assign process %d to job object: %w", cmd.Process.Pid, err)
+	}
+	return nil
+}
+
+func (p *processTree) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.closed {
+		p.closed = true
+		_ = windows.CloseHandle(p.job)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/gptscript-ai/gptscript/pkg/engine"
@@ -928,3 +929,75 @@ func TestSysContext(t *testing.T) {
 	require.Len(t, context.Call.AgentGroup, 1)
 	assert.Equal(t, context.Call.AgentGroup[0].Named, "iAmSuperman")
 }
+
+func TestArgTemplate(t *testing.T) {
+	r := tester.NewRunner(t)
+	x, err := r.Run("", `{"city": "Berlin", "days": 3}`)
+	require.NoError(t, err)
+	assert.Equal(t, "TEST RESULT CALL: 1", x)
+}
+
+func TestToolNamespace(t *testing.T) {
+	r := tester.NewRunner(t)
+	x, err := r.Run("", `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "TEST RESULT CALL: 1", x)
+}
+
+func TestTraceEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	r := tester.NewRunner(t)
+	x, err := r.Run("", "")
+	require.NoError(t, err)
+
+	fields := strings.Fields(x)
+	require.Len(t, fields, 3)
+	// The top level call starts the run, so they share an ID
+	assert.Equal(t, fields[0], fields[1])
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, fields[2])
+}
+
+func TestValidate(t *testing.T) {
+	r := tester.NewRunner(t)
+	r.RespondWith(tester.Result{
+		Text: "not json",
+	}, tester.Result{
+		Text: `{"name": "Ada"}`,
+	})
+
+	x, err := r.Run("", "")
+	require.NoError(t, err)
+	r.AssertResponded(t)
+	assert.Equal(t, `{"name": "Ada"}`, x)
+
+	r.RespondWith(tester.Result{
+		Text: "{}",
+	}, tester.Result{
+		Text: "{}",
+	})
+
+	_, err = r.Run("", "")
+	r.AssertResponded(t)
+	require.ErrorContains(t, err, "failed validation after 1 retries")
+}
+
+func TestValidateTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	r := tester.NewRunner(t)
+	r.RespondWith(tester.Result{
+		Text: "Hello",
+	}, tester.Result{
+		Text: "OK",
+	})
+
+	x, err := r.Run("", "")
+	require.NoError(t, err)
+	r.AssertResponded(t)
+	assert.Equal(t, "OK", x)
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Artifact is a file in the workspace that a tool declared as an output of the run.
+type Artifact struct {
+	// Name is the slash separated path of the file relative to the workspace
+	Name string `json:"name"`
+	// Tool is the name of the tool that declared the artifact
+	Tool string `json:"tool,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// ValidateArtifactPattern checks that the pattern is a valid glob relative to the workspace.
+func ValidateArtifactPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("artifact pattern must not be empty")
+	}
+	if path.IsAbs(pattern) || strings.Contains(pattern, "\\") {
+		return fmt.Errorf("artifact %s must be a slash separated path relative to the workspace", pattern)
+	}
+	for _, part := range strings.Split(pattern, "/") {
+		if part == ".." {
+			return fmt.Errorf("artifact %s must not reference files outside of the workspace", pattern)
+		}
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid artifact pattern %s: %w", pattern, err)
+	}
+	return nil
+}
+
+// ArtifactPatterns returns the artifact patterns declared by the tools of the program, keyed by tool name.
+func (p Program) ArtifactPatterns() map[string][]string {
+	result := map[string][]string{}
+	for _, tool := range p.ToolSet {
+		if len(tool.Artifacts) == 0 {
+			continue
+		}
+		name := tool.Name
+		if name == "" {
+			name = p.Name
+		}
+		result[name] = append(result[name], tool.Artifacts...)
+	}
+	return result
+}
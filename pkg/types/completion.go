@@ -1,8 +1,11 @@
 package types
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/getkin/kin-openapi/openapi3"
@@ -17,9 +20,38 @@ type CompletionRequest struct {
 	Chat                 bool                `json:"chat,omitempty"`
 	Temperature          *float32            `json:"temperature,omitempty"`
 	JSONResponse         bool                `json:"jsonResponse,omitempty"`
+	OutputSchema         json.RawMessage     `json:"outputSchema,omitempty"`
+	OutputRegex          string              `json:"outputRegex,omitempty"`
+	OutputGrammar        string              `json:"outputGrammar,omitempty"`
 	Cache                *bool               `json:"cache,omitempty"`
+	// Choices is the number of candidate responses to generate, when more than one.
+	Choices int `json:"choices,omitempty"`
+	// BestOf is the number of completions to request in parallel, when more than one. Each is sent with its index as
+	// the Candidate, so that they are not served from the same cache entry.
+	BestOf    int `json:"bestOf,omitempty"`
+	Candidate int `json:"candidate,omitempty"`
+	// TopP, Stop, FrequencyPenalty, and PresencePenalty are sampling parameters that are left to the provider when unset.
+	TopP             *float32 `json:"topP,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	FrequencyPenalty *float32 `json:"frequencyPenalty,omitempty"`
+	PresencePenalty  *float32 `json:"presencePenalty,omitempty"`
+	// ParallelToolCalls set to false asks the model to call at most one tool per response.
+	ParallelToolCalls *bool `json:"parallelToolCalls,omitempty"`
+	// ToolChoice is auto, none, required to have the model call at least one tool, or the name of the function it
+	// must call.
+	ToolChoice string `json:"toolChoice,omitempty"`
+	// LogProbs asks for the log probabilities of the tokens of the response, and TopLogProbs for those of that many of
+	// the most likely tokens at each position.
+	LogProbs    bool `json:"logProbs,omitempty"`
+	TopLogProbs int  `json:"topLogProbs,omitempty"`
 }
 
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
 func (r *CompletionRequest) GetCache() bool {
 	if r.Cache == nil {
 		return true
@@ -36,6 +68,7 @@ type CompletionFunctionDefinition struct {
 	Name        string           `json:"name"`
 	Description string           `json:"description,omitempty"`
 	Parameters  *openapi3.Schema `json:"parameters"`
+	Overrides   *ToolOverrides   `json:"overrides,omitempty"`
 }
 
 // Chat message role defined by the OpenAI API.
@@ -55,6 +88,26 @@ type CompletionMessage struct {
 	// result of the call describe by this field
 	ToolCall *CompletionToolCall `json:"toolCall,omitempty"`
 	Usage    Usage               `json:"usage,omitempty"`
+	// Candidates are the other responses generated for a request with more than one choice.
+	Candidates []CompletionMessage `json:"candidates,omitempty"`
+	// ContentFilter is set when the content filter of the provider blocked the request or the response.
+	ContentFilter *ContentFilter `json:"contentFilter,omitempty"`
+}
+
+// ContentFilter is the result of a request that the content filter of the provider blocked.
+type ContentFilter struct {
+	Filtered bool `json:"filtered"`
+	// Prompt is set when the request was rejected, instead of the response being cut off.
+	Prompt bool `json:"prompt,omitempty"`
+	// Categories are the categories of content that were filtered, such as hate or violence, when the provider
+	// reports them.
+	Categories []string `json:"categories,omitempty"`
+}
+
+// String returns the content filter result as JSON, which is the output of a tool whose response was filtered.
+func (c ContentFilter) String() string {
+	data, _ := json.Marshal(c)
+	return string(data)
 }
 
 func (c CompletionMessage) ChatText() string {
@@ -75,6 +128,10 @@ type Usage struct {
 	PromptTokens     int `json:"promptTokens,omitempty"`
 	CompletionTokens int `json:"completionTokens,omitempty"`
 	TotalTokens      int `json:"totalTokens,omitempty"`
+	// CacheReadTokens are the prompt tokens that the provider read from its prompt cache, and CacheWriteTokens those
+	// that it wrote to it, when it reports them.
+	CacheReadTokens  int `json:"cacheReadTokens,omitempty"`
+	CacheWriteTokens int `json:"cacheWriteTokens,omitempty"`
 }
 
 type CompletionStatus struct {
@@ -85,6 +142,85 @@ type CompletionStatus struct {
 	Cached          bool
 	Chunks          any
 	PartialResponse *CompletionMessage
+	// Placeholder is set for a PartialResponse that only says that the response is pending, and is not part of it.
+	Placeholder bool
+	// Approximate is set with Cached when the response was cached for a prompt that is only similar to that of the
+	// request.
+	Approximate bool
+	// Candidate is the Candidate of the request.
+	Candidate int
+	// Model is the model that the request was sent to, it is only set with the Response.
+	Model string
+	// Timing is how fast the model responded, it is only set with the Response of a request that was not cached.
+	Timing *Timing
+	// ContentFilter is set with the Response when the content filter of the provider blocked the request or response.
+	ContentFilter *ContentFilter
+	// RateLimit is set with the Response when the provider reported its rate limits in the headers of its responses.
+	RateLimit *RateLimit
+	// LogProbs are the log probabilities of the tokens of each choice of the Response, the response first and then its
+	// candidates. They are only set with the Response of a request that asked for them.
+	LogProbs [][]TokenLogProb
+	// Payload is set with the Response of a request that was sampled for its payload to be recorded.
+	Payload *CompletionPayload
+}
+
+// CompletionPayload is a request to a model provider and its response as they were sent over HTTP, with the secrets in
+// them redacted.
+type CompletionPayload struct {
+	Request  PayloadRequest  `json:"request"`
+	Response PayloadResponse `json:"response"`
+}
+
+type PayloadRequest struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   string              `json:"body,omitempty"`
+}
+
+type PayloadResponse struct {
+	StatusCode int                 `json:"statusCode"`
+	Header     map[string][]string `json:"header,omitempty"`
+	// Body is the whole body of the response, which are the server sent events of a streamed response.
+	Body string `json:"body,omitempty"`
+}
+
+// TokenLogProb is the log probability of a token of a response, and of the most likely tokens at its position when
+// they were asked for.
+type TokenLogProb struct {
+	Token       string         `json:"token"`
+	LogProb     float64        `json:"logProb"`
+	TopLogProbs []TopTokenProb `json:"topLogProbs,omitempty"`
+}
+
+type TopTokenProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logProb"`
+}
+
+// RateLimit is what a model provider reported of its rate limits with its latest response. Remaining requests or tokens
+// are nil when the provider didn't report them.
+type RateLimit struct {
+	LimitRequests     int  `json:"limitRequests,omitempty"`
+	RemainingRequests *int `json:"remainingRequests,omitempty"`
+	// ResetRequests is how long it takes for the remaining requests to reset to the limit.
+	ResetRequests   time.Duration `json:"resetRequests,omitempty"`
+	LimitTokens     int           `json:"limitTokens,omitempty"`
+	RemainingTokens *int          `json:"remainingTokens,omitempty"`
+	// ResetTokens is how long it takes for the remaining tokens to reset to the limit.
+	ResetTokens time.Duration `json:"resetTokens,omitempty"`
+}
+
+// Timing is how fast a model responded to a completion request.
+type Timing struct {
+	// TimeToFirstToken is the time from sending the request to receiving the first content of the response.
+	TimeToFirstToken time.Duration `json:"timeToFirstToken,omitempty"`
+	// Duration is the time from sending the request to receiving the whole response.
+	Duration time.Duration `json:"duration,omitempty"`
+	// CompletionTokens are the tokens the rate is based on, which are estimated if the provider didn't report usage.
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	// TokensPerSecond is the rate that the completion tokens were streamed at after the first token.
+	TokensPerSecond float64 `json:"tokensPerSecond,omitempty"`
 }
 
 func (c CompletionMessage) IsToolCall() bool {
@@ -114,6 +250,9 @@ func (c CompletionMessage) String() string {
 		if content.ToolCall != nil {
 			buf.WriteString(fmt.Sprintf("<tool call> %s -> %s", color.GreenString(content.ToolCall.Function.Name), content.ToolCall.Function.Arguments))
 		}
+		if content.Image != nil {
+			buf.WriteString(fmt.Sprintf("<image> %s", content.Image))
+		}
 	}
 	return buf.String()
 }
@@ -121,6 +260,32 @@ func (c CompletionMessage) String() string {
 type ContentPart struct {
 	Text     string              `json:"text,omitempty"`
 	ToolCall *CompletionToolCall `json:"toolCall,omitempty"`
+	Image    *ImageContent       `json:"image,omitempty"`
+}
+
+// ImageContent is an image that is sent to vision-capable models, either by URL or as data.
+type ImageContent struct {
+	URL string `json:"url,omitempty"`
+	// Data is the image itself, which is base64 encoded in JSON. MIMEType is required with it.
+	Data     []byte `json:"data,omitempty"`
+	MIMEType string `json:"mimeType,omitempty"`
+	// Detail is the resolution the model sees the image at: low, high, or auto (the default).
+	Detail string `json:"detail,omitempty"`
+}
+
+// DataURL returns the URL of the image, which is a data URL when the image is sent as data.
+func (i ImageContent) DataURL() string {
+	if len(i.Data) == 0 {
+		return i.URL
+	}
+	return "data:" + i.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+func (i ImageContent) String() string {
+	if len(i.Data) == 0 {
+		return i.URL
+	}
+	return fmt.Sprintf("%s, %d bytes", i.MIMEType, len(i.Data))
 }
 
 type CompletionToolCall struct {
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// FileRefType is the type of the output of a tool that returns a file instead of its content.
+const FileRefType = "file"
+
+// FileRef is a file that a tool returns as its output, such as {"type": "file", "path": "report.pdf"}. The LLM is
+// given the reference instead of the content of the file, and passes it on to the file arguments of other tools.
+type FileRef struct {
+	Type string `json:"type"`
+	// Path is the path of the file, relative to the workspace unless it is absolute.
+	Path     string `json:"path,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	// URL is where a tool that doesn't share the workspace, such as an HTTP tool, serves the file, which may also be a
+	// data URL. The file is downloaded to the workspace.
+	URL string `json:"url,omitempty"`
+}
+
+// ParseFileRef returns the file reference that the output of a tool is, if it is one.
+func ParseFileRef(output string) (FileRef, bool) {
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "{") {
+		return FileRef{}, false
+	}
+
+	var ref FileRef
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ref); err != nil || dec.More() {
+		return FileRef{}, false
+	}
+	if ref.Type != FileRefType || (ref.Path == "" && ref.URL == "") {
+		return FileRef{}, false
+	}
+	return ref, true
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToolOverrides are model parameters that replace the ones defined by a tool for a single invocation.
+type ToolOverrides struct {
+	ModelName   string   `json:"modelName,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+}
+
+// ParseToolOverrides parses the args of a tool reference into overrides. Only the model, temperature, and maxTokens
+// args are recognized, all others are ignored. If none are set, nil is returned.
+// Example: "gpt-4o-mini as model and 0.2 as temperature and 500 as maxTokens"
+func ParseToolOverrides(arg string) (*ToolOverrides, error) {
+	if arg == "" || strings.HasPrefix(arg, "as ") {
+		return nil, nil
+	}
+
+	// The reference name is irrelevant, only the args after "with" are interesting
+	_, _, args, err := ParseCredentialArgs("tool with "+arg, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result ToolOverrides
+		found  bool
+	)
+	for k, v := range args {
+		value, _ := v.(string)
+		switch strings.ToLower(k) {
+		case "model":
+			result.ModelName = value
+		case "temperature":
+			temp, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid temperature %q: %w", value, err)
+			}
+			result.Temperature = &[]float32{float32(temp)}[0]
+		case "maxtokens":
+			maxTokens, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid maxTokens %q: %w", value, err)
+			}
+			result.MaxTokens = maxTokens
+		default:
+			continue
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+// Apply returns a copy of the tool with the overrides set. A nil receiver returns the tool unchanged.
+func (o *ToolOverrides) Apply(tool Tool) Tool {
+	if o == nil {
+		return tool
+	}
+	if o.ModelName != "" {
+		tool.Parameters.ModelName = o.ModelName
+	}
+	if o.Temperature != nil {
+		tool.Parameters.Temperature = o.Temperature
+	}
+	if o.MaxTokens != 0 {
+		tool.Parameters.MaxTokens = o.MaxTokens
+	}
+	return tool
+}
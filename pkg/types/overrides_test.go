@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolOverrides(t *testing.T) {
+	temp := float32(0.2)
+	tests := []struct {
+		name     string
+		arg      string
+		expected *ToolOverrides
+		wantErr  bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "alias only",
+			arg:  "as myAlias",
+		},
+		{
+			name: "unrelated args",
+			arg:  "value1 as arg1",
+		},
+		{
+			name: "model",
+			arg:  "gpt-4o-mini as model",
+			expected: &ToolOverrides{
+				ModelName: "gpt-4o-mini",
+			},
+		},
+		{
+			name: "all",
+			arg:  "gpt-4o-mini as model and 0.2 as temperature and 500 as maxTokens and value1 as arg1",
+			expected: &ToolOverrides{
+				ModelName:   "gpt-4o-mini",
+				Temperature: &temp,
+				MaxTokens:   500,
+			},
+		},
+		{
+			name:    "invalid temperature",
+			arg:     "hot as temperature",
+			wantErr: true,
+		},
+		{
+			name:    "invalid max tokens",
+			arg:     "many as maxTokens",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides, err := ParseToolOverrides(tt.arg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, overrides)
+		})
+	}
+}
+
+func TestToolOverridesApply(t *testing.T) {
+	tool := Tool{
+		ToolDef: ToolDef{
+			Parameters: Parameters{
+				ModelName: "gpt-4o",
+				MaxTokens: 100,
+			},
+		},
+	}
+
+	assert.Equal(t, tool, (*ToolOverrides)(nil).Apply(tool))
+
+	applied := (&ToolOverrides{ModelName: "gpt-4o-mini"}).Apply(tool)
+	assert.Equal(t, "gpt-4o-mini", applied.Parameters.ModelName)
+	assert.Equal(t, 100, applied.Parameters.MaxTokens)
+	assert.Equal(t, "gpt-4o", tool.Parameters.ModelName)
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -18,8 +19,15 @@ import (
 const (
 	DaemonPrefix  = "#!sys.daemon"
 	OpenAPIPrefix = "#!sys.openapi"
+	GraphQLPrefix = "#!sys.graphql"
+	GRPCPrefix    = "#!sys.grpc"
 	EchoPrefix    = "#!sys.echo"
+	AgentsPrefix  = "#!sys.agents"
 	CommandPrefix = "#!"
+
+	// ToolTypeAgents is the type of a tool that routes its input to one of its agents, like a tool whose body starts
+	// with AgentsPrefix.
+	ToolTypeAgents = "agents"
 )
 
 var (
@@ -108,6 +116,14 @@ func (p Program) TopLevelTools() (result []Tool) {
 	return
 }
 
+// SetOverrides returns a copy of the program with the overrides applied to the entry tool.
+func (p Program) SetOverrides(overrides ToolOverrides) Program {
+	tools := maps.Clone(p.ToolSet)
+	tools[p.EntryToolID] = overrides.Apply(tools[p.EntryToolID])
+	p.ToolSet = tools
+	return p
+}
+
 func (p Program) SetBlocking() Program {
 	tool := p.ToolSet[p.EntryToolID]
 	tool.Blocking = true
@@ -120,30 +136,107 @@ func (p Program) SetBlocking() Program {
 type BuiltinFunc func(ctx context.Context, env []string, input string, progress chan<- string) (string, error)
 
 type Parameters struct {
-	Name                string           `json:"name,omitempty"`
-	Description         string           `json:"description,omitempty"`
-	MaxTokens           int              `json:"maxTokens,omitempty"`
-	ModelName           string           `json:"modelName,omitempty"`
-	ModelProvider       bool             `json:"modelProvider,omitempty"`
-	JSONResponse        bool             `json:"jsonResponse,omitempty"`
-	Chat                bool             `json:"chat,omitempty"`
-	Temperature         *float32         `json:"temperature,omitempty"`
-	Cache               *bool            `json:"cache,omitempty"`
-	InternalPrompt      *bool            `json:"internalPrompt"`
-	Arguments           *openapi3.Schema `json:"arguments,omitempty"`
-	Tools               []string         `json:"tools,omitempty"`
-	GlobalTools         []string         `json:"globalTools,omitempty"`
-	GlobalModelName     string           `json:"globalModelName,omitempty"`
-	Context             []string         `json:"context,omitempty"`
-	ExportContext       []string         `json:"exportContext,omitempty"`
-	Export              []string         `json:"export,omitempty"`
-	Agents              []string         `json:"agents,omitempty"`
-	Credentials         []string         `json:"credentials,omitempty"`
-	InputFilters        []string         `json:"inputFilters,omitempty"`
-	ExportInputFilters  []string         `json:"exportInputFilters,omitempty"`
-	OutputFilters       []string         `json:"outputFilters,omitempty"`
-	ExportOutputFilters []string         `json:"exportOutputFilters,omitempty"`
-	Blocking            bool             `json:"-"`
+	Name           string           `json:"name,omitempty"`
+	Description    string           `json:"description,omitempty"`
+	MaxTokens      int              `json:"maxTokens,omitempty"`
+	ModelName      string           `json:"modelName,omitempty"`
+	ModelProvider  bool             `json:"modelProvider,omitempty"`
+	JSONResponse   bool             `json:"jsonResponse,omitempty"`
+	Chat           bool             `json:"chat,omitempty"`
+	Temperature    *float32         `json:"temperature,omitempty"`
+	Cache          *bool            `json:"cache,omitempty"`
+	InternalPrompt *bool            `json:"internalPrompt"`
+	Arguments      *openapi3.Schema `json:"arguments,omitempty"`
+	// CredentialArguments are the parameters that are filled from a credential instead of by the caller, by name.
+	CredentialArguments map[string]string `json:"credentialArguments,omitempty"`
+	Tools               []string          `json:"tools,omitempty"`
+	GlobalTools         []string          `json:"globalTools,omitempty"`
+	GlobalModelName     string            `json:"globalModelName,omitempty"`
+	Context             []string          `json:"context,omitempty"`
+	// Mixins are tools whose instructions, tools, agents, and context are added to this tool when it is loaded.
+	Mixins              []string  `json:"mixins,omitempty"`
+	ExportContext       []string  `json:"exportContext,omitempty"`
+	Export              []string  `json:"export,omitempty"`
+	Agents              []string  `json:"agents,omitempty"`
+	Credentials         []string  `json:"credentials,omitempty"`
+	EnvVars             []EnvVar  `json:"envVars,omitempty"`
+	Binaries            []Binary  `json:"binaries,omitempty"`
+	InputFilters        []string  `json:"inputFilters,omitempty"`
+	ExportInputFilters  []string  `json:"exportInputFilters,omitempty"`
+	OutputFilters       []string  `json:"outputFilters,omitempty"`
+	ExportOutputFilters []string  `json:"exportOutputFilters,omitempty"`
+	Examples            []Example `json:"examples,omitempty"`
+	Artifacts           []string  `json:"artifacts,omitempty"`
+	Validate            string    `json:"validate,omitempty"`
+	ValidateRetries     *int      `json:"validateRetries,omitempty"`
+	OutputRegex         string    `json:"outputRegex,omitempty"`
+	OutputGrammar       string    `json:"outputGrammar,omitempty"`
+	Choices             int       `json:"choices,omitempty"`
+	BestOf              int       `json:"bestOf,omitempty"`
+	Choose              string    `json:"choose,omitempty"`
+	TopP                *float32  `json:"topP,omitempty"`
+	Stop                []string  `json:"stop,omitempty"`
+	FrequencyPenalty    *float32  `json:"frequencyPenalty,omitempty"`
+	PresencePenalty     *float32  `json:"presencePenalty,omitempty"`
+	ParallelToolCalls   *bool     `json:"parallelToolCalls,omitempty"`
+	ToolChoice          string    `json:"toolChoice,omitempty"`
+	// LogProbs asks the LLM for the log probabilities of the tokens of its responses, and TopLogProbs for those of
+	// the most likely tokens at each position too.
+	LogProbs    bool `json:"logProbs,omitempty"`
+	TopLogProbs int  `json:"topLogProbs,omitempty"`
+	// ImageArguments are the parameters whose values are the URL or path of an image that is sent to the LLM.
+	ImageArguments []string `json:"imageArguments,omitempty"`
+	// FileArguments are the parameters whose values are the path of a file in the workspace, or a file that another
+	// tool returned, which is passed to the tool as a file.
+	FileArguments []string `json:"fileArguments,omitempty"`
+	// WorkDir is the directory that the tool, and the tools that it calls, run in. It may reference environment
+	// variables such as $GPTSCRIPT_WORKSPACE_DIR.
+	WorkDir string `json:"workDir,omitempty"`
+	// Breakpoint pauses the run before the tool is called, when breakpoints are handled.
+	Breakpoint bool `json:"breakpoint,omitempty"`
+	// Type is the type of the tool, which is empty for tools that run their instructions or ToolTypeAgents.
+	Type     string `json:"type,omitempty"`
+	Blocking bool   `json:"-"`
+}
+
+// EnvVar is an environment variable that a tool needs, which the user is asked for when it isn't set.
+type EnvVar struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Optional environment variables are only documented, the user isn't asked for them.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// String formats the environment variable like it is declared, such as "FOO? (the description)".
+func (e EnvVar) String() string {
+	s := e.Name
+	if e.Optional {
+		s += "?"
+	}
+	if e.Description != "" {
+		s += " (" + e.Description + ")"
+	}
+	return s
+}
+
+// Binary is a prebuilt executable of a tool for one platform, which is downloaded instead of setting up a runtime.
+type Binary struct {
+	// Platform is the OS and architecture, such as linux/amd64.
+	Platform string `json:"platform,omitempty"`
+	// URL is the executable, or an archive that contains it.
+	URL string `json:"url,omitempty"`
+	// SHA256 is the hex encoded digest of the file at URL.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+func (b Binary) String() string {
+	return fmt.Sprintf("%s %s sha256:%s", b.Platform, b.URL, b.SHA256)
+}
+
+// Example is an input and the expected output that is shown to the LLM before the real input.
+type Example struct {
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
 }
 
 func (p Parameters) ToolRefNames() []string {
@@ -154,10 +247,50 @@ func (p Parameters) ToolRefNames() []string {
 		p.ExportContext,
 		p.Context,
 		p.Credentials,
+		p.Mixins,
 		p.InputFilters,
 		p.ExportInputFilters,
 		p.OutputFilters,
-		p.ExportOutputFilters)
+		p.ExportOutputFilters,
+		p.validateToolRefNames(),
+		p.chooseToolRefNames())
+}
+
+// ValidateSchema returns true if Validate is an inline JSON schema instead of a reference to a tool.
+func (p Parameters) ValidateSchema() bool {
+	return strings.HasPrefix(strings.TrimSpace(p.Validate), "{")
+}
+
+func (p Parameters) validateToolRefNames() []string {
+	if p.Validate == "" || p.ValidateSchema() {
+		return nil
+	}
+	return []string{p.Validate}
+}
+
+// The strategies that select one of the candidate responses of a tool with more than one choice. Any other value of
+// Choose is a judge tool that is asked to choose.
+const (
+	ChooseFirst    = "first"
+	ChooseShortest = "shortest"
+	ChooseLongest  = "longest"
+	ChooseMajority = "majority"
+)
+
+// IsChooseStrategy returns true if the value of Choose is a built-in strategy instead of a reference to a tool.
+func IsChooseStrategy(choose string) bool {
+	switch strings.ToLower(strings.TrimSpace(choose)) {
+	case ChooseFirst, ChooseShortest, ChooseLongest, ChooseMajority:
+		return true
+	}
+	return false
+}
+
+func (p Parameters) chooseToolRefNames() []string {
+	if p.Choose == "" || IsChooseStrategy(p.Choose) {
+		return nil
+	}
+	return []string{p.Choose}
 }
 
 type ToolDef struct {
@@ -339,6 +472,18 @@ func ParseCredentialArgs(toolName string, input string) (string, string, map[str
 	return originalName, alias, args, nil
 }
 
+// CredentialArgumentPrefix starts the source of a parameter that is filled from a credential.
+// Example: "Parameter: token: from: credential:github-token"
+const CredentialArgumentPrefix = "credential:"
+
+// ParseCredentialArgument parses the source of a parameter that is filled from a credential, without the prefix, into
+// the name of the credential and the name of the value to use, which is empty when the credential has a single value.
+// Example: "github-token#GITHUB_TOKEN" -> github-token, GITHUB_TOKEN
+func ParseCredentialArgument(source string) (credName, key string) {
+	credName, key, _ = strings.Cut(source, "#")
+	return strings.TrimSpace(credName), strings.TrimSpace(key)
+}
+
 func (t Tool) GetAgents(prg Program) (result []ToolReference, _ error) {
 	toolRefs, err := t.GetToolRefsFromNames(t.Agents)
 	if err != nil {
@@ -414,6 +559,9 @@ func (t ToolDef) String() string {
 	if len(t.Parameters.Context) != 0 {
 		_, _ = fmt.Fprintf(buf, "Context: %s\n", strings.Join(t.Parameters.Context, ", "))
 	}
+	if len(t.Parameters.Mixins) != 0 {
+		_, _ = fmt.Fprintf(buf, "Mixins: %s\n", strings.Join(t.Parameters.Mixins, ", "))
+	}
 	if len(t.Parameters.ExportContext) != 0 {
 		_, _ = fmt.Fprintf(buf, "Share Context: %s\n", strings.Join(t.Parameters.ExportContext, ", "))
 	}
@@ -447,6 +595,37 @@ func (t ToolDef) String() string {
 	if t.Parameters.Temperature != nil {
 		_, _ = fmt.Fprintf(buf, "Temperature: %f\n", *t.Parameters.Temperature)
 	}
+	if t.Parameters.TopP != nil {
+		_, _ = fmt.Fprintf(buf, "Top P: %f\n", *t.Parameters.TopP)
+	}
+	if len(t.Parameters.Stop) > 0 {
+		stop := make([]string, 0, len(t.Parameters.Stop))
+		for _, s := range t.Parameters.Stop {
+			// Sequences that would not be parsed back as they are must be quoted.
+			if s != strings.TrimSpace(s) || strings.ContainsAny(s, ",\"\n") {
+				s = strconv.Quote(s)
+			}
+			stop = append(stop, s)
+		}
+		_, _ = fmt.Fprintf(buf, "Stop: %s\n", strings.Join(stop, ", "))
+	}
+	if t.Parameters.FrequencyPenalty != nil {
+		_, _ = fmt.Fprintf(buf, "Frequency Penalty: %f\n", *t.Parameters.FrequencyPenalty)
+	}
+	if t.Parameters.PresencePenalty != nil {
+		_, _ = fmt.Fprintf(buf, "Presence Penalty: %f\n", *t.Parameters.PresencePenalty)
+	}
+	if t.Parameters.ParallelToolCalls != nil && !*t.Parameters.ParallelToolCalls {
+		_, _ = fmt.Fprintln(buf, "Parallel Tool Calls: false")
+	}
+	if t.Parameters.ToolChoice != "" {
+		_, _ = fmt.Fprintf(buf, "Tool Choice: %s\n", t.Parameters.ToolChoice)
+	}
+	if t.Parameters.TopLogProbs > 0 {
+		_, _ = fmt.Fprintf(buf, "Top Logprobs: %d\n", t.Parameters.TopLogProbs)
+	} else if t.Parameters.LogProbs {
+		_, _ = fmt.Fprintln(buf, "Logprobs: true")
+	}
 	if t.Parameters.Arguments != nil {
 		var keys []string
 		for k := range t.Parameters.Arguments.Properties {
@@ -455,9 +634,30 @@ func (t ToolDef) String() string {
 		sort.Strings(keys)
 		for _, key := range keys {
 			prop := t.Parameters.Arguments.Properties[key]
-			_, _ = fmt.Fprintf(buf, "Parameter: %s: %s\n", key, prop.Value.Description)
+			description := prop.Value.Description
+			// Descriptions that would not be parsed back as they are must be quoted.
+			if strings.Contains(description, "\n") || strings.HasPrefix(description, `"`) || strings.HasPrefix(description, "<<") || strings.HasPrefix(description, "from:") {
+				description = strconv.Quote(description)
+			}
+			_, _ = fmt.Fprintf(buf, "Parameter: %s: %s\n", key, description)
+		}
+	}
+	if len(t.Parameters.CredentialArguments) > 0 {
+		keys := maps.Keys(t.Parameters.CredentialArguments)
+		sort.Strings(keys)
+		for _, key := range keys {
+			_, _ = fmt.Fprintf(buf, "Parameter: %s: from: %s%s\n", key, CredentialArgumentPrefix, t.Parameters.CredentialArguments[key])
 		}
 	}
+	if len(t.Parameters.ImageArguments) > 0 {
+		_, _ = fmt.Fprintf(buf, "Image Args: %s\n", strings.Join(t.Parameters.ImageArguments, ", "))
+	}
+	if len(t.Parameters.FileArguments) > 0 {
+		_, _ = fmt.Fprintf(buf, "File Args: %s\n", strings.Join(t.Parameters.FileArguments, ", "))
+	}
+	if t.Parameters.WorkDir != "" {
+		_, _ = fmt.Fprintf(buf, "Working Dir: %s\n", t.Parameters.WorkDir)
+	}
 	if t.Parameters.InternalPrompt != nil {
 		_, _ = fmt.Fprintf(buf, "Internal Prompt: %v\n", *t.Parameters.InternalPrompt)
 	}
@@ -466,9 +666,57 @@ func (t ToolDef) String() string {
 			_, _ = fmt.Fprintf(buf, "Credential: %s\n", cred)
 		}
 	}
+	if len(t.Parameters.EnvVars) > 0 {
+		envVars := make([]string, 0, len(t.Parameters.EnvVars))
+		for _, envVar := range t.Parameters.EnvVars {
+			envVars = append(envVars, envVar.String())
+		}
+		_, _ = fmt.Fprintf(buf, "Env Vars: %s\n", strings.Join(envVars, ", "))
+	}
+	for _, binary := range t.Parameters.Binaries {
+		_, _ = fmt.Fprintf(buf, "Binary: %s\n", binary)
+	}
 	if t.Parameters.Chat {
 		_, _ = fmt.Fprintf(buf, "Chat: true\n")
 	}
+	if t.Parameters.Type != "" {
+		_, _ = fmt.Fprintf(buf, "Type: %s\n", t.Parameters.Type)
+	}
+	if t.Parameters.Breakpoint {
+		_, _ = fmt.Fprintln(buf, "Breakpoint: true")
+	}
+	for _, example := range t.Parameters.Examples {
+		if strings.Contains(example.Input, " => ") {
+			_, _ = fmt.Fprintf(buf, "Example Input: %s\n", example.Input)
+			_, _ = fmt.Fprintf(buf, "Example Output: %s\n", example.Output)
+		} else {
+			_, _ = fmt.Fprintf(buf, "Examples: %s => %s\n", example.Input, example.Output)
+		}
+	}
+	if len(t.Parameters.Artifacts) != 0 {
+		_, _ = fmt.Fprintf(buf, "Artifacts: %s\n", strings.Join(t.Parameters.Artifacts, ", "))
+	}
+	if t.Parameters.Validate != "" {
+		_, _ = fmt.Fprintf(buf, "Validate: %s\n", t.Parameters.Validate)
+	}
+	if t.Parameters.ValidateRetries != nil {
+		_, _ = fmt.Fprintf(buf, "Validate Retries: %d\n", *t.Parameters.ValidateRetries)
+	}
+	if t.Parameters.OutputRegex != "" {
+		_, _ = fmt.Fprintf(buf, "Output Regex: %s\n", t.Parameters.OutputRegex)
+	}
+	if t.Parameters.OutputGrammar != "" {
+		_, _ = fmt.Fprintf(buf, "Output Grammar: %s\n", t.Parameters.OutputGrammar)
+	}
+	if t.Parameters.Choices != 0 {
+		_, _ = fmt.Fprintf(buf, "Choices: %d\n", t.Parameters.Choices)
+	}
+	if t.Parameters.BestOf != 0 {
+		_, _ = fmt.Fprintf(buf, "Best Of: %d\n", t.Parameters.BestOf)
+	}
+	if t.Parameters.Choose != "" {
+		_, _ = fmt.Fprintf(buf, "Choose: %s\n", t.Parameters.Choose)
+	}
 
 	// Instructions should be printed last
 	if t.Instructions != "" && t.BuiltinFunc == nil {
@@ -556,6 +804,38 @@ func (t Tool) GetOutputFilterTools(program Program) ([]ToolReference, error) {
 	return result.List()
 }
 
+// GetValidateTool returns the tool that validates the output of this tool, if Validate references a tool.
+func (t Tool) GetValidateTool() (ToolReference, bool, error) {
+	names := t.validateToolRefNames()
+	if len(names) == 0 {
+		return ToolReference{}, false, nil
+	}
+	refs, err := t.GetToolRefsFromNames(names)
+	if err != nil {
+		return ToolReference{}, false, err
+	}
+	if len(refs) != 1 {
+		return ToolReference{}, false, fmt.Errorf("validate must reference exactly one tool, %s matches %d", t.Validate, len(refs))
+	}
+	return refs[0], true, nil
+}
+
+// GetChooseTool returns the judge tool that chooses the response of this tool, if Choose references a tool.
+func (t Tool) GetChooseTool() (ToolReference, bool, error) {
+	names := t.chooseToolRefNames()
+	if len(names) == 0 {
+		return ToolReference{}, false, nil
+	}
+	refs, err := t.GetToolRefsFromNames(names)
+	if err != nil {
+		return ToolReference{}, false, err
+	}
+	if len(refs) != 1 {
+		return ToolReference{}, false, fmt.Errorf("choose must reference exactly one tool, %s matches %d", t.Choose, len(refs))
+	}
+	return refs[0], true, nil
+}
+
 func (t Tool) GetInputFilterTools(program Program) ([]ToolReference, error) {
 	result := &toolRefSet{}
 
@@ -600,7 +880,20 @@ func (t Tool) GetCompletionTools(prg Program, agentGroup ...ToolReference) (resu
 	if err != nil {
 		return nil, err
 	}
-	return toolRefsToCompletionTools(refs, prg), nil
+	return toolRefsToCompletionTools(refs, prg)
+}
+
+// GetAgentCompletionTools returns the agents of the tool as the tools of a completion, without the tool itself.
+func (t Tool) GetAgentCompletionTools(prg Program) ([]CompletionTool, error) {
+	var result toolRefSet
+	if err := t.addAgents(prg, &result); err != nil {
+		return nil, err
+	}
+	refs, err := result.List()
+	if err != nil {
+		return nil, err
+	}
+	return toolRefsToCompletionTools(refs, prg)
 }
 
 func (t Tool) addAgents(prg Program, result *toolRefSet) error {
@@ -630,13 +923,32 @@ func (t Tool) addReferencedTools(prg Program, result *toolRefSet) error {
 		// Add the tool
 		result.Add(subToolRef)
 
-		// Get all tools exports
-		result.AddAll(prg.ToolSet[subToolRef.ToolID].GetExportedTools(prg))
+		// Get all tools exports, namespaced by the alias of the tool if there is one
+		exportedRefs, err := prg.ToolSet[subToolRef.ToolID].GetExportedTools(prg)
+		if err != nil {
+			return err
+		}
+		for _, exportedRef := range exportedRefs {
+			if subToolRef.Named != "" {
+				exportedRef.Named = namespacedToolName(subToolRef.Named, exportedRef)
+			}
+			result.Add(exportedRef)
+		}
 	}
 
 	return nil
 }
 
+// namespacedToolName returns the name of an exported tool prefixed with the alias of the tool that exported it,
+// for example "acme.search".
+func namespacedToolName(namespace string, ref ToolReference) string {
+	name := ref.Named
+	if name == "" {
+		name = ref.Reference
+	}
+	return namespace + "." + ToolNormalizer(name)
+}
+
 func (t Tool) addContextExportedTools(prg Program, result *toolRefSet) error {
 	contextTools, err := t.GetContextTools(prg)
 	if err != nil {
@@ -675,7 +987,7 @@ func (t Tool) getCompletionToolRefs(prg Program, agentGroup []ToolReference) ([]
 	return result.List()
 }
 
-func toolRefsToCompletionTools(completionTools []ToolReference, prg Program) (result []CompletionTool) {
+func toolRefsToCompletionTools(completionTools []ToolReference, prg Program) (result []CompletionTool, _ error) {
 	toolNames := map[string]struct{}{}
 
 	for _, subToolRef := range completionTools {
@@ -696,12 +1008,17 @@ func toolRefsToCompletionTools(completionTools []ToolReference, prg Program) (re
 		if subTool.Instructions == "" {
 			log.Debugf("Skipping zero instruction tool %s (%s)", subToolName, subTool.ID)
 		} else {
+			overrides, err := ParseToolOverrides(subToolRef.Arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference to tool %s: %w", subToolName, err)
+			}
 			result = append(result, CompletionTool{
 				Function: CompletionFunctionDefinition{
 					ToolID:      subTool.ID,
 					Name:        PickToolName(subToolName, toolNames),
 					Description: subTool.Parameters.Description,
 					Parameters:  args,
+					Overrides:   overrides,
 				},
 			})
 		}
@@ -763,10 +1080,23 @@ func (t Tool) IsOpenAPI() bool {
 	return strings.HasPrefix(t.Instructions, OpenAPIPrefix)
 }
 
+func (t Tool) IsGraphQL() bool {
+	return strings.HasPrefix(t.Instructions, GraphQLPrefix)
+}
+
+func (t Tool) IsGRPC() bool {
+	return strings.HasPrefix(t.Instructions, GRPCPrefix)
+}
+
 func (t Tool) IsEcho() bool {
 	return strings.HasPrefix(t.Instructions, EchoPrefix)
 }
 
+// IsAgents returns true for a tool that routes its input to one of its agents instead of running instructions.
+func (t Tool) IsAgents() bool {
+	return t.Type == ToolTypeAgents || strings.HasPrefix(t.Instructions, AgentsPrefix)
+}
+
 func (t Tool) IsHTTP() bool {
 	return strings.HasPrefix(t.Instructions, "#!http://") ||
 		strings.HasPrefix(t.Instructions, "#!https://")
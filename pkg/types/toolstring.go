@@ -52,6 +52,16 @@ func ToSysDisplayString(id string, args map[string]string) (string, error) {
 		} else {
 			return fmt.Sprintf("Downloading `%s` to workspace", args["url"]), nil
 		}
+	case "sys.doc.extract":
+		return fmt.Sprintf("Extracting text from `%s`", args["filename"]), nil
+	case "sys.email.read":
+		mailbox := args["mailbox"]
+		if mailbox == "" {
+			mailbox = "INBOX"
+		}
+		return fmt.Sprintf("Reading email from `%s`", mailbox), nil
+	case "sys.email.send":
+		return fmt.Sprintf("Sending email to `%s`", args["to"]), nil
 	case "sys.exec":
 		return fmt.Sprintf("Running `%s`", args["command"]), nil
 	case "sys.find":
@@ -66,6 +76,19 @@ func ToSysDisplayString(id string, args map[string]string) (string, error) {
 		return fmt.Sprintf("Sending to `%s`", args["url"]), nil
 	case "sys.http.html2text":
 		return fmt.Sprintf("Downloading `%s`", args["url"]), nil
+	case "sys.k8s.apply":
+		if args["dryRun"] == "false" {
+			return "Applying Kubernetes manifest", nil
+		}
+		return "Validating Kubernetes manifest", nil
+	case "sys.k8s.describe":
+		return fmt.Sprintf("Describing %s `%s`", args["kind"], args["name"]), nil
+	case "sys.k8s.get":
+		return fmt.Sprintf("Getting %s `%s`", args["kind"], args["name"]), nil
+	case "sys.k8s.list":
+		return fmt.Sprintf("Listing %s", args["kind"]), nil
+	case "sys.k8s.logs":
+		return fmt.Sprintf("Reading logs of pod `%s`", args["pod"]), nil
 	case "sys.ls":
 		return fmt.Sprintf("Listing `%s`", args["dir"]), nil
 	case "sys.read":
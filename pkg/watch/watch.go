@@ -0,0 +1,243 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+)
+
+var log = mvl.Package()
+
+const (
+	Created  = "created"
+	Modified = "modified"
+)
+
+// Event is a change to a file in the watched directory.
+type Event struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Type is Created or Modified.
+	Type string `json:"event"`
+}
+
+type Options struct {
+	// Patterns are matched against the path of files relative to the directory and their base name, every file
+	// matches if empty.
+	Patterns []string
+	// Interval is how often the directory is scanned for changes.
+	Interval time.Duration
+	// Debounce is how long a file must go unchanged before its event is handled, so that a file being written is
+	// handled once it is complete.
+	Debounce time.Duration
+	// Concurrency is the maximum number of events handled at once.
+	Concurrency int
+	// Existing handles the files that exist when the watch starts as created.
+	Existing bool
+}
+
+func complete(opts Options) Options {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Debounce < 0 {
+		opts.Debounce = 0
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	return opts
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+type pending struct {
+	event   Event
+	changed time.Time
+}
+
+// Watch scans the directory for files matching the patterns and calls the handler for every file that is created or
+// modified, until the context is cancelled. Changes to a file while its handler is running are handled again once
+// the handler returns.
+func Watch(ctx context.Context, dir string, opts Options, handler func(context.Context, Event)) error {
+	opts = complete(opts)
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range opts.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	w := &watcher{
+		dir:     dir,
+		opts:    opts,
+		pending: map[string]pending{},
+		running: map[string]bool{},
+		sem:     make(chan struct{}, opts.Concurrency),
+	}
+
+	w.files, err = w.scan()
+	if err != nil {
+		return err
+	}
+	if opts.Existing {
+		for path := range w.files {
+			w.pending[path] = pending{event: Event{Path: path, Type: Created}}
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.dispatch(ctx, &wg, handler)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		files, err := w.scan()
+		if err != nil {
+			log.Errorf("Failed to scan %s: %v", dir, err)
+			continue
+		}
+		w.diff(files)
+	}
+}
+
+type watcher struct {
+	dir     string
+	opts    Options
+	files   map[string]fileState
+	pending map[string]pending
+	sem     chan struct{}
+
+	lock    sync.Mutex
+	running map[string]bool
+}
+
+func (w *watcher) diff(files map[string]fileState) {
+	now := time.Now()
+	for path, state := range files {
+		old, ok := w.files[path]
+		switch {
+		case !ok:
+			w.pending[path] = pending{event: Event{Path: path, Type: Created}, changed: now}
+		case old != state:
+			event := Event{Path: path, Type: Modified}
+			if p, ok := w.pending[path]; ok {
+				// A file created and then written to is still reported as created.
+				event.Type = p.event.Type
+			}
+			w.pending[path] = pending{event: event, changed: now}
+		}
+	}
+	for path := range w.pending {
+		if _, ok := files[path]; !ok {
+			delete(w.pending, path)
+		}
+	}
+	w.files = files
+}
+
+// dispatch handles the pending events that have settled, as long as there is capacity to run them.
+func (w *watcher) dispatch(ctx context.Context, wg *sync.WaitGroup, handler func(context.Context, Event)) {
+	for path, p := range w.pending {
+		if time.Since(p.changed) < w.opts.Debounce {
+			continue
+		}
+
+		w.lock.Lock()
+		running := w.running[path]
+		w.lock.Unlock()
+		if running {
+			continue
+		}
+
+		select {
+		case w.sem <- struct{}{}:
+		default:
+			// At the concurrency limit, the remaining events wait for the next scan.
+			return
+		}
+
+		delete(w.pending, path)
+		w.lock.Lock()
+		w.running[path] = true
+		w.lock.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				w.lock.Lock()
+				delete(w.running, path)
+				w.lock.Unlock()
+				<-w.sem
+			}()
+			handler(ctx, p.event)
+		}()
+	}
+}
+
+func (w *watcher) scan() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !w.matches(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		files[path] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (w *watcher) matches(path string) bool {
+	if len(w.opts.Patterns) == 0 {
+		return true
+	}
+	rel, err := filepath.Rel(w.dir, path)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range w.opts.Patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("old"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, dir, Options{
+			Patterns: []string{"*.txt"},
+			Interval: 10 * time.Millisecond,
+			Debounce: 50 * time.Millisecond,
+		}, func(_ context.Context, event Event) {
+			events <- event
+		})
+	}()
+
+	// Give the watch time to take its initial scan.
+	time.Sleep(30 * time.Millisecond)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "new.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.json"), []byte("{}"), 0644))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "new.txt"), []byte("ab"), 0644))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, Event{Path: filepath.Join(dir, "sub", "new.txt"), Type: Created}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("new content"), 0644))
+	select {
+	case event := <-events:
+		assert.Equal(t, Event{Path: filepath.Join(dir, "existing.txt"), Type: Modified}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+)
+
+var log = mvl.Package()
+
+// maxBodySize is the largest request body the webhook server accepts.
+const maxBodySize = 10 << 20
+
+// Route maps a path of the server to a tool.
+type Route struct {
+	Path string
+	Tool string
+	// Secret verifies the HMAC signature of requests to the path, requests are not verified if empty.
+	Secret string
+}
+
+// Request is the input given to the tool of a route.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// RunFunc runs the tool with the JSON encoded Request as input and returns its output.
+type RunFunc func(ctx context.Context, tool, input string) (string, error)
+
+type Options struct {
+	// Wait makes requests wait for the tool to finish and respond with its output, instead of responding with 202
+	// Accepted as soon as the request is verified.
+	Wait bool
+	Run  RunFunc
+}
+
+type Server struct {
+	handler http.Handler
+	wg      sync.WaitGroup
+	ctx     context.Context
+}
+
+// NewServer returns a handler that runs the tool of the route matching the path of each POST request. Tools started
+// in the background are given ctx, and Wait waits for them to finish.
+func NewServer(ctx context.Context, routes []Route, opts Options) *Server {
+	s := &Server{
+		ctx: ctx,
+	}
+
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		mux.HandleFunc("POST "+route.Path, func(w http.ResponseWriter, r *http.Request) {
+			s.handle(w, r, route, opts)
+		})
+	}
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.handler = mux
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Wait waits for the tools running in the background to finish.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, route Route, opts Options) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if route.Secret != "" {
+		if err := Verify([]byte(route.Secret), r.Header, body, time.Now()); err != nil {
+			log.Infof("Rejected request to %s: %v", route.Path, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	input, err := json.Marshal(newRequest(r, body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !opts.Wait {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if _, err := opts.Run(s.ctx, route.Tool, string(input)); err != nil {
+				log.Errorf("Run of %s for %s failed: %v", route.Tool, route.Path, err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	out, err := opts.Run(r.Context(), route.Tool, string(input))
+	if err != nil {
+		log.Errorf("Run of %s for %s failed: %v", route.Tool, route.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if json.Valid([]byte(out)) {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, _ = w.Write([]byte(out))
+}
+
+func newRequest(r *http.Request, body []byte) Request {
+	req := Request{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Headers: map[string]string{},
+		Body:    string(body),
+	}
+	for k, v := range r.Header {
+		req.Headers[strings.ToLower(k)] = strings.Join(v, ", ")
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		req.Query = map[string]string{}
+		for k, v := range query {
+			req.Query[k] = strings.Join(v, ",")
+		}
+	}
+	return req
+}
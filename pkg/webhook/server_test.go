@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	var (
+		now    = time.Now()
+		ts     = strconv.FormatInt(now.Unix(), 10)
+		oldTS  = strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+		body   = `{"action":"opened"}`
+		secret = "secret"
+	)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		valid  bool
+	}{
+		{"github", http.Header{"X-Hub-Signature-256": {"sha256=" + sign(secret, body)}}, true},
+		{"github wrong secret", http.Header{"X-Hub-Signature-256": {"sha256=" + sign("other", body)}}, false},
+		{"stripe", http.Header{"Stripe-Signature": {fmt.Sprintf("t=%s,v1=%s", ts, sign(secret, ts+"."+body))}}, true},
+		{"stripe replay", http.Header{"Stripe-Signature": {fmt.Sprintf("t=%s,v1=%s", oldTS, sign(secret, oldTS+"."+body))}}, false},
+		{"slack", http.Header{"X-Slack-Signature": {"v0=" + sign(secret, "v0:"+ts+":"+body)}, "X-Slack-Request-Timestamp": {ts}}, true},
+		{"generic", http.Header{"X-Signature-256": {sign(secret, body)}}, true},
+		{"missing", http.Header{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify([]byte(secret), test.header, []byte(body), now)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(context.Background(), []Route{
+		{Path: "/github", Tool: "github.gpt", Secret: "secret"},
+		{Path: "/open", Tool: "open.gpt"},
+	}, Options{
+		Wait: true,
+		Run: func(_ context.Context, tool, input string) (string, error) {
+			var req Request
+			if err := json.Unmarshal([]byte(input), &req); err != nil {
+				return "", err
+			}
+			return tool + " " + req.Headers["x-github-event"] + " " + req.Query["a"] + " " + req.Body, nil
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/github?a=b", strings.NewReader("payload"))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("secret", "payload"))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "github.gpt push b payload", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/github", strings.NewReader("payload"))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("wrong", "payload"))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/open", strings.NewReader("hi")))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "open.gpt   hi", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
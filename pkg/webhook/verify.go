@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTimestampSkew is how old a signed timestamp can be before the request is rejected as a replay.
+const maxTimestampSkew = 5 * time.Minute
+
+// Verify checks the HMAC-SHA256 signature of the body using the signature scheme of GitHub (X-Hub-Signature-256),
+// Stripe (Stripe-Signature), Slack (X-Slack-Signature), or a generic X-Signature-256 header holding the hex encoded
+// signature of the body, optionally prefixed by "sha256=".
+func Verify(secret []byte, header http.Header, body []byte, now time.Time) error {
+	switch {
+	case header.Get("X-Hub-Signature-256") != "":
+		return verifyHex(secret, body, strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256="))
+	case header.Get("Stripe-Signature") != "":
+		return verifyStripe(secret, header.Get("Stripe-Signature"), body, now)
+	case header.Get("X-Slack-Signature") != "":
+		ts := header.Get("X-Slack-Request-Timestamp")
+		if err := checkTimestamp(ts, now); err != nil {
+			return err
+		}
+		return verifyHex(secret, []byte("v0:"+ts+":"+string(body)), strings.TrimPrefix(header.Get("X-Slack-Signature"), "v0="))
+	case header.Get("X-Signature-256") != "":
+		return verifyHex(secret, body, strings.TrimPrefix(header.Get("X-Signature-256"), "sha256="))
+	}
+	return fmt.Errorf("missing signature header")
+}
+
+func verifyStripe(secret []byte, signature string, body []byte, now time.Time) error {
+	var (
+		ts         string
+		signatures []string
+	)
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+	if err := checkTimestamp(ts, now); err != nil {
+		return err
+	}
+	for _, sig := range signatures {
+		if verifyHex(secret, []byte(ts+"."+string(body)), sig) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+func checkTimestamp(ts string, now time.Time) error {
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", ts)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > maxTimestampSkew || skew < -maxTimestampSkew {
+		return fmt.Errorf("signature timestamp is too old")
+	}
+	return nil
+}
+
+func verifyHex(secret, data []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}